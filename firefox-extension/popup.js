@@ -141,10 +141,25 @@ function initExtension() {
                 document.getElementById("bookmark-title").value = activeTab.title || "";
             if (document.getElementById("bookmark-url"))
                 document.getElementById("bookmark-url").value = activeTab.url || "";
+            if (apiToken && activeTab.url) lookupBookmark(activeTab);
         });
     }
 }
 
+// lookupBookmark tells whether the page in the tab is already bookmarked,
+// and marks the toolbar button of that tab if so
+function lookupBookmark(tab) {
+    fetch(`${API_BASE}/bookmarks/lookup?url=${encodeURIComponent(tab.url)}`, { headers: apiHeaders() })
+        .then(r => r.ok ? r.json() : null)
+        .then(bookmark => {
+            if (!bookmark) return;
+            const tags = bookmark.tags && bookmark.tags.length ? ` (${bookmark.tags.join(", ")})` : "";
+            showStatus(`Already saved on ${bookmark.created_at.slice(0, 10)}${tags}.`);
+            if (browser.action) browser.action.setBadgeText({ text: "✓", tabId: tab.id });
+        })
+        .catch(() => { });
+}
+
 function saveToken() {
     const input = document.getElementById("api-token-input").value.trim();
     if (!input) {
@@ -179,8 +194,8 @@ function submitData(endpoint, data) {
             if (!response.ok) throw new Error("Server error");
             return response.json();
         })
-        .then(() => {
-            showStatus("Saved successfully!");
+        .then(data => {
+            showStatus(data && data.status === "exists" ? "Already saved." : "Saved successfully!");
             document.querySelector(".tab-content.active form")?.reset();
             const chips = document.querySelector(".tab-content.active .tag-chips");
             if (chips) chips.innerHTML = "";
@@ -212,6 +227,7 @@ document.addEventListener("DOMContentLoaded", () => {
             title: document.getElementById("bookmark-title").value,
             url: document.getElementById("bookmark-url").value,
             description: document.getElementById("bookmark-desc").value,
+            notes: document.getElementById("bookmark-notes").value,
             tags: document.getElementById("bookmark-tags").value
         });
     });
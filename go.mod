@@ -3,10 +3,12 @@ module infokeep
 go 1.24.1
 
 require (
-	github.com/SherClockHolmes/webpush-go v1.4.0 // indirect
-	github.com/go-chi/chi/v5 v5.2.5 // indirect
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-	github.com/mattn/go-sqlite3 v1.14.34 // indirect
-	golang.org/x/crypto v0.48.0 // indirect
-	golang.org/x/net v0.50.0 // indirect
+	github.com/SherClockHolmes/webpush-go v1.4.0
+	github.com/go-chi/chi/v5 v5.2.5
+	github.com/go-pdf/fpdf v0.9.0
+	github.com/mattn/go-sqlite3 v1.14.34
+	golang.org/x/crypto v0.48.0
+	golang.org/x/net v0.50.0
 )
+
+require github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
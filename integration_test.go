@@ -0,0 +1,1760 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/handlers"
+)
+
+// The integration tests run the whole server (router, middleware, handlers
+// and a fresh SQLite database) in a temporary directory and use it the way
+// a browser does: register, log in and click through the main flows.
+
+// startServer serves newRouter from a temporary working directory with its
+// own data directory, so tests leave the repository alone
+func startServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	if err := datadir.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitDB(datadir.DB()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	if err := handlers.LoadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	handlers.LoadMaintenanceMode()
+	handlers.LoadAnnouncement()
+
+	srv := httptest.NewServer(newRouter())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// client is a browser session with the test server
+type client struct {
+	t    *testing.T
+	base string
+	http *http.Client
+	last string // the last request, for failure messages
+}
+
+func newClient(t *testing.T, srv *httptest.Server) *client {
+	jar, _ := cookiejar.New(nil)
+	return &client{t: t, base: srv.URL, http: &http.Client{Jar: jar}}
+}
+
+// do sends a request and returns the status and body of the final response
+func (c *client) do(method, path, contentType string, body io.Reader, header ...string) (int, string) {
+	c.t.Helper()
+	c.last = method + " " + path
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func (c *client) get(path string) (int, string) {
+	c.t.Helper()
+	return c.do("GET", path, "", nil)
+}
+
+// fragment gets what HTMX loads into a page, e.g. the cards of a list page
+func (c *client) fragment(path string) (int, string) {
+	c.t.Helper()
+	return c.do("GET", path, "", nil, "HX-Request", "true")
+}
+
+func (c *client) postForm(path string, values url.Values) (int, string) {
+	c.t.Helper()
+	return c.do("POST", path, "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+}
+
+// postMultipart posts fields and files (by field name, each a file name and
+// its content)
+func (c *client) postMultipart(path string, fields map[string]string, files map[string][2]string) (int, string) {
+	c.t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for field, f := range files {
+		fw, _ := mw.CreateFormFile(field, f[0])
+		fw.Write([]byte(f[1]))
+	}
+	mw.Close()
+	return c.do("POST", path, mw.FormDataContentType(), &buf)
+}
+
+// mustOK fails the test unless the last request's status is 200, and
+// returns its body
+func (c *client) mustOK(status int, body string) string {
+	c.t.Helper()
+	if status != http.StatusOK {
+		c.t.Fatalf("%s: status %d: %.300s", c.last, status, body)
+	}
+	return body
+}
+
+// signUp registers a user and logs in as them
+func (c *client) signUp(username string) {
+	c.t.Helper()
+	password := "correct horse battery staple"
+	c.mustOK(c.postForm("/register", url.Values{"username": {username}, "password": {password}}))
+	c.mustOK(c.postForm("/login", url.Values{"username": {username}, "password": {password}}))
+}
+
+// export returns the user's data as the JSON export has it
+func (c *client) export() map[string][]map[string]interface{} {
+	c.t.Helper()
+	return c.exportQuery("")
+}
+
+// exportQuery returns the items the JSON export has for the filter in query
+func (c *client) exportQuery(query string) map[string][]map[string]interface{} {
+	c.t.Helper()
+	body := c.mustOK(c.get("/settings/export?format=json" + query))
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		c.t.Fatalf("export: %v", err)
+	}
+	items := make(map[string][]map[string]interface{})
+	for _, key := range []string{"bookmarks", "notes", "drawings", "lists", "rated_lists", "recipes", "media"} {
+		var list []map[string]interface{}
+		json.Unmarshal(data[key], &list)
+		items[key] = list
+	}
+	return items
+}
+
+// pngImage is a small PNG file
+func pngImage(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// createEverything creates one item of each type through the web forms
+func createEverything(t *testing.T, c *client) {
+	img := pngImage(t)
+	c.mustOK(c.postForm("/notes", url.Values{
+		"title": {"Sourdough starter"}, "content": {"Feed the starter with rye flour"}, "tags": {"baking, kitchen"}}))
+	c.mustOK(c.postForm("/bookmarks", url.Values{
+		"title": {"Flour guide"}, "url": {"http://127.0.0.1:1/flour"}, "tags": {"baking"}}))
+	c.mustOK(c.postForm("/lists", url.Values{"title": {"Groceries"}, "tags": {"kitchen"}}))
+	c.mustOK(c.postForm("/rated-lists", url.Values{"title": {"Bakeries"}}))
+	c.mustOK(c.postMultipart("/recipes", map[string]string{
+		"title": "Rye bread", "ingredients": "500 g rye flour\n10 g salt", "instructions": "Bake for 45 minutes",
+		"tags": "baking"}, nil))
+	c.mustOK(c.postForm("/drawings", url.Values{
+		"title": {"Oven sketch"}, "image": {"data:image/png;base64," + base64.StdEncoding.EncodeToString(img)}}))
+	c.mustOK(c.postMultipart("/media", map[string]string{"title": "Crumb shot"},
+		map[string][2]string{"file": {"crumb.png", string(img)}}))
+}
+
+func TestLoginRequired(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+
+	body := c.mustOK(c.get("/"))
+	if !strings.Contains(body, `name="password"`) {
+		t.Errorf("home without a session should show the login form, got %.300s", body)
+	}
+	if status, _ := c.get("/api/stats"); status != http.StatusUnauthorized {
+		t.Errorf("API without a session: status %d, want 401", status)
+	}
+
+	c.signUp("alice")
+	c.mustOK(c.postForm("/login", url.Values{"username": {"alice"}, "password": {"wrong"}}))
+	body = c.mustOK(c.get("/settings"))
+	if !strings.Contains(body, "API") {
+		t.Errorf("settings after logging in: %.300s", body)
+	}
+}
+
+func TestItemFlows(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+
+	data := c.export()
+	var ids []string
+	for key, items := range data {
+		if len(items) != 1 {
+			t.Fatalf("export has %d %s, want 1", len(items), key)
+		}
+		ids = append(ids, strconv.FormatInt(int64(items[0]["id"].(float64)), 10))
+	}
+	if tags := data["notes"][0]["tags"]; len(tags.([]interface{})) != 2 {
+		t.Errorf("note tags = %v, want baking and kitchen", tags)
+	}
+
+	// Tag everything at once
+	c.mustOK(c.postForm("/items/bulk", url.Values{"ids": {strings.Join(ids, ",")}, "action": {"tag-add"}, "tag": {"Reviewed"}}))
+	counts := make(map[string]int)
+	for _, items := range c.export() {
+		for _, tag := range items[0]["tags"].([]interface{}) {
+			counts[tag.(string)]++
+		}
+	}
+	if counts["reviewed"] != len(ids) || counts["baking"] != 3 || counts["kitchen"] != 2 {
+		t.Errorf("tag counts = %v", counts)
+	}
+	if body := c.mustOK(c.fragment("/notes?tag=reviewed")); !strings.Contains(body, "Sourdough starter") {
+		t.Error("note missing from the notes tagged reviewed")
+	}
+
+	// Search finds items by title and by content
+	for query, want := range map[string]string{"sourdough": "Sourdough starter", "rye": "Rye bread", "groceries": "Groceries"} {
+		body := c.mustOK(c.get("/search?q=" + url.QueryEscape(query)))
+		if !strings.Contains(body, want) {
+			t.Errorf("search for %q does not find %q", query, want)
+		}
+	}
+
+	// Deleting moves the note to the trash and out of the notes page
+	noteID := strconv.FormatInt(int64(data["notes"][0]["id"].(float64)), 10)
+	c.mustOK(c.do("DELETE", "/items/"+noteID, "", nil))
+	if body := c.mustOK(c.fragment("/notes")); strings.Contains(body, "Sourdough starter") {
+		t.Error("deleted note still on the notes page")
+	}
+	if body := c.mustOK(c.get("/trash")); !strings.Contains(body, "Sourdough starter") {
+		t.Error("deleted note not in the trash")
+	}
+	if got := len(c.export()["notes"]); got != 0 {
+		t.Errorf("export has %d notes after deleting the only one", got)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	srv := startServer(t)
+	alice := newClient(t, srv)
+	alice.signUp("alice")
+	createEverything(t, alice)
+	backup := alice.mustOK(alice.get("/settings/export?format=json"))
+
+	// Another user imports alice's backup and ends up with the same items,
+	// except drawings and media: a JSON backup has no files
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	bob.mustOK(bob.postMultipart("/settings/import", map[string]string{"mode": "merge"},
+		map[string][2]string{"importFile": {"backup.json", backup}}))
+	want, got := alice.export(), bob.export()
+	want["drawings"], want["media"] = nil, nil
+	for key := range want {
+		if len(got[key]) != len(want[key]) {
+			t.Errorf("bob has %d %s after the import, want %d", len(got[key]), key, len(want[key]))
+			continue
+		}
+		for i := range want[key] {
+			if got[key][i]["title"] != want[key][i]["title"] {
+				t.Errorf("imported %s title = %v, want %v", key, got[key][i]["title"], want[key][i]["title"])
+			}
+		}
+	}
+
+	// Each user still only sees their own items
+	if body := alice.mustOK(alice.fragment("/notes")); !strings.Contains(body, "Sourdough starter") {
+		t.Error("alice lost her note")
+	}
+	if got := len(alice.export()["notes"]); got != 1 {
+		t.Errorf("alice has %d notes after bob's import, want 1", got)
+	}
+}
+
+func TestShareLinks(t *testing.T) {
+	srv := startServer(t)
+	alice := newClient(t, srv)
+	alice.signUp("alice")
+	alice.mustOK(alice.postForm("/lists", url.Values{"title": {"Packing list"}}))
+	listID := strconv.FormatInt(int64(alice.export()["lists"][0]["id"].(float64)), 10)
+	alice.mustOK(alice.postForm("/lists/"+listID+"/items", url.Values{"content": {"Sunscreen"}}))
+
+	share := func(body string) map[string]interface{} {
+		t.Helper()
+		resp := alice.mustOK(alice.do("POST", "/api/share", "application/json", strings.NewReader(body)))
+		var link map[string]interface{}
+		if err := json.Unmarshal([]byte(resp), &link); err != nil {
+			t.Fatalf("share: %v: %s", err, resp)
+		}
+		return link
+	}
+	link := share(`{"item_type": "list", "item_id": "` + listID + `"}`)
+	hash := link["link_hash"].(string)
+	if link["expires_at"] != nil {
+		t.Errorf("link expires at %v without an expiry", link["expires_at"])
+	}
+
+	// A guest without an account sees the checklist, read-only
+	guest := newClient(t, srv)
+	body := guest.mustOK(guest.get("/shared/" + hash))
+	if !strings.Contains(body, "Packing list") || !strings.Contains(body, "Sunscreen") || strings.Contains(body, "hx-post") {
+		t.Errorf("shared checklist: %.500s", body)
+	}
+
+	// Sharing again returns the same link, with the new expiry
+	again := share(`{"item_type": "list", "item_id": "` + listID + `", "expires_in_days": 7}`)
+	if again["link_hash"] != hash || again["expires_at"] == nil {
+		t.Errorf("sharing again = %v, want %s expiring", again, hash)
+	}
+	if _, body := alice.get("/api/share?item_type=list&item_id=" + listID); !strings.Contains(body, hash) {
+		t.Errorf("looking up the link: %s", body)
+	}
+	if status, _ := alice.get("/api/share?item_type=note&item_id=" + listID); status != http.StatusNotFound {
+		t.Errorf("looking up a link of an item that isn't shared: status %d, want 404", status)
+	}
+
+	// Past its expiry the link stops working
+	past := time.Now().Add(-time.Hour)
+	if err := database.SetSharedLinkExpiry(hash, 1, &past); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := guest.get("/shared/" + hash); status != http.StatusGone {
+		t.Errorf("expired link: status %d, want 410", status)
+	}
+	newHash := share(`{"item_type": "list", "item_id": "` + listID + `"}`)["link_hash"].(string)
+	if newHash == hash {
+		t.Error("sharing an item with an expired link brought the old link back")
+	}
+
+	if body := alice.mustOK(alice.get("/settings")); !strings.Contains(body, newHash) {
+		t.Error("settings don't list the shared links")
+	}
+
+	// Others can't share alice's items, and revoked links are gone
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.do("POST", "/api/share", "application/json",
+		strings.NewReader(`{"item_type": "list", "item_id": "`+listID+`"}`)); status != http.StatusNotFound {
+		t.Errorf("bob sharing alice's list: status %d, want 404", status)
+	}
+	alice.mustOK(alice.do("DELETE", "/api/share/"+newHash, "", nil))
+	if status, _ := guest.get("/shared/" + newHash); status != http.StatusNotFound {
+		t.Errorf("revoked link: status %d, want 404", status)
+	}
+}
+
+func TestStaticFiles(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+
+	// Templates and static files come from the binary, not the working directory
+	for _, path := range []string{"/static/css/tags.css", "/static/manifest.json", "/sw.js"} {
+		c.mustOK(c.get(path))
+	}
+
+	// Uploads land in the data directory and are served from there
+	c.signUp("alice")
+	c.mustOK(c.postMultipart("/media", map[string]string{"title": "Crumb shot"},
+		map[string][2]string{"file": {"crumb.png", string(pngImage(t))}}))
+	media := c.export()["media"]
+	if len(media) != 1 {
+		t.Fatalf("export has %d media, want 1", len(media))
+	}
+	path := media[0]["file_path"].(string)
+	if _, ok := datadir.File(path); !ok {
+		t.Fatalf("media stored at %s, outside the data directory", path)
+	}
+	if _, body := c.get(path); body != string(pngImage(t)) {
+		t.Errorf("GET %s does not return the upload", path)
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+
+	settings := c.mustOK(c.get("/settings"))
+	m := regexp.MustCompile(`/feeds/([0-9a-f]+)/rss`).FindStringSubmatch(settings)
+	if m == nil {
+		t.Fatal("settings show no feed URL")
+	}
+	feed := "/feeds/" + m[1]
+
+	// The feeds are public, so a reader without the session can fetch them
+	reader := newClient(t, srv)
+	body := reader.mustOK(reader.get(feed + "/rss"))
+	for _, want := range []string{"<rss", "Sourdough starter", "Feed the starter with rye flour", "http://127.0.0.1:1/flour", "<category>baking</category>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("RSS feed does not contain %q", want)
+		}
+	}
+	body = reader.mustOK(reader.get(feed + "/atom?type=note"))
+	if !strings.Contains(body, "<feed") || !strings.Contains(body, "Sourdough starter") || strings.Contains(body, "Flour guide") {
+		t.Errorf("Atom feed of notes: %.500s", body)
+	}
+	body = reader.mustOK(reader.get(feed + "/rss?tag=kitchen"))
+	if !strings.Contains(body, "Groceries") || strings.Contains(body, "Rye bread") {
+		t.Errorf("RSS feed tagged kitchen: %.500s", body)
+	}
+	if status, _ := reader.get(feed + "/rss?type=nope"); status != http.StatusBadRequest {
+		t.Errorf("unknown type: status %d, want 400", status)
+	}
+
+	// Regenerating the URLs turns the old ones off
+	c.mustOK(c.do("POST", "/settings/feed-token", "", nil))
+	if status, _ := reader.get(feed + "/rss"); status != http.StatusUnauthorized {
+		t.Errorf("old feed URL: status %d, want 401", status)
+	}
+	if status, _ := reader.get("/feeds//rss"); status == http.StatusOK {
+		t.Error("feed without a token served")
+	}
+}
+
+func TestReminders(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+	export := c.export()
+	noteID := strconv.FormatInt(int64(export["notes"][0]["id"].(float64)), 10)
+	listID := strconv.FormatInt(int64(export["lists"][0]["id"].(float64)), 10)
+
+	remind := func(at time.Time) (int, string) {
+		return c.postForm("/items/"+noteID+"/reminders", url.Values{"remind_at": {at.Format("2006-01-02T15:04")}})
+	}
+	if status, body := remind(time.Now().Add(-time.Hour)); status != http.StatusUnprocessableEntity || !strings.Contains(body, "remind_at") {
+		t.Errorf("reminder in the past: status %d: %s", status, body)
+	}
+	var reminders []map[string]interface{}
+	if err := json.Unmarshal([]byte(c.mustOK(remind(time.Now().Add(2*time.Hour)))), &reminders); err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 1 || reminders[0]["item_title"] != "Sourdough starter" {
+		t.Fatalf("reminders = %v", reminders)
+	}
+
+	// A checklist entry with a due date shows it and lands on the dashboard
+	due := time.Now().AddDate(0, 0, 2).Format(time.DateOnly)
+	c.mustOK(c.postForm("/lists/"+listID+"/items", url.Values{"content": {"Buy rye flour"}, "due_date": {due}}))
+	if body := c.mustOK(c.fragment("/lists/" + listID + "/items")); !strings.Contains(body, due) {
+		t.Errorf("checklist does not show the due date %s", due)
+	}
+	if status, _ := c.postForm("/lists/"+listID+"/items", url.Values{"content": {"Oats"}, "due_date": {"tomorrow"}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("invalid due date: status %d, want 422", status)
+	}
+	home := c.mustOK(c.get("/"))
+	for _, want := range []string{"Upcoming", "Sourdough starter", "Buy rye flour"} {
+		if !strings.Contains(home, want) {
+			t.Errorf("dashboard does not show %q", want)
+		}
+	}
+
+	// The Reminders page lists the reminder, and it can be removed there
+	if body := c.mustOK(c.get("/reminders")); !strings.Contains(body, "Sourdough starter") {
+		t.Error("reminders page does not list the item reminder")
+	}
+	reminderPath := fmt.Sprintf("/item-reminders/%d", int64(reminders[0]["id"].(float64)))
+	c.mustOK(c.do("DELETE", reminderPath, "", nil))
+	if body := c.mustOK(c.get("/items/" + noteID + "/reminders")); body != "[]\n" {
+		t.Errorf("reminders after deleting: %s", body)
+	}
+	if status, _ := c.do("DELETE", reminderPath, "", nil); status != http.StatusNotFound {
+		t.Errorf("deleting the reminder again: status %d, want 404", status)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+	export := c.export()
+	listID := strconv.FormatInt(int64(export["lists"][0]["id"].(float64)), 10)
+	mediaID := strconv.FormatInt(int64(export["media"][0]["id"].(float64)), 10)
+	c.mustOK(c.postForm("/lists/"+listID+"/items", url.Values{"content": {"Rye flour"}, "due_date": {"2030-01-31"}}))
+
+	duplicate := func(id string) map[string]interface{} {
+		t.Helper()
+		status, body := c.do("POST", "/items/"+id+"/duplicate", "", nil)
+		if status != http.StatusCreated {
+			t.Fatalf("duplicate %s: status %d: %s", id, status, body)
+		}
+		var copied map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &copied); err != nil {
+			t.Fatal(err)
+		}
+		return copied["item"].(map[string]interface{})
+	}
+
+	list := duplicate(listID)
+	items := list["items"].([]interface{})
+	if list["title"] != "Groceries (copy)" || len(items) != 1 || fmt.Sprint(list["tags"]) != "[kitchen]" {
+		t.Fatalf("copied checklist = %v", list)
+	}
+	if entry := items[0].(map[string]interface{}); entry["content"] != "Rye flour" || entry["due_date"] != "2030-01-31" {
+		t.Errorf("copied entry = %v", entry)
+	}
+
+	// The copy gets its own file, which stays when the original is purged
+	media := duplicate(mediaID)
+	original := export["media"][0]["file_path"].(string)
+	if media["file_path"] == original {
+		t.Fatalf("copied media shares the file %s", original)
+	}
+	c.mustOK(c.do("DELETE", "/items/"+mediaID, "", nil))
+	c.mustOK(c.do("DELETE", "/trash/"+mediaID, "", nil))
+	if body := c.mustOK(c.get(media["file_path"].(string))); !strings.HasPrefix(body, "\x89PNG") {
+		t.Errorf("copied file is gone after purging the original")
+	}
+
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.do("POST", "/items/"+listID+"/duplicate", "", nil); status != http.StatusNotFound {
+		t.Errorf("duplicating another user's item: status %d, want 404", status)
+	}
+}
+
+func TestItemLinks(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+	export := c.export()
+	id := func(kind string) string {
+		return strconv.FormatInt(int64(export[kind][0]["id"].(float64)), 10)
+	}
+	note, recipe, bookmark := id("notes"), id("recipes"), id("bookmarks")
+
+	links := func(status int, body string) []map[string]interface{} {
+		t.Helper()
+		var items []map[string]interface{}
+		if err := json.Unmarshal([]byte(c.mustOK(status, body)), &items); err != nil {
+			t.Fatalf("%v: %s", err, body)
+		}
+		return items
+	}
+	titles := func(items []map[string]interface{}) string {
+		var s []string
+		for _, it := range items {
+			s = append(s, it["title"].(string))
+		}
+		return strings.Join(s, ", ")
+	}
+
+	got := links(c.do("POST", "/items/"+note+"/links", "application/json", strings.NewReader(`{"item_id": `+recipe+`}`)))
+	if titles(got) != "Rye bread" || got[0]["type"] != "recipe" {
+		t.Fatalf("note links = %v", got)
+	}
+	links(c.postForm("/items/"+bookmark+"/links", url.Values{"item_id": {note}}))
+	links(c.postForm("/items/"+bookmark+"/links", url.Values{"item_id": {note}}))
+
+	// Links go both ways, and linking twice adds nothing
+	if got := titles(links(c.get("/items/" + note + "/links"))); got != "Flour guide, Rye bread" {
+		t.Errorf("note links = %s", got)
+	}
+	if got := titles(links(c.get("/items/" + recipe + "/links"))); got != "Sourdough starter" {
+		t.Errorf("recipe links = %s", got)
+	}
+	if status, _ := c.postForm("/items/"+note+"/links", url.Values{"item_id": {note}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("linking an item to itself: status %d, want 422", status)
+	}
+
+	// The Related section links to the item and offers the unlinked ones
+	related := c.mustOK(c.fragment("/items/" + recipe + "/links"))
+	if !strings.Contains(related, "Sourdough starter") || !strings.Contains(related, "/notes#note-"+note) {
+		t.Errorf("related section: %.500s", related)
+	}
+	search := c.mustOK(c.fragment("/items/" + note + "/links/search?q=o"))
+	if strings.Contains(search, "Rye bread") || strings.Contains(search, "Flour guide") || !strings.Contains(search, "Oven sketch") {
+		t.Errorf("link candidates: %.500s", search)
+	}
+
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.get("/items/" + note + "/links"); status != http.StatusNotFound {
+		t.Errorf("another user's links: status %d, want 404", status)
+	}
+
+	// Trashed items drop out of the links; unlinking removes both sides
+	c.mustOK(c.do("DELETE", "/items/"+bookmark, "", nil))
+	if got := titles(links(c.get("/items/" + note + "/links"))); got != "Rye bread" {
+		t.Errorf("note links with the bookmark in the trash = %s", got)
+	}
+	links(c.do("DELETE", "/items/"+recipe+"/links/"+note, "", nil))
+	if got := links(c.get("/items/" + note + "/links")); len(got) != 0 {
+		t.Errorf("note links after unlinking = %v", got)
+	}
+}
+
+func TestItemLog(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+	recipe := strconv.FormatInt(int64(c.export()["recipes"][0]["id"].(float64)), 10)
+
+	c.mustOK(c.postForm("/items/"+recipe+"/log", url.Values{"body": {"Tried it, too salty"}}))
+	body := c.mustOK(c.do("POST", "/items/"+recipe+"/log", "application/json", strings.NewReader(`{"body": "Half the salt, much better"}`)))
+	var entries []map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0]["body"] != "Half the salt, much better" || entries[1]["body"] != "Tried it, too salty" {
+		t.Fatalf("log = %v", entries)
+	}
+	if status, _ := c.postForm("/items/"+recipe+"/log", url.Values{"body": {"  "}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("empty entry: status %d, want 422", status)
+	}
+
+	// The log is separate from the recipe, which it leaves unchanged
+	if section := c.mustOK(c.fragment("/items/" + recipe + "/log")); !strings.Contains(section, "Tried it, too salty") {
+		t.Errorf("log section: %.500s", section)
+	}
+	if got := c.export()["recipes"][0]["notes"]; got != "" && got != nil {
+		t.Errorf("recipe notes = %v", got)
+	}
+
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.postForm("/items/"+recipe+"/log", url.Values{"body": {"Mine now"}}); status != http.StatusNotFound {
+		t.Errorf("logging on another user's item: status %d, want 404", status)
+	}
+}
+
+func TestReorder(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	for _, title := range []string{"Mill", "Oven", "Scale"} {
+		c.mustOK(c.postForm("/bookmarks", url.Values{"title": {title}, "url": {"http://127.0.0.1:1/" + title}}))
+	}
+	ids := map[string]string{}
+	for _, b := range c.export()["bookmarks"] {
+		ids[b["title"].(string)] = strconv.FormatInt(int64(b["id"].(float64)), 10)
+	}
+	card := regexp.MustCompile(`id="bookmark-(\d+)"`)
+	order := func() string {
+		t.Helper()
+		var titles []string
+		for _, m := range card.FindAllStringSubmatch(c.mustOK(c.fragment("/bookmarks?sort=manual")), -1) {
+			for title, id := range ids {
+				if id == m[1] {
+					titles = append(titles, title)
+				}
+			}
+		}
+		return strings.Join(titles, " ")
+	}
+	reorder := func(titles ...string) (int, string) {
+		t.Helper()
+		var list []string
+		for _, title := range titles {
+			list = append(list, ids[title])
+		}
+		return c.do("POST", "/api/v1/items/reorder", "application/json",
+			strings.NewReader(`{"ids": [`+strings.Join(list, ", ")+`]}`))
+	}
+
+	c.mustOK(reorder("Mill", "Scale", "Oven"))
+	if got := order(); got != "Mill Scale Oven" {
+		t.Fatalf("order = %q, want Mill Scale Oven", got)
+	}
+	// Reordering some of the items keeps them in the places they had
+	c.mustOK(c.postForm("/items/reorder", url.Values{"ids": {ids["Oven"] + "," + ids["Mill"]}}))
+	if got := order(); got != "Oven Scale Mill" {
+		t.Errorf("order = %q, want Oven Scale Mill", got)
+	}
+
+	if status, body := c.do("POST", "/items/reorder", "application/json", strings.NewReader(`{"ids": []}`)); status != http.StatusUnprocessableEntity {
+		t.Errorf("empty reorder: status %d: %s", status, body)
+	}
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.do("POST", "/items/reorder", "application/json", strings.NewReader(`{"ids": [`+ids["Mill"]+`]}`)); status != http.StatusNotFound {
+		t.Errorf("reordering another user's item: status %d, want 404", status)
+	}
+}
+
+func TestPinItem(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+	noteID := strconv.FormatInt(int64(c.export()["notes"][0]["id"].(float64)), 10)
+
+	for _, want := range []string{`"pinned":true`, `"pinned":false`} {
+		if body := c.mustOK(c.do("POST", "/api/v1/items/"+noteID+"/pin", "", nil)); !strings.Contains(body, want) {
+			t.Errorf("toggling the pin: %s, want %s", body, want)
+		}
+	}
+	body := c.mustOK(c.do("POST", "/api/v1/items/"+noteID+"/pin", "application/json", strings.NewReader(`{"pinned": true}`)))
+	if !strings.Contains(body, `"pinned":true`) {
+		t.Errorf("pinning: %s", body)
+	}
+
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.do("POST", "/api/v1/items/"+noteID+"/pin", "application/json", strings.NewReader(`{"pinned": false}`)); status != http.StatusNotFound {
+		t.Errorf("pinning another user's item: status %d, want 404", status)
+	}
+}
+
+func TestRateListItem(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	c.mustOK(c.postForm("/rated-lists", url.Values{"title": {"Films"}}))
+	listID := strconv.FormatInt(int64(c.export()["rated_lists"][0]["id"].(float64)), 10)
+
+	rate := func(body string) (int, string) {
+		t.Helper()
+		status, resp := c.do("POST", "/api/v1/rated-lists/"+listID+"/rate", "application/json", strings.NewReader(body))
+		var result struct {
+			Status string `json:"status"`
+		}
+		json.Unmarshal([]byte(resp), &result)
+		return status, result.Status
+	}
+	for _, tt := range []struct {
+		body   string
+		status int
+		result string
+	}{
+		{`{"title": "Dune: Part Two 9"}`, http.StatusCreated, "created"},
+		// A sequel is another film, not a typo of the first
+		{`{"title": "Dune 8"}`, http.StatusCreated, "created"},
+		{`{"title": "dune 7"}`, http.StatusOK, "updated"},
+		{`{"title": "Dune", "score": 6, "create": true}`, http.StatusCreated, "created"},
+	} {
+		if status, result := rate(tt.body); status != tt.status || result != tt.result {
+			t.Errorf("rating %s: %d %s, want %d %s", tt.body, status, result, tt.status, tt.result)
+		}
+	}
+}
+
+func TestCookbooks(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	c.mustOK(c.postMultipart("/recipes", map[string]string{"title": "Rye bread", "ingredients": "Rye flour"}, nil))
+	recipeID := strconv.FormatInt(int64(c.export()["recipes"][0]["id"].(float64)), 10)
+
+	// A cookbook made from the recipe's page has the recipe in it
+	c.mustOK(c.postMultipart("/cookbooks", map[string]string{"title": "Baking", "description": "Breads", "recipe_id": recipeID}, nil))
+	body := c.mustOK(c.fragment("/cookbooks"))
+	for _, want := range []string{"Baking", "Breads", "1 recipe"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("cookbooks page does not show %q", want)
+		}
+	}
+	if strings.Contains(body, "1 recipes") {
+		t.Error("cookbooks page counts 1 recipes")
+	}
+	m := regexp.MustCompile(`/cookbooks/(\d+)-baking"`).FindStringSubmatch(body)
+	if m == nil {
+		t.Fatal("cookbooks page does not link the cookbook")
+	}
+	if body := c.mustOK(c.get("/recipes/" + recipeID)); !strings.Contains(body, "/cookbooks/"+m[1]+"-baking") {
+		t.Error("recipe page does not link the cookbook it is in")
+	}
+	if body := c.mustOK(c.get("/cookbooks/" + m[1] + "/export")); !strings.Contains(body, "# Baking\n\nBreads") {
+		t.Errorf("cookbook export = %q", body)
+	}
+
+	// An archived cookbook is only listed in the archive
+	c.mustOK(c.postForm("/items/"+m[1]+"/archive", nil))
+	if body := c.mustOK(c.fragment("/cookbooks")); strings.Contains(body, "Breads") {
+		t.Error("cookbooks page lists the archived cookbook")
+	}
+	if body := c.mustOK(c.fragment("/cookbooks?archived=true")); !strings.Contains(body, "Breads") {
+		t.Error("cookbooks archive does not list the archived cookbook")
+	}
+
+	// The list comes a page at a time
+	c.mustOK(c.postMultipart("/cookbooks", map[string]string{"title": "Soups"}, nil))
+	c.mustOK(c.postMultipart("/cookbooks", map[string]string{"title": "Stews"}, nil))
+	body = c.mustOK(c.fragment("/cookbooks?per_page=1"))
+	if !strings.Contains(body, "Stews") || strings.Contains(body, "Soups") || !strings.Contains(body, "(1 of 2)") {
+		t.Errorf("first page of cookbooks = %q", body)
+	}
+
+	// The order picked is kept for the next visit
+	c.mustOK(c.fragment("/cookbooks?sort=title"))
+	if body := c.mustOK(c.fragment("/cookbooks?per_page=1")); !strings.Contains(body, "Soups") {
+		t.Errorf("first page of cookbooks by title = %q", body)
+	}
+}
+
+func TestApiUploadMedia(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+
+	status, body := c.postMultipart("/api/v1/media", map[string]string{"title": "Crumb shot"},
+		map[string][2]string{"file": {"crumb.html", string(pngImage(t))}})
+	if status != http.StatusCreated {
+		t.Fatalf("uploading an image: status %d: %s", status, body)
+	}
+	var created struct {
+		FilePath string `json:"file_path"`
+	}
+	json.Unmarshal([]byte(body), &created)
+	// The file is named for what it holds, whatever the client called it
+	if !strings.HasSuffix(created.FilePath, ".png") {
+		t.Errorf("file path %q, want a .png", created.FilePath)
+	}
+	if media := c.export()["media"]; len(media) != 1 || media[0]["mime_type"] != "image/png" {
+		t.Errorf("media = %v, want one image/png", media)
+	}
+
+	for name, content := range map[string]string{
+		"page.png":  "<!DOCTYPE html><script>alert(document.cookie)</script>",
+		"logo.svg":  `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`,
+		"notes.txt": "Feed the starter",
+	} {
+		if status, body := c.postMultipart("/api/v1/media", nil, map[string][2]string{"file": {name, content}}); status != http.StatusUnprocessableEntity {
+			t.Errorf("uploading %s: status %d, want 422: %s", name, status, body)
+		}
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+	noteID := strconv.FormatInt(int64(c.export()["notes"][0]["id"].(float64)), 10)
+	const pdf = "%PDF-1.4 starter schedule"
+
+	status, body := c.postMultipart("/api/v1/items/"+noteID+"/attachments", nil, map[string][2]string{"file": {"schedule.pdf", pdf}})
+	if status != http.StatusCreated {
+		t.Fatalf("attach: status %d: %s", status, body)
+	}
+	var attachment struct {
+		ID       int64  `json:"id"`
+		FileName string `json:"file_name"`
+		FilePath string `json:"file_path"`
+		Size     int64  `json:"size"`
+	}
+	if err := json.Unmarshal([]byte(body), &attachment); err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName != "schedule.pdf" || attachment.Size != int64(len(pdf)) {
+		t.Errorf("attachment = %+v", attachment)
+	}
+	if got := c.mustOK(c.get(attachment.FilePath)); got != pdf {
+		t.Errorf("attached file = %q", got)
+	}
+	if section := c.mustOK(c.fragment("/items/" + noteID + "/attachments")); !strings.Contains(section, "schedule.pdf") {
+		t.Errorf("Attachments section doesn't list the file: %s", section)
+	}
+
+	// A page can be attached but is downloaded, not run on the app's origin
+	status, body = c.postMultipart("/api/v1/items/"+noteID+"/attachments", nil,
+		map[string][2]string{"file": {"menu.html", "<script>alert(document.cookie)</script>"}})
+	if status != http.StatusCreated {
+		t.Fatalf("attach page: status %d: %s", status, body)
+	}
+	var page struct {
+		ID       int64  `json:"id"`
+		FilePath string `json:"file_path"`
+	}
+	json.Unmarshal([]byte(body), &page)
+	for path, download := range map[string]bool{page.FilePath: true, attachment.FilePath: false} {
+		resp, err := c.http.Get(c.base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s served without nosniff", path)
+		}
+		if got := resp.Header.Get("Content-Disposition") == "attachment"; got != download {
+			t.Errorf("%s: Content-Disposition %q", path, resp.Header.Get("Content-Disposition"))
+		}
+	}
+	if status, body := c.do("DELETE", "/items/"+noteID+"/attachments/"+strconv.FormatInt(page.ID, 10), "", nil); status != http.StatusNoContent {
+		t.Fatalf("remove page: status %d: %s", status, body)
+	}
+
+	// A copy of the note has its own copy of the file
+	status, body = c.do("POST", "/items/"+noteID+"/duplicate", "", nil)
+	if status != http.StatusCreated {
+		t.Fatalf("duplicate: status %d: %s", status, body)
+	}
+	var copied struct {
+		ID int64 `json:"id"`
+	}
+	json.Unmarshal([]byte(body), &copied)
+	copyID := strconv.FormatInt(copied.ID, 10)
+	var copies []struct {
+		FilePath string `json:"file_path"`
+	}
+	json.Unmarshal([]byte(c.mustOK(c.get("/api/v1/items/"+copyID+"/attachments"))), &copies)
+	if len(copies) != 1 || copies[0].FilePath == attachment.FilePath {
+		t.Fatalf("copy's attachments = %+v", copies)
+	}
+
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.get("/api/v1/items/" + noteID + "/attachments"); status != http.StatusNotFound {
+		t.Errorf("listing another user's attachments: status %d, want 404", status)
+	}
+	path := "/items/" + noteID + "/attachments/" + strconv.FormatInt(attachment.ID, 10)
+	if status, _ := bob.do("DELETE", path, "", nil); status != http.StatusNotFound {
+		t.Errorf("removing another user's attachment: status %d, want 404", status)
+	}
+
+	if status, body := c.do("DELETE", path, "", nil); status != http.StatusNoContent {
+		t.Fatalf("remove: status %d: %s", status, body)
+	}
+	if status, _ := c.get(attachment.FilePath); status != http.StatusNotFound {
+		t.Errorf("removed file: status %d, want 404", status)
+	}
+	// Purging the copy removes its file
+	c.mustOK(c.do("DELETE", "/items/"+copyID, "", nil))
+	c.mustOK(c.do("DELETE", "/trash/"+copyID, "", nil))
+	if status, _ := c.get(copies[0].FilePath); status != http.StatusNotFound {
+		t.Errorf("purged copy's file: status %d, want 404", status)
+	}
+}
+
+func TestNoteImages(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	const png = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR starter crumb"
+
+	upload := func(c *client, noteID string, name, content string) (int, string, string) {
+		t.Helper()
+		status, body := c.postMultipart("/notes/attachments", map[string]string{"note_id": noteID},
+			map[string][2]string{"file": {name, content}})
+		var image struct {
+			URL      string `json:"url"`
+			Markdown string `json:"markdown"`
+		}
+		json.Unmarshal([]byte(body), &image)
+		// The Markdown links the image from the root, as BASE_PATH is only
+		// added when the note is shown
+		if status == http.StatusCreated && image.Markdown != "![crumb](/static/uploads/"+path.Base(image.URL)+")" {
+			t.Errorf("markdown = %q, url %q", image.Markdown, image.URL)
+		}
+		return status, image.URL, image.Markdown
+	}
+	if status, _, _ := upload(c, "", "crumb.txt", "not an image"); status != http.StatusUnprocessableEntity {
+		t.Errorf("uploading text: status %d, want 422", status)
+	}
+
+	// An image pasted into a new note becomes its attachment when it is saved
+	status, url1, markdown := upload(c, "", "crumb.png", png)
+	if status != http.StatusCreated {
+		t.Fatalf("upload: status %d", status)
+	}
+	if got := c.mustOK(c.get(url1)); got != png {
+		t.Errorf("uploaded image = %q", got)
+	}
+	c.mustOK(c.postForm("/notes", url.Values{"title": {"Crumb"}, "content": {"Open crumb:\n" + markdown}}))
+	if body := c.mustOK(c.fragment("/notes")); !strings.Contains(body, `<img src="`+url1+`"`) {
+		t.Errorf("note card does not show the image %s", url1)
+	}
+	noteID := strconv.FormatInt(int64(c.export()["notes"][0]["id"].(float64)), 10)
+	attachments := func(id string) []string {
+		t.Helper()
+		var list []struct {
+			FilePath string `json:"file_path"`
+		}
+		json.Unmarshal([]byte(c.mustOK(c.get("/api/v1/items/"+id+"/attachments"))), &list)
+		var paths []string
+		for _, a := range list {
+			paths = append(paths, a.FilePath)
+		}
+		return paths
+	}
+	if got := attachments(noteID); !slices.Equal(got, []string{url1}) {
+		t.Errorf("note's attachments = %v, want %s", got, url1)
+	}
+
+	// One pasted into a saved note is attached to it at once
+	status, url2, _ := upload(c, noteID, "crumb.png", png)
+	if status != http.StatusCreated {
+		t.Fatalf("upload to note: status %d", status)
+	}
+	if got := attachments(noteID); !slices.Equal(got, []string{url1, url2}) {
+		t.Errorf("note's attachments = %v, want %s and %s", got, url1, url2)
+	}
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _, _ := upload(bob, noteID, "crumb.png", png); status != http.StatusNotFound {
+		t.Errorf("uploading to another user's note: status %d, want 404", status)
+	}
+
+	// A copy of the note shows its own copy of the image
+	status, body := c.do("POST", "/items/"+noteID+"/duplicate", "", nil)
+	if status != http.StatusCreated {
+		t.Fatalf("duplicate: status %d: %s", status, body)
+	}
+	var copied struct {
+		ID int64 `json:"id"`
+	}
+	json.Unmarshal([]byte(body), &copied)
+	var note struct {
+		Content string `json:"content"`
+	}
+	json.Unmarshal([]byte(c.mustOK(c.get("/notes/"+strconv.FormatInt(copied.ID, 10)))), &note)
+	if strings.Contains(note.Content, url1) || !strings.Contains(note.Content, "![crumb](/static/uploads/") {
+		t.Errorf("copy's content = %q", note.Content)
+	}
+
+	// Purging the note removes its images
+	c.mustOK(c.do("DELETE", "/items/"+noteID, "", nil))
+	c.mustOK(c.do("DELETE", "/trash/"+noteID, "", nil))
+	for _, u := range []string{url1, url2} {
+		if status, _ := c.get(u); status != http.StatusNotFound {
+			t.Errorf("purged note's image %s: status %d, want 404", u, status)
+		}
+	}
+}
+
+func TestNotesVaultExport(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	const png = "\x89PNG\r\n\x1a\n vault crumb"
+
+	status, body := c.postMultipart("/notes/attachments", nil, map[string][2]string{"file": {"crumb.png", png}})
+	if status != http.StatusCreated {
+		t.Fatalf("upload: status %d: %s", status, body)
+	}
+	var image struct {
+		Markdown string `json:"markdown"`
+	}
+	json.Unmarshal([]byte(body), &image)
+	c.mustOK(c.postForm("/notes", url.Values{"title": {"Levain: day 1?"}, "content": {"Fed it.\n" + image.Markdown}, "tags": {"sourdough, bread baking"}}))
+	c.mustOK(c.postForm("/notes", url.Values{"title": {"levain  day 1"}, "content": {"Same name"}}))
+
+	if status, body := c.postForm("/settings/export", url.Values{"format": {"vault"}}); status != http.StatusAccepted {
+		t.Fatalf("export: status %d: %s", status, body)
+	}
+	handlers.RunQueuedJobs()
+	var jobs []struct {
+		Status      string `json:"status"`
+		Error       string `json:"error"`
+		DownloadURL string `json:"download_url"`
+	}
+	json.Unmarshal([]byte(c.mustOK(c.get("/settings/export/jobs"))), &jobs)
+	if len(jobs) != 1 || jobs[0].DownloadURL == "" {
+		t.Fatalf("export jobs = %+v", jobs)
+	}
+	archive := c.mustOK(c.get(jobs[0].DownloadURL))
+	zr, err := zip.NewReader(strings.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	page, ok := files["pages/Levain day 1.md"]
+	if !ok {
+		t.Fatalf("vault files = %v", slices.Sorted(maps.Keys(files)))
+	}
+	for _, want := range []string{"---\ntitle: \"Levain: day 1?\"\ntags:\n  - \"bread-baking\"\n  - \"sourdough\"\ncreated: ", "\nupdated: ", "\n---\n\nFed it.\n![crumb](../assets/"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page doesn't contain %q:\n%s", want, page)
+		}
+	}
+	asset := regexp.MustCompile(`\(\.\./(assets/[^)]+)\)`).FindStringSubmatch(page)
+	if asset == nil || files[asset[1]] != png {
+		t.Errorf("image linked as %v; files %v", asset, slices.Sorted(maps.Keys(files)))
+	}
+	if other := files["pages/levain day 1 (2).md"]; !strings.Contains(other, "tags: []\n") || !strings.HasSuffix(other, "\n---\n\nSame name\n") {
+		t.Errorf("second page = %q", other)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+
+	capture := func(text string, want int) map[string]interface{} {
+		t.Helper()
+		status, body := c.do("POST", "/capture", "application/json",
+			strings.NewReader(fmt.Sprintf(`{"text": %q, "tags": "inbox"}`, text)))
+		if status != want {
+			t.Fatalf("capture %q: status %d: %s", text, status, body)
+		}
+		var created map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &created); err != nil {
+			t.Fatal(err)
+		}
+		return created
+	}
+	if got := capture("Rye basics http://127.0.0.1:1/rye", http.StatusCreated); got["type"] != "bookmark" || got["title"] != "Rye basics" {
+		t.Errorf("captured link = %v", got)
+	}
+	if got := capture("http://127.0.0.1:1/rye", http.StatusOK); got["status"] != "exists" {
+		t.Errorf("captured link again = %v", got)
+	}
+	if got := capture("Bake\n- feed starter\n- preheat oven", http.StatusCreated); got["type"] != "list" || got["title"] != "Bake" {
+		t.Errorf("captured checklist = %v", got)
+	}
+	if got := capture("Ask the bakery about rye", http.StatusCreated); got["type"] != "note" {
+		t.Errorf("captured note = %v", got)
+	}
+
+	export := c.export()
+	if len(export["bookmarks"]) != 1 || len(export["notes"]) != 1 || len(export["lists"]) != 1 {
+		t.Fatalf("export after capturing = %v", export)
+	}
+	if items := export["lists"][0]["items"].([]interface{}); len(items) != 2 {
+		t.Errorf("captured checklist entries = %v", items)
+	}
+	if note := export["notes"][0]; note["content"] != "Ask the bakery about rye" || fmt.Sprint(note["tags"]) != "[inbox]" {
+		t.Errorf("captured note = %v", note)
+	}
+
+	if status, body := c.postForm("/capture", url.Values{"text": {"  "}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("empty capture: status %d: %s", status, body)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+	bookmarkID := strconv.FormatInt(int64(c.export()["bookmarks"][0]["id"].(float64)), 10)
+	c.mustOK(c.postForm("/bookmarks/"+bookmarkID+"/read-later", url.Values{"queued": {"true"}}))
+
+	if status, body := c.postForm("/settings/digest", url.Values{"frequency": {"daily"}, "hour": {"8"}}); status != http.StatusUnprocessableEntity || !strings.Contains(body, "email") {
+		t.Errorf("digest without an address: status %d: %s", status, body)
+	}
+	body := c.mustOK(c.postForm("/settings/digest", url.Values{
+		"frequency": {"weekly"}, "email": {"alice@example.com"}, "hour": {"7"}, "weekday": {"5"}}))
+	if body != `{"email":"alice@example.com","frequency":"weekly","hour":7,"weekday":5}`+"\n" {
+		t.Errorf("saved digest settings = %s", body)
+	}
+	if page := c.mustOK(c.get("/settings")); !strings.Contains(page, `value="alice@example.com"`) {
+		t.Error("settings page doesn't show the digest address")
+	}
+
+	preview := c.mustOK(c.get("/settings/digest/preview"))
+	for _, want := range []string{"Added since", "Sourdough starter (note)", "Crumb shot (media)", "read-later queue (1)", "http://127.0.0.1:1/flour"} {
+		if !strings.Contains(preview, want) {
+			t.Errorf("digest preview doesn't contain %q:\n%s", want, preview)
+		}
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	for _, title := range []string{"Camping", "Groceries", "Bakery"} {
+		if status, body := c.do("POST", "/api/v1/lists", "application/json", strings.NewReader(`{"title": "`+title+`"}`)); status != http.StatusCreated {
+			t.Fatalf("create %s: status %d: %s", title, status, body)
+		}
+	}
+
+	body := c.mustOK(c.get("/api/v1/preferences"))
+	if body != `{"default_page":"dashboard","per_page":50,"sort":"created","theme":"light","date_format":"iso"}`+"\n" {
+		t.Errorf("default preferences = %s", body)
+	}
+	body = c.mustOK(c.do("PUT", "/api/v1/preferences", "application/json",
+		strings.NewReader(`{"per_page": 2, "sort": "title", "theme": "nord", "date_format": "relative"}`)))
+	if body != `{"default_page":"dashboard","per_page":2,"sort":"title","theme":"nord","date_format":"relative"}`+"\n" {
+		t.Errorf("changed preferences = %s", body)
+	}
+
+	// A page without per_page or sort has the user's
+	var lists []struct {
+		Title string `json:"title"`
+	}
+	json.Unmarshal([]byte(c.mustOK(c.get("/api/v1/lists?page=1"))), &lists)
+	if len(lists) != 2 || lists[0].Title != "Bakery" || lists[1].Title != "Camping" {
+		t.Errorf("first page = %+v", lists)
+	}
+
+	if status, body := c.postForm("/settings/preferences", url.Values{"per_page": {"1000"}, "theme": {"neon"}}); status != http.StatusUnprocessableEntity ||
+		!strings.Contains(body, "per_page") || !strings.Contains(body, "theme") {
+		t.Errorf("invalid preferences: status %d: %s", status, body)
+	}
+	c.mustOK(c.postForm("/settings/preferences", url.Values{"default_page": {"notes"}}))
+	if body := c.mustOK(c.get("/settings/preferences")); !strings.Contains(body, `"default_page":"notes","per_page":2`) {
+		t.Errorf("preferences after saving the landing page = %s", body)
+	}
+}
+
+func TestRecentViews(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+	export := c.export()
+	id := func(kind string) string {
+		return strconv.FormatInt(int64(export[kind][0]["id"].(float64)), 10)
+	}
+
+	c.mustOK(c.get("/recipes/" + id("recipes")))
+	c.mustOK(c.fragment("/lists/" + id("lists") + "/items"))
+	c.mustOK(c.get("/recipes/" + id("recipes")))
+	if status, body := c.do("POST", "/api/v1/items/"+id("bookmarks")+"/view", "", nil); status != http.StatusNoContent {
+		t.Fatalf("view bookmark: status %d: %s", status, body)
+	}
+
+	var viewed []struct {
+		Title string `json:"title"`
+	}
+	json.Unmarshal([]byte(c.mustOK(c.get("/api/recent-views"))), &viewed)
+	var titles []string
+	for _, v := range viewed {
+		titles = append(titles, v.Title)
+	}
+	if got := strings.Join(titles, ", "); got != "Flour guide, Rye bread, Groceries" {
+		t.Errorf("recently viewed = %s", got)
+	}
+	if page := c.mustOK(c.get("/dashboard")); !strings.Contains(page, "Recently Viewed") {
+		t.Error("dashboard doesn't show the recently viewed items")
+	}
+
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.do("POST", "/items/"+id("notes")+"/view", "", nil); status != http.StatusNotFound {
+		t.Errorf("viewing another user's item: status %d, want 404", status)
+	}
+
+	// Items in the trash aren't listed
+	c.mustOK(c.do("DELETE", "/items/"+id("bookmarks"), "", nil))
+	json.Unmarshal([]byte(c.mustOK(c.get("/api/recent-views?limit=1"))), &viewed)
+	if len(viewed) != 1 || viewed[0].Title != "Rye bread" {
+		t.Errorf("recently viewed after trashing the bookmark = %+v", viewed)
+	}
+}
+
+func TestSelectiveExport(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	createEverything(t, c)
+
+	count := func(export map[string][]map[string]interface{}) string {
+		var counts []string
+		for _, key := range []string{"bookmarks", "notes", "drawings", "lists", "rated_lists", "recipes", "media"} {
+			if n := len(export[key]); n > 0 {
+				counts = append(counts, fmt.Sprintf("%s:%d", key, n))
+			}
+		}
+		return strings.Join(counts, " ")
+	}
+	for query, want := range map[string]string{
+		"&tag=kitchen":                   "notes:1 lists:1",
+		"&type=note,recipe&tag=baking":   "notes:1 recipes:1",
+		"&type=rated_list":               "rated_lists:1",
+		"&from=2000-01-01&to=2000-12-31": "",
+	} {
+		if got := count(c.exportQuery(query)); got != want {
+			t.Errorf("export%s = %s, want %s", query, got, want)
+		}
+	}
+	list := c.export()["lists"][0]
+	picked := c.exportQuery("&ids=" + strconv.FormatInt(int64(list["id"].(float64)), 10))
+	if got := count(picked); got != "lists:1" || picked["lists"][0]["title"] != "Groceries" {
+		t.Errorf("export of one list = %s", got)
+	}
+
+	if status, body := c.get("/settings/export?type=widget&from=yesterday"); status != http.StatusUnprocessableEntity ||
+		!strings.Contains(body, "type") || !strings.Contains(body, "from") {
+		t.Errorf("invalid filter: status %d: %s", status, body)
+	}
+	if status, body := c.postForm("/settings/export", url.Values{"format": {"personal"}, "tag": {"kitchen"}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("filtered personal export: status %d: %s", status, body)
+	}
+}
+
+func TestBookmarkMetadata(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bare" {
+			fmt.Fprint(w, `<html><body>No head here</body></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html><head>
+			<title>Rye | Baking Blog</title>
+			<meta property="og:title" content="Baking with rye">
+			<meta property="og:description" content="Why rye needs a sour dough.">
+			<link rel="canonical" href="https://bakery.example/rye">
+			</head><body></body></html>`)
+	}))
+	defer page.Close()
+
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+
+	c.mustOK(c.postForm("/bookmarks", url.Values{"url": {page.URL + "/rye?utm_source=feed"}}))
+	// The page is read in the background, meanwhile the card polls for it
+	id := strconv.FormatInt(int64(c.export()["bookmarks"][0]["id"].(float64)), 10)
+	if body := c.mustOK(c.fragment("/bookmarks")); !strings.Contains(body, "/bookmarks/"+id+"/card?poll=0") {
+		t.Errorf("card of a bookmark whose page is being read = %s", body)
+	}
+	if body := c.mustOK(c.fragment("/bookmarks/" + id + "/card?poll=3")); !strings.Contains(body, "/bookmarks/"+id+"/card?poll=4") {
+		t.Errorf("card after 3 polls = %s", body)
+	}
+	// The card gives up after a while
+	if body := c.mustOK(c.fragment("/bookmarks/" + id + "/card?poll=100")); strings.Contains(body, "/card") {
+		t.Errorf("card after 100 polls = %s", body)
+	}
+	handlers.RunQueuedJobs()
+	if body := c.mustOK(c.fragment("/bookmarks/" + id + "/card")); !strings.Contains(body, "Baking with rye") ||
+		strings.Contains(body, "/bookmarks/"+id+"/card") {
+		t.Errorf("card once the page was read = %s", body)
+	}
+	bookmarks := c.export()["bookmarks"]
+	if len(bookmarks) != 1 {
+		t.Fatalf("bookmarks = %v", bookmarks)
+	}
+	b := bookmarks[0]
+	if b["title"] != "Baking with rye" || b["description"] != "Why rye needs a sour dough." || b["canonical_url"] != "https://bakery.example/rye" {
+		t.Errorf("bookmark saved with only a URL = %v", b)
+	}
+
+	status, body := c.do("POST", "/api/bookmarks", "application/json",
+		strings.NewReader(`{"url": "`+page.URL+`/bare", "description": "Mine"}`))
+	if status != http.StatusCreated {
+		t.Fatalf("clip: status %d: %s", status, body)
+	}
+	for _, b := range c.export()["bookmarks"] {
+		if b["url"] == page.URL+"/bare" && (b["title"] != page.URL+"/bare" || b["description"] != "Mine") {
+			t.Errorf("clipped bookmark of a page without a title = %v", b)
+		}
+	}
+}
+
+func TestBookmarkArticleText(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := "Spelt is an old wheat."
+		if r.URL.Path == "/rye" {
+			body = "Rye bread starts from a levain."
+		}
+		fmt.Fprintf(w, `<html><head><title>Baking</title></head><body><article><p>%s</p></article></body></html>`, body)
+	}))
+	defer page.Close()
+
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	c.mustOK(c.postForm("/bookmarks", url.Values{"url": {page.URL + "/rye"}, "title": {"Loaves"}, "description": {"To bake"}}))
+	handlers.RunQueuedJobs()
+	if body := c.mustOK(c.get("/search?q=levain")); !strings.Contains(body, "Loaves") {
+		t.Error("search does not find the bookmark by the text of its page")
+	}
+
+	// A new address replaces the text
+	id := strconv.FormatInt(int64(c.export()["bookmarks"][0]["id"].(float64)), 10)
+	c.mustOK(c.postForm("/bookmarks/"+id, url.Values{"url": {page.URL + "/spelt"}, "title": {"Loaves"}}))
+	handlers.RunQueuedJobs()
+	if body := c.mustOK(c.get("/search?q=levain")); strings.Contains(body, "Loaves") {
+		t.Error("search finds the bookmark by the text of its old page")
+	}
+	if body := c.mustOK(c.get("/search?q=wheat")); !strings.Contains(body, "Loaves") {
+		t.Error("search does not find the bookmark by the text of its new page")
+	}
+}
+
+func TestBookmarkNotes(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Baking with rye</title></head></html>`)
+	}))
+	defer page.Close()
+
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	status, body := c.do("POST", "/api/bookmarks", "application/json",
+		strings.NewReader(`{"url": "`+page.URL+`/rye", "notes": "Try with caraway"}`))
+	if status != http.StatusCreated {
+		t.Fatalf("clip: status %d: %s", status, body)
+	}
+	b := c.export()["bookmarks"][0]
+	if b["notes"] != "Try with caraway" || strings.Contains(b["favicon"].(string), "caraway") {
+		t.Errorf("clipped bookmark = %v", b)
+	}
+	if body := c.mustOK(c.get("/search?q=caraway")); !strings.Contains(body, "Baking with rye") {
+		t.Error("search does not find the bookmark by its notes")
+	}
+
+	id := strconv.FormatInt(int64(b["id"].(float64)), 10)
+	c.mustOK(c.postForm("/bookmarks/"+id, url.Values{"url": {page.URL + "/rye"}, "title": {"Rye"}, "notes": {"Too dense"}}))
+	if body := c.mustOK(c.fragment("/bookmarks")); !strings.Contains(body, "Too dense") {
+		t.Errorf("card after editing the notes = %s", body)
+	}
+}
+
+func TestBookmarkNotesMigration(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	status, body := c.do("POST", "/api/bookmarks", "application/json",
+		strings.NewReader(`{"url": "http://127.0.0.1:1/rye", "title": "Baking with rye"}`))
+	if status != http.StatusCreated {
+		t.Fatalf("clip: status %d: %s", status, body)
+	}
+
+	// A database from before bookmarks had notes, whose clipper kept them
+	// as the favicon, indexed by a view without them
+	for _, stmt := range []string{
+		"DROP VIEW IF EXISTS search_documents",
+		`CREATE VIEW search_documents AS
+		SELECT i.id AS id, i.title AS title, TRIM(COALESCE(n.content, '') || ' ' || COALESCE(b.description, '') || ' ' || COALESCE(b.url, '')) AS body,
+			'' AS tags
+		FROM items i LEFT JOIN notes n ON n.item_id = i.id LEFT JOIN bookmarks b ON b.item_id = i.id`,
+		"ALTER TABLE bookmarks DROP COLUMN notes",
+		"UPDATE bookmarks SET favicon = 'Try with caraway'",
+		"DELETE FROM system_settings WHERE key = 'search_index_version'",
+	} {
+		if _, err := database.DB.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	database.DB.Close()
+	if err := database.InitDB(datadir.DB()); err != nil {
+		t.Fatal(err)
+	}
+
+	b := c.export()["bookmarks"][0]
+	if b["notes"] != "Try with caraway" {
+		t.Errorf("migrated bookmark = %v", b)
+	}
+	if body := c.mustOK(c.get("/search?q=caraway")); !strings.Contains(body, "Baking with rye") {
+		t.Error("search does not find the migrated bookmark by its notes")
+	}
+}
+
+func TestPageArchive(t *testing.T) {
+	text := "Rye needs a sour dough."
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><head><title>Baking with rye</title></head><body>
+			<nav>Home</nav><article><h1>Rye</h1><p onclick="steal()">%s</p><script>track()</script></article>
+			</body></html>`, text)
+	}))
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+
+	c.mustOK(c.postForm("/bookmarks", url.Values{"url": {page.URL + "/rye"}, "archive": {"true"}}))
+	c.mustOK(c.postForm("/bookmarks", url.Values{"url": {page.URL + "/spelt"}}))
+	handlers.RunQueuedJobs()
+	ids := map[string]string{}
+	for _, b := range c.export()["bookmarks"] {
+		ids[b["url"].(string)] = strconv.FormatInt(int64(b["id"].(float64)), 10)
+	}
+	rye, spelt := ids[page.URL+"/rye"], ids[page.URL+"/spelt"]
+
+	body := c.mustOK(c.get("/bookmarks/" + rye + "/archive"))
+	if !strings.Contains(body, text) || strings.Contains(body, "track()") || strings.Contains(body, "steal()") {
+		t.Errorf("archived page = %s", body)
+	}
+	if status, _ := c.get("/bookmarks/" + spelt + "/archive"); status != http.StatusNotFound {
+		t.Errorf("bookmark added without archiving: status %d", status)
+	}
+
+	// The page goes away, but the archived copy stays and is searchable
+	page.Close()
+	var archive struct {
+		URL  string `json:"url"`
+		Text string `json:"text"`
+	}
+	json.Unmarshal([]byte(c.mustOK(c.get("/api/v1/bookmarks/"+rye+"/archive"))), &archive)
+	if archive.URL != page.URL+"/rye" || archive.Text != "Rye\n"+text {
+		t.Errorf("archive = %+v", archive)
+	}
+	if body := c.mustOK(c.get("/search?q=sour")); !strings.Contains(body, "Baking with rye") {
+		t.Error("search does not find the bookmark by its archived text")
+	}
+	if status, _ := c.do("POST", "/api/v1/bookmarks/"+spelt+"/archive", "", nil); status != http.StatusBadGateway {
+		t.Errorf("archiving a page that is gone: status %d", status)
+	}
+
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := bob.get("/bookmarks/" + rye + "/archive"); status != http.StatusNotFound {
+		t.Errorf("another user's archive: status %d", status)
+	}
+}
+
+func TestBrokenLinks(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><head><title>`+strings.TrimPrefix(r.URL.Path, "/")+`</title></head></html>`)
+	}))
+	defer page.Close()
+
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	c.mustOK(c.postForm("/bookmarks", url.Values{"url": {page.URL + "/gone"}, "title": {"Gone page"}}))
+	c.mustOK(c.postForm("/bookmarks", url.Values{"url": {page.URL + "/alive"}, "title": {"Alive page"}}))
+	ids := map[string]string{}
+	for _, b := range c.export()["bookmarks"] {
+		ids[b["title"].(string)] = strconv.FormatInt(int64(b["id"].(float64)), 10)
+	}
+
+	var checked struct {
+		LinkStatus int  `json:"link_status"`
+		LinkBroken bool `json:"link_broken"`
+	}
+	json.Unmarshal([]byte(c.mustOK(c.do("POST", "/api/v1/bookmarks/"+ids["Gone page"]+"/check-link", "", nil))), &checked)
+	if checked.LinkStatus != http.StatusNotFound || !checked.LinkBroken {
+		t.Errorf("checked dead link = %+v", checked)
+	}
+	c.mustOK(c.do("POST", "/api/v1/bookmarks/"+ids["Alive page"]+"/check-link", "", nil))
+
+	body := c.mustOK(c.fragment("/bookmarks?kind=broken"))
+	if !strings.Contains(body, "Gone page") || strings.Contains(body, "Alive page") {
+		t.Errorf("broken links = %s", body)
+	}
+
+	// Fixing the address takes the bookmark off the list
+	c.mustOK(c.postForm("/bookmarks/"+ids["Gone page"], url.Values{"url": {page.URL + "/moved"}, "title": {"Gone page"}}))
+	if body := c.mustOK(c.fragment("/bookmarks?kind=broken")); strings.Contains(body, "Gone page") {
+		t.Error("bookmark with a new address still listed as broken")
+	}
+}
+
+func TestReadToggle(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Baking with rye</title></head></html>`)
+	}))
+	defer page.Close()
+
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	status, body := c.do("POST", "/api/bookmarks", "application/json",
+		strings.NewReader(`{"url": "`+page.URL+`/rye", "toread": true}`))
+	if status != http.StatusCreated {
+		t.Fatalf("clip: status %d: %s", status, body)
+	}
+	id := strconv.FormatInt(int64(c.export()["bookmarks"][0]["id"].(float64)), 10)
+	if body := c.mustOK(c.fragment("/bookmarks?kind=later")); !strings.Contains(body, "Baking with rye") {
+		t.Fatalf("unread bookmarks = %s", body)
+	}
+
+	var toggled struct {
+		ReadLater bool   `json:"read_later"`
+		ReadAt    string `json:"read_at"`
+	}
+	toggle := func() {
+		t.Helper()
+		toggled.ReadLater, toggled.ReadAt = false, ""
+		json.Unmarshal([]byte(c.mustOK(c.do("POST", "/api/v1/bookmarks/"+id+"/read-toggle", "", nil))), &toggled)
+	}
+	toggle()
+	if toggled.ReadLater || toggled.ReadAt == "" {
+		t.Errorf("toggled once = %+v", toggled)
+	}
+	if body := c.mustOK(c.fragment("/bookmarks?kind=later")); strings.Contains(body, "Baking with rye") {
+		t.Error("read bookmark still listed as unread")
+	}
+	toggle()
+	if !toggled.ReadLater || toggled.ReadAt != "" {
+		t.Errorf("toggled twice = %+v", toggled)
+	}
+}
+
+func TestTagSuggestions(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Channels in Go</title>
+			<meta name="keywords" content="Concurrency, Tutorial, go"></head></html>`)
+	}))
+	defer page.Close()
+
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	c.mustOK(c.postForm("/notes", url.Values{"title": {"Go notes"}, "content": {"Generics"}, "tags": {"go"}}))
+
+	var suggested []string
+	json.Unmarshal([]byte(c.mustOK(c.get("/bookmarks/suggest-tags?url="+url.QueryEscape(page.URL+"/channels")))), &suggested)
+	if !slices.Equal(suggested, []string{"go", "concurrency", "tutorial"}) {
+		t.Errorf("suggested for a URL = %q", suggested)
+	}
+
+	status, body := c.do("POST", "/api/bookmarks", "application/json",
+		strings.NewReader(`{"url": "`+page.URL+`/channels", "tags": "Tutorial"}`))
+	var created struct {
+		ID            int64    `json:"id"`
+		SuggestedTags []string `json:"suggested_tags"`
+	}
+	if json.Unmarshal([]byte(body), &created); status != http.StatusCreated ||
+		!slices.Equal(created.SuggestedTags, []string{"go", "concurrency"}) {
+		t.Errorf("clip: status %d: %s", status, body)
+	}
+
+	json.Unmarshal([]byte(c.mustOK(c.get("/api/v1/bookmarks/"+strconv.FormatInt(created.ID, 10)+"/suggest-tags"))), &suggested)
+	if !slices.Equal(suggested, []string{"go", "concurrency"}) {
+		t.Errorf("suggested for a bookmark = %q", suggested)
+	}
+}
+
+func TestBookmarkLookup(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Baking with rye</title></head></html>`)
+	}))
+	defer page.Close()
+
+	srv := startServer(t)
+	alice := newClient(t, srv)
+	alice.signUp("alice")
+	alice.mustOK(alice.postForm("/bookmarks", url.Values{"url": {page.URL + "/rye"}, "tags": {"baking"}}))
+
+	lookup := func(c *client, pageURL string) (int, string) {
+		return c.get("/api/bookmarks/lookup?url=" + url.QueryEscape(pageURL))
+	}
+	var found struct {
+		ID        int64    `json:"id"`
+		Tags      []string `json:"tags"`
+		CreatedAt string   `json:"created_at"`
+	}
+	// Tracking parameters don't hide a saved page
+	status, body := lookup(alice, page.URL+"/rye?utm_source=feed")
+	if json.Unmarshal([]byte(body), &found); status != http.StatusOK || found.ID == 0 ||
+		!slices.Equal(found.Tags, []string{"baking"}) || found.CreatedAt == "" {
+		t.Errorf("lookup of a saved page: status %d: %s", status, body)
+	}
+	if status, _ := lookup(alice, page.URL+"/spelt"); status != http.StatusNotFound {
+		t.Errorf("lookup of an unsaved page: status %d", status)
+	}
+
+	bob := newClient(t, srv)
+	bob.signUp("bob")
+	if status, _ := lookup(bob, page.URL+"/rye"); status != http.StatusNotFound {
+		t.Errorf("lookup of another user's bookmark: status %d", status)
+	}
+}
+
+func TestPocketImport(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+	if body := c.mustOK(c.get("/settings")); !strings.Contains(body, "Pocket (HTML or CSV export)") {
+		t.Fatal("Pocket importer missing from the settings page")
+	}
+
+	export := "title,url,time_added,tags,status\n" +
+		"The Go Blog,https://go.dev/blog,1700000000,go|reading,unread\n" +
+		"Bread,https://example.com/bread,1600000000,baking,archive\n"
+	file := map[string][2]string{"importFile": {"part_000000.csv", export}}
+
+	// A preview imports nothing
+	c.mustOK(c.postMultipart("/settings/import-app", map[string]string{"importer": "pocket", "dry_run": "true"}, file))
+	if bookmarks := c.export()["bookmarks"]; len(bookmarks) != 0 {
+		t.Fatalf("preview imported %v", bookmarks)
+	}
+
+	c.mustOK(c.postMultipart("/settings/import-app", map[string]string{"importer": "pocket"}, file))
+	got := map[string]map[string]interface{}{}
+	for _, b := range c.export()["bookmarks"] {
+		got[b["title"].(string)] = b
+	}
+	if b := got["The Go Blog"]; b == nil || b["read_later"] != true || b["read_at"] != nil || len(b["tags"].([]interface{})) != 2 {
+		t.Errorf("unread bookmark = %v", b)
+	}
+	if b := got["Bread"]; b == nil || b["read_later"] != false || b["read_at"] == nil {
+		t.Errorf("read bookmark = %v", b)
+	}
+}
+
+func TestBookmarkJSONImport(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+	c.signUp("alice")
+
+	pinboard := `[{"href": "https://go.dev/blog", "description": "The Go Blog", "extended": "Release notes",
+		"tags": "go reading", "time": "2014-05-06T07:08:09Z", "toread": "yes"}]`
+	c.mustOK(c.postMultipart("/settings/import-app", map[string]string{"importer": "pinboard"},
+		map[string][2]string{"importFile": {"pinboard_export.json", pinboard}}))
+	raindrop := `{"items": [{"link": "https://example.com/bread", "title": "Bread", "tags": ["baking"],
+		"created": "2020-10-11T12:13:14.000Z"}]}`
+	c.mustOK(c.postMultipart("/settings/import-app", map[string]string{"importer": "raindrop"},
+		map[string][2]string{"importFile": {"raindrops.json", raindrop}}))
+
+	got := map[string]map[string]interface{}{}
+	for _, b := range c.export()["bookmarks"] {
+		got[b["title"].(string)] = b
+	}
+	if b := got["The Go Blog"]; b == nil || b["description"] != "Release notes" || b["read_later"] != true ||
+		!strings.HasPrefix(b["created_at"].(string), "2014-05-06") || len(b["tags"].([]interface{})) != 2 {
+		t.Errorf("Pinboard bookmark = %v", b)
+	}
+	if b := got["Bread"]; b == nil || b["read_later"] != false || !strings.HasPrefix(b["created_at"].(string), "2020-10-11") {
+		t.Errorf("Raindrop bookmark = %v", b)
+	}
+}
+
+func TestPasswordValidation(t *testing.T) {
+	srv := startServer(t)
+	c := newClient(t, srv)
+
+	status, body := c.postForm("/register", url.Values{"username": {"alice"}, "password": {"alice"}})
+	if status != http.StatusUnprocessableEntity || !strings.Contains(body, "Password must be at least") {
+		t.Errorf("registering with a short password: status %d: %.300s", status, body)
+	}
+	c.signUp("alice")
+
+	// Every field's problem is reported at once, by field name
+	status, body = c.postForm("/settings/password", url.Values{"current_password": {"wrong"}, "new_password": {"alice-and-bob"}})
+	if status != http.StatusUnprocessableEntity ||
+		!strings.Contains(body, `{"field":"current_password","message":"is incorrect"}`) ||
+		!strings.Contains(body, `{"field":"new_password","message":"must not contain your username"}`) {
+		t.Errorf("changing the password with bad fields: status %d: %s", status, body)
+	}
+	c.mustOK(c.postForm("/settings/password", url.Values{
+		"current_password": {"correct horse battery staple"}, "new_password": {"Tr0ub4dor&3-staple"}}))
+}
@@ -0,0 +1,78 @@
+// Package buildinfo describes the running build. Version, Commit and Date
+// are set at link time by the Makefile and Dockerfile:
+//
+//	go build -ldflags "-X infokeep/internal/buildinfo.Version=v1.4.0 \
+//		-X infokeep/internal/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//		-X infokeep/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// A plain go build still gets Commit and Date from the VCS information Go
+// embeds when building inside a git checkout.
+package buildinfo
+
+import (
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && Commit == "":
+			Commit = s.Value
+			if len(Commit) > 12 {
+				Commit = Commit[:12]
+			}
+		case s.Key == "vcs.time" && Date == "":
+			Date = s.Value
+		}
+	}
+}
+
+// Newer reports whether version a is a later release than b. Both are
+// semantic versions with an optional leading v, e.g. v1.4.0; anything else,
+// like "dev", is never newer nor older.
+func Newer(a, b string) bool {
+	va, okA := parse(a)
+	vb, okB := parse(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] > vb[i]
+		}
+	}
+	return false
+}
+
+// parse reads major.minor.patch, ignoring a pre-release or build suffix
+func parse(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
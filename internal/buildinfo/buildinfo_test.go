@@ -0,0 +1,25 @@
+package buildinfo
+
+import "testing"
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.4.0", "v1.3.9", true},
+		{"1.10.0", "v1.9.0", true},
+		{"v2", "v1.9.9", true},
+		{"v1.3.0", "v1.3.0", false},
+		{"v1.3.0", "v1.4.0", false},
+		{"v1.4.0-rc.1", "v1.3.0", true},
+		{"v1.4.0", "dev", false},
+		{"dev", "v1.4.0", false},
+		{"v1.x", "v1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import "fmt"
+
+// Kinds of activity log entries
+const (
+	ActivityFetch  = "fetch"  // the server fetched a URL on the user's behalf
+	ActivityAPI    = "api"    // a request made with the user's API token
+	ActivityChange = "change" // a saved page changed since it was last checked
+)
+
+// ActivityEntry is an outbound fetch or API call made for a user, or a change
+// found in one of their bookmarked pages. Action is what the fetch was for
+// (thumbnail, recipe, ...) or the method of an API call; Target is the
+// fetched URL or the API path. ItemID is the item an entry is about, if any.
+type ActivityEntry struct {
+	ID         int64  `json:"id"`
+	Kind       string `json:"kind"`
+	Action     string `json:"action"`
+	Target     string `json:"target"`
+	Status     int    `json:"status"` // HTTP status, 0 if the request failed
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	IPAddress  string `json:"ip_address,omitempty"`
+	ItemID     int64  `json:"item_id,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+func RecordActivity(userID int64, e ActivityEntry) error {
+	_, err := DB.Exec(`
+		INSERT INTO activity_log (user_id, kind, action, target, status, error, duration_ms, ip_address, item_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, 0))`,
+		userID, e.Kind, e.Action, e.Target, e.Status, e.Error, e.DurationMs, e.IPAddress, e.ItemID)
+	return err
+}
+
+// GetActivity returns a user's latest activity log entries, newest first.
+// An empty kind returns entries of all kinds; limit <= 0 returns all.
+func GetActivity(userID int64, kind string, limit int) ([]ActivityEntry, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := DB.Query(`
+		SELECT id, kind, action, target, status, COALESCE(error, ''), duration_ms, COALESCE(ip_address, ''), COALESCE(item_id, 0), created_at
+		FROM activity_log
+		WHERE user_id = ? AND (? = '' OR kind = ?)
+		ORDER BY id DESC
+		LIMIT ?`, userID, kind, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []ActivityEntry
+	for rows.Next() {
+		var e ActivityEntry
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Action, &e.Target, &e.Status, &e.Error, &e.DurationMs, &e.IPAddress, &e.ItemID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// DeleteOldActivity removes activity log entries older than the given number
+// of days and returns how many there were.
+func DeleteOldActivity(days int) (int64, error) {
+	result, err := DB.Exec("DELETE FROM activity_log WHERE created_at < datetime('now', ?)", fmt.Sprintf("-%d days", days))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,54 @@
+package database
+
+import "database/sql"
+
+// Items can be archived (archived_at) to keep them out of the way: archived
+// items are left out of the list pages, the dashboard and search, but are
+// kept, exported and backed up like any other, and each list can show them
+// on their own.
+
+// Archived picks items of a list query by whether they are archived
+type Archived int
+
+const (
+	NotArchived  Archived = iota // what the list pages show
+	OnlyArchived                 // a list's archive
+	AnyArchived                  // exports, backups and rules
+)
+
+// sql returns the condition on items i that picks the items
+func (a Archived) sql() string {
+	switch a {
+	case NotArchived:
+		return " AND i.archived_at IS NULL"
+	case OnlyArchived:
+		return " AND i.archived_at IS NOT NULL"
+	}
+	return ""
+}
+
+// IsItemArchived reports whether one of the user's items (not in the trash)
+// is archived. It returns sql.ErrNoRows if there is no such item.
+func IsItemArchived(itemID, userID int64) (bool, error) {
+	var archived bool
+	err := DB.QueryRow("SELECT archived_at IS NOT NULL FROM items WHERE id = ? AND user_id = ? AND deleted_at IS NULL",
+		itemID, userID).Scan(&archived)
+	return archived, err
+}
+
+// SetItemArchived archives or unarchives one of the user's items (not in the
+// trash). It returns sql.ErrNoRows if there is no such item.
+func SetItemArchived(itemID, userID int64, archived bool) error {
+	query := "UPDATE items SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP)"
+	if !archived {
+		query = "UPDATE items SET archived_at = NULL"
+	}
+	res, err := DB.Exec(query+" WHERE id = ? AND user_id = ? AND deleted_at IS NULL", itemID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
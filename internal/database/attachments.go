@@ -0,0 +1,63 @@
+package database
+
+import (
+	"time"
+
+	"infokeep/internal/models"
+)
+
+// Any item can have files attached to it. Their rows go with the item when
+// it is purged from the trash, and the files with them (itemFilesQuery).
+
+// AddAttachment records a file uploaded to one of the user's items, who the
+// caller checked owns it
+func AddAttachment(a models.Attachment) (*models.Attachment, error) {
+	a.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	res, err := DB.Exec("INSERT INTO attachments (item_id, file_path, file_name, mime_type, size, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		a.ItemID, a.FilePath, a.FileName, a.MimeType, a.Size, a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if a.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	a.CreatedAt = a.CreatedAt.Local()
+	return &a, nil
+}
+
+// GetAttachments returns the files attached to one of the user's items,
+// oldest first. itemID 0 returns those of all their items.
+func GetAttachments(userID, itemID int64) ([]models.Attachment, error) {
+	rows, err := DB.Query(`
+		SELECT a.id, a.item_id, a.file_name, a.file_path, a.mime_type, a.size, a.created_at
+		FROM attachments a JOIN items i ON i.id = a.item_id
+		WHERE i.user_id = ? AND (? = 0 OR a.item_id = ?)
+		ORDER BY a.item_id, a.id`, userID, itemID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(&a.ID, &a.ItemID, &a.FileName, &a.FilePath, &a.MimeType, &a.Size, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.CreatedAt = a.CreatedAt.Local()
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment removes a file attached to one of the user's items and
+// returns its path, for the caller to remove the file. It returns
+// sql.ErrNoRows if the item has no such attachment or is not the user's.
+func DeleteAttachment(userID, itemID, id int64) (string, error) {
+	var path string
+	err := DB.QueryRow(`
+		DELETE FROM attachments WHERE id = ? AND item_id = ?
+			AND item_id IN (SELECT id FROM items WHERE user_id = ?)
+		RETURNING file_path`, id, itemID, userID).Scan(&path)
+	return path, err
+}
@@ -0,0 +1,48 @@
+package database
+
+// Authentication events recorded per user
+const (
+	AuthLogin             = "login"
+	AuthLoginFailed       = "login_failed"
+	AuthLogout            = "logout"
+	AuthPasswordChanged   = "password_changed"
+	AuthAPITokenGenerated = "api_token_generated"
+)
+
+// AuthEvent is a sign-in or credential change of a user.
+type AuthEvent struct {
+	ID        int64  `json:"id"`
+	Event     string `json:"event"`
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+	CreatedAt string `json:"created_at"`
+}
+
+func RecordAuthEvent(userID int64, event, ipAddress, userAgent string) error {
+	_, err := DB.Exec("INSERT INTO auth_events (user_id, event, ip_address, user_agent) VALUES (?, ?, ?, ?)",
+		userID, event, ipAddress, userAgent)
+	return err
+}
+
+// GetAuthEvents returns all of a user's authentication events, newest first.
+func GetAuthEvents(userID int64) ([]AuthEvent, error) {
+	rows, err := DB.Query(`
+		SELECT id, event, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		FROM auth_events
+		WHERE user_id = ?
+		ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []AuthEvent
+	for rows.Next() {
+		var e AuthEvent
+		if err := rows.Scan(&e.ID, &e.Event, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
@@ -0,0 +1,55 @@
+package database
+
+// A bookmark added from the web form is saved before its page is read; a
+// background job fills in what the page says afterwards. metadata_pending
+// is set in between.
+
+// BookmarkMetadata is what was read from a bookmark's page
+type BookmarkMetadata struct {
+	CanonicalURL string
+	Title        string // replaces the title only if it is the one to replace
+	Description  string // fills in an empty description
+	Thumbnail    string
+	ArticleText  string
+}
+
+// SetBookmarkMetadataPending marks whether a bookmark's page is still to be
+// read
+func SetBookmarkMetadataPending(itemID int64, pending bool) error {
+	_, err := DB.Exec("UPDATE bookmarks SET metadata_pending = ? WHERE item_id = ?", pending, itemID)
+	return err
+}
+
+// FillBookmarkMetadata stores what was read from the page at url of a
+// bookmark and clears metadata_pending. The title replaces titleToReplace,
+// what the bookmark was saved with when the user gave none, unless it was
+// changed meanwhile. A bookmark that was moved to another address meanwhile
+// is left alone: its new page is read too.
+func FillBookmarkMetadata(itemID int64, url, titleToReplace string, m BookmarkMetadata) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE bookmarks SET canonical_url = ?, thumbnail = COALESCE(NULLIF(?, ''), thumbnail),
+			description = CASE WHEN COALESCE(description, '') = '' THEN ? ELSE description END,
+			article_text = NULLIF(?, ''), metadata_pending = 0
+		WHERE item_id = ? AND url = ?`,
+		m.CanonicalURL, m.Thumbnail, m.Description, m.ArticleText, itemID, url)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	if titleToReplace != "" && m.Title != "" {
+		_, err = tx.Exec("UPDATE items SET title = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND title = ?",
+			m.Title, itemID, titleToReplace)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
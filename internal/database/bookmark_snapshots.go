@@ -0,0 +1,114 @@
+package database
+
+import "fmt"
+
+// Bookmarked pages are fetched again now and then and the readable text of
+// each is kept in bookmark_snapshots, to tell when a page has changed since
+// it was saved. The text before the last significant change is kept too, so
+// the change can be shown.
+
+// BookmarkToCheck is a bookmark due for a check of its page
+type BookmarkToCheck struct {
+	ID     int64
+	UserID int64
+	Title  string
+	URL    string
+}
+
+// BookmarkSnapshot is the stored text of a bookmarked page
+type BookmarkSnapshot struct {
+	ItemID          int64
+	URL             string
+	ContentHash     string
+	Content         string
+	PreviousContent string
+	ChangePercent   int
+	CheckedAt       string
+	ChangedAt       string // empty if no change was found yet
+	ChangeSeen      bool   // whether the user has looked at the change
+}
+
+// GetBookmarksToCheck returns up to limit bookmarks (of every user, not in
+// the trash) whose page was never checked or not in the last days days,
+// those checked longest ago first
+func GetBookmarksToCheck(days, limit int) ([]BookmarkToCheck, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.user_id, i.title, COALESCE(NULLIF(b.canonical_url, ''), b.url)
+		FROM items i
+		JOIN bookmarks b ON b.item_id = i.id
+		LEFT JOIN bookmark_snapshots s ON s.item_id = i.id
+		WHERE i.deleted_at IS NULL AND (s.checked_at IS NULL OR s.checked_at < datetime('now', ?))
+		ORDER BY s.checked_at IS NOT NULL, s.checked_at, i.id
+		LIMIT ?`, fmt.Sprintf("-%d days", days), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []BookmarkToCheck
+	for rows.Next() {
+		var b BookmarkToCheck
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Title, &b.URL); err != nil {
+			return nil, err
+		}
+		due = append(due, b)
+	}
+	return due, rows.Err()
+}
+
+// GetBookmarkSnapshot returns the stored text of a bookmark's page. It
+// returns sql.ErrNoRows if the page was not checked yet.
+func GetBookmarkSnapshot(itemID int64) (*BookmarkSnapshot, error) {
+	var s BookmarkSnapshot
+	err := DB.QueryRow(`
+		SELECT item_id, url, content_hash, content, COALESCE(previous_content, ''), change_percent,
+			COALESCE(checked_at, ''), COALESCE(changed_at, ''), change_seen
+		FROM bookmark_snapshots WHERE item_id = ?`, itemID).
+		Scan(&s.ItemID, &s.URL, &s.ContentHash, &s.Content, &s.PreviousContent, &s.ChangePercent, &s.CheckedAt, &s.ChangedAt, &s.ChangeSeen)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveBookmarkSnapshot stores the text of a bookmark's page as checked now,
+// replacing what was stored. The stored change is kept.
+func SaveBookmarkSnapshot(itemID int64, url, hash, content string) error {
+	_, err := DB.Exec(`
+		INSERT INTO bookmark_snapshots (item_id, url, content_hash, content, checked_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(item_id) DO UPDATE SET
+			url = excluded.url, content_hash = excluded.content_hash,
+			content = excluded.content, checked_at = excluded.checked_at`,
+		itemID, url, hash, content)
+	return err
+}
+
+// SaveBookmarkChange stores the text of a bookmark's page as checked now
+// and as a significant change (of percent of its lines) from the text that
+// was stored
+func SaveBookmarkChange(itemID int64, hash, content string, percent int) error {
+	_, err := DB.Exec(`
+		UPDATE bookmark_snapshots SET
+			previous_content = content, content = ?, content_hash = ?, change_percent = ?,
+			checked_at = CURRENT_TIMESTAMP, changed_at = CURRENT_TIMESTAMP, change_seen = 0
+		WHERE item_id = ?`, content, hash, percent, itemID)
+	return err
+}
+
+// TouchBookmarkSnapshot records that a bookmark's page was checked now
+// without its text, as when it could not be fetched, so it isn't checked
+// again before it is due
+func TouchBookmarkSnapshot(itemID int64, url string) error {
+	_, err := DB.Exec(`
+		INSERT INTO bookmark_snapshots (item_id, url, checked_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(item_id) DO UPDATE SET checked_at = excluded.checked_at`, itemID, url)
+	return err
+}
+
+// MarkBookmarkChangeSeen records that the user has looked at the last change
+// of a bookmark's page, which takes the mark off its card
+func MarkBookmarkChangeSeen(itemID int64) error {
+	_, err := DB.Exec("UPDATE bookmark_snapshots SET change_seen = 1 WHERE item_id = ?", itemID)
+	return err
+}
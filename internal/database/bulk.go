@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// BulkAction is what BulkUpdateItems does to each of the items
+type BulkAction string
+
+const (
+	BulkDelete    BulkAction = "delete" // move to the trash
+	BulkAddTag    BulkAction = "tag-add"
+	BulkRemoveTag BulkAction = "tag-remove"
+	BulkArchive   BulkAction = "archive"
+	BulkUnarchive BulkAction = "unarchive"
+	BulkPin       BulkAction = "pin"
+	BulkUnpin     BulkAction = "unpin"
+)
+
+// MaxBulkItems is how many items BulkUpdateItems takes at a time, so their
+// ids fit in one query
+const MaxBulkItems = tagBatchSize
+
+// BulkActions are the actions BulkUpdateItems knows
+var BulkActions = []BulkAction{BulkDelete, BulkAddTag, BulkRemoveTag, BulkArchive, BulkUnarchive, BulkPin, BulkUnpin}
+
+// ValidBulkAction reports whether BulkUpdateItems knows the action
+func ValidBulkAction(action BulkAction) bool {
+	for _, a := range BulkActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// BulkUpdateItems applies an action to some of the user's items in one
+// transaction: either every item changes or none does. tag is the tag the
+// tag actions add or remove. It returns sql.ErrNoRows unless all ids are
+// items of the user outside the trash.
+func BulkUpdateItems(userID int64, ids []int64, action BulkAction, tag string) error {
+	if !ValidBulkAction(action) {
+		return fmt.Errorf("unknown action %q", action)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > MaxBulkItems {
+		return fmt.Errorf("at most %d items at a time", MaxBulkItems)
+	}
+	tag = strings.TrimSpace(strings.ToLower(tag))
+	if (action == BulkAddTag || action == BulkRemoveTag) && tag == "" {
+		return fmt.Errorf("%s needs a tag", action)
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := make([]interface{}, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			args = append(args, id)
+		}
+	}
+	in := "?" + strings.Repeat(", ?", len(args)-1)
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM items WHERE user_id = ? AND deleted_at IS NULL AND id IN ("+in+")",
+		append([]interface{}{userID}, args...)...).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != len(args) {
+		return sql.ErrNoRows
+	}
+
+	switch action {
+	case BulkDelete:
+		_, err = tx.Exec("UPDATE items SET deleted_at = CURRENT_TIMESTAMP WHERE id IN ("+in+")", args...)
+	case BulkArchive:
+		_, err = tx.Exec("UPDATE items SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP) WHERE id IN ("+in+")", args...)
+	case BulkUnarchive:
+		_, err = tx.Exec("UPDATE items SET archived_at = NULL WHERE id IN ("+in+")", args...)
+	case BulkPin, BulkUnpin:
+		_, err = tx.Exec("UPDATE items SET is_pinned = ? WHERE id IN ("+in+")", append([]interface{}{action == BulkPin}, args...)...)
+	case BulkAddTag:
+		if _, err = tx.Exec("INSERT OR IGNORE INTO tags (user_id, name) VALUES (?, ?)", userID, tag); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT OR IGNORE INTO item_tags (item_id, tag_id)
+			SELECT i.id, t.id FROM items i JOIN tags t ON t.user_id = ? AND t.name = ?
+			WHERE i.id IN (`+in+`)`, append([]interface{}{userID, tag}, args...)...)
+	case BulkRemoveTag:
+		_, err = tx.Exec(`
+			DELETE FROM item_tags
+			WHERE tag_id IN (SELECT id FROM tags WHERE user_id = ? AND name = ?) AND item_id IN (`+in+`)`,
+			append([]interface{}{userID, tag}, args...)...)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Comment statuses. Comments left by guests start out pending until the
+// owner of the item approves or rejects them from their inbox.
+const (
+	CommentPending  = "pending"
+	CommentApproved = "approved"
+	CommentRejected = "rejected"
+)
+
+// Comment is a guest comment on a shared item.
+type Comment struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id"`
+	ItemID     int64  `json:"item_id"`
+	ItemTitle  string `json:"item_title"`
+	ItemType   string `json:"item_type"`
+	LinkHash   string `json:"link_hash"`
+	AuthorName string `json:"author_name"`
+	Body       string `json:"body"`
+	Status     string `json:"status"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// CreateComment stores a pending comment for the owner to moderate.
+func CreateComment(userID, itemID int64, linkHash, authorName, body, ipAddress string) (int64, error) {
+	result, err := DB.Exec(`
+		INSERT INTO comments (user_id, item_id, link_hash, author_name, body, status, ip_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, userID, itemID, linkHash, authorName, body, CommentPending, ipAddress)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// CountRecentCommentsByIP counts comments left from an IP address in the last
+// given number of minutes, for simple rate limiting of guests.
+func CountRecentCommentsByIP(ipAddress string, minutes int) (int, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM comments
+		WHERE ip_address = ? AND created_at > datetime('now', ?)`,
+		ipAddress, fmt.Sprintf("-%d minutes", minutes)).Scan(&count)
+	return count, err
+}
+
+// GetInboxComments returns a user's comments with the given status, newest first.
+func GetInboxComments(userID int64, status string) ([]Comment, error) {
+	rows, err := DB.Query(`
+		SELECT c.id, c.user_id, c.item_id, COALESCE(i.title, ''), COALESCE(i.type, ''), c.link_hash,
+			c.author_name, c.body, c.status, c.created_at
+		FROM comments c
+		LEFT JOIN items i ON c.item_id = i.id
+		WHERE c.user_id = ? AND c.status = ?
+		ORDER BY c.created_at DESC, c.id DESC`, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanComments(rows)
+}
+
+// GetApprovedComments returns the approved comments on an item, oldest first.
+func GetApprovedComments(itemID int64) ([]Comment, error) {
+	rows, err := DB.Query(`
+		SELECT c.id, c.user_id, c.item_id, COALESCE(i.title, ''), COALESCE(i.type, ''), c.link_hash,
+			c.author_name, c.body, c.status, c.created_at
+		FROM comments c
+		LEFT JOIN items i ON c.item_id = i.id
+		WHERE c.item_id = ? AND c.status = ?
+		ORDER BY c.created_at ASC, c.id ASC`, itemID, CommentApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanComments(rows)
+}
+
+func scanComments(rows *sql.Rows) ([]Comment, error) {
+	var comments []Comment
+	for rows.Next() {
+		var c Comment
+		var linkHash sql.NullString
+		if err := rows.Scan(&c.ID, &c.UserID, &c.ItemID, &c.ItemTitle, &c.ItemType, &linkHash,
+			&c.AuthorName, &c.Body, &c.Status, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		c.LinkHash = linkHash.String
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+// CountPendingComments returns how many comments await the user's moderation.
+func CountPendingComments(userID int64) int {
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM comments WHERE user_id = ? AND status = ?", userID, CommentPending).Scan(&count)
+	return count
+}
+
+// SetCommentStatus approves or rejects a comment. Returns sql.ErrNoRows if
+// the comment does not belong to the user.
+func SetCommentStatus(userID, id int64, status string) error {
+	result, err := DB.Exec("UPDATE comments SET status = ? WHERE id = ? AND user_id = ?", status, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func DeleteComment(userID, id int64) error {
+	_, err := DB.Exec("DELETE FROM comments WHERE id = ? AND user_id = ?", id, userID)
+	return err
+}
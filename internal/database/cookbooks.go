@@ -0,0 +1,235 @@
+package database
+
+import (
+	"database/sql"
+
+	"infokeep/internal/models"
+)
+
+// Cookbooks group recipes into named collections. A cookbook is an item of
+// type "cookbook" so it gets tags, pinning and deletion for free; the
+// many-to-many membership lives in cookbook_recipes.
+
+func CreateCookbook(userID int64, title, description, coverImage string) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO items (user_id, title, type) VALUES (?, ?, ?)", userID, title, "cookbook")
+	if err != nil {
+		return 0, err
+	}
+	itemID, _ := result.LastInsertId()
+
+	_, err = tx.Exec("INSERT INTO cookbooks (item_id, description, cover_image) VALUES (?, ?, ?)", itemID, description, coverImage)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return itemID, nil
+}
+
+// GetCookbooks returns all of the user's cookbooks, archived or not, by title
+func GetCookbooks(userID int64, tagFilter string) ([]models.Cookbook, error) {
+	cookbooks, _, err := GetCookbooksPage(userID, tagFilter, AnyArchived, Page{Sort: SortTitle})
+	return cookbooks, err
+}
+
+// GetCookbooksPage returns a page of the user's cookbooks, those with the
+// tag if tagFilter is set, that archived picks, and how many there are on
+// all pages
+func GetCookbooksPage(userID int64, tagFilter string, archived Archived, page Page) ([]models.Cookbook, int, error) {
+	from := `
+		FROM items i
+		JOIN cookbooks c ON i.id = c.item_id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL`
+	args := []interface{}{userID}
+
+	if tagFilter != "" {
+		from += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
+		args = append(args, tagFilter)
+	}
+
+	from += archived.sql()
+
+	limit, limitArgs := page.sql()
+	query := `
+		SELECT i.id, i.title, i.created_at, c.description, c.cover_image, COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL,
+			(SELECT COUNT(*) FROM cookbook_recipes cr JOIN items ri ON cr.recipe_id = ri.id WHERE cr.cookbook_id = i.id AND ri.deleted_at IS NULL)` +
+		from + page.Sort.sql() + limit
+
+	rows, err := DB.Query(query, append(args, limitArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []models.Cookbook
+	var ids []int64
+	for rows.Next() {
+		var cb models.Cookbook
+		var title, createdAt, description, coverImage sql.NullString
+		if err := rows.Scan(&cb.ID, &title, &createdAt, &description, &coverImage, &cb.IsPinned, &cb.Archived, &cb.RecipeCount); err != nil {
+			return nil, 0, err
+		}
+
+		cb.Title, cb.CreatedAt = title.String, createdAt.String
+		cb.Description, cb.CoverImage = description.String, coverImage.String
+		ids = append(ids, cb.ID)
+		results = append(results, cb)
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+
+	total, err := pageTotal(from, args, page, len(results))
+	return results, total, err
+}
+
+func GetCookbook(userID int64, id int64) (*models.Cookbook, error) {
+	var title, createdAt, description, coverImage sql.NullString
+	cb := &models.Cookbook{}
+	err := DB.QueryRow(`
+		SELECT i.id, i.title, i.created_at, c.description, c.cover_image, COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL
+		FROM items i
+		JOIN cookbooks c ON i.id = c.item_id
+		WHERE i.id = ? AND i.user_id = ? AND i.deleted_at IS NULL`, id, userID).Scan(&cb.ID, &title, &createdAt, &description, &coverImage, &cb.IsPinned, &cb.Archived)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cb.Title, cb.CreatedAt = title.String, createdAt.String
+	cb.Description, cb.CoverImage = description.String, coverImage.String
+	cb.Tags, _ = GetItemTags(id)
+	return cb, nil
+}
+
+// UpdateCookbook updates the cookbook's name and description. The cover image
+// is only replaced when coverImage is non-empty.
+func UpdateCookbook(userID int64, id int64, title, description, coverImage string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE items SET title = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND type = 'cookbook'", title, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.Exec("UPDATE cookbooks SET description = ? WHERE item_id = ?", description, id)
+	if err != nil {
+		return err
+	}
+
+	if coverImage != "" {
+		_, err = tx.Exec("UPDATE cookbooks SET cover_image = ? WHERE item_id = ?", coverImage, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetCookbookRecipes returns the recipes in a cookbook, alphabetically.
+func GetCookbookRecipes(userID int64, cookbookID int64) ([]models.Recipe, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.title, i.created_at, r.ingredients, r.instructions, r.notes, r.thumbnail, r.source_url, COALESCE(i.is_pinned, 0)
+		FROM cookbook_recipes cr
+		JOIN items i ON cr.recipe_id = i.id
+		JOIN recipes r ON i.id = r.item_id
+		WHERE cr.cookbook_id = ? AND i.user_id = ? AND i.deleted_at IS NULL
+		ORDER BY i.title COLLATE NOCASE ASC`, cookbookID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.Recipe
+	var ids []int64
+	for rows.Next() {
+		var rec models.Recipe
+		var title, createdAt, ingredients, instructions, notes, thumbnail, sourceURL sql.NullString
+		if err := rows.Scan(&rec.ID, &title, &createdAt, &ingredients, &instructions, &notes, &thumbnail, &sourceURL, &rec.IsPinned); err != nil {
+			return nil, err
+		}
+
+		rec.Title, rec.CreatedAt = title.String, createdAt.String
+		rec.Ingredients, rec.Instructions, rec.Notes = ingredients.String, instructions.String, notes.String
+		rec.Thumbnail, rec.SourceURL = thumbnail.String, sourceURL.String
+		ids = append(ids, rec.ID)
+		results = append(results, rec)
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+	return results, nil
+}
+
+// GetRecipeCookbookIDs returns the set of cookbook IDs a recipe belongs to.
+func GetRecipeCookbookIDs(recipeID int64) (map[int64]bool, error) {
+	rows, err := DB.Query("SELECT cookbook_id FROM cookbook_recipes WHERE recipe_id = ?", recipeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// AddRecipeToCookbook links a recipe to a cookbook. Both must belong to the user.
+func AddRecipeToCookbook(userID, cookbookID, recipeID int64) error {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM items
+		WHERE user_id = ? AND deleted_at IS NULL AND ((id = ? AND type = 'cookbook') OR (id = ? AND type = 'recipe'))`,
+		userID, cookbookID, recipeID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != 2 {
+		return sql.ErrNoRows
+	}
+
+	_, err = DB.Exec("INSERT OR IGNORE INTO cookbook_recipes (cookbook_id, recipe_id) VALUES (?, ?)", cookbookID, recipeID)
+	return err
+}
+
+func RemoveRecipeFromCookbook(userID, cookbookID, recipeID int64) error {
+	_, err := DB.Exec(`
+		DELETE FROM cookbook_recipes
+		WHERE cookbook_id = ? AND recipe_id = ?
+		AND cookbook_id IN (SELECT id FROM items WHERE user_id = ? AND type = 'cookbook')`,
+		cookbookID, recipeID, userID)
+	return err
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
@@ -10,18 +11,43 @@ import (
 	"strings"
 	"time"
 
+	"infokeep/internal/models"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var DB *sql.DB
 
+// SchemaVersion is the version of the schema createSchema and runMigrations
+// produce. Bump it with every schema change; it is stored as the database's
+// user_version once migrations have run.
+const SchemaVersion = 34
+
+// connParams configures every connection: a busy timeout so concurrent
+// writers wait for the lock instead of failing with "database is locked",
+// foreign keys so ON DELETE CASCADE fires, and immediate transactions, which
+// take the write lock when they begin. A deferred transaction that reads
+// before it writes can't wait for the lock and fails with SQLITE_BUSY when
+// another writer got there first. The database also uses WAL journaling, so
+// reads carry on while a write is in progress.
+const connParams = "?_busy_timeout=5000&_foreign_keys=on&_txlock=immediate"
+
 func InitDB(filepath string) error {
-	var err error
-	DB, err = sql.Open("sqlite3", filepath)
+	key, err := dbKey()
 	if err != nil {
 		return err
 	}
 
+	if key != "" {
+		DB, err = openEncrypted(filepath, key)
+	} else {
+		DB, err = sql.Open(timedSQLiteDriver, filepath+connParams+"&_journal_mode=WAL")
+	}
+	if err != nil {
+		return err
+	}
+	configurePool(DB)
+
 	if err = DB.Ping(); err != nil {
 		return err
 	}
@@ -30,7 +56,15 @@ func InitDB(filepath string) error {
 		return err
 	}
 
-	return runMigrations()
+	if err = runMigrations(); err != nil {
+		return err
+	}
+
+	if err = createTouchTriggers(); err != nil {
+		return err
+	}
+
+	return createSearchIndex()
 }
 
 func createSchema() error {
@@ -66,12 +100,22 @@ func createSchema() error {
 		description TEXT,
 		favicon TEXT,
 		thumbnail TEXT,
+		summary TEXT,
+		read_later_at DATETIME,
+		read_at DATETIME,
+		link_status INTEGER,
+		link_error TEXT,
+		link_checked_at DATETIME,
+		article_text TEXT,
+		metadata_pending INTEGER NOT NULL DEFAULT 0,
+		notes TEXT,
 		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
 	);
 
 	CREATE TABLE IF NOT EXISTS notes (
 		item_id INTEGER PRIMARY KEY,
 		content TEXT,
+		summary TEXT,
 		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
 	);
 
@@ -80,6 +124,9 @@ func createSchema() error {
 		list_id INTEGER NOT NULL,
 		content TEXT NOT NULL,
 		completed BOOLEAN DEFAULT 0,
+		quantity REAL,
+		unit TEXT,
+		price REAL,
 		FOREIGN KEY(list_id) REFERENCES items(id) ON DELETE CASCADE
 	);
 
@@ -96,12 +143,18 @@ func createSchema() error {
 		item_id INTEGER PRIMARY KEY,
 		file_path TEXT NOT NULL,
 		mime_type TEXT,
+		content_hash TEXT,
+		image_hash TEXT,
+		kind TEXT,
 		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
 	);
 
 	CREATE TABLE IF NOT EXISTS tags (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT UNIQUE NOT NULL
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		UNIQUE(user_id, name),
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 	);
 
 	CREATE TABLE IF NOT EXISTS item_tags (
@@ -128,7 +181,7 @@ func createSchema() error {
 		recipe_id INTEGER NOT NULL,
 		file_path TEXT NOT NULL,
 		display_order INTEGER DEFAULT 0,
-		FOREIGN KEY(recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+		FOREIGN KEY(recipe_id) REFERENCES items(id) ON DELETE CASCADE
 	);
 	CREATE TABLE IF NOT EXISTS drawings (
 		item_id INTEGER PRIMARY KEY,
@@ -164,11 +217,92 @@ func createSchema() error {
 		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS item_reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		item_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		remind_at DATETIME NOT NULL,
+		notification_type TEXT NOT NULL,
+		emails TEXT,
+		fired_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS item_links (
+		item_id INTEGER NOT NULL,
+		linked_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (item_id, linked_id),
+		CHECK (item_id < linked_id),
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE,
+		FOREIGN KEY(linked_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS item_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		item_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		body TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		item_id INTEGER NOT NULL,
+		file_path TEXT NOT NULL,
+		file_name TEXT NOT NULL,
+		mime_type TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS note_uploads (
+		file_path TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		file_name TEXT NOT NULL,
+		mime_type TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS item_views (
+		user_id INTEGER NOT NULL,
+		item_id INTEGER NOT NULL,
+		viewed_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, item_id),
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS page_archives (
+		item_id INTEGER PRIMARY KEY,
+		url TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		html TEXT NOT NULL,
+		text TEXT NOT NULL,
+		archived_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
 	CREATE TABLE IF NOT EXISTS system_settings (
 		key TEXT PRIMARY KEY,
 		value TEXT NOT NULL
 	);
 
+	CREATE TABLE IF NOT EXISTS user_settings (
+		user_id INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY(user_id, key),
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
 	CREATE TABLE IF NOT EXISTS shared_links (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		link_hash TEXT UNIQUE NOT NULL,
@@ -178,6 +312,171 @@ func createSchema() error {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 	);
+
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		batch_id TEXT,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		result TEXT,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS exports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		job_id INTEGER NOT NULL,
+		format TEXT NOT NULL,
+		token TEXT NOT NULL UNIQUE,
+		file_path TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS media_uploads (
+		id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		object_key TEXT NOT NULL,
+		title TEXT NOT NULL,
+		tags TEXT,
+		mime_type TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		item_id INTEGER NOT NULL,
+		link_hash TEXT,
+		author_name TEXT NOT NULL,
+		body TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		ip_address TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS cookbooks (
+		item_id INTEGER PRIMARY KEY,
+		description TEXT,
+		cover_image TEXT,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS cookbook_recipes (
+		cookbook_id INTEGER NOT NULL,
+		recipe_id INTEGER NOT NULL,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (cookbook_id, recipe_id),
+		FOREIGN KEY(cookbook_id) REFERENCES items(id) ON DELETE CASCADE,
+		FOREIGN KEY(recipe_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS known_devices (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		user_agent TEXT NOT NULL,
+		ip_address TEXT NOT NULL,
+		first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_id, user_agent, ip_address),
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS auth_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		ip_address TEXT,
+		user_agent TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS task_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task TEXT NOT NULL,
+		status TEXT NOT NULL,
+		result TEXT,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		finished_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS activity_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		ip_address TEXT,
+		item_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS favicons (
+		host TEXT PRIMARY KEY,
+		content_type TEXT,
+		data BLOB,
+		source_url TEXT,
+		failures INTEGER NOT NULL DEFAULT 0,
+		fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		item_type TEXT NOT NULL DEFAULT '',
+		field TEXT NOT NULL,
+		operator TEXT NOT NULL,
+		value TEXT NOT NULL,
+		action TEXT NOT NULL,
+		action_value TEXT NOT NULL DEFAULT '',
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS snippets (
+		item_id INTEGER PRIMARY KEY,
+		code TEXT UNIQUE NOT NULL,
+		expires_at DATETIME NOT NULL,
+		burn_after_read INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS bookmark_snapshots (
+		item_id INTEGER PRIMARY KEY,
+		url TEXT NOT NULL,
+		content_hash TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL DEFAULT '',
+		previous_content TEXT,
+		change_percent INTEGER NOT NULL DEFAULT 0,
+		checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		changed_at DATETIME,
+		change_seen INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS item_embeddings (
+		item_id INTEGER PRIMARY KEY,
+		model TEXT NOT NULL,
+		vector BLOB NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
 	`
 	_, err := DB.Exec(schema)
 	return err
@@ -291,7 +590,11 @@ func runMigrations() error {
 
 	// 4. Other miscellaneous migrations (safe to run multiple times with _, _ =)
 	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN thumbnail TEXT")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN canonical_url TEXT")
 	_, _ = DB.Exec("ALTER TABLE recipes ADD COLUMN source_url TEXT")
+	_, _ = DB.Exec("ALTER TABLE recipes ADD COLUMN original_language TEXT")
+	_, _ = DB.Exec("ALTER TABLE recipes ADD COLUMN original_ingredients TEXT")
+	_, _ = DB.Exec("ALTER TABLE recipes ADD COLUMN original_instructions TEXT")
 	_, _ = DB.Exec("ALTER TABLE users ADD COLUMN api_token TEXT")
 	_, _ = DB.Exec("ALTER TABLE users ADD COLUMN pcloud_access_token TEXT")
 	_, _ = DB.Exec("ALTER TABLE users ADD COLUMN pcloud_hostname TEXT")
@@ -302,10 +605,203 @@ func runMigrations() error {
 	_, _ = DB.Exec("ALTER TABLE users ADD COLUMN gdrive_folder_id TEXT")
 	_, _ = DB.Exec("ALTER TABLE rated_list_items ADD COLUMN image_path TEXT")
 	_, _ = DB.Exec("ALTER TABLE users ADD COLUMN default_page TEXT DEFAULT 'dashboard'")
+	_, _ = DB.Exec("ALTER TABLE users ADD COLUMN login_alert_email TEXT")
+	_, _ = DB.Exec("ALTER TABLE users ADD COLUMN api_token_allowlist TEXT")
+	_, _ = DB.Exec("ALTER TABLE drawings ADD COLUMN vector_data TEXT")
+	_, _ = DB.Exec("ALTER TABLE jobs ADD COLUMN progress INTEGER NOT NULL DEFAULT 0")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN summary TEXT")
+	_, _ = DB.Exec("ALTER TABLE notes ADD COLUMN summary TEXT")
+	_, _ = DB.Exec("ALTER TABLE items ADD COLUMN deleted_at DATETIME")
+	_, _ = DB.Exec("ALTER TABLE media ADD COLUMN content_hash TEXT")
+	_, _ = DB.Exec("ALTER TABLE media ADD COLUMN image_hash TEXT")
+	_, _ = DB.Exec("ALTER TABLE activity_log ADD COLUMN item_id INTEGER")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN read_later_at DATETIME")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN read_at DATETIME")
+	_, _ = DB.Exec("ALTER TABLE list_items ADD COLUMN quantity REAL")
+	_, _ = DB.Exec("ALTER TABLE list_items ADD COLUMN unit TEXT")
+	_, _ = DB.Exec("ALTER TABLE list_items ADD COLUMN price REAL")
+	_, _ = DB.Exec("ALTER TABLE items ADD COLUMN archived_at DATETIME")
+	_, _ = DB.Exec("ALTER TABLE items ADD COLUMN sort_order INTEGER")
+	_, _ = DB.Exec("ALTER TABLE users ADD COLUMN feed_token TEXT")
+	_, _ = DB.Exec("ALTER TABLE list_items ADD COLUMN due_date TEXT")
+	_, _ = DB.Exec("ALTER TABLE list_items ADD COLUMN due_notified_at DATETIME")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN link_status INTEGER")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN link_error TEXT")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN link_checked_at DATETIME")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN article_text TEXT")
+	_, _ = DB.Exec("ALTER TABLE bookmarks ADD COLUMN metadata_pending INTEGER NOT NULL DEFAULT 0")
+	// Before links expired, rated lists were the only lists that could be
+	// shared and were shared as "list", which now means a checklist
+	if _, err := DB.Exec("ALTER TABLE shared_links ADD COLUMN expires_at DATETIME"); err == nil {
+		_, _ = DB.Exec("UPDATE shared_links SET item_type = 'rated_list' WHERE item_type = 'list'")
+	}
+	// The clipper used to store a bookmark's notes as its favicon, which
+	// only ever holds a path or URL
+	if _, err := DB.Exec("ALTER TABLE bookmarks ADD COLUMN notes TEXT"); err == nil {
+		_, _ = DB.Exec(`UPDATE bookmarks SET notes = favicon, favicon = ''
+			WHERE favicon != '' AND favicon NOT LIKE '/%' AND favicon NOT LIKE 'http%'`)
+	}
+	// Media fingerprinted before kinds were told apart is fingerprinted again
+	if _, err := DB.Exec("ALTER TABLE media ADD COLUMN kind TEXT"); err == nil {
+		_, _ = DB.Exec("UPDATE media SET content_hash = NULL")
+	}
+
+	if err := fixRecipeImagesForeignKey(); err != nil {
+		return err
+	}
+
+	if err := splitTagsByUser(); err != nil {
+		return err
+	}
+
+	if err := createIndexes(); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// indexes back the queries run on every page view: item lists of a user
+// and type, newest first, tag lookups and filters, the entries of checklists
+// and rated lists, and the cleanup of expired sessions.
+var indexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_items_user_type_created ON items(user_id, type, created_at)",
+	"CREATE INDEX IF NOT EXISTS idx_item_tags_tag ON item_tags(tag_id)",
+	"CREATE INDEX IF NOT EXISTS idx_list_items_list ON list_items(list_id)",
+	"CREATE INDEX IF NOT EXISTS idx_rated_list_items_list ON rated_list_items(rated_list_id)",
+	"CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at)",
+	"CREATE INDEX IF NOT EXISTS idx_item_reminders_due ON item_reminders(fired_at, remind_at)",
+	"CREATE INDEX IF NOT EXISTS idx_item_links_linked ON item_links(linked_id)",
+	"CREATE INDEX IF NOT EXISTS idx_item_log_item ON item_log(item_id, id)",
+	"CREATE INDEX IF NOT EXISTS idx_attachments_item ON attachments(item_id)",
+	"CREATE INDEX IF NOT EXISTS idx_item_views_user ON item_views(user_id, viewed_at)",
+}
+
+// createIndexes adds the indexes missing from the database. It runs after the
+// columns they cover have been migrated in.
+func createIndexes() error {
+	for _, stmt := range indexes {
+		if _, err := DB.Exec(stmt); err != nil {
+			return fmt.Errorf("creating index: %w", err)
+		}
+	}
+	return nil
+}
+
+// fixRecipeImagesForeignKey rebuilds recipe_images of databases created
+// when its recipe_id referenced recipes(id). recipe_id holds the recipe's
+// item id, so once foreign keys are enforced inserts fail and deleting a
+// recipe would cascade to another recipe's images. Images of recipes that
+// no longer exist are dropped.
+func fixRecipeImagesForeignKey() error {
+	var parent string
+	err := DB.QueryRow(`SELECT "table" FROM pragma_foreign_key_list('recipe_images') WHERE "from" = 'recipe_id'`).Scan(&parent)
+	if err != nil || parent != "recipes" {
+		return nil
+	}
+
+	log.Println("Migrating database: Pointing recipe_images at items")
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`CREATE TABLE recipe_images_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recipe_id INTEGER NOT NULL,
+			file_path TEXT NOT NULL,
+			display_order INTEGER DEFAULT 0,
+			FOREIGN KEY(recipe_id) REFERENCES items(id) ON DELETE CASCADE
+		)`,
+		`INSERT INTO recipe_images_new (id, recipe_id, file_path, display_order)
+			SELECT id, recipe_id, file_path, display_order FROM recipe_images
+			WHERE recipe_id IN (SELECT id FROM items)`,
+		`DROP TABLE recipe_images`,
+		`ALTER TABLE recipe_images_new RENAME TO recipe_images`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("rebuilding recipe_images: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// splitTagsByUser gives each user their own tags in databases created when
+// tags were shared by all users: a tag used by several users becomes one tag
+// per user, and tags no item uses are dropped. Both tables are rebuilt, as
+// the UNIQUE constraint on the name can't be changed in place; item_tags goes
+// first so dropping tags doesn't cascade to it.
+func splitTagsByUser() error {
+	var n int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('tags') WHERE name = 'user_id'`).Scan(&n); err != nil || n > 0 {
+		return err
+	}
+
+	log.Println("Migrating database: Giving each user their own tags")
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`CREATE TEMP TABLE tag_owners AS
+			SELECT it.item_id, i.user_id, t.name
+			FROM item_tags it
+			JOIN tags t ON t.id = it.tag_id
+			JOIN items i ON i.id = it.item_id
+			WHERE i.user_id IS NOT NULL`,
+		`DROP TABLE item_tags`,
+		`DROP TABLE tags`,
+		`CREATE TABLE tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			UNIQUE(user_id, name),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE item_tags (
+			item_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (item_id, tag_id),
+			FOREIGN KEY(item_id) REFERENCES items(id) ON DELETE CASCADE,
+			FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+		)`,
+		`INSERT INTO tags (user_id, name)
+			SELECT DISTINCT user_id, name FROM tag_owners
+			WHERE user_id IN (SELECT id FROM users)
+			ORDER BY user_id, name`,
+		`INSERT INTO item_tags (item_id, tag_id)
+			SELECT o.item_id, t.id FROM tag_owners o
+			JOIN tags t ON t.user_id = o.user_id AND t.name = o.name`,
+		`DROP TABLE tag_owners`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("splitting tags by user: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// BackupTo writes a consistent copy of the database to path, which must not
+// exist yet. Unlike copying infokeep.db it includes the changes still in the
+// WAL file.
+func BackupTo(path string) error {
+	_, err := DB.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// GetSchemaVersion returns the schema version recorded in the database
+func GetSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := DB.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
 func GetUserByToken(token string) (int64, error) {
 	var userID int64
 	err := DB.QueryRow("SELECT id FROM users WHERE api_token = ?", token).Scan(&userID)
@@ -340,6 +836,21 @@ func RegenerateAPIToken(userID int64) (string, error) {
 	return token, nil
 }
 
+// GetTokenAllowlist returns the newline-separated CIDR ranges the user's API
+// token may be used from. Empty means no restriction.
+func GetTokenAllowlist(userID int64) string {
+	var allowlist sql.NullString
+	if err := DB.QueryRow("SELECT api_token_allowlist FROM users WHERE id = ?", userID).Scan(&allowlist); err != nil {
+		return ""
+	}
+	return allowlist.String
+}
+
+func SetTokenAllowlist(userID int64, allowlist string) error {
+	_, err := DB.Exec("UPDATE users SET api_token_allowlist = ? WHERE id = ?", allowlist, userID)
+	return err
+}
+
 func GetSystemSetting(key string) (string, error) {
 	var value string
 	err := DB.QueryRow("SELECT value FROM system_settings WHERE key = ?", key).Scan(&value)
@@ -354,6 +865,64 @@ func SetSystemSetting(key string, value string) error {
 	return err
 }
 
+// GetUserSetting returns one of the user's settings, or "" if it isn't set
+func GetUserSetting(userID int64, key string) string {
+	var value string
+	if err := DB.QueryRow("SELECT value FROM user_settings WHERE user_id = ? AND key = ?", userID, key).Scan(&value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// GetUserSettings returns the user's settings among keys, by key; keys the
+// user has no setting for are missing
+func GetUserSettings(userID int64, keys ...string) map[string]string {
+	settings := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return settings
+	}
+	args := []interface{}{userID}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	rows, err := DB.Query("SELECT key, value FROM user_settings WHERE user_id = ? AND key IN (?"+strings.Repeat(", ?", len(keys)-1)+")", args...)
+	if err != nil {
+		return settings
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if rows.Scan(&key, &value) == nil {
+			settings[key] = value
+		}
+	}
+	return settings
+}
+
+func SetUserSetting(userID int64, key string, value string) error {
+	_, err := DB.Exec("INSERT INTO user_settings (user_id, key, value) VALUES (?, ?, ?) ON CONFLICT(user_id, key) DO UPDATE SET value=excluded.value", userID, key, value)
+	return err
+}
+
+// GetUsersWithSetting returns the users who have the setting key set to
+// something other than ""
+func GetUsersWithSetting(key string) ([]int64, error) {
+	rows, err := DB.Query("SELECT user_id FROM user_settings WHERE key = ? AND value != '' ORDER BY user_id", key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		users = append(users, id)
+	}
+	return users, rows.Err()
+}
+
 // ... (skipping unchanged parts) ...
 
 // Recipes
@@ -384,68 +953,84 @@ func CreateDrawing(userID int64, title, filePath string) (int64, error) {
 	return itemID, nil
 }
 
-func GetDrawings(userID int64, tagFilter string) ([]map[string]interface{}, error) {
-	query := `
-		SELECT i.id, i.title, i.created_at, d.file_path, COALESCE(i.is_pinned, 0)
+func GetDrawings(userID int64, tagFilter string) ([]models.Drawing, error) {
+	drawings, _, err := GetDrawingsPage(userID, tagFilter, AnyArchived, Page{})
+	return drawings, err
+}
+
+// GetDrawingsPage returns one page of what GetDrawings returns, of the items archived
+// picks, and the number of rows on all pages
+func GetDrawingsPage(userID int64, tagFilter string, archived Archived, page Page) ([]models.Drawing, int, error) {
+	from := `
 		FROM items i 
 		JOIN drawings d ON i.id = d.item_id 
-		WHERE i.user_id = ?`
+		WHERE i.user_id = ? AND i.deleted_at IS NULL`
 	args := []interface{}{userID}
 
 	if tagFilter != "" {
-		query += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
+		from += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
 		args = append(args, tagFilter)
 	}
 
-	query += " ORDER BY i.created_at DESC"
+	from += archived.sql()
+
+	limit, limitArgs := page.sql()
+	query := `
+		SELECT i.id, i.title, i.created_at, d.file_path, COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL` + from + page.Sort.sql() + limit
 
-	rows, err := DB.Query(query, args...)
+	rows, err := DB.Query(query, append(args, limitArgs...)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.Drawing
+	var ids []int64
 	for rows.Next() {
-		var id int64
-		var isPinned int
+		var d models.Drawing
 		var title, createdAt, filePath sql.NullString
-		if err := rows.Scan(&id, &title, &createdAt, &filePath, &isPinned); err != nil {
-			return nil, err
+		if err := rows.Scan(&d.ID, &title, &createdAt, &filePath, &d.IsPinned, &d.Archived); err != nil {
+			return nil, 0, err
 		}
+		d.Title, d.CreatedAt, d.FilePath = title.String, createdAt.String, filePath.String
+		ids = append(ids, d.ID)
+		results = append(results, d)
+	}
 
-		tags, _ := GetItemTags(id)
-		results = append(results, map[string]interface{}{
-			"id":         id,
-			"title":      title.String,
-			"created_at": createdAt.String,
-			"file_path":  filePath.String,
-			"tags":       tags,
-			"is_pinned":  isPinned == 1,
-		})
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, 0, err
 	}
-	return results, nil
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+
+	total, err := pageTotal(from, args, page, len(results))
+	return results, total, err
 }
 
-func GetDrawing(userID int64, id int64) (map[string]interface{}, error) {
-	var title, filePath sql.NullString
+func GetDrawing(userID int64, id int64) (*models.Drawing, error) {
+	var title, createdAt, filePath, vectorData sql.NullString
+	d := &models.Drawing{}
 	err := DB.QueryRow(`
-		SELECT i.title, d.file_path 
+		SELECT i.id, i.title, i.created_at, d.file_path, d.vector_data, COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL
 		FROM items i 
 		JOIN drawings d ON i.id = d.item_id 
-		WHERE i.id = ? AND i.user_id = ?`, id, userID).Scan(&title, &filePath)
+		WHERE i.id = ? AND i.user_id = ? AND i.deleted_at IS NULL`, id, userID).Scan(&d.ID, &title, &createdAt, &filePath, &vectorData, &d.IsPinned, &d.Archived)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tags, _ := GetItemTags(id)
-	return map[string]interface{}{
-		"id":        id,
-		"title":     title.String,
-		"file_path": filePath.String,
-		"tags":      tags,
-	}, nil
+	d.Title, d.CreatedAt, d.FilePath, d.VectorData = title.String, createdAt.String, filePath.String, vectorData.String
+	d.Tags, _ = GetItemTags(id)
+	return d, nil
+}
+
+// SetDrawingVectorData stores the vector (stroke) data an external app sent along with a drawing
+func SetDrawingVectorData(id int64, vectorData string) error {
+	_, err := DB.Exec("UPDATE drawings SET vector_data = ? WHERE item_id = ?", vectorData, id)
+	return err
 }
 
 func UpdateDrawing(id int64, title, filePath string) error {
@@ -461,7 +1046,8 @@ func UpdateDrawing(id int64, title, filePath string) error {
 	}
 
 	if filePath != "" {
-		_, err = tx.Exec("UPDATE drawings SET file_path = ? WHERE item_id = ?", filePath, id)
+		// The canvas editor saves a flat image, so any vector data no longer matches
+		_, err = tx.Exec("UPDATE drawings SET file_path = ?, vector_data = NULL WHERE item_id = ?", filePath, id)
 		if err != nil {
 			return err
 		}
@@ -472,7 +1058,10 @@ func UpdateDrawing(id int64, title, filePath string) error {
 
 // Bookmarks
 
-func CreateBookmark(userID int64, title, url, description, favicon, thumbnail string) (int64, error) {
+// CreateBookmark saves a bookmark. url is the address as the user gave it,
+// canonicalURL where it resolved to (after redirects and rel=canonical); an
+// empty canonicalURL means the same as url.
+func CreateBookmark(userID int64, title, url, canonicalURL, description, favicon, thumbnail string) (int64, error) {
 	tx, err := DB.Begin()
 	if err != nil {
 		return 0, err
@@ -486,8 +1075,8 @@ func CreateBookmark(userID int64, title, url, description, favicon, thumbnail st
 	itemID, _ := result.LastInsertId()
 
 	_, err = tx.Exec(
-		"INSERT INTO bookmarks (item_id, url, description, favicon, thumbnail) VALUES (?, ?, ?, ?, ?)",
-		itemID, url, description, favicon, thumbnail,
+		"INSERT INTO bookmarks (item_id, url, canonical_url, description, favicon, thumbnail) VALUES (?, ?, ?, ?, ?, ?)",
+		itemID, url, canonicalURL, description, favicon, thumbnail,
 	)
 	if err != nil {
 		return 0, err
@@ -500,58 +1089,91 @@ func CreateBookmark(userID int64, title, url, description, favicon, thumbnail st
 	return itemID, nil
 }
 
-func GetBookmarks(userID int64, tagFilter string) ([]map[string]interface{}, error) {
-	query := `
-		SELECT i.id, i.title, i.created_at, b.url, b.description, b.favicon, b.thumbnail, COALESCE(i.is_pinned, 0)
+func GetBookmarks(userID int64, tagFilter string) ([]models.Bookmark, error) {
+	bookmarks, _, err := GetBookmarksPage(userID, tagFilter, AllBookmarks, AnyArchived, Page{})
+	return bookmarks, err
+}
+
+// BookmarkKind picks the bookmarks of a list query
+type BookmarkKind int
+
+const (
+	AllBookmarks       BookmarkKind = iota
+	ReadLaterBookmarks              // the read-later queue
+	BrokenBookmarks                 // those whose link failed its last check
+)
+
+// GetBookmarksPage returns one page of what GetBookmarks returns, of the items archived
+// picks, and the number of rows on all pages. ReadLaterBookmarks returns the read-later queue instead,
+// the longest waiting first.
+func GetBookmarksPage(userID int64, tagFilter string, kind BookmarkKind, archived Archived, page Page) ([]models.Bookmark, int, error) {
+	from := `
 		FROM items i 
 		JOIN bookmarks b ON i.id = b.item_id 
-		WHERE i.user_id = ?`
+		LEFT JOIN bookmark_snapshots s ON s.item_id = i.id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL`
 	args := []interface{}{userID}
 
 	if tagFilter != "" {
-		query += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
+		from += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
 		args = append(args, tagFilter)
 	}
+	order := page.Sort.sql()
+	switch kind {
+	case ReadLaterBookmarks:
+		from += " AND b.read_later_at IS NOT NULL AND b.read_at IS NULL"
+		order = " ORDER BY b.read_later_at, i.id"
+	case BrokenBookmarks:
+		from += " AND " + brokenLinkSQL
+	}
 
-	query += " ORDER BY i.created_at DESC"
+	from += archived.sql()
 
-	rows, err := DB.Query(query, args...)
+	limit, limitArgs := page.sql()
+	query := `
+		SELECT i.id, i.title, i.created_at, b.url, COALESCE(NULLIF(b.canonical_url, ''), b.url),
+			b.description, COALESCE(b.notes, ''), b.favicon, b.thumbnail, COALESCE(b.summary, ''), COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL,
+			s.changed_at IS NOT NULL AND NOT COALESCE(s.change_seen, 0),
+			b.read_later_at IS NOT NULL AND b.read_at IS NULL, COALESCE(b.read_at, ''),
+			EXISTS (SELECT 1 FROM page_archives pa WHERE pa.item_id = i.id),
+			COALESCE(b.link_status, 0), COALESCE(b.link_error, ''), COALESCE(b.link_checked_at, ''), ` + brokenLinkSQL + `,
+			b.metadata_pending` + from + order + limit
+
+	rows, err := DB.Query(query, append(args, limitArgs...)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.Bookmark
+	var ids []int64
 	for rows.Next() {
-		var id int64
-		var isPinned int
-		var title, createdAt, rawURL, description, favicon, thumbnail sql.NullString
-		if err := rows.Scan(&id, &title, &createdAt, &rawURL, &description, &favicon, &thumbnail, &isPinned); err != nil {
-			return nil, err
+		var b models.Bookmark
+		var title, createdAt, rawURL, canonicalURL, description, favicon, thumbnail sql.NullString
+		if err := rows.Scan(&b.ID, &title, &createdAt, &rawURL, &canonicalURL, &description, &b.Notes, &favicon, &thumbnail, &b.Summary, &b.IsPinned, &b.Archived, &b.PageChanged,
+			&b.ReadLater, &b.ReadAt, &b.PageSaved, &b.LinkStatus, &b.LinkError, &b.LinkCheckedAt, &b.LinkBroken,
+			&b.MetadataPending); err != nil {
+			return nil, 0, err
 		}
 
-		// Auto-generate favicon URL from domain if not stored
-		faviconURL := favicon.String
-		if faviconURL == "" && rawURL.String != "" {
-			if domain := extractDomain(rawURL.String); domain != "" {
-				faviconURL = "https://www.google.com/s2/favicons?domain=" + domain + "&sz=32"
-			}
-		}
+		b.Title, b.CreatedAt = title.String, createdAt.String
+		b.URL, b.CanonicalURL = rawURL.String, canonicalURL.String
+		b.Description, b.Thumbnail = description.String, thumbnail.String
+		b.Favicon = bookmarkFavicon(favicon.String, canonicalURL.String)
+		ids = append(ids, b.ID)
+		results = append(results, b)
+	}
 
-		tags, _ := GetItemTags(id)
-		results = append(results, map[string]interface{}{
-			"id":          id,
-			"title":       title.String,
-			"created_at":  createdAt.String,
-			"url":         rawURL.String,
-			"description": description.String,
-			"favicon":     faviconURL,
-			"thumbnail":   thumbnail.String,
-			"tags":        tags,
-			"is_pinned":   isPinned == 1,
-		})
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, 0, err
 	}
-	return results, nil
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+
+	total, err := pageTotal(from, args, page, len(results))
+	return results, total, err
 }
 
 // extractDomain parses a raw URL and returns just the host (e.g. "github.com").
@@ -563,31 +1185,67 @@ func extractDomain(rawURL string) string {
 	return u.Host
 }
 
-func GetBookmark(userID int64, id int64) (map[string]interface{}, error) {
-	var title, url, description, favicon, thumbnail sql.NullString
+// bookmarkFavicon returns the icon shown for a bookmark: an uploaded favicon
+// (from an import) if it has one, otherwise the server's favicon service for
+// the bookmark's host.
+func bookmarkFavicon(stored, rawURL string) string {
+	if strings.HasPrefix(stored, "/static/") {
+		return stored
+	}
+	if domain := extractDomain(rawURL); domain != "" {
+		return "/favicons/" + domain
+	}
+	return ""
+}
+
+// FindBookmarkByURL returns the ID and title of the user's bookmark whose
+// canonical URL or original URL is the given one, or sql.ErrNoRows
+func FindBookmarkByURL(userID int64, canonicalURL string) (int64, string, error) {
+	var id int64
+	var title sql.NullString
+	err := DB.QueryRow(`
+		SELECT i.id, i.title
+		FROM items i
+		JOIN bookmarks b ON i.id = b.item_id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND (COALESCE(NULLIF(b.canonical_url, ''), b.url) = ? OR b.url = ?)
+		ORDER BY i.created_at
+		LIMIT 1`, userID, canonicalURL, canonicalURL).Scan(&id, &title)
+	return id, title.String, err
+}
+
+// GetBookmark returns one of the user's bookmarks as GetBookmarks does
+func GetBookmark(userID int64, id int64) (*models.Bookmark, error) {
+	var title, createdAt, url, canonicalURL, description, favicon, thumbnail sql.NullString
+	b := &models.Bookmark{}
 	err := DB.QueryRow(`
-		SELECT i.title, b.url, b.description, b.favicon, b.thumbnail 
+		SELECT i.id, i.title, i.created_at, b.url, COALESCE(NULLIF(b.canonical_url, ''), b.url),
+			b.description, COALESCE(b.notes, ''), b.favicon, b.thumbnail, COALESCE(b.summary, ''), COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL,
+			s.changed_at IS NOT NULL AND NOT COALESCE(s.change_seen, 0),
+			b.read_later_at IS NOT NULL AND b.read_at IS NULL, COALESCE(b.read_at, ''),
+			EXISTS (SELECT 1 FROM page_archives pa WHERE pa.item_id = i.id),
+			COALESCE(b.link_status, 0), COALESCE(b.link_error, ''), COALESCE(b.link_checked_at, ''), `+brokenLinkSQL+`,
+			b.metadata_pending
 		FROM items i 
 		JOIN bookmarks b ON i.id = b.item_id 
-		WHERE i.id = ? AND i.user_id = ?`, id, userID).Scan(&title, &url, &description, &favicon, &thumbnail)
+		LEFT JOIN bookmark_snapshots s ON s.item_id = i.id
+		WHERE i.id = ? AND i.user_id = ? AND i.deleted_at IS NULL`, id, userID).Scan(&b.ID, &title, &createdAt, &url, &canonicalURL,
+		&description, &b.Notes, &favicon, &thumbnail, &b.Summary, &b.IsPinned, &b.Archived, &b.PageChanged, &b.ReadLater, &b.ReadAt, &b.PageSaved,
+		&b.LinkStatus, &b.LinkError, &b.LinkCheckedAt, &b.LinkBroken, &b.MetadataPending)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tags, _ := GetItemTags(id)
-	return map[string]interface{}{
-		"id":          id,
-		"title":       title.String,
-		"url":         url.String,
-		"description": description.String,
-		"favicon":     favicon.String,
-		"thumbnail":   thumbnail.String,
-		"tags":        tags,
-	}, nil
+	b.Title, b.CreatedAt = title.String, createdAt.String
+	b.URL, b.CanonicalURL = url.String, canonicalURL.String
+	b.Description, b.Thumbnail = description.String, thumbnail.String
+	b.Favicon = bookmarkFavicon(favicon.String, canonicalURL.String)
+	b.Tags, _ = GetItemTags(id)
+	return b, nil
 }
 
-func UpdateBookmark(userID int64, id int64, title, url, description string) error {
+// UpdateBookmark changes a bookmark; canonicalURL is as for CreateBookmark
+func UpdateBookmark(userID int64, id int64, title, url, canonicalURL, description, notes string) error {
 	tx, err := DB.Begin()
 	if err != nil {
 		return err
@@ -599,7 +1257,15 @@ func UpdateBookmark(userID int64, id int64, title, url, description string) erro
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE bookmarks SET url = ?, description = ? WHERE item_id = ?", url, description, id)
+	// A new address clears the result of the last link check and the text
+	// of the old page
+	_, err = tx.Exec(`
+		UPDATE bookmarks SET url = ?, canonical_url = ?, description = ?, notes = NULLIF(?, ''),
+			link_status = CASE WHEN url = ? THEN link_status END,
+			link_error = CASE WHEN url = ? THEN link_error END,
+			link_checked_at = CASE WHEN url = ? THEN link_checked_at END,
+			article_text = CASE WHEN url = ? THEN article_text END
+		WHERE item_id = ?`, url, canonicalURL, description, notes, url, url, url, url, id)
 	if err != nil {
 		return err
 	}
@@ -607,6 +1273,45 @@ func UpdateBookmark(userID int64, id int64, title, url, description string) erro
 	return tx.Commit()
 }
 
+// SetBookmarkNotes stores the user's notes on a bookmark
+func SetBookmarkNotes(itemID int64, notes string) error {
+	_, err := DB.Exec("UPDATE bookmarks SET notes = NULLIF(?, '') WHERE item_id = ?", notes, itemID)
+	return err
+}
+
+// SetBookmarkArticleText stores the readable text of a bookmark's page,
+// which full-text search matches
+func SetBookmarkArticleText(itemID int64, text string) error {
+	_, err := DB.Exec("UPDATE bookmarks SET article_text = NULLIF(?, '') WHERE item_id = ?", text, itemID)
+	return err
+}
+
+// GetBookmarkTexts returns the text of the user's bookmarked pages by
+// bookmark id, for searching without the full-text index: the archived copy
+// of a page if there is one, else its text from when it was saved
+func GetBookmarkTexts(userID int64) (map[int64]string, error) {
+	rows, err := DB.Query(`
+		SELECT b.item_id, COALESCE(pa.text, b.article_text) FROM bookmarks b
+		JOIN items i ON i.id = b.item_id
+		LEFT JOIN page_archives pa ON pa.item_id = b.item_id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND COALESCE(pa.text, b.article_text) IS NOT NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	texts := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			return nil, err
+		}
+		texts[id] = text
+	}
+	return texts, rows.Err()
+}
+
 // Notes
 
 func CreateNote(userID int64, title, content string) (int64, error) {
@@ -634,68 +1339,79 @@ func CreateNote(userID int64, title, content string) (int64, error) {
 	return itemID, nil
 }
 
-func GetNotes(userID int64, tagFilter string) ([]map[string]interface{}, error) {
-	query := `
-		SELECT i.id, i.title, i.created_at, n.content, COALESCE(i.is_pinned, 0)
+func GetNotes(userID int64, tagFilter string) ([]models.Note, error) {
+	notes, _, err := GetNotesPage(userID, tagFilter, AnyArchived, Page{})
+	return notes, err
+}
+
+// GetNotesPage returns one page of what GetNotes returns, of the items archived
+// picks, and the number of rows on all pages
+func GetNotesPage(userID int64, tagFilter string, archived Archived, page Page) ([]models.Note, int, error) {
+	from := `
 		FROM items i 
 		JOIN notes n ON i.id = n.item_id 
-		WHERE i.user_id = ?`
+		WHERE i.user_id = ? AND i.type = 'note' AND i.deleted_at IS NULL`
 	args := []interface{}{userID}
 
 	if tagFilter != "" {
-		query += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
+		from += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
 		args = append(args, tagFilter)
 	}
 
-	query += " ORDER BY i.created_at DESC"
+	from += archived.sql()
+
+	limit, limitArgs := page.sql()
+	query := `
+		SELECT i.id, i.title, i.created_at, COALESCE(i.updated_at, i.created_at), n.content, COALESCE(n.summary, ''), COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL` + from + page.Sort.sql() + limit
 
-	rows, err := DB.Query(query, args...)
+	rows, err := DB.Query(query, append(args, limitArgs...)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.Note
+	var ids []int64
 	for rows.Next() {
-		var id int64
-		var isPinned int
-		var title, createdAt, content sql.NullString
-		if err := rows.Scan(&id, &title, &createdAt, &content, &isPinned); err != nil {
-			return nil, err
+		var n models.Note
+		var title, createdAt, updatedAt, content sql.NullString
+		if err := rows.Scan(&n.ID, &title, &createdAt, &updatedAt, &content, &n.Summary, &n.IsPinned, &n.Archived); err != nil {
+			return nil, 0, err
 		}
 
-		tags, _ := GetItemTags(id)
-		results = append(results, map[string]interface{}{
-			"id":         id,
-			"title":      title.String,
-			"created_at": createdAt.String,
-			"content":    content.String,
-			"tags":       tags,
-			"is_pinned":  isPinned == 1,
-		})
+		n.Title, n.CreatedAt, n.UpdatedAt, n.Content = title.String, createdAt.String, updatedAt.String, content.String
+		ids = append(ids, n.ID)
+		results = append(results, n)
 	}
-	return results, nil
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+
+	total, err := pageTotal(from, args, page, len(results))
+	return results, total, err
 }
 
-func GetNote(userID int64, id int64) (map[string]interface{}, error) {
-	var title, content sql.NullString
+func GetNote(userID int64, id int64) (*models.Note, error) {
+	var title, createdAt, updatedAt, content sql.NullString
+	n := &models.Note{}
 	err := DB.QueryRow(`
-		SELECT i.title, n.content 
+		SELECT i.id, i.title, i.created_at, COALESCE(i.updated_at, i.created_at), n.content, COALESCE(n.summary, ''), COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL
 		FROM items i 
 		JOIN notes n ON i.id = n.item_id 
-		WHERE i.id = ? AND i.user_id = ?`, id, userID).Scan(&title, &content)
+		WHERE i.id = ? AND i.user_id = ? AND i.type = 'note' AND i.deleted_at IS NULL`, id, userID).Scan(&n.ID, &title, &createdAt, &updatedAt, &content, &n.Summary, &n.IsPinned, &n.Archived)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tags, _ := GetItemTags(id)
-	return map[string]interface{}{
-		"id":      id,
-		"title":   title.String,
-		"content": content.String,
-		"tags":    tags,
-	}, nil
+	n.Title, n.CreatedAt, n.UpdatedAt, n.Content = title.String, createdAt.String, updatedAt.String, content.String
+	n.Tags, _ = GetItemTags(id)
+	return n, nil
 }
 
 func UpdateNote(userID int64, id int64, title, content string) error {
@@ -718,6 +1434,22 @@ func UpdateNote(userID int64, id int64, title, content string) error {
 	return tx.Commit()
 }
 
+// SetItemSummary stores the summary of one of the user's bookmarks or notes.
+// It returns sql.ErrNoRows for other items.
+func SetItemSummary(userID, id int64, summary string) error {
+	for _, table := range []string{"bookmarks", "notes"} {
+		res, err := DB.Exec("UPDATE "+table+" SET summary = ? WHERE item_id = ? AND item_id IN (SELECT id FROM items WHERE user_id = ?)",
+			summary, id, userID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
 // Rated Lists
 func CreateRatedList(userID int64, title string) (int64, error) {
 	result, err := DB.Exec("INSERT INTO items (user_id, title, type) VALUES (?, ?, ?)", userID, title, "rated_list")
@@ -727,59 +1459,72 @@ func CreateRatedList(userID int64, title string) (int64, error) {
 	return result.LastInsertId()
 }
 
-func GetRatedList(userID int64, id int64) (map[string]interface{}, error) {
+func GetRatedList(userID int64, id int64) (*models.RatedList, error) {
 	var title, createdAt sql.NullString
-	err := DB.QueryRow("SELECT title, created_at FROM items WHERE id = ? AND user_id = ? AND type = 'rated_list'", id, userID).Scan(&title, &createdAt)
+	l := &models.RatedList{}
+	err := DB.QueryRow("SELECT id, title, created_at, COALESCE(is_pinned, 0), archived_at IS NOT NULL FROM items WHERE id = ? AND user_id = ? AND type = 'rated_list' AND deleted_at IS NULL",
+		id, userID).Scan(&l.ID, &title, &createdAt, &l.IsPinned, &l.Archived)
 	if err != nil {
 		return nil, err
 	}
-	tags, _ := GetItemTags(id)
-	return map[string]interface{}{
-		"id":         id,
-		"title":      title.String,
-		"created_at": createdAt.String,
-		"tags":       tags,
-	}, nil
+	l.Title, l.CreatedAt = title.String, createdAt.String
+	l.Tags, _ = GetItemTags(id)
+	return l, nil
 }
 
-func GetRatedLists(userID int64, tagFilter string) ([]map[string]interface{}, error) {
-	query := `
-		SELECT i.id, i.title, i.created_at, COALESCE(i.is_pinned, 0)
+func GetRatedLists(userID int64, tagFilter string) ([]models.RatedList, error) {
+	lists, _, err := GetRatedListsPage(userID, tagFilter, AnyArchived, Page{})
+	return lists, err
+}
+
+// GetRatedListsPage returns one page of what GetRatedLists returns, of the items archived
+// picks, and the number of rows on all pages
+func GetRatedListsPage(userID int64, tagFilter string, archived Archived, page Page) ([]models.RatedList, int, error) {
+	from := `
 		FROM items i 
-		WHERE i.type = 'rated_list' AND i.user_id = ?`
+		WHERE i.type = 'rated_list' AND i.user_id = ? AND i.deleted_at IS NULL`
 	args := []interface{}{userID}
 
 	if tagFilter != "" {
-		query += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
+		from += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
 		args = append(args, tagFilter)
 	}
 
-	query += " ORDER BY i.created_at DESC"
+	from += archived.sql()
 
-	rows, err := DB.Query(query, args...)
+	limit, limitArgs := page.sql()
+	query := `
+		SELECT i.id, i.title, i.created_at, COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL` + from + page.Sort.sql() + limit
+
+	rows, err := DB.Query(query, append(args, limitArgs...)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.RatedList
+	var ids []int64
 	for rows.Next() {
-		var id int64
-		var isPinned int
+		var l models.RatedList
 		var title, createdAt sql.NullString
-		if err := rows.Scan(&id, &title, &createdAt, &isPinned); err != nil {
-			return nil, err
+		if err := rows.Scan(&l.ID, &title, &createdAt, &l.IsPinned, &l.Archived); err != nil {
+			return nil, 0, err
 		}
-		tags, _ := GetItemTags(id)
-		results = append(results, map[string]interface{}{
-			"id":         id,
-			"title":      title.String,
-			"created_at": createdAt.String,
-			"tags":       tags,
-			"is_pinned":  isPinned == 1,
-		})
+		l.Title, l.CreatedAt = title.String, createdAt.String
+		ids = append(ids, l.ID)
+		results = append(results, l)
 	}
-	return results, nil
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+
+	total, err := pageTotal(from, args, page, len(results))
+	return results, total, err
 }
 
 func AddRatedListItem(listID int64, title string, score int, note string) (int64, error) {
@@ -791,47 +1536,35 @@ func AddRatedListItem(listID int64, title string, score int, note string) (int64
 	return result.LastInsertId()
 }
 
-func GetRatedListItems(listID int64) ([]map[string]interface{}, error) {
+func GetRatedListItems(listID int64) ([]models.RatedListItem, error) {
 	rows, err := DB.Query("SELECT id, title, score, note, image_path FROM rated_list_items WHERE rated_list_id = ? ORDER BY score DESC, title ASC", listID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.RatedListItem
 	for rows.Next() {
-		var id int64
-		var title string
+		var item models.RatedListItem
 		var note, imagePath sql.NullString
-		var score int
-		if err := rows.Scan(&id, &title, &score, &note, &imagePath); err != nil {
+		if err := rows.Scan(&item.ID, &item.Title, &item.Score, &note, &imagePath); err != nil {
 			return nil, err
 		}
-		results = append(results, map[string]interface{}{
-			"id":         id,
-			"title":      title,
-			"score":      score,
-			"note":       note.String,
-			"image_path": imagePath.String,
-		})
+		item.Note, item.ImagePath = note.String, imagePath.String
+		results = append(results, item)
 	}
 	return results, nil
 }
 
-func GetRatedListItem(id int64) (map[string]interface{}, error) {
+func GetRatedListItem(id int64) (*models.RatedListItem, error) {
 	var title, note, imagePath sql.NullString
-	var score int
-	err := DB.QueryRow("SELECT title, score, note, image_path FROM rated_list_items WHERE id = ?", id).Scan(&title, &score, &note, &imagePath)
+	item := &models.RatedListItem{ID: id}
+	err := DB.QueryRow("SELECT title, score, note, image_path FROM rated_list_items WHERE id = ?", id).Scan(&title, &item.Score, &note, &imagePath)
 	if err != nil {
 		return nil, err
 	}
-	return map[string]interface{}{
-		"id":         id,
-		"title":      title.String,
-		"score":      score,
-		"note":       note.String,
-		"image_path": imagePath.String,
-	}, nil
+	item.Title, item.Note, item.ImagePath = title.String, note.String, imagePath.String
+	return item, nil
 }
 
 func UpdateRatedListItem(id int64, title string, score int, note string) error {
@@ -854,86 +1587,137 @@ func CreateList(userID int64, title string) (int64, error) {
 	return result.LastInsertId()
 }
 
-func GetLists(userID int64, tagFilter string) ([]map[string]interface{}, error) {
-	query := `
-		SELECT i.id, i.title, i.created_at, COALESCE(i.is_pinned, 0)
+func GetLists(userID int64, tagFilter string) ([]models.List, error) {
+	lists, _, err := GetListsPage(userID, tagFilter, AnyArchived, Page{})
+	return lists, err
+}
+
+// GetListsPage returns one page of what GetLists returns, of the items archived
+// picks, and the number of rows on all pages
+func GetListsPage(userID int64, tagFilter string, archived Archived, page Page) ([]models.List, int, error) {
+	from := `
 		FROM items i 
-		WHERE i.type = 'list' AND i.user_id = ?`
+		WHERE i.type = 'list' AND i.user_id = ? AND i.deleted_at IS NULL`
 	args := []interface{}{userID}
 
 	if tagFilter != "" {
-		query += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
+		from += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
 		args = append(args, tagFilter)
 	}
 
-	query += " ORDER BY i.created_at DESC"
+	from += archived.sql()
+
+	limit, limitArgs := page.sql()
+	query := `
+		SELECT i.id, i.title, i.created_at, COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL` + from + page.Sort.sql() + limit
 
-	rows, err := DB.Query(query, args...)
+	rows, err := DB.Query(query, append(args, limitArgs...)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.List
+	var ids []int64
 	for rows.Next() {
-		var id int64
-		var isPinned int
+		var l models.List
 		var title, createdAt sql.NullString
-		if err := rows.Scan(&id, &title, &createdAt, &isPinned); err != nil {
-			return nil, err
+		if err := rows.Scan(&l.ID, &title, &createdAt, &l.IsPinned, &l.Archived); err != nil {
+			return nil, 0, err
 		}
 
-		tags, _ := GetItemTags(id)
-		results = append(results, map[string]interface{}{
-			"id":         id,
-			"title":      title.String,
-			"created_at": createdAt.String,
-			"tags":       tags,
-			"is_pinned":  isPinned == 1,
-		})
+		l.Title, l.CreatedAt = title.String, createdAt.String
+		ids = append(ids, l.ID)
+		results = append(results, l)
 	}
-	return results, nil
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+
+	total, err := pageTotal(from, args, page, len(results))
+	return results, total, err
 }
 
-func AddListItem(listID int64, content string) error {
-	_, err := DB.Exec("INSERT INTO list_items (list_id, content) VALUES (?, ?)", listID, content)
-	return err
+func GetList(userID int64, id int64) (*models.List, error) {
+	var title, createdAt sql.NullString
+	l := &models.List{}
+	err := DB.QueryRow("SELECT id, title, created_at, COALESCE(is_pinned, 0), archived_at IS NOT NULL FROM items WHERE id = ? AND user_id = ? AND type = 'list' AND deleted_at IS NULL",
+		id, userID).Scan(&l.ID, &title, &createdAt, &l.IsPinned, &l.Archived)
+	if err != nil {
+		return nil, err
+	}
+	l.Title, l.CreatedAt = title.String, createdAt.String
+	l.Tags, _ = GetItemTags(id)
+	return l, nil
+}
+
+func AddListItem(listID int64, content string) (int64, error) {
+	result, err := DB.Exec("INSERT INTO list_items (list_id, content) VALUES (?, ?)", listID, content)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetListItem returns an item of the given list, or sql.ErrNoRows if the item belongs to another list.
+func GetListItem(listID, itemID int64) (*models.ListItem, error) {
+	var content sql.NullString
+	item := &models.ListItem{ID: itemID}
+	err := DB.QueryRow("SELECT content, completed, "+listItemAmount+", COALESCE(due_date, '') FROM list_items WHERE id = ? AND list_id = ?", itemID, listID).
+		Scan(&content, &item.Completed, &item.Quantity, &item.Unit, &item.Price, &item.DueDate)
+	if err != nil {
+		return nil, err
+	}
+	item.Content = content.String
+	return item, nil
+}
+
+// FindListItemByContent looks up an item of a list by its text, ignoring case and surrounding whitespace.
+func FindListItemByContent(listID int64, content string) (*models.ListItem, error) {
+	var id int64
+	err := DB.QueryRow("SELECT id FROM list_items WHERE list_id = ? AND LOWER(TRIM(content)) = LOWER(TRIM(?)) ORDER BY completed ASC, id ASC LIMIT 1",
+		listID, content).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return GetListItem(listID, id)
 }
 
-func GetListItems(listID int64) ([]map[string]interface{}, error) {
-	rows, err := DB.Query("SELECT id, content, completed FROM list_items WHERE list_id = ? ORDER BY completed ASC, id ASC", listID)
+func GetListItems(listID int64) ([]models.ListItem, error) {
+	rows, err := DB.Query("SELECT id, content, completed, "+listItemAmount+", COALESCE(due_date, '') FROM list_items WHERE list_id = ? ORDER BY completed ASC, id ASC", listID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.ListItem
 	for rows.Next() {
-		var id int64
+		var item models.ListItem
 		var content sql.NullString
-		var completed bool
-		if err := rows.Scan(&id, &content, &completed); err != nil {
+		if err := rows.Scan(&item.ID, &content, &item.Completed, &item.Quantity, &item.Unit, &item.Price, &item.DueDate); err != nil {
 			return nil, err
 		}
-		results = append(results, map[string]interface{}{
-			"id":        id,
-			"content":   content.String,
-			"completed": completed,
-		})
+		item.Content = content.String
+		results = append(results, item)
 	}
 	return results, nil
 }
 
-func GetListItemById(id int64) (map[string]interface{}, error) {
+func GetListItemById(id int64) (*models.ListItem, error) {
 	var content sql.NullString
-	err := DB.QueryRow("SELECT content FROM list_items WHERE id = ?", id).Scan(&content)
+	item := &models.ListItem{ID: id}
+	err := DB.QueryRow("SELECT content, completed, "+listItemAmount+", COALESCE(due_date, '') FROM list_items WHERE id = ?", id).
+		Scan(&content, &item.Completed, &item.Quantity, &item.Unit, &item.Price, &item.DueDate)
 	if err != nil {
 		return nil, err
 	}
-	return map[string]interface{}{
-		"id":      id,
-		"content": content.String,
-	}, nil
+	item.Content = content.String
+	return item, nil
 }
 
 func UpdateListItem(id int64, content string) error {
@@ -941,6 +1725,26 @@ func UpdateListItem(id int64, content string) error {
 	return err
 }
 
+// listItemAmount selects the quantity, unit and price of a list item, with
+// those not set as zero values
+const listItemAmount = "COALESCE(quantity, 0), COALESCE(unit, ''), COALESCE(price, 0)"
+
+// SetListItemDueDate sets the day a list item is due, as YYYY-MM-DD; ""
+// clears it. A changed date is notified about again.
+func SetListItemDueDate(id int64, dueDate string) error {
+	_, err := DB.Exec(`UPDATE list_items SET due_date = NULLIF(?, ''),
+		due_notified_at = CASE WHEN COALESCE(due_date, '') = ? THEN due_notified_at END WHERE id = ?`, dueDate, dueDate, id)
+	return err
+}
+
+// SetListItemAmount sets how much of a list item is needed and its estimated
+// price. Zero values clear them.
+func SetListItemAmount(id int64, quantity float64, unit string, price float64) error {
+	_, err := DB.Exec("UPDATE list_items SET quantity = NULLIF(?, 0), unit = NULLIF(?, ''), price = NULLIF(?, 0) WHERE id = ?",
+		quantity, unit, price, id)
+	return err
+}
+
 func ToggleListItem(itemID int64, completed bool) error {
 	_, err := DB.Exec("UPDATE list_items SET completed = ? WHERE id = ?", completed, itemID)
 	return err
@@ -974,74 +1778,86 @@ func CreateMedia(userID int64, title, filePath, mimeType string) (int64, error)
 	return itemID, err
 }
 
-func GetMedia(userID int64, tagFilter string) ([]map[string]interface{}, error) {
-	query := `
-		SELECT i.id, i.title, i.created_at, m.file_path, m.mime_type, COALESCE(i.is_pinned, 0)
+func GetMedia(userID int64, tagFilter string) ([]models.Media, error) {
+	media, _, err := GetMediaPage(userID, tagFilter, "", AnyArchived, Page{})
+	return media, err
+}
+
+// GetMediaPage returns one page of what GetMedia returns, of the items archived
+// picks, and the number of rows on all pages. A kind other than "" only returns media of that kind.
+func GetMediaPage(userID int64, tagFilter, kind string, archived Archived, page Page) ([]models.Media, int, error) {
+	from := `
 		FROM items i
 		JOIN media m ON i.id = m.item_id 
-		WHERE i.user_id = ?`
+		WHERE i.user_id = ? AND i.deleted_at IS NULL`
 
 	args := []interface{}{userID}
 	if tagFilter != "" {
-		query += ` AND i.id IN (SELECT item_id FROM item_tags it ON i.id = it.item_id JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)`
+		from += ` AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)`
 		args = append(args, tagFilter)
 	}
+	if kind != "" {
+		from += ` AND m.kind = ?`
+		args = append(args, kind)
+	}
+
+	from += archived.sql()
 
-	query += ` ORDER BY i.created_at DESC`
+	limit, limitArgs := page.sql()
+	query := `
+		SELECT i.id, i.title, i.created_at, m.file_path, m.mime_type, COALESCE(m.kind, ''), COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL` + from + page.Sort.sql() + limit
 
-	rows, err := DB.Query(query, args...)
+	rows, err := DB.Query(query, append(args, limitArgs...)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.Media
+	var ids []int64
 	for rows.Next() {
-		var id int64
-		var isPinned int
+		var m models.Media
 		var title, createdAt, filePath, mimeType sql.NullString
-		if err := rows.Scan(&id, &title, &createdAt, &filePath, &mimeType, &isPinned); err != nil {
-			return nil, err
+		if err := rows.Scan(&m.ID, &title, &createdAt, &filePath, &mimeType, &m.Kind, &m.IsPinned, &m.Archived); err != nil {
+			return nil, 0, err
 		}
-		tags, _ := GetItemTags(id)
-		results = append(results, map[string]interface{}{
-			"id":         id,
-			"title":      title.String,
-			"created_at": createdAt.String,
-			"file_path":  filePath.String,
-			"mime_type":  mimeType.String,
-			"tags":       tags,
-			"is_pinned":  isPinned == 1,
-		})
+		m.Title, m.CreatedAt, m.FilePath, m.MimeType = title.String, createdAt.String, filePath.String, mimeType.String
+		ids = append(ids, m.ID)
+		results = append(results, m)
 	}
-	return results, nil
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+
+	total, err := pageTotal(from, args, page, len(results))
+	return results, total, err
 }
 
-func GetMediaItem(id int64, userID int64) (map[string]interface{}, error) {
+func GetMediaItem(id int64, userID int64) (*models.Media, error) {
 	query := `
-		SELECT i.id, i.title, i.created_at, m.file_path, m.mime_type
+		SELECT i.id, i.title, i.created_at, m.file_path, m.mime_type, COALESCE(m.kind, ''), COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL
 		FROM items i
 		JOIN media m ON i.id = m.item_id 
-		WHERE i.id = ? AND i.user_id = ?`
+		WHERE i.id = ? AND i.user_id = ? AND i.deleted_at IS NULL`
 
 	var title, createdAt, filePath, mimeType sql.NullString
-	err := DB.QueryRow(query, id, userID).Scan(&id, &title, &createdAt, &filePath, &mimeType)
+	m := &models.Media{}
+	err := DB.QueryRow(query, id, userID).Scan(&m.ID, &title, &createdAt, &filePath, &mimeType, &m.Kind, &m.IsPinned, &m.Archived)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("media not found")
 		}
 		return nil, err
 	}
-	
-	tags, _ := GetItemTags(id)
-	return map[string]interface{}{
-		"id":         id,
-		"title":      title.String,
-		"created_at": createdAt.String,
-		"file_path":  filePath.String,
-		"mime_type":  mimeType.String,
-		"tags":       tags,
-	}, nil
+
+	m.Title, m.CreatedAt, m.FilePath, m.MimeType = title.String, createdAt.String, filePath.String, mimeType.String
+	m.Tags, _ = GetItemTags(id)
+	return m, nil
 }
 
 func UpdateMediaItem(id int64, userID int64, title string) error {
@@ -1055,6 +1871,13 @@ func DeleteItem(userID int64, id int64) error {
 	return err
 }
 
+// SetItemCreatedAt changes when an item counts as created, e.g. to when an
+// imported item was first saved elsewhere
+func SetItemCreatedAt(id int64, createdAt time.Time) error {
+	_, err := DB.Exec("UPDATE items SET created_at = ? WHERE id = ?", createdAt.UTC().Format(time.DateTime), id)
+	return err
+}
+
 func DeleteListItem(id int64) error {
 	_, err := DB.Exec("DELETE FROM list_items WHERE id = ?", id)
 	return err
@@ -1067,8 +1890,8 @@ func DeleteRatedListItem(id int64) error {
 
 // Tags
 type TagCount struct {
-	Name  string
-	Count int
+	Name  string `json:"name"`
+	Count int    `json:"count"`
 }
 
 func GetTagsWithCounts(userID int64) ([]TagCount, error) {
@@ -1077,7 +1900,7 @@ func GetTagsWithCounts(userID int64) ([]TagCount, error) {
 		FROM tags t
 		JOIN item_tags it ON t.id = it.tag_id
 		JOIN items i ON it.item_id = i.id
-		WHERE i.user_id = ?
+		WHERE i.user_id = ? AND i.deleted_at IS NULL
 		GROUP BY t.name
 		ORDER BY count DESC`, userID)
 	if err != nil {
@@ -1117,6 +1940,50 @@ func GetItemTags(itemID int64) ([]string, error) {
 	return tags, nil
 }
 
+// tagBatchSize is how many item ids GetTagsForItems puts in one query,
+// well below SQLite's limit on query parameters
+const tagBatchSize = 500
+
+// GetTagsForItems returns the tags of each of the items, keyed by item id,
+// in a query per tagBatchSize items rather than one per item. Items without
+// tags are missing from the map.
+func GetTagsForItems(ids []int64) (map[int64][]string, error) {
+	tags := make(map[int64][]string)
+	for start := 0; start < len(ids); start += tagBatchSize {
+		batch := ids[start:min(start+tagBatchSize, len(ids))]
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		// Ordered like GetItemTags, which reads them off the primary key
+		rows, err := DB.Query(`
+			SELECT it.item_id, t.name
+			FROM item_tags it
+			JOIN tags t ON t.id = it.tag_id
+			WHERE it.item_id IN (?`+strings.Repeat(", ?", len(batch)-1)+`)
+			ORDER BY it.item_id, it.tag_id`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			tags[id] = append(tags[id], name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// SetItemTags replaces the tags of an item with the given ones, creating the
+// tags its owner doesn't have yet
 func SetItemTags(itemID int64, tags []string) error {
 	tx, err := DB.Begin()
 	if err != nil {
@@ -1124,6 +1991,12 @@ func SetItemTags(itemID int64, tags []string) error {
 	}
 	defer tx.Rollback()
 
+	// Tags belong to the item's owner
+	var userID int64
+	if err := tx.QueryRow("SELECT user_id FROM items WHERE id = ?", itemID).Scan(&userID); err != nil {
+		return err
+	}
+
 	// Remove existing tags
 	_, err = tx.Exec("DELETE FROM item_tags WHERE item_id = ?", itemID)
 	if err != nil {
@@ -1137,14 +2010,14 @@ func SetItemTags(itemID int64, tags []string) error {
 		}
 
 		// Ensure tag exists
-		_, err = tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tagName)
+		_, err = tx.Exec("INSERT OR IGNORE INTO tags (user_id, name) VALUES (?, ?)", userID, tagName)
 		if err != nil {
 			return err
 		}
 
 		// Get tag ID
 		var tagID int64
-		err = tx.QueryRow("SELECT id FROM tags WHERE name = ?", tagName).Scan(&tagID)
+		err = tx.QueryRow("SELECT id FROM tags WHERE user_id = ? AND name = ?", userID, tagName).Scan(&tagID)
 		if err != nil {
 			return err
 		}
@@ -1159,8 +2032,9 @@ func SetItemTags(itemID int64, tags []string) error {
 	return tx.Commit()
 }
 
-func GetAllUniqueTags() ([]string, error) {
-	rows, err := DB.Query("SELECT name FROM tags ORDER BY name ASC")
+// GetAllUniqueTags returns the names of the user's tags
+func GetAllUniqueTags(userID int64) ([]string, error) {
+	rows, err := DB.Query("SELECT name FROM tags WHERE user_id = ? ORDER BY name ASC", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -1215,78 +2089,98 @@ func CreateRecipe(userID int64, title, ingredients, instructions, notes, thumbna
 	return itemID, nil
 }
 
-func GetRecipes(userID int64, tagFilter string) ([]map[string]interface{}, error) {
-	query := `
-		SELECT i.id, i.title, i.created_at, r.ingredients, r.instructions, r.notes, r.thumbnail, r.source_url, COALESCE(i.is_pinned, 0)
+func GetRecipes(userID int64, tagFilter string) ([]models.Recipe, error) {
+	recipes, _, err := GetRecipesPage(userID, tagFilter, AnyArchived, Page{})
+	return recipes, err
+}
+
+// GetRecipesPage returns one page of what GetRecipes returns, of the items archived
+// picks, and the number of rows on all pages
+func GetRecipesPage(userID int64, tagFilter string, archived Archived, page Page) ([]models.Recipe, int, error) {
+	from := `
 		FROM items i 
 		JOIN recipes r ON i.id = r.item_id 
-		WHERE i.user_id = ?`
+		WHERE i.user_id = ? AND i.deleted_at IS NULL`
 	args := []interface{}{userID}
 
 	if tagFilter != "" {
-		query += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
+		from += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.name = ?)"
 		args = append(args, tagFilter)
 	}
 
-	query += " ORDER BY i.created_at DESC"
+	from += archived.sql()
+
+	limit, limitArgs := page.sql()
+	query := `
+		SELECT i.id, i.title, i.created_at, r.ingredients, r.instructions, r.notes, r.thumbnail, r.source_url, COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL` + from + page.Sort.sql() + limit
 
-	rows, err := DB.Query(query, args...)
+	rows, err := DB.Query(query, append(args, limitArgs...)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.Recipe
+	var ids []int64
 	for rows.Next() {
-		var id int64
-		var isPinned int
+		var rec models.Recipe
 		var title, createdAt, ingredients, instructions, notes, thumbnail, sourceURL sql.NullString
-		if err := rows.Scan(&id, &title, &createdAt, &ingredients, &instructions, &notes, &thumbnail, &sourceURL, &isPinned); err != nil {
-			return nil, err
+		if err := rows.Scan(&rec.ID, &title, &createdAt, &ingredients, &instructions, &notes, &thumbnail, &sourceURL, &rec.IsPinned, &rec.Archived); err != nil {
+			return nil, 0, err
 		}
 
-		tags, _ := GetItemTags(id)
-		results = append(results, map[string]interface{}{
-			"id":           id,
-			"title":        title.String,
-			"created_at":   createdAt.String,
-			"ingredients":  ingredients.String,
-			"instructions": instructions.String,
-			"notes":        notes.String,
-			"thumbnail":    thumbnail.String,
-			"source_url":   sourceURL.String,
-			"tags":         tags,
-			"is_pinned":    isPinned == 1,
-		})
+		rec.Title, rec.CreatedAt = title.String, createdAt.String
+		rec.Ingredients, rec.Instructions, rec.Notes = ingredients.String, instructions.String, notes.String
+		rec.Thumbnail, rec.SourceURL = thumbnail.String, sourceURL.String
+		ids = append(ids, rec.ID)
+		results = append(results, rec)
 	}
-	return results, nil
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+
+	total, err := pageTotal(from, args, page, len(results))
+	return results, total, err
 }
 
-func GetRecipe(userID int64, id int64) (map[string]interface{}, error) {
-	var title, ingredients, instructions, notes, thumbnail, sourceURL sql.NullString
+func GetRecipe(userID int64, id int64) (*models.Recipe, error) {
+	var title, createdAt, ingredients, instructions, notes, thumbnail, sourceURL sql.NullString
+	var originalLanguage, originalIngredients, originalInstructions sql.NullString
+	rec := &models.Recipe{}
 	err := DB.QueryRow(`
-		SELECT i.title, r.ingredients, r.instructions, r.notes, r.thumbnail, r.source_url 
+		SELECT i.id, i.title, i.created_at, r.ingredients, r.instructions, r.notes, r.thumbnail, r.source_url,
+			r.original_language, r.original_ingredients, r.original_instructions, COALESCE(i.is_pinned, 0), i.archived_at IS NOT NULL
 		FROM items i 
 		JOIN recipes r ON i.id = r.item_id 
-		WHERE i.id = ? AND i.user_id = ?`, id, userID).Scan(&title, &ingredients, &instructions, &notes, &thumbnail, &sourceURL)
+		WHERE i.id = ? AND i.user_id = ? AND i.deleted_at IS NULL`, id, userID).Scan(&rec.ID, &title, &createdAt, &ingredients, &instructions, &notes, &thumbnail, &sourceURL,
+		&originalLanguage, &originalIngredients, &originalInstructions, &rec.IsPinned, &rec.Archived)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tags, _ := GetItemTags(id)
-	images, _ := GetRecipeImages(id)
-	return map[string]interface{}{
-		"id":           id,
-		"title":        title.String,
-		"ingredients":  ingredients.String,
-		"instructions": instructions.String,
-		"notes":        notes.String,
-		"thumbnail":    thumbnail.String,
-		"source_url":   sourceURL.String,
-		"tags":         tags,
-		"images":       images,
-	}, nil
+	rec.Title, rec.CreatedAt = title.String, createdAt.String
+	rec.Ingredients, rec.Instructions, rec.Notes = ingredients.String, instructions.String, notes.String
+	rec.Thumbnail, rec.SourceURL = thumbnail.String, sourceURL.String
+	rec.OriginalLanguage = originalLanguage.String
+	rec.OriginalIngredients, rec.OriginalInstructions = originalIngredients.String, originalInstructions.String
+	rec.Translated = originalIngredients.Valid || originalInstructions.Valid
+	rec.Tags, _ = GetItemTags(id)
+	rec.Images, _ = GetRecipeImages(id)
+	return rec, nil
+}
+
+// SetRecipeOriginal stores the untranslated ingredients and instructions of a
+// recipe that was translated on import.
+func SetRecipeOriginal(itemID int64, language, ingredients, instructions string) error {
+	_, err := DB.Exec("UPDATE recipes SET original_language = ?, original_ingredients = ?, original_instructions = ? WHERE item_id = ?",
+		language, ingredients, instructions, itemID)
+	return err
 }
 
 func UpdateRecipe(userID int64, id int64, title, ingredients, instructions, notes, thumbnail, sourceURL string) error {
@@ -1371,6 +2265,37 @@ func GetUserByUsername(username string) (map[string]interface{}, error) {
 	}, nil
 }
 
+func GetUserByID(userID int64) (map[string]interface{}, error) {
+	var username, passwordHash string
+	err := DB.QueryRow("SELECT username, password_hash FROM users WHERE id = ?", userID).Scan(&username, &passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":            userID,
+		"username":      username,
+		"password_hash": passwordHash,
+	}, nil
+}
+
+// UpdateUserPassword stores a new password hash and signs out every other
+// session of the user, keeping only keepSessionID.
+func UpdateUserPassword(userID int64, passwordHash string, keepSessionID string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND id != ?", userID, keepSessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func CreateSession(userID int64, duration time.Duration) (string, error) {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
@@ -1628,7 +2553,7 @@ func GetRemindersForUser(userID int64) ([]Reminder, error) {
 		SELECT id, item_id, user_id, name, frequency, time_of_day, start_date, end_date, notification_type, emails, last_triggered_at, COALESCE(is_pinned, 0), created_at
 		FROM reminders
 		WHERE user_id = ?
-		ORDER BY created_at DESC
+		ORDER BY COALESCE(is_pinned, 0) DESC, created_at DESC
 	`, userID)
 	if err != nil {
 		return nil, err
@@ -1746,38 +2671,57 @@ func DeletePushSubscription(endpoint string) error {
 // ---------------------------------------------------------
 
 type SharedLink struct {
-	ID        int64     `json:"id"`
-	LinkHash  string    `json:"link_hash"`
-	ItemType  string    `json:"item_type"` // e.g., "note", "recipe", "bookmark", "list"
-	ItemID    int64     `json:"item_id"`
-	UserID    int64     `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int64      `json:"id"`
+	LinkHash  string     `json:"link_hash"`
+	ItemType  string     `json:"item_type"` // the items.type: note, recipe, bookmark, list or rated_list
+	ItemID    int64      `json:"item_id"`
+	UserID    int64      `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil for links that don't expire
+	Title     string     `json:"title,omitempty"`      // of the item, from GetSharedLinksByUser
+}
+
+// Expired reports whether the link has passed its expiry
+func (l SharedLink) Expired() bool {
+	return l.ExpiresAt != nil && !time.Now().Before(*l.ExpiresAt)
+}
+
+const sharedLinkColumns = "id, link_hash, item_type, item_id, user_id, created_at, expires_at"
+
+func scanSharedLink(row interface{ Scan(...interface{}) error }) (SharedLink, error) {
+	var link SharedLink
+	var expiresAt sql.NullTime
+	err := row.Scan(&link.ID, &link.LinkHash, &link.ItemType, &link.ItemID, &link.UserID, &link.CreatedAt, &expiresAt)
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+	return link, err
 }
 
-func CreateSharedLink(hash, itemType string, itemID, userID int64) error {
+// CreateSharedLink shares an item under hash until expiresAt, or for good
+// when it is nil
+func CreateSharedLink(hash, itemType string, itemID, userID int64, expiresAt *time.Time) error {
 	_, err := DB.Exec(`
-		INSERT INTO shared_links (link_hash, item_type, item_id, user_id) 
-		VALUES (?, ?, ?, ?)
-	`, hash, itemType, itemID, userID)
+		INSERT INTO shared_links (link_hash, item_type, item_id, user_id, expires_at) 
+		VALUES (?, ?, ?, ?, ?)
+	`, hash, itemType, itemID, userID, expiresAt)
+	return err
+}
+
+// SetSharedLinkExpiry changes when one of the user's links expires; nil
+// keeps it from expiring
+func SetSharedLinkExpiry(hash string, userID int64, expiresAt *time.Time) error {
+	_, err := DB.Exec("UPDATE shared_links SET expires_at = ? WHERE link_hash = ? AND user_id = ?", expiresAt, hash, userID)
 	return err
 }
 
 func GetSharedLinkByHash(hash string) (SharedLink, error) {
-	var link SharedLink
-	err := DB.QueryRow(`
-		SELECT id, link_hash, item_type, item_id, user_id, created_at 
-		FROM shared_links WHERE link_hash = ?
-	`, hash).Scan(&link.ID, &link.LinkHash, &link.ItemType, &link.ItemID, &link.UserID, &link.CreatedAt)
-	return link, err
+	return scanSharedLink(DB.QueryRow("SELECT "+sharedLinkColumns+" FROM shared_links WHERE link_hash = ?", hash))
 }
 
 func GetSharedLinkByItem(itemType string, itemID, userID int64) (SharedLink, error) {
-	var link SharedLink
-	err := DB.QueryRow(`
-		SELECT id, link_hash, item_type, item_id, user_id, created_at 
-		FROM shared_links WHERE item_type = ? AND item_id = ? AND user_id = ?
-	`, itemType, itemID, userID).Scan(&link.ID, &link.LinkHash, &link.ItemType, &link.ItemID, &link.UserID, &link.CreatedAt)
-	return link, err
+	return scanSharedLink(DB.QueryRow("SELECT "+sharedLinkColumns+" FROM shared_links WHERE item_type = ? AND item_id = ? AND user_id = ?",
+		itemType, itemID, userID))
 }
 
 func DeleteSharedLink(hash string, userID int64) error {
@@ -1804,19 +2748,19 @@ func SetDefaultPage(userID int64, page string) error {
 }
 
 // GetPinnedItems returns all pinned items for a user across all types.
-// Each result has: id, type, title, url (for bookmarks), thumbnail, favicon.
-func GetPinnedItems(userID int64) ([]map[string]interface{}, error) {
+func GetPinnedItems(userID int64) ([]models.PinnedItem, error) {
 	rows, err := DB.Query(`
 		SELECT i.id, i.type, i.title,
 			COALESCE(b.url, ''),
-			COALESCE(b.thumbnail, r.thumbnail, d.file_path, m.file_path, ''),
+			COALESCE(b.thumbnail, r.thumbnail, d.file_path, m.file_path, c.cover_image, ''),
 			COALESCE(b.favicon, '')
 		FROM items i
 		LEFT JOIN bookmarks b ON i.id = b.item_id
 		LEFT JOIN recipes r ON i.id = r.item_id
 		LEFT JOIN drawings d ON i.id = d.item_id
 		LEFT JOIN media m ON i.id = m.item_id
-		WHERE i.user_id = ? AND i.is_pinned = 1
+		LEFT JOIN cookbooks c ON i.id = c.item_id
+		WHERE i.user_id = ? AND i.is_pinned = 1 AND i.deleted_at IS NULL AND i.archived_at IS NULL
 		ORDER BY i.updated_at DESC
 	`, userID)
 	if err != nil {
@@ -1824,39 +2768,67 @@ func GetPinnedItems(userID int64) ([]map[string]interface{}, error) {
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var results []models.PinnedItem
+	var ids []int64
 	for rows.Next() {
-		var id int64
-		var itemType, title, rawURL, thumbnail, favicon string
-		if err := rows.Scan(&id, &itemType, &title, &rawURL, &thumbnail, &favicon); err != nil {
+		var item models.PinnedItem
+		var favicon string
+		if err := rows.Scan(&item.ID, &item.Type, &item.Title, &item.URL, &item.Thumbnail, &favicon); err != nil {
 			return nil, err
 		}
-		// Generate favicon if missing
-		if favicon == "" && rawURL != "" {
-			if domain := extractDomain(rawURL); domain != "" {
-				favicon = "https://www.google.com/s2/favicons?domain=" + domain + "&sz=32"
-			}
-		}
-		tags, _ := GetItemTags(id)
-		results = append(results, map[string]interface{}{
-			"id":        id,
-			"type":      itemType,
-			"title":     title,
-			"url":       rawURL,
-			"thumbnail": thumbnail,
-			"favicon":   favicon,
-			"tags":      tags,
-		})
+		item.Favicon = bookmarkFavicon(favicon, item.URL)
+		ids = append(ids, item.ID)
+		results = append(results, item)
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
 	}
 	return results, nil
 }
 
+// pinnedFirst starts the ORDER BY of a list query over items i, so that
+// pinned items come at the top of their section
+const pinnedFirst = "COALESCE(i.is_pinned, 0) DESC, "
+
+// PinItem pins an item owned by the user to the dashboard
+func PinItem(itemID, userID int64) error {
+	_, err := DB.Exec("UPDATE items SET is_pinned = 1 WHERE id = ? AND user_id = ?", itemID, userID)
+	return err
+}
+
+// IsItemPinned reports whether one of the user's items (not in the trash) is
+// pinned. It returns sql.ErrNoRows if there is no such item.
+func IsItemPinned(itemID, userID int64) (bool, error) {
+	var pinned bool
+	err := DB.QueryRow("SELECT COALESCE(is_pinned, 0) FROM items WHERE id = ? AND user_id = ? AND deleted_at IS NULL",
+		itemID, userID).Scan(&pinned)
+	return pinned, err
+}
+
+// SetItemPinned pins or unpins one of the user's items (not in the trash).
+// It returns sql.ErrNoRows if there is no such item.
+func SetItemPinned(itemID, userID int64, pinned bool) error {
+	res, err := DB.Exec("UPDATE items SET is_pinned = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL", pinned, itemID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // TogglePinItem flips the is_pinned flag for an item owned by the user.
 // Returns the new pinned state (true = now pinned).
 func TogglePinItem(itemID, userID int64) (bool, error) {
 	// Check items table first
 	var current int
-	err := DB.QueryRow("SELECT COALESCE(is_pinned, 0) FROM items WHERE id = ? AND user_id = ?", itemID, userID).Scan(&current)
+	err := DB.QueryRow("SELECT COALESCE(is_pinned, 0) FROM items WHERE id = ? AND user_id = ? AND deleted_at IS NULL", itemID, userID).Scan(&current)
 	if err == nil {
 		newVal := 0
 		if current == 0 {
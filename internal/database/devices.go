@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// KnownDevice is a user agent / IP combination a user has logged in from.
+type KnownDevice struct {
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"user_id"`
+	UserAgent   string `json:"user_agent"`
+	IPAddress   string `json:"ip_address"`
+	FirstSeenAt string `json:"first_seen_at"`
+	LastSeenAt  string `json:"last_seen_at"`
+}
+
+// RecordLoginDevice marks the device as seen for the user and reports whether
+// it was previously unknown. firstDevice is true when the user had no known
+// devices at all, i.e. this is their first recorded login.
+func RecordLoginDevice(userID int64, userAgent, ipAddress string) (isNew bool, firstDevice bool, err error) {
+	var id int64
+	err = DB.QueryRow("SELECT id FROM known_devices WHERE user_id = ? AND user_agent = ? AND ip_address = ?",
+		userID, userAgent, ipAddress).Scan(&id)
+	if err == nil {
+		_, err = DB.Exec("UPDATE known_devices SET last_seen_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+		return false, false, err
+	}
+	if err != sql.ErrNoRows {
+		return false, false, err
+	}
+
+	var count int
+	if err = DB.QueryRow("SELECT COUNT(*) FROM known_devices WHERE user_id = ?", userID).Scan(&count); err != nil {
+		return false, false, err
+	}
+
+	_, err = DB.Exec("INSERT INTO known_devices (user_id, user_agent, ip_address) VALUES (?, ?, ?)",
+		userID, userAgent, ipAddress)
+	if err != nil {
+		return false, false, err
+	}
+	return true, count == 0, nil
+}
+
+func GetKnownDevices(userID int64) ([]KnownDevice, error) {
+	rows, err := DB.Query(`
+		SELECT id, user_id, user_agent, ip_address, first_seen_at, last_seen_at
+		FROM known_devices
+		WHERE user_id = ?
+		ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []KnownDevice
+	for rows.Next() {
+		var d KnownDevice
+		if err := rows.Scan(&d.ID, &d.UserID, &d.UserAgent, &d.IPAddress, &d.FirstSeenAt, &d.LastSeenAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// DeleteKnownDevice forgets a device so the next login from it alerts again.
+func DeleteKnownDevice(userID int64, id int64) error {
+	_, err := DB.Exec("DELETE FROM known_devices WHERE id = ? AND user_id = ?", id, userID)
+	return err
+}
+
+// GetLoginAlertEmail returns the address new-device alerts are sent to, or "" if disabled.
+func GetLoginAlertEmail(userID int64) string {
+	var email sql.NullString
+	if err := DB.QueryRow("SELECT login_alert_email FROM users WHERE id = ?", userID).Scan(&email); err != nil {
+		return ""
+	}
+	return email.String
+}
+
+func SetLoginAlertEmail(userID int64, email string) error {
+	_, err := DB.Exec("UPDATE users SET login_alert_email = ? WHERE id = ?", email, userID)
+	return err
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"time"
+
+	"infokeep/internal/models"
+)
+
+// The digest email sums up what a user added in a day or a week and what
+// waits in their read-later queue.
+
+// GetItemsAddedSince returns up to limit of the items the user added since
+// since (not in the trash), newest first, and how many they added in all
+func GetItemsAddedSince(userID int64, since time.Time, limit int) ([]models.FeedItem, int, error) {
+	where := `FROM items i LEFT JOIN bookmarks b ON i.id = b.item_id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND i.type IN (` + recentTypes + `) AND i.created_at >= ?`
+	after := since.UTC().Format(time.DateTime)
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) "+where, userID, after).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	items, err := queryDigestItems("SELECT i.id, i.type, i.title, COALESCE(b.url, ''), i.created_at "+where+
+		" ORDER BY i.created_at DESC, i.id DESC LIMIT ?", userID, after, limit)
+	return items, total, err
+}
+
+// GetReadLaterQueue returns up to limit of the bookmarks in the user's
+// read-later queue, longest waiting first, and how many there are in all
+func GetReadLaterQueue(userID int64, limit int) ([]models.FeedItem, int, error) {
+	where := `FROM items i JOIN bookmarks b ON i.id = b.item_id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND b.read_later_at IS NOT NULL AND b.read_at IS NULL`
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) "+where, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	items, err := queryDigestItems("SELECT i.id, i.type, i.title, b.url, b.read_later_at "+where+
+		" ORDER BY b.read_later_at, i.id LIMIT ?", userID, limit)
+	return items, total, err
+}
+
+func queryDigestItems(query string, args ...interface{}) ([]models.FeedItem, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []models.FeedItem
+	for rows.Next() {
+		var item models.FeedItem
+		if err := rows.Scan(&item.ID, &item.Type, &item.Title, &item.URL, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
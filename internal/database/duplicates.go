@@ -0,0 +1,155 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Uploaded images are fingerprinted to find duplicates: media.content_hash
+// is the SHA-256 of the file, the same for byte-identical uploads, and
+// media.image_hash the perceptual hash of the picture (see
+// internal/imagehash), close for re-saves and resizes. Both are hex; the
+// image hash is empty for files that are not images it can read, and both
+// are empty for files that could not be read. Both are NULL until the media
+// is fingerprinted, which also sets media.kind (see internal/mediakind).
+
+// MediaFile is a media item whose file is to be fingerprinted
+type MediaFile struct {
+	ID       int64
+	UserID   int64
+	Title    string
+	FilePath string
+}
+
+// GetMediaWithoutHashes returns up to limit media items, of any user, that
+// have not been fingerprinted
+func GetMediaWithoutHashes(limit int) ([]MediaFile, error) {
+	rows, err := DB.Query(`
+		SELECT m.item_id, i.user_id, COALESCE(i.title, ''), m.file_path
+		FROM media m
+		JOIN items i ON i.id = m.item_id
+		WHERE m.content_hash IS NULL AND i.deleted_at IS NULL
+		ORDER BY m.item_id
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []MediaFile
+	for rows.Next() {
+		var f MediaFile
+		if err := rows.Scan(&f.ID, &f.UserID, &f.Title, &f.FilePath); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// SetMediaFingerprints stores the fingerprints and kind of a media item's
+// file
+func SetMediaFingerprints(itemID int64, contentHash, imageHash, kind string) error {
+	_, err := DB.Exec("UPDATE media SET content_hash = ?, image_hash = ?, kind = ? WHERE item_id = ?",
+		contentHash, imageHash, kind, itemID)
+	return err
+}
+
+// MediaFingerprint is a fingerprinted media item
+type MediaFingerprint struct {
+	ID          int64
+	Title       string
+	FilePath    string
+	CreatedAt   string
+	ContentHash string
+	ImageHash   string
+	Tags        []string
+}
+
+// GetMediaFingerprints returns the user's media items whose files were
+// fingerprinted, oldest first
+func GetMediaFingerprints(userID int64) ([]MediaFingerprint, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.title, m.file_path, i.created_at, m.content_hash, COALESCE(m.image_hash, '')
+		FROM items i
+		JOIN media m ON m.item_id = i.id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND m.content_hash <> ''
+		ORDER BY i.created_at, i.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var media []MediaFingerprint
+	var ids []int64
+	for rows.Next() {
+		var m MediaFingerprint
+		var title, createdAt sql.NullString
+		if err := rows.Scan(&m.ID, &title, &m.FilePath, &createdAt, &m.ContentHash, &m.ImageHash); err != nil {
+			return nil, err
+		}
+		m.Title, m.CreatedAt = title.String, createdAt.String
+		media = append(media, m)
+		ids = append(ids, m.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range media {
+		media[i].Tags = tags[media[i].ID]
+	}
+	return media, nil
+}
+
+// MergeMedia keeps one of the user's media items and moves the others to the
+// trash, giving the one kept their tags, and pinning it if one of them was.
+// It returns sql.ErrNoRows unless all are media items of the user outside
+// the trash.
+func MergeMedia(userID, keepID int64, dropIDs []int64) error {
+	if len(dropIDs) == 0 {
+		return nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	in := "?" + strings.Repeat(", ?", len(dropIDs)-1)
+	args := []interface{}{userID, keepID}
+	for _, id := range dropIDs {
+		if id == keepID {
+			return sql.ErrNoRows
+		}
+		args = append(args, id)
+	}
+	var count int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM items
+		WHERE user_id = ? AND type = 'media' AND deleted_at IS NULL AND id IN (?, `+in+`)`, args...).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != len(dropIDs)+1 {
+		return sql.ErrNoRows
+	}
+
+	steps := []string{
+		"INSERT OR IGNORE INTO item_tags (item_id, tag_id) SELECT ?, tag_id FROM item_tags WHERE item_id IN (" + in + ")",
+		"UPDATE items SET is_pinned = 1 WHERE id = ? AND EXISTS (SELECT 1 FROM items WHERE is_pinned = 1 AND id IN (" + in + "))",
+	}
+	for _, query := range steps {
+		if _, err := tx.Exec(query, args[1:]...); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE items SET deleted_at = CURRENT_TIMESTAMP WHERE id IN ("+in+")", args[2:]...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
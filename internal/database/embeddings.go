@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Semantic search keeps an embedding vector per item in item_embeddings,
+// computed by the embedding model named in its model column (see
+// handlers/semantic_search.go). Vectors are stored as little-endian float32s.
+// A row is deleted when its item changes and the item is embedded again.
+
+// EmbeddingDocument is the text of an item to compute an embedding for
+type EmbeddingDocument struct {
+	ID   int64
+	Text string
+}
+
+// GetItemsWithoutEmbeddings returns up to limit items, of any user, that
+// have no embedding by model
+func GetItemsWithoutEmbeddings(model string, limit int) ([]EmbeddingDocument, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.title,
+			TRIM(COALESCE(n.content, '') || ' ' || COALESCE(b.description, '') || ' ' || COALESCE(b.url, '') || ' ' ||
+				COALESCE(r.ingredients, '') || ' ' || COALESCE(r.instructions, '')),
+			COALESCE((SELECT GROUP_CONCAT(t.name, ' ') FROM item_tags it JOIN tags t ON t.id = it.tag_id WHERE it.item_id = i.id), '')
+		FROM items i
+		LEFT JOIN notes n ON n.item_id = i.id
+		LEFT JOIN bookmarks b ON b.item_id = i.id
+		LEFT JOIN recipes r ON r.item_id = i.id
+		LEFT JOIN item_embeddings e ON e.item_id = i.id AND e.model = ?
+		WHERE e.item_id IS NULL AND i.deleted_at IS NULL AND i.type IN (`+searchTypes+`)
+		ORDER BY i.id
+		LIMIT ?`, model, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []EmbeddingDocument
+	for rows.Next() {
+		var doc EmbeddingDocument
+		var title, body, tags sql.NullString
+		if err := rows.Scan(&doc.ID, &title, &body, &tags); err != nil {
+			return nil, err
+		}
+		var parts []string
+		for _, s := range []string{title.String, tags.String, body.String} {
+			if s = strings.TrimSpace(s); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		doc.Text = strings.Join(parts, "\n")
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// SaveItemEmbedding stores the embedding of an item, replacing any other
+func SaveItemEmbedding(itemID int64, model string, vector []float32) error {
+	_, err := DB.Exec("INSERT OR REPLACE INTO item_embeddings (item_id, model, vector) VALUES (?, ?, ?)",
+		itemID, model, encodeVector(vector))
+	return err
+}
+
+// DeleteItemEmbedding drops the embedding of an item whose text changed
+func DeleteItemEmbedding(itemID int64) error {
+	_, err := DB.Exec("DELETE FROM item_embeddings WHERE item_id = ?", itemID)
+	return err
+}
+
+// ScoredItem is an item and the cosine similarity of its embedding to a
+// query's
+type ScoredItem struct {
+	ID         int64
+	Similarity float64
+}
+
+// NearestItems returns up to limit of the user's items whose embeddings by
+// model are at least minSimilarity similar to query, most similar first
+func NearestItems(userID int64, model string, query []float32, minSimilarity float64, limit int) ([]ScoredItem, error) {
+	rows, err := DB.Query(`
+		SELECT e.item_id, e.vector
+		FROM item_embeddings e
+		JOIN items i ON i.id = e.item_id
+		WHERE i.user_id = ? AND e.model = ? AND i.deleted_at IS NULL AND i.archived_at IS NULL AND i.type IN (`+searchTypes+`)`, userID, model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []ScoredItem
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, err
+		}
+		if s := cosineSimilarity(query, decodeVector(blob)); s >= minSimilarity {
+			found = append(found, ScoredItem{ID: id, Similarity: s})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Similarity > found[j].Similarity })
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// CountItemEmbeddings returns how many items have an embedding by model
+func CountItemEmbeddings(model string) (int, error) {
+	var n int
+	err := DB.QueryRow("SELECT COUNT(*) FROM item_embeddings WHERE model = ?", model).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("counting embeddings: %w", err)
+	}
+	return n, nil
+}
+
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+// cosineSimilarity is 0 for vectors of different lengths, such as those of
+// another model
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
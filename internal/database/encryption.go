@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// keyConnector opens connections to the database at dsn with a sqlite3
+// driver that sends the SQLCipher key as the first statement on each. It
+// is handed to sql.OpenDB rather than registered, as the key is only known
+// when the database is opened, which can happen more than once.
+type keyConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c keyConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c keyConnector) Driver() driver.Driver                        { return c.driver }
+
+// dbKey returns the database encryption key from DB_KEY, or from the file
+// named by DB_KEYFILE. An empty key means the database is not encrypted.
+func dbKey() (string, error) {
+	if key := os.Getenv("DB_KEY"); key != "" {
+		return key, nil
+	}
+	if path := os.Getenv("DB_KEYFILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read DB_KEYFILE: %w", err)
+		}
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", fmt.Errorf("DB_KEYFILE %s is empty", path)
+		}
+		return key, nil
+	}
+	return "", nil
+}
+
+// openEncrypted opens an SQLCipher database with the given passphrase. The
+// binary must be linked against SQLCipher (see `make build-sqlcipher`); with
+// plain SQLite the key pragma is silently ignored, so that case is refused
+// rather than writing the data unencrypted.
+func openEncrypted(path, key string) (*sql.DB, error) {
+	d := timedDriver{&sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if _, err := conn.Exec("PRAGMA key = '"+strings.ReplaceAll(key, "'", "''")+"'", nil); err != nil {
+				return err
+			}
+			// Not in the DSN like for plain SQLite: the driver runs DSN pragmas
+			// before this hook, and switching to WAL reads the database
+			_, err := conn.Exec("PRAGMA journal_mode = WAL", nil)
+			return err
+		},
+	}}
+	db := sql.OpenDB(keyConnector{dsn: path + connParams, driver: d})
+
+	var cipherVersion string
+	if err := db.QueryRow("PRAGMA cipher_version").Scan(&cipherVersion); err != nil || cipherVersion == "" {
+		db.Close()
+		return nil, fmt.Errorf("DB_KEY is set but this build does not include SQLCipher; rebuild with `make build-sqlcipher`")
+	}
+
+	// A wrong key (or an existing unencrypted database) only shows up on first read
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&count); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot decrypt %s, wrong key or not an encrypted database: %w", path, err)
+	}
+	return db, nil
+}
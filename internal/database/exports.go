@@ -0,0 +1,75 @@
+package database
+
+// Export is a finished data export archive, downloadable until it expires.
+type Export struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	JobID     int64  `json:"job_id"`
+	Format    string `json:"format"`
+	Token     string `json:"-"`
+	FilePath  string `json:"-"`
+	Size      int64  `json:"size"`
+	ExpiresAt string `json:"expires_at"`
+	CreatedAt string `json:"created_at"`
+}
+
+const exportColumns = "id, user_id, job_id, format, token, file_path, size, expires_at, created_at"
+
+func scanExport(row interface{ Scan(...interface{}) error }) (*Export, error) {
+	var e Export
+	if err := row.Scan(&e.ID, &e.UserID, &e.JobID, &e.Format, &e.Token, &e.FilePath, &e.Size, &e.ExpiresAt, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func queryExports(query string, args ...interface{}) ([]Export, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []Export
+	for rows.Next() {
+		e, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *e)
+	}
+	return exports, nil
+}
+
+// CreateExport records a finished export archive that expires after ttlMinutes
+func CreateExport(userID, jobID int64, format, token, filePath string, size int64, ttlMinutes int) (int64, error) {
+	result, err := DB.Exec(`
+		INSERT INTO exports (user_id, job_id, format, token, file_path, size, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, datetime('now', '+' || ? || ' minutes'))`,
+		userID, jobID, format, token, filePath, size, ttlMinutes)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetExportByToken returns an unexpired export of the user, or sql.ErrNoRows
+func GetExportByToken(userID int64, token string) (*Export, error) {
+	return scanExport(DB.QueryRow("SELECT "+exportColumns+" FROM exports WHERE token = ? AND user_id = ? AND expires_at > datetime('now')",
+		token, userID))
+}
+
+// GetExportsByUser returns the user's stored exports, oldest first
+func GetExportsByUser(userID int64) ([]Export, error) {
+	return queryExports("SELECT "+exportColumns+" FROM exports WHERE user_id = ? ORDER BY id", userID)
+}
+
+// GetExpiredExports returns exports of all users whose download link has expired
+func GetExpiredExports() ([]Export, error) {
+	return queryExports("SELECT " + exportColumns + " FROM exports WHERE expires_at <= datetime('now')")
+}
+
+func DeleteExport(id int64) error {
+	_, err := DB.Exec("DELETE FROM exports WHERE id = ?", id)
+	return err
+}
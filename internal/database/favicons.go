@@ -0,0 +1,51 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Favicon is the cached icon of a host. Data is empty when no icon was
+// found; Failures counts the lookups in a row that found nothing.
+type Favicon struct {
+	Host        string
+	ContentType string
+	Data        []byte
+	SourceURL   string
+	Failures    int
+	FetchedAt   time.Time
+}
+
+// GetFavicon returns the cached favicon of a host, or nil if it was never
+// looked up
+func GetFavicon(host string) (*Favicon, error) {
+	f := Favicon{Host: host}
+	var contentType, sourceURL sql.NullString
+	err := DB.QueryRow(`
+		SELECT content_type, data, source_url, failures, fetched_at
+		FROM favicons WHERE host = ?`, host).Scan(&contentType, &f.Data, &sourceURL, &f.Failures, &f.FetchedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.ContentType = contentType.String
+	f.SourceURL = sourceURL.String
+	return &f, nil
+}
+
+// SaveFavicon stores the result of a favicon lookup, fetched now
+func SaveFavicon(f Favicon) error {
+	_, err := DB.Exec(`
+		INSERT INTO favicons (host, content_type, data, source_url, failures, fetched_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(host) DO UPDATE SET
+			content_type = excluded.content_type,
+			data = excluded.data,
+			source_url = excluded.source_url,
+			failures = excluded.failures,
+			fetched_at = excluded.fetched_at`,
+		f.Host, f.ContentType, f.Data, f.SourceURL, f.Failures)
+	return err
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+
+	"infokeep/internal/models"
+)
+
+// Feed readers can't send the session cookie or an Authorization header, so
+// feeds are authenticated by a token in their URL. It is a token of its own
+// rather than the API token, since feed URLs end up in readers and their
+// sync services, and it only gives read access to the feeds.
+
+// GetUserByFeedToken returns the user a feed token belongs to
+func GetUserByFeedToken(token string) (int64, error) {
+	var userID int64
+	err := DB.QueryRow("SELECT id FROM users WHERE feed_token = ? AND feed_token != ''", token).Scan(&userID)
+	return userID, err
+}
+
+// GetFeedToken returns the user's feed token, creating it on first use
+func GetFeedToken(userID int64) (string, error) {
+	var token sql.NullString
+	if err := DB.QueryRow("SELECT feed_token FROM users WHERE id = ?", userID).Scan(&token); err != nil {
+		return "", err
+	}
+	if !token.Valid || token.String == "" {
+		return RegenerateFeedToken(userID)
+	}
+	return token.String, nil
+}
+
+// RegenerateFeedToken gives the user a new feed token, so the old feed URLs
+// stop working
+func RegenerateFeedToken(userID int64) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if _, err := DB.Exec("UPDATE users SET feed_token = ? WHERE id = ?", token, userID); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetFeedItems returns up to limit of the user's newest items (not in the
+// trash or archived), newest first, optionally only those of one type or
+// with one tag
+func GetFeedItems(userID int64, itemType, tag string, limit int) ([]models.FeedItem, error) {
+	query := `
+		SELECT i.id, i.type, i.title, COALESCE(b.url, ''), COALESCE(b.description, n.content, ''), i.created_at
+		FROM items i
+		LEFT JOIN bookmarks b ON i.id = b.item_id
+		LEFT JOIN notes n ON i.id = n.item_id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND i.archived_at IS NULL AND i.type IN (` + recentTypes + `)`
+	args := []interface{}{userID}
+	if itemType != "" {
+		query += " AND i.type = ?"
+		args = append(args, itemType)
+	}
+	if tag != "" {
+		query += " AND i.id IN (SELECT item_id FROM item_tags it JOIN tags t ON it.tag_id = t.id WHERE t.user_id = ? AND t.name = ?)"
+		args = append(args, userID, tag)
+	}
+	query += " ORDER BY i.created_at DESC, i.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.FeedItem
+	var ids []int64
+	for rows.Next() {
+		var item models.FeedItem
+		if err := rows.Scan(&item.ID, &item.Type, &item.Title, &item.URL, &item.Summary, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		ids = append(ids, item.ID)
+		results = append(results, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+	return results, nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Foreign keys were not enforced by older versions, so a database can hold
+// rows whose item or user is gone: a bookmark without its item, tags of
+// deleted items, images of recipes that no longer exist. Such rows are
+// invisible but take up space and can confuse exports and migrations.
+
+// orphanCheck finds the rows of table that point to nothing: those matching
+// where
+type orphanCheck struct {
+	name, table, where string
+}
+
+const (
+	missingItem = "NOT IN (SELECT id FROM items)"
+	missingUser = "NOT IN (SELECT id FROM users)"
+)
+
+var orphanChecks = []orphanCheck{
+	{"Items of deleted users", "items", "user_id IS NULL OR user_id " + missingUser},
+	{"Bookmarks without an item", "bookmarks", "item_id " + missingItem},
+	{"Notes without an item", "notes", "item_id " + missingItem},
+	{"Media without an item", "media", "item_id " + missingItem},
+	{"Drawings without an item", "drawings", "item_id " + missingItem},
+	{"Recipes without an item", "recipes", "item_id " + missingItem},
+	{"Recipe images without a recipe", "recipe_images", "recipe_id " + missingItem},
+	{"Checklist entries without a list", "list_items", "list_id " + missingItem},
+	{"Rated list entries without a list", "rated_list_items", "rated_list_id " + missingItem},
+	{"Cookbooks without an item", "cookbooks", "item_id " + missingItem},
+	{"Cookbook recipes of deleted items", "cookbook_recipes", "cookbook_id " + missingItem + " OR recipe_id " + missingItem},
+	{"Reminders without an item", "reminders", "item_id IS NOT NULL AND item_id " + missingItem},
+	{"Snippets without an item", "snippets", "item_id " + missingItem},
+	{"Tags of deleted items", "item_tags", "item_id " + missingItem + " OR tag_id NOT IN (SELECT id FROM tags)"},
+	{"Tags of deleted users", "tags", "user_id " + missingUser},
+	{"Share links of deleted items", "shared_links", "item_id " + missingItem},
+	{"Comments on deleted items", "comments", "item_id " + missingItem},
+	{"Page snapshots of deleted bookmarks", "bookmark_snapshots", "item_id " + missingItem},
+	{"Archived pages of deleted bookmarks", "page_archives", "item_id " + missingItem},
+	{"Search embeddings of deleted items", "item_embeddings", "item_id " + missingItem},
+	{"Attachments of deleted items", "attachments", "item_id " + missingItem},
+	{"Note images of deleted users", "note_uploads", "user_id " + missingUser},
+}
+
+// OrphanCount is the number of orphaned rows one check found or removed
+type OrphanCount struct {
+	Name  string `json:"name"`
+	Table string `json:"table"`
+	Count int64  `json:"count"`
+}
+
+// FindOrphans counts the orphaned rows of every check
+func FindOrphans() ([]OrphanCount, error) {
+	counts := make([]OrphanCount, len(orphanChecks))
+	for i, c := range orphanChecks {
+		counts[i] = OrphanCount{Name: c.name, Table: c.table}
+		err := DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", c.table, c.where)).Scan(&counts[i].Count)
+		if err != nil {
+			return nil, fmt.Errorf("checking %s: %w", c.table, err)
+		}
+	}
+	return counts, nil
+}
+
+// DeleteOrphans deletes the orphaned rows of every check in one
+// transaction and returns how many went. Items of deleted users go first,
+// so the rows that pointed to them are removed with the rest.
+func DeleteOrphans() ([]OrphanCount, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	counts := make([]OrphanCount, len(orphanChecks))
+	for i, c := range orphanChecks {
+		res, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", c.table, c.where))
+		if err != nil {
+			return nil, fmt.Errorf("cleaning %s: %w", c.table, err)
+		}
+		n, _ := res.RowsAffected()
+		counts[i] = OrphanCount{Name: c.name, Table: c.table, Count: n}
+	}
+	return counts, tx.Commit()
+}
+
+// fileColumns are the columns that hold paths of uploaded files
+var fileColumns = []string{
+	"SELECT file_path FROM media",
+	"SELECT file_path FROM drawings",
+	"SELECT thumbnail FROM recipes",
+	"SELECT file_path FROM recipe_images",
+	"SELECT image_path FROM rated_list_items",
+	"SELECT cover_image FROM cookbooks",
+	"SELECT favicon FROM bookmarks",
+	"SELECT thumbnail FROM bookmarks",
+	"SELECT file_path FROM attachments",
+	"SELECT file_path FROM note_uploads",
+}
+
+// GetReferencedFiles returns the file paths that any row, including those of
+// items in the trash, points to
+func GetReferencedFiles() (map[string]bool, error) {
+	rows, err := DB.Query(strings.Join(fileColumns, " UNION "))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files := map[string]bool{}
+	for rows.Next() {
+		var path sql.NullString
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		if path.String != "" {
+			files[path.String] = true
+		}
+	}
+	return files, rows.Err()
+}
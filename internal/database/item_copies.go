@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Duplicating an item copies everything that belongs to it in one
+// transaction. Uploaded files are copied too, so that purging the copy or
+// the original from the trash doesn't remove files the other still shows.
+
+// itemCopyQueries copy the rows of each item type from the item ? (second
+// argument) to its copy ? (first argument)
+var itemCopyQueries = map[string][]string{
+	"bookmark": {
+		`INSERT INTO bookmarks (item_id, url, canonical_url, description, favicon, thumbnail, summary, article_text, notes)
+		SELECT ?, url, canonical_url, description, favicon, thumbnail, summary, article_text, notes FROM bookmarks WHERE item_id = ?`,
+		`INSERT INTO page_archives (item_id, url, title, html, text, archived_at)
+		SELECT ?, url, title, html, text, archived_at FROM page_archives WHERE item_id = ?`,
+	},
+	"note": {`INSERT INTO notes (item_id, content, summary) SELECT ?, content, summary FROM notes WHERE item_id = ?`},
+	"list": {`INSERT INTO list_items (list_id, content, completed, quantity, unit, price, due_date)
+		SELECT ?, content, completed, quantity, unit, price, due_date FROM list_items WHERE list_id = ? ORDER BY id`},
+	"rated_list": {`INSERT INTO rated_list_items (rated_list_id, title, score, note, image_path)
+		SELECT ?, title, score, note, image_path FROM rated_list_items WHERE rated_list_id = ? ORDER BY id`},
+	"recipe": {
+		`INSERT INTO recipes (item_id, ingredients, instructions, notes, thumbnail, source_url,
+			original_language, original_ingredients, original_instructions)
+		SELECT ?, ingredients, instructions, notes, thumbnail, source_url,
+			original_language, original_ingredients, original_instructions FROM recipes WHERE item_id = ?`,
+		`INSERT INTO recipe_images (recipe_id, file_path, display_order)
+		SELECT ?, file_path, display_order FROM recipe_images WHERE recipe_id = ? ORDER BY display_order, id`,
+	},
+	"drawing": {`INSERT INTO drawings (item_id, file_path, vector_data) SELECT ?, file_path, vector_data FROM drawings WHERE item_id = ?`},
+	"media": {`INSERT INTO media (item_id, file_path, mime_type, content_hash, image_hash, kind)
+		SELECT ?, file_path, mime_type, content_hash, image_hash, kind FROM media WHERE item_id = ?`},
+	"cookbook": {
+		`INSERT INTO cookbooks (item_id, description, cover_image) SELECT ?, description, cover_image FROM cookbooks WHERE item_id = ?`,
+		`INSERT INTO cookbook_recipes (cookbook_id, recipe_id) SELECT ?, recipe_id FROM cookbook_recipes WHERE cookbook_id = ?`,
+	},
+}
+
+// itemFileColumns are the columns holding the files of each item type, as
+// table.column and the column naming the item
+var itemFileColumns = map[string][][3]string{
+	"rated_list": {{"rated_list_items", "image_path", "rated_list_id"}},
+	"recipe":     {{"recipes", "thumbnail", "item_id"}, {"recipe_images", "file_path", "recipe_id"}},
+	"drawing":    {{"drawings", "file_path", "item_id"}},
+	"media":      {{"media", "file_path", "item_id"}},
+	"cookbook":   {{"cookbooks", "cover_image", "item_id"}},
+}
+
+// DuplicateItem copies one of the user's items with its tags, its links to
+// other items, its attachments and what belongs to its type: a checklist's
+// or rated list's entries, a recipe's images, a cookbook's recipes. The copy
+// is titled "<title> (copy)" and is neither pinned nor archived. copyFile is
+// called with each file the item uses and returns the file the copy uses
+// instead. It returns the copy's id and type, or sql.ErrNoRows if the user
+// has no such item outside the trash.
+func DuplicateItem(userID, id int64, copyFile func(path string) (string, error)) (int64, string, error) {
+	var itemType, title string
+	err := DB.QueryRow("SELECT type, title FROM items WHERE id = ? AND user_id = ? AND deleted_at IS NULL", id, userID).
+		Scan(&itemType, &title)
+	if err != nil {
+		return 0, "", err
+	}
+	queries, ok := itemCopyQueries[itemType]
+	if !ok {
+		return 0, "", fmt.Errorf("items of type %s can't be duplicated", itemType)
+	}
+
+	// Copy the files before the transaction, which would otherwise hold
+	// the database's write lock while they are read and written
+	args := make([]interface{}, itemFilesArgs)
+	for i := range args {
+		args[i] = id
+	}
+	rows, err := DB.Query(fmt.Sprintf(itemFilesQuery, "?"), args...)
+	if err != nil {
+		return 0, "", err
+	}
+	var files []string
+	for rows.Next() {
+		var path sql.NullString
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return 0, "", err
+		}
+		if path.String != "" {
+			files = append(files, path.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+	copies := make(map[string]string, len(files))
+	for _, f := range files {
+		if _, done := copies[f]; done {
+			continue
+		}
+		if copies[f], err = copyFile(f); err != nil {
+			return 0, "", fmt.Errorf("copying %s: %w", f, err)
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO items (user_id, title, type) VALUES (?, ?, ?)", userID, title+" (copy)", itemType)
+	if err != nil {
+		return 0, "", err
+	}
+	copyID, err := res.LastInsertId()
+	if err != nil {
+		return 0, "", err
+	}
+	for _, q := range queries {
+		if _, err := tx.Exec(q, copyID, id); err != nil {
+			return 0, "", err
+		}
+	}
+	_, err = tx.Exec(`INSERT INTO attachments (item_id, file_path, file_name, mime_type, size, created_at)
+		SELECT ?, file_path, file_name, mime_type, size, created_at FROM attachments WHERE item_id = ? ORDER BY id`, copyID, id)
+	if err != nil {
+		return 0, "", err
+	}
+	columns := append([][3]string{{"attachments", "file_path", "item_id"}}, itemFileColumns[itemType]...)
+	for old, path := range copies {
+		if path == old {
+			continue
+		}
+		for _, c := range columns {
+			q := fmt.Sprintf("UPDATE %[1]s SET %[2]s = ? WHERE %[3]s = ? AND %[2]s = ?", c[0], c[1], c[2])
+			if _, err := tx.Exec(q, path, copyID, old); err != nil {
+				return 0, "", err
+			}
+		}
+		// Images pasted into a note are its attachments, which its
+		// content shows by their path
+		if itemType == "note" {
+			if _, err := tx.Exec("UPDATE notes SET content = REPLACE(content, ?, ?) WHERE item_id = ?", old, path, copyID); err != nil {
+				return 0, "", err
+			}
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO item_tags (item_id, tag_id) SELECT ?, tag_id FROM item_tags WHERE item_id = ?", copyID, id); err != nil {
+		return 0, "", err
+	}
+	// The copy has the highest id, so it comes second in its links
+	_, err = tx.Exec(`
+		INSERT INTO item_links (item_id, linked_id)
+		SELECT CASE WHEN item_id = ? THEN linked_id ELSE item_id END, ? FROM item_links WHERE item_id = ? OR linked_id = ?`,
+		id, copyID, id, id)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+	return copyID, itemType, nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"infokeep/internal/models"
+)
+
+// Items can be linked to each other, e.g. a note to the recipe and the
+// bookmark it is about. A link goes both ways: it is stored once in
+// item_links, with the lower id first, and each item lists the other as
+// related.
+
+// ErrSelfLink is returned when an item is linked to itself
+var ErrSelfLink = errors.New("an item can't be linked to itself")
+
+// linkTypes are the item types that can be linked, those of the user's
+// content
+const linkTypes = recentTypes
+
+// linkPair orders two item ids the way item_links stores them
+func linkPair(a, b int64) (int64, int64) {
+	if a > b {
+		return b, a
+	}
+	return a, b
+}
+
+// LinkItems links two of the user's items; linking them again does nothing.
+// It returns sql.ErrNoRows unless both are items of the user outside the
+// trash, of one of the linkTypes.
+func LinkItems(userID, a, b int64) error {
+	if a == b {
+		return ErrSelfLink
+	}
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM items WHERE id IN (?, ?) AND user_id = ? AND deleted_at IS NULL AND type IN ("+linkTypes+")",
+		a, b, userID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != 2 {
+		return sql.ErrNoRows
+	}
+	a, b = linkPair(a, b)
+	_, err = DB.Exec("INSERT OR IGNORE INTO item_links (item_id, linked_id) VALUES (?, ?)", a, b)
+	return err
+}
+
+// UnlinkItems removes the link between two of the user's items. It returns
+// sql.ErrNoRows if they are not linked.
+func UnlinkItems(userID, a, b int64) error {
+	a, b = linkPair(a, b)
+	res, err := DB.Exec(`
+		DELETE FROM item_links
+		WHERE item_id = ? AND linked_id = ? AND item_id IN (SELECT id FROM items WHERE user_id = ?)`, a, b, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetLinkedItems returns the items linked to one of the user's items, by
+// type and title. Items in the trash are left out until they are restored.
+func GetLinkedItems(userID, id int64) ([]models.LinkedItem, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.type, i.title, COALESCE(b.url, ''), i.archived_at IS NOT NULL
+		FROM item_links l
+		JOIN items i ON i.id = CASE WHEN l.item_id = ? THEN l.linked_id ELSE l.item_id END
+		LEFT JOIN bookmarks b ON b.item_id = i.id
+		WHERE (l.item_id = ? OR l.linked_id = ?) AND i.user_id = ? AND i.deleted_at IS NULL
+		ORDER BY i.type, i.title COLLATE NOCASE`, id, id, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.LinkedItem
+	for rows.Next() {
+		var it models.LinkedItem
+		if err := rows.Scan(&it.ID, &it.Type, &it.Title, &it.URL, &it.Archived); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// FindLinkCandidates returns up to limit of the user's items whose title
+// contains query, that the item id could be linked to: not the item itself
+// nor items already linked to it. Recently changed items come first.
+func FindLinkCandidates(userID, id int64, query string, limit int) ([]models.LinkedItem, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.type, i.title, COALESCE(b.url, ''), i.archived_at IS NOT NULL
+		FROM items i
+		LEFT JOIN bookmarks b ON b.item_id = i.id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND i.id != ? AND i.type IN (`+linkTypes+`)
+			AND instr(lower(i.title), lower(?)) > 0
+			AND i.id NOT IN (SELECT linked_id FROM item_links WHERE item_id = ?)
+			AND i.id NOT IN (SELECT item_id FROM item_links WHERE linked_id = ?)
+		ORDER BY i.updated_at DESC, i.id DESC
+		LIMIT ?`, userID, id, query, id, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.LinkedItem
+	for rows.Next() {
+		var it models.LinkedItem
+		if err := rows.Scan(&it.ID, &it.Type, &it.Title, &it.URL, &it.Archived); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"time"
+
+	"infokeep/internal/models"
+)
+
+// Each item has a log the user can add dated notes to without editing the
+// item itself. The log is append-only: entries are not edited or deleted,
+// they go with the item when it is purged from the trash.
+
+// AddLogEntry adds an entry to the log of one of the user's items, who the
+// caller checked owns it
+func AddLogEntry(userID, itemID int64, body string) (*models.LogEntry, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	res, err := DB.Exec("INSERT INTO item_log (item_id, user_id, body, created_at) VALUES (?, ?, ?, ?)", itemID, userID, body, now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &models.LogEntry{ID: id, ItemID: itemID, Body: body, CreatedAt: now.Local()}, nil
+}
+
+// GetLogEntries returns the log of one of the user's items, newest first.
+// itemID 0 returns the entries of all their items, with the items' titles.
+func GetLogEntries(userID, itemID int64) ([]models.LogEntry, error) {
+	rows, err := DB.Query(`
+		SELECT l.id, l.item_id, i.title, l.body, l.created_at
+		FROM item_log l JOIN items i ON i.id = l.item_id
+		WHERE l.user_id = ? AND (? = 0 OR l.item_id = ?)
+		ORDER BY l.id DESC`, userID, itemID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LogEntry
+	for rows.Next() {
+		var e models.LogEntry
+		if err := rows.Scan(&e.ID, &e.ItemID, &e.ItemTitle, &e.Body, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = e.CreatedAt.Local()
+		if itemID != 0 {
+			e.ItemTitle = ""
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"infokeep/internal/models"
+)
+
+// Besides the reminders of the Reminders page, which are items of their own,
+// any item can have one-off reminders at a date and time, and checklist
+// entries can have a due date. The reminder worker notifies about both.
+
+// CreateItemReminder adds a reminder about an item at remindAt
+func CreateItemReminder(r *models.ItemReminder) (int64, error) {
+	res, err := DB.Exec(`INSERT INTO item_reminders (item_id, user_id, remind_at, notification_type, emails)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''))`,
+		r.ItemID, r.UserID, r.RemindAt.UTC().Truncate(time.Second), r.NotificationType, r.Emails)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+const itemReminderColumns = `r.id, r.user_id, r.item_id, i.type, i.title, r.remind_at, r.notification_type, COALESCE(r.emails, ''), r.fired_at`
+
+func queryItemReminders(query string, args ...interface{}) ([]models.ItemReminder, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.ItemReminder
+	for rows.Next() {
+		var r models.ItemReminder
+		var firedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.UserID, &r.ItemID, &r.ItemType, &r.ItemTitle, &r.RemindAt, &r.NotificationType, &r.Emails, &firedAt); err != nil {
+			return nil, err
+		}
+		r.RemindAt = r.RemindAt.Local()
+		if firedAt.Valid {
+			r.FiredAt = &firedAt.Time
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// GetItemReminders returns the reminders about the user's items that have
+// not gone off yet, soonest first; itemID 0 returns those of all items
+func GetItemReminders(userID, itemID int64) ([]models.ItemReminder, error) {
+	query := `SELECT ` + itemReminderColumns + `
+		FROM item_reminders r JOIN items i ON i.id = r.item_id
+		WHERE r.user_id = ? AND r.fired_at IS NULL AND i.deleted_at IS NULL`
+	args := []interface{}{userID}
+	if itemID != 0 {
+		query += " AND r.item_id = ?"
+		args = append(args, itemID)
+	}
+	return queryItemReminders(query+" ORDER BY r.remind_at, r.id", args...)
+}
+
+// GetAllItemReminders returns every reminder about the user's items, also
+// those that went off and those of items in the trash, oldest first
+func GetAllItemReminders(userID int64) ([]models.ItemReminder, error) {
+	return queryItemReminders(`SELECT `+itemReminderColumns+`
+		FROM item_reminders r JOIN items i ON i.id = r.item_id
+		WHERE r.user_id = ?
+		ORDER BY r.remind_at, r.id`, userID)
+}
+
+// DeleteItemReminder removes one of the user's item reminders. It returns
+// sql.ErrNoRows if the user has no such reminder.
+func DeleteItemReminder(userID, id int64) error {
+	res, err := DB.Exec("DELETE FROM item_reminders WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetDueItemReminders returns the reminders of all users that are due at
+// now and have not gone off. Those of items in the trash wait until the
+// item is restored.
+func GetDueItemReminders(now time.Time) ([]models.ItemReminder, error) {
+	return queryItemReminders(`SELECT `+itemReminderColumns+`
+		FROM item_reminders r JOIN items i ON i.id = r.item_id
+		WHERE r.fired_at IS NULL AND r.remind_at <= ? AND i.deleted_at IS NULL
+		ORDER BY r.remind_at`, now.UTC().Truncate(time.Second))
+}
+
+// MarkItemReminderFired records that a reminder went off
+func MarkItemReminderFired(id int64, t time.Time) error {
+	_, err := DB.Exec("UPDATE item_reminders SET fired_at = ? WHERE id = ?", t.UTC(), id)
+	return err
+}
+
+// GetDueListItems returns the checklist entries of all users due on or
+// before the day (YYYY-MM-DD) that are not checked off and were not
+// notified about yet
+func GetDueListItems(day string) ([]models.DueListItem, error) {
+	rows, err := DB.Query(`
+		SELECT li.id, i.user_id, i.id, i.title, li.content, li.due_date
+		FROM list_items li JOIN items i ON i.id = li.list_id
+		WHERE li.due_date <= ? AND li.completed = 0 AND li.due_notified_at IS NULL AND i.deleted_at IS NULL
+		ORDER BY li.due_date, li.id`, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DueListItem
+	for rows.Next() {
+		var item models.DueListItem
+		if err := rows.Scan(&item.ID, &item.UserID, &item.ListID, &item.ListTitle, &item.Content, &item.DueDate); err != nil {
+			return nil, err
+		}
+		results = append(results, item)
+	}
+	return results, rows.Err()
+}
+
+// GetListItemDueDates returns the user's checklist entries that have a due
+// date, also those checked off and those of checklists in the trash
+func GetListItemDueDates(userID int64) ([]models.DueListItem, error) {
+	rows, err := DB.Query(`
+		SELECT li.id, i.user_id, i.id, i.title, COALESCE(li.content, ''), li.due_date, li.completed
+		FROM list_items li JOIN items i ON i.id = li.list_id
+		WHERE i.user_id = ? AND li.due_date IS NOT NULL
+		ORDER BY li.due_date, li.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DueListItem
+	for rows.Next() {
+		var item models.DueListItem
+		if err := rows.Scan(&item.ID, &item.UserID, &item.ListID, &item.ListTitle, &item.Content, &item.DueDate, &item.Completed); err != nil {
+			return nil, err
+		}
+		results = append(results, item)
+	}
+	return results, rows.Err()
+}
+
+// MarkListItemDueNotified records that the user was told a checklist entry
+// is due
+func MarkListItemDueNotified(id int64, t time.Time) error {
+	_, err := DB.Exec("UPDATE list_items SET due_notified_at = ? WHERE id = ?", t.UTC(), id)
+	return err
+}
+
+// GetUpcoming returns the user's item reminders that go off before until and
+// the checklist entries not checked off that are due by then or overdue,
+// soonest first. The reminders of the Reminders page repeat, so the reminder
+// worker works out when they go off next.
+func GetUpcoming(userID int64, now, until time.Time) ([]models.Upcoming, error) {
+	reminders, err := queryItemReminders(`SELECT `+itemReminderColumns+`
+		FROM item_reminders r JOIN items i ON i.id = r.item_id
+		WHERE r.user_id = ? AND r.fired_at IS NULL AND r.remind_at < ? AND i.deleted_at IS NULL AND i.archived_at IS NULL
+		ORDER BY r.remind_at`, userID, until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	var results []models.Upcoming
+	for _, r := range reminders {
+		results = append(results, models.Upcoming{
+			Kind: "item_reminder", ItemID: r.ItemID, Type: r.ItemType, Title: r.ItemTitle,
+			At: r.RemindAt, Overdue: r.RemindAt.Before(now),
+		})
+	}
+
+	today := now.Format(time.DateOnly)
+	rows, err := DB.Query(`
+		SELECT i.id, i.title, li.content, li.due_date
+		FROM list_items li JOIN items i ON i.id = li.list_id
+		WHERE i.user_id = ? AND li.due_date < ? AND li.completed = 0 AND i.deleted_at IS NULL AND i.archived_at IS NULL
+		ORDER BY li.due_date, li.id`, userID, until.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u := models.Upcoming{Kind: "due", Type: "list", AllDay: true}
+		var due string
+		if err := rows.Scan(&u.ItemID, &u.Detail, &u.Title, &due); err != nil {
+			return nil, err
+		}
+		u.At, _ = time.ParseInLocation(time.DateOnly, due, now.Location())
+		u.Overdue = due < today
+		results = append(results, u)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// Job statuses
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is a unit of background work. Jobs enqueued together share a BatchID so
+// a summary can be produced once the whole batch has finished.
+type Job struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Type      string `json:"type"`
+	BatchID   string `json:"batch_id"`
+	Payload   string `json:"payload"`
+	Status    string `json:"status"`
+	Result    string `json:"result"`
+	Attempts  int    `json:"attempts"`
+	Progress  int    `json:"progress"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// JobBatch summarizes the jobs of one batch.
+type JobBatch struct {
+	BatchID   string `json:"batch_id"`
+	Type      string `json:"type"`
+	Total     int    `json:"total"`
+	Pending   int    `json:"pending"`
+	Done      int    `json:"done"`
+	Failed    int    `json:"failed"`
+	CreatedAt string `json:"created_at"`
+}
+
+const jobColumns = "id, user_id, type, COALESCE(batch_id, ''), payload, status, COALESCE(result, ''), attempts, progress, created_at, updated_at"
+
+func scanJob(row interface{ Scan(...interface{}) error }) (*Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.UserID, &j.Type, &j.BatchID, &j.Payload, &j.Status, &j.Result, &j.Attempts, &j.Progress, &j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func EnqueueJob(userID int64, jobType, batchID, payload string) (int64, error) {
+	result, err := DB.Exec("INSERT INTO jobs (user_id, type, batch_id, payload) VALUES (?, ?, ?, ?)",
+		userID, jobType, batchID, payload)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// ClaimNextJob marks the oldest pending job as running and returns it, or
+// sql.ErrNoRows if the queue is empty.
+func ClaimNextJob() (*Job, error) {
+	return scanJob(DB.QueryRow(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1, progress = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = (SELECT id FROM jobs WHERE status = ? ORDER BY id LIMIT 1)
+		RETURNING `+jobColumns, JobRunning, JobPending))
+}
+
+// FinishJob records the outcome of a job
+func FinishJob(id int64, status, result string) error {
+	_, err := DB.Exec("UPDATE jobs SET status = ?, result = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", status, result, id)
+	return err
+}
+
+// SetJobProgress records how far along a running job is, in percent
+func SetJobProgress(id int64, percent int) error {
+	_, err := DB.Exec("UPDATE jobs SET progress = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", percent, id)
+	return err
+}
+
+// SetJobPayload replaces a job's payload, e.g. to drop credentials once they are no longer needed
+func SetJobPayload(id int64, payload string) error {
+	_, err := DB.Exec("UPDATE jobs SET payload = ? WHERE id = ?", payload, id)
+	return err
+}
+
+// GetJob returns a job of the user, or sql.ErrNoRows
+func GetJob(userID, id int64) (*Job, error) {
+	return scanJob(DB.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ? AND user_id = ?", id, userID))
+}
+
+// GetRecentJobs returns the user's latest jobs of a type, newest first.
+func GetRecentJobs(userID int64, jobType string, limit int) ([]Job, error) {
+	rows, err := DB.Query("SELECT "+jobColumns+" FROM jobs WHERE user_id = ? AND type = ? ORDER BY id DESC LIMIT ?", userID, jobType, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, nil
+}
+
+// RequeueRunningJobs puts jobs that were interrupted by a restart back in the queue.
+func RequeueRunningJobs() (int64, error) {
+	result, err := DB.Exec("UPDATE jobs SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE status = ?", JobPending, JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetBatchJobs returns every job in a batch in the order they were enqueued.
+func GetBatchJobs(batchID string) ([]Job, error) {
+	rows, err := DB.Query("SELECT "+jobColumns+" FROM jobs WHERE batch_id = ? ORDER BY id", batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, nil
+}
+
+// CountUnfinishedBatchJobs returns how many jobs of a batch are still pending or running.
+func CountUnfinishedBatchJobs(batchID string) (int, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM jobs WHERE batch_id = ? AND status IN (?, ?)",
+		batchID, JobPending, JobRunning).Scan(&count)
+	return count, err
+}
+
+// GetRecentJobBatches returns the user's latest batches of a job type, newest first.
+func GetRecentJobBatches(userID int64, jobType string, limit int) ([]JobBatch, error) {
+	rows, err := DB.Query(`
+		SELECT batch_id, type, COUNT(*),
+			SUM(CASE WHEN status IN (?, ?) THEN 1 ELSE 0 END),
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END),
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END),
+			MIN(created_at)
+		FROM jobs
+		WHERE user_id = ? AND type = ? AND batch_id IS NOT NULL AND batch_id != ''
+		GROUP BY batch_id
+		ORDER BY MIN(id) DESC
+		LIMIT ?`, JobPending, JobRunning, JobDone, JobFailed, userID, jobType, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []JobBatch
+	for rows.Next() {
+		var b JobBatch
+		var createdAt sql.NullString
+		if err := rows.Scan(&b.BatchID, &b.Type, &b.Total, &b.Pending, &b.Done, &b.Failed, &createdAt); err != nil {
+			return nil, err
+		}
+		b.CreatedAt = createdAt.String
+		batches = append(batches, b)
+	}
+	return batches, nil
+}
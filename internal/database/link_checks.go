@@ -0,0 +1,47 @@
+package database
+
+import "fmt"
+
+// Bookmarked links are requested now and then to find the ones that no
+// longer work. The outcome of the last check is kept on the bookmark: the
+// HTTP status (0 if there was no response), the error and when it ran.
+
+// brokenLinkSQL is the condition on bookmarks b of a link whose last check
+// failed
+const brokenLinkSQL = "(b.link_checked_at IS NOT NULL AND (COALESCE(b.link_status, 0) = 0 OR b.link_status >= 400))"
+
+// GetBookmarksToLinkCheck returns up to limit bookmarks (of every user, not
+// in the trash) whose link was never checked or not in the last days days,
+// those checked longest ago first
+func GetBookmarksToLinkCheck(days, limit int) ([]BookmarkToCheck, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.user_id, i.title, b.url
+		FROM items i
+		JOIN bookmarks b ON b.item_id = i.id
+		WHERE i.deleted_at IS NULL AND (b.link_checked_at IS NULL OR b.link_checked_at < datetime('now', ?))
+		ORDER BY b.link_checked_at IS NOT NULL, b.link_checked_at, i.id
+		LIMIT ?`, fmt.Sprintf("-%d days", days), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []BookmarkToCheck
+	for rows.Next() {
+		var b BookmarkToCheck
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Title, &b.URL); err != nil {
+			return nil, err
+		}
+		due = append(due, b)
+	}
+	return due, rows.Err()
+}
+
+// SetLinkStatus records the outcome of a check of a bookmark's link, done
+// now: the HTTP status, or 0 and the error if there was no response
+func SetLinkStatus(itemID int64, status int, errText string) error {
+	_, err := DB.Exec(`
+		UPDATE bookmarks SET link_status = ?, link_error = NULLIF(?, ''), link_checked_at = CURRENT_TIMESTAMP
+		WHERE item_id = ?`, status, errText, itemID)
+	return err
+}
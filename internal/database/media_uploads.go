@@ -0,0 +1,41 @@
+package database
+
+// MediaUpload is a direct-to-storage upload that has been handed a
+// pre-signed URL but whose media item is only created once the client
+// reports the upload as complete.
+type MediaUpload struct {
+	ID        string `json:"id"`
+	UserID    int64  `json:"user_id"`
+	ObjectKey string `json:"object_key"`
+	Title     string `json:"title"`
+	Tags      string `json:"tags"`
+	MimeType  string `json:"mime_type"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateMediaUpload records a pending upload. Uploads that were never
+// completed are dropped after a day.
+func CreateMediaUpload(u MediaUpload) error {
+	_, _ = DB.Exec("DELETE FROM media_uploads WHERE created_at < datetime('now', '-1 day')")
+	_, err := DB.Exec("INSERT INTO media_uploads (id, user_id, object_key, title, tags, mime_type) VALUES (?, ?, ?, ?, ?, ?)",
+		u.ID, u.UserID, u.ObjectKey, u.Title, u.Tags, u.MimeType)
+	return err
+}
+
+// GetMediaUpload returns a pending upload of the user, or sql.ErrNoRows
+func GetMediaUpload(userID int64, id string) (*MediaUpload, error) {
+	var u MediaUpload
+	err := DB.QueryRow(`
+		SELECT id, user_id, object_key, title, COALESCE(tags, ''), COALESCE(mime_type, ''), created_at
+		FROM media_uploads WHERE id = ? AND user_id = ?`, id, userID).
+		Scan(&u.ID, &u.UserID, &u.ObjectKey, &u.Title, &u.Tags, &u.MimeType, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func DeleteMediaUpload(id string) error {
+	_, err := DB.Exec("DELETE FROM media_uploads WHERE id = ?", id)
+	return err
+}
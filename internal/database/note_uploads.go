@@ -0,0 +1,70 @@
+package database
+
+import "fmt"
+
+// Images pasted into a note that is not saved yet are kept in note_uploads.
+// They become attachments of the note whose content shows them when it is
+// saved, and go with it when it is purged from the trash; those of notes
+// that were never saved are removed after a while.
+
+// AddNoteUpload records an image the user uploaded for a note not saved yet
+func AddNoteUpload(userID int64, filePath, fileName, mimeType string, size int64) error {
+	_, err := DB.Exec("INSERT INTO note_uploads (file_path, user_id, file_name, mime_type, size) VALUES (?, ?, ?, ?, ?)",
+		filePath, userID, fileName, mimeType, size)
+	return err
+}
+
+// ClaimNoteUploads attaches to the user's note the images they uploaded for
+// a note not saved yet that its content shows
+func ClaimNoteUploads(userID, noteID int64, content string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO attachments (item_id, file_path, file_name, mime_type, size, created_at)
+		SELECT ?, file_path, file_name, mime_type, size, created_at FROM note_uploads
+		WHERE user_id = ? AND instr(?, file_path) > 0 ORDER BY created_at`, noteID, userID, content)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM note_uploads WHERE user_id = ? AND instr(?, file_path) > 0", userID, content); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteStaleNoteUploads deletes the images uploaded more than hours ago for
+// notes that were never saved, returning their files
+func DeleteStaleNoteUploads(hours int) ([]string, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cutoff := fmt.Sprintf("-%d hours", hours)
+	rows, err := tx.Query("SELECT file_path FROM note_uploads WHERE created_at < datetime('now', ?)", cutoff)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM note_uploads WHERE created_at < datetime('now', ?)", cutoff); err != nil {
+		return nil, err
+	}
+	return files, tx.Commit()
+}
@@ -0,0 +1,31 @@
+package database
+
+// GetItemOwner returns the ID of the user an item belongs to, or
+// sql.ErrNoRows if there is no such item or it is in the trash.
+func GetItemOwner(itemID int64) (int64, error) {
+	var userID int64
+	err := DB.QueryRow("SELECT user_id FROM items WHERE id = ? AND deleted_at IS NULL", itemID).Scan(&userID)
+	return userID, err
+}
+
+// GetListItemOwner returns the checklist an entry is on and the user that
+// list belongs to.
+func GetListItemOwner(id int64) (listID, userID int64, err error) {
+	err = DB.QueryRow(`
+		SELECT li.list_id, i.user_id
+		FROM list_items li
+		JOIN items i ON li.list_id = i.id
+		WHERE li.id = ? AND i.deleted_at IS NULL`, id).Scan(&listID, &userID)
+	return listID, userID, err
+}
+
+// GetRatedListItemOwner returns the rated list an entry is on and the user
+// that list belongs to.
+func GetRatedListItemOwner(id int64) (listID, userID int64, err error) {
+	err = DB.QueryRow(`
+		SELECT rli.rated_list_id, i.user_id
+		FROM rated_list_items rli
+		JOIN items i ON rli.rated_list_id = i.id
+		WHERE rli.id = ? AND i.deleted_at IS NULL`, id).Scan(&listID, &userID)
+	return listID, userID, err
+}
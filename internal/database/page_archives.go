@@ -0,0 +1,43 @@
+package database
+
+// A bookmark's page can be archived: its article, cleaned of everything but
+// text markup, is kept in page_archives with its plain text, which the
+// search index holds too. The copy stays readable after the page changes or
+// goes away.
+
+// PageArchive is the archived copy of a bookmarked page
+type PageArchive struct {
+	ItemID     int64  `json:"item_id"`
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	HTML       string `json:"html"`
+	Text       string `json:"text"`
+	ArchivedAt string `json:"archived_at"`
+}
+
+// SavePageArchive stores the copy of a bookmark's page as archived now,
+// replacing an older one
+func SavePageArchive(itemID int64, url, title, html, text string) error {
+	_, err := DB.Exec(`
+		INSERT INTO page_archives (item_id, url, title, html, text, archived_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(item_id) DO UPDATE SET
+			url = excluded.url, title = excluded.title, html = excluded.html,
+			text = excluded.text, archived_at = excluded.archived_at`,
+		itemID, url, title, html, text)
+	return err
+}
+
+// GetPageArchive returns the archived copy of a bookmark's page. It returns
+// sql.ErrNoRows if the page was not archived.
+func GetPageArchive(itemID int64) (*PageArchive, error) {
+	var a PageArchive
+	err := DB.QueryRow(`
+		SELECT item_id, url, title, html, text, COALESCE(archived_at, '')
+		FROM page_archives WHERE item_id = ?`, itemID).
+		Scan(&a.ItemID, &a.URL, &a.Title, &a.HTML, &a.Text, &a.ArchivedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,33 @@
+package database
+
+// Page selects part of a list query's rows: up to Limit rows after the
+// first Offset, in the order Sort. The zero Page selects every row, newest
+// first.
+type Page struct {
+	Limit  int
+	Offset int
+	Sort   Sort
+}
+
+// sql returns the LIMIT clause for the page and its arguments
+func (p Page) sql() (string, []interface{}) {
+	if p.Limit <= 0 {
+		return "", nil
+	}
+	return " LIMIT ? OFFSET ?", []interface{}{p.Limit, p.Offset}
+}
+
+// pageTotal returns the number of rows a list query matches on all pages.
+// from is the query's FROM and WHERE part and read the number of rows the
+// page got; when those are all the rows there are, no count is needed.
+func pageTotal(from string, args []interface{}, page Page, read int) (int, error) {
+	if page.Limit <= 0 {
+		return read, nil
+	}
+	if read < page.Limit && (read > 0 || page.Offset == 0) {
+		return page.Offset + read, nil
+	}
+	var total int
+	err := DB.QueryRow("SELECT COUNT(*)"+from, args...).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// AccountInfo is a user's account record without password or token secrets.
+type AccountInfo struct {
+	ID                 int64  `json:"id"`
+	Username           string `json:"username"`
+	CreatedAt          string `json:"created_at"`
+	DefaultPage        string `json:"default_page"`
+	LoginAlertEmail    string `json:"login_alert_email"`
+	HasAPIToken        bool   `json:"has_api_token"`
+	APITokenAllowlist  string `json:"api_token_allowlist"`
+	PCloudLinked       bool   `json:"pcloud_linked"`
+	PCloudHostname     string `json:"pcloud_hostname"`
+	GDriveLinked       bool   `json:"gdrive_linked"`
+	BackupIntervalDays int    `json:"backup_interval_days"`
+	LastBackupAt       string `json:"last_backup_at"`
+}
+
+func GetAccountInfo(userID int64) (*AccountInfo, error) {
+	var a AccountInfo
+	var createdAt, defaultPage, alertEmail, apiToken, allowlist, pcloudToken, pcloudHost, gdriveToken, lastBackup sql.NullString
+	var interval sql.NullInt64
+	err := DB.QueryRow(`
+		SELECT id, username, created_at, default_page, login_alert_email, api_token, api_token_allowlist,
+			pcloud_access_token, pcloud_hostname, gdrive_refresh_token, backup_interval_days, last_backup_at
+		FROM users WHERE id = ?`, userID).Scan(&a.ID, &a.Username, &createdAt, &defaultPage, &alertEmail, &apiToken,
+		&allowlist, &pcloudToken, &pcloudHost, &gdriveToken, &interval, &lastBackup)
+	if err != nil {
+		return nil, err
+	}
+	a.CreatedAt = createdAt.String
+	a.DefaultPage = defaultPage.String
+	a.LoginAlertEmail = alertEmail.String
+	a.HasAPIToken = apiToken.String != ""
+	a.APITokenAllowlist = allowlist.String
+	a.PCloudLinked = pcloudToken.String != ""
+	a.PCloudHostname = pcloudHost.String
+	a.GDriveLinked = gdriveToken.String != ""
+	a.BackupIntervalDays = int(interval.Int64)
+	a.LastBackupAt = lastBackup.String
+	return &a, nil
+}
+
+// GetSessionExpiries returns when each of the user's sessions expires, latest
+// first. Session IDs are credentials, so they are not returned.
+func GetSessionExpiries(userID int64) ([]string, error) {
+	rows, err := DB.Query("SELECT expires_at FROM sessions WHERE user_id = ? ORDER BY expires_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expiries []string
+	for rows.Next() {
+		var expiresAt string
+		if err := rows.Scan(&expiresAt); err != nil {
+			return nil, err
+		}
+		expiries = append(expiries, expiresAt)
+	}
+	return expiries, nil
+}
+
+// GetSharedLinksByUser returns the user's shared links with the titles of
+// their items, oldest first
+func GetSharedLinksByUser(userID int64) ([]SharedLink, error) {
+	rows, err := DB.Query(`
+		SELECT s.id, s.link_hash, s.item_type, s.item_id, s.user_id, s.created_at, s.expires_at, COALESCE(i.title, '')
+		FROM shared_links s LEFT JOIN items i ON i.id = s.item_id
+		WHERE s.user_id = ? ORDER BY s.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []SharedLink
+	for rows.Next() {
+		var link SharedLink
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&link.ID, &link.LinkHash, &link.ItemType, &link.ItemID, &link.UserID, &link.CreatedAt, &expiresAt, &link.Title); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			link.ExpiresAt = &expiresAt.Time
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// GetJobsByUser returns all of a user's background jobs, newest first.
+func GetJobsByUser(userID int64) ([]Job, error) {
+	rows, err := DB.Query("SELECT "+jobColumns+" FROM jobs WHERE user_id = ? ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, nil
+}
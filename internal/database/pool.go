@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"infokeep/internal/metrics"
+)
+
+// SQLite lets one connection write at a time. When several HTMX requests
+// write at once, the busy timeout makes the others wait for the lock, but
+// SQLite's waiting backs off in growing sleeps and a writer can still run
+// out of time and fail with SQLITE_BUSY. So writes are also queued in the
+// process: a write statement or transaction waits here for the ones before
+// it to finish, and then finds the database free. Reads are not held up.
+//
+// The wait is capped at the busy timeout. A transaction that writes through
+// DB instead of its own Tx would otherwise wait for itself forever; past the
+// cap the write goes ahead and SQLite's own locking decides, as without the
+// queue.
+
+// writeWait caps the wait for the write queue, matching _busy_timeout
+const writeWait = 5 * time.Second
+
+var (
+	// serializeWrites is off with DB_SERIALIZE_WRITES=off
+	serializeWrites = os.Getenv("DB_SERIALIZE_WRITES") != "off"
+	// writeQueue holds a token while a write runs
+	writeQueue = make(chan struct{}, 1)
+)
+
+var writeQueueWait = metrics.NewHistogramVec("infokeep_db_write_wait_seconds",
+	"Time writes waited for the ones before them to finish.",
+	[]float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+	"outcome")
+
+// acquireWrite waits for the turn of a write and reports whether it got it;
+// only then must releaseWrite be called
+func acquireWrite(ctx context.Context) bool {
+	if !serializeWrites {
+		return false
+	}
+	start := time.Now()
+	select {
+	case writeQueue <- struct{}{}:
+		writeQueueWait.Observe(time.Since(start).Seconds(), "acquired")
+		return true
+	default:
+	}
+	timer := time.NewTimer(writeWait)
+	defer timer.Stop()
+	select {
+	case writeQueue <- struct{}{}:
+		writeQueueWait.Observe(time.Since(start).Seconds(), "acquired")
+		return true
+	case <-timer.C:
+		writeQueueWait.Observe(time.Since(start).Seconds(), "timeout")
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func releaseWrite() {
+	<-writeQueue
+}
+
+// isWrite reports whether a statement changes the database. Statements run
+// inside a transaction are covered by the transaction's turn.
+func isWrite(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "CREATE", "DROP", "ALTER":
+		return true
+	}
+	return false
+}
+
+// queuedTx is a transaction that holds the write queue until it ends
+type queuedTx struct {
+	driver.Tx
+	conn *timedConn
+}
+
+func (t *queuedTx) Commit() error {
+	defer t.end()
+	return t.Tx.Commit()
+}
+
+func (t *queuedTx) Rollback() error {
+	defer t.end()
+	return t.Tx.Rollback()
+}
+
+func (t *queuedTx) end() {
+	t.conn.inTx = false
+	if t.conn.queued {
+		t.conn.queued = false
+		releaseWrite()
+	}
+}
+
+// queuedRows releases the write queue once a writing query's rows are closed
+type queuedRows struct {
+	driver.Rows
+	released bool
+}
+
+func (r *queuedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.released {
+		r.released = true
+		releaseWrite()
+	}
+	return err
+}
+
+// configurePool sizes the connection pool from DB_MAX_OPEN_CONNS and
+// DB_MAX_IDLE_CONNS, leaving database/sql's defaults (no limit, 2 idle)
+// for those not set
+func configurePool(db *sql.DB) {
+	if n, err := strconv.Atoi(os.Getenv("DB_MAX_OPEN_CONNS")); err == nil && n > 0 {
+		db.SetMaxOpenConns(n)
+	}
+	if n, err := strconv.Atoi(os.Getenv("DB_MAX_IDLE_CONNS")); err == nil && n >= 0 {
+		db.SetMaxIdleConns(n)
+	}
+	if !serializeWrites {
+		log.Printf("DB_SERIALIZE_WRITES=off: concurrent writes rely on SQLite's busy timeout")
+	}
+}
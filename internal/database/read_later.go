@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Bookmarks can be put in a read-later queue (read_later_at) and marked read
+// from it (read_at). Both times are kept once a bookmark is read, for the
+// reading statistics.
+
+// SetReadLater puts one of the user's bookmarks in the read-later queue, or
+// takes it out without it counting as read. It returns sql.ErrNoRows if
+// there is no such bookmark.
+func SetReadLater(userID, id int64, queued bool) error {
+	query := "UPDATE bookmarks SET read_later_at = CURRENT_TIMESTAMP, read_at = NULL"
+	if !queued {
+		query = "UPDATE bookmarks SET read_later_at = NULL, read_at = NULL"
+	}
+	return updateOwnBookmark(query, userID, id)
+}
+
+// MarkBookmarkRead marks one of the user's bookmarks in the read-later queue
+// as read. It returns sql.ErrNoRows if there is no such bookmark in the
+// queue.
+func MarkBookmarkRead(userID, id int64) error {
+	return updateOwnBookmark(
+		"UPDATE bookmarks SET read_at = CURRENT_TIMESTAMP", userID, id,
+		"read_later_at IS NOT NULL AND read_at IS NULL")
+}
+
+// ToggleBookmarkRead marks one of the user's bookmarks read if it waits in
+// the read-later queue, and puts it back in the queue as unread otherwise.
+// It returns sql.ErrNoRows if there is no such bookmark.
+func ToggleBookmarkRead(userID, id int64) error {
+	return updateOwnBookmark(`
+		UPDATE bookmarks SET
+			read_at = CASE WHEN read_later_at IS NOT NULL AND read_at IS NULL THEN CURRENT_TIMESTAMP END,
+			read_later_at = COALESCE(read_later_at, CURRENT_TIMESTAMP)`, userID, id)
+}
+
+// updateOwnBookmark runs the UPDATE query on the bookmark id of the user
+// that matches the extra conditions
+func updateOwnBookmark(query string, userID, id int64, conditions ...string) error {
+	query += " WHERE item_id = ? AND item_id IN (SELECT id FROM items WHERE user_id = ? AND deleted_at IS NULL)"
+	for _, c := range conditions {
+		query += " AND " + c
+	}
+	res, err := DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReadingStats sums up a user's read-later queue
+type ReadingStats struct {
+	Queue int64 `json:"queue"` // bookmarks waiting to be read
+	// BacklogAgeDays is how long, on average, those have been waiting
+	BacklogAgeDays float64       `json:"backlog_age_days"`
+	Weeks          []ReadingWeek `json:"weeks"` // oldest week first
+}
+
+// ReadingWeek is the number of bookmarks put in the read-later queue and
+// read from it in the week starting on Monday Week
+type ReadingWeek struct {
+	Week  string `json:"week"` // YYYY-MM-DD
+	Saved int64  `json:"saved"`
+	Read  int64  `json:"read"`
+}
+
+// GetReadingStats returns the statistics of the user's read-later queue
+// for the last weeks weeks (up to now)
+func GetReadingStats(userID int64, weeks int, now time.Time) (*ReadingStats, error) {
+	stats := &ReadingStats{}
+	var ageDays sql.NullFloat64
+	err := DB.QueryRow(`
+		SELECT COUNT(*), AVG(julianday(?) - julianday(b.read_later_at))
+		FROM bookmarks b
+		JOIN items i ON i.id = b.item_id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND b.read_later_at IS NOT NULL AND b.read_at IS NULL`,
+		now.UTC().Format("2006-01-02 15:04:05"), userID).Scan(&stats.Queue, &ageDays)
+	if err != nil {
+		return nil, err
+	}
+	stats.BacklogAgeDays = ageDays.Float64
+
+	first := firstWeek(now, weeks)
+	counts := map[string]*ReadingWeek{}
+	stats.Weeks = make([]ReadingWeek, weeks)
+	for i := range stats.Weeks {
+		stats.Weeks[i].Week = first.AddDate(0, 0, 7*i).Format("2006-01-02")
+		counts[stats.Weeks[i].Week] = &stats.Weeks[i]
+	}
+
+	// Bookmarks in the trash still count in the weeks they were saved and
+	// read in
+	rows, err := DB.Query(`
+		SELECT date(b.read_later_at, '-6 days', 'weekday 1'), COALESCE(date(b.read_at, '-6 days', 'weekday 1'), '')
+		FROM bookmarks b
+		JOIN items i ON i.id = b.item_id
+		WHERE i.user_id = ? AND b.read_later_at IS NOT NULL
+			AND (b.read_later_at >= ? OR b.read_at >= ?)`,
+		userID, first.Format("2006-01-02"), first.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var saved, read string
+		if err := rows.Scan(&saved, &read); err != nil {
+			return nil, err
+		}
+		if w := counts[saved]; w != nil {
+			w.Saved++
+		}
+		if w := counts[read]; w != nil {
+			w.Read++
+		}
+	}
+	return stats, rows.Err()
+}
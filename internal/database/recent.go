@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"infokeep/internal/models"
+)
+
+// items.updated_at is when an item or anything it is made of (a note's
+// text, a list's entries, a recipe's images, its tags, ...) last changed.
+// Triggers on those tables keep it current, so the write functions don't
+// need to remember to. Changes made by the server on its own (thumbnails,
+// fingerprints, summaries) don't count.
+
+// touchTriggers bump updated_at of the items a change affects. item selects
+// the item id from the changed row (NEW or OLD).
+var touchTriggers = []struct {
+	name, event, table, item string
+}{
+	{"touch_items_au", "UPDATE OF title", "items", "NEW.id"},
+	{"touch_notes_au", "UPDATE OF content", "notes", "NEW.item_id"},
+	{"touch_bookmarks_au", "UPDATE OF url, canonical_url, description", "bookmarks", "NEW.item_id"},
+	{"touch_recipes_au", "UPDATE OF ingredients, instructions, notes, thumbnail, source_url", "recipes", "NEW.item_id"},
+	{"touch_recipe_images_ai", "INSERT", "recipe_images", "NEW.recipe_id"},
+	{"touch_recipe_images_ad", "DELETE", "recipe_images", "OLD.recipe_id"},
+	{"touch_drawings_au", "UPDATE OF file_path, vector_data", "drawings", "NEW.item_id"},
+	{"touch_list_items_ai", "INSERT", "list_items", "NEW.list_id"},
+	{"touch_list_items_au", "UPDATE", "list_items", "NEW.list_id"},
+	{"touch_list_items_ad", "DELETE", "list_items", "OLD.list_id"},
+	{"touch_rated_list_items_ai", "INSERT", "rated_list_items", "NEW.rated_list_id"},
+	{"touch_rated_list_items_au", "UPDATE", "rated_list_items", "NEW.rated_list_id"},
+	{"touch_rated_list_items_ad", "DELETE", "rated_list_items", "OLD.rated_list_id"},
+	{"touch_cookbooks_au", "UPDATE OF description, cover_image", "cookbooks", "NEW.item_id"},
+	{"touch_cookbook_recipes_ai", "INSERT", "cookbook_recipes", "NEW.cookbook_id"},
+	{"touch_cookbook_recipes_ad", "DELETE", "cookbook_recipes", "OLD.cookbook_id"},
+	{"touch_item_tags_ai", "INSERT", "item_tags", "NEW.item_id"},
+	{"touch_item_tags_ad", "DELETE", "item_tags", "OLD.item_id"},
+}
+
+// createTouchTriggers (re)creates the triggers that maintain updated_at.
+// It runs after the migrations, so tables they rebuild don't make every
+// item look changed.
+func createTouchTriggers() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, t := range touchTriggers {
+		stmt := fmt.Sprintf(`
+			DROP TRIGGER IF EXISTS %[1]s;
+			CREATE TRIGGER %[1]s AFTER %[2]s ON %[3]s BEGIN
+				UPDATE items SET updated_at = CURRENT_TIMESTAMP WHERE id = %[4]s;
+			END`, t.name, t.event, t.table, t.item)
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("creating trigger %s: %w", t.name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// recentTypes are the item types GetRecentItems returns
+const recentTypes = "'note', 'bookmark', 'recipe', 'cookbook', 'list', 'rated_list', 'drawing', 'media'"
+
+// GetRecentItems returns up to limit of the user's items (not in the trash
+// or archived) across all types, the most recently created or changed first
+func GetRecentItems(userID int64, limit int) ([]models.RecentItem, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.type, i.title, COALESCE(b.url, ''), i.created_at, i.updated_at
+		FROM items i
+		LEFT JOIN bookmarks b ON i.id = b.item_id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND i.archived_at IS NULL AND i.type IN (`+recentTypes+`)
+		ORDER BY COALESCE(i.updated_at, i.created_at) DESC, i.id DESC
+		LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.RecentItem
+	var ids []int64
+	for rows.Next() {
+		var item models.RecentItem
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&item.ID, &item.Type, &item.Title, &item.URL, &item.CreatedAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		item.UpdatedAt = item.CreatedAt
+		if updatedAt.Valid {
+			item.UpdatedAt = updatedAt.Time
+		}
+		item.Updated = item.UpdatedAt.After(item.CreatedAt)
+		ids = append(ids, item.ID)
+		results = append(results, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+	return results, nil
+}
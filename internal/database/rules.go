@@ -0,0 +1,65 @@
+package database
+
+// Rule is an automation rule: when an item of ItemType ("" for any type) is
+// created or updated and its Field matches Value with Operator, Action is
+// applied to it with ActionValue (see handlers/rules.go).
+type Rule struct {
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"user_id"`
+	ItemType    string `json:"item_type"`
+	Field       string `json:"field"`
+	Operator    string `json:"operator"`
+	Value       string `json:"value"`
+	Action      string `json:"action"`
+	ActionValue string `json:"action_value"`
+	Enabled     bool   `json:"enabled"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// GetRules returns the user's rules in the order they run, oldest first
+func GetRules(userID int64) ([]Rule, error) {
+	rows, err := DB.Query(`
+		SELECT id, user_id, item_type, field, operator, value, action, action_value, enabled, created_at
+		FROM rules
+		WHERE user_id = ?
+		ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []Rule
+	for rows.Next() {
+		var r Rule
+		if err := rows.Scan(&r.ID, &r.UserID, &r.ItemType, &r.Field, &r.Operator, &r.Value,
+			&r.Action, &r.ActionValue, &r.Enabled, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// CreateRule adds an enabled rule for r.UserID
+func CreateRule(r Rule) (int64, error) {
+	res, err := DB.Exec(`
+		INSERT INTO rules (user_id, item_type, field, operator, value, action, action_value)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		r.UserID, r.ItemType, r.Field, r.Operator, r.Value, r.Action, r.ActionValue)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// SetRuleEnabled turns one of the user's rules on or off
+func SetRuleEnabled(userID, id int64, enabled bool) error {
+	_, err := DB.Exec("UPDATE rules SET enabled = ? WHERE id = ? AND user_id = ?", enabled, id, userID)
+	return err
+}
+
+// DeleteRule removes one of the user's rules
+func DeleteRule(userID, id int64) error {
+	_, err := DB.Exec("DELETE FROM rules WHERE id = ? AND user_id = ?", id, userID)
+	return err
+}
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Full-text search runs on search_index, an FTS5 table with one row per item
+// (rowid = item id) holding its title, its text (note content, bookmark
+// description, notes, URL and page text, recipe ingredients and instructions)
+// and its tag names. Triggers on the tables those come from keep it up to date, so the
+// write functions don't need to know about it.
+//
+// FTS5 is only compiled into the bundled SQLite with the sqlite_fts5 build
+// tag (see the Makefile). Without it SearchEnabled reports false and
+// callers fall back to searching items one by one.
+
+var searchEnabled bool
+
+// SearchEnabled reports whether the full-text index is available
+func SearchEnabled() bool {
+	return searchEnabled
+}
+
+// searchDocumentsView is what search_index holds for each item. It is
+// created again on every start, so that a changed view replaces the old one.
+const searchDocumentsView = `
+	DROP VIEW IF EXISTS search_documents;
+	CREATE VIEW search_documents AS
+	SELECT i.id AS id, i.title AS title,
+		TRIM(COALESCE(n.content, '') || ' ' || COALESCE(b.description, '') || ' ' || COALESCE(b.notes, '') || ' ' || COALESCE(b.url, '') || ' ' ||
+			COALESCE(pa.text, b.article_text, '') || ' ' || COALESCE(r.ingredients, '') || ' ' || COALESCE(r.instructions, '')) AS body,
+		COALESCE((SELECT GROUP_CONCAT(t.name, ' ') FROM item_tags it JOIN tags t ON t.id = it.tag_id WHERE it.item_id = i.id), '') AS tags
+	FROM items i
+	LEFT JOIN notes n ON n.item_id = i.id
+	LEFT JOIN bookmarks b ON b.item_id = i.id
+	LEFT JOIN page_archives pa ON pa.item_id = i.id
+	LEFT JOIN recipes r ON r.item_id = i.id`
+
+// searchTriggers re-index the items a change affects. ids selects their
+// item ids from the changed row (NEW or OLD).
+var searchTriggers = []struct {
+	name, event, table, ids string
+}{
+	{"search_items_ai", "INSERT", "items", "SELECT NEW.id"},
+	{"search_items_au", "UPDATE OF title", "items", "SELECT NEW.id"},
+	{"search_items_ad", "DELETE", "items", "SELECT OLD.id"},
+	{"search_notes_ai", "INSERT", "notes", "SELECT NEW.item_id"},
+	{"search_notes_au", "UPDATE", "notes", "SELECT NEW.item_id"},
+	{"search_bookmarks_ai", "INSERT", "bookmarks", "SELECT NEW.item_id"},
+	{"search_bookmarks_au", "UPDATE", "bookmarks", "SELECT NEW.item_id"},
+	{"search_page_archives_ai", "INSERT", "page_archives", "SELECT NEW.item_id"},
+	{"search_page_archives_au", "UPDATE", "page_archives", "SELECT NEW.item_id"},
+	{"search_recipes_ai", "INSERT", "recipes", "SELECT NEW.item_id"},
+	{"search_recipes_au", "UPDATE", "recipes", "SELECT NEW.item_id"},
+	{"search_item_tags_ai", "INSERT", "item_tags", "SELECT NEW.item_id"},
+	{"search_item_tags_ad", "DELETE", "item_tags", "SELECT OLD.item_id"},
+	{"search_tags_au", "UPDATE OF name", "tags", "SELECT item_id FROM item_tags WHERE tag_id = NEW.id"},
+}
+
+// searchIndexVersionKey is the system setting holding searchIndexVersion of
+// the build that filled search_index
+const searchIndexVersionKey = "search_index_version"
+
+// searchIndexVersion identifies what search_index holds: a hash of the view
+// and triggers that fill it. Migrations run before the view is replaced, so
+// rows they change are indexed as the old view saw them; a new version
+// fills the index again.
+func searchIndexVersion() string {
+	sum := sha256.Sum256([]byte(searchDocumentsView + fmt.Sprint(searchTriggers)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// createSearchIndex sets up search_index and its triggers, and fills the
+// index when the triggers weren't there to keep it current (when it is new,
+// or after the database was used by a build without FTS5) or when what it
+// holds changed.
+func createSearchIndex() error {
+	_, err := DB.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(title, body, tags, tokenize = 'unicode61 remove_diacritics 2')`)
+	if err != nil {
+		if !strings.Contains(err.Error(), "no such module") {
+			return fmt.Errorf("creating search index: %w", err)
+		}
+		log.Println("SQLite was built without FTS5 (build tag sqlite_fts5), searching without an index")
+		searchEnabled = false
+		// Triggers left by an FTS5 build would make every write fail
+		for _, t := range searchTriggers {
+			if _, err := DB.Exec("DROP TRIGGER IF EXISTS " + t.name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, err := DB.Exec(searchDocumentsView); err != nil {
+		return fmt.Errorf("creating search_documents: %w", err)
+	}
+
+	var n int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'search\\_%' ESCAPE '\\'").Scan(&n); err != nil {
+		return err
+	}
+	version, _ := GetSystemSetting(searchIndexVersionKey)
+	if n != len(searchTriggers) || version != searchIndexVersion() {
+		log.Println("Building the search index")
+		tx, err := DB.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, t := range searchTriggers {
+			stmt := fmt.Sprintf(`
+				DROP TRIGGER IF EXISTS %[1]s;
+				CREATE TRIGGER %[1]s AFTER %[2]s ON %[3]s BEGIN
+					DELETE FROM search_index WHERE rowid IN (%[4]s);
+					INSERT INTO search_index (rowid, title, body, tags)
+						SELECT id, title, body, tags FROM search_documents WHERE id IN (%[4]s);
+				END`, t.name, t.event, t.table, t.ids)
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("creating trigger %s: %w", t.name, err)
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM search_index"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO search_index (rowid, title, body, tags) SELECT id, title, body, tags FROM search_documents"); err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO system_settings (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value",
+			searchIndexVersionKey, searchIndexVersion())
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	searchEnabled = true
+	return nil
+}
+
+// SearchResult is an item matching a full-text search
+type SearchResult struct {
+	ID        int64
+	Type      string // items.type
+	Title     string
+	Snippet   string // the matching part of the item's text
+	Thumbnail string // bookmark or recipe thumbnail, drawing or media file
+	URL       string // bookmarks only
+	Tags      []string
+	CreatedAt string
+}
+
+// searchTypes are the item types search results are shown for
+const searchTypes = "'note', 'bookmark', 'recipe', 'list', 'rated_list', 'drawing', 'media'"
+
+// SearchItems returns up to limit of the user's items matching every word of
+// query, best matches first. Words match by prefix, so "book" finds
+// "bookshelf". Title and tag matches rank above matches in the text.
+// Archived items are left out.
+func SearchItems(userID int64, query string, limit int) ([]SearchResult, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	rows, err := DB.Query(`
+		SELECT i.id, i.type, i.title, i.created_at,
+			snippet(search_index, 1, '', '', '...', 24),
+			COALESCE(NULLIF(b.thumbnail, ''), NULLIF(r.thumbnail, ''), d.file_path, m.file_path, ''),
+			COALESCE(b.url, '')
+		FROM search_index s
+		JOIN items i ON i.id = s.rowid
+		LEFT JOIN bookmarks b ON b.item_id = i.id
+		LEFT JOIN recipes r ON r.item_id = i.id
+		LEFT JOIN drawings d ON d.item_id = i.id
+		LEFT JOIN media m ON m.item_id = i.id
+		WHERE search_index MATCH ? AND i.user_id = ? AND i.deleted_at IS NULL AND i.archived_at IS NULL AND i.type IN (`+searchTypes+`)
+		ORDER BY bm25(search_index, 10.0, 1.0, 15.0)
+		LIMIT ?`, match, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	var ids []int64
+	for rows.Next() {
+		var res SearchResult
+		var createdAt sql.NullString
+		if err := rows.Scan(&res.ID, &res.Type, &res.Title, &createdAt, &res.Snippet, &res.Thumbnail, &res.URL); err != nil {
+			return nil, err
+		}
+		res.CreatedAt = createdAt.String
+		ids = append(ids, res.ID)
+		results = append(results, res)
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+	return results, rows.Err()
+}
+
+// GetSearchResults returns the user's items among ids as search results, in
+// the order of ids. Their snippet is the start of their text.
+func GetSearchResults(userID int64, ids []int64) ([]SearchResult, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := DB.Query(`
+		SELECT i.id, i.type, i.title, i.created_at,
+			SUBSTR(COALESCE(NULLIF(n.content, ''), NULLIF(b.description, ''), r.instructions, ''), 1, 150),
+			COALESCE(NULLIF(b.thumbnail, ''), NULLIF(r.thumbnail, ''), d.file_path, m.file_path, ''),
+			COALESCE(b.url, '')
+		FROM items i
+		LEFT JOIN notes n ON n.item_id = i.id
+		LEFT JOIN bookmarks b ON b.item_id = i.id
+		LEFT JOIN recipes r ON r.item_id = i.id
+		LEFT JOIN drawings d ON d.item_id = i.id
+		LEFT JOIN media m ON m.item_id = i.id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND i.archived_at IS NULL AND i.type IN (`+searchTypes+`) AND i.id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := map[int64]SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		var createdAt sql.NullString
+		if err := rows.Scan(&res.ID, &res.Type, &res.Title, &createdAt, &res.Snippet, &res.Thumbnail, &res.URL); err != nil {
+			return nil, err
+		}
+		res.CreatedAt = createdAt.String
+		byID[res.ID] = res
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, id := range ids {
+		if res, ok := byID[id]; ok {
+			res.Tags = tags[id]
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+// ftsQuery turns what the user typed into an FTS5 query matching every word
+// as a prefix. Words are quoted so FTS5 operators and punctuation in them
+// are taken literally.
+func ftsQuery(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Snippets are short-lived texts for moving something from one device to
+// another, fetched by a short code. They are notes of their own item type,
+// 'snippet', so the note lists and search leave them out, with their code
+// and expiry in snippets. Expired ones are deleted for good, not trashed.
+
+// ErrSnippetCodeTaken is returned by CreateSnippet when the code is in use
+var ErrSnippetCodeTaken = errors.New("snippet code already in use")
+
+// Snippet is a stored snippet
+type Snippet struct {
+	ID            int64
+	Code          string
+	Content       string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	BurnAfterRead bool
+}
+
+// CreateSnippet stores a snippet under code that expires after ttl
+func CreateSnippet(userID int64, code, content string, ttl time.Duration, burnAfterRead bool) (*Snippet, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO items (user_id, title, type) VALUES (?, ?, 'snippet')", userID, snippetTitle(content))
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("INSERT INTO notes (item_id, content) VALUES (?, ?)", id, content); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO snippets (item_id, code, expires_at, burn_after_read)
+		VALUES (?, ?, datetime('now', ?), ?)`, id, code, fmt.Sprintf("+%d seconds", int64(ttl/time.Second)), burnAfterRead)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		return nil, ErrSnippetCodeTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := getSnippet(tx, userID, code)
+	if err != nil {
+		return nil, err
+	}
+	return s, tx.Commit()
+}
+
+// ReadSnippet returns the user's snippet with code unless it has expired.
+// A snippet to burn after reading is deleted as it is returned.
+func ReadSnippet(userID int64, code string) (*Snippet, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s, err := getSnippet(tx, userID, code)
+	if err != nil {
+		return nil, err
+	}
+	if s.BurnAfterRead {
+		if _, err := tx.Exec("DELETE FROM items WHERE id = ?", s.ID); err != nil {
+			return nil, err
+		}
+	}
+	return s, tx.Commit()
+}
+
+// DeleteSnippet deletes the user's snippet with code. It returns
+// sql.ErrNoRows if there is none.
+func DeleteSnippet(userID int64, code string) error {
+	res, err := DB.Exec(`
+		DELETE FROM items WHERE user_id = ? AND type = 'snippet'
+			AND id IN (SELECT item_id FROM snippets WHERE code = ?)`, userID, code)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteExpiredSnippets deletes the snippets of every user that have
+// expired and returns how many there were
+func DeleteExpiredSnippets() (int64, error) {
+	res, err := DB.Exec(`
+		DELETE FROM items WHERE type = 'snippet'
+			AND id IN (SELECT item_id FROM snippets WHERE expires_at <= datetime('now'))`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func getSnippet(tx *sql.Tx, userID int64, code string) (*Snippet, error) {
+	var s Snippet
+	err := tx.QueryRow(`
+		SELECT i.id, sn.code, COALESCE(n.content, ''), i.created_at, sn.expires_at, sn.burn_after_read
+		FROM snippets sn
+		JOIN items i ON i.id = sn.item_id
+		JOIN notes n ON n.item_id = i.id
+		WHERE sn.code = ? AND i.user_id = ? AND sn.expires_at > datetime('now')`, code, userID).
+		Scan(&s.ID, &s.Code, &s.Content, &s.CreatedAt, &s.ExpiresAt, &s.BurnAfterRead)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// snippetTitle is the first line of a snippet, shortened, as its item title
+func snippetTitle(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	line = strings.TrimSpace(line)
+	if r := []rune(line); len(r) > 60 {
+		line = string(r[:60]) + "…"
+	}
+	if line == "" {
+		return "Snippet"
+	}
+	return line
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sort is the order of a list query's items. Pinned items come first in
+// every order.
+type Sort string
+
+const (
+	SortCreated Sort = "created" // newest first, the default
+	SortUpdated Sort = "updated" // most recently changed first
+	SortTitle   Sort = "title"   // alphabetically
+	SortManual  Sort = "manual"  // by items.sort_order, unordered items last
+)
+
+// Sorts are the orders a list can be shown in
+var Sorts = []Sort{SortCreated, SortUpdated, SortTitle, SortManual}
+
+// ParseSort returns the order named s, or false if there is none
+func ParseSort(s string) (Sort, bool) {
+	for _, sort := range Sorts {
+		if string(sort) == s {
+			return sort, true
+		}
+	}
+	return SortCreated, false
+}
+
+// sql returns the ORDER BY clause that puts items i in the order
+func (s Sort) sql() string {
+	var order string
+	switch s {
+	case SortUpdated:
+		order = "COALESCE(i.updated_at, i.created_at) DESC, i.id DESC"
+	case SortTitle:
+		order = "i.title COLLATE NOCASE, i.id"
+	case SortManual:
+		order = "i.sort_order IS NULL, i.sort_order, i.created_at DESC, i.id DESC"
+	default:
+		order = "i.created_at DESC, i.id DESC"
+	}
+	return " ORDER BY " + pinnedFirst + order
+}
+
+// ReorderItems puts the user's items ids in that order for SortManual. The
+// items take the places the ones among them already had, and any that had
+// none come after every ordered item, so that reordering one page of a list
+// leaves the items of the other pages where they were. It returns
+// sql.ErrNoRows if one of the items is not the user's or is in the trash.
+func ReorderItems(userID int64, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > MaxBulkItems {
+		return fmt.Errorf("at most %d items at a time", MaxBulkItems)
+	}
+	order := make([]int64, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			order = append(order, id)
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := make([]interface{}, 0, len(order)+1)
+	args = append(args, userID)
+	for _, id := range order {
+		args = append(args, id)
+	}
+	in := "?" + strings.Repeat(", ?", len(order)-1)
+	rows, err := tx.Query("SELECT sort_order FROM items WHERE user_id = ? AND deleted_at IS NULL AND id IN ("+in+")", args...)
+	if err != nil {
+		return err
+	}
+	var slots []int64
+	count := 0
+	for rows.Next() {
+		var slot sql.NullInt64
+		if err := rows.Scan(&slot); err != nil {
+			rows.Close()
+			return err
+		}
+		count++
+		if slot.Valid {
+			slots = append(slots, slot.Int64)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if count != len(order) {
+		return sql.ErrNoRows
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	if len(slots) < len(order) {
+		var last int64
+		if err := tx.QueryRow("SELECT COALESCE(MAX(sort_order), 0) FROM items WHERE user_id = ?", userID).Scan(&last); err != nil {
+			return err
+		}
+		for len(slots) < len(order) {
+			last++
+			slots = append(slots, last)
+		}
+	}
+	for i, id := range order {
+		if _, err := tx.Exec("UPDATE items SET sort_order = ? WHERE id = ?", slots[i], id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CountUsers returns the number of registered users
+func CountUsers() (int64, error) {
+	var count int64
+	err := DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+// CountItemsByType returns how many items of each type exist across all users
+func CountItemsByType() (map[string]int64, error) {
+	rows, err := DB.Query("SELECT type, COUNT(*) FROM items GROUP BY type")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var itemType string
+		var count int64
+		if err := rows.Scan(&itemType, &count); err != nil {
+			return nil, err
+		}
+		counts[itemType] = count
+	}
+	return counts, nil
+}
+
+// JobCount is the number of jobs of one type in one status
+type JobCount struct {
+	Type   string
+	Status string
+	Count  int64
+}
+
+// CountJobsByStatus returns the number of queued, running and finished jobs per type
+func CountJobsByStatus() ([]JobCount, error) {
+	rows, err := DB.Query("SELECT type, status, COUNT(*) FROM jobs GROUP BY type, status ORDER BY type, status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []JobCount
+	for rows.Next() {
+		var c JobCount
+		if err := rows.Scan(&c.Type, &c.Status, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, nil
+}
+
+// UserStats sums up one user's items for the dashboard and /api/stats
+type UserStats struct {
+	Counts       map[string]int64 `json:"counts"` // items by type, trash left out
+	Total        int64            `json:"total"`
+	InTrash      int64            `json:"in_trash"`
+	AddedPerWeek []WeekCount      `json:"added_per_week"` // oldest week first
+	TopTags      []TagCount       `json:"top_tags"`
+	Reading      *ReadingStats    `json:"reading"`
+	// StorageBytes is the size of the user's uploaded files, filled in by
+	// the caller, which knows where they are
+	StorageBytes int64 `json:"storage_bytes"`
+	Files        int   `json:"files"`
+}
+
+// WeekCount is the number of items added in the week starting on Monday
+// Week
+type WeekCount struct {
+	Week  string `json:"week"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
+
+// statsTypes are the item types counted in UserStats
+const statsTypes = "'note', 'bookmark', 'recipe', 'cookbook', 'list', 'rated_list', 'drawing', 'media'"
+
+// GetUserStats counts a user's items by type, those added in each of the
+// last weeks weeks (up to now) and their topTags most used tags, and sums up
+// their read-later queue
+func GetUserStats(userID int64, weeks, topTags int, now time.Time) (*UserStats, error) {
+	stats := &UserStats{Counts: map[string]int64{}}
+	rows, err := DB.Query(`
+		SELECT type, deleted_at IS NOT NULL, COUNT(*)
+		FROM items
+		WHERE user_id = ? AND type IN (`+statsTypes+`)
+		GROUP BY type, deleted_at IS NOT NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemType string
+		var trashed bool
+		var n int64
+		if err := rows.Scan(&itemType, &trashed, &n); err != nil {
+			return nil, err
+		}
+		if trashed {
+			stats.InTrash += n
+			continue
+		}
+		stats.Counts[itemType] = n
+		stats.Total += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.AddedPerWeek, err = countAddedPerWeek(userID, weeks, now); err != nil {
+		return nil, err
+	}
+	if stats.Reading, err = GetReadingStats(userID, weeks, now); err != nil {
+		return nil, err
+	}
+
+	tags, err := GetTagsWithCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > topTags {
+		tags = tags[:topTags]
+	}
+	stats.TopTags = append([]TagCount{}, tags...)
+	return stats, nil
+}
+
+// countAddedPerWeek returns how many of the user's items were created in
+// each of the last weeks weeks, including weeks without any
+func countAddedPerWeek(userID int64, weeks int, now time.Time) ([]WeekCount, error) {
+	first := firstWeek(now, weeks)
+
+	// '-6 days', 'weekday 1' gives the Monday on or before a date
+	rows, err := DB.Query(`
+		SELECT date(created_at, '-6 days', 'weekday 1') AS week, COUNT(*)
+		FROM items
+		WHERE user_id = ? AND type IN (`+statsTypes+`) AND deleted_at IS NULL AND created_at >= ?
+		GROUP BY week`, userID, first.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int64{}
+	for rows.Next() {
+		var week sql.NullString
+		var n int64
+		if err := rows.Scan(&week, &n); err != nil {
+			return nil, err
+		}
+		counts[week.String] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]WeekCount, weeks)
+	for i := range result {
+		week := first.AddDate(0, 0, 7*i).Format("2006-01-02")
+		result[i] = WeekCount{Week: week, Count: counts[week]}
+	}
+	return result, nil
+}
+
+// firstWeek returns the Monday starting the first of the last weeks weeks
+// up to now, in UTC like the times in the database
+func firstWeek(now time.Time, weeks int) time.Time {
+	now = now.UTC()
+	monday := time.Date(now.Year(), now.Month(), now.Day()-(int(now.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	return monday.AddDate(0, 0, -7*(weeks-1))
+}
+
+// GetUserFiles returns the paths of the files of all the user's items,
+// those in the trash included
+func GetUserFiles(userID int64) ([]string, error) {
+	ids := "SELECT id FROM items WHERE user_id = ?"
+	args := make([]interface{}, itemFilesArgs)
+	for i := range args {
+		args[i] = userID
+	}
+	rows, err := DB.Query(fmt.Sprintf(itemFilesQuery, ids), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var path sql.NullString
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		if path.String != "" {
+			files = append(files, path.String)
+		}
+	}
+	return files, rows.Err()
+}
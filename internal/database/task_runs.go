@@ -0,0 +1,73 @@
+package database
+
+import "database/sql"
+
+// Scheduled task run statuses
+const (
+	TaskRunning = "running"
+	TaskOK      = "ok"
+	TaskFailed  = "failed"
+)
+
+// TaskRun is one run of a scheduled maintenance task.
+type TaskRun struct {
+	ID         int64  `json:"id"`
+	Task       string `json:"task"`
+	Status     string `json:"status"`
+	Result     string `json:"result"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+}
+
+// StartTaskRun records that a task has started and returns the run ID.
+func StartTaskRun(task string) (int64, error) {
+	result, err := DB.Exec("INSERT INTO task_runs (task, status) VALUES (?, ?)", task, TaskRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func FinishTaskRun(id int64, status, result string) error {
+	_, err := DB.Exec("UPDATE task_runs SET status = ?, result = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?", status, result, id)
+	return err
+}
+
+// FailInterruptedTaskRuns marks runs that were cut short by a restart as failed.
+func FailInterruptedTaskRuns() error {
+	_, err := DB.Exec("UPDATE task_runs SET status = ?, result = 'interrupted by a restart', finished_at = CURRENT_TIMESTAMP WHERE status = ?",
+		TaskFailed, TaskRunning)
+	return err
+}
+
+// GetLastTaskRun returns the latest run of a task, or sql.ErrNoRows if it never ran.
+func GetLastTaskRun(task string) (*TaskRun, error) {
+	var r TaskRun
+	var finishedAt sql.NullString
+	err := DB.QueryRow(`
+		SELECT id, task, status, COALESCE(result, ''), started_at, finished_at
+		FROM task_runs WHERE task = ? ORDER BY id DESC LIMIT 1`, task).Scan(&r.ID, &r.Task, &r.Status, &r.Result, &r.StartedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.FinishedAt = finishedAt.String
+	return &r, nil
+}
+
+// PruneTaskRuns deletes all but the latest keep runs of a task.
+func PruneTaskRuns(task string, keep int) error {
+	_, err := DB.Exec(`
+		DELETE FROM task_runs WHERE task = ? AND id NOT IN (
+			SELECT id FROM task_runs WHERE task = ? ORDER BY id DESC LIMIT ?
+		)`, task, task, keep)
+	return err
+}
+
+// DeleteExpiredSessions removes sessions past their expiry and returns how many there were.
+func DeleteExpiredSessions() (int64, error) {
+	result, err := DB.Exec("DELETE FROM sessions WHERE datetime(expires_at) <= datetime('now')")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
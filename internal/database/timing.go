@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"infokeep/internal/metrics"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// timedSQLiteDriver is the plain sqlite3 driver with query timing
+const timedSQLiteDriver = "sqlite3_timed"
+
+func init() {
+	sql.Register(timedSQLiteDriver, timedDriver{&sqlite3.SQLiteDriver{}})
+}
+
+var queryDuration = metrics.NewHistogramVec("infokeep_db_query_duration_seconds",
+	"Time spent executing database statements.",
+	[]float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	"op")
+
+// timedDriver wraps a database driver so every statement's duration is
+// recorded in the query metrics, labelled exec or query, and writes are
+// queued (see pool.go).
+type timedDriver struct {
+	driver.Driver
+}
+
+func (d timedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{Conn: conn}, nil
+}
+
+type timedConn struct {
+	driver.Conn
+	inTx   bool // a transaction is open
+	queued bool // the transaction holds the write queue
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if !c.inTx && isWrite(query) && acquireWrite(ctx) {
+		defer releaseWrite()
+	}
+	start := time.Now()
+	res, err := execer.ExecContext(ctx, query, args)
+	queryDuration.Observe(time.Since(start).Seconds(), "exec")
+	return res, err
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	queued := !c.inTx && isWrite(query) && acquireWrite(ctx)
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	queryDuration.Observe(time.Since(start).Seconds(), "query")
+	if queued {
+		if err != nil {
+			releaseWrite()
+			return nil, err
+		}
+		return &queuedRows{Rows: rows}, nil
+	}
+	return rows, err
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx takes the write queue for the transaction: with _txlock=immediate
+// every transaction but a read-only one takes the write lock
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.queued = !opts.ReadOnly && acquireWrite(ctx)
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		if c.queued {
+			c.queued = false
+			releaseWrite()
+		}
+		return nil, err
+	}
+	c.inTx = true
+	return &queuedTx{Tx: tx, conn: c}, nil
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
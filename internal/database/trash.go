@@ -0,0 +1,153 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Deleting an item moves it to the trash: items.deleted_at is set and every
+// query of items leaves it out, until it is restored or purged. Purging
+// deletes the item for good (its rows in other tables go with it through
+// ON DELETE CASCADE) and returns the uploaded files it used, which the
+// caller removes.
+
+// TrashedItem is an item in the trash
+type TrashedItem struct {
+	ID        int64
+	Type      string
+	Title     string
+	DeletedAt time.Time
+}
+
+// TrashItem moves one of the user's items to the trash. It returns
+// sql.ErrNoRows if the user has no such item outside the trash.
+func TrashItem(userID, id int64) error {
+	res, err := DB.Exec("UPDATE items SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND deleted_at IS NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetTrash returns the items in the user's trash, most recently deleted
+// first
+func GetTrash(userID int64) ([]TrashedItem, error) {
+	rows, err := DB.Query(`
+		SELECT id, type, title, deleted_at
+		FROM items
+		WHERE user_id = ? AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TrashedItem
+	for rows.Next() {
+		var it TrashedItem
+		if err := rows.Scan(&it.ID, &it.Type, &it.Title, &it.DeletedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// RestoreItem takes an item out of the user's trash. It returns
+// sql.ErrNoRows if the item is not in the trash.
+func RestoreItem(userID, id int64) error {
+	res, err := DB.Exec("UPDATE items SET deleted_at = NULL WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeItem deletes an item in the user's trash for good and returns the
+// files it used. It returns sql.ErrNoRows if the item is not in the trash.
+func PurgeItem(userID, id int64) ([]string, error) {
+	files, n, err := purgeItems("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID)
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return files, err
+}
+
+// EmptyTrash deletes all items in the user's trash for good. It returns the
+// files they used and how many there were.
+func EmptyTrash(userID int64) ([]string, int64, error) {
+	return purgeItems("user_id = ? AND deleted_at IS NOT NULL", userID)
+}
+
+// PurgeExpiredTrash deletes the items of all users that have been in the
+// trash for more than days days. It returns the files they used and how
+// many there were.
+func PurgeExpiredTrash(days int) ([]string, int64, error) {
+	return purgeItems("deleted_at < datetime('now', ?)", fmt.Sprintf("-%d days", days))
+}
+
+// itemFilesQuery selects the files of the items in the subquery %[1]s
+const itemFilesQuery = `
+	SELECT file_path FROM drawings WHERE item_id IN (%[1]s)
+	UNION ALL SELECT file_path FROM media WHERE item_id IN (%[1]s)
+	UNION ALL SELECT thumbnail FROM recipes WHERE item_id IN (%[1]s)
+	UNION ALL SELECT file_path FROM recipe_images WHERE recipe_id IN (%[1]s)
+	UNION ALL SELECT image_path FROM rated_list_items WHERE rated_list_id IN (%[1]s)
+	UNION ALL SELECT cover_image FROM cookbooks WHERE item_id IN (%[1]s)
+	UNION ALL SELECT file_path FROM attachments WHERE item_id IN (%[1]s)`
+
+// itemFilesArgs is how many times the arguments of the subquery go into
+// itemFilesQuery
+var itemFilesArgs = strings.Count(itemFilesQuery, "%[1]s")
+
+// purgeItems deletes the items matching where, returning their files
+func purgeItems(where string, args ...interface{}) ([]string, int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	ids := "SELECT id FROM items WHERE " + where
+	var allArgs []interface{}
+	for i := 0; i < itemFilesArgs; i++ {
+		allArgs = append(allArgs, args...)
+	}
+	rows, err := tx.Query(fmt.Sprintf(itemFilesQuery, ids), allArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	var files []string
+	for rows.Next() {
+		var path sql.NullString
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		if path.String != "" {
+			files = append(files, path.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	res, err := tx.Exec("DELETE FROM items WHERE "+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	n, _ := res.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+	return files, n, nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"time"
+
+	"infokeep/internal/models"
+)
+
+// Item views are kept like a ring buffer: one row per item, moved to the
+// front each time the item is viewed again, and only the latest
+// ViewsKept of each user, so the table doesn't grow with use.
+
+// ViewsKept is how many of their latest item views a user keeps
+const ViewsKept = 100
+
+// RecordView notes that the user viewed one of their items now. Items of
+// other users are ignored.
+func RecordView(userID, itemID int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`
+		INSERT INTO item_views (user_id, item_id, viewed_at)
+		SELECT ?, id, ? FROM items WHERE id = ? AND user_id = ?
+		ON CONFLICT(user_id, item_id) DO UPDATE SET viewed_at = excluded.viewed_at`,
+		userID, time.Now().UTC(), itemID, userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		DELETE FROM item_views WHERE user_id = ? AND item_id NOT IN (
+			SELECT item_id FROM item_views WHERE user_id = ? ORDER BY viewed_at DESC LIMIT ?)`,
+		userID, userID, ViewsKept)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetRecentViews returns up to limit of the items the user viewed last,
+// the latest first, leaving out items in the trash
+func GetRecentViews(userID int64, limit int) ([]models.ViewedItem, error) {
+	rows, err := DB.Query(`
+		SELECT i.id, i.type, i.title, COALESCE(b.url, ''), v.viewed_at
+		FROM item_views v
+		JOIN items i ON i.id = v.item_id
+		LEFT JOIN bookmarks b ON i.id = b.item_id
+		WHERE v.user_id = ? AND i.deleted_at IS NULL
+		ORDER BY v.viewed_at DESC
+		LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.ViewedItem
+	var ids []int64
+	for rows.Next() {
+		var item models.ViewedItem
+		if err := rows.Scan(&item.ID, &item.Type, &item.Title, &item.URL, &item.ViewedAt); err != nil {
+			return nil, err
+		}
+		ids = append(ids, item.ID)
+		results = append(results, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := GetTagsForItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+	}
+	return results, nil
+}
@@ -0,0 +1,194 @@
+// Package datadir locates the files infokeep keeps between runs. The
+// database, uploads and finished exports all live in one data directory,
+// DATA_DIR ("data" in the working directory by default), so the binary
+// itself carries nothing but code, templates and static files and can be
+// replaced by a newer one without touching any data.
+//
+// Older versions kept infokeep.db in the working directory and uploads under
+// web/static; Init moves those into the data directory on the first run.
+package datadir
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Dir is the data directory
+var Dir = dataDir()
+
+func dataDir() string {
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		return dir
+	}
+	return "data"
+}
+
+// Path returns the path of a file in the data directory
+func Path(elem ...string) string {
+	return filepath.Join(append([]string{Dir}, elem...)...)
+}
+
+// DB returns the path of the database
+func DB() string {
+	return Path("infokeep.db")
+}
+
+// Uploads returns the directory of uploaded files, served under
+// /static/uploads/
+func Uploads() string {
+	return Path("uploads")
+}
+
+// RatedItems returns the directory of rated list entries' images, served
+// under /static/rated_items/
+func RatedItems() string {
+	return Path("rated_items")
+}
+
+// served are the data directory's folders that are served under /static/
+var served = []string{"uploads", "rated_items"}
+
+// File returns the path of the file served at a /static/uploads/ or
+// /static/rated_items/ URL path, or false if the path is not one of them
+func File(urlPath string) (string, bool) {
+	rel, ok := strings.CutPrefix(path.Clean(urlPath), "/static/")
+	if !ok {
+		return "", false
+	}
+	for _, dir := range served {
+		if name, ok := strings.CutPrefix(rel, dir+"/"); ok && name != "" {
+			return Path(dir, filepath.FromSlash(name)), true
+		}
+	}
+	return "", false
+}
+
+// legacyDB and legacyDirs are where older versions kept their data,
+// relative to the working directory
+var (
+	legacyDB   = "infokeep.db"
+	legacyDirs = map[string]string{
+		filepath.Join("web", "static", "uploads"):     "uploads",
+		filepath.Join("web", "static", "rated_items"): "rated_items",
+	}
+)
+
+// Init creates the data directory and its folders and moves the data of
+// the old layout into it. The old database is only moved into a data
+// directory without one, so a later run never replaces it.
+func Init() error {
+	for _, dir := range served {
+		if err := os.MkdirAll(Path(dir), 0755); err != nil {
+			return fmt.Errorf("creating the data directory: %w", err)
+		}
+	}
+
+	if same, _ := samePath(legacyDB, DB()); !same && exists(legacyDB) && !exists(DB()) {
+		log.Printf("Moving %s into the data directory %s", legacyDB, Dir)
+		// The write-ahead log holds changes not yet in the database file
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			if exists(legacyDB + suffix) {
+				if err := move(legacyDB+suffix, DB()+suffix); err != nil {
+					return fmt.Errorf("moving the database: %w", err)
+				}
+			}
+		}
+	}
+
+	for from, to := range legacyDirs {
+		if same, _ := samePath(from, Path(to)); same {
+			continue
+		}
+		entries, err := os.ReadDir(from)
+		if err != nil {
+			continue // nothing to move
+		}
+		moved := 0
+		for _, e := range entries {
+			// Leave .gitkeep and the like, and files already moved
+			dst := Path(to, e.Name())
+			if strings.HasPrefix(e.Name(), ".") || exists(dst) {
+				continue
+			}
+			if err := move(filepath.Join(from, e.Name()), dst); err != nil {
+				return fmt.Errorf("moving %s: %w", from, err)
+			}
+			moved++
+		}
+		if moved > 0 {
+			log.Printf("Moved %d files from %s to %s", moved, from, Path(to))
+		}
+	}
+	return nil
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// samePath reports whether two paths are the same file or directory
+func samePath(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	return absA == absB, err
+}
+
+// move renames a file or directory, or copies it where renaming can't work:
+// across file systems, or out of a file bind-mounted into a container. A
+// source that can't be removed afterwards is left where it was.
+func move(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	if err := copyTree(from, to); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(from); err != nil {
+		log.Printf("Copied %s to %s but could not remove it: %v", from, to, err)
+	}
+	return nil
+}
+
+func copyTree(from, to string) error {
+	return filepath.WalkDir(from, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(to, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+		return copyFile(p, dst)
+	})
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
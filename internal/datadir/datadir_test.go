@@ -0,0 +1,117 @@
+package datadir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useDir runs a test in an empty working directory with the data directory
+// dir
+func useDir(t *testing.T, dir string) {
+	t.Chdir(t.TempDir())
+	old := Dir
+	Dir = dir
+	t.Cleanup(func() { Dir = old })
+}
+
+func write(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestInitMovesLegacyLayout(t *testing.T) {
+	useDir(t, "data")
+	write(t, "infokeep.db", "db")
+	write(t, "infokeep.db-wal", "wal")
+	write(t, "web/static/uploads/photo.jpg", "photo")
+	write(t, "web/static/uploads/.gitkeep", "")
+	write(t, "web/static/rated_items/item_1.png", "item")
+
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"data/infokeep.db":            "db",
+		"data/infokeep.db-wal":        "wal",
+		"data/uploads/photo.jpg":      "photo",
+		"data/rated_items/item_1.png": "item",
+	} {
+		if got := read(t, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	for _, gone := range []string{"infokeep.db", "infokeep.db-wal", "web/static/uploads/photo.jpg"} {
+		if _, err := os.Stat(gone); err == nil {
+			t.Errorf("%s is still there", gone)
+		}
+	}
+	if _, err := os.Stat("data/uploads/.gitkeep"); err == nil {
+		t.Error(".gitkeep was moved")
+	}
+}
+
+func TestInitKeepsData(t *testing.T) {
+	useDir(t, "data")
+	write(t, "data/infokeep.db", "new")
+	write(t, "data/uploads/photo.jpg", "new photo")
+	write(t, "infokeep.db", "old")
+	write(t, "web/static/uploads/photo.jpg", "old photo")
+
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, "data/infokeep.db"); got != "new" {
+		t.Errorf("database = %q, the old one replaced it", got)
+	}
+	if got := read(t, "data/uploads/photo.jpg"); got != "new photo" {
+		t.Errorf("upload = %q, the old one replaced it", got)
+	}
+	if _, err := os.Stat("data/rated_items"); err != nil {
+		t.Errorf("rated_items was not created: %v", err)
+	}
+}
+
+// TestInitInPlace keeps the database where it is with DATA_DIR=.
+func TestInitInPlace(t *testing.T) {
+	useDir(t, ".")
+	write(t, "infokeep.db", "db")
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, "infokeep.db"); got != "db" {
+		t.Errorf("database = %q", got)
+	}
+}
+
+func TestFile(t *testing.T) {
+	useDir(t, "data")
+	tests := map[string]string{
+		"/static/uploads/a.png":          filepath.Join("data", "uploads", "a.png"),
+		"/static/rated_items/item_1.png": filepath.Join("data", "rated_items", "item_1.png"),
+		"/static/uploads/../css/x.css":   "",
+		"/static/uploads/":               "",
+		"/static/js/tags.js":             "",
+		"https://example.com/a.png":      "",
+	}
+	for url, want := range tests {
+		got, ok := File(url)
+		if ok != (want != "") || got != want {
+			t.Errorf("File(%q) = %q, %v, want %q", url, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package extensions holds the extension points features can plug into
+// without changes to the handlers: hooks run when items are created or
+// updated, recipe parsers for sites the built-in parser handles badly, and
+// importers for files exported from other apps.
+//
+// Extensions are separate packages that register themselves from an init
+// function and are compiled in with a blank import in main.go:
+//
+//	import _ "infokeep/internal/plugins/netscape"
+//
+// Registering the same name twice panics, like jobs.Register does.
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Item is the item a hook is run for
+type Item struct {
+	UserID int64
+	ID     int64
+	Type   string // items.type: "bookmark", "note", "recipe", ...
+	Title  string
+}
+
+// ItemHook is run after items are created or updated. Either function may
+// be nil. Hooks run in the request that made the change, so anything slow
+// (fetching, sending) belongs in a goroutine of their own.
+type ItemHook struct {
+	Name    string
+	Created func(ctx context.Context, item Item)
+	Updated func(ctx context.Context, item Item)
+}
+
+var (
+	mu        sync.RWMutex
+	itemHooks []ItemHook
+	names     = map[string]bool{}
+)
+
+// register claims name for an extension of the given kind
+func register(kind, name string) {
+	if name == "" {
+		panic(fmt.Sprintf("extensions: %s without a name", kind))
+	}
+	key := kind + "/" + name
+	if names[key] {
+		panic(fmt.Sprintf("extensions: %s %q registered twice", kind, name))
+	}
+	names[key] = true
+}
+
+// RegisterItemHook adds a hook run for every created or updated item
+func RegisterItemHook(h ItemHook) {
+	mu.Lock()
+	defer mu.Unlock()
+	register("item hook", h.Name)
+	itemHooks = append(itemHooks, h)
+}
+
+// ItemCreated runs the Created function of every item hook
+func ItemCreated(ctx context.Context, item Item) {
+	runItemHooks(ctx, item, "created", func(h ItemHook) func(context.Context, Item) { return h.Created })
+}
+
+// ItemUpdated runs the Updated function of every item hook
+func ItemUpdated(ctx context.Context, item Item) {
+	runItemHooks(ctx, item, "updated", func(h ItemHook) func(context.Context, Item) { return h.Updated })
+}
+
+func runItemHooks(ctx context.Context, item Item, event string, fn func(ItemHook) func(context.Context, Item)) {
+	mu.RLock()
+	hooks := itemHooks
+	mu.RUnlock()
+	for _, h := range hooks {
+		if f := fn(h); f != nil {
+			runHook(ctx, h.Name, event, item, f)
+		}
+	}
+}
+
+// runHook calls a hook, so that a broken one only logs instead of failing
+// the request that triggered it
+func runHook(ctx context.Context, name, event string, item Item, f func(context.Context, Item)) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("Extension %s panicked on %s %d %s: %v", name, item.Type, item.ID, event, err)
+		}
+	}()
+	f(ctx, item)
+}
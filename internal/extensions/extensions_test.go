@@ -0,0 +1,56 @@
+package extensions
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestItemHooks(t *testing.T) {
+	var created, updated []string
+	RegisterItemHook(ItemHook{
+		Name:    "broken",
+		Created: func(context.Context, Item) { panic("boom") },
+	})
+	RegisterItemHook(ItemHook{
+		Name:    "recorder",
+		Created: func(_ context.Context, item Item) { created = append(created, item.Title) },
+		Updated: func(_ context.Context, item Item) { updated = append(updated, item.Title) },
+	})
+
+	// The panicking hook must not keep the others from running
+	ItemCreated(context.Background(), Item{ID: 1, Type: "note", Title: "first"})
+	ItemUpdated(context.Background(), Item{ID: 1, Type: "note", Title: "renamed"})
+	if len(created) != 1 || created[0] != "first" {
+		t.Errorf("created hooks saw %v, want [first]", created)
+	}
+	if len(updated) != 1 || updated[0] != "renamed" {
+		t.Errorf("updated hooks saw %v, want [renamed]", updated)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a hook name twice did not panic")
+		}
+	}()
+	RegisterItemHook(ItemHook{Name: "recorder"})
+}
+
+func TestRecipeParsers(t *testing.T) {
+	RegisterRecipeParser(RecipeParser{
+		Name:  "example",
+		Match: func(u *url.URL) bool { return u.Hostname() == "recipes.example.com" },
+		Parse: func(context.Context, *url.URL, *html.Node) (*Recipe, error) { return &Recipe{Title: "Soup"}, nil },
+	})
+
+	u, _ := url.Parse("https://recipes.example.com/soup")
+	if parsers := RecipeParsers(u); len(parsers) != 1 || parsers[0].Name != "example" {
+		t.Errorf("RecipeParsers(%s) = %v, want the example parser", u, parsers)
+	}
+	u, _ = url.Parse("https://other.example.com/soup")
+	if parsers := RecipeParsers(u); len(parsers) != 0 {
+		t.Errorf("RecipeParsers(%s) = %v, want none", u, parsers)
+	}
+}
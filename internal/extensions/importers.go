@@ -0,0 +1,73 @@
+package extensions
+
+import (
+	"context"
+	"io"
+	"sort"
+	"time"
+)
+
+// Importer reads a file exported from another app. The items it finds are
+// added like those of a backup (Settings → Import Data, merge mode): items
+// identical to existing ones are skipped.
+type Importer struct {
+	Name        string // used in forms, e.g. "netscape"
+	Description string // shown on the settings page, e.g. "Browser bookmarks (HTML)"
+	Accept      string // the file input's accept attribute, e.g. ".html,.htm"
+	Import      func(ctx context.Context, r io.Reader) (*Import, error)
+}
+
+// Import is what an importer found in a file
+type Import struct {
+	Bookmarks []ImportedBookmark
+	Notes     []ImportedNote
+}
+
+type ImportedBookmark struct {
+	Title       string
+	URL         string
+	Description string
+	Tags        []string
+	ReadLater   bool      // goes into the read-later queue
+	Read        bool      // and is marked read there
+	Created     time.Time // when it was bookmarked, if known
+}
+
+type ImportedNote struct {
+	Title   string
+	Content string
+	Tags    []string
+}
+
+var importers = map[string]Importer{}
+
+// RegisterImporter adds an importer to the settings page
+func RegisterImporter(i Importer) {
+	if i.Import == nil {
+		panic("extensions: importer " + i.Name + " needs Import")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	register("importer", i.Name)
+	importers[i.Name] = i
+}
+
+// Importers returns the registered importers sorted by description
+func Importers() []Importer {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Importer, 0, len(importers))
+	for _, i := range importers {
+		list = append(list, i)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Description < list[b].Description })
+	return list
+}
+
+// LookupImporter returns the importer registered as name
+func LookupImporter(name string) (Importer, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	i, ok := importers[name]
+	return i, ok
+}
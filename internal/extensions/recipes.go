@@ -0,0 +1,54 @@
+package extensions
+
+import (
+	"context"
+	"net/url"
+
+	"golang.org/x/net/html"
+)
+
+// Recipe is a recipe found on a web page
+type Recipe struct {
+	Title        string   `json:"title"`
+	Ingredients  []string `json:"ingredients"`
+	Instructions string   `json:"instructions"`
+	Image        string   `json:"image"`
+	Language     string   `json:"language"`
+}
+
+// RecipeParser extracts recipes from the pages of particular sites. It is
+// tried before the built-in parser (JSON-LD, microdata, then HTML
+// heuristics) for pages Match accepts. Parse gets the fetched and parsed
+// page; when it returns nil or an error the built-in parser is used.
+type RecipeParser struct {
+	Name  string
+	Match func(u *url.URL) bool
+	Parse func(ctx context.Context, u *url.URL, doc *html.Node) (*Recipe, error)
+}
+
+var recipeParsers []RecipeParser
+
+// RegisterRecipeParser adds a parser for recipe imports
+func RegisterRecipeParser(p RecipeParser) {
+	if p.Match == nil || p.Parse == nil {
+		panic("extensions: recipe parser " + p.Name + " needs Match and Parse")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	register("recipe parser", p.Name)
+	recipeParsers = append(recipeParsers, p)
+}
+
+// RecipeParsers returns the parsers that accept pages of u, in the order
+// they were registered
+func RecipeParsers(u *url.URL) []RecipeParser {
+	mu.RLock()
+	defer mu.RUnlock()
+	var matching []RecipeParser
+	for _, p := range recipeParsers {
+		if p.Match(u) {
+			matching = append(matching, p)
+		}
+	}
+	return matching
+}
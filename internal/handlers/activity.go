@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"infokeep/internal/database"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Everything the server fetches on a user's behalf, and every request made
+// with a user's API token, goes into their activity log (Settings → Activity
+// log) and the server log, so users can see what was done in their name and
+// admins can spot abuse.
+
+// activityRetentionDays is how long activity log entries are kept
+const activityRetentionDays = 90
+
+// withUserID returns ctx carrying userID, for work done for a user outside
+// of one of their requests (background jobs)
+func withUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// recordActivity writes an entry to the user's activity log and the server
+// log. Entries without a user (e.g. from scheduled tasks) are only logged.
+func recordActivity(userID int64, e database.ActivityEntry) {
+	result := http.StatusText(e.Status)
+	if e.Error != "" {
+		result = e.Error
+	}
+	log.Printf("Activity: user %d %s %s %s: %s (%dms)", userID, e.Kind, e.Action, e.Target, result, e.DurationMs)
+	if userID == 0 {
+		return
+	}
+	if err := database.RecordActivity(userID, e); err != nil {
+		log.Printf("Failed to record activity for user %d: %v", userID, err)
+	}
+}
+
+// activityTransport records each request of an outbound fetch (redirects
+// included) in the activity log of the user in the request's context. Unless
+// it calls a service, it applies the fetch policy and proxy of
+// fetch_policy.go.
+type activityTransport struct {
+	action string
+	// service is set for calls to a service the admin configured, such as
+	// SUMMARIZE_URL, which the policy for the URLs of users doesn't apply to
+	service bool
+}
+
+// fetchTransport is the Transport of HTTP clients that fetch URLs for users.
+// action says what the fetch is for, e.g. "thumbnail".
+func fetchTransport(action string) http.RoundTripper {
+	return activityTransport{action: action}
+}
+
+// serviceTransport is the Transport of HTTP clients that call a service set
+// up by the admin. They go out directly (or through HTTPS_PROXY), whatever
+// FETCH_PROXY and FETCH_ALLOW_DOMAINS say, as the service may well be on the
+// local network.
+func serviceTransport(action string) http.RoundTripper {
+	return activityTransport{action: action, service: true}
+}
+
+func (t activityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	var resp *http.Response
+	var err error
+	if t.service {
+		resp, err = http.DefaultTransport.RoundTrip(req)
+	} else if err = checkFetchPolicy(req.URL, fetchAllowDomains, fetchDenyDomains); err == nil {
+		resp, err = fetchBaseTransport.RoundTrip(req)
+	}
+
+	e := database.ActivityEntry{
+		Kind:       database.ActivityFetch,
+		Action:     t.action,
+		Target:     req.URL.Redacted(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	} else {
+		e.Status = resp.StatusCode
+	}
+	userID, _ := req.Context().Value(userIDKey).(int64)
+	recordActivity(userID, e)
+	return resp, err
+}
+
+// logAPICall records a request authenticated with an API token in the
+// user's activity log once it has been handled
+func logAPICall(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		recordActivity(getUserID(r), database.ActivityEntry{
+			Kind:       database.ActivityAPI,
+			Action:     r.Method,
+			Target:     r.URL.Path,
+			Status:     status,
+			DurationMs: time.Since(start).Milliseconds(),
+			IPAddress:  clientIP(r),
+		})
+	})
+}
+
+// ActivityLogHandler shows the user's activity log, optionally only one kind
+// of entry (?kind=fetch, ?kind=api or ?kind=change)
+func ActivityLogHandler(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	if kind != database.ActivityFetch && kind != database.ActivityAPI && kind != database.ActivityChange {
+		kind = ""
+	}
+
+	entries, err := database.GetActivity(getUserID(r), kind, 500)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tagsWithCounts, _ := database.GetTagsWithCounts(getUserID(r))
+	RenderTemplate(w, "activity.html", map[string]interface{}{
+		"Entries":       entries,
+		"Kind":          kind,
+		"RetentionDays": activityRetentionDays,
+		"Tags":          tagsWithCounts,
+		"ActiveTag":     "",
+	})
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+)
+
+// Admins can post an announcement, e.g. a planned maintenance window or a
+// new feature, shown as a banner above every page until the user dismisses
+// it. Each announcement gets a new id; users who dismissed an older one see
+// the new one again.
+var announcement struct {
+	sync.RWMutex
+	id      string
+	message string
+}
+
+// dismissedAnnouncementSetting is the user setting holding the id of the
+// announcement the user last dismissed
+const dismissedAnnouncementSetting = "dismissed_announcement"
+
+// LoadAnnouncement reads the stored announcement; call it once the database
+// is open
+func LoadAnnouncement() {
+	id, _ := database.GetSystemSetting("announcement_id")
+	message, _ := database.GetSystemSetting("announcement_message")
+	announcement.Lock()
+	announcement.id = id
+	announcement.message = message
+	announcement.Unlock()
+}
+
+func currentAnnouncement() (id, message string) {
+	announcement.RLock()
+	defer announcement.RUnlock()
+	return announcement.id, announcement.message
+}
+
+// AnnouncementBannerHandler renders the announcement banner layout.html
+// loads, or nothing if there is no announcement or the user dismissed it
+func AnnouncementBannerHandler(w http.ResponseWriter, r *http.Request) {
+	id, message := currentAnnouncement()
+	if message == "" || database.GetUserSetting(getUserID(r), dismissedAnnouncementSetting) == id {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	RenderFragment(w, "announcement.html", map[string]interface{}{
+		"ID":      id,
+		"Message": message,
+	})
+}
+
+// DismissAnnouncementHandler hides the announcement with the posted id for
+// the user. Dismissing one that was replaced meanwhile does nothing.
+func DismissAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if id, _ := currentAnnouncement(); id != "" && r.FormValue("id") == id {
+		if err := database.SetUserSetting(userID, dismissedAnnouncementSetting, id); err != nil {
+			http.Error(w, "failed to save", http.StatusInternalServerError)
+			return
+		}
+	}
+	// An empty 200 lets htmx swap the banner out
+	w.WriteHeader(http.StatusOK)
+}
+
+// AnnouncementHandler sets the announcement, or removes it when the message
+// is empty (admins only)
+func AnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if !isAdmin(userID) {
+		http.Error(w, "Only admins can change the announcement", http.StatusForbidden)
+		return
+	}
+
+	var v validation.Validator
+	message := v.MaxLength("message", r.FormValue("message"), maxShortText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	id := ""
+	if message != "" {
+		id = strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	if err := database.SetSystemSetting("announcement_id", id); err != nil {
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	if err := database.SetSystemSetting("announcement_message", message); err != nil {
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	announcement.Lock()
+	announcement.id = id
+	announcement.message = message
+	announcement.Unlock()
+	log.Printf("Announcement %q set by user %d", message, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": message})
+}
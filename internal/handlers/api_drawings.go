@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+const (
+	maxDrawingUpload = 10 << 20 // 10MB
+	maxVectorData    = 5 << 20  // 5MB
+)
+
+// validateSVG rejects SVG documents that could run script when the file is
+// opened from /static/uploads: script and foreignObject elements, on*
+// event attributes and javascript: links.
+func validateSVG(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	sawRoot := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid SVG: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if !sawRoot {
+				if name != "svg" {
+					return errors.New("invalid SVG: root element is not <svg>")
+				}
+				sawRoot = true
+			}
+			if name == "script" || name == "foreignobject" {
+				return fmt.Errorf("SVG must not contain <%s>", t.Name.Local)
+			}
+			for _, attr := range t.Attr {
+				attrName := strings.ToLower(attr.Name.Local)
+				if strings.HasPrefix(attrName, "on") {
+					return fmt.Errorf("SVG must not contain event handler %q", attr.Name.Local)
+				}
+				// Checked on every attribute, <set>/<animate> can assign an href through "to" or "values"
+				value := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+				if strings.Contains(value, "javascript:") || strings.Contains(value, "data:text/html") {
+					return errors.New("SVG must not contain script links")
+				}
+			}
+		case xml.Directive:
+			// DOCTYPEs can declare entities that expand into markup
+			return errors.New("SVG must not contain a DOCTYPE")
+		}
+	}
+	if !sawRoot {
+		return errors.New("invalid SVG: no <svg> element")
+	}
+	return nil
+}
+
+// drawingImageExt validates an uploaded drawing image and returns its file extension
+func drawingImageExt(data []byte) (string, error) {
+	if http.DetectContentType(data) == "image/png" {
+		return ".png", nil
+	}
+	if err := validateSVG(data); err != nil {
+		return "", err
+	}
+	return ".svg", nil
+}
+
+// ApiGetDrawingsHandler returns the user's drawings, optionally filtered with ?tag=
+// and paged with ?page= and ?per_page=
+func ApiGetDrawingsHandler(w http.ResponseWriter, r *http.Request) {
+	drawings, total, err := database.GetDrawingsPage(getUserID(r), r.URL.Query().Get("tag"), apiArchived(r), apiPage(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if drawings == nil {
+		drawings = []models.Drawing{}
+	}
+
+	setTotalHeader(w, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(drawings)
+}
+
+// ApiGetDrawingHandler returns a drawing including its vector data, if any
+func ApiGetDrawingHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	drawing, err := database.GetDrawing(getUserID(r), id)
+	if err != nil {
+		http.Error(w, "Drawing not found", http.StatusNotFound)
+		return
+	}
+
+	// Hand the vector data back as the JSON the app sent rather than a string
+	resp := struct {
+		*models.Drawing
+		VectorData json.RawMessage `json:"vector_data"`
+	}{Drawing: drawing}
+	if drawing.VectorData != "" {
+		resp.VectorData = json.RawMessage(drawing.VectorData)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ApiCreateDrawingHandler creates a drawing from a multipart upload with a PNG
+// or SVG "image" file, a "title", optional "tags" and an optional "vector"
+// part holding the app's own stroke data as JSON.
+func ApiCreateDrawingHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxDrawingUpload+maxVectorData+(1<<20))
+	if err := r.ParseMultipartForm(maxDrawingUpload); err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "Image file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxDrawingUpload+1))
+	if err != nil {
+		http.Error(w, "Failed to read image", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxDrawingUpload {
+		http.Error(w, "Image is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	ext, err := drawingImageExt(data)
+	if err != nil {
+		http.Error(w, "Image must be a PNG or SVG: "+err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// The vector data may come as a form field or as a file part
+	vector := r.FormValue("vector")
+	if vf, _, err := r.FormFile("vector"); err == nil {
+		b, _ := io.ReadAll(io.LimitReader(vf, maxVectorData+1))
+		vf.Close()
+		vector = string(b)
+	}
+	if len(vector) > maxVectorData {
+		http.Error(w, "Vector data is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if vector != "" && !json.Valid([]byte(vector)) {
+		http.Error(w, "Vector data must be JSON", http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("drawing_%d%s", time.Now().UnixNano(), ext)
+	savePath := filepath.Join(datadir.Uploads(), filename)
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		http.Error(w, "Failed to create uploads directory", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(savePath, data, 0644); err != nil {
+		http.Error(w, "Failed to save drawing", http.StatusInternalServerError)
+		return
+	}
+
+	itemID, err := database.CreateDrawing(userID, title, "/static/uploads/"+filename)
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if vector != "" {
+		if err := database.SetDrawingVectorData(itemID, vector); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if tags := parseTags(r.FormValue("tags")); len(tags) > 0 {
+		database.SetItemTags(itemID, tags)
+	}
+	itemCreated(r.Context(), userID, itemID, "drawing", title)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        itemID,
+		"file_path": "/static/uploads/" + filename,
+		"status":    "created",
+	})
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import "testing"
+
+func TestValidateSVG(t *testing.T) {
+	tests := []struct {
+		name string
+		svg  string
+		ok   bool
+	}{
+		{"plain", `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><path d="M0 0L10 10" stroke="black"/></svg>`, true},
+		{"link", `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><a xlink:href="https://example.com"><circle r="4"/></a></svg>`, true},
+		{"script", `<svg><script>alert(1)</script></svg>`, false},
+		{"event handler", `<svg onload="alert(1)"></svg>`, false},
+		{"javascript href", `<svg><a href=" java script:alert(1)"><text>x</text></a></svg>`, false},
+		{"animated href", `<svg><a><set attributeName="href" to="javascript:alert(1)"/></a></svg>`, false},
+		{"foreign object", `<svg><foreignObject><div/></foreignObject></svg>`, false},
+		{"doctype", `<!DOCTYPE svg [<!ENTITY x "y">]><svg></svg>`, false},
+		{"not svg", `<html><body></body></html>`, false},
+		{"not xml", `hello`, false},
+	}
+	for _, tt := range tests {
+		if err := validateSVG([]byte(tt.svg)); (err == nil) != tt.ok {
+			t.Errorf("%s: validateSVG() error = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+// Checklist endpoints of the versioned API, meant for voice assistants and
+// shortcuts ("add milk to the shopping list").
+
+// apiList loads the checklist named by the {id} URL parameter, writing a
+// 404 if it does not exist or belongs to another user.
+func apiList(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return 0, false
+	}
+	if _, err := database.GetList(getUserID(r), id); err != nil {
+		http.Error(w, "List not found", http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// ApiGetListsHandler returns the user's checklists, optionally filtered with ?tag=
+// and paged with ?page= and ?per_page=
+func ApiGetListsHandler(w http.ResponseWriter, r *http.Request) {
+	lists, total, err := database.GetListsPage(getUserID(r), r.URL.Query().Get("tag"), apiArchived(r), apiPage(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if lists == nil {
+		lists = []models.List{}
+	}
+
+	setTotalHeader(w, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+// ApiCreateListHandler creates a checklist
+func ApiCreateListHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title string `json:"title"`
+		Tags  string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var v validation.Validator
+	input.Title = v.Required("title", input.Title, maxTitleLength)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	itemID, err := database.CreateList(getUserID(r), input.Title)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tags := parseTags(input.Tags); len(tags) > 0 {
+		database.SetItemTags(itemID, tags)
+	}
+	itemCreated(r.Context(), getUserID(r), itemID, "list", input.Title)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "status": "created"})
+}
+
+// ApiGetListItemsHandler returns the items of a checklist, open items first
+func ApiGetListItemsHandler(w http.ResponseWriter, r *http.Request) {
+	listID, ok := apiList(w, r)
+	if !ok {
+		return
+	}
+	items, err := database.GetListItems(listID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []models.ListItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// ApiAddListItemHandler adds an item to a checklist. Saying "milk" twice
+// should not put it on the list twice, so an open item with the same text is
+// returned as is and a checked-off one is unchecked instead; a quantity,
+// unit or price given replaces the item's.
+func ApiAddListItemHandler(w http.ResponseWriter, r *http.Request) {
+	listID, ok := apiList(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Content string `json:"content"`
+		listItemAmount
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var v validation.Validator
+	input.Content = v.Required("content", input.Content, maxShortText)
+	input.check(&v)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if existing, err := database.FindListItemByContent(listID, input.Content); err == nil {
+		status := "exists"
+		if existing.Completed {
+			if err := database.ToggleListItem(existing.ID, false); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			status = "reopened"
+		}
+		if input.isSet() {
+			if err := input.save(existing.ID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": existing.ID, "status": status})
+		return
+	}
+
+	itemID, err := database.AddListItem(listID, input.Content)
+	if err == nil {
+		err = input.save(itemID)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "status": "created"})
+}
+
+// ApiToggleListItemHandler checks or unchecks a checklist item. The body may
+// set {"completed": true|false}; without it the item is flipped.
+func ApiToggleListItemHandler(w http.ResponseWriter, r *http.Request) {
+	listID, ok := apiList(w, r)
+	if !ok {
+		return
+	}
+	itemID, ok := pathID(w, r, "itemID")
+	if !ok {
+		return
+	}
+
+	item, err := database.GetListItem(listID, itemID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var input struct {
+		Completed *bool `json:"completed"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	completed := !item.Completed
+	if input.Completed != nil {
+		completed = *input.Completed
+	}
+
+	if err := database.ToggleListItem(itemID, completed); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	item.Completed = completed
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
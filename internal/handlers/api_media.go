@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxMediaUpload = 32 << 20 // 32MB, larger files should use a pre-signed upload
+	// presignedUploadTTL is how long a pre-signed upload URL stays valid
+	presignedUploadTTL = time.Hour
+)
+
+var safeExt = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
+// mediaExts are the files the media API takes, by their sniffed type: the
+// images, audio and video that uploads are served inline as
+var mediaExts = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"application/ogg": ".ogg",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+}
+
+// ApiGetMediaHandler returns the user's media, optionally filtered with ?tag=
+// and ?kind= (screenshot, photo or scan), and paged with ?page= and
+// ?per_page=
+func ApiGetMediaHandler(w http.ResponseWriter, r *http.Request) {
+	media, total, err := database.GetMediaPage(getUserID(r), r.URL.Query().Get("tag"), r.URL.Query().Get("kind"), apiArchived(r), apiPage(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if media == nil {
+		media = []models.Media{}
+	}
+
+	setTotalHeader(w, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(media)
+}
+
+// ApiGetMediaItemHandler returns a single media item
+func ApiGetMediaItemHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	item, err := database.GetMediaItem(id, getUserID(r))
+	if err != nil {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// ApiUploadMediaHandler stores an image, audio or video file sent as
+// multipart "file" (with optional "title" and "tags") in the local uploads
+// folder. The type is sniffed from the content, not taken from the client.
+func ApiUploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaUpload+(1<<20))
+	if err := r.ParseMultipartForm(maxMediaUpload); err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	mimeType := http.DetectContentType(head[:n])
+	ext, ok := mediaExts[mimeType]
+	if !ok {
+		var v validation.Validator
+		v.Add("file", "must be an image, audio or video file")
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		title = header.Filename
+	}
+
+	fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
+	savePath := filepath.Join(datadir.Uploads(), fileName)
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		http.Error(w, "Failed to create uploads directory", http.StatusInternalServerError)
+		return
+	}
+
+	out, err := os.Create(savePath)
+	if err != nil {
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+	_, err = io.Copy(out, file)
+	out.Close()
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+
+	relPath := "/static/uploads/" + fileName
+	itemID, err := database.CreateMedia(userID, title, relPath, mimeType)
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tags := parseTags(r.FormValue("tags")); len(tags) > 0 {
+		database.SetItemTags(itemID, tags)
+	}
+	media := database.MediaFile{ID: itemID, UserID: userID, Title: title, FilePath: relPath}
+	if err := fingerprintMediaItem(media); err != nil {
+		log.Printf("Media fingerprints: item %d: %v", itemID, err)
+	}
+	itemCreated(r.Context(), userID, itemID, "media", title)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "file_path": relPath, "status": "created"})
+}
+
+// ApiCreateMediaUploadHandler hands out a pre-signed URL so a large file can be
+// PUT straight to object storage. The media item is created when the client
+// calls the returned complete_url.
+func ApiCreateMediaUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if !s3Enabled() {
+		http.Error(w, "Direct uploads need the S3 backend, which is not configured; use POST /api/v1/media", http.StatusNotImplemented)
+		return
+	}
+	userID := getUserID(r)
+
+	var input struct {
+		Filename    string `json:"filename"`
+		ContentType string `json:"content_type"`
+		Title       string `json:"title"`
+		Tags        string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.Filename == "" {
+		http.Error(w, "Filename is required", http.StatusBadRequest)
+		return
+	}
+	var v validation.Validator
+	title := v.MaxLength("title", strings.TrimSpace(input.Title), maxTitleLength)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	if title == "" {
+		title = input.Filename
+	}
+
+	uploadID, err := generateSecureHash(24)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	ext := filepath.Ext(input.Filename)
+	if !safeExt.MatchString(ext) {
+		ext = ""
+	}
+	objectKey := fmt.Sprintf("media/%d/%s%s", userID, uploadID, ext)
+
+	err = database.CreateMediaUpload(database.MediaUpload{
+		ID:        uploadID,
+		UserID:    userID,
+		ObjectKey: objectKey,
+		Title:     title,
+		Tags:      input.Tags,
+		MimeType:  input.ContentType,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	headers := map[string]string{}
+	if input.ContentType != "" {
+		headers["Content-Type"] = input.ContentType
+	}
+	now := time.Now()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"upload_id":    uploadID,
+		"method":       http.MethodPut,
+		"upload_url":   s3.presign(http.MethodPut, s3.objectURL(objectKey), presignedUploadTTL, now),
+		"headers":      headers,
+		"expires_at":   now.Add(presignedUploadTTL).UTC().Format(time.RFC3339),
+		"complete_url": "/api/v1/media/uploads/" + uploadID + "/complete",
+	})
+}
+
+// ApiCompleteMediaUploadHandler checks that a pre-signed upload reached object
+// storage and creates its media item
+func ApiCompleteMediaUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if !s3Enabled() {
+		http.Error(w, "Direct uploads need the S3 backend, which is not configured", http.StatusNotImplemented)
+		return
+	}
+	userID := getUserID(r)
+
+	upload, err := database.GetMediaUpload(userID, chi.URLParam(r, "uploadID"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, contentType, err := s3.headObject(upload.ObjectKey)
+	if err != nil {
+		log.Printf("Media upload %s for user %d not found in object storage: %v", upload.ID, userID, err)
+		http.Error(w, "The file has not been uploaded yet", http.StatusConflict)
+		return
+	}
+	if contentType == "" || contentType == "binary/octet-stream" {
+		contentType = upload.MimeType
+	}
+
+	filePath := s3.publicURL(upload.ObjectKey)
+	itemID, err := database.CreateMedia(userID, upload.Title, filePath, contentType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tags := parseTags(upload.Tags); len(tags) > 0 {
+		database.SetItemTags(itemID, tags)
+	}
+	itemCreated(r.Context(), userID, itemID, "media", upload.Title)
+	database.DeleteMediaUpload(upload.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "file_path": filePath, "status": "created"})
+}
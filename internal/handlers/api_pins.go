@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"infokeep/internal/database"
+)
+
+// ApiPinItemHandler pins or unpins any of the user's items. The body
+// {"pinned": true} sets the state; without a body the pin is flipped.
+// Pinned items come first in their section and show on the dashboard.
+func ApiPinItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	itemID, ok := pathID(w, r, "id")
+	if !ok || !requireOwnership(w, itemID, userID) {
+		return
+	}
+
+	var input struct {
+		Pinned *bool `json:"pinned"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pinned, err := database.IsItemPinned(itemID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pinned = !pinned
+	if input.Pinned != nil {
+		pinned = *input.Pinned
+	}
+	if err := database.SetItemPinned(itemID, userID, pinned); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "pinned": pinned})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+// minTitleSimilarity is how close a title must be to an existing item to be
+// treated as the same entry
+const minTitleSimilarity = 0.8
+
+// titleFillers are the words a title can have on top of another and still
+// name the same thing ("Matrix, The" and "Matrix")
+var titleFillers = map[string]bool{"the": true, "a": true, "an": true, "and": true}
+
+// trailingScore matches a score typed after the title, e.g. "Luigi's 8" or "Luigi's 8/10"
+var trailingScore = regexp.MustCompile(`^(.*\S)\s+(10|[1-9])(?:\s*/\s*10)?$`)
+
+// normalizeTitle lowercases a title and reduces it to space-separated words,
+// dropping punctuation and a leading article
+func normalizeTitle(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	for i, w := range words {
+		words[i] = strings.Trim(w, "'")
+	}
+	if len(words) > 1 && (words[0] == "the" || words[0] == "a" || words[0] == "an") {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// titleSimilarity scores how likely two titles name the same thing, from 0 to 1.
+// Typos score by edit distance; a title whose words all appear in the other,
+// which has only articles on top ("matrix" and "matrix the"), counts as a
+// strong match. Other extra words make another thing: "Dune" is not "Dune:
+// Part Two".
+func titleSimilarity(a, b string) float64 {
+	a, b = normalizeTitle(a), normalizeTitle(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	score := 1 - float64(levenshtein(ra, rb))/float64(longest)
+
+	short, long := strings.Fields(a), strings.Fields(b)
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	if len([]rune(strings.Join(short, " "))) >= 4 {
+		words := make(map[string]int, len(short))
+		for _, w := range short {
+			words[w]++
+		}
+		contained := true
+		for _, w := range long {
+			if words[w] > 0 {
+				words[w]--
+			} else if !titleFillers[w] {
+				contained = false
+				break
+			}
+		}
+		for _, n := range words {
+			if n > 0 {
+				contained = false
+			}
+		}
+		if contained {
+			score = max(score, 0.9)
+		}
+	}
+	return score
+}
+
+// bestTitleMatch returns the item whose title is most similar to title, or
+// nil if none is similar enough
+func bestTitleMatch(title string, items []models.RatedListItem) *models.RatedListItem {
+	var best *models.RatedListItem
+	bestScore := minTitleSimilarity
+	for i := range items {
+		if s := titleSimilarity(title, items[i].Title); s >= bestScore {
+			best, bestScore = &items[i], s
+		}
+	}
+	return best
+}
+
+// ApiRateListItemHandler rates an entry of a rated list by free-text title,
+// for quick entry from a phone ("rate Luigi's 8"). If an existing item's title
+// is a close match its score is updated, otherwise a new item is created;
+// "create": true always creates one. The score can be given as a field or
+// typed after the title.
+func ApiRateListItemHandler(w http.ResponseWriter, r *http.Request) {
+	listID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if _, err := database.GetRatedList(getUserID(r), listID); err != nil {
+		http.Error(w, "List not found", http.StatusNotFound)
+		return
+	}
+
+	var input struct {
+		Title string `json:"title"`
+		Score *int   `json:"score"`
+		Note  string `json:"note"`
+		// Create adds a new item even if one has a similar title
+		Create bool `json:"create"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(input.Title)
+	if input.Score == nil {
+		if m := trailingScore.FindStringSubmatch(title); m != nil {
+			score, _ := strconv.Atoi(m[2])
+			title, input.Score = m[1], &score
+		}
+	}
+	var v validation.Validator
+	title = v.Required("title", title, maxTitleLength)
+	if input.Score == nil {
+		v.Add("score", "is required")
+	} else {
+		v.Range("score", *input.Score, minScore, maxScore)
+	}
+	input.Note = v.MaxLength("note", input.Note, maxShortText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	items, err := database.GetRatedListItems(listID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if match := bestTitleMatch(title, items); match != nil && !input.Create {
+		note := match.Note
+		if input.Note != "" {
+			note = input.Note
+		}
+		if err := database.UpdateRatedListItem(match.ID, match.Title, *input.Score, note); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":             match.ID,
+			"title":          match.Title,
+			"score":          *input.Score,
+			"previous_score": match.Score,
+			"status":         "updated",
+		})
+		return
+	}
+
+	id, err := database.AddRatedListItem(listID, title, *input.Score, input.Note)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     id,
+		"title":  title,
+		"score":  *input.Score,
+		"status": "created",
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"testing"
+
+	"infokeep/internal/models"
+)
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		match bool
+	}{
+		{"Luigi's", "luigi's", true},
+		{"The Matrix", "Matrix", true},
+		{"Luigis Trattoria", "Luigi's Trattoria", true},
+		{"Matrix, The", "The Matrix", true},
+		{"Fish and Chips", "Fish Chips", true},
+		{"Luigi's", "Luigi's Trattoria", false},
+		{"Dune", "Dune: Part Two", false},
+		{"Dune", "Dune Dune", false},
+		{"Pho 99", "Pho Hoa", false},
+		{"Cat", "Cats", false},
+		{"Up", "Up in the Air", false},
+		{"Sushi Bar", "Taco Bar", false},
+	}
+	for _, tt := range tests {
+		s := titleSimilarity(tt.a, tt.b)
+		if (s >= minTitleSimilarity) != tt.match {
+			t.Errorf("titleSimilarity(%q, %q) = %.2f, want match=%v", tt.a, tt.b, s, tt.match)
+		}
+	}
+}
+
+func TestBestTitleMatch(t *testing.T) {
+	items := []models.RatedListItem{
+		{ID: 1, Title: "Luigi's Trattoria"},
+		{ID: 2, Title: "Luigi's"},
+		{ID: 3, Title: "Burger Barn"},
+	}
+	if m := bestTitleMatch("luigis", items); m == nil || m.ID != 2 {
+		t.Errorf("bestTitleMatch(luigis) = %v, want item 2", m)
+	}
+	if m := bestTitleMatch("Burger Bran", items); m == nil || m.ID != 3 {
+		t.Errorf("bestTitleMatch(Burger Bran) = %v, want item 3", m)
+	}
+	if m := bestTitleMatch("Noodle House", items); m != nil {
+		t.Errorf("bestTitleMatch(Noodle House) = %v, want nil", m)
+	}
+}
+
+func TestTrailingScore(t *testing.T) {
+	tests := []struct {
+		in, title, score string
+	}{
+		{"Luigi's 8", "Luigi's", "8"},
+		{"Luigi's 10/10", "Luigi's", "10"},
+		{"Pho 99", "", ""},
+		{"Blade Runner 2049 7", "Blade Runner 2049", "7"},
+	}
+	for _, tt := range tests {
+		m := trailingScore.FindStringSubmatch(tt.in)
+		if tt.title == "" {
+			if m != nil {
+				t.Errorf("trailingScore(%q) matched %q", tt.in, m)
+			}
+			continue
+		}
+		if m == nil || m[1] != tt.title || m[2] != tt.score {
+			t.Errorf("trailingScore(%q) = %q, want %q %q", tt.in, m, tt.title, tt.score)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"infokeep/internal/database"
+)
+
+// ArchiveItemHandler archives one of the user's items, or with the form
+// value archived=false takes it out of the archive. Either way the item
+// leaves the list it was shown in, so HTMX callers remove its card.
+func ArchiveItemHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	setItemArchived(w, itemID, getUserID(r), r.FormValue("archived") != "false")
+}
+
+// ApiArchiveItemHandler archives or unarchives any of the user's items. The
+// body {"archived": true} sets the state; without a body it is flipped.
+// Archived items are left out of lists, the dashboard and search, and
+// listed with ?archived=true.
+func ApiArchiveItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Archived *bool `json:"archived"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	archived, err := database.IsItemArchived(itemID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	archived = !archived
+	if input.Archived != nil {
+		archived = *input.Archived
+	}
+	setItemArchived(w, itemID, userID, archived)
+}
+
+// setItemArchived stores the archived state of an item and writes it back
+func setItemArchived(w http.ResponseWriter, itemID, userID int64, archived bool) {
+	err := database.SetItemArchived(itemID, userID, archived)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "archived": archived})
+}
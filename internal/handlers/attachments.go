@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+// Files can be attached to any item, not only uploaded as media: a note
+// keeps the PDF it summarizes, a bookmark the manual of the product, a
+// checklist the tickets of the trip. They are stored in the uploads folder
+// like media and removed when the item is purged from the trash.
+
+// attachmentsView is the data of fragments/attachments.html
+type attachmentsView struct {
+	ItemID      int64
+	Attachments []models.Attachment
+}
+
+// writeAttachments responds with the files attached to an item: the
+// Attachments section to HTMX, JSON to anyone else
+func writeAttachments(w http.ResponseWriter, r *http.Request, userID, itemID int64) {
+	attachments, err := database.GetAttachments(userID, itemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if r.Header.Get("HX-Request") != "" {
+		RenderFragment(w, "attachments.html", attachmentsView{ItemID: itemID, Attachments: attachments})
+		return
+	}
+	if attachments == nil {
+		attachments = []models.Attachment{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// ItemAttachmentsHandler lists the files attached to an item and, on POST,
+// attaches the multipart "file" to it. An API client gets the new
+// attachment back with 201.
+func ItemAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	if !requireOwnership(w, itemID, userID) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeAttachments(w, r, userID, itemID)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaUpload+(1<<20))
+	if err := r.ParseMultipartForm(maxMediaUpload); err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var v validation.Validator
+		v.Add("file", "is required")
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	defer file.Close()
+
+	ext := filepath.Ext(header.Filename)
+	if !safeExt.MatchString(ext) {
+		ext = ""
+	}
+	fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
+	savePath := filepath.Join(datadir.Uploads(), fileName)
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		http.Error(w, "Failed to create uploads directory", http.StatusInternalServerError)
+		return
+	}
+	out, err := os.Create(savePath)
+	if err != nil {
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+	size, err := io.Copy(out, file)
+	out.Close()
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+
+	name := strings.TrimSpace(filepath.Base(header.Filename))
+	if len(name) > maxTitleLength {
+		name = name[:maxTitleLength]
+	}
+	if name == "" || name == "." {
+		name = fileName
+	}
+	attachment, err := database.AddAttachment(models.Attachment{
+		ItemID:   itemID,
+		FileName: name,
+		FilePath: "/static/uploads/" + fileName,
+		MimeType: header.Header.Get("Content-Type"),
+		Size:     size,
+	})
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "" {
+		writeAttachments(w, r, userID, itemID)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// DeleteAttachmentHandler removes a file attached to an item
+func DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r, "attachmentID")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	path, err := database.DeleteAttachment(userID, itemID, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	removeItemFiles([]string{path})
+
+	if r.Header.Get("HX-Request") != "" {
+		writeAttachments(w, r, userID, itemID)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/jobs"
+)
+
+const (
+	autoBackupTask = "server_backup"
+	// autoBackupPrefix and autoBackupExt make up the names of backup
+	// archives; only files named like this are rotated
+	autoBackupPrefix = "infokeep_backup_"
+	autoBackupExt    = ".zip"
+	// autoBackupS3Prefix is where backups go in the S3 bucket
+	autoBackupS3Prefix = "backups/"
+)
+
+// Automatic server backups: the whole database and every upload, written on
+// a schedule to a directory and/or the S3 bucket, keeping the latest few
+var (
+	autoBackupDir      = os.Getenv("BACKUP_DIR")
+	autoBackupToS3     = os.Getenv("BACKUP_S3") == "on"
+	autoBackupSchedule = os.Getenv("BACKUP_SCHEDULE")
+	autoBackupKeep     = 7
+)
+
+func init() {
+	if autoBackupSchedule == "" {
+		autoBackupSchedule = "0 3 * * *"
+	}
+	if v, err := strconv.Atoi(os.Getenv("BACKUP_KEEP")); err == nil && v > 0 {
+		autoBackupKeep = v
+	}
+}
+
+// autoBackupEnabled reports whether automatic backups have somewhere to go
+func autoBackupEnabled() bool {
+	return autoBackupDir != "" || (autoBackupToS3 && s3Enabled())
+}
+
+// autoBackupCopy is a stored backup archive
+type autoBackupCopy struct {
+	Target string
+	Name   string
+	Size   int64
+}
+
+// SizeMB returns the size of the copy in megabytes, for display
+func (c autoBackupCopy) SizeMB() string {
+	return fmt.Sprintf("%.1f MB", float64(c.Size)/(1<<20))
+}
+
+func registerAutoBackupTask() {
+	if !autoBackupEnabled() {
+		if autoBackupToS3 {
+			log.Printf("BACKUP_S3 is on but S3 is not configured; automatic backups are off")
+		}
+		return
+	}
+	schedule, err := jobs.ParseSchedule(autoBackupSchedule)
+	if err != nil {
+		log.Printf("Invalid BACKUP_SCHEDULE, automatic backups are off: %v", err)
+		return
+	}
+	jobs.Register(jobs.Task{
+		Name:        autoBackupTask,
+		Description: fmt.Sprintf("Back up the database and uploads to %s, keeping the latest %d", strings.Join(autoBackupTargets(), " and "), autoBackupKeep),
+		Schedule:    schedule,
+		Run:         runAutoBackup,
+	})
+}
+
+// autoBackupTargets describes where backups are written
+func autoBackupTargets() []string {
+	var targets []string
+	if autoBackupDir != "" {
+		targets = append(targets, autoBackupDir)
+	}
+	if autoBackupToS3 && s3Enabled() {
+		targets = append(targets, "s3://"+s3.Bucket+"/"+autoBackupS3Prefix)
+	}
+	return targets
+}
+
+// runAutoBackup writes a backup archive to each target and removes the
+// copies beyond autoBackupKeep
+func runAutoBackup() (string, error) {
+	now := time.Now()
+	name := autoBackupName(now)
+
+	dir := autoBackupDir
+	if dir == "" {
+		dir = os.TempDir()
+		if DBPath != "" {
+			dir = filepath.Dir(DBPath)
+		}
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// Written under a temporary name so a half-written archive is never
+	// taken for a backup
+	tmp, err := os.CreateTemp(dir, ".infokeep-backup-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	files, err := writeBackupArchive(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return "", err
+	}
+
+	removed := 0
+	if autoBackupToS3 && s3Enabled() {
+		f, err := os.Open(tmpPath)
+		if err != nil {
+			return "", err
+		}
+		err = s3.putObject(autoBackupS3Prefix+name, "application/zip", f, info.Size())
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to upload backup to S3: %w", err)
+		}
+		n, err := rotateS3Backups(autoBackupKeep)
+		if err != nil {
+			return "", fmt.Errorf("failed to remove old S3 backups: %w", err)
+		}
+		removed += n
+	}
+	if autoBackupDir != "" {
+		if err := os.Rename(tmpPath, filepath.Join(autoBackupDir, name)); err != nil {
+			return "", err
+		}
+		n, err := rotateDirBackups(autoBackupDir, autoBackupKeep)
+		if err != nil {
+			return "", fmt.Errorf("failed to remove old backups: %w", err)
+		}
+		removed += n
+	}
+
+	log.Printf("Automatic backup %s written (%d uploads, %d bytes)", name, files, info.Size())
+	return fmt.Sprintf("Wrote %s (%.1f MB, %d uploads), removed %d old copies", name, float64(info.Size())/(1<<20), files, removed), nil
+}
+
+// writeBackupArchive writes a zip of a database snapshot (infokeep.db) and
+// the uploads folder (uploads/...) to w and returns the number of uploads
+func writeBackupArchive(w io.Writer) (int, error) {
+	snapshot, err := databaseSnapshot()
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(snapshot)
+
+	zw := zip.NewWriter(w)
+	if err := addFileToZip(zw, snapshot, "infokeep.db", zip.Deflate); err != nil {
+		return 0, err
+	}
+
+	uploads := datadir.Uploads()
+	files := 0
+	err = filepath.WalkDir(uploads, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == uploads {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(uploads, p)
+		if err != nil {
+			return err
+		}
+		files++
+		// Uploads are mostly images, which don't compress any further
+		return addFileToZip(zw, p, path.Join("uploads", filepath.ToSlash(rel)), zip.Store)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return files, zw.Close()
+}
+
+// addFileToZip copies the file at src into the archive as name
+func addFileToZip(zw *zip.Writer, src, name string, method uint16) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = method
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+// autoBackupName returns the archive name of a backup taken at t; names sort
+// in the order the backups were taken
+func autoBackupName(t time.Time) string {
+	return autoBackupPrefix + t.Format("2006-01-02_150405") + autoBackupExt
+}
+
+func isAutoBackupName(name string) bool {
+	return strings.HasPrefix(name, autoBackupPrefix) && strings.HasSuffix(name, autoBackupExt)
+}
+
+// expiredBackups returns the backups to remove to keep the latest keep of names
+func expiredBackups(names []string, keep int) []string {
+	var backups []string
+	for _, n := range names {
+		if isAutoBackupName(n) {
+			backups = append(backups, n)
+		}
+	}
+	sort.Strings(backups)
+	if len(backups) <= keep {
+		return nil
+	}
+	return backups[:len(backups)-keep]
+}
+
+// rotateDirBackups deletes all but the latest keep backups in dir
+func rotateDirBackups(dir string, keep int) (int, error) {
+	copies, err := dirBackups(dir)
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, len(copies))
+	for i, c := range copies {
+		names[i] = c.Name
+	}
+	removed := 0
+	for _, name := range expiredBackups(names, keep) {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// rotateS3Backups deletes all but the latest keep backups in the bucket
+func rotateS3Backups(keep int) (int, error) {
+	copies, err := s3Backups()
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, len(copies))
+	for i, c := range copies {
+		names[i] = c.Name
+	}
+	removed := 0
+	for _, name := range expiredBackups(names, keep) {
+		if err := s3.deleteObject(autoBackupS3Prefix + name); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// dirBackups returns the backups stored in dir, oldest first
+func dirBackups(dir string) ([]autoBackupCopy, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var copies []autoBackupCopy
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !isAutoBackupName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		copies = append(copies, autoBackupCopy{Target: dir, Name: e.Name(), Size: info.Size()})
+	}
+	return copies, nil
+}
+
+// s3Backups returns the backups stored in the bucket, oldest first
+func s3Backups() ([]autoBackupCopy, error) {
+	objects, err := s3.listObjects(autoBackupS3Prefix)
+	if err != nil {
+		return nil, err
+	}
+	var copies []autoBackupCopy
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, autoBackupS3Prefix)
+		if strings.Contains(name, "/") || !isAutoBackupName(name) {
+			continue
+		}
+		copies = append(copies, autoBackupCopy{Target: "S3", Name: name, Size: o.Size})
+	}
+	return copies, nil
+}
+
+// autoBackupStatus is what the settings page shows about automatic backups
+// (admins only), or nil if they are off
+func autoBackupStatus() map[string]interface{} {
+	if !autoBackupEnabled() {
+		return nil
+	}
+	var copies []autoBackupCopy
+	if autoBackupDir != "" {
+		local, err := dirBackups(autoBackupDir)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to list backups in %s: %v", autoBackupDir, err)
+		}
+		copies = append(copies, local...)
+	}
+	if autoBackupToS3 && s3Enabled() {
+		remote, err := s3Backups()
+		if err != nil {
+			log.Printf("Failed to list S3 backups: %v", err)
+		}
+		copies = append(copies, remote...)
+	}
+	// Newest first
+	sort.SliceStable(copies, func(i, j int) bool { return copies[i].Name > copies[j].Name })
+
+	lastRun, _ := database.GetLastTaskRun(autoBackupTask)
+	return map[string]interface{}{
+		"Targets":  autoBackupTargets(),
+		"Schedule": autoBackupSchedule,
+		"Keep":     autoBackupKeep,
+		"LastRun":  lastRun,
+		"Copies":   copies,
+	}
+}
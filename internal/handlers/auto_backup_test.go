@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAutoBackupName(t *testing.T) {
+	name := autoBackupName(time.Date(2026, 3, 1, 3, 0, 5, 0, time.UTC))
+	if name != "infokeep_backup_2026-03-01_030005.zip" {
+		t.Errorf("autoBackupName() = %q", name)
+	}
+	if !isAutoBackupName(name) {
+		t.Errorf("isAutoBackupName(%q) = false", name)
+	}
+	for _, other := range []string{"infokeep_backup_2026-03-01.db", ".infokeep-backup-123.tmp", "notes.zip"} {
+		if isAutoBackupName(other) {
+			t.Errorf("isAutoBackupName(%q) = true", other)
+		}
+	}
+}
+
+func TestExpiredBackups(t *testing.T) {
+	names := []string{
+		"infokeep_backup_2026-03-03_030000.zip",
+		"infokeep_backup_2026-03-01_030000.zip",
+		"unrelated.zip",
+		"infokeep_backup_2026-03-02_030000.zip",
+	}
+	got := expiredBackups(names, 2)
+	want := []string{"infokeep_backup_2026-03-01_030000.zip"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expiredBackups(keep 2) = %v, want %v", got, want)
+	}
+	if got := expiredBackups(names, 3); got != nil {
+		t.Errorf("expiredBackups(keep 3) = %v, want none", got)
+	}
+}
+
+func TestRotateDirBackups(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"infokeep_backup_2026-03-01_030000.zip",
+		"infokeep_backup_2026-03-02_030000.zip",
+		"infokeep_backup_2026-03-03_030000.zip",
+		"keep-me.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := rotateDirBackups(dir, 1)
+	if err != nil || removed != 2 {
+		t.Fatalf("rotateDirBackups() = %d, %v, want 2", removed, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	want := []string{"infokeep_backup_2026-03-03_030000.zip", "keep-me.txt"}
+	if !reflect.DeepEqual(left, want) {
+		t.Errorf("left %v, want %v", left, want)
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// BasePath is the URL path prefix infokeep is served under, e.g. "/infokeep"
+// when a reverse proxy forwards https://example.com/infokeep/ to it. It is
+// empty when infokeep is served at the root of its host.
+var BasePath = normalizeBasePath(os.Getenv("BASE_PATH"))
+
+// normalizeBasePath turns "infokeep", "/infokeep/" etc. into "/infokeep", and "/" into ""
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// appURL prefixes a root-relative app path like "/notes" or a stored
+// "/static/uploads/..." file path with the base path. Absolute URLs,
+// protocol-relative URLs and relative paths are returned unchanged.
+func appURL(path string) string {
+	if BasePath == "" || !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return path
+	}
+	return BasePath + path
+}
+
+// redirectTo is http.Redirect for app paths, which it prefixes with the base path
+func redirectTo(w http.ResponseWriter, r *http.Request, path string, code int) {
+	http.Redirect(w, r, appURL(path), code)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"":           "",
+		"/":          "",
+		"infokeep":   "/infokeep",
+		"/infokeep/": "/infokeep",
+		" /a/b/ ":    "/a/b",
+	} {
+		if got := normalizeBasePath(in); got != want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAppURL(t *testing.T) {
+	old := BasePath
+	defer func() { BasePath = old }()
+	BasePath = "/infokeep"
+
+	for in, want := range map[string]string{
+		"/notes":                    "/infokeep/notes",
+		"/static/uploads/1.png":     "/infokeep/static/uploads/1.png",
+		"https://cdn.example.com/x": "https://cdn.example.com/x",
+		"//cdn.example.com/x":       "//cdn.example.com/x",
+		"":                          "",
+	} {
+		if got := appURL(in); got != want {
+			t.Errorf("appURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNoteImagesURL(t *testing.T) {
+	old := BasePath
+	defer func() { BasePath = old }()
+	BasePath = "/infokeep"
+
+	// Notes store the root path of their images; BASE_PATH comes in when shown
+	content := "Crumb:\n![open crumb](/static/uploads/1.png) and ![](/static/uploads/2.webp)\n" +
+		"![remote](https://example.com/3.png) [not an image](/static/uploads/4.png)"
+	tmpl := template.Must(template.New("").Funcs(templateFuncs).Parse(`{{range noteImages .}}{{url .}} {{end}}`))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, content); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/infokeep/static/uploads/1.png /infokeep/static/uploads/2.webp "; b.String() != want {
+		t.Errorf("note images = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"infokeep/internal/database"
+	"infokeep/internal/metrics"
+)
+
+// The list benchmarks run against a large library: benchItems bookmarks and
+// as many notes, each with two of benchTags tags. Run them with make bench
+// and compare against the numbers before a change to the list queries or
+// templates. TestListQueryBudget keeps the number of queries a page takes
+// from growing, which benchmarks would only show as time.
+
+const (
+	benchItems = 10000
+	benchTags  = 20
+)
+
+// seedLibrary gives user 1 of a fresh database n bookmarks and n notes, each
+// with two tags, and returns the bookmarks' ids
+func seedLibrary(tb testing.TB, n int) []int64 {
+	tb.Helper()
+	if err := database.InitDB(filepath.Join(tb.TempDir(), "library.db")); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { database.DB.Close() })
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer tx.Rollback()
+	exec := func(query string, args ...interface{}) int64 {
+		res, err := tx.Exec(query, args...)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		id, _ := res.LastInsertId()
+		return id
+	}
+
+	exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'bench', 'x')")
+	tagIDs := make([]int64, benchTags)
+	for i := range tagIDs {
+		tagIDs[i] = exec("INSERT INTO tags (user_id, name) VALUES (1, ?)", fmt.Sprintf("tag%d", i))
+	}
+	var bookmarks []int64
+	for i := 0; i < n; i++ {
+		created := fmt.Sprintf("-%d minutes", i)
+		id := exec("INSERT INTO items (user_id, title, type, created_at) VALUES (1, ?, 'bookmark', datetime('now', ?))",
+			fmt.Sprintf("Bookmark %d", i), created)
+		exec("INSERT INTO bookmarks (item_id, url, description) VALUES (?, ?, ?)",
+			id, fmt.Sprintf("https://example.com/%d", i), "A page worth keeping")
+		bookmarks = append(bookmarks, id)
+		note := exec("INSERT INTO items (user_id, title, type, created_at) VALUES (1, ?, 'note', datetime('now', ?))",
+			fmt.Sprintf("Note %d", i), created)
+		exec("INSERT INTO notes (item_id, content) VALUES (?, ?)", note, "Some **markdown** to render")
+		for _, item := range []int64{id, note} {
+			exec("INSERT INTO item_tags (item_id, tag_id) VALUES (?, ?), (?, ?)",
+				item, tagIDs[i%benchTags], item, tagIDs[(i+1)%benchTags])
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+	return bookmarks
+}
+
+// queryCount returns how many statements the database has run, from its
+// query metrics
+func queryCount() int {
+	var buf bytes.Buffer
+	metrics.WriteAll(&buf)
+	count := 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "infokeep_db_query_duration_seconds_count") {
+			fields := strings.Fields(line)
+			n, _ := strconv.Atoi(fields[len(fields)-1])
+			count += n
+		}
+	}
+	return count
+}
+
+// libraryRequest serves a request of user 1 the way HTMX sends it
+func libraryRequest(tb testing.TB, handler http.HandlerFunc, path string) {
+	r := httptest.NewRequest("GET", path, nil).WithContext(withUserID(context.Background(), 1))
+	r.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusOK {
+		tb.Fatalf("GET %s: status %d: %.300s", path, w.Code, w.Body.String())
+	}
+}
+
+// reportQueries adds the statements per operation since start to a
+// benchmark's results
+func reportQueries(b *testing.B, start int) {
+	b.ReportMetric(float64(queryCount()-start)/float64(b.N), "queries/op")
+}
+
+// TestListQueryBudget checks that the list pages and the dashboard don't
+// take more queries than they do today, e.g. that tags are still read in
+// batches rather than per item. A change that needs more should raise the
+// budget knowingly.
+func TestListQueryBudget(t *testing.T) {
+	useRepoTemplates(t)
+	budgets := []struct {
+		path    string
+		handler http.HandlerFunc
+		queries int
+	}{
+		{"/bookmarks", BookmarkHandler, 5}, // 4, and the user's items per page
+		{"/notes", NoteHandler, 5},
+		{"/bookmarks?page=3&tag=tag1", BookmarkHandler, 4},
+		{"/", DashboardHandler, 27}, // with Recently Viewed
+	}
+
+	// More items than one batch of tags holds, so a page needs several
+	seedLibrary(t, 2*database.MaxBulkItems+1)
+	for _, b := range budgets {
+		libraryRequest(t, b.handler, b.path) // warm up caches
+		start := queryCount()
+		libraryRequest(t, b.handler, b.path)
+		if n := queryCount() - start; n > b.queries {
+			t.Errorf("GET %s took %d queries, the budget is %d", b.path, n, b.queries)
+		}
+	}
+}
+
+func BenchmarkGetBookmarks(b *testing.B) {
+	seedLibrary(b, benchItems)
+	pages := map[string]database.Page{
+		"all":  {},
+		"page": {Limit: defaultPerPage},
+		"last": {Limit: defaultPerPage, Offset: benchItems - defaultPerPage},
+	}
+	for name, page := range pages {
+		b.Run(name, func(b *testing.B) {
+			start := queryCount()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := database.GetBookmarksPage(1, "", database.AllBookmarks, database.NotArchived, page); err != nil {
+					b.Fatal(err)
+				}
+			}
+			reportQueries(b, start)
+		})
+	}
+	b.Run("tagged", func(b *testing.B) {
+		start := queryCount()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := database.GetBookmarksPage(1, "tag1", database.AllBookmarks, database.NotArchived, pages["page"]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		reportQueries(b, start)
+	})
+}
+
+func BenchmarkGetNotes(b *testing.B) {
+	seedLibrary(b, benchItems)
+	for name, page := range map[string]database.Page{"all": {}, "page": {Limit: defaultPerPage}} {
+		b.Run(name, func(b *testing.B) {
+			start := queryCount()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := database.GetNotesPage(1, "", database.NotArchived, page); err != nil {
+					b.Fatal(err)
+				}
+			}
+			reportQueries(b, start)
+		})
+	}
+}
+
+// BenchmarkItemTags compares reading the tags of a page and of all bookmarks
+// in batches, as the list queries do, with a query per item
+func BenchmarkItemTags(b *testing.B) {
+	ids := seedLibrary(b, benchItems)
+	for name, ids := range map[string][]int64{"page": ids[:defaultPerPage], "all": ids} {
+		b.Run(name+"/batched", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := database.GetTagsForItems(ids); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/per-item", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, id := range ids {
+					if _, err := database.GetItemTags(id); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkListPage serves the first page of bookmarks and notes from the
+// template cache, and with TEMPLATE_RELOAD checking the files on every render
+func BenchmarkListPage(b *testing.B) {
+	useRepoTemplates(b)
+	seedLibrary(b, benchItems)
+	for _, reload := range []bool{false, true} {
+		mode := "cached"
+		if reload {
+			mode = "reload"
+		}
+		for path, handler := range map[string]http.HandlerFunc{"bookmarks": BookmarkHandler, "notes": NoteHandler} {
+			b.Run(path+"/"+mode, func(b *testing.B) {
+				old := templateReload
+				templateReload = reload
+				defer func() { templateReload = old }()
+				start := queryCount()
+				for i := 0; i < b.N; i++ {
+					libraryRequest(b, handler, "/"+path)
+				}
+				reportQueries(b, start)
+			})
+		}
+	}
+}
+
+// BenchmarkDashboard serves the dashboard, which shows every item that is
+// not archived
+func BenchmarkDashboard(b *testing.B) {
+	useRepoTemplates(b)
+	seedLibrary(b, benchItems)
+	b.Run("all", func(b *testing.B) {
+		start := queryCount()
+		for i := 0; i < b.N; i++ {
+			libraryRequest(b, DashboardHandler, "/")
+		}
+		reportQueries(b, start)
+	})
+	b.Run("tagged", func(b *testing.B) {
+		start := queryCount()
+		for i := 0; i < b.N; i++ {
+			libraryRequest(b, DashboardHandler, "/?tag=tag1")
+		}
+		reportQueries(b, start)
+	})
+}
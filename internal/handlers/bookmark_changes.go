@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/jobs"
+	"infokeep/internal/scraper"
+
+	"golang.org/x/net/html"
+)
+
+// Bookmarked pages can be fetched again every few days to notice when one
+// has changed since it was saved. The readable text of the page is compared
+// line by line with the text from the last check; when enough of it differs,
+// the change goes into the user's activity log, the bookmark's card is marked
+// and the difference can be looked at. Off unless BOOKMARK_RECHECK_DAYS is
+// set: nothing is fetched by default.
+var (
+	bookmarkRecheckDays   = 0
+	bookmarkChangePercent = 10
+	bookmarkRecheckClient = &http.Client{Timeout: 30 * time.Second, Transport: fetchTransport("recheck")}
+)
+
+const (
+	// bookmarkRecheckBatch is how many pages one run of the task fetches
+	bookmarkRecheckBatch = 25
+	// bookmarkMaxPage caps the size of a page that is read
+	bookmarkMaxPage = 2 << 20
+	// diffMaxCells caps the work of a line diff (lines of one text times
+	// lines of the other, once the common start and end are left out);
+	// bigger differences are shown as everything removed and added
+	diffMaxCells = 4_000_000
+	// diffContext is how many unchanged lines are shown around a change
+	diffContext = 2
+)
+
+func init() {
+	if v, err := strconv.Atoi(os.Getenv("BOOKMARK_RECHECK_DAYS")); err == nil && v > 0 {
+		bookmarkRecheckDays = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("BOOKMARK_CHANGE_PERCENT")); err == nil && v > 0 && v <= 100 {
+		bookmarkChangePercent = v
+	}
+}
+
+func registerBookmarkRecheckTask() {
+	if bookmarkRecheckDays == 0 {
+		return
+	}
+	jobs.Register(jobs.Task{
+		Name:        "bookmark_recheck",
+		Description: fmt.Sprintf("Fetch bookmarked pages again every %d days and flag the ones whose text changed by %d%% or more", bookmarkRecheckDays, bookmarkChangePercent),
+		Interval:    time.Hour,
+		Run:         recheckBookmarks,
+	})
+}
+
+// recheckBookmarks checks the pages of a batch of bookmarks that are due
+func recheckBookmarks() (string, error) {
+	due, err := database.GetBookmarksToCheck(bookmarkRecheckDays, bookmarkRecheckBatch)
+	if err != nil {
+		return "", err
+	}
+	changed, failed := 0, 0
+	for _, b := range due {
+		outcome, err := recheckBookmark(b)
+		if err != nil {
+			return "", err
+		}
+		switch outcome {
+		case recheckChanged:
+			changed++
+		case recheckFailed:
+			failed++
+		}
+	}
+	return fmt.Sprintf("Checked %d bookmarks: %d changed, %d could not be read", len(due), changed, failed), nil
+}
+
+// Outcomes of recheckBookmark
+const (
+	recheckSame = iota
+	recheckChanged
+	recheckFailed
+)
+
+// recheckBookmark fetches a bookmark's page and compares its text with the
+// stored one. Errors are those of the database; a page that can't be read
+// is recheckFailed and checked again when it is next due.
+func recheckBookmark(b database.BookmarkToCheck) (int, error) {
+	text, err := fetchPageText(withUserID(context.Background(), b.UserID), bookmarkRecheckClient, b.URL, bookmarkMaxPage)
+	if err != nil || text == "" {
+		return recheckFailed, database.TouchBookmarkSnapshot(b.ID, b.URL)
+	}
+	hash := textHash(text)
+
+	stored, err := database.GetBookmarkSnapshot(b.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	// The first text of a page, or of a new address, is what later checks
+	// are compared with
+	if stored == nil || stored.URL != b.URL || stored.ContentHash == "" {
+		return recheckSame, database.SaveBookmarkSnapshot(b.ID, b.URL, hash, text)
+	}
+	if stored.ContentHash == hash {
+		return recheckSame, database.TouchBookmarkSnapshot(b.ID, b.URL)
+	}
+
+	percent := changePercent(diffLines(splitLines(stored.Content), splitLines(text)))
+	if percent < bookmarkChangePercent {
+		// Small changes (a date, a counter) are left to add up against the
+		// stored text
+		return recheckSame, database.TouchBookmarkSnapshot(b.ID, b.URL)
+	}
+	if err := database.SaveBookmarkChange(b.ID, hash, text, percent); err != nil {
+		return 0, err
+	}
+	recordActivity(b.UserID, database.ActivityEntry{
+		Kind:   database.ActivityChange,
+		Action: fmt.Sprintf("%d%% changed", percent),
+		Target: b.URL,
+		Status: http.StatusOK,
+		ItemID: b.ID,
+	})
+	return recheckChanged, nil
+}
+
+// fetchPageText returns the readable text of the HTML page at url, reading
+// at most limit bytes of it
+func fetchPageText(ctx context.Context, client *http.Client, url string, limit int64) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("page returned %s", resp.Status)
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return "", fmt.Errorf("not an HTML page")
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return "", err
+	}
+	return scraper.ArticleText(doc), nil
+}
+
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// diffLine is a line of a diff. Op is "=" for an unchanged line, "-" for a
+// removed and "+" for an added one, and "…" for a run of unchanged lines
+// left out, whose number is Text.
+type diffLine struct {
+	Op   string
+	Text string
+}
+
+// diffLines returns the lines of a diff from a to b, from their longest
+// common subsequence
+func diffLines(a, b []string) []diffLine {
+	var prefix, suffix []diffLine
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, diffLine{"=", a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]diffLine{{"=", a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	middle := make([]diffLine, 0, len(a)+len(b))
+	if len(a)*len(b) > diffMaxCells {
+		for _, line := range a {
+			middle = append(middle, diffLine{"-", line})
+		}
+		for _, line := range b {
+			middle = append(middle, diffLine{"+", line})
+		}
+		return append(append(prefix, middle...), suffix...)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			middle = append(middle, diffLine{"=", a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			middle = append(middle, diffLine{"-", a[i]})
+			i++
+		default:
+			middle = append(middle, diffLine{"+", b[j]})
+			j++
+		}
+	}
+	return append(append(prefix, middle...), suffix...)
+}
+
+// changePercent returns how much of two texts a diff between them changed:
+// the share of their lines that were removed or added
+func changePercent(diff []diffLine) int {
+	changed, total := 0, 0
+	for _, l := range diff {
+		switch l.Op {
+		case "=":
+			total += 2
+		case "-", "+":
+			changed++
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return changed * 100 / total
+}
+
+// compactDiff leaves out the unchanged lines of a diff that are more than
+// context lines away from a change
+func compactDiff(diff []diffLine, context int) []diffLine {
+	keep := make([]bool, len(diff))
+	for i, l := range diff {
+		if l.Op == "=" {
+			continue
+		}
+		for k := max(0, i-context); k <= min(len(diff)-1, i+context); k++ {
+			keep[k] = true
+		}
+	}
+	var out []diffLine
+	for i := 0; i < len(diff); {
+		if keep[i] {
+			out = append(out, diff[i])
+			i++
+			continue
+		}
+		run := i
+		for i < len(diff) && !keep[i] {
+			i++
+		}
+		out = append(out, diffLine{"…", strconv.Itoa(i - run)})
+	}
+	return out
+}
+
+// BookmarkChangesHandler shows how a bookmark's page changed at its last
+// significant change, and takes the mark off its card
+func BookmarkChangesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	bookmark, err := database.GetBookmark(userID, id)
+	if err != nil {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	snapshot, err := database.GetBookmarkSnapshot(id)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var diff []diffLine
+	if snapshot != nil && snapshot.ChangedAt != "" {
+		diff = compactDiff(diffLines(splitLines(snapshot.PreviousContent), splitLines(snapshot.Content)), diffContext)
+		if !snapshot.ChangeSeen {
+			if err := database.MarkBookmarkChangeSeen(id); err != nil {
+				log.Printf("Failed to mark change of bookmark %d as seen: %v", id, err)
+			}
+		}
+	}
+
+	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
+	RenderTemplate(w, "bookmark_changes.html", map[string]interface{}{
+		"Bookmark":    bookmark,
+		"Snapshot":    snapshot,
+		"Diff":        diff,
+		"RecheckDays": bookmarkRecheckDays,
+		"Tags":        tagsWithCounts,
+		"ActiveTag":   "",
+	})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	a := []string{"title", "one", "two", "three", "footer"}
+	b := []string{"title", "one", "2", "three", "four", "footer"}
+	want := []diffLine{
+		{"=", "title"}, {"=", "one"}, {"-", "two"}, {"+", "2"}, {"=", "three"}, {"+", "four"}, {"=", "footer"},
+	}
+	if got := diffLines(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines = %v, want %v", got, want)
+	}
+
+	if got := diffLines(nil, []string{"new"}); !reflect.DeepEqual(got, []diffLine{{"+", "new"}}) {
+		t.Errorf("diffLines from nothing = %v", got)
+	}
+}
+
+func TestChangePercent(t *testing.T) {
+	same := diffLines([]string{"a", "b"}, []string{"a", "b"})
+	if got := changePercent(same); got != 0 {
+		t.Errorf("changePercent of equal texts = %d, want 0", got)
+	}
+	// One line of ten replaced: 2 changed lines of 20
+	a := strings.Split("0 1 2 3 4 5 6 7 8 9", " ")
+	b := append([]string{}, a...)
+	b[5] = "five"
+	if got := changePercent(diffLines(a, b)); got != 10 {
+		t.Errorf("changePercent = %d, want 10", got)
+	}
+	if got := changePercent(diffLines([]string{"a"}, []string{"b"})); got != 100 {
+		t.Errorf("changePercent of different texts = %d, want 100", got)
+	}
+}
+
+func TestCompactDiff(t *testing.T) {
+	var diff []diffLine
+	for i := 0; i < 10; i++ {
+		diff = append(diff, diffLine{"=", "same"})
+	}
+	diff[6] = diffLine{"+", "added"}
+
+	want := []diffLine{
+		{"…", "4"}, {"=", "same"}, {"=", "same"}, {"+", "added"}, {"=", "same"}, {"=", "same"}, {"…", "1"},
+	}
+	if got := compactDiff(diff, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("compactDiff = %v, want %v", got, want)
+	}
+}
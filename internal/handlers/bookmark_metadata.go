@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+// Bookmarks added or moved to a new address from the web form are saved at
+// once, and their page is read afterwards by a job of the background queue
+// (jobs.go): its canonical URL, preview image and text, and the title and
+// description the user left empty. Until then the bookmark is titled by its
+// address, and its card polls GET /bookmarks/{id}/card for what was found,
+// up to maxCardPolls times.
+// The clipper and quick capture still read the page during the request, as
+// they answer with what they found there.
+
+const bookmarkMetadataJob = "bookmark_metadata"
+
+// maxCardPolls is how often a bookmark's card polls for its page, 2s apart,
+// before it gives up and shows what it has
+const maxCardPolls = 30
+
+// bookmarkMetadataPayload is the job payload of a bookmark whose page is to
+// be read
+type bookmarkMetadataPayload struct {
+	BookmarkID int64  `json:"bookmark_id"`
+	URL        string `json:"url"`
+	// TitleToReplace is the title the bookmark was saved with when the user
+	// gave none
+	TitleToReplace  string `json:"title_to_replace,omitempty"`
+	FillDescription bool   `json:"fill_description,omitempty"`
+	Archive         bool   `json:"archive,omitempty"` // archive a copy of the page too
+}
+
+// queueBookmarkMetadata queues the reading of a bookmark's page
+func queueBookmarkMetadata(userID int64, p bookmarkMetadataPayload) {
+	if err := database.SetBookmarkMetadataPending(p.BookmarkID, true); err != nil {
+		log.Printf("Queueing the page of bookmark %d: %v", p.BookmarkID, err)
+		return
+	}
+	payload, _ := json.Marshal(p)
+	if _, err := enqueueJob(userID, bookmarkMetadataJob, "", string(payload)); err != nil {
+		log.Printf("Queueing the page of bookmark %d: %v", p.BookmarkID, err)
+		database.SetBookmarkMetadataPending(p.BookmarkID, false)
+	}
+}
+
+// runBookmarkMetadataJob reads a bookmark's page and fills in what it found
+func runBookmarkMetadataJob(job *database.Job) (string, error) {
+	var p bookmarkMetadataPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		return "", fmt.Errorf("invalid payload: %w", err)
+	}
+
+	ctx := withUserID(context.Background(), job.UserID)
+	page := fetchBookmarkPage(ctx, p.URL)
+	m := database.BookmarkMetadata{CanonicalURL: page.CanonicalURL, Thumbnail: page.Thumbnail, ArticleText: page.ArticleText}
+	if p.TitleToReplace != "" {
+		m.Title, _ = page.fill("", "")
+	}
+	if p.FillDescription {
+		_, m.Description = page.fill("", "")
+	}
+	if err := database.FillBookmarkMetadata(p.BookmarkID, p.URL, p.TitleToReplace, m); err != nil {
+		// Failed jobs are not retried, so the card is not to wait for it
+		database.SetBookmarkMetadataPending(p.BookmarkID, false)
+		return "", err
+	}
+	if p.Archive {
+		archiveNewBookmark(ctx, job.UserID, p.BookmarkID, page.CanonicalURL)
+	}
+	if b, err := database.GetBookmark(job.UserID, p.BookmarkID); err == nil {
+		itemUpdated(ctx, job.UserID, b.ID, "bookmark", b.Title)
+	}
+	return page.CanonicalURL, nil
+}
+
+// BookmarkCardHandler returns a bookmark's card, which polls it while the
+// page is being read. ?poll= counts the polls so far.
+func BookmarkCardHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	bookmark, err := database.GetBookmark(getUserID(r), id)
+	if err != nil {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	poll, _ := strconv.Atoi(r.URL.Query().Get("poll"))
+	bookmark.CardPoll = poll + 1
+	if bookmark.CardPoll >= maxCardPolls {
+		bookmark.MetadataPending = false
+	}
+	RenderFragment(w, "bookmark_list.html", []models.Bookmark{*bookmark})
+}
+
+// newBookmarkTitle returns the title a bookmark is saved with before its
+// page is read: the user's, or else its address. The second result is the
+// title for the page's to replace, if it is the address.
+func newBookmarkTitle(title, canonicalURL string) (string, string) {
+	if title = strings.TrimSpace(title); title != "" {
+		return title, ""
+	}
+	title = truncateRunes(canonicalURL, maxTitleLength)
+	return title, title
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"infokeep/internal/scraper"
+
+	"golang.org/x/net/html"
+)
+
+// Bookmarks are saved with both the URL the user gave and the page's
+// canonical URL: where the link ends up after redirects, or the page's own
+// <link rel="canonical"> (og:url) when it declares one. Shortened (t.co) and
+// AMP links of a page thus share one canonical URL, which is what duplicates
+// are detected by and what the bookmark's icon is looked up for.
+
+// bookmarkPage is what saving a bookmark learns from fetching its page
+type bookmarkPage struct {
+	CanonicalURL string
+	Title        string // og:title, twitter:title or <title>
+	Description  string // og:description, twitter:description or meta description
+	Thumbnail    string // og:image or twitter:image
+	Keywords     []string
+	ArticleText  string // the readable text of the page, see scraper.ArticleText
+}
+
+// fill returns the title and description a bookmark of the page is saved
+// with: the ones the user gave, or else the page's. A page without a title
+// is titled by its URL.
+func (p bookmarkPage) fill(title, description string) (string, string) {
+	if title = strings.TrimSpace(title); title == "" {
+		title = p.Title
+	}
+	if title == "" {
+		title = p.CanonicalURL
+	}
+	if strings.TrimSpace(description) == "" {
+		description = p.Description
+	}
+	return truncateRunes(title, maxTitleLength), truncateRunes(description, maxLongText)
+}
+
+// fetchBookmarkPage follows targetURL to the page it leads to and reads its
+// canonical URL, title, description, preview image and text. It gives up after a few seconds, or
+// earlier if ctx is cancelled; the canonical URL is then targetURL itself,
+// cleaned (see cleanBookmarkURL).
+func fetchBookmarkPage(ctx context.Context, targetURL string) bookmarkPage {
+	targetURL = cleanBookmarkURL(targetURL)
+	page := bookmarkPage{CanonicalURL: normalizeBookmarkURL(targetURL)}
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: fetchTransport("thumbnail"),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		fmt.Printf("Error creating request for %s: %v\n", targetURL, err)
+		return page
+	}
+
+	// Add a common User-Agent to avoid being blocked by anti-bot protections
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Printf("Error fetching page for %s: %v\n", targetURL, err)
+		return page
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("Page fetch returned status %d for %s\n", resp.StatusCode, targetURL)
+		return page
+	}
+	page.CanonicalURL = normalizeBookmarkURL(resp.Request.URL.String())
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, bookmarkMaxPage))
+	if err != nil {
+		return page
+	}
+	meta := scraper.Extract(doc, resp.Request.URL)
+	page.CanonicalURL = canonicalBookmarkURL(page.CanonicalURL, meta.Canonical)
+	page.Title = strings.TrimSpace(meta.Title)
+	page.Description = strings.TrimSpace(meta.Description)
+	page.Thumbnail = meta.Image
+	page.Keywords = meta.Keywords
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		page.ArticleText = scraper.ArticleText(doc)
+	}
+	return page
+}
+
+// canonicalBookmarkURL returns the canonical URL a page declares, or
+// finalURL (where its redirects ended) if it declares none or something
+// other than an absolute http(s) URL
+func canonicalBookmarkURL(finalURL, declared string) string {
+	u, err := url.Parse(strings.TrimSpace(declared))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return finalURL
+	}
+	return normalizeBookmarkURL(u.String())
+}
+
+// normalizeBookmarkURL cleans a URL, lowercases its scheme and host and drops
+// its fragment and default port, so spellings of one address compare equal
+func normalizeBookmarkURL(rawURL string) string {
+	u, err := url.Parse(cleanBookmarkURL(rawURL))
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
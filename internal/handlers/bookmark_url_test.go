@@ -0,0 +1,38 @@
+package handlers
+
+import "testing"
+
+func TestNormalizeBookmarkURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"HTTPS://Example.COM:443/Path?q=1#top", "https://example.com/Path?q=1"},
+		{"http://example.com:80", "http://example.com/"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"https://[::1]:443/x", "https://[::1]/x"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		if got := normalizeBookmarkURL(tt.in); got != tt.want {
+			t.Errorf("normalizeBookmarkURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalBookmarkURL(t *testing.T) {
+	final := "https://news.example/amp/story"
+	tests := []struct {
+		declared, want string
+	}{
+		{"https://news.example/story#comments", "https://news.example/story"},
+		{"", final},
+		{"/story", final},
+		{"javascript:alert(1)", final},
+		{"ftp://news.example/story", final},
+	}
+	for _, tt := range tests {
+		if got := canonicalBookmarkURL(final, tt.declared); got != tt.want {
+			t.Errorf("canonicalBookmarkURL(%q) = %q, want %q", tt.declared, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+)
+
+// Items can be changed many at a time: the list pages have a checkbox on
+// each card, and the selection bar in layout.html posts the ticked ones to
+// POST /items/bulk with the action to take (delete, tag-add, tag-remove,
+// archive, unarchive, pin, unpin). All of them change in one transaction.
+
+// bulkRequest is a bulk action and the items it applies to
+type bulkRequest struct {
+	IDs    []int64             `json:"ids"`
+	Action database.BulkAction `json:"action"`
+	Tag    string              `json:"tag"`
+}
+
+// parseBulkRequest reads a bulk request from a JSON body or, from the
+// selection bar, form values: ids (repeated or comma separated), action and
+// tag
+func parseBulkRequest(r *http.Request) (bulkRequest, error) {
+	var req bulkRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, err
+		}
+		return req, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return req, err
+	}
+	for _, value := range r.Form["ids"] {
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			id, err := parseID(s)
+			if err != nil {
+				return req, fmt.Errorf("invalid item id %q", s)
+			}
+			req.IDs = append(req.IDs, id)
+		}
+	}
+	req.Action = database.BulkAction(r.FormValue("action"))
+	req.Tag = r.FormValue("tag")
+	return req, nil
+}
+
+// validate checks a bulk request the way the form validation does
+func (req *bulkRequest) validate() validation.Errors {
+	var v validation.Validator
+	if len(req.IDs) == 0 {
+		v.Add("ids", "select at least one item")
+	} else if len(req.IDs) > database.MaxBulkItems {
+		v.Add("ids", fmt.Sprintf("at most %d items at a time", database.MaxBulkItems))
+	}
+	if !database.ValidBulkAction(req.Action) {
+		v.Add("action", "is not a known action")
+	}
+	if req.Action == database.BulkAddTag || req.Action == database.BulkRemoveTag {
+		req.Tag = v.Required("tag", req.Tag, maxTitleLength)
+	}
+	if v.Valid() {
+		return nil
+	}
+	return v.Errors()
+}
+
+// BulkItemsHandler applies one action to many of the user's items at once.
+// Either all items change or, if one of them is not the user's or is in the
+// trash, none does.
+func BulkItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	req, err := parseBulkRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errs := req.validate(); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	err = database.BulkUpdateItems(userID, req.IDs, req.Action, req.Tag)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"action": req.Action, "count": len(req.IDs)})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"infokeep/internal/database"
+)
+
+const (
+	recipeImportJob = "recipe_import"
+	// maxBulkImportURLs caps a single bulk import
+	maxBulkImportURLs = 200
+)
+
+// recipeImportPayload is the job payload of a single bulk-imported recipe URL
+type recipeImportPayload struct {
+	URL   string   `json:"url"`
+	Tags  []string `json:"tags,omitempty"`
+	Email string   `json:"email,omitempty"`
+}
+
+// BulkImportRecipesHandler queues a list of recipe URLs (one per line) for import
+func BulkImportRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+	}
+	tags := parseTags(r.FormValue("tags"))
+
+	var urls, invalid []string
+	seen := make(map[string]bool)
+	for _, line := range splitLines(r.FormValue("urls")) {
+		u, err := url.Parse(line)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid = append(invalid, line)
+			continue
+		}
+		if !seen[line] {
+			seen[line] = true
+			urls = append(urls, line)
+		}
+	}
+	if len(urls) == 0 {
+		http.Error(w, "No valid recipe URLs given", http.StatusBadRequest)
+		return
+	}
+	if len(urls) > maxBulkImportURLs {
+		http.Error(w, fmt.Sprintf("At most %d URLs can be imported at once", maxBulkImportURLs), http.StatusBadRequest)
+		return
+	}
+
+	batchID, err := generateSecureHash(12)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, u := range urls {
+		payload, _ := json.Marshal(recipeImportPayload{URL: u, Tags: tags, Email: email})
+		if _, err := enqueueJob(userID, recipeImportJob, batchID, string(payload)); err != nil {
+			log.Printf("Failed to enqueue recipe import for user %d: %v", userID, err)
+			http.Error(w, "Failed to queue import", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch_id": batchID,
+		"queued":   len(urls),
+		"invalid":  invalid,
+	})
+}
+
+// RecipeImportStatusHandler returns the per-URL results of a bulk import
+func RecipeImportStatusHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	jobs, err := database.GetBatchJobs(r.URL.Query().Get("batch"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type importResult struct {
+		URL      string `json:"url"`
+		Status   string `json:"status"`
+		RecipeID int64  `json:"recipe_id,omitempty"`
+		Error    string `json:"error,omitempty"`
+	}
+	results := []importResult{}
+	for _, job := range jobs {
+		if job.UserID != userID {
+			continue
+		}
+		var p recipeImportPayload
+		json.Unmarshal([]byte(job.Payload), &p)
+		res := importResult{URL: p.URL, Status: job.Status}
+		switch job.Status {
+		case database.JobDone:
+			res.RecipeID, _ = strconv.ParseInt(job.Result, 10, 64)
+		case database.JobFailed:
+			res.Error = job.Result
+		}
+		results = append(results, res)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// runRecipeImportJob imports one recipe URL and returns the new recipe's ID
+func runRecipeImportJob(job *database.Job) (string, error) {
+	var p recipeImportPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		return "", fmt.Errorf("invalid payload: %w", err)
+	}
+
+	ctx := withUserID(context.Background(), job.UserID)
+	recipeData, err := ParseRecipeFromURL(ctx, p.URL)
+	if err != nil {
+		return "", err
+	}
+	if recipeData.Title == "" {
+		recipeData.Title = p.URL
+	}
+
+	original := translateRecipe(ctx, recipeData)
+	itemID, err := database.CreateRecipe(job.UserID, recipeData.Title, strings.Join(recipeData.Ingredients, "\n"),
+		recipeData.Instructions, "", recipeData.Image, p.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to save recipe: %w", err)
+	}
+	if original != nil {
+		database.SetRecipeOriginal(itemID, original.Language, original.Ingredients, original.Instructions)
+	}
+	if len(p.Tags) > 0 {
+		database.SetItemTags(itemID, p.Tags)
+	}
+	itemCreated(ctx, job.UserID, itemID, "recipe", recipeData.Title)
+	return strconv.FormatInt(itemID, 10), nil
+}
+
+// recipeImportBatchDone notifies the user of the outcome of a bulk import
+func recipeImportBatchDone(userID int64, jobs []database.Job) {
+	var failed []string
+	email := ""
+	for _, job := range jobs {
+		var p recipeImportPayload
+		json.Unmarshal([]byte(job.Payload), &p)
+		if p.Email != "" {
+			email = p.Email
+		}
+		if job.Status == database.JobFailed {
+			failed = append(failed, fmt.Sprintf("- %s\n  %s", p.URL, job.Result))
+		}
+	}
+	imported := len(jobs) - len(failed)
+
+	summary := fmt.Sprintf("Imported %d of %d recipes", imported, len(jobs))
+	if len(failed) > 0 {
+		summary += fmt.Sprintf(", %d failed", len(failed))
+	}
+	log.Printf("Worker: Bulk recipe import for user %d finished: %s", userID, summary)
+
+	sendPushToUser(userID, "InfoKeep recipe import finished", summary+".")
+
+	if email != "" {
+		body := summary + ".\n"
+		if len(failed) > 0 {
+			body += "\nThese URLs could not be imported:\n\n" + strings.Join(failed, "\n") + "\n"
+		}
+		if err := sendMail([]string{email}, "InfoKeep: "+summary, body); err != nil {
+			log.Printf("Worker: Failed to email bulk import summary to user %d: %v", userID, err)
+		}
+	}
+}
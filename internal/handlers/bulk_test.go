@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"infokeep/internal/database"
+)
+
+func TestParseBulkRequest(t *testing.T) {
+	tests := []struct {
+		contentType, body string
+		want              bulkRequest
+		wantErr           bool
+	}{
+		{"application/x-www-form-urlencoded", "ids=1&ids=2&action=pin",
+			bulkRequest{IDs: []int64{1, 2}, Action: database.BulkPin}, false},
+		{"application/x-www-form-urlencoded", "ids=3,+4,&action=tag-add&tag=old",
+			bulkRequest{IDs: []int64{3, 4}, Action: database.BulkAddTag, Tag: "old"}, false},
+		{"application/json", `{"ids": [5], "action": "delete"}`,
+			bulkRequest{IDs: []int64{5}, Action: database.BulkDelete}, false},
+		{"application/x-www-form-urlencoded", "ids=x&action=pin", bulkRequest{}, true},
+		{"application/json", `{"ids": "5"}`, bulkRequest{}, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/items/bulk", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+		got, err := parseBulkRequest(r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBulkRequest(%q) error = %v, want error %v", tt.body, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseBulkRequest(%q) = %+v, want %+v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestBulkRequestValidate(t *testing.T) {
+	tests := []struct {
+		req    bulkRequest
+		fields []string
+	}{
+		{bulkRequest{IDs: []int64{1}, Action: database.BulkArchive}, nil},
+		{bulkRequest{Action: database.BulkArchive}, []string{"ids"}},
+		{bulkRequest{IDs: make([]int64, database.MaxBulkItems+1), Action: database.BulkPin}, []string{"ids"}},
+		{bulkRequest{IDs: []int64{1}, Action: "explode"}, []string{"action"}},
+		{bulkRequest{IDs: []int64{1}, Action: database.BulkRemoveTag, Tag: "  "}, []string{"tag"}},
+	}
+	for _, tt := range tests {
+		var fields []string
+		for _, e := range tt.req.validate() {
+			fields = append(fields, e.Field)
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("validate(%+v) errors on %v, want %v", tt.req.Action, fields, tt.fields)
+		}
+	}
+}
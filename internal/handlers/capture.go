@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+)
+
+// Quick capture saves a single piece of text without asking what it is:
+// POST /capture (session) or /api/capture (token) with text, as a form
+// value or in a JSON body {"text": "...", "tags": "..."}. A link becomes a
+// bookmark, lines starting with "- " a checklist and anything else a note.
+// It suits a share target or a one-line shell alias.
+
+// capture is what a captured text turns into
+type capture struct {
+	Type    string   // bookmark, list or note
+	Title   string   // the title the text gives, if any
+	URL     string   // of a bookmark
+	Entries []string // of a checklist
+}
+
+// parseCapture decides what text is. A single line ending in an http(s)
+// URL is a bookmark; the words before the URL, as a phone shares a page
+// ("Article title https://..."), are its title if the page has none. Lines that all start with a list
+// marker, after an optional first line that is the title, are a checklist.
+func parseCapture(text string) capture {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if !strings.Contains(text, "\n") {
+		words := strings.Fields(text)
+		if n := len(words); n > 0 && isWebURL(words[n-1]) {
+			return capture{Type: "bookmark", Title: strings.Join(words[:n-1], " "), URL: words[n-1]}
+		}
+	}
+
+	lines := strings.Split(text, "\n")
+	title := ""
+	if !isChecklistLine(lines[0]) {
+		title, lines = strings.TrimSpace(lines[0]), lines[1:]
+	}
+	entries := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !isChecklistLine(line) {
+			return capture{Type: "note"}
+		}
+		entries++
+	}
+	if entries == 0 {
+		return capture{Type: "note"}
+	}
+	return capture{Type: "list", Title: title, Entries: checklistLines(strings.Join(lines, "\n"))}
+}
+
+// isChecklistLine reports whether line starts with a list marker
+func isChecklistLine(line string) bool {
+	line = strings.TrimSpace(line)
+	for _, marker := range checklistMarkers {
+		if strings.HasPrefix(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWebURL reports whether s is an absolute http or https URL
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// CaptureHandler creates the bookmark, checklist or note a text is and
+// returns {"id", "type", "title"} with 201. A link that is already
+// bookmarked returns that bookmark with status "exists" and 200.
+func CaptureHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	var input struct {
+		Text string `json:"text"`
+		Tags string `json:"tags"`
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		input.Text = r.FormValue("text")
+		input.Tags = r.FormValue("tags")
+	}
+	var v validation.Validator
+	text := v.Required("text", input.Text, maxLongText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	c := parseCapture(text)
+	var itemID int64
+	var err error
+	switch c.Type {
+	case "bookmark":
+		page := fetchBookmarkPage(r.Context(), c.URL)
+		if existingID, existing, err := database.FindBookmarkByURL(userID, page.CanonicalURL); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": existingID, "type": "bookmark", "title": existing, "status": "exists"})
+			return
+		}
+		if page.Title != "" {
+			c.Title = page.Title
+		} else if c.Title == "" {
+			c.Title = page.CanonicalURL
+		}
+		c.Title = truncateRunes(c.Title, maxTitleLength)
+		itemID, err = database.CreateBookmark(userID, c.Title, c.URL, page.CanonicalURL, "", "", page.Thumbnail)
+		if err == nil {
+			database.SetBookmarkArticleText(itemID, page.ArticleText)
+		}
+	case "list":
+		if c.Title == "" {
+			c.Title = "Checklist " + time.Now().Format("2006-01-02")
+		}
+		c.Title = truncateRunes(c.Title, maxTitleLength)
+		itemID, err = database.CreateList(userID, c.Title)
+		for _, entry := range c.Entries {
+			if err != nil {
+				break
+			}
+			_, err = database.AddListItem(itemID, truncateRunes(entry, maxShortText))
+		}
+	default:
+		c.Title = voiceNoteTitle(text, time.Now().Format("2006-01-02 15:04"))
+		itemID, err = database.CreateNote(userID, c.Title, text)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if tags := parseTags(input.Tags); len(tags) > 0 {
+		database.SetItemTags(itemID, tags)
+	}
+	itemCreated(r.Context(), userID, itemID, c.Type, c.Title)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "type": c.Type, "title": c.Title, "status": "created"})
+}
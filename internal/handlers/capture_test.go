@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCapture(t *testing.T) {
+	tests := []struct {
+		text string
+		want capture
+	}{
+		{"https://example.com/bread", capture{Type: "bookmark", URL: "https://example.com/bread"}},
+		{"  Rye bread basics https://example.com/rye \n", capture{Type: "bookmark", Title: "Rye bread basics", URL: "https://example.com/rye"}},
+		{"- milk\n- eggs", capture{Type: "list", Entries: []string{"milk", "eggs"}}},
+		{"Packing\r\n- passport\r\n\r\n* charger", capture{Type: "list", Title: "Packing", Entries: []string{"passport", "charger"}}},
+		{"Call the bakery about rye flour", capture{Type: "note"}},
+		{"Read this\nhttps://example.com/rye", capture{Type: "note"}},
+		{"Shopping\n- milk\nand whatever is on sale", capture{Type: "note"}},
+		{"ftp://example.com/file", capture{Type: "note"}},
+	}
+	for _, tt := range tests {
+		if got := parseCapture(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCapture(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// trustedProxies are the reverse proxies (TRUSTED_PROXIES, CIDR ranges or
+// addresses separated by commas) whose X-Forwarded-For is believed. Without
+// any, the header is ignored unless the server listens on a unix socket,
+// which only a local proxy can connect to.
+var trustedProxies []*net.IPNet
+
+func init() {
+	nets, err := parseAllowlist(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Printf("Ignoring TRUSTED_PROXIES: %v", err)
+		return
+	}
+	trustedProxies = nets
+}
+
+// clientIP returns the originating client address. A proxy appends the
+// address it got the request from to X-Forwarded-For, and anything to its
+// left came from the client, who can write what they like there. So the
+// header is read from the right, past the trusted proxies, and the first
+// address no trusted proxy could have added is the client's.
+func clientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remote = host
+	}
+	local, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	overSocket := local != nil && local.Network() == "unix"
+	if !overSocket && !ipAllowed(remote, trustedProxies) {
+		return remote
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// Not an address a proxy would write; what is left of it
+			// can't be told apart from the client's own
+			break
+		}
+		client = hop
+		if !ipAllowed(hop, trustedProxies) {
+			break
+		}
+	}
+	return client
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"infokeep/internal/database"
+)
+
+// withTrustedProxies sets TRUSTED_PROXIES for the rest of the test
+func withTrustedProxies(t *testing.T, ranges string) {
+	t.Helper()
+	nets, err := parseAllowlist(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := trustedProxies
+	trustedProxies = nets
+	t.Cleanup(func() { trustedProxies = saved })
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name, trusted, remote, forwarded, want string
+	}{
+		{"no proxies trusted", "", "10.0.0.2:4000", "203.0.113.9", "10.0.0.2"},
+		{"untrusted peer", "10.0.0.0/8", "198.51.100.7:4000", "203.0.113.9", "198.51.100.7"},
+		{"behind a trusted proxy", "10.0.0.0/8", "10.0.0.2:4000", "203.0.113.9", "203.0.113.9"},
+		{"forged entry on the left", "10.0.0.0/8", "10.0.0.2:4000", "10.1.2.3, 203.0.113.9", "203.0.113.9"},
+		{"two trusted proxies", "10.0.0.0/8", "10.0.0.2:4000", "203.0.113.9, 10.0.0.3", "203.0.113.9"},
+		{"only trusted hops", "10.0.0.0/8", "10.0.0.2:4000", "10.0.0.3", "10.0.0.3"},
+		{"garbage in the header", "10.0.0.0/8", "10.0.0.2:4000", "203.0.113.9, junk", "10.0.0.2"},
+		{"no header", "10.0.0.0/8", "10.0.0.2:4000", "", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		withTrustedProxies(t, tt.trusted)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := clientIP(r); got != tt.want {
+			t.Errorf("%s: clientIP = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// A proxy on the unix socket is trusted without being listed
+	withTrustedProxies(t, "")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "@"
+	r.Header.Set("X-Forwarded-For", "10.1.2.3, 203.0.113.9")
+	r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, &net.UnixAddr{Name: "/run/infokeep.sock", Net: "unix"}))
+	if got := clientIP(r); got != "203.0.113.9" {
+		t.Errorf("over the socket: clientIP = %s, want 203.0.113.9", got)
+	}
+}
+
+func TestTokenAllowlistForgedForwardedFor(t *testing.T) {
+	if err := database.InitDB(filepath.Join(t.TempDir(), "allowlist.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	if _, err := database.DB.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := database.GetAPIToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetTokenAllowlist(1, "10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	ok := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name, trusted, remote, forwarded string
+		want                             int
+	}{
+		{"forged, no proxies trusted", "", "127.0.0.1:4000", "10.1.2.3", http.StatusForbidden},
+		{"forged behind a trusted proxy", "127.0.0.0/8", "127.0.0.1:4000", "10.1.2.3, 203.0.113.9", http.StatusForbidden},
+		{"allowed client behind a trusted proxy", "127.0.0.0/8", "127.0.0.1:4000", "203.0.113.9, 10.1.2.3", http.StatusOK},
+		{"allowed peer", "", "10.1.2.3:4000", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		withTrustedProxies(t, tt.trusted)
+		r := httptest.NewRequest(http.MethodGet, "/api/notes", nil)
+		r.RemoteAddr = tt.remote
+		r.Header.Set("Authorization", "Bearer "+token)
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		w := httptest.NewRecorder()
+		ok.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"infokeep/internal/database"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxCommentAuthorLen = 60
+	maxCommentBodyLen   = 2000
+	// Guests may leave at most this many comments per IP address per window
+	commentRateLimit       = 5
+	commentRateLimitWindow = 10 // minutes
+)
+
+// PublicCommentHandler accepts a comment from a guest viewing a shared recipe.
+// Comments are held for moderation in the owner's inbox.
+func PublicCommentHandler(w http.ResponseWriter, r *http.Request) {
+	hash := chi.URLParam(r, "hash")
+	link, err := database.GetSharedLinkByHash(hash)
+	if err != nil || link.LinkHash == "" {
+		http.Error(w, "This link is invalid or has been revoked.", http.StatusNotFound)
+		return
+	}
+	if link.Expired() {
+		http.Error(w, "This link has expired.", http.StatusGone)
+		return
+	}
+	if link.ItemType != "recipe" {
+		http.Error(w, "Comments are not available for this item", http.StatusBadRequest)
+		return
+	}
+
+	redirect := func(status string) {
+		redirectTo(w, r, fmt.Sprintf("/shared/%s?comment=%s#comments", hash, status), http.StatusSeeOther)
+	}
+
+	// Hidden field that people never fill in but naive bots do
+	if r.FormValue("website") != "" {
+		redirect("pending")
+		return
+	}
+
+	author := strings.TrimSpace(r.FormValue("author_name"))
+	body := strings.TrimSpace(r.FormValue("body"))
+	if author == "" || body == "" {
+		redirect("empty")
+		return
+	}
+	if len([]rune(author)) > maxCommentAuthorLen || len([]rune(body)) > maxCommentBodyLen {
+		redirect("too_long")
+		return
+	}
+
+	ip := clientIP(r)
+	if count, err := database.CountRecentCommentsByIP(ip, commentRateLimitWindow); err == nil && count >= commentRateLimit {
+		redirect("rate_limited")
+		return
+	}
+
+	if _, err := database.CreateComment(link.UserID, link.ItemID, link.LinkHash, author, body, ip); err != nil {
+		log.Printf("Failed to save comment on %s: %v", hash, err)
+		http.Error(w, "Failed to save comment", http.StatusInternalServerError)
+		return
+	}
+
+	redirect("pending")
+}
+
+// InboxHandler lists comments awaiting moderation (or, with ?status=, approved or rejected ones)
+func InboxHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	status := r.URL.Query().Get("status")
+	switch status {
+	case database.CommentApproved, database.CommentRejected:
+	default:
+		status = database.CommentPending
+	}
+
+	comments, err := database.GetInboxComments(userID, status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
+	RenderTemplate(w, "inbox.html", map[string]interface{}{
+		"Comments":     comments,
+		"Status":       status,
+		"PendingCount": database.CountPendingComments(userID),
+		"Tags":         tagsWithCounts,
+		"ActiveTag":    "",
+	})
+}
+
+// ModerateCommentHandler approves or rejects a comment (form value action)
+func ModerateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var status string
+	switch r.FormValue("action") {
+	case "approve":
+		status = database.CommentApproved
+	case "reject":
+		status = database.CommentRejected
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.SetCommentStatus(userID, id, status); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The comment leaves the current inbox tab; htmx swaps the row out
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteCommentHandler permanently deletes a comment
+func DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteComment(userID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,613 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// CookbooksHandler renders the cookbook browse page and handles creation
+func CookbooksHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if r.Method == http.MethodPost {
+		r.ParseMultipartForm(10 << 20) // 10MB max
+		var v validation.Validator
+		title := v.Required("title", r.FormValue("title"), maxTitleLength)
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
+
+		cover := ""
+		file, header, fileErr := r.FormFile("cover")
+		if fileErr == nil && header.Size > 0 {
+			defer file.Close()
+			cover = saveCookbookCover(file, header)
+		}
+
+		itemID, err := database.CreateCookbook(userID, title, r.FormValue("description"), cover)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		tags := parseTags(r.FormValue("tags"))
+		if len(tags) > 0 {
+			database.SetItemTags(itemID, tags)
+		}
+		itemCreated(r.Context(), userID, itemID, "cookbook", title)
+
+		// Adding the first recipe straight from a recipe page
+		if recipeID, err := strconv.ParseInt(r.FormValue("recipe_id"), 10, 64); err == nil && recipeID > 0 {
+			database.AddRecipeToCookbook(userID, itemID, recipeID)
+			redirectTo(w, r, fmt.Sprintf("/recipes/%d", recipeID), http.StatusSeeOther)
+			return
+		}
+
+		redirectTo(w, r, "/cookbooks/"+itemSlug(itemID, title), http.StatusSeeOther)
+		return
+	}
+
+	tagFilter := r.URL.Query().Get("tag")
+	if r.Header.Get("HX-Request") != "" {
+		renderListPage(w, r, "cookbooks", userID, tagFilter)
+		return
+	}
+
+	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
+	RenderTemplate(w, "cookbooks.html", map[string]interface{}{
+		"Tags":      tagsWithCounts,
+		"ActiveTag": tagFilter,
+		"Archived":  showArchived(r),
+		"SortMenu":  sortMenu(r, userID, "cookbooks"),
+	})
+}
+
+// GetCookbookHandler renders a single cookbook with its recipes
+func GetCookbookHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	cookbook, err := database.GetCookbook(userID, id)
+	if err != nil {
+		http.Error(w, "Cookbook not found", http.StatusNotFound)
+		return
+	}
+	recordView(userID, id)
+
+	recipes, _ := database.GetCookbookRecipes(userID, id)
+
+	// Recipes not yet in this cookbook, for the "add recipe" picker
+	inCookbook := make(map[int64]bool)
+	for _, rec := range recipes {
+		inCookbook[rec.ID] = true
+	}
+	allRecipes, _ := database.GetRecipes(userID, "")
+	var available []models.Recipe
+	for _, rec := range allRecipes {
+		if !inCookbook[rec.ID] {
+			available = append(available, rec)
+		}
+	}
+
+	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
+	RenderTemplate(w, "cookbook_detail.html", map[string]interface{}{
+		"Cookbook":         cookbook,
+		"Recipes":          recipes,
+		"AvailableRecipes": available,
+		"Tags":             tagsWithCounts,
+		"ActiveTag":        "",
+	})
+}
+
+// UpdateCookbookHandler saves name, description, tags and optionally a new cover
+func UpdateCookbookHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	r.ParseMultipartForm(10 << 20) // 10MB max
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	cover := ""
+	file, header, fileErr := r.FormFile("cover")
+	if fileErr == nil && header.Size > 0 {
+		defer file.Close()
+		cover = saveCookbookCover(file, header)
+	}
+
+	if err := database.UpdateCookbook(userID, id, title, r.FormValue("description"), cover); err != nil {
+		http.Error(w, "Cookbook not found", http.StatusNotFound)
+		return
+	}
+	database.SetItemTags(id, parseTags(r.FormValue("tags")))
+	itemUpdated(r.Context(), userID, id, "cookbook", title)
+
+	redirectTo(w, r, "/cookbooks/"+itemSlug(id, title), http.StatusSeeOther)
+}
+
+// DeleteCookbookHandler moves a cookbook to the trash; the recipes
+// themselves are kept
+func DeleteCookbookHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, err := database.GetCookbook(userID, id); err != nil {
+		http.Error(w, "Cookbook not found", http.StatusNotFound)
+		return
+	}
+
+	if err := database.TrashItem(userID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", appURL("/cookbooks"))
+	w.WriteHeader(http.StatusOK)
+}
+
+// AddCookbookRecipeHandler adds a recipe to a cookbook. The form may come from
+// the cookbook page (recipe_id) or from a recipe page (redirect back there).
+func AddCookbookRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	cookbookID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	recipeID, err := strconv.ParseInt(r.FormValue("recipe_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.AddRecipeToCookbook(userID, cookbookID, recipeID); err != nil {
+		http.Error(w, "Cookbook or recipe not found", http.StatusNotFound)
+		return
+	}
+
+	redirect := fmt.Sprintf("/cookbooks/%d", cookbookID)
+	if r.FormValue("return") == "recipe" {
+		redirect = fmt.Sprintf("/recipes/%d", recipeID)
+	}
+	redirectTo(w, r, redirect, http.StatusSeeOther)
+}
+
+// RemoveCookbookRecipeHandler removes a recipe from a cookbook
+func RemoveCookbookRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	cookbookID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	recipeID, ok := pathID(w, r, "recipeID")
+	if !ok {
+		return
+	}
+
+	if err := database.RemoveRecipeFromCookbook(userID, cookbookID, recipeID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ExportCookbookHandler exports a cookbook as Markdown (default) or PDF
+func ExportCookbookHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	cookbook, err := database.GetCookbook(userID, id)
+	if err != nil {
+		http.Error(w, "Cookbook not found", http.StatusNotFound)
+		return
+	}
+
+	recipes, err := database.GetCookbookRecipes(userID, id)
+	if err != nil {
+		http.Error(w, "Failed to fetch recipes", http.StatusInternalServerError)
+		return
+	}
+
+	writeRecipeExport(w, r, cookbook.Title, cookbook.Description, cookbook.CoverImage, recipes)
+}
+
+// ExportRecipesHandler exports all recipes, or those with ?tag=, as Markdown or PDF
+func ExportRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	tagFilter := r.URL.Query().Get("tag")
+	recipes, err := database.GetRecipes(userID, tagFilter)
+	if err != nil {
+		http.Error(w, "Failed to fetch recipes", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(recipes, func(i, j int) bool {
+		return strings.ToLower(recipes[i].Title) < strings.ToLower(recipes[j].Title)
+	})
+
+	title := "Recipes"
+	if tagFilter != "" {
+		title = "Recipes: " + tagFilter
+	}
+	writeRecipeExport(w, r, title, "", "", recipes)
+}
+
+// writeRecipeExport writes recipes as a Markdown or PDF download depending on ?format=
+func writeRecipeExport(w http.ResponseWriter, r *http.Request, title, description, coverImage string, recipes []models.Recipe) {
+	filename := exportFilename(title)
+
+	switch r.URL.Query().Get("format") {
+	case "", "md", "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.md\"", filename))
+		io.WriteString(w, renderCookbookMarkdown(title, description, recipes))
+
+	case "pdf":
+		var buf bytes.Buffer
+		if err := renderCookbookPDF(r.Context(), &buf, title, description, coverImage, recipes); err != nil {
+			log.Printf("Recipe PDF export %q failed: %v", title, err)
+			http.Error(w, "Failed to generate PDF", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.pdf\"", filename))
+		w.Write(buf.Bytes())
+
+	default:
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+	}
+}
+
+var exportFilenameRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// exportFilename turns a title into a safe, lowercase file name stem
+func exportFilename(title string) string {
+	name := strings.Trim(exportFilenameRegex.ReplaceAllString(strings.ToLower(title), "_"), "_")
+	if name == "" {
+		name = "cookbook"
+	}
+	return name
+}
+
+// splitLines splits a newline-separated recipe field into trimmed, non-empty lines
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			lines = append(lines, trimmed)
+		}
+	}
+	return lines
+}
+
+func renderCookbookMarkdown(title, description string, recipes []models.Recipe) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	if description != "" {
+		fmt.Fprintf(&sb, "%s\n\n", description)
+	}
+
+	if len(recipes) > 0 {
+		sb.WriteString("## Contents\n\n")
+		for _, rec := range recipes {
+			fmt.Fprintf(&sb, "- %s\n", rec.Title)
+		}
+		sb.WriteString("\n")
+	}
+
+	for _, rec := range recipes {
+		fmt.Fprintf(&sb, "---\n\n## %s\n\n", rec.Title)
+		if rec.SourceURL != "" {
+			fmt.Fprintf(&sb, "Source: <%s>\n\n", rec.SourceURL)
+		}
+		if len(rec.Tags) > 0 {
+			fmt.Fprintf(&sb, "Tags: %s\n\n", strings.Join(rec.Tags, ", "))
+		}
+
+		sb.WriteString("### Ingredients\n\n")
+		for _, line := range splitLines(rec.Ingredients) {
+			fmt.Fprintf(&sb, "- %s\n", line)
+		}
+		sb.WriteString("\n### Instructions\n\n")
+		for _, line := range splitLines(rec.Instructions) {
+			fmt.Fprintf(&sb, "%s\n\n", line)
+		}
+		if notes := strings.TrimSpace(rec.Notes); notes != "" {
+			fmt.Fprintf(&sb, "### Notes\n\n%s\n\n", notes)
+		}
+	}
+	return sb.String()
+}
+
+const (
+	pdfAccent     = 180 // red channel of headings and rules
+	pdfImageMaxH  = 70.0
+	pdfCoverMaxH  = 90.0
+	pdfImageLimit = 10 << 20
+)
+
+// renderCookbookPDF typesets recipes as a book: a title page, a linked table
+// of contents with page numbers, then one or more pages per recipe.
+func renderCookbookPDF(ctx context.Context, w io.Writer, title, description, coverImage string, recipes []models.Recipe) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // core fonts are cp1252
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("InfoKeep", true)
+
+	contentWidth, _ := pdf.GetPageSize()
+	contentWidth -= 40
+
+	// Running header and page numbers on everything but the title page
+	pdf.SetHeaderFuncMode(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(140, 140, 140)
+		pdf.CellFormat(0, 5, tr(title), "", 0, "R", false, 0, "")
+		pdf.Ln(10)
+		pdf.SetTextColor(0, 0, 0)
+	}, true)
+	pdf.SetFooterFunc(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(140, 140, 140)
+		pdf.CellFormat(0, 5, strconv.Itoa(pdf.PageNo()), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	heading := func(text string) {
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "B", 13)
+		pdf.SetTextColor(pdfAccent, 40, 40)
+		pdf.CellFormat(0, 8, text, "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetFont("Helvetica", "", 11)
+	}
+
+	// Title page
+	pdf.AddPage()
+	pdf.Ln(30)
+	if name, iw, ih, ok := registerPDFImage(ctx, pdf, coverImage); ok {
+		dw, dh := fitImage(iw, ih, contentWidth, pdfCoverMaxH)
+		pdf.ImageOptions(name, 20+(contentWidth-dw)/2, pdf.GetY(), dw, dh, false, fpdf.ImageOptions{}, 0, "")
+		pdf.SetY(pdf.GetY() + dh + 12)
+	} else {
+		pdf.Ln(30)
+	}
+	pdf.SetFont("Helvetica", "B", 30)
+	pdf.MultiCell(0, 13, tr(title), "", "C", false)
+	if description != "" {
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.SetTextColor(90, 90, 90)
+		pdf.MultiCell(0, 6, tr(description), "", "C", false)
+		pdf.SetTextColor(0, 0, 0)
+	}
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "I", 10)
+	pdf.SetTextColor(140, 140, 140)
+	pdf.CellFormat(0, 6, fmt.Sprintf("%d recipes - %s", len(recipes), time.Now().Format("January 2006")), "", 1, "C", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+
+	// Table of contents. Page numbers are aliases resolved once every recipe
+	// has been laid out, and each entry links to its recipe page.
+	links := make([]int, len(recipes))
+	if len(recipes) > 0 {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "B", 20)
+		pdf.CellFormat(0, 12, "Contents", "", 1, "L", false, 0, "")
+		pdf.Ln(2)
+		pdf.SetFont("Helvetica", "", 11)
+		for i, rec := range recipes {
+			links[i] = pdf.AddLink()
+			alias := fmt.Sprintf("{toc%d}", i)
+			pdf.CellFormat(contentWidth-15, 7, tr(truncateRunes(rec.Title, 80)), "B", 0, "L", false, links[i], "")
+			pdf.CellFormat(15, 7, alias, "B", 1, "R", false, links[i], "")
+		}
+	}
+
+	for i, rec := range recipes {
+		pdf.AddPage()
+		pdf.SetLink(links[i], 0, -1)
+		pdf.RegisterAlias(fmt.Sprintf("{toc%d}", i), strconv.Itoa(pdf.PageNo()))
+
+		pdf.SetFont("Helvetica", "B", 22)
+		pdf.MultiCell(0, 10, tr(rec.Title), "", "L", false)
+
+		var meta []string
+		if len(rec.Tags) > 0 {
+			meta = append(meta, strings.Join(rec.Tags, ", "))
+		}
+		if rec.SourceURL != "" {
+			meta = append(meta, rec.SourceURL)
+		}
+		if len(meta) > 0 {
+			pdf.SetFont("Helvetica", "I", 9)
+			pdf.SetTextColor(110, 110, 110)
+			pdf.MultiCell(0, 5, tr(strings.Join(meta, "  |  ")), "", "L", false)
+			pdf.SetTextColor(0, 0, 0)
+		}
+		pdf.SetDrawColor(pdfAccent, 40, 40)
+		pdf.Line(20, pdf.GetY()+2, 20+contentWidth, pdf.GetY()+2)
+		pdf.SetDrawColor(0, 0, 0)
+		pdf.Ln(5)
+
+		// Prefer the recipe's thumbnail, falling back to its first uploaded photo
+		image := rec.Thumbnail
+		if image == "" {
+			if images, _ := database.GetRecipeImages(rec.ID); len(images) > 0 {
+				image = images[0]
+			}
+		}
+		if name, iw, ih, ok := registerPDFImage(ctx, pdf, image); ok {
+			dw, dh := fitImage(iw, ih, contentWidth, pdfImageMaxH)
+			pdf.ImageOptions(name, 20+(contentWidth-dw)/2, pdf.GetY(), dw, dh, false, fpdf.ImageOptions{}, 0, "")
+			pdf.SetY(pdf.GetY() + dh + 2)
+		}
+
+		if ingredients := splitLines(rec.Ingredients); len(ingredients) > 0 {
+			heading("Ingredients")
+			for _, line := range ingredients {
+				pdf.SetX(24)
+				pdf.MultiCell(contentWidth-4, 6, tr("• "+line), "", "L", false)
+			}
+		}
+
+		if steps := splitLines(rec.Instructions); len(steps) > 0 {
+			heading("Instructions")
+			for n, line := range steps {
+				pdf.SetFont("Helvetica", "B", 11)
+				pdf.CellFormat(8, 6, fmt.Sprintf("%d.", n+1), "", 0, "L", false, 0, "")
+				pdf.SetFont("Helvetica", "", 11)
+				pdf.MultiCell(contentWidth-8, 6, tr(line), "", "L", false)
+				pdf.Ln(1.5)
+			}
+		}
+
+		if notes := strings.TrimSpace(rec.Notes); notes != "" {
+			heading("Notes")
+			pdf.SetFont("Helvetica", "I", 10)
+			pdf.MultiCell(0, 5.5, tr(notes), "", "L", false)
+		}
+	}
+
+	return pdf.Output(w)
+}
+
+// registerPDFImage loads an uploaded (/static/...) or remote image into the
+// document and returns its registered name and pixel size. Formats fpdf cannot
+// embed (e.g. WebP) are skipped.
+func registerPDFImage(ctx context.Context, pdf *fpdf.Fpdf, src string) (string, float64, float64, bool) {
+	if src == "" {
+		return "", 0, 0, false
+	}
+
+	var data []byte
+	var err error
+	if local, ok := datadir.File(src); ok {
+		data, err = os.ReadFile(local)
+	} else if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		client := &http.Client{Timeout: 10 * time.Second, Transport: fetchTransport("pdf_image")}
+		var req *http.Request
+		var resp *http.Response
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, src, nil); err == nil {
+			resp, err = client.Do(req)
+		}
+		if err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			} else {
+				data, err = io.ReadAll(io.LimitReader(resp.Body, pdfImageLimit))
+			}
+		}
+	} else {
+		return "", 0, 0, false
+	}
+	if err != nil {
+		log.Printf("Skipping PDF image %s: %v", src, err)
+		return "", 0, 0, false
+	}
+
+	var imageType string
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		imageType = "JPG"
+	case "image/png":
+		imageType = "PNG"
+	case "image/gif":
+		imageType = "GIF"
+	default:
+		return "", 0, 0, false
+	}
+
+	info := pdf.RegisterImageOptionsReader(src, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	if pdf.Err() {
+		// A broken image should not fail the whole export
+		log.Printf("Skipping PDF image %s: %v", src, pdf.Error())
+		pdf.ClearError()
+		return "", 0, 0, false
+	}
+	return src, info.Width(), info.Height(), true
+}
+
+// fitImage scales w x h to fit within maxW x maxH, keeping the aspect ratio
+func fitImage(w, h, maxW, maxH float64) (float64, float64) {
+	if w <= 0 || h <= 0 {
+		return maxW, maxH
+	}
+	scale := maxW / w
+	if h*scale > maxH {
+		scale = maxH / h
+	}
+	return w * scale, h * scale
+}
+
+// truncateRunes shortens s to at most n runes, adding an ellipsis
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// saveCookbookCover saves an uploaded cover image and returns its public path
+func saveCookbookCover(file multipart.File, header *multipart.FileHeader) string {
+	uploadDir := datadir.Uploads()
+	os.MkdirAll(uploadDir, 0755)
+
+	filename := fmt.Sprintf("cookbook_%d%s", time.Now().UnixNano(), filepath.Ext(header.Filename))
+	dst, err := os.Create(filepath.Join(uploadDir, filename))
+	if err != nil {
+		log.Printf("Failed to create cookbook cover file: %v", err)
+		return ""
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		log.Printf("Failed to write cookbook cover: %v", err)
+		return ""
+	}
+
+	return "/static/uploads/" + filename
+}
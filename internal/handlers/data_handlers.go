@@ -2,394 +2,837 @@ package handlers
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"infokeep/internal/database"
+	"infokeep/internal/models"
+	"infokeep/internal/plugins/gitnotes"
+	"infokeep/internal/validation"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// ExportDataHandler handles the export of all data
-func ExportDataHandler(w http.ResponseWriter, r *http.Request) {
-	format := r.URL.Query().Get("format")
-	if format == "" {
-		format = "json"
-	}
+// exportData holds everything that goes into a data export
+type exportData struct {
+	Bookmarks  []models.Bookmark
+	Notes      []models.Note
+	Drawings   []models.Drawing
+	Lists      []models.List
+	RatedLists []models.RatedList
+	Recipes    []models.Recipe
+	Media      []models.Media
+}
 
-	userID := getUserID(r)
-	// Fetch all data
+// collectExportData gathers all of a user's data, calling progress with the
+// percentage done as it goes
+func collectExportData(userID int64, progress func(percent int)) (*exportData, error) {
 	bookmarks, err := database.GetBookmarks(userID, "")
 	if err != nil {
-		http.Error(w, "Failed to fetch bookmarks", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to fetch bookmarks: %w", err)
 	}
 	notes, err := database.GetNotes(userID, "")
 	if err != nil {
-		http.Error(w, "Failed to fetch notes", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to fetch notes: %w", err)
 	}
+	progress(15)
+
 	drawings, err := database.GetDrawings(userID, "")
 	if err != nil {
-		http.Error(w, "Failed to fetch drawings", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to fetch drawings: %w", err)
+	}
+	for i, d := range drawings {
+		if full, err := database.GetDrawing(userID, d.ID); err == nil {
+			drawings[i].VectorData = full.VectorData
+		}
 	}
 
+	progress(25)
+
 	// Lists with items
 	lists, err := database.GetLists(userID, "")
 	if err != nil {
-		http.Error(w, "Failed to fetch lists", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to fetch lists: %w", err)
 	}
 	for i, l := range lists {
-		id := l["id"].(int64)
-		items, _ := database.GetListItems(id)
-		lists[i]["items"] = items
+		lists[i].Items, _ = database.GetListItems(l.ID)
 	}
 
+	progress(40)
+
 	// Rated Lists with items
 	ratedLists, err := database.GetRatedLists(userID, "")
 	if err != nil {
-		http.Error(w, "Failed to fetch rated lists", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to fetch rated lists: %w", err)
 	}
 	for i, l := range ratedLists {
-		id := l["id"].(int64)
-		items, _ := database.GetRatedListItems(id)
-		ratedLists[i]["items"] = items
+		ratedLists[i].Items, _ = database.GetRatedListItems(l.ID)
 	}
 
+	progress(55)
+
 	// Recipes
 	recipes, err := database.GetRecipes(userID, "")
 	if err != nil {
-		http.Error(w, "Failed to fetch recipes", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to fetch recipes: %w", err)
 	}
+	for i, rec := range recipes {
+		recipes[i].Images, _ = database.GetRecipeImages(rec.ID)
+	}
+
+	progress(70)
 
 	// Media
 	media, err := database.GetMedia(userID, "")
 	if err != nil {
-		http.Error(w, "Failed to fetch media", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to fetch media: %w", err)
 	}
 
-	timestamp := time.Now().Format("2006-01-02_150405")
+	progress(80)
+
+	return &exportData{
+		Bookmarks:  bookmarks,
+		Notes:      notes,
+		Drawings:   drawings,
+		Lists:      lists,
+		RatedLists: ratedLists,
+		Recipes:    recipes,
+		Media:      media,
+	}, nil
+}
 
-	if format == "json" {
-		data := map[string]interface{}{
-			"version":     "1.0",
-			"exported_at": time.Now(),
-			"bookmarks":   bookmarks,
-			"notes":       notes,
-			"drawings":    drawings,
-			"lists":       lists,
-			"rated_lists": ratedLists,
-			"recipes":     recipes,
-			"media":       media,
+// writeJSONExport writes an export as a JSON backup that ImportDataHandler can read back
+func writeJSONExport(w io.Writer, d *exportData) error {
+	data := map[string]interface{}{
+		"version":     "1.0",
+		"exported_at": time.Now(),
+		"bookmarks":   d.Bookmarks,
+		"notes":       d.Notes,
+		"drawings":    d.Drawings,
+		"lists":       d.Lists,
+		"rated_lists": d.RatedLists,
+		"recipes":     d.Recipes,
+		"media":       d.Media,
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// writeCSVExport writes an export as a ZIP of CSV files
+func writeCSVExport(w io.Writer, d *exportData) error {
+	zw := zip.NewWriter(w)
+
+	// Helper to write CSV
+	writeCSV := func(filename string, header []string, rows [][]string) error {
+		f, err := zw.Create(filename)
+		if err != nil {
+			return err
 		}
+		cw := csv.NewWriter(f)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"infokeep_backup_%s.json\"", timestamp))
+	// Bookmarks CSV
+	bRows := [][]string{}
+	for _, b := range d.Bookmarks {
+		bRows = append(bRows, []string{
+			strconv.FormatInt(b.ID, 10),
+			b.Title,
+			b.URL,
+			b.Description,
+			b.CreatedAt,
+			strings.Join(b.Tags, ","),
+		})
+	}
+	if err := writeCSV("bookmarks.csv", []string{"id", "title", "url", "description", "created_at", "tags"}, bRows); err != nil {
+		return err
+	}
+
+	// Notes CSV
+	nRows := [][]string{}
+	for _, n := range d.Notes {
+		nRows = append(nRows, []string{
+			strconv.FormatInt(n.ID, 10),
+			n.Title,
+			n.Content,
+			n.CreatedAt,
+			strings.Join(n.Tags, ","),
+		})
+	}
+	writeCSV("notes.csv", []string{"id", "title", "content", "created_at", "tags"}, nRows)
+
+	// Recipes CSV
+	rRows := [][]string{}
+	for _, r := range d.Recipes {
+		rRows = append(rRows, []string{
+			strconv.FormatInt(r.ID, 10),
+			r.Title,
+			r.Ingredients,
+			r.Instructions,
+			r.Notes,
+			r.Thumbnail,
+			r.SourceURL,
+			r.CreatedAt,
+			strings.Join(r.Tags, ","),
+		})
+	}
+	writeCSV("recipes.csv", []string{"id", "title", "ingredients", "instructions", "notes", "thumbnail", "source_url", "created_at", "tags"}, rRows)
+
+	// Lists CSV, with the items of all lists in list_items.csv
+	lRows := [][]string{}
+	liRows := [][]string{}
+	for _, l := range d.Lists {
+		lRows = append(lRows, []string{
+			strconv.FormatInt(l.ID, 10),
+			l.Title,
+			l.CreatedAt,
+			strings.Join(l.Tags, ","),
+		})
+		for _, item := range l.Items {
+			liRows = append(liRows, []string{
+				strconv.FormatInt(item.ID, 10),
+				strconv.FormatInt(l.ID, 10),
+				item.Content,
+				strconv.FormatBool(item.Completed),
+				formatAmount(item.Quantity),
+				item.Unit,
+				formatAmount(item.Price),
+				item.DueDate,
+			})
+		}
+	}
+	writeCSV("lists.csv", []string{"id", "title", "created_at", "tags"}, lRows)
+
+	if err := writeCSV("list_items.csv", []string{"id", "list_id", "content", "completed", "quantity", "unit", "price", "due_date"}, liRows); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeNotesExport writes the notes of an export as Markdown files for a git
+// repository, which the gitnotes importer reads back
+func writeNotesExport(w io.Writer, d *exportData) error {
+	notes := make([]gitnotes.Note, len(d.Notes))
+	for i, n := range d.Notes {
+		notes[i] = gitnotes.Note{ID: n.ID, Title: n.Title, Content: n.Content, Tags: n.Tags, CreatedAt: n.CreatedAt}
+	}
+	return gitnotes.Write(w, notes)
+}
+
+// dataExportFilename returns the download name of a data export in the given format
+func dataExportFilename(format string, t time.Time) string {
+	timestamp := t.Format("2006-01-02_150405")
+	switch format {
+	case "csv":
+		return fmt.Sprintf("infokeep_export_%s.zip", timestamp)
+	case "personal":
+		return fmt.Sprintf("infokeep_personal_data_%s.zip", timestamp)
+	case "notes":
+		return fmt.Sprintf("infokeep_notes_%s.zip", timestamp)
+	case "vault":
+		return fmt.Sprintf("infokeep_vault_%s.zip", timestamp)
+	}
+	return fmt.Sprintf("infokeep_backup_%s.json", timestamp)
+}
+
+// ExportDataHandler handles the export of all data, or of the items
+// ?type=, ?tag=, ?from=, ?to= and ?ids= pick (see exportFilter)
+func ExportDataHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+	var v validation.Validator
+	filter := parseExportFilter(&v, r.URL.Query())
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
 
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
-		if err := enc.Encode(data); err != nil {
+	userID := getUserID(r)
+	data, err := collectExportData(userID, func(int) {})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filter.apply(data)
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", dataExportFilename(format, time.Now())))
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeJSONExport(w, data); err != nil {
 			http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	if format == "csv" {
-		w.Header().Set("Content-Type", "application/zip")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"infokeep_export_%s.zip\"", timestamp))
+	w.Header().Set("Content-Type", "application/zip")
+	if err := writeCSVExport(w, data); err != nil {
+		http.Error(w, "Failed to write CSV", http.StatusInternalServerError)
+	}
+}
 
-		zw := zip.NewWriter(w)
-		defer zw.Close()
+// importData is the JSON backup format read by ImportDataHandler
+type importData struct {
+	Bookmarks []map[string]interface{} `json:"bookmarks"`
+	Notes     []map[string]interface{} `json:"notes"`
+	Drawings  []map[string]interface{} `json:"drawings"`
+	Lists     []struct {
+		Title string `json:"title"`
+		Items []struct {
+			Content   string `json:"content"`
+			Completed bool   `json:"completed"`
+			DueDate   string `json:"due_date"`
+			listItemAmount
+		} `json:"items"`
+		Tags []string `json:"tags"`
+	} `json:"lists"`
+	RatedLists []struct {
+		Title string `json:"title"`
+		Items []struct {
+			Title string `json:"title"`
+			Score int    `json:"score"`
+			Note  string `json:"note"`
+		} `json:"items"`
+		Tags []string `json:"tags"`
+	} `json:"rated_lists"`
+	Recipes []struct {
+		Title        string   `json:"title"`
+		Ingredients  string   `json:"ingredients"`
+		Instructions string   `json:"instructions"`
+		Notes        string   `json:"notes"`
+		Thumbnail    string   `json:"thumbnail"`
+		SourceURL    string   `json:"source_url"`
+		Images       []string `json:"images"`
+		Tags         []string `json:"tags"`
+	} `json:"recipes"`
+	Media []map[string]interface{} `json:"media"`
+}
 
-		// Helper to write CSV
-		writeCSV := func(filename string, header []string, rows [][]string) error {
-			f, err := zw.Create(filename)
-			if err != nil {
-				return err
-			}
-			cw := csv.NewWriter(f)
-			if err := cw.Write(header); err != nil {
-				return err
-			}
-			if err := cw.WriteAll(rows); err != nil {
-				return err
-			}
-			cw.Flush()
-			return cw.Error()
-		}
+// decodeImportData reads a JSON backup. It only fails on invalid JSON: what
+// the backup's items hold is checked as they are imported.
+func decodeImportData(r io.Reader) (*importData, error) {
+	var data importData
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
 
-		// Bookmarks CSV
-		bRows := [][]string{}
-		for _, b := range bookmarks {
-			// Extract tags (slice of strings) and join
-			tags := ""
-			if t, ok := b["tags"].([]string); ok {
-				// join tags
-				for i, tag := range t {
-					if i > 0 {
-						tags += ","
-					}
-					tags += tag
-				}
-			}
-			bRows = append(bRows, []string{
-				fmt.Sprintf("%v", b["id"]),
-				fmt.Sprintf("%v", b["title"]),
-				fmt.Sprintf("%v", b["url"]),
-				fmt.Sprintf("%v", b["description"]),
-				fmt.Sprintf("%v", b["created_at"]),
-				tags,
-			})
+// itemCount returns how many items the backup holds
+func (d *importData) itemCount() int {
+	return len(d.Bookmarks) + len(d.Notes) + len(d.Drawings) + len(d.Lists) + len(d.RatedLists) + len(d.Recipes) + len(d.Media)
+}
+
+// Import modes for restoring a backup into an account that already has data
+const (
+	importMerge     = "merge"     // skip items identical to ones already there
+	importOverwrite = "overwrite" // replace the item with the same type, title and source
+	importNamespace = "namespace" // import everything, with tags under a namespace
+)
+
+// importOptions controls how importBackup treats the items of a backup
+type importOptions struct {
+	Mode string
+	// Namespace is added as a tag to every item and prefixed to its own tags
+	// ("namespace/tag") in namespace mode
+	Namespace string
+	// FetchFile returns a local copy of an uploaded file (/static/uploads/...)
+	// the backup refers to, or "" if it could not be copied. Without it,
+	// drawings and media are skipped since their files are missing.
+	FetchFile func(path string) string
+	Progress  func(percent int)
+	// DryRun only counts what the import would do, changing nothing
+	DryRun bool
+}
+
+// importCounts is what happened to the backup items of one type
+type importCounts struct {
+	Created, Replaced, Skipped, Failed int
+	// Ambiguous are the items not overwritten since several items match them
+	Ambiguous int
+}
+
+// importReport summarizes an import per item type
+type importReport map[string]*importCounts
+
+// importTypes are the item types of a backup, in import order
+var importTypes = []struct{ typ, label string }{
+	{"bookmark", "Bookmarks"},
+	{"note", "Notes"},
+	{"list", "Checklists"},
+	{"rated_list", "Rated lists"},
+	{"recipe", "Recipes"},
+	{"drawing", "Drawings"},
+	{"media", "Media"},
+}
+
+// String describes the import for the user, e.g.
+// "Notes: 3 created, 2 skipped as identical; Recipes: 1 replaced"
+func (r importReport) String() string {
+	return r.describe("created", "replaced", "skipped as identical")
+}
+
+// preview describes a dry run, e.g.
+// "Nothing was imported yet. Bookmarks: 3 to create, 2 identical to skip"
+func (r importReport) preview() string {
+	return "Nothing was imported yet. " + r.describe("to create", "to replace", "identical to skip")
+}
+
+// describe lists the counts of each item type with the words given for them
+func (r importReport) describe(created, replaced, skipped string) string {
+	var parts []string
+	for _, t := range importTypes {
+		c := r[t.typ]
+		if c == nil {
+			continue
 		}
-		if err := writeCSV("bookmarks.csv", []string{"id", "title", "url", "description", "created_at", "tags"}, bRows); err != nil {
-			http.Error(w, "Failed to write CSV", http.StatusInternalServerError)
-			return
+		var counts []string
+		if c.Created > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.Created, created))
 		}
-
-		// Notes CSV
-		nRows := [][]string{}
-		for _, n := range notes {
-			tags := ""
-			if t, ok := n["tags"].([]string); ok {
-				for i, tag := range t {
-					if i > 0 {
-						tags += ","
-					}
-					tags += tag
-				}
-			}
-			nRows = append(nRows, []string{
-				fmt.Sprintf("%v", n["id"]),
-				fmt.Sprintf("%v", n["title"]),
-				fmt.Sprintf("%v", n["content"]),
-				fmt.Sprintf("%v", n["created_at"]),
-				tags,
-			})
+		if c.Replaced > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.Replaced, replaced))
 		}
-		writeCSV("notes.csv", []string{"id", "title", "content", "created_at", "tags"}, nRows)
-
-		// Recipes CSV
-		rRows := [][]string{}
-		for _, r := range recipes {
-			tags := ""
-			if t, ok := r["tags"].([]string); ok {
-				for i, tag := range t {
-					if i > 0 {
-						tags += ","
-					}
-					tags += tag
-				}
-			}
-			rRows = append(rRows, []string{
-				fmt.Sprintf("%v", r["id"]),
-				fmt.Sprintf("%v", r["title"]),
-				fmt.Sprintf("%v", r["ingredients"]),
-				fmt.Sprintf("%v", r["instructions"]),
-				fmt.Sprintf("%v", r["notes"]),
-				fmt.Sprintf("%v", r["thumbnail"]),
-				fmt.Sprintf("%v", r["source_url"]),
-				fmt.Sprintf("%v", r["created_at"]),
-				tags,
-			})
+		if c.Skipped > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.Skipped, skipped))
 		}
-		writeCSV("recipes.csv", []string{"id", "title", "ingredients", "instructions", "notes", "thumbnail", "source_url", "created_at", "tags"}, rRows)
-
-		// Lists CSV
-		lRows := [][]string{}
-		for _, l := range lists {
-			tags := ""
-			if t, ok := l["tags"].([]string); ok {
-				for i, tag := range t {
-					if i > 0 {
-						tags += ","
-					}
-					tags += tag
-				}
-			}
-			lRows = append(lRows, []string{
-				fmt.Sprintf("%v", l["id"]),
-				fmt.Sprintf("%v", l["title"]),
-				fmt.Sprintf("%v", l["created_at"]),
-				tags,
-			})
-
-			// List Items logic could be separate csv "list_items.csv" with list_id
-			if items, ok := l["items"].([]map[string]interface{}); ok {
-				liRows := [][]string{}
-				for _, item := range items {
-					liRows = append(liRows, []string{
-						fmt.Sprintf("%v", item["id"]),
-						fmt.Sprintf("%v", l["id"]), // parent list id
-						fmt.Sprintf("%v", item["content"]),
-						fmt.Sprintf("%v", item["completed"]),
-					})
-				}
-				// Append to a global list_items.csv?
-				// Better to iterate lists again or use a closure for global collection
-			}
+		if c.Ambiguous > 0 {
+			counts = append(counts, fmt.Sprintf("%d skipped as matching several items", c.Ambiguous))
 		}
-		writeCSV("lists.csv", []string{"id", "title", "created_at", "tags"}, lRows)
-
-		// Collect ALL list items
-		liRows := [][]string{}
-		for _, l := range lists {
-			if items, ok := l["items"].([]map[string]interface{}); ok {
-				for _, item := range items {
-					liRows = append(liRows, []string{
-						fmt.Sprintf("%v", item["id"]),
-						fmt.Sprintf("%v", l["id"]),
-						fmt.Sprintf("%v", item["content"]),
-						fmt.Sprintf("%v", item["completed"]),
-					})
-				}
-			}
+		if c.Failed > 0 {
+			counts = append(counts, fmt.Sprintf("%d failed", c.Failed))
 		}
-		writeCSV("list_items.csv", []string{"id", "list_id", "content", "completed"}, liRows)
+		parts = append(parts, t.label+": "+strings.Join(counts, ", "))
+	}
+	if len(parts) == 0 {
+		return "The backup contained no items"
+	}
+	return strings.Join(parts, "; ")
+}
 
-		return
+// total adds up the counts of all types
+func (r importReport) total() importCounts {
+	var sum importCounts
+	for _, c := range r {
+		sum.Created += c.Created
+		sum.Replaced += c.Replaced
+		sum.Skipped += c.Skipped
+		sum.Failed += c.Failed
+		sum.Ambiguous += c.Ambiguous
 	}
+	return sum
+}
 
-	http.Error(w, "Invalid format", http.StatusBadRequest)
+// existingItem is an item in the account that backup items are matched against
+type existingItem struct {
+	id       int64
+	identity string
+	imported bool // created by this import
 }
 
-// ImportDataHandler handles the import of data from JSON
-func ImportDataHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse multipart form
-	err := r.ParseMultipartForm(10 << 20) // 10 MB max
+// importer recreates the items of one backup
+type importer struct {
+	userID int64
+	opts   importOptions
+	// existing holds the account's items by matchKey
+	existing    map[string][]existingItem
+	report      importReport
+	done, total int
+}
+
+// matchKey identifies the item a backup item would overwrite: same type,
+// title and, for bookmarks and recipes, source URL
+func matchKey(typ, title, source string) string {
+	return typ + "\x00" + strings.ToLower(strings.TrimSpace(title)) + "\x00" + strings.TrimSpace(source)
+}
+
+// identity joins the fields that make two items identical
+func identity(fields ...string) string {
+	return strings.Join(fields, "\x00")
+}
+
+// listIdentity is the identity of a checklist: its title and its items in any order
+func listIdentity(title string, items []string) string {
+	sorted := append([]string(nil), items...)
+	sort.Strings(sorted)
+	return identity(append([]string{title}, sorted...)...)
+}
+
+// indexExisting loads the account's items so backup items can be matched against them
+func (imp *importer) indexExisting() error {
+	current, err := collectExportData(imp.userID, func(int) {})
 	if err != nil {
-		http.Error(w, "File too large", http.StatusBadRequest)
-		return
+		return err
+	}
+	add := func(typ, title, source string, id int64, ident string) {
+		key := matchKey(typ, title, source)
+		imp.existing[key] = append(imp.existing[key], existingItem{id: id, identity: ident})
 	}
 
-	file, _, err := r.FormFile("importFile")
-	if err != nil {
-		http.Error(w, "Failed to retrieve file", http.StatusBadRequest)
-		return
+	for _, b := range current.Bookmarks {
+		add("bookmark", b.Title, b.URL, b.ID, identity(b.Title, b.URL, b.Description))
 	}
-	defer file.Close()
+	for _, n := range current.Notes {
+		add("note", n.Title, "", n.ID, identity(n.Title, n.Content))
+	}
+	for _, l := range current.Lists {
+		var items []string
+		for _, item := range l.Items {
+			items = append(items, fmt.Sprintf("%s\x00%v", item.Content, item.Completed))
+		}
+		add("list", l.Title, "", l.ID, listIdentity(l.Title, items))
+	}
+	for _, l := range current.RatedLists {
+		var items []string
+		for _, item := range l.Items {
+			items = append(items, identity(item.Title, strconv.Itoa(item.Score), item.Note))
+		}
+		add("rated_list", l.Title, "", l.ID, listIdentity(l.Title, items))
+	}
+	for _, r := range current.Recipes {
+		add("recipe", r.Title, r.SourceURL, r.ID, identity(r.Title, r.Ingredients, r.Instructions, r.Notes, r.SourceURL))
+	}
+	for _, d := range current.Drawings {
+		add("drawing", d.Title, "", d.ID, identity(d.Title, d.FilePath, d.VectorData))
+	}
+	for _, m := range current.Media {
+		add("media", m.Title, "", m.ID, identity(m.Title, m.FilePath, m.MimeType))
+	}
+	return nil
+}
 
-	// Decode JSON
-	var data struct {
-		Bookmarks []map[string]interface{} `json:"bookmarks"`
-		Notes     []map[string]interface{} `json:"notes"`
-		Drawings  []map[string]interface{} `json:"drawings"`
-		Lists     []struct {
-			Title string `json:"title"`
-			Items []struct {
-				Content   string `json:"content"`
-				Completed bool   `json:"completed"`
-			} `json:"items"`
-			Tags []string `json:"tags"`
-		} `json:"lists"`
-		RatedLists []struct {
-			Title string `json:"title"`
-			Items []struct {
-				Title string `json:"title"`
-				Score int    `json:"score"`
-				Note  string `json:"note"`
-			} `json:"items"`
-			Tags []string `json:"tags"`
-		} `json:"rated_lists"`
-		Recipes []struct {
-			Title        string   `json:"title"`
-			Ingredients  string   `json:"ingredients"`
-			Instructions string   `json:"instructions"`
-			Notes        string   `json:"notes"`
-			Thumbnail    string   `json:"thumbnail"`
-			SourceURL    string   `json:"source_url"`
-			Images       []string `json:"images"`
-			Tags         []string `json:"tags"`
-		} `json:"recipes"`
-		// Media import if we want, but file paths might be broken if not uploaded
-	}
-
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
-		http.Error(w, "Invalid JSON file", http.StatusBadRequest)
+// add imports one backup item according to the import mode. create makes the
+// item and returns its ID; it only runs if the item is to be imported.
+func (imp *importer) add(typ, title, source, ident string, tags []string, create func() (int64, error)) {
+	counts := imp.report[typ]
+	if counts == nil {
+		counts = &importCounts{}
+		imp.report[typ] = counts
+	}
+	defer func() {
+		imp.done++
+		imp.opts.Progress(imp.done * 100 / imp.total)
+	}()
+
+	key := matchKey(typ, title, source)
+	matches := imp.existing[key]
+	replace, candidates := -1, 0
+	if imp.opts.Mode != importNamespace {
+		for i, m := range matches {
+			if m.identity == ident {
+				counts.Skipped++
+				return
+			}
+			// Items this import created are not replaced, so backups holding
+			// several items with the same title don't clobber each other
+			if imp.opts.Mode == importOverwrite && !m.imported {
+				if replace < 0 {
+					replace = i
+				}
+				candidates++
+			}
+		}
+	}
+	// Which of several matching items to replace can't be told, so none is
+	if candidates > 1 {
+		counts.Ambiguous++
 		return
 	}
 
-	userID := getUserID(r)
-	// Insert data
-	// Bookmarks
-	for _, b := range data.Bookmarks {
-		title := fmt.Sprintf("%v", b["title"])
-		url := fmt.Sprintf("%v", b["url"])
-		desc := ""
-		if v, ok := b["description"]; ok && v != nil {
-			desc = fmt.Sprintf("%v", v)
+	var id int64
+	if !imp.opts.DryRun {
+		var err error
+		if id, err = create(); err != nil {
+			log.Printf("Import: Failed to create %s %q: %v", typ, title, err)
+			counts.Failed++
+			return
 		}
-		fav := ""
-		if v, ok := b["favicon"]; ok && v != nil {
-			fav = fmt.Sprintf("%v", v)
+		database.SetItemTags(id, imp.tags(tags))
+		itemCreated(context.Background(), imp.userID, id, typ, title)
+	}
+
+	// Remove what the new item replaces only once it exists
+	if replace >= 0 {
+		if !imp.opts.DryRun {
+			database.DeleteItem(imp.userID, matches[replace].id)
 		}
-		thumb := ""
-		if v, ok := b["thumbnail"]; ok && v != nil {
-			thumb = fmt.Sprintf("%v", v)
+		matches = append(matches[:replace:replace], matches[replace+1:]...)
+		counts.Replaced++
+	} else {
+		counts.Created++
+	}
+	imp.existing[key] = append(matches, existingItem{id: id, identity: ident, imported: true})
+}
+
+// tags returns the tags an imported item gets
+func (imp *importer) tags(tags []string) []string {
+	if imp.opts.Mode != importNamespace {
+		return tags
+	}
+	namespaced := []string{imp.opts.Namespace}
+	for _, t := range tags {
+		namespaced = append(namespaced, imp.opts.Namespace+"/"+t)
+	}
+	return namespaced
+}
+
+// localFile returns the local path of a file the backup refers to
+func (imp *importer) localFile(path string) string {
+	if imp.opts.FetchFile == nil || !strings.HasPrefix(path, "/static/uploads/") {
+		return path
+	}
+	return imp.opts.FetchFile(path)
+}
+
+// importBackup recreates the items of a backup for a user according to
+// opts.Mode and reports what happened to them
+func importBackup(userID int64, data *importData, opts importOptions) (importReport, error) {
+	if opts.Progress == nil {
+		opts.Progress = func(int) {}
+	}
+	imp := &importer{
+		userID:   userID,
+		opts:     opts,
+		existing: map[string][]existingItem{},
+		report:   importReport{},
+		total:    data.itemCount(),
+	}
+	if opts.Mode != importNamespace {
+		if err := imp.indexExisting(); err != nil {
+			return nil, fmt.Errorf("failed to load existing items: %w", err)
 		}
+	}
 
-		id, err := database.CreateBookmark(userID, title, url, desc, fav, thumb)
-		if err == nil {
-			if tags, ok := b["tags"].([]interface{}); ok {
-				tagStrs := []string{}
-				for _, t := range tags {
-					tagStrs = append(tagStrs, fmt.Sprintf("%v", t))
-				}
-				database.SetItemTags(id, tagStrs)
+	// Bookmarks
+	for _, b := range data.Bookmarks {
+		title, link, desc := backupString(b, "title"), backupString(b, "url"), backupString(b, "description")
+		imp.add("bookmark", title, link, identity(title, link, desc), backupTags(b), func() (int64, error) {
+			id, err := database.CreateBookmark(userID, title, link, backupString(b, "canonical_url"), desc,
+				imp.localFile(backupString(b, "favicon")), imp.localFile(backupString(b, "thumbnail")))
+			if err == nil {
+				database.SetBookmarkNotes(id, backupString(b, "notes"))
+				restoreReadLater(userID, id, b)
+				restoreCreatedAt(id, b)
 			}
-		}
+			return id, err
+		})
 	}
 
 	// Notes
 	for _, n := range data.Notes {
-		title := fmt.Sprintf("%v", n["title"])
-		content := fmt.Sprintf("%v", n["content"])
-		id, err := database.CreateNote(userID, title, content)
-		if err == nil {
-			if tags, ok := n["tags"].([]interface{}); ok {
-				tagStrs := []string{}
-				for _, t := range tags {
-					tagStrs = append(tagStrs, fmt.Sprintf("%v", t))
-				}
-				database.SetItemTags(id, tagStrs)
-			}
-		}
+		title, content := backupString(n, "title"), backupString(n, "content")
+		imp.add("note", title, "", identity(title, content), backupTags(n), func() (int64, error) {
+			return database.CreateNote(userID, title, content)
+		})
 	}
 
 	// Lists
 	for _, l := range data.Lists {
-		id, err := database.CreateList(userID, l.Title)
-		if err == nil {
-			database.SetItemTags(id, l.Tags)
+		var items []string
+		for _, item := range l.Items {
+			items = append(items, fmt.Sprintf("%s\x00%v", item.Content, item.Completed))
+		}
+		imp.add("list", l.Title, "", listIdentity(l.Title, items), l.Tags, func() (int64, error) {
+			id, err := database.CreateList(userID, l.Title)
+			if err != nil {
+				return 0, err
+			}
 			for _, item := range l.Items {
-				database.AddListItem(id, item.Content)
+				itemID, err := database.AddListItem(id, item.Content)
+				if err == nil && item.Completed {
+					database.ToggleListItem(itemID, true)
+				}
+				if err == nil && item.isSet() {
+					item.save(itemID)
+				}
+				if err == nil && item.DueDate != "" {
+					if _, perr := time.Parse(time.DateOnly, item.DueDate); perr == nil {
+						database.SetListItemDueDate(itemID, item.DueDate)
+					}
+				}
 			}
-		}
+			return id, nil
+		})
 	}
 
 	// Rated Lists
 	for _, l := range data.RatedLists {
-		id, err := database.CreateRatedList(userID, l.Title)
-		if err == nil {
-			database.SetItemTags(id, l.Tags)
+		var items []string
+		for _, item := range l.Items {
+			items = append(items, identity(item.Title, strconv.Itoa(item.Score), item.Note))
+		}
+		imp.add("rated_list", l.Title, "", listIdentity(l.Title, items), l.Tags, func() (int64, error) {
+			id, err := database.CreateRatedList(userID, l.Title)
+			if err != nil {
+				return 0, err
+			}
 			for _, item := range l.Items {
 				database.AddRatedListItem(id, item.Title, item.Score, item.Note) //nolint:errcheck
 			}
-		}
+			return id, nil
+		})
 	}
 
 	// Recipes
 	for _, r := range data.Recipes {
-		id, err := database.CreateRecipe(userID, r.Title, r.Ingredients, r.Instructions, r.Notes, r.Thumbnail, r.SourceURL, r.Images)
-		if err == nil {
-			database.SetItemTags(id, r.Tags)
+		ident := identity(r.Title, r.Ingredients, r.Instructions, r.Notes, r.SourceURL)
+		imp.add("recipe", r.Title, r.SourceURL, ident, r.Tags, func() (int64, error) {
+			images := []string{}
+			for _, img := range r.Images {
+				if path := imp.localFile(img); path != "" {
+					images = append(images, path)
+				}
+			}
+			return database.CreateRecipe(userID, r.Title, r.Ingredients, r.Instructions, r.Notes, imp.localFile(r.Thumbnail), r.SourceURL, images)
+		})
+	}
+
+	if opts.FetchFile == nil {
+		return imp.report, nil
+	}
+
+	// Drawings
+	for _, d := range data.Drawings {
+		title, path, vector := backupString(d, "title"), backupString(d, "file_path"), backupString(d, "vector_data")
+		imp.add("drawing", title, "", identity(title, path, vector), backupTags(d), func() (int64, error) {
+			local := imp.localFile(path)
+			if local == "" {
+				return 0, fmt.Errorf("missing drawing file %s", path)
+			}
+			id, err := database.CreateDrawing(userID, title, local)
+			if err == nil && vector != "" {
+				database.SetDrawingVectorData(id, vector)
+			}
+			return id, err
+		})
+	}
+
+	// Media
+	for _, m := range data.Media {
+		title, path, mimeType := backupString(m, "title"), backupString(m, "file_path"), backupString(m, "mime_type")
+		imp.add("media", title, "", identity(title, path, mimeType), backupTags(m), func() (int64, error) {
+			local := imp.localFile(path)
+			if local == "" {
+				return 0, fmt.Errorf("missing media file %s", path)
+			}
+			return database.CreateMedia(userID, title, local, mimeType)
+		})
+	}
+
+	return imp.report, nil
+}
+
+// backupString returns a field of a backup item as a string, "" if it is missing
+func backupString(m map[string]interface{}, key string) string {
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+// backupTags returns the tags of a backup item
+func backupTags(m map[string]interface{}) []string {
+	tagStrs := []string{}
+	if tags, ok := m["tags"].([]interface{}); ok {
+		for _, t := range tags {
+			tagStrs = append(tagStrs, fmt.Sprintf("%v", t))
+		}
+	}
+	return tagStrs
+}
+
+// restoreReadLater puts a backup bookmark in the read-later queue, or marks
+// it read there, as it was: "read_later" is true while it waits and
+// "read_at" (or "read", from other apps) is set once it was read
+func restoreReadLater(userID, id int64, b map[string]interface{}) {
+	read := b["read"] == true || backupString(b, "read_at") != ""
+	if b["read_later"] != true && !read {
+		return
+	}
+	database.SetReadLater(userID, id, true)
+	if read {
+		database.MarkBookmarkRead(userID, id)
+	}
+}
+
+// restoreCreatedAt gives an imported item the creation time of the
+// original, if the backup has it
+func restoreCreatedAt(id int64, m map[string]interface{}) {
+	s := backupString(m, "created_at")
+	for _, layout := range []string{time.RFC3339, time.DateTime} {
+		if t, err := time.Parse(layout, s); err == nil {
+			database.SetItemCreatedAt(id, t)
+			return
+		}
+	}
+}
+
+// ImportDataHandler handles the import of data from JSON
+func ImportDataHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse multipart form
+	err := r.ParseMultipartForm(10 << 20) // 10 MB max
+	if err != nil {
+		http.Error(w, "File too large", http.StatusBadRequest)
+		return
+	}
+
+	opts := importOptions{Mode: r.FormValue("mode")}
+	switch opts.Mode {
+	case "":
+		opts.Mode = importMerge
+	case importMerge, importOverwrite:
+	case importNamespace:
+		opts.Namespace = strings.Trim(strings.TrimSpace(strings.ToLower(r.FormValue("namespace"))), "/")
+		if opts.Namespace == "" {
+			http.Error(w, "A namespace tag is required to restore into a namespace", http.StatusBadRequest)
+			return
 		}
+	default:
+		http.Error(w, "Invalid import mode", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("importFile")
+	if err != nil {
+		http.Error(w, "Failed to retrieve file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := decodeImportData(file)
+	if err != nil {
+		http.Error(w, "Invalid JSON file", http.StatusBadRequest)
+		return
+	}
+
+	report, err := importBackup(getUserID(r), data, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	// Redirect back to settings with success message
-	http.Redirect(w, r, "/settings?import=success", http.StatusSeeOther)
+	redirectTo(w, r, "/settings?import=success&summary="+url.QueryEscape(report.String()), http.StatusSeeOther)
 }
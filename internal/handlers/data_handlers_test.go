@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"infokeep/internal/database"
+)
+
+func TestImportBackupModes(t *testing.T) {
+	type note struct{ title, content string }
+	tests := []struct {
+		name     string
+		existing []note
+		backup   string
+		opts     importOptions
+		want     importCounts
+		summary  string
+		// notes are the account's notes afterwards as "title: content [tags]"
+		notes []string
+	}{
+		{
+			name:     "merge skips identical",
+			existing: []note{{"Bread", "rye"}},
+			backup:   `{"notes":[{"title":"Bread","content":"rye"},{"title":"Soup","content":"leek","tags":["lunch"]}]}`,
+			opts:     importOptions{Mode: importMerge},
+			want:     importCounts{Created: 1, Skipped: 1},
+			summary:  "Notes: 1 created, 1 skipped as identical",
+			notes:    []string{"Bread: rye []", "Soup: leek [lunch]"},
+		},
+		{
+			name:     "merge keeps a changed item",
+			existing: []note{{"Bread", "rye"}},
+			backup:   `{"notes":[{"title":"bread ","content":"wheat"}]}`,
+			opts:     importOptions{Mode: importMerge},
+			want:     importCounts{Created: 1},
+			summary:  "Notes: 1 created",
+			notes:    []string{"Bread: rye []", "bread : wheat []"},
+		},
+		{
+			name:     "overwrite replaces the one match",
+			existing: []note{{"Bread", "rye"}, {"Soup", "leek"}},
+			backup:   `{"notes":[{"title":"Bread","content":"wheat","tags":["baking"]}]}`,
+			opts:     importOptions{Mode: importOverwrite},
+			want:     importCounts{Replaced: 1},
+			summary:  "Notes: 1 replaced",
+			notes:    []string{"Bread: wheat [baking]", "Soup: leek []"},
+		},
+		{
+			name:     "overwrite skips several matches",
+			existing: []note{{"Bread", "rye"}, {"Bread", "spelt"}},
+			backup:   `{"notes":[{"title":"Bread","content":"wheat"}]}`,
+			opts:     importOptions{Mode: importOverwrite},
+			want:     importCounts{Ambiguous: 1},
+			summary:  "Notes: 1 skipped as matching several items",
+			notes:    []string{"Bread: rye []", "Bread: spelt []"},
+		},
+		{
+			name:     "overwrite keeps the backup's own duplicates",
+			existing: []note{{"Bread", "rye"}},
+			backup:   `{"notes":[{"title":"Bread","content":"wheat"},{"title":"Bread","content":"spelt"}]}`,
+			opts:     importOptions{Mode: importOverwrite},
+			want:     importCounts{Created: 1, Replaced: 1},
+			summary:  "Notes: 1 created, 1 replaced",
+			notes:    []string{"Bread: spelt []", "Bread: wheat []"},
+		},
+		{
+			name:     "overwrite skips identical",
+			existing: []note{{"Bread", "rye"}, {"Bread", "spelt"}},
+			backup:   `{"notes":[{"title":"Bread","content":"spelt"}]}`,
+			opts:     importOptions{Mode: importOverwrite},
+			want:     importCounts{Skipped: 1},
+			summary:  "Notes: 1 skipped as identical",
+			notes:    []string{"Bread: rye []", "Bread: spelt []"},
+		},
+		{
+			name:     "namespace imports everything under a tag",
+			existing: []note{{"Bread", "rye"}},
+			backup:   `{"notes":[{"title":"Bread","content":"rye","tags":["baking","rye"]}]}`,
+			opts:     importOptions{Mode: importNamespace, Namespace: "old"},
+			want:     importCounts{Created: 1},
+			summary:  "Notes: 1 created",
+			notes:    []string{"Bread: rye []", "Bread: rye [old old/baking old/rye]"},
+		},
+		{
+			name:     "dry run changes nothing",
+			existing: []note{{"Bread", "rye"}},
+			backup:   `{"notes":[{"title":"Bread","content":"wheat"},{"title":"Soup","content":"leek"}]}`,
+			opts:     importOptions{Mode: importOverwrite, DryRun: true},
+			want:     importCounts{Created: 1, Replaced: 1},
+			summary:  "Notes: 1 created, 1 replaced",
+			notes:    []string{"Bread: rye []"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := database.InitDB(filepath.Join(t.TempDir(), "import.db")); err != nil {
+				t.Fatal(err)
+			}
+			defer database.DB.Close()
+			if _, err := database.DB.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')"); err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range tt.existing {
+				if _, err := database.CreateNote(1, n.title, n.content); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := decodeImportData(strings.NewReader(tt.backup))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := importBackup(1, data, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := report["note"]; got == nil || *got != tt.want {
+				t.Errorf("note counts = %+v, want %+v", got, tt.want)
+			}
+			if got := report.String(); got != tt.summary {
+				t.Errorf("summary = %q, want %q", got, tt.summary)
+			}
+
+			notes, err := database.GetNotes(1, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, n := range notes {
+				tags := slices.Clone(n.Tags)
+				slices.Sort(tags)
+				got = append(got, fmt.Sprintf("%s: %s [%s]", n.Title, n.Content, strings.Join(tags, " ")))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.notes) {
+				t.Errorf("notes = %q, want %q", got, tt.notes)
+			}
+		})
+	}
+}
+
+func TestImportBackupMatchesSource(t *testing.T) {
+	if err := database.InitDB(filepath.Join(t.TempDir(), "import.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	if _, err := database.DB.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CreateBookmark(1, "Docs", "https://a.example/docs", "", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := decodeImportData(strings.NewReader(`{"bookmarks":[` +
+		`{"title":"Docs","url":"https://b.example/docs"},` +
+		`{"title":"Docs","url":"https://a.example/docs","description":"Read first"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bookmarks with another URL are other bookmarks, whatever their title
+	report, err := importBackup(1, data, importOptions{Mode: importOverwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Bookmarks: 1 created, 1 replaced"; report.String() != want {
+		t.Errorf("summary = %q, want %q", report.String(), want)
+	}
+	if total := report.total(); total != (importCounts{Created: 1, Replaced: 1}) {
+		t.Errorf("total = %+v", total)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"infokeep/internal/database"
+)
+
+// DatabaseBackupHandler downloads a snapshot of the whole database (admins
+// only). The snapshot is taken with VACUUM INTO while the server keeps
+// running, so it is consistent and includes what is still in the WAL file.
+func DatabaseBackupHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if !isAdmin(userID) {
+		http.Error(w, "Only admins can download the database", http.StatusForbidden)
+		return
+	}
+
+	path, err := databaseSnapshot()
+	if err != nil {
+		log.Printf("Database backup: %v", err)
+		http.Error(w, "Failed to back up the database", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Failed to back up the database", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	now := time.Now()
+	log.Printf("Database backup downloaded by user %d", userID)
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", databaseBackupFilename(now)))
+	http.ServeContent(w, r, "", now, f)
+}
+
+// databaseSnapshot writes a copy of the database to a new file next to it
+// (or in the temp folder) and returns its path; the caller removes it
+func databaseSnapshot() (string, error) {
+	dir := os.TempDir()
+	if DBPath != "" {
+		dir = filepath.Dir(DBPath)
+	}
+	tmp, err := os.CreateTemp(dir, "infokeep-snapshot-*.db")
+	if err != nil {
+		return "", err
+	}
+	path := tmp.Name()
+	tmp.Close()
+	// VACUUM INTO refuses to overwrite a file
+	os.Remove(path)
+	if err := database.BackupTo(path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func databaseBackupFilename(t time.Time) string {
+	return fmt.Sprintf("infokeep_database_%s.db", t.Format("2006-01-02_150405"))
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"infokeep/internal/database"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// recordAuthEvent logs a sign-in or credential change of the user, which
+// shows up in their personal data export
+func recordAuthEvent(r *http.Request, userID int64, event string) {
+	if err := database.RecordAuthEvent(userID, event, clientIP(r), r.UserAgent()); err != nil {
+		log.Printf("Failed to record %s event for user %d: %v", event, userID, err)
+	}
+}
+
+// recordLoginDevice remembers the user agent / IP pair of a successful login
+// and raises an alert when it has not been seen before for this user.
+func recordLoginDevice(r *http.Request, userID int64, username string) {
+	userAgent := r.UserAgent()
+	ip := clientIP(r)
+
+	isNew, firstDevice, err := database.RecordLoginDevice(userID, userAgent, ip)
+	if err != nil {
+		log.Printf("Failed to record login device for user %d: %v", userID, err)
+		return
+	}
+	// The very first login has nothing to compare against, so it is not suspicious
+	if !isNew || firstDevice {
+		return
+	}
+
+	log.Printf("New device login for user %d from %s (%s)", userID, ip, userAgent)
+
+	email := database.GetLoginAlertEmail(userID)
+	if email == "" {
+		return
+	}
+	go func() {
+		body := fmt.Sprintf("A new device signed in to the InfoKeep account %q.\n\n"+
+			"Time: %s\nIP address: %s\nBrowser: %s\n\n"+
+			"If this was you, you can ignore this message. Otherwise, change your password "+
+			"and review your known devices in Settings.",
+			username, time.Now().Format("2006-01-02 15:04:05 MST"), ip, userAgent)
+		if err := sendMail([]string{email}, "InfoKeep: new sign-in from "+ip, body); err != nil {
+			log.Printf("Failed to send new device alert for user %d: %v", userID, err)
+		}
+	}()
+}
+
+// LoginAlertEmailHandler saves (or clears) the address new-device alerts go to
+func LoginAlertEmailHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := database.SetLoginAlertEmail(userID, email); err != nil {
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"email": email})
+}
+
+// ForgetDeviceHandler removes a device from the user's known devices
+func ForgetDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteKnownDevice(userID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+)
+
+// The digest is an email sent daily or weekly, at an hour the user picks,
+// with the items they added since the last one, what is coming up and the
+// bookmarks waiting in their read-later queue. Users opt in on the Settings
+// page; the schedule is kept in their user settings.
+
+const (
+	digestFrequencySetting = "digest_frequency" // "", "daily" or "weekly"
+	digestEmailSetting     = "digest_email"
+	digestHourSetting      = "digest_hour"    // 0 to 23, in the server's time zone
+	digestWeekdaySetting   = "digest_weekday" // 0 (Sunday) to 6, of a weekly digest
+	digestSentSetting      = "digest_sent_at" // RFC 3339
+
+	defaultDigestHour = 8
+	// digestItems is how many added items and queued bookmarks a digest
+	// lists; it counts the rest
+	digestItems = 20
+)
+
+// digestSettings is when and where a user's digest is sent
+type digestSettings struct {
+	Frequency string
+	Email     string
+	Hour      int
+	Weekday   time.Weekday
+	SentAt    time.Time // zero if none was sent yet
+}
+
+func getDigestSettings(userID int64) digestSettings {
+	s := digestSettings{
+		Frequency: database.GetUserSetting(userID, digestFrequencySetting),
+		Email:     database.GetUserSetting(userID, digestEmailSetting),
+		Hour:      defaultDigestHour,
+		Weekday:   time.Monday,
+	}
+	if h, err := strconv.Atoi(database.GetUserSetting(userID, digestHourSetting)); err == nil {
+		s.Hour = h
+	}
+	if d, err := strconv.Atoi(database.GetUserSetting(userID, digestWeekdaySetting)); err == nil {
+		s.Weekday = time.Weekday(d)
+	}
+	s.SentAt, _ = time.Parse(time.RFC3339, database.GetUserSetting(userID, digestSentSetting))
+	return s
+}
+
+// days is how many days a digest covers
+func (s digestSettings) days() int {
+	if s.Frequency == "weekly" {
+		return 7
+	}
+	return 1
+}
+
+// lastSlot returns the latest time at or before now a digest is scheduled for
+func (s digestSettings) lastSlot(now time.Time) time.Time {
+	slot := time.Date(now.Year(), now.Month(), now.Day(), s.Hour, 0, 0, 0, now.Location())
+	if s.Frequency == "weekly" {
+		slot = slot.AddDate(0, 0, -((int(now.Weekday()) - int(s.Weekday) + 7) % 7))
+	}
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -s.days())
+	}
+	return slot
+}
+
+// due reports whether the digest last scheduled at or before now is still to
+// be sent, and returns the time it covers from: when the previous digest was
+// sent, or one period back for the first one
+func (s digestSettings) due(now time.Time) (time.Time, bool) {
+	slot := s.lastSlot(now)
+	if !s.SentAt.IsZero() && !s.SentAt.Before(slot) {
+		return time.Time{}, false
+	}
+	if s.SentAt.IsZero() {
+		return slot.AddDate(0, 0, -s.days()), true
+	}
+	return s.SentAt, true
+}
+
+// describe sums up the schedule, e.g. "Weekly on Monday at 08:00"
+func (s digestSettings) describe() string {
+	switch s.Frequency {
+	case "daily":
+		return fmt.Sprintf("Daily at %02d:00", s.Hour)
+	case "weekly":
+		return fmt.Sprintf("Weekly on %s at %02d:00", s.Weekday, s.Hour)
+	}
+	return "Off"
+}
+
+// Hours and Weekdays are the choices of the Settings page
+func (digestSettings) Hours() []int {
+	hours := make([]int, 24)
+	for h := range hours {
+		hours[h] = h
+	}
+	return hours
+}
+
+func (digestSettings) Weekdays() []time.Weekday {
+	days := make([]time.Weekday, 7)
+	for d := range days {
+		days[d] = time.Weekday(d)
+	}
+	return days
+}
+
+// buildDigest writes the digest of what the user added since since, what
+// is coming up after now and their read-later queue. It reports whether
+// there is nothing to tell.
+func buildDigest(userID int64, since, now time.Time) (string, bool, error) {
+	added, addedTotal, err := database.GetItemsAddedSince(userID, since, digestItems)
+	if err != nil {
+		return "", false, err
+	}
+	soon, err := upcoming(userID, now)
+	if err != nil {
+		return "", false, err
+	}
+	queue, queueTotal, err := database.GetReadLaterQueue(userID, digestItems)
+	if err != nil {
+		return "", false, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Added since %s (%d):\n\n", since.Format("Mon Jan 2 15:04"), addedTotal)
+	for _, item := range added {
+		fmt.Fprintf(&b, "- %s (%s)", item.Title, strings.ReplaceAll(item.Type, "_", " "))
+		if item.URL != "" {
+			fmt.Fprintf(&b, "\n  %s", item.URL)
+		}
+		b.WriteString("\n")
+	}
+	if addedTotal > len(added) {
+		fmt.Fprintf(&b, "…and %d more\n", addedTotal-len(added))
+	}
+	if addedTotal == 0 {
+		b.WriteString("Nothing new.\n")
+	}
+
+	fmt.Fprintf(&b, "\nComing up in the next %d days:\n\n", upcomingDays)
+	for _, u := range soon {
+		when := u.At.Format("Mon Jan 2 15:04")
+		if u.AllDay {
+			when = u.At.Format("Mon Jan 2")
+		}
+		fmt.Fprintf(&b, "- %s  %s", when, u.Title)
+		if u.Detail != "" {
+			fmt.Fprintf(&b, " (%s)", u.Detail)
+		}
+		if u.Overdue {
+			b.WriteString(" — overdue")
+		}
+		b.WriteString("\n")
+	}
+	if len(soon) == 0 {
+		b.WriteString("Nothing due.\n")
+	}
+
+	fmt.Fprintf(&b, "\nIn your read-later queue (%d):\n\n", queueTotal)
+	for _, item := range queue {
+		fmt.Fprintf(&b, "- %s\n  %s\n", item.Title, item.URL)
+	}
+	if queueTotal > len(queue) {
+		fmt.Fprintf(&b, "…and %d more\n", queueTotal-len(queue))
+	}
+	if queueTotal == 0 {
+		b.WriteString("All read.\n")
+	}
+
+	b.WriteString("\nChange or turn off this digest in InfoKeep's Settings.\n")
+	return b.String(), addedTotal == 0 && len(soon) == 0 && queueTotal == 0, nil
+}
+
+// sendDueDigests emails the digests that are due. A digest with nothing in
+// it is skipped but counts as sent.
+func sendDueDigests() (string, error) {
+	if !mailConfigured() {
+		return "SMTP is not configured", nil
+	}
+	users, err := database.GetUsersWithSetting(digestFrequencySetting)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	sent, failed := 0, 0
+	for _, userID := range users {
+		s := getDigestSettings(userID)
+		since, due := s.due(now)
+		if !due || s.Email == "" {
+			continue
+		}
+		body, empty, err := buildDigest(userID, since, now)
+		if err == nil && !empty {
+			subject := "InfoKeep daily digest"
+			if s.Frequency == "weekly" {
+				subject = "InfoKeep weekly digest"
+			}
+			err = sendMail([]string{s.Email}, subject, body)
+		}
+		if err != nil {
+			log.Printf("Digest: user %d: %v", userID, err)
+			failed++
+			continue
+		}
+		if !empty {
+			sent++
+		}
+		database.SetUserSetting(userID, digestSentSetting, now.Format(time.RFC3339))
+	}
+
+	result := fmt.Sprintf("Sent %d digests", sent)
+	if failed > 0 {
+		return "", fmt.Errorf("%s, %d failed", result, failed)
+	}
+	return result, nil
+}
+
+// DigestSettingsHandler saves when and where the user's digest is sent:
+// frequency ("" to turn it off, "daily" or "weekly"), email, hour (0-23)
+// and weekday (0 for Sunday to 6). The first digest goes out at the next
+// scheduled time.
+func DigestSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	var v validation.Validator
+	frequency := r.FormValue("frequency")
+	if frequency != "" && frequency != "daily" && frequency != "weekly" {
+		v.Add("frequency", "must be daily or weekly")
+	}
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			v.Add("email", "is not a valid email address")
+		}
+	} else if frequency != "" {
+		v.Add("email", "is required")
+	}
+	hour, err := strconv.Atoi(r.FormValue("hour"))
+	if err != nil || hour < 0 || hour > 23 {
+		v.Add("hour", "must be between 0 and 23")
+	}
+	weekday, err := strconv.Atoi(r.FormValue("weekday"))
+	if r.FormValue("weekday") == "" {
+		weekday, err = int(time.Monday), nil
+	}
+	if err != nil || weekday < 0 || weekday > 6 {
+		v.Add("weekday", "must be between 0 (Sunday) and 6")
+	}
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	previous := getDigestSettings(userID)
+	settings := map[string]string{
+		digestFrequencySetting: frequency,
+		digestEmailSetting:     email,
+		digestHourSetting:      strconv.Itoa(hour),
+		digestWeekdaySetting:   strconv.Itoa(weekday),
+	}
+	if frequency != "" && previous.Frequency == "" {
+		// Start from now rather than send one right away for the last period
+		settings[digestSentSetting] = time.Now().Format(time.RFC3339)
+	}
+	for key, value := range settings {
+		if err := database.SetUserSetting(userID, key, value); err != nil {
+			http.Error(w, "failed to save", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s := getDigestSettings(userID)
+	if r.Header.Get("HX-Request") != "" {
+		msg := "Digest turned off."
+		if s.Frequency != "" {
+			msg = s.describe() + ", to " + s.Email + "."
+		}
+		w.Write([]byte(msg))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"frequency": s.Frequency, "email": s.Email, "hour": s.Hour, "weekday": int(s.Weekday),
+	})
+}
+
+// DigestPreviewHandler shows the digest the user would get now for the
+// last period, as plain text
+func DigestPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	s := getDigestSettings(userID)
+	now := time.Now()
+	body, _, err := buildDigest(userID, now.AddDate(0, 0, -s.days()), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(body))
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestDue(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	daily := digestSettings{Frequency: "daily", Hour: 8}
+	weekly := digestSettings{Frequency: "weekly", Hour: 8, Weekday: time.Monday}
+
+	tests := []struct {
+		name      string
+		s         digestSettings
+		sent, now string
+		due       bool
+		since     string
+	}{
+		{"daily, before the hour", daily, "2026-10-15 08:00", "2026-10-16 07:59", false, ""},
+		{"daily, at the hour", daily, "2026-10-15 08:00", "2026-10-16 08:00", true, "2026-10-15 08:00"},
+		{"daily, already sent", daily, "2026-10-16 08:05", "2026-10-16 20:00", false, ""},
+		{"daily, missed days", daily, "2026-10-12 08:00", "2026-10-16 09:00", true, "2026-10-12 08:00"},
+		{"daily, never sent", daily, "", "2026-10-16 09:00", true, "2026-10-15 08:00"},
+		// 2026-10-19 is a Monday
+		{"weekly, on another day", weekly, "2026-10-12 08:00", "2026-10-18 23:00", false, ""},
+		{"weekly, on the day", weekly, "2026-10-12 08:00", "2026-10-19 08:30", true, "2026-10-12 08:00"},
+		{"weekly, never sent", weekly, "", "2026-10-21 12:00", true, "2026-10-12 08:00"},
+	}
+	for _, tt := range tests {
+		s := tt.s
+		if tt.sent != "" {
+			s.SentAt = at(tt.sent)
+		}
+		since, due := s.due(at(tt.now))
+		if due != tt.due {
+			t.Errorf("%s: due = %v, want %v", tt.name, due, tt.due)
+			continue
+		}
+		if due && !since.Equal(at(tt.since)) {
+			t.Errorf("%s: since = %v, want %s", tt.name, since, tt.since)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/imagehash"
+	"infokeep/internal/mediakind"
+)
+
+// Images are fingerprinted when they are uploaded, or by the
+// media_fingerprints task for those that reach the server another way
+// (direct uploads to object storage, imports), and the duplicates page
+// groups images that are the same file or look the same.
+
+const (
+	// duplicateMaxDistance is how many bits the perceptual hashes of two
+	// images may differ in for them to count as the same picture
+	duplicateMaxDistance = 6
+	// fingerprintBatchSize is how many media items the media_fingerprints
+	// task reads per run
+	fingerprintBatchSize = 100
+	// fingerprintMaxFile caps the size of a file in object storage that is
+	// fingerprinted
+	fingerprintMaxFile = 64 << 20
+)
+
+// errNotFingerprintable marks files that cannot be read now or later
+var errNotFingerprintable = errors.New("file cannot be fingerprinted")
+
+// fingerprintPendingMedia fingerprints media items that have no fingerprint.
+// Files that are gone are marked so they are not tried again; other errors
+// are retried on the next run.
+func fingerprintPendingMedia() (string, error) {
+	files, err := database.GetMediaWithoutHashes(fingerprintBatchSize)
+	if err != nil {
+		return "", err
+	}
+	done, failed := 0, 0
+	for _, f := range files {
+		if err := fingerprintMediaItem(f); err != nil {
+			log.Printf("Media fingerprints: item %d: %v", f.ID, err)
+			failed++
+			continue
+		}
+		done++
+	}
+	return fmt.Sprintf("Fingerprinted %d images, %d failed", done, failed), nil
+}
+
+// fingerprintMediaItem computes and stores the fingerprints and kind of a
+// media item, and tags it with its kind if its owner wants that
+func fingerprintMediaItem(f database.MediaFile) error {
+	fp, err := fingerprintFile(f.FilePath, f.Title)
+	if errors.Is(err, errNotFingerprintable) || os.IsNotExist(err) {
+		return errors.Join(err, database.SetMediaFingerprints(f.ID, "", "", ""))
+	} else if err != nil {
+		return err
+	}
+	if err := database.SetMediaFingerprints(f.ID, fp.ContentHash, fp.ImageHash, string(fp.Kind)); err != nil {
+		return err
+	}
+	if fp.Kind != mediakind.Unknown && database.GetUserSetting(f.UserID, mediaKindTagsSetting) == "on" {
+		return addItemTag(f.ID, string(fp.Kind))
+	}
+	return nil
+}
+
+// mediaFingerprint is what fingerprintFile finds out about a file
+type mediaFingerprint struct {
+	ContentHash string // SHA-256
+	ImageHash   string // perceptual hash, if it is an image
+	Kind        mediakind.Kind
+}
+
+// fingerprintFile fingerprints the file at path, as stored with a media item
+// called name
+func fingerprintFile(path, name string) (mediaFingerprint, error) {
+	var fp mediaFingerprint
+	var file io.ReadSeeker
+	if fileName, ok := uploadedFileName(path); ok {
+		f, err := os.Open(filepath.Join(datadir.Uploads(), fileName))
+		if err != nil {
+			return fp, err
+		}
+		defer f.Close()
+		file = f
+	} else if key, ok := s3.objectKey(path); ok && s3Enabled() {
+		body, err := s3.getObject(key)
+		if err != nil {
+			return fp, err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(io.LimitReader(body, fingerprintMaxFile+1))
+		if err != nil {
+			return fp, err
+		}
+		if len(data) > fingerprintMaxFile {
+			return fp, fmt.Errorf("%w: larger than %d bytes", errNotFingerprintable, fingerprintMaxFile)
+		}
+		file = bytes.NewReader(data)
+	} else {
+		return fp, fmt.Errorf("%w: %s is not stored by this server", errNotFingerprintable, path)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return fp, err
+	}
+	fp.ContentHash = hex.EncodeToString(h.Sum(nil))
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fp, err
+	}
+	exif, _ := mediakind.ReadEXIF(file)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fp, err
+	}
+	if img, format, err := imagehash.Decode(file); err == nil {
+		fp.ImageHash = fmt.Sprintf("%016x", imagehash.Difference(img))
+		fp.Kind = mediakind.Classify(img, format, exif, name)
+	}
+	return fp, nil
+}
+
+// addItemTag adds a tag to the tags an item has
+func addItemTag(itemID int64, tag string) error {
+	tags, err := database.GetItemTags(itemID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if t == tag {
+			return nil
+		}
+	}
+	return database.SetItemTags(itemID, append(tags, tag))
+}
+
+// duplicateGroup is a set of images that look like copies of each other
+type duplicateGroup struct {
+	// Identical is set when all are the same file
+	Identical bool
+	Media     []database.MediaFingerprint
+}
+
+// groupDuplicates groups the media whose files are identical or whose
+// pictures are at most duplicateMaxDistance apart. Media with no copies is
+// left out.
+func groupDuplicates(media []database.MediaFingerprint) []duplicateGroup {
+	parent := make([]int, len(media))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	hashes := make([]uint64, len(media))
+	hasHash := make([]bool, len(media))
+	for i, m := range media {
+		if v, err := strconv.ParseUint(m.ImageHash, 16, 64); err == nil {
+			hashes[i], hasHash[i] = v, true
+		}
+	}
+	for i := range media {
+		for j := i + 1; j < len(media); j++ {
+			same := media[i].ContentHash != "" && media[i].ContentHash == media[j].ContentHash
+			if !same && hasHash[i] && hasHash[j] {
+				same = imagehash.Distance(hashes[i], hashes[j]) <= duplicateMaxDistance
+			}
+			if same {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	// Groups in the order of their first (oldest) image
+	var groups []duplicateGroup
+	index := make(map[int]int)
+	for i, m := range media {
+		root := find(i)
+		g, ok := index[root]
+		if !ok {
+			g = len(groups)
+			index[root] = g
+			groups = append(groups, duplicateGroup{Identical: true})
+		}
+		if len(groups[g].Media) > 0 && groups[g].Media[0].ContentHash != m.ContentHash {
+			groups[g].Identical = false
+		}
+		groups[g].Media = append(groups[g].Media, m)
+	}
+	var dups []duplicateGroup
+	for _, g := range groups {
+		if len(g.Media) > 1 {
+			dups = append(dups, g)
+		}
+	}
+	return dups
+}
+
+// DuplicateMediaHandler shows the user's images that look like copies of
+// each other
+func DuplicateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	media, err := database.GetMediaFingerprints(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
+	RenderTemplate(w, "media_duplicates.html", map[string]interface{}{
+		"Groups":    groupDuplicates(media),
+		"Tags":      tagsWithCounts,
+		"ActiveTag": "",
+	})
+}
+
+// MergeDuplicateMediaHandler keeps one image of a group of duplicates
+// ("keep") and moves the others ("ids") to the trash, after giving the one
+// kept their tags
+func MergeDuplicateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	keepID, err := strconv.ParseInt(r.FormValue("keep"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid image to keep", http.StatusBadRequest)
+		return
+	}
+	var dropIDs []int64
+	for _, s := range r.Form["ids"] {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid image ID", http.StatusBadRequest)
+			return
+		}
+		if id != keepID {
+			dropIDs = append(dropIDs, id)
+		}
+	}
+
+	err = database.MergeMedia(userID, keepID, dropIDs)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
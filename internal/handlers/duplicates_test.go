@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"testing"
+
+	"infokeep/internal/database"
+)
+
+func TestGroupDuplicates(t *testing.T) {
+	media := []database.MediaFingerprint{
+		{ID: 1, ContentHash: "aa", ImageHash: "f0f0f0f0f0f0f0f0"},
+		{ID: 2, ContentHash: "bb", ImageHash: "0123456789abcdef"},
+		{ID: 3, ContentHash: "aa", ImageHash: "f0f0f0f0f0f0f0f0"},
+		{ID: 4, ContentHash: "cc", ImageHash: "0123456789abcde0"}, // 3 bits from 2
+		{ID: 5, ContentHash: "dd", ImageHash: "ffffffffffffffff"},
+		{ID: 6, ContentHash: "ee"}, // not an image
+		{ID: 7, ContentHash: "ff"},
+	}
+	groups := groupDuplicates(media)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %+v", len(groups), groups)
+	}
+	want := []struct {
+		ids       []int64
+		identical bool
+	}{
+		{[]int64{1, 3}, true},
+		{[]int64{2, 4}, false},
+	}
+	for i, w := range want {
+		g := groups[i]
+		var ids []int64
+		for _, m := range g.Media {
+			ids = append(ids, m.ID)
+		}
+		if len(ids) != len(w.ids) || ids[0] != w.ids[0] || ids[1] != w.ids[1] || g.Identical != w.identical {
+			t.Errorf("group %d = %v (identical %v), want %v (identical %v)", i, ids, g.Identical, w.ids, w.identical)
+		}
+	}
+}
+
+func TestFingerprintFileNotStored(t *testing.T) {
+	if _, err := fingerprintFile("https://example.com/a.png", "a.png"); err == nil {
+		t.Error("fingerprintFile() of a file elsewhere succeeded")
+	}
+}
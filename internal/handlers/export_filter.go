@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+// exportFilter narrows a data export down to some of the user's items, such
+// as the notes tagged work or a single rated list. The zero filter exports
+// everything.
+type exportFilter struct {
+	Types []string `json:"types,omitempty"` // item types, as in importTypes
+	Tag   string   `json:"tag,omitempty"`
+	From  string   `json:"from,omitempty"` // YYYY-MM-DD, created on or after
+	To    string   `json:"to,omitempty"`   // YYYY-MM-DD, created on or before
+	IDs   []int64  `json:"ids,omitempty"`
+}
+
+// parseExportFilter reads the filter of an export request: type and ids as
+// comma-separated or repeated values, tag, from and to
+func parseExportFilter(v *validation.Validator, values url.Values) exportFilter {
+	var f exportFilter
+	for _, t := range splitValues(values["type"]) {
+		if !slices.ContainsFunc(importTypes, func(it struct{ typ, label string }) bool { return it.typ == t }) {
+			v.Add("type", "is not an item type: "+t)
+			continue
+		}
+		f.Types = append(f.Types, t)
+	}
+	for _, s := range splitValues(values["ids"]) {
+		if id := v.ID("ids", s); id != 0 {
+			f.IDs = append(f.IDs, id)
+		}
+	}
+	f.Tag = strings.TrimSpace(values.Get("tag"))
+	f.From = v.Date("from", values.Get("from"))
+	f.To = v.Date("to", values.Get("to"))
+	if f.From != "" && f.To != "" && f.To < f.From {
+		v.Add("to", "must not be before from")
+	}
+	return f
+}
+
+// splitValues returns the comma-separated values of repeated form values
+func splitValues(values []string) []string {
+	var split []string
+	for _, value := range values {
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				split = append(split, s)
+			}
+		}
+	}
+	return split
+}
+
+// all reports whether the filter exports everything
+func (f exportFilter) all() bool {
+	return len(f.Types) == 0 && len(f.IDs) == 0 && f.Tag == "" && f.From == "" && f.To == ""
+}
+
+// keeps reports whether the export includes an item of the type
+func (f exportFilter) keeps(itemType string, item models.Item) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, itemType) {
+		return false
+	}
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, item.ID) {
+		return false
+	}
+	if f.Tag != "" && !slices.ContainsFunc(item.Tags, func(tag string) bool { return strings.EqualFold(tag, f.Tag) }) {
+		return false
+	}
+	// created_at starts with the date in every format the database returns
+	day := item.CreatedAt[:min(len(item.CreatedAt), len(time.DateOnly))]
+	if f.From != "" && day < f.From {
+		return false
+	}
+	if f.To != "" && day > f.To {
+		return false
+	}
+	return true
+}
+
+// apply removes the items the filter leaves out from an export
+func (f exportFilter) apply(d *exportData) {
+	d.Bookmarks = filterItems(d.Bookmarks, func(b models.Bookmark) bool { return f.keeps("bookmark", b.Item) })
+	d.Notes = filterItems(d.Notes, func(n models.Note) bool { return f.keeps("note", n.Item) })
+	d.Drawings = filterItems(d.Drawings, func(dr models.Drawing) bool { return f.keeps("drawing", dr.Item) })
+	d.Lists = filterItems(d.Lists, func(l models.List) bool { return f.keeps("list", l.Item) })
+	d.RatedLists = filterItems(d.RatedLists, func(l models.RatedList) bool { return f.keeps("rated_list", l.Item) })
+	d.Recipes = filterItems(d.Recipes, func(r models.Recipe) bool { return f.keeps("recipe", r.Item) })
+	d.Media = filterItems(d.Media, func(m models.Media) bool { return f.keeps("media", m.Item) })
+}
+
+// filterItems returns the items keep is true for
+func filterItems[T any](items []T, keep func(T) bool) []T {
+	kept := items[:0]
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
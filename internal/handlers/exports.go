@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/validation"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	dataExportJob = "data_export"
+	// exportLinkTTL is how long a finished export can be downloaded, in minutes
+	exportLinkTTL = 24 * 60
+)
+
+var (
+	// exportDir holds finished export archives until their link expires
+	exportDir = os.Getenv("EXPORT_DIR")
+	// exportQuota caps the disk space one user's stored exports may take
+	exportQuota int64 = 500 << 20
+)
+
+func init() {
+	if exportDir == "" {
+		exportDir = datadir.Path("exports")
+	}
+	if v, err := strconv.ParseInt(os.Getenv("EXPORT_QUOTA_MB"), 10, 64); err == nil && v > 0 {
+		exportQuota = v << 20
+	}
+}
+
+// dataExportPayload is the job payload of a data export
+type dataExportPayload struct {
+	Format string       `json:"format"`
+	Filter exportFilter `json:"filter"`
+}
+
+// StartExportHandler queues a data export (form value format=json|csv|personal|notes|vault).
+// The personal format is the full package of everything stored about the user;
+// notes is the notes as Markdown files to keep in a git repository, and vault
+// as a vault to open in Obsidian or Logseq (notes_vault.go). The other
+// formats can be narrowed down to some items with the form values of
+// ExportDataHandler.
+func StartExportHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	format := r.FormValue("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "personal" && format != "notes" && format != "vault" {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+	r.ParseForm()
+	var v validation.Validator
+	filter := parseExportFilter(&v, r.Form)
+	if format == "personal" && !filter.all() {
+		v.Add("format", "everything about you can't be filtered")
+	}
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	// One export at a time is plenty; hand back the one already in progress
+	if recent, err := database.GetRecentJobs(userID, dataExportJob, 1); err == nil && len(recent) > 0 {
+		if job := recent[0]; job.Status == database.JobPending || job.Status == database.JobRunning {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"job_id": job.ID, "status": job.Status})
+			return
+		}
+	}
+
+	payload, _ := json.Marshal(dataExportPayload{Format: format, Filter: filter})
+	jobID, err := enqueueJob(userID, dataExportJob, "", string(payload))
+	if err != nil {
+		log.Printf("Failed to enqueue export for user %d: %v", userID, err)
+		http.Error(w, "Failed to start export", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"job_id": jobID, "status": database.JobPending})
+}
+
+// ExportJobsHandler lists the user's recent exports with their progress and,
+// once finished, a download link. The settings page polls it.
+func ExportJobsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	purgeExpiredExports()
+
+	jobs, err := database.GetRecentJobs(userID, dataExportJob, 10)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stored, _ := database.GetExportsByUser(userID)
+	byJob := make(map[int64]database.Export, len(stored))
+	for _, e := range stored {
+		byJob[e.JobID] = e
+	}
+
+	type exportStatus struct {
+		JobID       int64  `json:"job_id"`
+		Format      string `json:"format"`
+		Status      string `json:"status"`
+		Progress    int    `json:"progress"`
+		Error       string `json:"error,omitempty"`
+		CreatedAt   string `json:"created_at"`
+		Size        int64  `json:"size,omitempty"`
+		ExpiresAt   string `json:"expires_at,omitempty"`
+		DownloadURL string `json:"download_url,omitempty"`
+	}
+	results := []exportStatus{}
+	for _, job := range jobs {
+		var p dataExportPayload
+		json.Unmarshal([]byte(job.Payload), &p)
+		s := exportStatus{JobID: job.ID, Format: p.Format, Status: job.Status, Progress: job.Progress, CreatedAt: job.CreatedAt}
+		switch job.Status {
+		case database.JobDone:
+			if e, ok := byJob[job.ID]; ok {
+				s.Size = e.Size
+				s.ExpiresAt = e.ExpiresAt
+				s.DownloadURL = appURL("/settings/export/download/" + e.Token)
+			} else {
+				// Removed to stay under the quota, or the link expired
+				s.Status = "expired"
+			}
+		case database.JobFailed:
+			s.Error = job.Result
+		}
+		results = append(results, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// DownloadExportHandler serves a finished export while its link is valid
+func DownloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	export, err := database.GetExportByToken(getUserID(r), chi.URLParam(r, "token"))
+	if err != nil {
+		http.Error(w, "This download link has expired", http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(export.FilePath)
+	if err != nil {
+		http.Error(w, "This export is no longer available", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	created, _ := time.Parse(time.RFC3339, export.CreatedAt)
+	contentType := "application/json"
+	if export.Format != "json" {
+		contentType = "application/zip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", dataExportFilename(export.Format, created)))
+	http.ServeContent(w, r, "", created, f)
+}
+
+// runDataExportJob writes a user's export archive to exportDir and returns the export ID
+func runDataExportJob(job *database.Job) (string, error) {
+	purgeExpiredExports()
+
+	var p dataExportPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		return "", fmt.Errorf("invalid payload: %w", err)
+	}
+
+	data, err := collectExportData(job.UserID, func(percent int) {
+		database.SetJobProgress(job.ID, percent)
+	})
+	if err != nil {
+		return "", err
+	}
+	p.Filter.apply(data)
+
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+	ext := "json"
+	if p.Format != "json" {
+		ext = "zip"
+	}
+	path := filepath.Join(exportDir, fmt.Sprintf("export_%d_%d.%s", job.UserID, job.ID, ext))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	switch p.Format {
+	case "csv":
+		err = writeCSVExport(f, data)
+	case "personal":
+		err = writePersonalDataExport(f, job.UserID, data)
+	case "notes":
+		err = writeNotesExport(f, data)
+	case "vault":
+		err = writeVaultExport(f, job.UserID, data)
+	default:
+		err = writeJSONExport(f, data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write export: %w", err)
+	}
+	database.SetJobProgress(job.ID, 90)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if err := makeRoomForExport(job.UserID, info.Size()); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	token, err := generateSecureHash(32)
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	exportID, err := database.CreateExport(job.UserID, job.ID, p.Format, token, path, info.Size(), exportLinkTTL)
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to record export: %w", err)
+	}
+	database.SetJobProgress(job.ID, 100)
+	return strconv.FormatInt(exportID, 10), nil
+}
+
+// makeRoomForExport deletes the user's oldest stored exports until one of the
+// given size fits within the export quota
+func makeRoomForExport(userID, size int64) error {
+	if size > exportQuota {
+		return fmt.Errorf("export is %d MB, more than the %d MB export storage quota", size>>20, exportQuota>>20)
+	}
+	stored, err := database.GetExportsByUser(userID)
+	if err != nil {
+		return err
+	}
+	var used int64
+	for _, e := range stored {
+		used += e.Size
+	}
+	for _, e := range stored {
+		if used+size <= exportQuota {
+			break
+		}
+		removeExport(e)
+		used -= e.Size
+	}
+	return nil
+}
+
+// purgeExpiredExports deletes archives whose download link has expired
+func purgeExpiredExports() {
+	expired, err := database.GetExpiredExports()
+	if err != nil {
+		log.Printf("Failed to look up expired exports: %v", err)
+		return
+	}
+	for _, e := range expired {
+		removeExport(e)
+	}
+}
+
+func removeExport(e database.Export) {
+	if err := os.Remove(e.FilePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove export %s: %v", e.FilePath, err)
+		return
+	}
+	database.DeleteExport(e.ID)
+}
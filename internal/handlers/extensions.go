@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"infokeep/internal/extensions"
+)
+
+// itemCreated runs the item hooks of extensions for a new item
+func itemCreated(ctx context.Context, userID, itemID int64, itemType, title string) {
+	extensions.ItemCreated(ctx, extensions.Item{UserID: userID, ID: itemID, Type: itemType, Title: title})
+}
+
+// itemUpdated runs the item hooks of extensions for a changed item
+func itemUpdated(ctx context.Context, userID, itemID int64, itemType, title string) {
+	extensions.ItemUpdated(ctx, extensions.Item{UserID: userID, ID: itemID, Type: itemType, Title: title})
+}
+
+// ImportFromAppHandler imports a file exported from another app with the
+// extension importer named by the importer form value
+func ImportFromAppHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		http.Error(w, "File too large", http.StatusBadRequest)
+		return
+	}
+	importer, ok := extensions.LookupImporter(r.FormValue("importer"))
+	if !ok {
+		http.Error(w, "Unknown importer", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("importFile")
+	if err != nil {
+		http.Error(w, "Failed to retrieve file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	found, err := importer.Import(r.Context(), file)
+	if err != nil {
+		http.Error(w, "Could not read the file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := getUserID(r)
+	dryRun := r.FormValue("dry_run") == "true"
+	report, err := importBackup(userID, extensionImportData(found), importOptions{Mode: importMerge, DryRun: dryRun})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if dryRun {
+		redirectTo(w, r, "/settings?import=preview&summary="+url.QueryEscape(report.preview()), http.StatusSeeOther)
+		return
+	}
+	log.Printf("User %d imported a file with %s: %s", userID, importer.Name, report)
+	redirectTo(w, r, "/settings?import=success&summary="+url.QueryEscape(report.String()), http.StatusSeeOther)
+}
+
+// extensionImportData turns what an importer found into a backup, so it is
+// imported the same way
+func extensionImportData(found *extensions.Import) *importData {
+	data := &importData{}
+	for _, b := range found.Bookmarks {
+		bookmark := map[string]interface{}{
+			"title":       b.Title,
+			"url":         b.URL,
+			"description": b.Description,
+			"tags":        backupTagList(b.Tags),
+			"read_later":  b.ReadLater,
+			"read":        b.Read,
+		}
+		if !b.Created.IsZero() {
+			bookmark["created_at"] = b.Created.Format(time.RFC3339)
+		}
+		data.Bookmarks = append(data.Bookmarks, bookmark)
+	}
+	for _, n := range found.Notes {
+		data.Notes = append(data.Notes, map[string]interface{}{
+			"title":   n.Title,
+			"content": n.Content,
+			"tags":    backupTagList(n.Tags),
+		})
+	}
+	return data
+}
+
+// backupTagList is tags the way they are decoded from a backup's JSON
+func backupTagList(tags []string) []interface{} {
+	list := make([]interface{}, len(tags))
+	for i, t := range tags {
+		list[i] = t
+	}
+	return list
+}
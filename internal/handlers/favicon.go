@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/scraper"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Bookmark icons are served by /favicons/{host}. An icon is looked up once
+// per host: the <link rel="icon"> tags of the site's home page are tried
+// first, then the usual paths. What is found is stored in the database and
+// served from there; hosts without an icon get a letter tile.
+const (
+	faviconMaxSize      = 100 * 1024
+	faviconRefreshAfter = 30 * 24 * time.Hour
+)
+
+// faviconPaths are tried when a page doesn't link to a usable icon
+var faviconPaths = []string{"/favicon.ico", "/favicon.png", "/favicon.svg", "/apple-touch-icon.png"}
+
+// faviconRetryAfter is how long to wait before looking up the icon of a host
+// again after the given number of failed lookups in a row: an hour after the
+// first, doubling up to a week.
+func faviconRetryAfter(failures int) time.Duration {
+	week := 7 * 24 * time.Hour
+	if failures > 8 {
+		return week
+	}
+	return min(time.Hour<<max(failures-1, 0), week)
+}
+
+// FaviconHandler serves the cached icon of a host, looking it up first if it
+// isn't cached or is due for a refresh or retry
+func FaviconHandler(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(chi.URLParam(r, "host"))
+	if u, err := url.Parse("https://" + host); err != nil || u.Host != host || u.Hostname() == "" {
+		http.Error(w, "Invalid host", http.StatusBadRequest)
+		return
+	}
+
+	cached, err := database.GetFavicon(host)
+	if err != nil {
+		log.Printf("Favicon cache lookup for %s failed: %v", host, err)
+	}
+
+	if faviconDue(cached) {
+		contentType, data, source, err := resolveFavicon(r.Context(), host)
+		if err != nil {
+			log.Printf("No favicon found for %s: %v", host, err)
+			if cached == nil {
+				cached = &database.Favicon{Host: host}
+			}
+			// Keep serving a previously found icon until a lookup succeeds
+			cached.Failures++
+		} else {
+			cached = &database.Favicon{Host: host, ContentType: contentType, Data: data, SourceURL: source}
+		}
+		if err := database.SaveFavicon(*cached); err != nil {
+			log.Printf("Failed to cache favicon for %s: %v", host, err)
+		}
+	}
+
+	if len(cached.Data) == 0 {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		io.WriteString(w, faviconTile(host))
+		return
+	}
+	w.Header().Set("Content-Type", cached.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	// SVG icons come from other sites; don't let them run scripts here
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Write(cached.Data)
+}
+
+// faviconDue reports whether the icon of a host should be looked up (again)
+func faviconDue(f *database.Favicon) bool {
+	if f == nil {
+		return true
+	}
+	age := time.Since(f.FetchedAt)
+	if len(f.Data) == 0 || f.Failures > 0 {
+		return age > faviconRetryAfter(f.Failures)
+	}
+	return age > faviconRefreshAfter
+}
+
+// resolveFavicon finds and downloads the icon of a host. It returns the
+// icon's content type, its data and the URL it was found at.
+func resolveFavicon(ctx context.Context, host string) (string, []byte, string, error) {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: fetchTransport("favicon"),
+	}
+
+	// Sites that can't be reached over HTTPS are tried over plain HTTP
+	home := &url.URL{Scheme: "https", Host: host, Path: "/"}
+	candidates, finalURL, err := fetchFaviconPage(ctx, client, home.String())
+	if err != nil && ctx.Err() == nil {
+		home.Scheme = "http"
+		candidates, finalURL, err = fetchFaviconPage(ctx, client, home.String())
+	}
+	if err == nil {
+		home = finalURL
+	}
+	for _, p := range faviconPaths {
+		candidates = append(candidates, home.ResolveReference(&url.URL{Path: p}).String())
+	}
+
+	seen := map[string]bool{}
+	var lastErr error
+	for _, c := range candidates {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		contentType, data, err := fetchFavicon(ctx, client, c)
+		if err == nil {
+			return contentType, data, c, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no icon candidates")
+	}
+	return "", nil, "", lastErr
+}
+
+// fetchFaviconPage fetches a page, returning the icons it links to and its URL
+// after redirects
+func fetchFaviconPage(ctx context.Context, client *http.Client, pageURL string) ([]string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	meta, err := scraper.Parse(io.LimitReader(resp.Body, 512*1024), resp.Request.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return meta.Icons, resp.Request.URL, nil
+}
+
+// fetchFavicon downloads an icon, returning an error unless it is an image
+// of at most faviconMaxSize bytes
+func fetchFavicon(ctx context.Context, client *http.Client, iconURL string) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", iconURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("%s: status %d", iconURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, faviconMaxSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) == 0 || len(data) > faviconMaxSize {
+		return "", nil, fmt.Errorf("%s: empty or larger than %d bytes", iconURL, faviconMaxSize)
+	}
+
+	contentType := faviconContentType(data)
+	if contentType == "" {
+		return "", nil, fmt.Errorf("%s: not an image", iconURL)
+	}
+	return contentType, data, nil
+}
+
+// faviconContentType returns the image type of an icon, or "" if it isn't
+// one. Servers often send icons with a wrong or generic type, so the type is
+// sniffed from the data.
+func faviconContentType(data []byte) string {
+	sniffed := http.DetectContentType(data)
+	if strings.HasPrefix(sniffed, "image/") {
+		return sniffed
+	}
+	if bytes.Contains(data[:min(len(data), 1024)], []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return ""
+}
+
+// faviconTileColors are the background colors of letter tiles
+var faviconTileColors = []string{"#3e8ed0", "#48c78e", "#f14668", "#00d1b2", "#485fc7", "#9b59b6", "#e67e22", "#7a7a7a"}
+
+// faviconTile returns a square SVG showing the first letter or digit of a
+// host ("www." aside), on a color that is always the same for the host
+func faviconTile(host string) string {
+	name := strings.TrimPrefix(host, "www.")
+	letter := "?"
+	for _, c := range name {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			letter = strings.ToUpper(string(c))
+			break
+		}
+	}
+	sum := 0
+	for _, c := range name {
+		sum += int(c)
+	}
+	color := faviconTileColors[sum%len(faviconTileColors)]
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="32" height="32" viewBox="0 0 32 32">`+
+		`<rect width="32" height="32" rx="6" fill="%s"/>`+
+		`<text x="16" y="22" text-anchor="middle" font-family="sans-serif" font-size="18" font-weight="bold" fill="#fff">%s</text>`+
+		`</svg>`, color, letter)
+}
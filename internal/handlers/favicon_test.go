@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaviconContentType(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"\x00\x00\x01\x00\x01\x00", "image/x-icon"},
+		{"\x89PNG\r\n\x1a\n", "image/png"},
+		{`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`, "image/svg+xml"},
+		{"<!DOCTYPE html><html></html>", ""},
+	}
+	for _, tt := range tests {
+		if got := faviconContentType([]byte(tt.data)); got != tt.want {
+			t.Errorf("faviconContentType(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestFaviconTile(t *testing.T) {
+	tile := faviconTile("www.github.com")
+	if !strings.Contains(tile, ">G</text>") {
+		t.Errorf("tile for www.github.com doesn't show G: %s", tile)
+	}
+	if faviconTile("github.com") != tile {
+		t.Error("tile color differs with and without www.")
+	}
+	if !strings.Contains(faviconTile("[::1]:8080"), ">1</text>") {
+		t.Error("tile for an IP address should show its first digit")
+	}
+}
+
+func TestFaviconRetryAfter(t *testing.T) {
+	for failures, want := range map[int]time.Duration{
+		0:  time.Hour,
+		1:  time.Hour,
+		2:  2 * time.Hour,
+		4:  8 * time.Hour,
+		8:  128 * time.Hour,
+		9:  7 * 24 * time.Hour,
+		50: 7 * 24 * time.Hour,
+	} {
+		if got := faviconRetryAfter(failures); got != want {
+			t.Errorf("faviconRetryAfter(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"infokeep/internal/database"
+)
+
+// Experimental subsystems are gated by feature flags so they can ship dark
+// and be tried out by some users first. A flag has a built-in default that
+// admins can change for the whole server (system setting "feature:<name>"),
+// and each user can turn it on or off for themselves (user setting
+// "feature:<name>", empty to follow the server). A flag whose subsystem is
+// not configured, e.g. semantic search without EMBEDDINGS_URL, is off for
+// everyone.
+
+// feature is an experimental subsystem behind a flag
+type feature struct {
+	Name        string
+	Title       string
+	Description string
+	Default     bool
+	// available reports whether the subsystem is configured at all
+	available func() bool
+}
+
+var features = []feature{
+	{
+		Name:        "semantic_search",
+		Title:       "Semantic search",
+		Description: "Blend in items that match the meaning of the search, not only its words.",
+		Default:     true,
+		available:   semanticSearchEnabled,
+	},
+}
+
+// featureSettingKey is the system and user setting holding a flag's state
+func featureSettingKey(name string) string {
+	return "feature:" + name
+}
+
+func findFeature(name string) (feature, bool) {
+	for _, f := range features {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return feature{}, false
+}
+
+// resolveFeature is the state of a flag given its built-in default and the
+// stored server and user states ("on", "off" or empty)
+func resolveFeature(def bool, server, user string) bool {
+	enabled := def
+	if server != "" {
+		enabled = server == "on"
+	}
+	if user != "" {
+		enabled = user == "on"
+	}
+	return enabled
+}
+
+// serverFeatureDefault is whether a flag is on for users who did not choose
+func serverFeatureDefault(f feature) bool {
+	server, _ := database.GetSystemSetting(featureSettingKey(f.Name))
+	return resolveFeature(f.Default, server, "")
+}
+
+// featureEnabled reports whether the named flag is on for the user
+func featureEnabled(userID int64, name string) bool {
+	f, ok := findFeature(name)
+	if !ok || (f.available != nil && !f.available()) {
+		return false
+	}
+	server, _ := database.GetSystemSetting(featureSettingKey(name))
+	return resolveFeature(f.Default, server, database.GetUserSetting(userID, featureSettingKey(name)))
+}
+
+// userFeatures is the state of every flag for the user, for templates and
+// the API
+func userFeatures(userID int64) map[string]bool {
+	enabled := make(map[string]bool, len(features))
+	for _, f := range features {
+		enabled[f.Name] = featureEnabled(userID, f.Name)
+	}
+	return enabled
+}
+
+// featureSetting is a flag as the settings page shows it
+type featureSetting struct {
+	feature
+	Available     bool
+	Enabled       bool
+	ServerDefault bool
+	UserState     string // "on", "off" or empty to follow the server
+}
+
+func featureSettings(userID int64) []featureSetting {
+	var settings []featureSetting
+	for _, f := range features {
+		settings = append(settings, featureSetting{
+			feature:       f,
+			Available:     f.available == nil || f.available(),
+			Enabled:       featureEnabled(userID, f.Name),
+			ServerDefault: serverFeatureDefault(f),
+			UserState:     database.GetUserSetting(userID, featureSettingKey(f.Name)),
+		})
+	}
+	return settings
+}
+
+// FeatureHandler sets a flag for the user, with the form values name and
+// state ("on", "off" or empty to follow the server). With scope=server it
+// sets the default for everyone instead (admins only).
+func FeatureHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	name := r.FormValue("name")
+	if _, ok := findFeature(name); !ok {
+		http.Error(w, "Unknown feature", http.StatusBadRequest)
+		return
+	}
+	state := r.FormValue("state")
+	if state != "on" && state != "off" && state != "" {
+		http.Error(w, "state must be on, off or empty", http.StatusBadRequest)
+		return
+	}
+
+	if r.FormValue("scope") == "server" {
+		if !isAdmin(userID) {
+			http.Error(w, "Only admins can change the server defaults", http.StatusForbidden)
+			return
+		}
+		if err := database.SetSystemSetting(featureSettingKey(name), state); err != nil {
+			http.Error(w, "failed to save", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Feature %s set to %q for the server by user %d", name, state, userID)
+	} else if err := database.SetUserSetting(userID, featureSettingKey(name), state); err != nil {
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "enabled": featureEnabled(userID, name)})
+}
+
+// ApiFeaturesHandler returns which feature flags are on for the user
+func ApiFeaturesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userFeatures(getUserID(r)))
+}
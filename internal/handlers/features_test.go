@@ -0,0 +1,24 @@
+package handlers
+
+import "testing"
+
+func TestResolveFeature(t *testing.T) {
+	tests := []struct {
+		def          bool
+		server, user string
+		want         bool
+	}{
+		{true, "", "", true},
+		{false, "", "", false},
+		{true, "off", "", false},
+		{false, "on", "", true},
+		{false, "off", "on", true},
+		{true, "on", "off", false},
+		{true, "", "off", false},
+	}
+	for _, tt := range tests {
+		if got := resolveFeature(tt.def, tt.server, tt.user); got != tt.want {
+			t.Errorf("resolveFeature(%v, %q, %q) = %v, want %v", tt.def, tt.server, tt.user, got, tt.want)
+		}
+	}
+}
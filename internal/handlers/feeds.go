@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+// Each user has an RSS and an Atom feed of their newest items at
+// /feeds/{token}/rss and /feeds/{token}/atom, so a feed reader can show what
+// they clipped lately. ?type= limits a feed to one item type and ?tag= to
+// items with a tag. The token is the user's feed token (Settings → Feeds),
+// which only opens the feeds.
+
+const (
+	// feedItems is how many items a feed holds
+	feedItems = 50
+	// feedSummaryLength is how much of a bookmark's description or a note's
+	// text goes into a feed entry
+	feedSummaryLength = 500
+)
+
+// feedTypes are the item types a feed can be limited to, named as in the
+// feed's title
+var feedTypes = map[string]string{
+	"bookmark":   "bookmarks",
+	"note":       "notes",
+	"recipe":     "recipes",
+	"cookbook":   "cookbooks",
+	"list":       "checklists",
+	"rated_list": "rated lists",
+	"drawing":    "drawings",
+	"media":      "media",
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// itemLink returns where an item is shown: a bookmark's page, or the item in
+// infokeep under base
+func itemLink(base, itemType string, id int64, url string) string {
+	switch itemType {
+	case "bookmark":
+		if url != "" {
+			return url
+		}
+		return base + "/bookmarks"
+	case "recipe":
+		return fmt.Sprintf("%s/recipes/%d", base, id)
+	case "cookbook":
+		return fmt.Sprintf("%s/cookbooks/%d", base, id)
+	case "list":
+		return fmt.Sprintf("%s/lists?id=%d", base, id)
+	case "rated_list":
+		return fmt.Sprintf("%s/rated-lists?id=%d", base, id)
+	case "media":
+		return fmt.Sprintf("%s/media#media-%d", base, id)
+	default: // note, drawing
+		return fmt.Sprintf("%s/%ss#%s-%d", base, itemType, itemType, id)
+	}
+}
+
+// feedItemLink returns where a feed entry leads
+func feedItemLink(base string, item models.FeedItem) string {
+	return itemLink(base, item.Type, item.ID, item.URL)
+}
+
+// feedTitle names a feed after its filters, e.g. "InfoKeep: notes tagged
+// kitchen"
+func feedTitle(itemType, tag string) string {
+	title := "InfoKeep: new items"
+	if name, ok := feedTypes[itemType]; ok {
+		title = "InfoKeep: " + name
+	}
+	if tag != "" {
+		title += " tagged " + tag
+	}
+	return title
+}
+
+func feedSummary(item models.FeedItem) string {
+	summary := strings.TrimSpace(item.Summary)
+	if r := []rune(summary); len(r) > feedSummaryLength {
+		summary = strings.TrimSpace(string(r[:feedSummaryLength])) + "…"
+	}
+	return summary
+}
+
+// feedURLs returns the user's RSS and Atom feed URLs
+func feedURLs(r *http.Request, token string) map[string]string {
+	base := getBaseURL(r) + "/feeds/" + token
+	return map[string]string{"rss": base + "/rss", "atom": base + "/atom"}
+}
+
+// FeedHandler serves a user's feed of new items as RSS or Atom. It is
+// public, authenticated by the feed token in the path.
+func FeedHandler(w http.ResponseWriter, r *http.Request) {
+	format := chi.URLParam(r, "format")
+	if format != "rss" && format != "atom" {
+		http.NotFound(w, r)
+		return
+	}
+	userID, err := database.GetUserByFeedToken(chi.URLParam(r, "token"))
+	if err != nil {
+		http.Error(w, "Invalid feed token", http.StatusUnauthorized)
+		return
+	}
+
+	itemType := r.URL.Query().Get("type")
+	if _, ok := feedTypes[itemType]; itemType != "" && !ok {
+		http.Error(w, "Unknown item type", http.StatusBadRequest)
+		return
+	}
+	tag := strings.TrimSpace(strings.ToLower(r.URL.Query().Get("tag")))
+
+	items, err := database.GetFeedItems(userID, itemType, tag, feedItems)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := getBaseURL(r)
+	title := feedTitle(itemType, tag)
+	updated := time.Now()
+	if len(items) > 0 {
+		updated = items[0].CreatedAt
+	}
+
+	var doc interface{}
+	if format == "rss" {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		feed := rssFeed{Version: "2.0", Channel: rssChannel{
+			Title:         title,
+			Link:          base + "/",
+			Description:   "The newest items saved in InfoKeep",
+			LastBuildDate: updated.UTC().Format(time.RFC1123Z),
+		}}
+		for _, item := range items {
+			feed.Channel.Items = append(feed.Channel.Items, rssItem{
+				Title:       item.Title,
+				Link:        feedItemLink(base, item),
+				GUID:        rssGUID{Value: fmt.Sprintf("%s/items/%d", base, item.ID)},
+				Description: feedSummary(item),
+				Categories:  item.Tags,
+				PubDate:     item.CreatedAt.UTC().Format(time.RFC1123Z),
+			})
+		}
+		doc = feed
+	} else {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		self := base + r.URL.RequestURI()
+		feed := atomFeed{
+			Title:   title,
+			ID:      self,
+			Updated: updated.UTC().Format(time.RFC3339),
+			Author:  "InfoKeep",
+			Links:   []atomLink{{Href: self, Rel: "self"}, {Href: base + "/"}},
+		}
+		for _, item := range items {
+			entry := atomEntry{
+				Title:     item.Title,
+				ID:        fmt.Sprintf("%s/items/%d", base, item.ID),
+				Link:      atomLink{Href: feedItemLink(base, item)},
+				Published: item.CreatedAt.UTC().Format(time.RFC3339),
+				Updated:   item.CreatedAt.UTC().Format(time.RFC3339),
+				Summary:   feedSummary(item),
+			}
+			for _, tag := range item.Tags {
+				entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+		doc = feed
+	}
+
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(doc)
+}
+
+// RegenerateFeedTokenHandler gives the user a new feed token, so the feed
+// URLs they handed out stop working, and returns the new URLs
+func RegenerateFeedTokenHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := database.RegenerateFeedToken(getUserID(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedURLs(r, token))
+}
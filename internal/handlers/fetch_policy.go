@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Fetches made for users (see fetchTransport) can be sent through their own
+// proxy, e.g. Tor, and limited to or kept away from certain domains:
+//
+//	FETCH_PROXY=socks5://127.0.0.1:9050
+//	FETCH_ALLOW_DOMAINS=example.com,allrecipes.com
+//	FETCH_DENY_DOMAINS=internal.corp
+//
+// A domain rule also matches its subdomains. Deny rules win over allow rules;
+// with allow rules set, every other domain is blocked. The services the admin
+// configures (TRANSLATE_URL, SUMMARIZE_URL and the like, see
+// serviceTransport) and other outbound requests (cloud backups, S3) are not
+// limited and use the standard HTTPS_PROXY/NO_PROXY variables.
+var (
+	fetchProxy        = os.Getenv("FETCH_PROXY")
+	fetchAllowDomains = parseDomainList(os.Getenv("FETCH_ALLOW_DOMAINS"))
+	fetchDenyDomains  = parseDomainList(os.Getenv("FETCH_DENY_DOMAINS"))
+)
+
+// fetchBaseTransport makes the requests of fetchTransport clients
+var fetchBaseTransport = newFetchBaseTransport(fetchProxy)
+
+// newFetchBaseTransport returns a transport that uses the given proxy, or
+// the environment's proxy settings when it is empty. An invalid proxy makes
+// every request fail rather than quietly going out directly.
+func newFetchBaseTransport(proxy string) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy == "" {
+		return t
+	}
+	proxyURL, err := parseProxyURL(proxy)
+	t.Proxy = func(*http.Request) (*url.URL, error) {
+		if err != nil {
+			return nil, fmt.Errorf("invalid FETCH_PROXY: %w", err)
+		}
+		return proxyURL, nil
+	}
+	return t
+}
+
+// parseProxyURL accepts http, https and socks5 proxy URLs. socks5h, which
+// curl uses to resolve names on the proxy, means the same here: Go always
+// lets a SOCKS proxy resolve the host name.
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing proxy host")
+	}
+	return u, nil
+}
+
+// parseDomainList splits a comma separated list of domains, dropping a
+// leading "*." or "."
+func parseDomainList(s string) []string {
+	var domains []string
+	for _, d := range strings.Split(s, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(strings.TrimPrefix(d, "*"), ".")
+		if d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// matchesDomain reports whether host is one of the domains or a subdomain of one
+func matchesDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFetchPolicy returns an error if the fetch policy blocks the URL's host
+func checkFetchPolicy(u *url.URL, allow, deny []string) error {
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if matchesDomain(host, deny) || (len(allow) > 0 && !matchesDomain(host, allow)) {
+		return fmt.Errorf("fetching from %s is blocked by the server's fetch policy", host)
+	}
+	return nil
+}
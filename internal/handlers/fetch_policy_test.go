@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCheckFetchPolicy(t *testing.T) {
+	allow := parseDomainList("example.com, *.recipes.org")
+	deny := parseDomainList(".ads.example.com")
+
+	tests := []struct {
+		url     string
+		allow   []string
+		blocked bool
+	}{
+		{"https://example.com/a", allow, false},
+		{"https://www.example.com/a", allow, false},
+		{"https://EXAMPLE.com./a", allow, false},
+		{"https://recipes.org/", allow, false},
+		{"https://cdn.ads.example.com/x.png", allow, true},
+		{"https://notexample.com/", allow, true},
+		{"http://10.0.0.1:8080/", allow, true},
+		{"https://anything.net/", nil, false},
+		{"https://tracker.ads.example.com/", nil, true},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if err := checkFetchPolicy(u, tt.allow, deny); (err != nil) != tt.blocked {
+			t.Errorf("checkFetchPolicy(%s) = %v, want blocked=%v", tt.url, err, tt.blocked)
+		}
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"http://proxy.corp:3128", "http://proxy.corp:3128"},
+		{"socks5://127.0.0.1:9050", "socks5://127.0.0.1:9050"},
+		{"socks5h://127.0.0.1:9050", "socks5://127.0.0.1:9050"},
+		{"ftp://proxy", ""},
+		{"127.0.0.1:9050", ""},
+	}
+	for _, tt := range tests {
+		u, err := parseProxyURL(tt.in)
+		got := ""
+		if err == nil {
+			got = u.String()
+		}
+		if got != tt.want {
+			t.Errorf("parseProxyURL(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestServiceTransportSkipsFetchPolicy(t *testing.T) {
+	old := fetchAllowDomains
+	defer func() { fetchAllowDomains = old }()
+	fetchAllowDomains = parseDomainList("example.com")
+
+	// A local service, such as an Ollama the admin set SUMMARIZE_URL to
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	resp, err := (&http.Client{Transport: serviceTransport("summarize")}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("service call: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := (&http.Client{Transport: fetchTransport("thumbnail")}).Get(srv.URL); err == nil {
+		t.Error("fetch for a user outside FETCH_ALLOW_DOMAINS was not blocked")
+	}
+}
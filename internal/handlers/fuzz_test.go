@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+
+	"infokeep/internal/database"
+)
+
+// The fuzz targets feed the parsers what a hostile or broken site or backup
+// could hold. The seeds are trimmed from real recipe pages and exports; run
+// make fuzz (or go test -fuzz=FuzzParseJSONLDContent ./internal/handlers) to
+// explore further.
+
+var jsonLDSeeds = []string{
+	// WordPress (Yoast) puts the recipe in a @graph next to the page
+	`{"@context":"https://schema.org","@graph":[{"@type":"WebPage","@id":"https://example.com/bread/","name":"Bread"},` +
+		`{"@type":"Recipe","name":"No-Knead Bread","author":{"@type":"Person","name":"Jim"},` +
+		`"image":["https://example.com/bread-1x1.jpg","https://example.com/bread-4x3.jpg"],` +
+		`"recipeYield":["1","1 loaf"],"prepTime":"PT10M","cookTime":"PT45M",` +
+		`"recipeIngredient":["3 cups flour","1 &frac12; tsp salt","&frac14; tsp yeast"],` +
+		`"recipeInstructions":[{"@type":"HowToSection","name":"Dough","itemListElement":[` +
+		`{"@type":"HowToStep","text":"Mix everything."},{"@type":"HowToStep","text":"Rest 18 hours."}]},` +
+		`{"@type":"HowToSection","name":"Bake","itemListElement":[{"@type":"HowToStep","text":"Bake at 230&deg;C."}]}]}]}`,
+	// A top-level array with @type as an array and an image object
+	`[{"@context":"http://schema.org","@type":["Recipe","NewsArticle"],"name":"Shakshuka",` +
+		`"image":{"@type":"ImageObject","url":"https://example.com/shakshuka.jpg","width":1200},` +
+		`"recipeIngredient":["6 eggs","1 can tomatoes"],"recipeInstructions":"Simmer the sauce. Crack in the eggs."}]`,
+	// Instructions as plain strings with entities, nutrition and a rating
+	`{"@context":"http://schema.org","@type":"Recipe","name":"Chicken &quot;Parmesan&quot;",` +
+		`"recipeIngredient":["Salt &amp; Pepper"],"recipeInstructions":["Cook for 10&#45;15 minutes."],` +
+		`"nutrition":{"@type":"NutritionInformation","calories":"520 kcal"},` +
+		`"aggregateRating":{"@type":"AggregateRating","ratingValue":"4.8","ratingCount":"1312"}}`,
+	// Shapes a sloppy site produces
+	`{"@type":"Recipe","name":null,"recipeIngredient":"2 eggs","recipeInstructions":[null,1,{"text":[]}],"image":[[]]}`,
+	`{"@graph":"not a list","@type":"Recipe"}`,
+	`[[{"@type":"Recipe"}]]`,
+	`{"@type":{"nested":"Recipe"}}`,
+	`null`,
+	`{`,
+	``,
+}
+
+func FuzzParseJSONLDContent(f *testing.F) {
+	for _, seed := range jsonLDSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, content string) {
+		parseJSONLDContent(content)
+	})
+}
+
+func FuzzExtractFromHTML(f *testing.F) {
+	for _, seed := range jsonLDSeeds {
+		f.Add(`<html><head><script type="application/ld+json">` + seed + `</script></head><body></body></html>`)
+	}
+	f.Add(`<html lang="de"><body><h1 class="recipe-title">Apfelkuchen</h1>
+<div itemscope itemtype="https://schema.org/Recipe"><span itemprop="name">Apfelkuchen</span>
+<ul><li itemprop="recipeIngredient">4 Äpfel</li><li itemprop="recipeIngredient">200 g Mehl</li></ul>
+<div itemprop="recipeInstructions"><ol><li>Äpfel schälen.</li><li>45 Minuten backen.</li></ol></div>
+<img itemprop="image" src="/kuchen.jpg"></div></body></html>`)
+	f.Add(`<article><h2>Ingredients</h2><ul class="ingredients"><li>1 cup rice</li><li>2 cups water</li></ul>
+<h2>Instructions</h2><ol class="instructions"><li>Rinse the rice.</li><li>Boil for 12 minutes.</li></ol></article>`)
+	f.Add(`<div itemscope itemtype="Recipe"><div itemscope><span itemprop="recipeIngredient"></span></div>`)
+	f.Add(`<script type="application/ld+json"></script><h1></h1><ul class="ingredients"><li></li>`)
+	f.Add(`<table><tr><td><ul class="ingredients"><li><ul><li>nested</li></ul></li></ul>`)
+	f.Fuzz(func(t *testing.T, page string) {
+		doc, err := html.Parse(strings.NewReader(page))
+		if err != nil {
+			return
+		}
+		extractJSONLD(doc)
+		extractMicrodata(doc)
+		if _, err := extractFromHTML(doc); err != nil {
+			return
+		}
+		documentLanguage(doc)
+	})
+}
+
+func FuzzImportData(f *testing.F) {
+	if err := database.InitDB(filepath.Join(f.TempDir(), "fuzz.db")); err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { database.DB.Close() })
+	if _, err := database.DB.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'fuzz', 'x')"); err != nil {
+		f.Fatal(err)
+	}
+
+	// An export as /settings/export writes it, and variations on it
+	f.Add(`{"bookmarks":[{"id":3,"title":"Flour guide","url":"https://example.com/flour","description":"",` +
+		`"favicon":"/static/uploads/favicon.ico","tags":["baking"],"created_at":"2024-03-01T10:00:00Z"}],` +
+		`"notes":[{"id":1,"title":"Sourdough starter","content":"Feed with **rye**","tags":["baking","kitchen"]}],` +
+		`"drawings":[{"id":6,"title":"Oven sketch","image_path":"/static/uploads/oven.png","tags":[]}],` +
+		`"lists":[{"title":"Groceries","items":[{"content":"Flour","completed":false,"quantity":2,"unit":"kg"},` +
+		`{"content":"Salt","completed":true}],"tags":["kitchen"]}],` +
+		`"rated_lists":[{"title":"Bakeries","items":[{"title":"Corner","score":4,"note":"good crust"}],"tags":[]}],` +
+		`"recipes":[{"title":"Rye bread","ingredients":"500 g rye flour\n10 g salt","instructions":"Bake 45 minutes",` +
+		`"images":["/static/uploads/rye.jpg"],"tags":["baking"]}],` +
+		`"media":[{"id":7,"title":"Crumb shot","file_path":"/static/uploads/crumb.png","mime_type":"image/png"}]}`)
+	f.Add(`{"notes":[{"title":1,"content":{"a":[]},"tags":"baking"}],"bookmarks":[{"url":null,"tags":[1,null,"x"]}]}`)
+	f.Add(`{"lists":[{"title":"","items":[{"content":"","quantity":-1e308}]}],"rated_lists":[{"items":[{"score":-7}]}]}`)
+	f.Add(`{"recipes":[{"images":["../../../etc/passwd","/static/uploads/../x"],"tags":["/","a/b/"]}]}`)
+	f.Add(`{"notes":[{"title":"\u0000","tags":["` + strings.Repeat("x", 300) + `"]}]}`)
+	f.Add(`{"notes":[]}`)
+	f.Add(`[]`)
+	f.Add(`{"bookmarks":{}`)
+
+	f.Fuzz(func(t *testing.T, backup string) {
+		data, err := decodeImportData(strings.NewReader(backup))
+		if err != nil {
+			return
+		}
+		// Keep each run short: the import path is the same for every item
+		if data.itemCount() > 50 {
+			return
+		}
+		if _, err := importBackup(1, data, importOptions{Mode: importMerge}); err != nil {
+			t.Logf("import failed: %v", err)
+		}
+	})
+}
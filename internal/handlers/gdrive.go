@@ -46,7 +46,7 @@ func GDriveCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	if code == "" {
 		errMsg := r.URL.Query().Get("error")
 		log.Printf("Google Drive auth denied: %s", errMsg)
-		http.Redirect(w, r, "/settings?gdrive=error", http.StatusFound)
+		redirectTo(w, r, "/settings?gdrive=error", http.StatusFound)
 		return
 	}
 
@@ -61,7 +61,7 @@ func GDriveCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		"grant_type":    {"authorization_code"},
 	}
 
-	resp, err := http.PostForm("https://oauth2.googleapis.com/token", data)
+	resp, err := cloudAPIClient.PostForm("https://oauth2.googleapis.com/token", data)
 	if err != nil {
 		log.Printf("Google Drive token exchange failed: %v", err)
 		http.Error(w, "Failed to exchange authorization code", http.StatusInternalServerError)
@@ -105,7 +105,7 @@ func GDriveCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Google Drive account linked successfully for user %d", userID)
-	http.Redirect(w, r, "/settings?gdrive=linked", http.StatusFound)
+	redirectTo(w, r, "/settings?gdrive=linked", http.StatusFound)
 }
 
 // GDriveUnlinkHandler removes Google Drive credentials
@@ -147,7 +147,7 @@ func refreshGDriveToken(userID int64, refreshToken string) (string, error) {
 		"grant_type":    {"refresh_token"},
 	}
 
-	resp, err := http.PostForm("https://oauth2.googleapis.com/token", data)
+	resp, err := cloudAPIClient.PostForm("https://oauth2.googleapis.com/token", data)
 	if err != nil {
 		return "", fmt.Errorf("refresh request failed: %w", err)
 	}
@@ -191,7 +191,8 @@ func performGDriveBackup(userID int64, accessToken, refreshToken string) error {
 
 	// Create a copy of the database
 	tmpPath := DBPath + ".gdrive-backup"
-	if err := copyFile(DBPath, tmpPath); err != nil {
+	os.Remove(tmpPath)
+	if err := database.BackupTo(tmpPath); err != nil {
 		return fmt.Errorf("failed to copy database: %w", err)
 	}
 	defer os.Remove(tmpPath)
@@ -226,7 +227,7 @@ func ensureGDriveFolder(userID int64, accessToken string) (string, error) {
 		checkURL := fmt.Sprintf("https://www.googleapis.com/drive/v3/files/%s?fields=id,trashed", cachedID)
 		req, _ := http.NewRequest("GET", checkURL, nil)
 		req.Header.Set("Authorization", "Bearer "+accessToken)
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := cloudAPIClient.Do(req)
 		if err == nil {
 			defer resp.Body.Close()
 			if resp.StatusCode == 200 {
@@ -250,7 +251,7 @@ func ensureGDriveFolder(userID int64, accessToken string) (string, error) {
 	req, _ := http.NewRequest("GET", searchURL, nil)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := cloudAPIClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -281,7 +282,7 @@ func ensureGDriveFolder(userID int64, accessToken string) (string, error) {
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp2, err := http.DefaultClient.Do(req)
+	resp2, err := cloudAPIClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -370,22 +371,24 @@ func uploadToGDrive(accessToken, filePath, folderID, filename string) error {
 	return nil
 }
 
-// checkAndRunGDriveBackups is called by the scheduler to run Google Drive backups
-func checkAndRunGDriveBackups() {
+// checkAndRunGDriveBackups is checkAndRunBackups for Google Drive
+func checkAndRunGDriveBackups() (ran, failed int, err error) {
 	users, err := database.GetAllUsersWithGDrive()
 	if err != nil {
-		log.Printf("GDrive backup scheduler: failed to get users: %v", err)
-		return
+		return 0, 0, fmt.Errorf("failed to get Google Drive users: %w", err)
 	}
 
 	for _, u := range users {
 		if isGDriveBackupDue(u) {
 			log.Printf("Google Drive backup due for user %d, starting...", u.UserID)
+			ran++
 			if err := performGDriveBackup(u.UserID, u.AccessToken, u.RefreshToken); err != nil {
 				log.Printf("Scheduled Google Drive backup failed for user %d: %v", u.UserID, err)
+				failed++
 			}
 		}
 	}
+	return ran, failed, nil
 }
 
 func isGDriveBackupDue(u database.UserGDriveBackupInfo) bool {
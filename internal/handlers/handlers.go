@@ -2,24 +2,28 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"infokeep/internal/buildinfo"
 	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/extensions"
+	"infokeep/internal/jobs"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/go-chi/chi/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -61,18 +65,37 @@ func parseTags(tagsStr string) []string {
 	return tags
 }
 
-func RenderTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
-	tmplPath := filepath.Join("web", "templates", tmpl)
-	layoutPath := filepath.Join("web", "templates", "layout.html")
-
-	files := []string{layoutPath, tmplPath}
-	fragments, _ := filepath.Glob(filepath.Join("web", "templates", "fragments", "*.html"))
-	files = append(files, fragments...)
-
-	t, err := template.New(filepath.Base(layoutPath)).Funcs(template.FuncMap{
-		"getTagColor": getTagColor,
-	}).ParseFiles(files...)
+// templateFuncs are the functions available to all page templates. base is
+// the base path for building app URLs ({{base}}/notes) and url prefixes a
+// stored path with it, leaving absolute URLs alone ({{url .file_path}}).
+// slug makes the ID path segment of a recipe or cookbook link
+// ({{base}}/recipes/{{slug .id .title}}). itemLink links to an item of any
+// type ({{itemLink .Type .ID .URL}}). noteImages lists the uploaded images a
+// note's Markdown shows, as stored paths to give to url.
+var templateFuncs = template.FuncMap{
+	"getTagColor": getTagColor,
+	"base":        func() string { return BasePath },
+	"url":         appURL,
+	"noteImages":  noteImages,
+	"slug":        itemSlug,
+	"itemLink": func(itemType string, id int64, url string) string {
+		return itemLink(BasePath, itemType, id, url)
+	},
+	"maintenance": func() bool {
+		enabled, _ := maintenanceMode()
+		return enabled
+	},
+	"announcement": func() bool {
+		_, message := currentAnnouncement()
+		return message != ""
+	},
+	"summarize": summarizeEnabled,
+	"megabytes": func(n int64) string { return fmt.Sprintf("%.1f MB", float64(n)/(1<<20)) },
+	"isAudio":   func(mimeType string) bool { return strings.HasPrefix(mimeType, "audio/") },
+}
 
+func RenderTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
+	t, err := getTemplate("layout.html", tmpl)
 	if err != nil {
 		fmt.Printf("RenderTemplate Parse Error: %v\n", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -87,17 +110,7 @@ func RenderTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
 }
 
 func RenderPublicTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
-	tmplPath := filepath.Join("web", "templates", tmpl)
-	layoutPath := filepath.Join("web", "templates", "public_layout.html")
-
-	files := []string{layoutPath, tmplPath}
-	fragments, _ := filepath.Glob(filepath.Join("web", "templates", "fragments", "*.html"))
-	files = append(files, fragments...)
-
-	t, err := template.New(filepath.Base(layoutPath)).Funcs(template.FuncMap{
-		"getTagColor": getTagColor,
-	}).ParseFiles(files...)
-
+	t, err := getTemplate("public_layout.html", tmpl)
 	if err != nil {
 		fmt.Printf("RenderPublicTemplate Parse Error: %v\n", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -112,10 +125,7 @@ func RenderPublicTemplate(w http.ResponseWriter, tmpl string, data interface{})
 }
 
 func RenderFragment(w http.ResponseWriter, tmpl string, data interface{}) {
-	tmplPath := filepath.Join("web", "templates", "fragments", tmpl)
-	t, err := template.New(filepath.Base(tmplPath)).Funcs(template.FuncMap{
-		"getTagColor": getTagColor,
-	}).ParseFiles(tmplPath)
+	t, err := getTemplate("", tmpl)
 	if err != nil {
 		fmt.Printf("RenderFragment Parse Error: %v\n", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -132,15 +142,24 @@ func RenderFragment(w http.ResponseWriter, tmpl string, data interface{}) {
 func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	tagFilter := r.URL.Query().Get("tag")
-	bookmarks, _ := database.GetBookmarks(userID, tagFilter)
-	notes, _ := database.GetNotes(userID, tagFilter)
-	drawings, _ := database.GetDrawings(userID, tagFilter)
-	ratedLists, _ := database.GetRatedLists(userID, tagFilter)
-	checklists, _ := database.GetLists(userID, tagFilter)
-	media, _ := database.GetMedia(userID, tagFilter)
-	recipes, _ := database.GetRecipes(userID, tagFilter)
+	// Archived items stay off the dashboard
+	all := database.Page{}
+	bookmarks, _, _ := database.GetBookmarksPage(userID, tagFilter, database.AllBookmarks, database.NotArchived, all)
+	notes, _, _ := database.GetNotesPage(userID, tagFilter, database.NotArchived, all)
+	drawings, _, _ := database.GetDrawingsPage(userID, tagFilter, database.NotArchived, all)
+	ratedLists, _, _ := database.GetRatedListsPage(userID, tagFilter, database.NotArchived, all)
+	checklists, _, _ := database.GetListsPage(userID, tagFilter, database.NotArchived, all)
+	media, _, _ := database.GetMediaPage(userID, tagFilter, "", database.NotArchived, all)
+	recipes, _, _ := database.GetRecipesPage(userID, tagFilter, database.NotArchived, all)
 	tags, _ := database.GetTagsWithCounts(userID)
 	pinned, _ := database.GetPinnedItems(userID)
+	recent, _ := database.GetRecentItems(userID, dashboardRecentItems)
+	viewed, _ := database.GetRecentViews(userID, dashboardRecentViews)
+	next, _ := upcoming(userID, time.Now())
+	stats, err := userStats(userID)
+	if err != nil {
+		log.Printf("Failed to compute stats for user %d: %v", userID, err)
+	}
 
 	data := map[string]interface{}{
 		"Bookmarks":  bookmarks,
@@ -153,17 +172,33 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 		"Tags":       tags,
 		"ActiveTag":  tagFilter,
 		"Pinned":     pinned,
+		"Recent":     recent,
+		"Viewed":     viewed,
+		"Upcoming":   next,
+		"Stats":      stats,
+	}
+	if stats != nil {
+		data["WeekBars"] = weekBars(stats.AddedPerWeek)
+		if stats.Reading != nil {
+			data["ReadingBars"] = readingBars(stats.Reading.Weeks)
+		}
 	}
 	RenderTemplate(w, "index.html", data)
 }
 
 func TogglePinHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	itemIDStr := chi.URLParam(r, "id")
-	var itemID int64
-	fmt.Sscanf(itemIDStr, "%d", &itemID)
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 
 	pinned, err := database.TogglePinItem(itemID, userID)
+	if err == sql.ErrNoRows {
+		// Pins apply to items and reminders, so this can't use requireOwnership
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to toggle pin", http.StatusInternalServerError)
 		return
@@ -172,7 +207,6 @@ func TogglePinHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]bool{"pinned": pinned})
 }
 
-
 func IndexHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 
@@ -190,88 +224,25 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 		"settings":    "/settings",
 	}
 	if route, ok := pageRoutes[defaultPage]; ok {
-		http.Redirect(w, r, route, http.StatusFound)
+		redirectTo(w, r, route, http.StatusFound)
 		return
 	}
 	// Default or "dashboard" — render the dashboard directly
 	DashboardHandler(w, r)
 }
 
-
-func fetchThumbnail(targetURL string) string {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	req, err := http.NewRequest("GET", targetURL, nil)
-	if err != nil {
-		fmt.Printf("Error creating request for %s: %v\n", targetURL, err)
-		return ""
-	}
-
-	// Add a common User-Agent to avoid being blocked by anti-bot protections
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("Error fetching thumbnail for %s: %v\n", targetURL, err)
-		return ""
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Thumbnail fetch returned status %d for %s\n", resp.StatusCode, targetURL)
-		return ""
-	}
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*100)) // Limit to 100KB
-	if err != nil {
-		return ""
-	}
-
-	htmlBody := string(body)
-
-	// More robust regex for og:image
-	ogImageRegex := regexp.MustCompile(`(?i)<meta\s+[^>]*?(?:property|name)=["']og:image["']\s+[^>]*?content=["']([^"']+)["']|<meta\s+[^>]*?content=["']([^"']+)["']\s+[^>]*?(?:property|name)=["']og:image["']`)
-	matches := ogImageRegex.FindStringSubmatch(htmlBody)
-	if len(matches) > 1 {
-		if matches[1] != "" {
-			return matches[1]
-		}
-		if len(matches) > 2 && matches[2] != "" {
-			return matches[2]
-		}
-	}
-
-	// Fallback to twitter:image
-	twitterImageRegex := regexp.MustCompile(`(?i)<meta\s+[^>]*?(?:property|name)=["']twitter:image["']\s+[^>]*?content=["']([^"']+)["']|<meta\s+[^>]*?content=["']([^"']+)["']\s+[^>]*?(?:property|name)=["']twitter:image["']`)
-	matches = twitterImageRegex.FindStringSubmatch(htmlBody)
-	if len(matches) > 1 {
-		if matches[1] != "" {
-			return matches[1]
-		}
-		if len(matches) > 2 && matches[2] != "" {
-			return matches[2]
-		}
-	}
-
-	return ""
-}
-
-func getFaviconURL(targetURL string) string {
-	u, err := url.Parse(targetURL)
-	if err != nil {
-		return ""
-	}
-	return fmt.Sprintf("%s://%s/favicon.ico", u.Scheme, u.Host)
-}
-
 func BookmarkHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	if r.Method == http.MethodPost {
-		title := r.FormValue("title")
-		targetURL := r.FormValue("url")
-		description := r.FormValue("description")
+		var v validation.Validator
+		title := v.MaxLength("title", r.FormValue("title"), maxTitleLength)
+		targetURL := v.URL("url", v.Required("url", r.FormValue("url"), 0))
+		description := v.MaxLength("description", r.FormValue("description"), maxLongText)
+		notes := v.MaxLength("notes", r.FormValue("notes"), maxLongText)
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
 		tags := strings.Split(r.FormValue("tags"), ",")
 
 		// Clean tags
@@ -283,53 +254,66 @@ func BookmarkHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		// Try to fetch favicon
-		favicon := ""
-		if targetURL != "" {
-			favicon = getFaviconURL(targetURL)
+		// The page is read in the background (see bookmark_metadata.go), so
+		// only a bookmark of the same address is found here; the favicon is
+		// served by FaviconHandler
+		canonicalURL := normalizeBookmarkURL(targetURL)
+		if _, existing, err := database.FindBookmarkByURL(userID, canonicalURL); err == nil {
+			v.Add("url", fmt.Sprintf("is already bookmarked as %q", existing))
+			writeValidationErrors(w, v.Errors())
+			return
 		}
+		title, titleToReplace := newBookmarkTitle(title, canonicalURL)
 
-		// Try to fetch thumbnail
-		thumbnail := fetchThumbnail(targetURL)
-
-		itemID, err := database.CreateBookmark(userID, title, targetURL, description, favicon, thumbnail)
+		itemID, err := database.CreateBookmark(userID, title, targetURL, canonicalURL, description, "", "")
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		database.SetBookmarkNotes(itemID, notes)
 
 		if len(cleanTags) > 0 {
 			database.SetItemTags(itemID, cleanTags)
 		}
+		queueBookmarkMetadata(userID, bookmarkMetadataPayload{
+			BookmarkID:      itemID,
+			URL:             targetURL,
+			TitleToReplace:  titleToReplace,
+			FillDescription: strings.TrimSpace(description) == "",
+			Archive:         r.FormValue("archive") == "true",
+		})
+		itemCreated(r.Context(), userID, itemID, "bookmark", title)
 
 		// Return fragment if HTMX
 		if r.Header.Get("HX-Request") != "" {
-			bookmarks, _ := database.GetBookmarks(userID, "")
-			RenderFragment(w, "bookmark_list.html", bookmarks)
+			renderListPage(w, r, "bookmarks", userID, "")
 			return
 		}
 	}
 
 	tagFilter := r.URL.Query().Get("tag")
-	bookmarks, _ := database.GetBookmarks(userID, tagFilter)
-
 	if r.Header.Get("HX-Request") != "" && r.Method == http.MethodGet {
-		RenderFragment(w, "bookmark_list.html", bookmarks)
+		renderListPage(w, r, "bookmarks", userID, tagFilter)
 		return
 	}
 
 	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
 	data := map[string]interface{}{
-		"Bookmarks": bookmarks,
-		"Tags":      tagsWithCounts,
-		"ActiveTag": tagFilter,
+		"Tags":       tagsWithCounts,
+		"ActiveTag":  tagFilter,
+		"Archived":   showArchived(r),
+		"SortMenu":   sortMenu(r, userID, "bookmarks"),
+		"ActiveKind": r.URL.Query().Get("kind"),
 	}
 	RenderTemplate(w, "bookmarks.html", data)
 }
 
 func GetBookmarkHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 	bookmark, err := database.GetBookmark(userID, id)
 	if err != nil {
 		http.Error(w, "Bookmark not found", http.StatusNotFound)
@@ -341,22 +325,40 @@ func GetBookmarkHandler(w http.ResponseWriter, r *http.Request) {
 
 func UpdateBookmarkHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
-	title := r.FormValue("title")
-	url := r.FormValue("url")
-	description := r.FormValue("description")
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !requireOwnership(w, id, userID) {
+		return
+	}
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
+	url := v.URL("url", v.Required("url", r.FormValue("url"), 0))
+	description := v.MaxLength("description", r.FormValue("description"), maxLongText)
+	notes := v.MaxLength("notes", r.FormValue("notes"), maxLongText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
 	tags := strings.Split(r.FormValue("tags"), ",")
 
-	if title == "" || url == "" {
-		http.Error(w, "Title and URL are required", http.StatusBadRequest)
-		return
+	// Only a changed URL is read again, in the background
+	canonicalURL, moved := "", true
+	if old, err := database.GetBookmark(userID, id); err == nil && old.URL == url {
+		canonicalURL, moved = old.CanonicalURL, false
+	} else {
+		canonicalURL = normalizeBookmarkURL(url)
 	}
 
-	err := database.UpdateBookmark(userID, id, title, url, description)
+	err := database.UpdateBookmark(userID, id, title, url, canonicalURL, description, notes)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if moved {
+		queueBookmarkMetadata(userID, bookmarkMetadataPayload{BookmarkID: id, URL: url})
+	}
 
 	var cleanTags []string
 	for _, t := range tags {
@@ -366,22 +368,27 @@ func UpdateBookmarkHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	database.SetItemTags(id, cleanTags)
+	itemUpdated(r.Context(), userID, id, "bookmark", title)
 
 	// Return fragment if HTMX
 	if r.Header.Get("HX-Request") != "" {
-		bookmarks, _ := database.GetBookmarks(userID, "")
-		RenderFragment(w, "bookmark_list.html", bookmarks)
+		renderListPage(w, r, "bookmarks", userID, "")
 		return
 	}
 
-	http.Redirect(w, r, "/bookmarks", http.StatusFound)
+	redirectTo(w, r, "/bookmarks", http.StatusFound)
 }
 
 func NoteHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	if r.Method == http.MethodPost {
-		title := r.FormValue("title")
-		content := r.FormValue("content")
+		var v validation.Validator
+		title := v.Required("title", r.FormValue("title"), maxTitleLength)
+		content := v.MaxLength("content", r.FormValue("content"), maxLongText)
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
 
 		tags := strings.Split(r.FormValue("tags"), ",")
 
@@ -401,53 +408,63 @@ func NoteHandler(w http.ResponseWriter, r *http.Request) {
 		if len(cleanTags) > 0 {
 			database.SetItemTags(itemID, cleanTags)
 		}
+		claimNoteUploads(userID, itemID, content)
+		itemCreated(r.Context(), userID, itemID, "note", title)
 
 		if r.Header.Get("HX-Request") != "" {
-			notes, _ := database.GetNotes(userID, "")
-			RenderFragment(w, "note_list.html", notes)
+			renderListPage(w, r, "notes", userID, "")
 			return
 		}
 	}
 
 	tagFilter := r.URL.Query().Get("tag")
-	notes, _ := database.GetNotes(userID, tagFilter)
-
 	if r.Header.Get("HX-Request") != "" && r.Method == http.MethodGet {
-		RenderFragment(w, "note_list.html", notes)
+		renderListPage(w, r, "notes", userID, tagFilter)
 		return
 	}
 
 	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
 	data := map[string]interface{}{
-		"Notes":     notes,
 		"Tags":      tagsWithCounts,
 		"ActiveTag": tagFilter,
+		"Archived":  showArchived(r),
+		"SortMenu":  sortMenu(r, userID, "notes"),
 	}
 	RenderTemplate(w, "notes.html", data)
 }
 
 func GetNoteHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 	note, err := database.GetNote(userID, id)
 	if err != nil {
 		http.Error(w, "Note not found", http.StatusNotFound)
 		return
 	}
+	recordView(userID, id)
 	json.NewEncoder(w).Encode(note)
 }
 
 func UpdateNoteHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
-	title := r.FormValue("title")
-	content := r.FormValue("content")
-	tags := strings.Split(r.FormValue("tags"), ",")
-
-	if title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !requireOwnership(w, id, userID) {
 		return
 	}
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
+	content := v.MaxLength("content", r.FormValue("content"), maxLongText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	tags := strings.Split(r.FormValue("tags"), ",")
 
 	err := database.UpdateNote(userID, id, title, content)
 	if err != nil {
@@ -463,23 +480,26 @@ func UpdateNoteHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	database.SetItemTags(id, cleanTags)
+	claimNoteUploads(userID, id, content)
+	itemUpdated(r.Context(), userID, id, "note", title)
 
 	if r.Header.Get("HX-Request") != "" {
-		notes, _ := database.GetNotes(userID, "")
-		data := map[string]interface{}{
-			"Notes": notes,
-		}
-		RenderFragment(w, "note_list.html", data)
+		renderListPage(w, r, "notes", userID, "")
 		return
 	}
 
-	http.Redirect(w, r, "/notes", http.StatusFound)
+	redirectTo(w, r, "/notes", http.StatusFound)
 }
 
 func RatedListHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	if r.Method == http.MethodPost {
-		title := r.FormValue("title")
+		var v validation.Validator
+		title := v.Required("title", r.FormValue("title"), maxTitleLength)
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
 		tags := parseTags(r.FormValue("tags"))
 
 		itemID, err := database.CreateRatedList(userID, title)
@@ -491,20 +511,18 @@ func RatedListHandler(w http.ResponseWriter, r *http.Request) {
 		if len(tags) > 0 {
 			database.SetItemTags(itemID, tags)
 		}
+		itemCreated(r.Context(), userID, itemID, "rated_list", title)
 
 		// Return fragment if HTMX
 		if r.Header.Get("HX-Request") != "" {
-			lists, _ := database.GetRatedLists(userID, "")
-			RenderFragment(w, "rated_list_nav.html", lists)
+			renderListPage(w, r, "rated-lists", userID, "")
 			return
 		}
 	}
 
 	tagFilter := r.URL.Query().Get("tag")
-	lists, _ := database.GetRatedLists(userID, tagFilter)
-
 	if r.Header.Get("HX-Request") != "" && r.Method == http.MethodGet {
-		RenderFragment(w, "rated_list_nav.html", lists)
+		renderListPage(w, r, "rated-lists", userID, tagFilter)
 		return
 	}
 
@@ -512,26 +530,34 @@ func RatedListHandler(w http.ResponseWriter, r *http.Request) {
 
 	activeID := r.URL.Query().Get("id")
 	data := map[string]interface{}{
-		"Lists":     lists,
 		"ActiveID":  activeID,
 		"Tags":      tagsWithCounts,
 		"ActiveTag": tagFilter,
+		"Archived":  showArchived(r),
+		"SortMenu":  sortMenu(r, userID, "rated-lists"),
 	}
 	RenderTemplate(w, "rated_lists.html", data)
 }
 
 func RatedListItemHandler(w http.ResponseWriter, r *http.Request) {
-	listIDStr := chi.URLParam(r, "id")
-	var listID int64
-	fmt.Sscanf(listIDStr, "%d", &listID)
+	listID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !requireOwnership(w, listID, getUserID(r)) {
+		return
+	}
 
 	if r.Method == http.MethodPost {
 		r.ParseMultipartForm(10 << 20) // 10MB max
-		title := r.FormValue("title")
-		scoreStr := r.FormValue("score")
-		var score int
-		fmt.Sscanf(scoreStr, "%d", &score)
-		note := r.FormValue("note")
+		var v validation.Validator
+		title := v.Required("title", r.FormValue("title"), maxTitleLength)
+		score := v.Int("score", r.FormValue("score"), minScore, maxScore)
+		note := v.MaxLength("note", r.FormValue("note"), maxShortText)
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
 
 		itemID, err := database.AddRatedListItem(listID, title, score, note)
 		if err != nil {
@@ -551,14 +577,20 @@ func RatedListItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordView(getUserID(r), listID)
 	items, _ := database.GetRatedListItems(listID)
 	RenderFragment(w, "rated_list_items.html", items)
 }
 
 func GetRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	var id int64
-	fmt.Sscanf(idStr, "%d", &id)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := requireRatedListItemOwnership(w, id, getUserID(r)); !ok {
+		return
+	}
 
 	item, err := database.GetRatedListItem(id)
 	if err != nil {
@@ -571,16 +603,24 @@ func GetRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func UpdateRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	var id int64
-	fmt.Sscanf(idStr, "%d", &id)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	listID, ok := requireRatedListItemOwnership(w, id, getUserID(r))
+	if !ok {
+		return
+	}
 
 	r.ParseMultipartForm(10 << 20) // 10MB max
-	title := r.FormValue("title")
-	scoreStr := r.FormValue("score")
-	var score int
-	fmt.Sscanf(scoreStr, "%d", &score)
-	note := r.FormValue("note")
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
+	score := v.Int("score", r.FormValue("score"), minScore, maxScore)
+	note := v.MaxLength("note", r.FormValue("note"), maxShortText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
 
 	err := database.UpdateRatedListItem(id, title, score, note)
 	if err != nil {
@@ -600,10 +640,6 @@ func UpdateRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
 		saveRatedItemImage(id, file, header)
 	}
 
-	listIDStr := r.FormValue("list_id")
-	var listID int64
-	fmt.Sscanf(listIDStr, "%d", &listID)
-
 	items, _ := database.GetRatedListItems(listID)
 	RenderFragment(w, "rated_list_items.html", items)
 }
@@ -611,7 +647,12 @@ func UpdateRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
 func ListHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	if r.Method == http.MethodPost {
-		title := r.FormValue("title")
+		var v validation.Validator
+		title := v.Required("title", r.FormValue("title"), maxTitleLength)
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
 		tags := parseTags(r.FormValue("tags"))
 		itemID, err := database.CreateList(userID, title)
 		if err != nil {
@@ -621,19 +662,17 @@ func ListHandler(w http.ResponseWriter, r *http.Request) {
 		if len(tags) > 0 {
 			database.SetItemTags(itemID, tags)
 		}
+		itemCreated(r.Context(), userID, itemID, "list", title)
 
 		if r.Header.Get("HX-Request") != "" {
-			lists, _ := database.GetLists(userID, "")
-			RenderFragment(w, "list_nav.html", lists)
+			renderListPage(w, r, "lists", userID, "")
 			return
 		}
 	}
 
 	tagFilter := r.URL.Query().Get("tag")
-	lists, _ := database.GetLists(userID, tagFilter)
-
 	if r.Header.Get("HX-Request") != "" && r.Method == http.MethodGet {
-		RenderFragment(w, "list_nav.html", lists)
+		renderListPage(w, r, "lists", userID, tagFilter)
 		return
 	}
 
@@ -641,36 +680,60 @@ func ListHandler(w http.ResponseWriter, r *http.Request) {
 
 	activeID := r.URL.Query().Get("id")
 	data := map[string]interface{}{
-		"Lists":     lists,
 		"ActiveID":  activeID,
 		"Tags":      tagsWithCounts,
 		"ActiveTag": tagFilter,
+		"Archived":  showArchived(r),
+		"SortMenu":  sortMenu(r, userID, "lists"),
 	}
 	RenderTemplate(w, "lists.html", data)
 }
 
 func ListItemHandler(w http.ResponseWriter, r *http.Request) {
-	listIDStr := chi.URLParam(r, "id")
-	var listID int64
-	fmt.Sscanf(listIDStr, "%d", &listID)
+	listID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !requireOwnership(w, listID, getUserID(r)) {
+		return
+	}
 
 	if r.Method == http.MethodPost {
-		content := r.FormValue("content")
-		err := database.AddListItem(listID, content)
+		var v validation.Validator
+		content := v.Required("content", r.FormValue("content"), maxShortText)
+		amount := formListItemAmount(&v, r)
+		due := v.Date("due_date", r.FormValue("due_date"))
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
+		itemID, err := database.AddListItem(listID, content)
+		if err == nil {
+			err = amount.save(itemID)
+		}
+		if err == nil && due != "" {
+			err = database.SetListItemDueDate(itemID, due)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+	} else {
+		recordView(getUserID(r), listID)
 	}
 
-	items, _ := database.GetListItems(listID)
-	RenderFragment(w, "list_items.html", items)
+	renderListItems(w, listID, false)
 }
 
 func GetListItemByIdHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	var id int64
-	fmt.Sscanf(idStr, "%d", &id)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := requireListItemOwnership(w, id, getUserID(r)); !ok {
+		return
+	}
 
 	item, err := database.GetListItemById(id)
 	if err != nil {
@@ -683,35 +746,54 @@ func GetListItemByIdHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func UpdateListItemHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	var id int64
-	fmt.Sscanf(idStr, "%d", &id)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 
-	content := r.FormValue("content")
+	listID, ok := requireListItemOwnership(w, id, getUserID(r))
+	if !ok {
+		return
+	}
+
+	var v validation.Validator
+	content := v.Required("content", r.FormValue("content"), maxShortText)
+	amount := formListItemAmount(&v, r)
+	due := v.Date("due_date", r.FormValue("due_date"))
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
 
 	err := database.UpdateListItem(id, content)
+	if err == nil {
+		err = amount.save(id)
+	}
+	if err == nil {
+		err = database.SetListItemDueDate(id, due)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	listIDStr := r.FormValue("list_id")
-	var listID int64
-	fmt.Sscanf(listIDStr, "%d", &listID)
-
-	items, _ := database.GetListItems(listID)
-	RenderFragment(w, "list_items.html", items)
+	renderListItems(w, listID, false)
 }
 
 func ToggleListItemHandler(w http.ResponseWriter, r *http.Request) {
-	itemIDStr := chi.URLParam(r, "itemID")
-	var itemID int64
-	fmt.Sscanf(itemIDStr, "%d", &itemID)
+	itemID, ok := pathID(w, r, "itemID")
+	if !ok {
+		return
+	}
+	listID, ok := requireListItemOwnership(w, itemID, getUserID(r))
+	if !ok {
+		return
+	}
 
 	completed := r.FormValue("completed") == "true"
 	database.ToggleListItem(itemID, completed)
 
-	w.WriteHeader(http.StatusOK)
+	renderListItems(w, listID, true)
 }
 
 func MediaHandler(w http.ResponseWriter, r *http.Request) {
@@ -724,14 +806,19 @@ func MediaHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		defer file.Close()
 
-		title := r.FormValue("title")
+		var v validation.Validator
+		title := v.MaxLength("title", strings.TrimSpace(r.FormValue("title")), maxTitleLength)
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
 		if title == "" {
 			title = header.Filename
 		}
 
 		ext := filepath.Ext(header.Filename)
 		fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-		savePath := filepath.Join("web", "static", "uploads", fileName)
+		savePath := filepath.Join(datadir.Uploads(), fileName)
 
 		out, err := os.Create(savePath)
 		if err != nil {
@@ -756,37 +843,40 @@ func MediaHandler(w http.ResponseWriter, r *http.Request) {
 		if len(tags) > 0 {
 			database.SetItemTags(itemID, tags)
 		}
+		media := database.MediaFile{ID: itemID, UserID: userID, Title: title, FilePath: relPath}
+		if err := fingerprintMediaItem(media); err != nil {
+			log.Printf("Media fingerprints: item %d: %v", itemID, err)
+		}
+		itemCreated(r.Context(), userID, itemID, "media", title)
 
 		if r.Header.Get("HX-Request") != "" {
-			media, _ := database.GetMedia(userID, "")
-			RenderFragment(w, "media_grid.html", media)
+			renderListPage(w, r, "media", userID, "")
 			return
 		}
 	}
 
 	tagFilter := r.URL.Query().Get("tag")
-	media, _ := database.GetMedia(userID, tagFilter)
-
 	if r.Header.Get("HX-Request") != "" && r.Method == http.MethodGet {
-		RenderFragment(w, "media_grid.html", media)
+		renderListPage(w, r, "media", userID, tagFilter)
 		return
 	}
 
 	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
 	data := map[string]interface{}{
-		"Media":     media,
-		"Tags":      tagsWithCounts,
-		"ActiveTag": tagFilter,
+		"Tags":       tagsWithCounts,
+		"ActiveTag":  tagFilter,
+		"Archived":   showArchived(r),
+		"SortMenu":   sortMenu(r, userID, "media"),
+		"Kinds":      mediaKindFilters,
+		"ActiveKind": r.URL.Query().Get("kind"),
 	}
 	RenderTemplate(w, "media.html", data)
 }
 
 func GetMediaItemHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
 
@@ -795,6 +885,7 @@ func GetMediaItemHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	recordView(userID, id)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(item)
@@ -802,10 +893,8 @@ func GetMediaItemHandler(w http.ResponseWriter, r *http.Request) {
 
 func UpdateMediaItemHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
 
@@ -814,8 +903,17 @@ func UpdateMediaItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("title")
-	err = database.UpdateMediaItem(id, userID, title)
+	if !requireOwnership(w, id, userID) {
+		return
+	}
+
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	err := database.UpdateMediaItem(id, userID, title)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -823,35 +921,29 @@ func UpdateMediaItemHandler(w http.ResponseWriter, r *http.Request) {
 
 	tags := parseTags(r.FormValue("tags"))
 	database.SetItemTags(id, tags)
+	itemUpdated(r.Context(), userID, id, "media", title)
 
 	if r.Header.Get("HX-Request") != "" {
-		tagFilter := r.URL.Query().Get("tag")
-		media, _ := database.GetMedia(userID, tagFilter)
-		RenderFragment(w, "media_grid.html", media)
+		renderListPage(w, r, "media", userID, r.URL.Query().Get("tag"))
 		return
 	}
-	http.Redirect(w, r, "/media", http.StatusSeeOther)
+	redirectTo(w, r, "/media", http.StatusSeeOther)
 }
 
 func DrawingsHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	tagFilter := r.URL.Query().Get("tag")
-	drawings, err := database.GetDrawings(userID, tagFilter)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	if r.Header.Get("HX-Request") != "" {
-		RenderFragment(w, "drawing_list.html", drawings)
+		renderListPage(w, r, "drawings", userID, tagFilter)
 		return
 	}
 
 	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
 	data := map[string]interface{}{
-		"Drawings":  drawings,
 		"Tags":      tagsWithCounts,
 		"ActiveTag": tagFilter,
+		"Archived":  showArchived(r),
+		"SortMenu":  sortMenu(r, userID, "drawings"),
 	}
 	RenderTemplate(w, "drawings.html", data)
 }
@@ -865,11 +957,14 @@ func CreateDrawingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("title")
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
 	imageData := r.FormValue("image") // Base64 data URL
-
-	if title == "" || imageData == "" {
-		http.Error(w, "Title and image data are required", http.StatusBadRequest)
+	if imageData == "" {
+		v.Add("image", "is required")
+	}
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
 		return
 	}
 
@@ -883,7 +978,7 @@ func CreateDrawingHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Save to uploads folder
 	filename := fmt.Sprintf("drawing_%d.png", time.Now().UnixNano())
-	savePath := filepath.Join("web", "static", "uploads", filename)
+	savePath := filepath.Join(datadir.Uploads(), filename)
 
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
@@ -910,6 +1005,7 @@ func CreateDrawingHandler(w http.ResponseWriter, r *http.Request) {
 	if len(tags) > 0 {
 		database.SetItemTags(itemID, tags)
 	}
+	itemCreated(r.Context(), userID, itemID, "drawing", title)
 
 	// Redirect or return success
 	w.Header().Set("HX-Trigger", "newDrawing")
@@ -918,19 +1014,27 @@ func CreateDrawingHandler(w http.ResponseWriter, r *http.Request) {
 
 func GetDrawingHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 	drawing, err := database.GetDrawing(userID, id)
 	if err != nil {
 		http.Error(w, "Drawing not found", http.StatusNotFound)
 		return
 	}
+	recordView(userID, id)
 	json.NewEncoder(w).Encode(drawing)
 }
 
 func UpdateDrawingHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	var id int64
-	fmt.Sscanf(idStr, "%d", &id)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !requireOwnership(w, id, getUserID(r)) {
+		return
+	}
 
 	err := r.ParseForm()
 	if err != nil {
@@ -938,11 +1042,11 @@ func UpdateDrawingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("title")
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
 	imageData := r.FormValue("image") // Base64 data URL
-
-	if title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
 		return
 	}
 
@@ -957,7 +1061,7 @@ func UpdateDrawingHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		filename := fmt.Sprintf("drawing_%d.png", time.Now().UnixNano())
-		savePath := filepath.Join("web", "static", "uploads", filename)
+		savePath := filepath.Join(datadir.Uploads(), filename)
 
 		if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
 			http.Error(w, "Failed to create directory", http.StatusInternalServerError)
@@ -979,6 +1083,7 @@ func UpdateDrawingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	database.SetItemTags(id, tags)
+	itemUpdated(r.Context(), getUserID(r), id, "drawing", title)
 
 	w.Header().Set("HX-Trigger", "newDrawing")
 	DrawingsHandler(w, r)
@@ -987,6 +1092,7 @@ func UpdateDrawingHandler(w http.ResponseWriter, r *http.Request) {
 func SettingsHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	token, _ := database.GetAPIToken(userID)
+	feedToken, _ := database.GetFeedToken(userID)
 
 	// pCloud status
 	pcloudToken, _, _ := database.GetPCloudCredentials(userID)
@@ -996,16 +1102,55 @@ func SettingsHandler(w http.ResponseWriter, r *http.Request) {
 	_, gdriveRefresh, _ := database.GetGDriveCredentials(userID)
 
 	defaultPage := database.GetDefaultPage(userID)
+	knownDevices, _ := database.GetKnownDevices(userID)
+	sharedLinks, _ := database.GetSharedLinksByUser(userID)
+	recentImports, _ := database.GetRecentJobBatches(userID, recipeImportJob, 5)
 
+	maintenanceOn, maintenanceMsg := maintenanceMode()
+	_, announcementMsg := currentAnnouncement()
+	latest, latestURL := latestRelease()
+	var autoBackup map[string]interface{}
+	if isAdmin(userID) {
+		autoBackup = autoBackupStatus()
+	}
 	RenderTemplate(w, "settings.html", map[string]interface{}{
-		"APIToken":       token,
-		"PCloudLinked":   pcloudToken != "",
-		"BackupInterval": backupInterval,
-		"LastBackup":     lastBackup,
-		"PCloudMsg":      r.URL.Query().Get("pcloud"),
-		"GDriveLinked":   gdriveRefresh != "",
-		"GDriveMsg":      r.URL.Query().Get("gdrive"),
-		"DefaultPage":    defaultPage,
+		"APIToken":        token,
+		"PCloudLinked":    pcloudToken != "",
+		"BackupInterval":  backupInterval,
+		"LastBackup":      lastBackup,
+		"PCloudMsg":       r.URL.Query().Get("pcloud"),
+		"GDriveLinked":    gdriveRefresh != "",
+		"GDriveMsg":       r.URL.Query().Get("gdrive"),
+		"DefaultPage":     defaultPage,
+		"MediaKindTags":   database.GetUserSetting(userID, mediaKindTagsSetting) == "on",
+		"Features":        featureSettings(userID),
+		"KnownDevices":    knownDevices,
+		"SharedLinks":     sharedLinks,
+		"LoginAlertEmail": database.GetLoginAlertEmail(userID),
+		"Digest":          getDigestSettings(userID),
+		"Preferences":     getPreferences(userID),
+		"SortOptions":     sortOptions,
+		"PerPageOptions":  perPageOptions,
+		"MailConfigured":  mailConfigured(),
+		"PasswordPolicy":  passwordPolicy,
+		"RecentImports":   recentImports,
+		"TokenAllowlist":  database.GetTokenAllowlist(userID),
+		"Feeds":           feedURLs(r, feedToken),
+		"ScheduledTasks":  jobs.Statuses(),
+		"IsAdmin":         isAdmin(userID),
+		"Maintenance":     maintenanceOn,
+		"MaintenanceMsg":  maintenanceMsg,
+		"Announcement":    announcementMsg,
+		"AutoBackup":      autoBackup,
+		"Importers":       extensions.Importers(),
+		"Build": map[string]interface{}{
+			"Version":         buildinfo.Version,
+			"Commit":          buildinfo.Commit,
+			"Date":            buildinfo.Date,
+			"Latest":          latest,
+			"LatestURL":       latestURL,
+			"UpdateAvailable": buildinfo.Newer(latest, buildinfo.Version),
+		},
 	})
 }
 
@@ -1017,13 +1162,7 @@ func SetLandingPageHandler(w http.ResponseWriter, r *http.Request) {
 		r.ParseForm()
 	}
 	page := r.FormValue("page")
-	// Validate against known pages
-	validPages := map[string]bool{
-		"dashboard": true, "bookmarks": true, "notes": true, "recipes": true,
-		"media": true, "lists": true, "rated-lists": true, "drawings": true,
-		"reminders": true, "settings": true,
-	}
-	if !validPages[page] {
+	if !slices.Contains(landingPages, page) {
 		page = "dashboard"
 	}
 	if err := database.SetDefaultPage(userID, page); err != nil {
@@ -1034,12 +1173,6 @@ func SetLandingPageHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"page": page})
 }
 
-
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-}
-
 func ShareHandler(w http.ResponseWriter, r *http.Request) {
 	sharedURL := r.URL.Query().Get("url")
 	title := r.URL.Query().Get("title")
@@ -1070,17 +1203,24 @@ func RegenerateTokenHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAuthEvent(r, userID, database.AuthAPITokenGenerated)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"token": token})
 }
 
+// DeleteItemHandler moves an item to the trash
 func DeleteItemHandler(w http.ResponseWriter, r *http.Request) {
-	itemIDStr := chi.URLParam(r, "id")
-	var itemID int64
-	fmt.Sscanf(itemIDStr, "%d", &itemID)
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
 
 	userID := getUserID(r)
-	err := database.DeleteItem(userID, itemID)
+	if !requireOwnership(w, itemID, userID) {
+		return
+	}
+
+	err := database.TrashItem(userID, itemID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -1090,9 +1230,15 @@ func DeleteItemHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func DeleteListItemHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	var id int64
-	fmt.Sscanf(idStr, "%d", &id)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	listID, ok := requireListItemOwnership(w, id, getUserID(r))
+	if !ok {
+		return
+	}
 
 	err := database.DeleteListItem(id)
 	if err != nil {
@@ -1100,13 +1246,18 @@ func DeleteListItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	renderListItems(w, listID, true)
 }
 
 func DeleteRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	var id int64
-	fmt.Sscanf(idStr, "%d", &id)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := requireRatedListItemOwnership(w, id, getUserID(r)); !ok {
+		return
+	}
 
 	err := database.DeleteRatedListItem(id)
 	if err != nil {
@@ -1132,7 +1283,7 @@ func saveRatedItemImage(itemID int64, file multipart.File, header *multipart.Fil
 		}
 	}
 
-	uploadDir := "web/static/rated_items"
+	uploadDir := datadir.RatedItems()
 	os.MkdirAll(uploadDir, 0755)
 
 	filename := fmt.Sprintf("item_%d_%d%s", itemID, time.Now().UnixNano(), ext)
@@ -1159,22 +1310,19 @@ func saveRatedItemImage(itemID int64, file multipart.File, header *multipart.Fil
 func RecipeHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	tagFilter := r.URL.Query().Get("tag")
-	recipes, err := database.GetRecipes(userID, tagFilter)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	if r.Header.Get("HX-Request") != "" {
-		RenderFragment(w, "recipe_list.html", recipes)
+		renderListPage(w, r, "recipes", userID, tagFilter)
 		return
 	}
 
 	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
 	data := map[string]interface{}{
-		"Recipes":   recipes,
-		"Tags":      tagsWithCounts,
-		"ActiveTag": tagFilter,
+		"Tags":               tagsWithCounts,
+		"ActiveTag":          tagFilter,
+		"Archived":           showArchived(r),
+		"SortMenu":           sortMenu(r, userID, "recipes"),
+		"TranslationEnabled": translationEnabled(),
+		"TranslateTarget":    translateTarget,
 	}
 	RenderTemplate(w, "recipes.html", data)
 }
@@ -1186,12 +1334,17 @@ func CreateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("title")
-	ingredients := r.FormValue("ingredients")
-	instructions := r.FormValue("instructions")
-	notes := r.FormValue("notes")
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
+	ingredients := v.MaxLength("ingredients", r.FormValue("ingredients"), maxLongText)
+	instructions := v.MaxLength("instructions", r.FormValue("instructions"), maxLongText)
+	notes := v.MaxLength("notes", r.FormValue("notes"), maxLongText)
 	thumbnail := r.FormValue("thumbnail")
-	sourceURL := r.FormValue("source_url")
+	sourceURL := v.URL("source_url", r.FormValue("source_url"))
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
 	tags := parseTags(r.FormValue("tags"))
 
 	// Handle multiple image uploads
@@ -1207,7 +1360,7 @@ func CreateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		// Save file
 		ext := filepath.Ext(fileHeader.Filename)
 		fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-		savePath := filepath.Join("web", "static", "uploads", fileName)
+		savePath := filepath.Join(datadir.Uploads(), fileName)
 
 		out, err := os.Create(savePath)
 		if err != nil {
@@ -1228,20 +1381,24 @@ func CreateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Untranslated text from a translated import
+	if r.FormValue("original_ingredients") != "" || r.FormValue("original_instructions") != "" {
+		database.SetRecipeOriginal(itemID, r.FormValue("original_language"),
+			r.FormValue("original_ingredients"), r.FormValue("original_instructions"))
+	}
+
 	if len(tags) > 0 {
 		database.SetItemTags(itemID, tags)
 	}
+	itemCreated(r.Context(), userID, itemID, "recipe", title)
 
-	recipes, _ := database.GetRecipes(userID, "")
-	RenderFragment(w, "recipe_list.html", recipes)
+	renderListPage(w, r, "recipes", userID, "")
 }
 
 func GetRecipeHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
 
@@ -1254,33 +1411,37 @@ func GetRecipeHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if JSON is requested (for edit modal or API)
 	if r.Header.Get("Accept") == "application/json" || r.URL.Query().Get("json") == "true" {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(recipe)
+		json.NewEncoder(w).Encode(newRecipeJSON(recipe))
 		return
 	}
 
 	// Prepare data for HTML view
-	var ingredientsList []string
-	if ing, ok := recipe["ingredients"].(string); ok && ing != "" {
-		for _, s := range strings.Split(ing, "\n") {
-			if trimmed := strings.TrimSpace(s); trimmed != "" {
-				ingredientsList = append(ingredientsList, trimmed)
-			}
-		}
-	}
+	recordView(userID, id)
+	ingredientsList := splitLines(recipe.Ingredients)
 
-	var instructionsList []string
-	if inst, ok := recipe["instructions"].(string); ok && inst != "" {
-		for _, s := range strings.Split(inst, "\n") {
-			if trimmed := strings.TrimSpace(s); trimmed != "" {
-				instructionsList = append(instructionsList, trimmed)
-			}
+	comments, _ := database.GetApprovedComments(id)
+
+	// Cookbooks this recipe is in, and the ones it can still be added to
+	var inCookbooks, otherCookbooks []models.Cookbook
+	cookbooks, _ := database.GetCookbooks(userID, "")
+	memberOf, _ := database.GetRecipeCookbookIDs(id)
+	for _, c := range cookbooks {
+		if memberOf[c.ID] {
+			inCookbooks = append(inCookbooks, c)
+		} else if !c.Archived {
+			otherCookbooks = append(otherCookbooks, c)
 		}
 	}
 
 	data := map[string]interface{}{
-		"Recipe":           recipe,
-		"IngredientsList":  ingredientsList,
-		"InstructionsList": instructionsList,
+		"Recipe":                   recipe,
+		"IngredientsList":          ingredientsList,
+		"Steps":                    recipeSteps(recipe.Instructions),
+		"OriginalIngredientsList":  splitLines(recipe.OriginalIngredients),
+		"OriginalInstructionsList": splitLines(recipe.OriginalInstructions),
+		"Comments":                 comments,
+		"InCookbooks":              inCookbooks,
+		"OtherCookbooks":           otherCookbooks,
 	}
 
 	RenderTemplate(w, "recipe_detail_page.html", data)
@@ -1288,19 +1449,30 @@ func GetRecipeHandler(w http.ResponseWriter, r *http.Request) {
 
 func UpdateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !requireOwnership(w, id, userID) {
+		return
+	}
 
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	title := r.FormValue("title")
-	ingredients := r.FormValue("ingredients")
-	instructions := r.FormValue("instructions")
-	notes := r.FormValue("notes")
+	var v validation.Validator
+	title := v.Required("title", r.FormValue("title"), maxTitleLength)
+	ingredients := v.MaxLength("ingredients", r.FormValue("ingredients"), maxLongText)
+	instructions := v.MaxLength("instructions", r.FormValue("instructions"), maxLongText)
+	notes := v.MaxLength("notes", r.FormValue("notes"), maxLongText)
 	thumbnail := r.FormValue("thumbnail")
-	sourceURL := r.FormValue("source_url")
+	sourceURL := v.URL("source_url", r.FormValue("source_url"))
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
 	tags := parseTags(r.FormValue("tags"))
 
 	if err := database.UpdateRecipe(userID, id, title, ingredients, instructions, notes, thumbnail, sourceURL); err != nil {
@@ -1309,9 +1481,9 @@ func UpdateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	database.SetItemTags(id, tags)
+	itemUpdated(r.Context(), userID, id, "recipe", title)
 
-	recipes, _ := database.GetRecipes(userID, "")
-	RenderFragment(w, "recipe_list.html", recipes)
+	renderListPage(w, r, "recipes", userID, "")
 }
 
 func ImportRecipeHandler(w http.ResponseWriter, r *http.Request) {
@@ -1321,12 +1493,17 @@ func ImportRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recipeData, err := ParseRecipeFromURL(url)
+	recipeData, err := ParseRecipeFromURL(r.Context(), url)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to parse recipe: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	var original *RecipeOriginal
+	if r.URL.Query().Get("translate") != "0" {
+		original = translateRecipe(r.Context(), recipeData)
+	}
+
 	// Convert ingredients array to newline-separated string
 	ingredientsStr := strings.Join(recipeData.Ingredients, "\n")
 
@@ -1337,6 +1514,9 @@ func ImportRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		"thumbnail":    recipeData.Image,
 		"source_url":   url,
 	}
+	if original != nil {
+		response["original"] = original
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -1352,13 +1532,14 @@ func ShareImportRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recipeData, err := ParseRecipeFromURL(recipeURL)
+	recipeData, err := ParseRecipeFromURL(r.Context(), recipeURL)
 	if err != nil {
 		// If parsing fails, redirect to recipes page with an error
-		http.Redirect(w, r, "/recipes", http.StatusFound)
+		redirectTo(w, r, "/recipes", http.StatusFound)
 		return
 	}
 
+	original := translateRecipe(r.Context(), recipeData)
 	ingredientsStr := strings.Join(recipeData.Ingredients, "\n")
 	tags := parseTags(r.FormValue("tags"))
 
@@ -1368,13 +1549,17 @@ func ShareImportRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to save recipe", http.StatusInternalServerError)
 		return
 	}
+	if original != nil {
+		database.SetRecipeOriginal(itemID, original.Language, original.Ingredients, original.Instructions)
+	}
 
 	if len(tags) > 0 {
 		database.SetItemTags(itemID, tags)
 	}
+	itemCreated(r.Context(), userID, itemID, "recipe", recipeData.Title)
 
 	// Redirect to the new recipe's detail page
-	http.Redirect(w, r, fmt.Sprintf("/recipes/%d", itemID), http.StatusFound)
+	redirectTo(w, r, "/recipes/"+itemSlug(itemID, recipeData.Title), http.StatusFound)
 }
 
 // GlobalSearchResult represents a unified item found across any category
@@ -1396,6 +1581,19 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 	query := strings.ToLower(r.URL.Query().Get("q"))
 	category := r.URL.Query().Get("category")
 
+	// Searches from the browser's address bar (see opensearch.go) get a page
+	if r.Header.Get("HX-Request") == "" {
+		var results []GlobalSearchResult
+		if query != "" {
+			results = performGlobalSearch(userID, query)
+		}
+		RenderTemplate(w, "search.html", map[string]interface{}{
+			"Results": results,
+			"Query":   r.URL.Query().Get("q"),
+		})
+		return
+	}
+
 	// If there's a search term, do a global unified search
 	if query != "" {
 		results := performGlobalSearch(userID, query)
@@ -1407,28 +1605,11 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If there's NO search term (user cleared the bar), fallback to rendering the raw list for the current category page
+	if _, ok := pagedLists[category]; ok {
+		renderListPage(w, r, category, userID, "")
+		return
+	}
 	switch category {
-	case "bookmarks":
-		items, _ := database.GetBookmarks(userID, "")
-		RenderFragment(w, "bookmark_list.html", items)
-	case "notes":
-		items, _ := database.GetNotes(userID, "")
-		RenderFragment(w, "note_list.html", items)
-	case "drawings":
-		items, _ := database.GetDrawings(userID, "")
-		RenderFragment(w, "drawing_list.html", items)
-	case "rated-lists":
-		items, _ := database.GetRatedLists(userID, "")
-		RenderFragment(w, "rated_list_nav.html", items)
-	case "lists":
-		items, _ := database.GetLists(userID, "")
-		RenderFragment(w, "list_nav.html", items)
-	case "media":
-		items, _ := database.GetMedia(userID, "")
-		RenderFragment(w, "media_grid.html", items)
-	case "recipes":
-		items, _ := database.GetRecipes(userID, "")
-		RenderFragment(w, "recipe_list.html", items)
 	case "dashboard":
 		// Clear dashboard search (could render empty state or partial dashboard depending on design)
 		RenderFragment(w, "search_results.html", map[string]interface{}{})
@@ -1437,35 +1618,75 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func performGlobalSearch(userID int64, query string) []GlobalSearchResult {
-	var globalResults []GlobalSearchResult
+// searchResultLimit caps the results of a global search
+const searchResultLimit = 100
 
-	// Helper to safely extract string map values
-	getString := func(m map[string]interface{}, key string) string {
-		if val, ok := m[key].(string); ok {
-			return val
-		}
-		return ""
+// searchResultTypes maps item types to the type shown in search results
+// and the page an item of the type is found on
+var searchResultTypes = map[string]struct {
+	name string
+	link func(id int64) string
+}{
+	"note":       {"Note", func(id int64) string { return fmt.Sprintf("/notes#note-%d", id) }},
+	"bookmark":   {"Bookmark", func(id int64) string { return fmt.Sprintf("/bookmarks#bookmark-%d", id) }},
+	"recipe":     {"Recipe", func(id int64) string { return fmt.Sprintf("/recipes/%d", id) }},
+	"list":       {"Checklist", func(id int64) string { return fmt.Sprintf("/lists?id=%d", id) }},
+	"rated_list": {"Rated List", func(id int64) string { return fmt.Sprintf("/rated-lists?id=%d", id) }},
+	"drawing":    {"Drawing", func(id int64) string { return fmt.Sprintf("/drawings#drawing-%d", id) }},
+	"media":      {"Media", func(id int64) string { return fmt.Sprintf("/media#media-%d", id) }},
+}
+
+// performGlobalSearch searches all of the user's items with the full-text
+// index, or item by item when SQLite lacks FTS5, and blends in semantic
+// matches when semantic search is on for the user
+func performGlobalSearch(userID int64, query string) []GlobalSearchResult {
+	var results []GlobalSearchResult
+	if database.SearchEnabled() {
+		results = indexGlobalSearch(userID, query)
+	} else {
+		results = scanGlobalSearch(userID, query)
 	}
-	getInt64 := func(m map[string]interface{}, key string) int64 {
-		if val, ok := m[key].(int64); ok {
-			return val
-		}
-		// In case row returns int or float64 via JSON mapping
-		if val, ok := m[key].(float64); ok {
-			return int64(val)
-		}
-		if val, ok := m[key].(int); ok {
-			return int64(val)
-		}
-		return 0
+	if featureEnabled(userID, "semantic_search") {
+		results = blendSemanticResults(userID, query, results)
 	}
-	getTags := func(m map[string]interface{}) []string {
-		if tags, ok := m["tags"].([]string); ok {
-			return tags
-		}
+	return results
+}
+
+// indexGlobalSearch searches the full-text index
+func indexGlobalSearch(userID int64, query string) []GlobalSearchResult {
+	found, err := database.SearchItems(userID, query, searchResultLimit)
+	if err != nil {
+		fmt.Printf("Search error: %v\n", err)
 		return nil
 	}
+	var results []GlobalSearchResult
+	for _, f := range found {
+		results = append(results, globalSearchResult(f))
+	}
+	return results
+}
+
+// globalSearchResult converts a search result of the database
+func globalSearchResult(f database.SearchResult) GlobalSearchResult {
+	t := searchResultTypes[f.Type]
+	return GlobalSearchResult{
+		ID:        f.ID,
+		Type:      t.name,
+		Title:     f.Title,
+		Snippet:   f.Snippet,
+		Thumbnail: f.Thumbnail,
+		URL:       f.URL,
+		Tags:      f.Tags,
+		CreatedAt: f.CreatedAt,
+		Link:      t.link(f.ID),
+	}
+}
+
+// scanGlobalSearch loads the user's items (archived ones aside) and scores
+// each by where query occurs in it
+func scanGlobalSearch(userID int64, query string) []GlobalSearchResult {
+	var globalResults []GlobalSearchResult
+
 	truncate := func(s string, l int) string {
 		if len(s) > l {
 			return s[:l] + "..."
@@ -1499,148 +1720,130 @@ func performGlobalSearch(userID int64, query string) []GlobalSearchResult {
 	}
 
 	// 1. Notes
-	notes, _ := database.GetNotes(userID, "")
+	notes, _, _ := database.GetNotesPage(userID, "", database.NotArchived, database.Page{})
 	for _, n := range notes {
-		title := getString(n, "title")
-		content := getString(n, "content")
-		tags := getTags(n)
-		score := scoreItem(title, content, "", tags)
+		score := scoreItem(n.Title, n.Content, "", n.Tags)
 		if score > 0 {
 			globalResults = append(globalResults, GlobalSearchResult{
-				ID:        getInt64(n, "id"),
+				ID:        n.ID,
 				Type:      "Note",
-				Title:     title,
-				Snippet:   truncate(content, 150),
-				Tags:      tags,
+				Title:     n.Title,
+				Snippet:   truncate(n.Content, 150),
+				Tags:      n.Tags,
 				Score:     score,
-				CreatedAt: getString(n, "created_at"),
-				Link:      fmt.Sprintf("/notes#note-%d", getInt64(n, "id")),
+				CreatedAt: n.CreatedAt,
+				Link:      fmt.Sprintf("/notes#note-%d", n.ID),
 			})
 		}
 	}
 
 	// 2. Bookmarks
-	bookmarks, _ := database.GetBookmarks(userID, "")
+	bookmarks, _, _ := database.GetBookmarksPage(userID, "", database.AllBookmarks, database.NotArchived, database.Page{})
+	pageTexts, _ := database.GetBookmarkTexts(userID)
 	for _, b := range bookmarks {
-		title := getString(b, "title")
-		url := getString(b, "url")
-		desc := getString(b, "description")
-		tags := getTags(b)
-		score := scoreItem(title, desc, url, tags)
+		score := scoreItem(b.Title, b.Description+" "+b.Notes+" "+pageTexts[b.ID], b.URL, b.Tags)
 		if score > 0 {
 			globalResults = append(globalResults, GlobalSearchResult{
-				ID:        getInt64(b, "id"),
+				ID:        b.ID,
 				Type:      "Bookmark",
-				Title:     title,
-				Thumbnail: getString(b, "thumbnail"), // Can also use favicon
-				URL:       url,
-				Snippet:   truncate(desc, 150),
-				Tags:      tags,
+				Title:     b.Title,
+				Thumbnail: b.Thumbnail, // Can also use favicon
+				URL:       b.URL,
+				Snippet:   truncate(b.Description, 150),
+				Tags:      b.Tags,
 				Score:     score,
-				CreatedAt: getString(b, "created_at"),
-				Link:      fmt.Sprintf("/bookmarks#bookmark-%d", getInt64(b, "id")),
+				CreatedAt: b.CreatedAt,
+				Link:      fmt.Sprintf("/bookmarks#bookmark-%d", b.ID),
 			})
 		}
 	}
 
 	// 3. Recipes
-	recipes, _ := database.GetRecipes(userID, "")
+	recipes, _, _ := database.GetRecipesPage(userID, "", database.NotArchived, database.Page{})
 	for _, r := range recipes {
-		title := getString(r, "title")
-		ing := getString(r, "ingredients")
-		inst := getString(r, "instructions")
-		tags := getTags(r)
-		score := scoreItem(title, ing+" "+inst, "", tags)
+		score := scoreItem(r.Title, r.Ingredients+" "+r.Instructions, "", r.Tags)
 		if score > 0 {
 			globalResults = append(globalResults, GlobalSearchResult{
-				ID:        getInt64(r, "id"),
+				ID:        r.ID,
 				Type:      "Recipe",
-				Title:     title,
-				Thumbnail: getString(r, "thumbnail"),
-				Snippet:   truncate(inst, 150),
-				Tags:      tags,
+				Title:     r.Title,
+				Thumbnail: r.Thumbnail,
+				Snippet:   truncate(r.Instructions, 150),
+				Tags:      r.Tags,
 				Score:     score,
-				CreatedAt: getString(r, "created_at"),
-				Link:      fmt.Sprintf("/recipes/%d", getInt64(r, "id")),
+				CreatedAt: r.CreatedAt,
+				Link:      fmt.Sprintf("/recipes/%d", r.ID),
 			})
 		}
 	}
 
 	// 4. Checklists
-	lists, _ := database.GetLists(userID, "")
+	lists, _, _ := database.GetListsPage(userID, "", database.NotArchived, database.Page{})
 	for _, l := range lists {
-		title := getString(l, "title")
-		tags := getTags(l)
 		// Assuming lists items are joined or searchable in another way, but for now just title/tags
-		score := scoreItem(title, "", "", tags)
+		score := scoreItem(l.Title, "", "", l.Tags)
 		if score > 0 {
 			globalResults = append(globalResults, GlobalSearchResult{
-				ID:        getInt64(l, "id"),
+				ID:        l.ID,
 				Type:      "Checklist",
-				Title:     title,
-				Tags:      tags,
+				Title:     l.Title,
+				Tags:      l.Tags,
 				Score:     score,
-				CreatedAt: getString(l, "created_at"),
-				Link:      fmt.Sprintf("/lists?id=%d", getInt64(l, "id")),
+				CreatedAt: l.CreatedAt,
+				Link:      fmt.Sprintf("/lists?id=%d", l.ID),
 			})
 		}
 	}
 
 	// 5. Rated Lists
-	rated, _ := database.GetRatedLists(userID, "")
+	rated, _, _ := database.GetRatedListsPage(userID, "", database.NotArchived, database.Page{})
 	for _, r := range rated {
-		title := getString(r, "title")
-		tags := getTags(r)
-		score := scoreItem(title, "", "", tags)
+		score := scoreItem(r.Title, "", "", r.Tags)
 		if score > 0 {
 			globalResults = append(globalResults, GlobalSearchResult{
-				ID:        getInt64(r, "id"),
+				ID:        r.ID,
 				Type:      "Rated List",
-				Title:     title,
-				Tags:      tags,
+				Title:     r.Title,
+				Tags:      r.Tags,
 				Score:     score,
-				CreatedAt: getString(r, "created_at"),
-				Link:      fmt.Sprintf("/rated-lists?id=%d", getInt64(r, "id")),
+				CreatedAt: r.CreatedAt,
+				Link:      fmt.Sprintf("/rated-lists?id=%d", r.ID),
 			})
 		}
 	}
 
 	// 6. Drawings
-	drawings, _ := database.GetDrawings(userID, "")
+	drawings, _, _ := database.GetDrawingsPage(userID, "", database.NotArchived, database.Page{})
 	for _, d := range drawings {
-		title := getString(d, "title")
-		tags := getTags(d)
-		score := scoreItem(title, "", "", tags)
+		score := scoreItem(d.Title, "", "", d.Tags)
 		if score > 0 {
 			globalResults = append(globalResults, GlobalSearchResult{
-				ID:        getInt64(d, "id"),
+				ID:        d.ID,
 				Type:      "Drawing",
-				Title:     title,
-				Thumbnail: getString(d, "file_path"),
-				Tags:      tags,
+				Title:     d.Title,
+				Thumbnail: d.FilePath,
+				Tags:      d.Tags,
 				Score:     score,
-				CreatedAt: getString(d, "created_at"),
-				Link:      fmt.Sprintf("/drawings#drawing-%d", getInt64(d, "id")),
+				CreatedAt: d.CreatedAt,
+				Link:      fmt.Sprintf("/drawings#drawing-%d", d.ID),
 			})
 		}
 	}
 
 	// 7. Media
-	media, _ := database.GetMedia(userID, "")
+	media, _, _ := database.GetMediaPage(userID, "", "", database.NotArchived, database.Page{})
 	for _, m := range media {
-		title := getString(m, "title")
-		tags := getTags(m)
-		score := scoreItem(title, "", "", tags)
+		score := scoreItem(m.Title, "", "", m.Tags)
 		if score > 0 {
 			globalResults = append(globalResults, GlobalSearchResult{
-				ID:        getInt64(m, "id"),
+				ID:        m.ID,
 				Type:      "Media",
-				Title:     title,
-				Thumbnail: getString(m, "file_path"),
-				Tags:      tags,
+				Title:     m.Title,
+				Thumbnail: m.FilePath,
+				Tags:      m.Tags,
 				Score:     score,
-				CreatedAt: getString(m, "created_at"),
-				Link:      fmt.Sprintf("/media#media-%d", getInt64(m, "id")),
+				CreatedAt: m.CreatedAt,
+				Link:      fmt.Sprintf("/media#media-%d", m.ID),
 			})
 		}
 	}
@@ -1657,87 +1860,9 @@ func performGlobalSearch(userID int64, query string) []GlobalSearchResult {
 	return globalResults
 }
 
-func filterAndSort(items []map[string]interface{}, query string, fields []string) []map[string]interface{} {
-	if query == "" {
-		return items
-	}
-
-	type ScoredItem struct {
-		item  map[string]interface{}
-		score int
-	}
-
-	var scored []ScoredItem
-
-	for _, item := range items {
-		score := 0
-		found := false
-
-		for _, field := range fields {
-			val, ok := item[field].(string)
-			if !ok {
-				continue
-			}
-			valLower := strings.ToLower(val)
-
-			if strings.Contains(valLower, query) {
-				found = true
-				// Higher weight for title
-				weight := 1
-				if field == "title" {
-					weight = 10
-				} else if field == "url" || field == "description" || field == "content" {
-					weight = 5
-				}
-
-				// Bonus for exact start
-				if strings.HasPrefix(valLower, query) {
-					weight *= 2
-				}
-
-				score += weight
-			}
-		}
-
-		// Search tags
-		if tags, ok := item["tags"].([]string); ok {
-			for _, tag := range tags {
-				tagLower := strings.ToLower(tag)
-				if strings.Contains(tagLower, query) {
-					found = true
-					weight := 15 // Higher weight for tag match
-					if strings.HasPrefix(tagLower, query) {
-						weight *= 2
-					}
-					score += weight
-				}
-			}
-		}
-
-		if found {
-			scored = append(scored, ScoredItem{item: item, score: score})
-		}
-	}
-
-	// Sort by score descending
-	for i := 0; i < len(scored); i++ {
-		for j := i + 1; j < len(scored); j++ {
-			if scored[j].score > scored[i].score {
-				scored[i], scored[j] = scored[j], scored[i]
-			}
-		}
-	}
-
-	var results []map[string]interface{}
-	for _, s := range scored {
-		results = append(results, s.item)
-	}
-	return results
-}
-
 func TagSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
 	query := strings.ToLower(r.URL.Query().Get("q"))
-	allTags, _ := database.GetAllUniqueTags()
+	allTags, _ := database.GetAllUniqueTags(getUserID(r))
 
 	var suggestions []string
 	if query != "" {
@@ -1750,13 +1875,13 @@ func TagSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/html")
 	for _, s := range suggestions {
-		fmt.Fprintf(w, `<option value="%s">`, s)
+		fmt.Fprintf(w, `<option value="%s">`, template.HTMLEscapeString(s))
 	}
 }
 
 func SearchSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
 	query := strings.ToLower(r.URL.Query().Get("q"))
-	allTags, _ := database.GetAllUniqueTags()
+	allTags, _ := database.GetAllUniqueTags(getUserID(r))
 
 	var suggestions []string
 	if query != "" {
@@ -1829,6 +1954,9 @@ func ApiCreateBookmarkClipperHandler(w http.ResponseWriter, r *http.Request) {
 		Description string `json:"description"`
 		Notes       string `json:"notes"`
 		Tags        string `json:"tags"`
+		ReadLater   bool   `json:"read_later"`
+		ToRead      bool   `json:"toread"`  // read_later, as Pinboard calls it
+		Archive     bool   `json:"archive"` // archive a copy of the page
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -1836,19 +1964,75 @@ func ApiCreateBookmarkClipperHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var v validation.Validator
+	input.Title = v.MaxLength("title", input.Title, maxTitleLength)
+	input.URL = v.URL("url", v.Required("url", input.URL, 0))
+	input.Description = v.MaxLength("description", input.Description, maxLongText)
+	input.Notes = v.MaxLength("notes", input.Notes, maxLongText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	page := fetchBookmarkPage(r.Context(), input.URL)
+	if existingID, _, err := database.FindBookmarkByURL(userID, page.CanonicalURL); err == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": existingID, "status": "exists"})
+		return
+	}
+	input.Title, input.Description = page.fill(input.Title, input.Description)
+
 	tags := parseTags(input.Tags)
-	itemID, err := database.CreateBookmark(userID, input.Title, input.URL, input.Description, input.Notes, "")
+	itemID, err := database.CreateBookmark(userID, input.Title, input.URL, page.CanonicalURL, input.Description, "", page.Thumbnail)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	database.SetBookmarkNotes(itemID, input.Notes)
+	database.SetBookmarkArticleText(itemID, page.ArticleText)
 
 	if len(tags) > 0 {
 		database.SetItemTags(itemID, tags)
 	}
+	if input.ReadLater || input.ToRead {
+		database.SetReadLater(userID, itemID, true)
+	}
+	if input.Archive {
+		archiveNewBookmark(r.Context(), userID, itemID, page.CanonicalURL)
+	}
+	itemCreated(r.Context(), userID, itemID, "bookmark", input.Title)
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "status": "created"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id": itemID, "status": "created", "suggested_tags": suggestTags(userID, page, tags),
+	})
+}
+
+// ApiLookupBookmarkHandler returns the user's bookmark of the page at ?url=,
+// or 404 if they haven't saved it, so the browser extension can tell a page
+// is already bookmarked. The page isn't fetched: url is matched, cleaned, to
+// the address bookmarks were saved with and to their canonical URL.
+func ApiLookupBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	var v validation.Validator
+	pageURL := v.URL("url", v.Required("url", r.URL.Query().Get("url"), 0))
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	id, _, err := database.FindBookmarkByURL(userID, normalizeBookmarkURL(pageURL))
+	if err == sql.ErrNoRows {
+		id, _, err = database.FindBookmarkByURL(userID, pageURL)
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Not saved", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBookmark(w, r, userID, id)
 }
 
 func ApiCreateNoteClipperHandler(w http.ResponseWriter, r *http.Request) {
@@ -1864,6 +2048,14 @@ func ApiCreateNoteClipperHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var v validation.Validator
+	input.Title = v.Required("title", input.Title, maxTitleLength)
+	input.Content = v.MaxLength("content", input.Content, maxLongText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
 	tags := parseTags(input.Tags)
 	itemID, err := database.CreateNote(userID, input.Title, input.Content)
 	if err != nil {
@@ -1874,6 +2066,7 @@ func ApiCreateNoteClipperHandler(w http.ResponseWriter, r *http.Request) {
 	if len(tags) > 0 {
 		database.SetItemTags(itemID, tags)
 	}
+	itemCreated(r.Context(), userID, itemID, "note", input.Title)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{"id": itemID, "status": "created"})
@@ -1881,20 +2074,25 @@ func ApiCreateNoteClipperHandler(w http.ResponseWriter, r *http.Request) {
 
 func ApiGetRatedListsHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
-	lists, err := database.GetRatedLists(userID, "")
+	lists, total, err := database.GetRatedListsPage(userID, "", apiArchived(r), apiPage(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	setTotalHeader(w, total)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(lists)
 }
 
 func ApiAddRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
-	listIDStr := chi.URLParam(r, "id")
-	var listID int64
-	fmt.Sscanf(listIDStr, "%d", &listID)
+	listID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !requireOwnership(w, listID, getUserID(r)) {
+		return
+	}
 
 	var req ApiRatedListItemRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1902,6 +2100,15 @@ func ApiAddRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var v validation.Validator
+	req.Title = v.Required("title", req.Title, maxTitleLength)
+	v.Range("score", req.Score, minScore, maxScore)
+	req.Note = v.MaxLength("note", req.Note, maxShortText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
 	_, err := database.AddRatedListItem(listID, req.Title, req.Score, req.Note)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -1913,7 +2120,7 @@ func ApiAddRatedListItemHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func ApiGetTagsHandler(w http.ResponseWriter, r *http.Request) {
-	tags, err := database.GetAllUniqueTags()
+	tags, err := database.GetAllUniqueTags(getUserID(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -1933,19 +2140,22 @@ func ApiCreateRecipeClipperHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if body.URL == "" {
-		http.Error(w, "URL is required", http.StatusBadRequest)
+	var v validation.Validator
+	body.URL = v.URL("url", v.Required("url", body.URL, 0))
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
 		return
 	}
 
 	// 1. Import/Parse the recipe
-	recipeData, err := ParseRecipeFromURL(body.URL)
+	recipeData, err := ParseRecipeFromURL(r.Context(), body.URL)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to parse recipe: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// 2. Prepare data for database
+	// 2. Prepare data for database, translating it if configured
+	original := translateRecipe(r.Context(), recipeData)
 	ingredientsStr := strings.Join(recipeData.Ingredients, "\n")
 
 	// 3. Create the recipe
@@ -1964,6 +2174,9 @@ func ApiCreateRecipeClipperHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Failed to save recipe: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if original != nil {
+		database.SetRecipeOriginal(itemID, original.Language, original.Ingredients, original.Instructions)
+	}
 
 	// 4. Add tags if provided
 	if body.Tags != "" {
@@ -1976,6 +2189,7 @@ func ApiCreateRecipeClipperHandler(w http.ResponseWriter, r *http.Request) {
 			// Don't fail the whole request for tag errors
 		}
 	}
+	itemCreated(r.Context(), userID, itemID, "recipe", recipeData.Title)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2001,8 +2215,8 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Allow health check
-		if r.URL.Path == "/api/health" {
+		// Allow health checks
+		if r.URL.Path == "/api/health" || strings.HasPrefix(r.URL.Path, "/api/health/") {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -2012,8 +2226,14 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			token := strings.TrimPrefix(authHeader, "Bearer ")
 			userID, err := database.GetUserByToken(token)
 			if err == nil {
+				if !tokenAllowedFrom(userID, clientIP(r)) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusForbidden)
+					json.NewEncoder(w).Encode(map[string]string{"error": "API token not allowed from this address"})
+					return
+				}
 				ctx := context.WithValue(r.Context(), userIDKey, userID)
-				next.ServeHTTP(w, r.WithContext(ctx))
+				logAPICall(next).ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
 			// Invalid token
@@ -2031,7 +2251,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 				json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
 				return
 			}
-			http.Redirect(w, r, "/login", http.StatusFound)
+			redirectTo(w, r, "/login", http.StatusFound)
 			return
 		}
 
@@ -2043,7 +2263,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 				json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
 				return
 			}
-			http.Redirect(w, r, "/login", http.StatusFound)
+			redirectTo(w, r, "/login", http.StatusFound)
 			return
 		}
 
@@ -2065,17 +2285,21 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 		user, err := database.GetUserByUsername(username)
 		if err != nil {
-			http.Redirect(w, r, "/login?error=invalid", http.StatusFound)
+			redirectTo(w, r, "/login?error=invalid", http.StatusFound)
 			return
 		}
 
 		passwordHash := user["password_hash"].(string)
 		err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
 		if err != nil {
-			http.Redirect(w, r, "/login?error=invalid", http.StatusFound)
+			recordAuthEvent(r, user["id"].(int64), database.AuthLoginFailed)
+			redirectTo(w, r, "/login?error=invalid", http.StatusFound)
 			return
 		}
 
+		recordAuthEvent(r, user["id"].(int64), database.AuthLogin)
+		recordLoginDevice(r, user["id"].(int64), username)
+
 		duration := 24 * time.Hour
 		if stayConnected {
 			duration = 30 * 24 * time.Hour
@@ -2095,22 +2319,34 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 			Path:     "/",
 		})
 
-		http.Redirect(w, r, "/", http.StatusFound)
+		redirectTo(w, r, "/", http.StatusFound)
 	}
 }
 
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		RenderTemplate(w, "register.html", nil)
+		RenderTemplate(w, "register.html", map[string]interface{}{
+			"Error":  r.URL.Query().Get("error"),
+			"Policy": passwordPolicy,
+		})
 		return
 	}
 
 	if r.Method == http.MethodPost {
-		username := r.FormValue("username")
+		var v validation.Validator
+		username := v.Required("username", r.FormValue("username"), 0)
 		password := r.FormValue("password")
-
-		if username == "" || password == "" {
-			http.Redirect(w, r, "/register?error=empty", http.StatusFound)
+		if password == "" {
+			v.Add("password", "is required")
+		}
+		passwordPolicy.Validate(&v, "password", password, username)
+		if !v.Valid() {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			RenderTemplate(w, "register.html", map[string]interface{}{
+				"Errors":   v.Errors(),
+				"Username": username,
+				"Policy":   passwordPolicy,
+			})
 			return
 		}
 
@@ -2122,17 +2358,20 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 
 		_, err = database.CreateUser(username, string(hashedPassword))
 		if err != nil {
-			http.Redirect(w, r, "/register?error=exists", http.StatusFound)
+			redirectTo(w, r, "/register?error=exists", http.StatusFound)
 			return
 		}
 
-		http.Redirect(w, r, "/login?registered=true", http.StatusFound)
+		redirectTo(w, r, "/login?registered=true", http.StatusFound)
 	}
 }
 
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("session_id")
 	if err == nil {
+		if userID, err := database.GetSession(cookie.Value); err == nil {
+			recordAuthEvent(r, userID, database.AuthLogout)
+		}
 		database.DeleteSession(cookie.Value)
 	}
 
@@ -2144,5 +2383,5 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		Path:     "/",
 	})
 
-	http.Redirect(w, r, "/login", http.StatusFound)
+	redirectTo(w, r, "/login", http.StatusFound)
 }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+)
+
+// healthCheckTimeout bounds each readiness check so a stuck database fails the
+// probe instead of hanging it
+const healthCheckTimeout = 2 * time.Second
+
+// healthCheck is the outcome of one readiness check
+type healthCheck struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthHandler is the original health endpoint, kept for existing monitors;
+// it reports liveness only
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// HealthLiveHandler reports that the process is up and serving requests.
+// It checks no dependencies, so an orchestrator only restarts a hung process.
+func HealthLiveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "ok",
+		"started_at":     startTime.UTC(),
+		"uptime_seconds": int64(time.Since(startTime).Seconds()),
+	})
+}
+
+// HealthReadyHandler reports whether the instance can serve traffic: the
+// database answers, its schema is the one this build expects and the uploads
+// directory is writable. It returns 503 if any check fails.
+func HealthReadyHandler(w http.ResponseWriter, r *http.Request) {
+	checks, schemaVersion, ready := runReadinessChecks(r.Context())
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"schema": map[string]int{
+			"version":  schemaVersion,
+			"expected": database.SchemaVersion,
+		},
+		"checks": checks,
+	})
+}
+
+// runReadinessChecks runs the readiness checks by name. It also returns the
+// database's schema version and whether every check passed.
+func runReadinessChecks(ctx context.Context) (checks map[string]healthCheck, schemaVersion int, ready bool) {
+	checks = map[string]healthCheck{}
+	ready = true
+	run := func(name string, check func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+		start := time.Now()
+		err := check(ctx)
+		c := healthCheck{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+		if err != nil {
+			c.Status = "fail"
+			c.Error = err.Error()
+			ready = false
+		}
+		checks[name] = c
+	}
+
+	run("database", func(ctx context.Context) error {
+		return database.DB.PingContext(ctx)
+	})
+
+	run("schema", func(ctx context.Context) error {
+		version, err := database.GetSchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		schemaVersion = version
+		if version != database.SchemaVersion {
+			return fmt.Errorf("database schema is version %d, expected %d", version, database.SchemaVersion)
+		}
+		return nil
+	})
+
+	run("uploads", func(ctx context.Context) error {
+		return checkWritable(datadir.Uploads())
+	})
+	return checks, schemaVersion, ready
+}
+
+// checkWritable creates and removes a temporary file in dir
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".healthcheck-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
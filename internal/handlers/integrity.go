@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+)
+
+// The integrity check (admins only) finds what older versions, which didn't
+// enforce foreign keys, could leave behind: rows whose item or user is gone,
+// and files in the uploads folder that no row points to. It can then remove
+// them. Files in object storage are not looked at.
+
+const (
+	// orphanFileAge is how old an unreferenced upload must be to count as
+	// orphaned, so a file whose row is still being written is left alone
+	orphanFileAge = time.Hour
+	// integrityListedFiles caps the orphaned files named in a report
+	integrityListedFiles = 100
+)
+
+// integrityReport is the outcome of a check, or of a clean-up (Fixed)
+type integrityReport struct {
+	Fixed     bool                   `json:"fixed"`
+	Orphans   []database.OrphanCount `json:"orphans"`
+	Rows      int64                  `json:"rows"`
+	Files     []orphanFile           `json:"files"`
+	FileCount int                    `json:"file_count"`
+	FileBytes int64                  `json:"file_bytes"`
+}
+
+// orphanFile is a file in the uploads folder that nothing points to
+type orphanFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// unreferencedUploads returns the files in dir that are not referenced as
+// /static/uploads/<name> and were last changed before cutoff, by name
+func unreferencedUploads(dir string, referenced map[string]bool, cutoff time.Time) ([]orphanFile, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []orphanFile
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") || referenced["/static/uploads/"+e.Name()] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		files = append(files, orphanFile{Name: e.Name(), Size: info.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// checkIntegrity finds, or with fix removes, orphaned rows and uploads
+func checkIntegrity(fix bool) (*integrityReport, error) {
+	report := &integrityReport{Fixed: fix}
+	var err error
+	if fix {
+		report.Orphans, err = database.DeleteOrphans()
+	} else {
+		report.Orphans, err = database.FindOrphans()
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range report.Orphans {
+		report.Rows += o.Count
+	}
+
+	// Rows removed above no longer reference their files, which are then
+	// found here
+	referenced, err := database.GetReferencedFiles()
+	if err != nil {
+		return nil, err
+	}
+	uploads := datadir.Uploads()
+	files, err := unreferencedUploads(uploads, referenced, time.Now().Add(-orphanFileAge))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if fix {
+			if err := os.Remove(filepath.Join(uploads, f.Name)); err != nil {
+				log.Printf("Integrity: removing %s: %v", f.Name, err)
+				continue
+			}
+		}
+		report.FileCount++
+		report.FileBytes += f.Size
+		if len(report.Files) < integrityListedFiles {
+			report.Files = append(report.Files, f)
+		}
+	}
+	return report, nil
+}
+
+// IntegrityHandler reports orphaned rows and uploads on GET and removes them
+// on POST (admins only)
+func IntegrityHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if !isAdmin(userID) {
+		http.Error(w, "Only admins can check the database", http.StatusForbidden)
+		return
+	}
+
+	fix := r.Method == http.MethodPost
+	report, err := checkIntegrity(fix)
+	if err != nil {
+		log.Printf("Integrity check: %v", err)
+		http.Error(w, "Integrity check failed", http.StatusInternalServerError)
+		return
+	}
+	if fix {
+		log.Printf("Integrity clean-up by user %d removed %d orphaned rows and %d files (%d bytes)",
+			userID, report.Rows, report.FileCount, report.FileBytes)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
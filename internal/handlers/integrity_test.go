@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUnreferencedUploads(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"kept.png", "orphan.png", ".gitkeep", "new.png"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if name != "new.png" {
+			os.Chtimes(path, old, old)
+		}
+	}
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	referenced := map[string]bool{"/static/uploads/kept.png": true}
+	got, err := unreferencedUploads(dir, referenced, time.Now().Add(-orphanFileAge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []orphanFile{{Name: "orphan.png", Size: 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unreferencedUploads = %v, want %v", got, want)
+	}
+
+	if got, err := unreferencedUploads(filepath.Join(dir, "missing"), referenced, time.Now()); err != nil || got != nil {
+		t.Errorf("unreferencedUploads of a missing folder = %v, %v", got, err)
+	}
+}
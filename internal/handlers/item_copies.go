@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+)
+
+// DuplicateItemHandler copies one of the user's items, e.g. a packing list
+// to reuse or a recipe to make a variant of, and returns the copy as JSON,
+// in the form the item type's own endpoints use
+func DuplicateItemHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	copyID, itemType, err := database.DuplicateItem(userID, id, copyItemFile)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Duplicating item %d: %v", id, err)
+		http.Error(w, "Failed to duplicate the item", http.StatusInternalServerError)
+		return
+	}
+
+	item, title, err := getTypedItem(userID, copyID, itemType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	itemCreated(r.Context(), userID, copyID, itemType, title)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": copyID, "type": itemType, "item": item})
+}
+
+// getTypedItem returns an item as its type's model, with a checklist's or
+// rated list's entries, and its title
+func getTypedItem(userID, id int64, itemType string) (interface{}, string, error) {
+	switch itemType {
+	case "bookmark":
+		b, err := database.GetBookmark(userID, id)
+		if err != nil {
+			return nil, "", err
+		}
+		return b, b.Title, nil
+	case "note":
+		n, err := database.GetNote(userID, id)
+		if err != nil {
+			return nil, "", err
+		}
+		return n, n.Title, nil
+	case "list":
+		l, err := database.GetList(userID, id)
+		if err != nil {
+			return nil, "", err
+		}
+		if l.Items, err = database.GetListItems(id); err != nil {
+			return nil, "", err
+		}
+		return l, l.Title, nil
+	case "rated_list":
+		l, err := database.GetRatedList(userID, id)
+		if err != nil {
+			return nil, "", err
+		}
+		if l.Items, err = database.GetRatedListItems(id); err != nil {
+			return nil, "", err
+		}
+		return l, l.Title, nil
+	case "recipe":
+		rec, err := database.GetRecipe(userID, id)
+		if err != nil {
+			return nil, "", err
+		}
+		return rec, rec.Title, nil
+	case "drawing":
+		d, err := database.GetDrawing(userID, id)
+		if err != nil {
+			return nil, "", err
+		}
+		return d, d.Title, nil
+	case "media":
+		m, err := database.GetMediaItem(id, userID)
+		if err != nil {
+			return nil, "", err
+		}
+		return m, m.Title, nil
+	case "cookbook":
+		cb, err := database.GetCookbook(userID, id)
+		if err != nil {
+			return nil, "", err
+		}
+		return cb, cb.Title, nil
+	}
+	return nil, "", fmt.Errorf("unknown item type %s", itemType)
+}
+
+// copyItemFile copies a file an item uses, in the data directory or in
+// object storage, and returns the copy's path. Anything else, such as an
+// image on another site, and a file that is missing are shared by both
+// items.
+func copyItemFile(p string) (string, error) {
+	name := fmt.Sprintf("%d%s", time.Now().UnixNano(), path.Ext(p))
+	if local, ok := datadir.File(p); ok {
+		err := copyLocalFile(local, filepath.Join(filepath.Dir(local), name))
+		if errors.Is(err, os.ErrNotExist) {
+			return p, nil // nothing to copy, and nothing to lose when it is purged
+		}
+		if err != nil {
+			return "", err
+		}
+		return path.Join(path.Dir(p), name), nil
+	}
+	if !s3Enabled() {
+		return p, nil
+	}
+	key, ok := s3.objectKey(p)
+	if !ok {
+		return p, nil
+	}
+	size, contentType, err := s3.headObject(key)
+	if err != nil {
+		return "", err
+	}
+	body, err := s3.getObject(key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	copyKey := path.Join(path.Dir(key), name)
+	if err := s3.putObject(copyKey, contentType, body, size); err != nil {
+		return "", err
+	}
+	return s3.publicURL(copyKey), nil
+}
+
+func copyLocalFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(to)
+		return err
+	}
+	return out.Close()
+}
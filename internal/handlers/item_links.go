@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+// Items can be linked to each other, both ways: a note about a recipe lists
+// the recipe as related, and the recipe the note. The Related section of an
+// item's detail view (fragments/related.html) shows its links and searches
+// for items to link; the same endpoints return JSON to the API.
+
+// linkCandidates is how many items the Related section's search offers
+const linkCandidates = 8
+
+// relatedView is the data of fragments/related.html
+type relatedView struct {
+	ItemID int64
+	Links  []models.LinkedItem
+	Query  string // what the candidates were searched for
+}
+
+// writeItemLinks responds with the items linked to an item: the Related
+// section to HTMX, JSON to anyone else
+func writeItemLinks(w http.ResponseWriter, r *http.Request, userID, itemID int64) {
+	links, err := database.GetLinkedItems(userID, itemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if r.Header.Get("HX-Request") != "" {
+		RenderFragment(w, "related.html", relatedView{ItemID: itemID, Links: links})
+		return
+	}
+	if links == nil {
+		links = []models.LinkedItem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// ItemLinksHandler lists the items linked to an item, and links another one
+// to it: item_id, as a form value or in a JSON body {"item_id": 7}
+func ItemLinksHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	if !requireOwnership(w, itemID, userID) {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var input struct {
+			ItemID int64 `json:"item_id"`
+		}
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else if s := strings.TrimSpace(r.FormValue("item_id")); s != "" {
+			id, err := parseID(s)
+			if err != nil {
+				http.Error(w, "Invalid item id", http.StatusBadRequest)
+				return
+			}
+			input.ItemID = id
+		}
+
+		var v validation.Validator
+		if input.ItemID == 0 {
+			v.Add("item_id", "is required")
+		} else if input.ItemID == itemID {
+			v.Add("item_id", database.ErrSelfLink.Error())
+		}
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
+		err := database.LinkItems(userID, itemID, input.ItemID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeItemLinks(w, r, userID, itemID)
+}
+
+// UnlinkItemHandler removes the link between two items
+func UnlinkItemHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	linkedID, ok := pathID(w, r, "linkedID")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	err := database.UnlinkItems(userID, itemID, linkedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeItemLinks(w, r, userID, itemID)
+}
+
+// LinkCandidatesHandler offers the items whose title contains q to link to
+// an item, as buttons of the Related section
+func LinkCandidatesHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	if !requireOwnership(w, itemID, userID) {
+		return
+	}
+	var candidates []models.LinkedItem
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q != "" {
+		var err error
+		candidates, err = database.FindLinkCandidates(userID, itemID, q, linkCandidates)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	RenderFragment(w, "related_candidates.html", relatedView{ItemID: itemID, Links: candidates, Query: q})
+}
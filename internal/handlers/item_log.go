@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+// itemLogView is the data of fragments/item_log.html
+type itemLogView struct {
+	ItemID  int64
+	Entries []models.LogEntry
+}
+
+// ItemLogHandler lists the log of an item, newest entry first, and adds an
+// entry to it: body, as a form value or in a JSON body {"body": "..."}. It
+// responds with the Log section to HTMX and JSON to anyone else.
+func ItemLogHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	if !requireOwnership(w, itemID, userID) {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var input struct {
+			Body string `json:"body"`
+		}
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			input.Body = r.FormValue("body")
+		}
+		var v validation.Validator
+		body := v.Required("body", input.Body, maxShortText)
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
+		if _, err := database.AddLogEntry(userID, itemID, body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	entries, err := database.GetLogEntries(userID, itemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if r.Header.Get("HX-Request") != "" {
+		RenderFragment(w, "item_log.html", itemLogView{ItemID: itemID, Entries: entries})
+		return
+	}
+	if entries == nil {
+		entries = []models.LogEntry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
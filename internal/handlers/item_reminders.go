@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+const (
+	// upcomingDays is how far ahead the dashboard's Upcoming list looks
+	upcomingDays = 7
+	// dashboardUpcoming is how many entries the Upcoming list shows
+	dashboardUpcoming = 10
+	// dueNotifyHour is the hour of the day a checklist entry's due date is
+	// notified about
+	dueNotifyHour = 9
+	// remindAtLayout is the format of a datetime-local input
+	remindAtLayout = "2006-01-02T15:04"
+)
+
+// notificationTypes are the ways a reminder can go off
+var notificationTypes = map[string]bool{"notification_only": true, "email": true, "both": true}
+
+func writeItemReminders(w http.ResponseWriter, userID, itemID int64) {
+	reminders, err := database.GetItemReminders(userID, itemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if reminders == nil {
+		reminders = []models.ItemReminder{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reminders)
+}
+
+// ItemRemindersHandler lists the reminders about an item that have not gone
+// off yet, and adds one: remind_at (a datetime-local value in the server's
+// time zone), notification_type and, for email, emails
+func ItemRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	if !requireOwnership(w, itemID, userID) {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var v validation.Validator
+		remindAt, err := time.ParseInLocation(remindAtLayout, strings.TrimSpace(r.FormValue("remind_at")), time.Local)
+		if err != nil {
+			v.Add("remind_at", "must be a date and time")
+		} else if !remindAt.After(time.Now()) {
+			v.Add("remind_at", "must be in the future")
+		}
+		kind := r.FormValue("notification_type")
+		if kind == "" {
+			kind = "notification_only"
+		}
+		if !notificationTypes[kind] {
+			v.Add("notification_type", "must be notification_only, email or both")
+		}
+		emails := v.MaxLength("emails", r.FormValue("emails"), maxShortText)
+		if kind != "notification_only" && emails == "" {
+			v.Add("emails", "is required for email reminders")
+		}
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
+		if kind == "notification_only" {
+			emails = ""
+		}
+		if _, err := database.CreateItemReminder(&models.ItemReminder{
+			UserID: userID, ItemID: itemID, RemindAt: remindAt, NotificationType: kind, Emails: emails,
+		}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeItemReminders(w, userID, itemID)
+}
+
+// DeleteItemReminderHandler removes an item reminder
+func DeleteItemReminderHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := database.DeleteItemReminder(getUserID(r), id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Reminder not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// nextOccurrence returns when a reminder of the Reminders page goes off next
+// after now, looking at most days ahead
+func nextOccurrence(r database.Reminder, now time.Time, days int) (time.Time, bool) {
+	at, err := time.Parse("15:04", r.TimeOfDay)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for d := 0; d <= days; d++ {
+		day := now.AddDate(0, 0, d)
+		t := time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+		if t.After(now) && isReminderDue(r, t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// upcoming returns what the user is reminded of or has due in the next
+// upcomingDays days, and what is overdue, soonest first
+func upcoming(userID int64, now time.Time) ([]models.Upcoming, error) {
+	until := now.AddDate(0, 0, upcomingDays)
+	results, err := database.GetUpcoming(userID, now, until)
+	if err != nil {
+		return nil, err
+	}
+	reminders, err := database.GetRemindersForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reminders {
+		if at, ok := nextOccurrence(r, now, upcomingDays); ok {
+			results = append(results, models.Upcoming{
+				Kind: "reminder", ItemID: r.ItemID.Int64, Type: "reminder", Title: r.Name, At: at,
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].At.Before(results[j].At) })
+	if len(results) > dashboardUpcoming {
+		results = results[:dashboardUpcoming]
+	}
+	return results, nil
+}
+
+// fireItemReminders sends the item reminders that are due
+func fireItemReminders(now time.Time) {
+	reminders, err := database.GetDueItemReminders(now)
+	if err != nil {
+		log.Printf("Worker: Error fetching item reminders: %v", err)
+		return
+	}
+	for _, r := range reminders {
+		log.Printf("Worker: Firing reminder %d about item %d", r.ID, r.ItemID)
+		if r.NotificationType != "email" {
+			sendPushToUser(r.UserID, "InfoKeep Reminder", r.ItemTitle)
+		}
+		if r.NotificationType != "notification_only" && r.Emails != "" {
+			err := sendMail(strings.Split(r.Emails, ","), "InfoKeep Reminder: "+r.ItemTitle,
+				"This is the reminder you set in InfoKeep about your "+strings.ReplaceAll(r.ItemType, "_", " ")+" \""+r.ItemTitle+"\".")
+			if err != nil {
+				log.Printf("Worker: Failed to send email for item reminder %d: %v", r.ID, err)
+			}
+		}
+		if err := database.MarkItemReminderFired(r.ID, now); err != nil {
+			log.Printf("Worker: Failed to mark item reminder %d as fired: %v", r.ID, err)
+		}
+	}
+}
+
+// notifyDueListItems tells users about checklist entries due today, from
+// dueNotifyHour on, and about overdue ones they were not told about, e.g.
+// because the server was down
+func notifyDueListItems(now time.Time) {
+	day := now
+	if now.Hour() < dueNotifyHour {
+		day = now.AddDate(0, 0, -1)
+	}
+	items, err := database.GetDueListItems(day.Format(time.DateOnly))
+	if err != nil {
+		log.Printf("Worker: Error fetching due checklist entries: %v", err)
+		return
+	}
+	today := now.Format(time.DateOnly)
+	for _, item := range items {
+		title := "Due today: " + item.Content
+		if item.DueDate < today {
+			title = "Overdue: " + item.Content
+		}
+		sendPushToUser(item.UserID, title, "On your checklist "+item.ListTitle)
+		if err := database.MarkListItemDueNotified(item.ID, now); err != nil {
+			log.Printf("Worker: Failed to mark checklist entry %d as notified: %v", item.ID, err)
+		}
+	}
+}
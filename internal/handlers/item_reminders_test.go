@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	// A Wednesday morning
+	now := time.Date(2026, 3, 11, 8, 0, 0, 0, time.Local)
+	fired := sql.NullString{String: now.Format(time.RFC3339), Valid: true}
+	tests := []struct {
+		name     string
+		reminder database.Reminder
+		want     time.Time
+	}{
+		{"daily, later today", database.Reminder{Frequency: "Daily", TimeOfDay: "09:30", StartDate: "2026-01-01"},
+			time.Date(2026, 3, 11, 9, 30, 0, 0, time.Local)},
+		{"daily, passed today", database.Reminder{Frequency: "Daily", TimeOfDay: "07:00", StartDate: "2026-01-01"},
+			time.Date(2026, 3, 12, 7, 0, 0, 0, time.Local)},
+		{"daily, fired today", database.Reminder{Frequency: "Daily", TimeOfDay: "09:30", StartDate: "2026-01-01", LastTriggeredAt: fired},
+			time.Date(2026, 3, 12, 9, 30, 0, 0, time.Local)},
+		{"weekly on Mondays", database.Reminder{Frequency: "Weekly", TimeOfDay: "18:00", StartDate: "2026-03-02"},
+			time.Date(2026, 3, 16, 18, 0, 0, 0, time.Local)},
+		{"once, starting Friday", database.Reminder{Frequency: "Once", TimeOfDay: "12:00", StartDate: "2026-03-13"},
+			time.Date(2026, 3, 13, 12, 0, 0, 0, time.Local)},
+		{"once, already fired", database.Reminder{Frequency: "Once", TimeOfDay: "12:00", StartDate: "2026-03-13", LastTriggeredAt: fired},
+			time.Time{}},
+		{"monthly, next month", database.Reminder{Frequency: "Monthly", TimeOfDay: "12:00", StartDate: "2026-01-01"},
+			time.Time{}},
+		{"ended", database.Reminder{Frequency: "Daily", TimeOfDay: "12:00", StartDate: "2026-01-01",
+			EndDate: sql.NullString{String: "2026-03-10", Valid: true}}, time.Time{}},
+	}
+	for _, tt := range tests {
+		got, ok := nextOccurrence(tt.reminder, now, upcomingDays)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+			t.Errorf("%s: nextOccurrence = %v, %v, want %v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestItemRemindersAndDueDates(t *testing.T) {
+	if err := database.InitDB(filepath.Join(t.TempDir(), "reminders.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	if _, err := database.DB.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')"); err != nil {
+		t.Fatal(err)
+	}
+	note, _ := database.CreateNote(1, "Call the plumber", "")
+	list, _ := database.CreateList(1, "Chores")
+	overdue, _ := database.AddListItem(list, "Mow the lawn")
+	dueToday, _ := database.AddListItem(list, "Water the plants")
+	later, _ := database.AddListItem(list, "Clean the gutters")
+	done, _ := database.AddListItem(list, "Take out the bins")
+	database.ToggleListItem(done, true)
+
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+	for id, due := range map[int64]string{overdue: "2026-03-09", dueToday: "2026-03-11", later: "2026-03-30", done: "2026-03-10"} {
+		if err := database.SetListItemDueDate(id, due); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, at := range []time.Time{now.Add(-time.Minute), now.Add(2 * time.Hour)} {
+		if _, err := database.CreateItemReminder(&models.ItemReminder{
+			UserID: 1, ItemID: note, RemindAt: at, NotificationType: "notification_only",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next, err := upcoming(1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, u := range next {
+		got = append(got, u.Title)
+	}
+	// Soonest first, entries due today at the start of the day; the entry
+	// checked off and the one due in weeks are left out
+	want := []string{"Mow the lawn", "Water the plants", "Call the plumber", "Call the plumber"}
+	if len(got) != len(want) {
+		t.Fatalf("upcoming = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("upcoming = %q, want %q", got, want)
+		}
+	}
+	if !next[0].Overdue || next[1].Overdue || next[1].Detail != "Chores" || !next[2].Overdue || next[3].Overdue {
+		t.Errorf("upcoming = %+v", next)
+	}
+
+	// The worker fires the reminder that is due, once
+	fireItemReminders(now)
+	pending, _ := database.GetItemReminders(1, note)
+	if len(pending) != 1 || !pending[0].RemindAt.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("pending reminders after firing: %+v", pending)
+	}
+
+	// Before dueNotifyHour only overdue entries are notified about
+	early := time.Date(2026, 3, 11, dueNotifyHour-1, 0, 0, 0, time.Local)
+	items, _ := database.GetDueListItems(early.AddDate(0, 0, -1).Format(time.DateOnly))
+	if len(items) != 1 || items[0].ID != overdue {
+		t.Errorf("due before %d:00: %+v", dueNotifyHour, items)
+	}
+	notifyDueListItems(now)
+	if items, _ := database.GetDueListItems("2026-12-31"); len(items) != 1 || items[0].ID != later {
+		t.Errorf("entries left to notify about: %+v", items)
+	}
+
+	// Moving the due date notifies about it again
+	database.SetListItemDueDate(dueToday, "2026-03-12")
+	if items, _ := database.GetDueListItems("2026-03-12"); len(items) != 1 || items[0].ID != dueToday {
+		t.Errorf("after moving the due date: %+v", items)
+	}
+}
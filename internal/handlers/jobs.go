@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"infokeep/internal/database"
+)
+
+// jobType describes how to run one kind of background job. onBatchDone, if
+// set, is called once every job of a batch has finished.
+type jobType struct {
+	run         func(job *database.Job) (result string, err error)
+	onBatchDone func(userID int64, jobs []database.Job)
+}
+
+var jobTypes = map[string]jobType{
+	recipeImportJob:      {run: runRecipeImportJob, onBatchDone: recipeImportBatchDone},
+	dataExportJob:        {run: runDataExportJob},
+	instanceMigrationJob: {run: runInstanceMigrationJob},
+	bookmarkMetadataJob:  {run: runBookmarkMetadataJob},
+}
+
+// jobWake lets enqueueJob start the worker right away instead of waiting for the next poll
+var jobWake = make(chan struct{}, 1)
+
+// enqueueJob adds a job to the queue, wakes the worker and returns the job ID
+func enqueueJob(userID int64, jobType, batchID, payload string) (int64, error) {
+	id, err := database.EnqueueJob(userID, jobType, batchID, payload)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// StartJobWorker processes queued jobs one at a time, polling every few seconds
+func StartJobWorker() {
+	if n, err := database.RequeueRunningJobs(); err != nil {
+		log.Printf("Worker: Failed to requeue interrupted jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("Worker: Requeued %d interrupted jobs", n)
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		RunQueuedJobs()
+		select {
+		case <-ticker.C:
+		case <-jobWake:
+		}
+	}
+}
+
+// RunQueuedJobs runs queued jobs one at a time until the queue is empty
+func RunQueuedJobs() {
+	for runNextJob() {
+	}
+}
+
+// runNextJob runs the oldest pending job and reports whether there was one
+func runNextJob() bool {
+	job, err := database.ClaimNextJob()
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Printf("Worker: Failed to claim job: %v", err)
+		return false
+	}
+
+	jt, ok := jobTypes[job.Type]
+	if !ok {
+		log.Printf("Worker: Unknown job type %q for job %d", job.Type, job.ID)
+		database.FinishJob(job.ID, database.JobFailed, "unknown job type")
+		return true
+	}
+
+	status := database.JobDone
+	start := time.Now()
+	result, err := jt.run(job)
+	jobDuration.Observe(time.Since(start).Seconds(), job.Type)
+	if err != nil {
+		status = database.JobFailed
+		result = err.Error()
+		log.Printf("Worker: Job %d (%s) failed: %v", job.ID, job.Type, err)
+	}
+	jobsProcessed.Inc(job.Type, status)
+	if err := database.FinishJob(job.ID, status, result); err != nil {
+		log.Printf("Worker: Failed to record result of job %d: %v", job.ID, err)
+	}
+
+	if job.BatchID != "" && jt.onBatchDone != nil {
+		if remaining, err := database.CountUnfinishedBatchJobs(job.BatchID); err == nil && remaining == 0 {
+			if jobs, err := database.GetBatchJobs(job.BatchID); err == nil {
+				jt.onBatchDone(job.UserID, jobs)
+			}
+		}
+	}
+	return true
+}
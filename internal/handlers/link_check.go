@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/jobs"
+)
+
+// Bookmarked links can be checked every few days to find the dead ones: a
+// link whose page answers with an error or not at all is marked on its
+// card and listed under ?kind=broken, to fix or archive. Off unless
+// LINK_CHECK_DAYS is set: nothing is fetched by default.
+var (
+	linkCheckDays   = 0
+	linkCheckClient = &http.Client{Timeout: 15 * time.Second, Transport: fetchTransport("link_check")}
+)
+
+const (
+	// linkCheckBatch is how many links one run of the task checks
+	linkCheckBatch = 50
+	// brokenLinksKind is the ?kind= of the bookmarks list that shows the
+	// bookmarks whose link failed its last check
+	brokenLinksKind = "broken"
+)
+
+func init() {
+	if v, err := strconv.Atoi(os.Getenv("LINK_CHECK_DAYS")); err == nil && v > 0 {
+		linkCheckDays = v
+	}
+}
+
+func registerLinkCheckTask() {
+	if linkCheckDays == 0 {
+		return
+	}
+	jobs.Register(jobs.Task{
+		Name:        "link_check",
+		Description: fmt.Sprintf("Request bookmarked links every %d days and mark the ones that no longer work", linkCheckDays),
+		Interval:    time.Hour,
+		Run:         checkLinks,
+	})
+}
+
+// checkLinks checks the links of a batch of bookmarks that are due
+func checkLinks() (string, error) {
+	due, err := database.GetBookmarksToLinkCheck(linkCheckDays, linkCheckBatch)
+	if err != nil {
+		return "", err
+	}
+	broken := 0
+	for _, b := range due {
+		status, errText := checkLink(withUserID(context.Background(), b.UserID), b.URL)
+		if err := database.SetLinkStatus(b.ID, status, errText); err != nil {
+			return "", err
+		}
+		if linkBroken(status) {
+			broken++
+		}
+	}
+	return fmt.Sprintf("Checked %d links: %d broken", len(due), broken), nil
+}
+
+// checkLink requests url and returns the HTTP status of the answer, or 0
+// and the error if there was none. Servers that refuse HEAD are asked with
+// GET instead.
+func checkLink(ctx context.Context, url string) (int, string) {
+	status, err := requestLink(ctx, http.MethodHead, url)
+	if err != nil || linkBroken(status) {
+		status, err = requestLink(ctx, http.MethodGet, url)
+	}
+	if err != nil {
+		return 0, err.Error()
+	}
+	return status, ""
+}
+
+func requestLink(ctx context.Context, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	resp, err := linkCheckClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// linkBroken reports whether a link check with this outcome failed
+func linkBroken(status int) bool {
+	return status == 0 || status >= 400
+}
+
+// CheckLinkHandler checks a bookmark's link now, e.g. after fixing it, and
+// returns its card, or the bookmark as JSON to the API
+func CheckLinkHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	bookmark, err := database.GetBookmark(userID, id)
+	if err != nil {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	status, errText := checkLink(withUserID(r.Context(), userID), bookmark.URL)
+	if err := database.SetLinkStatus(id, status, errText); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBookmark(w, r, userID, id)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckLink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			http.NotFound(w, r)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	for path, want := range map[string]int{"/page": http.StatusOK, "/gone": http.StatusNotFound, "/no-head": http.StatusOK} {
+		if status, errText := checkLink(context.Background(), srv.URL+path); status != want || errText != "" {
+			t.Errorf("checkLink(%s) = %d, %q, want %d", path, status, errText, want)
+		}
+	}
+
+	if status, errText := checkLink(context.Background(), "http://127.0.0.1:1/"); status != 0 || errText == "" || !linkBroken(status) {
+		t.Errorf("checkLink of an unreachable server = %d, %q", status, errText)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+// Checklist entries can say how much is needed ("2 kg") and what it will
+// roughly cost, which turns a checklist into a shopping list with a total.
+
+// listItemAmount is the optional quantity, unit and estimated price of a
+// checklist entry
+type listItemAmount struct {
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	Price    float64 `json:"price"`
+}
+
+// formListItemAmount reads an entry's amount from the form fields quantity,
+// unit and price
+func formListItemAmount(v *validation.Validator, r *http.Request) listItemAmount {
+	return listItemAmount{
+		Quantity: v.Number("quantity", r.FormValue("quantity"), maxQuantity),
+		Unit:     v.MaxLength("unit", r.FormValue("unit"), maxUnitLength),
+		Price:    v.Number("price", r.FormValue("price"), maxPrice),
+	}
+}
+
+// check validates an amount given as JSON
+func (a *listItemAmount) check(v *validation.Validator) {
+	if a.Quantity < 0 || a.Quantity > maxQuantity {
+		v.Add("quantity", fmt.Sprintf("must be between 0 and %d", maxQuantity))
+	}
+	a.Unit = v.MaxLength("unit", a.Unit, maxUnitLength)
+	if a.Price < 0 || a.Price > maxPrice {
+		v.Add("price", fmt.Sprintf("must be between 0 and %d", maxPrice))
+	}
+}
+
+func (a listItemAmount) isSet() bool {
+	return a.Quantity != 0 || a.Unit != "" || a.Price != 0
+}
+
+func (a listItemAmount) save(itemID int64) error {
+	return database.SetListItemAmount(itemID, a.Quantity, a.Unit, a.Price)
+}
+
+// formatAmount writes a quantity or price for an export, leaving out one
+// that isn't set
+func formatAmount(n float64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// listItemsView is a checklist's entries with the estimated total of those
+// that have a price, and of those of them not checked off yet
+type listItemsView struct {
+	Items     []models.ListItem
+	Total     float64
+	Remaining float64
+	Priced    int // entries with a price
+	// Today is the date due dates are compared with, as YYYY-MM-DD
+	Today string
+	// TotalOnly renders just the total, to update it out of band after an
+	// entry was checked off or removed
+	TotalOnly bool
+}
+
+func newListItemsView(items []models.ListItem) listItemsView {
+	view := listItemsView{Items: items, Today: time.Now().Format(time.DateOnly)}
+	for _, item := range items {
+		if item.Price == 0 {
+			continue
+		}
+		view.Priced++
+		view.Total += item.Price
+		if !item.Completed {
+			view.Remaining += item.Price
+		}
+	}
+	return view
+}
+
+// renderListItems renders the entries of a checklist, or with totalOnly just
+// its total
+func renderListItems(w http.ResponseWriter, listID int64, totalOnly bool) {
+	items, _ := database.GetListItems(listID)
+	view := newListItemsView(items)
+	view.TotalOnly = totalOnly
+	RenderFragment(w, "list_items.html", view)
+}
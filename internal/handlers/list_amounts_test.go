@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"testing"
+
+	"infokeep/internal/models"
+)
+
+func TestNewListItemsView(t *testing.T) {
+	view := newListItemsView([]models.ListItem{
+		{Content: "Milk", Quantity: 2, Unit: "l", Price: 2.5},
+		{Content: "Bread"},
+		{Content: "Apples", Completed: true, Quantity: 1, Unit: "kg", Price: 3},
+	})
+	if view.Priced != 2 || view.Total != 5.5 || view.Remaining != 2.5 {
+		t.Errorf("view = %d priced, %g total, %g remaining; want 2, 5.5, 2.5", view.Priced, view.Total, view.Remaining)
+	}
+}
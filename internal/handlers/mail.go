@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// mailConfigured reports whether the SMTP_* environment variables needed to
+// send email are set
+func mailConfigured() bool {
+	return os.Getenv("SMTP_HOST") != "" && os.Getenv("SMTP_PORT") != ""
+}
+
+// sendMail sends a plain-text email using the SMTP_* environment variables.
+// It returns an error if SMTP is not configured.
+func sendMail(to []string, subject, body string) error {
+	smtpHost := os.Getenv("SMTP_HOST")
+	smtpPort := os.Getenv("SMTP_PORT")
+	smtpUser := os.Getenv("SMTP_USER")
+	smtpPass := os.Getenv("SMTP_PASS")
+	smtpFrom := os.Getenv("SMTP_FROM")
+
+	if !mailConfigured() {
+		return fmt.Errorf("missing SMTP config")
+	}
+	if smtpFrom == "" {
+		smtpFrom = "noreply@infokeep.local"
+	}
+
+	var recipients []string
+	for _, addr := range to {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			recipients = append(recipients, addr)
+		}
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
+	msg := []byte("To: " + strings.Join(recipients, ",") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"\r\n" +
+		body + "\r\n")
+
+	return smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpFrom, recipients, msg)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+)
+
+// Admins are the users named in ADMIN_USERS (comma separated usernames).
+// They can switch on maintenance mode, during which everyone else gets a
+// 503 page while admins keep using the site, e.g. to check a restore.
+var adminUsers = parseUserList(os.Getenv("ADMIN_USERS"))
+
+// maintenanceRetryAfter is the Retry-After sent with maintenance responses
+const maintenanceRetryAfter = "300"
+
+// maintenance is the current maintenance mode, kept in system_settings so
+// it survives restarts
+var maintenance struct {
+	sync.RWMutex
+	enabled bool
+	message string
+}
+
+func parseUserList(s string) map[string]bool {
+	users := map[string]bool{}
+	for _, u := range strings.Split(s, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			users[u] = true
+		}
+	}
+	return users
+}
+
+// isAdmin reports whether the user is listed in ADMIN_USERS
+func isAdmin(userID int64) bool {
+	if userID == 0 || len(adminUsers) == 0 {
+		return false
+	}
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return false
+	}
+	username, _ := user["username"].(string)
+	return adminUsers[username]
+}
+
+// LoadMaintenanceMode reads the stored maintenance mode; call it once the
+// database is open
+func LoadMaintenanceMode() {
+	enabled, _ := database.GetSystemSetting("maintenance_mode")
+	message, _ := database.GetSystemSetting("maintenance_message")
+	maintenance.Lock()
+	maintenance.enabled = enabled == "on"
+	maintenance.message = message
+	maintenance.Unlock()
+	if enabled == "on" {
+		log.Printf("Maintenance mode is on: only admins can use the site")
+	}
+}
+
+func maintenanceMode() (bool, string) {
+	maintenance.RLock()
+	defer maintenance.RUnlock()
+	return maintenance.enabled, maintenance.message
+}
+
+// requestUser returns the user a request is signed in as, by API token or
+// session cookie, or 0. Unlike AuthMiddleware it doesn't reject anything.
+func requestUser(r *http.Request) int64 {
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		userID, _ := database.GetUserByToken(strings.TrimPrefix(authHeader, "Bearer "))
+		return userID
+	}
+	if cookie, err := r.Cookie("session_id"); err == nil {
+		userID, _ := database.GetSession(cookie.Value)
+		return userID
+	}
+	return 0
+}
+
+// maintenanceExempt reports whether a path stays reachable for everyone in
+// maintenance mode: assets, signing in and out, health checks, the status
+// page and metrics
+func maintenanceExempt(path string) bool {
+	if StatusPath != "" && path == StatusPath {
+		return true
+	}
+	switch path {
+	case "/login", "/logout", "/favicon.ico", "/sw.js", "/metrics", "/api/health":
+		return true
+	}
+	return strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/api/health/")
+}
+
+// MaintenanceMiddleware answers requests with 503 Service Unavailable while
+// maintenance mode is on, except for admins and the exempt paths
+func MaintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enabled, message := maintenanceMode()
+		if !enabled || maintenanceExempt(r.URL.Path) || isAdmin(requestUser(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if message == "" {
+			message = "InfoKeep is down for maintenance and will be back shortly."
+		}
+		w.Header().Set("Retry-After", maintenanceRetryAfter)
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": message})
+			return
+		}
+		if r.Header.Get("HX-Request") != "" {
+			http.Error(w, message, http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		RenderPublicTemplate(w, "public_maintenance.html", map[string]interface{}{
+			"Message": message,
+		})
+	})
+}
+
+// MaintenanceHandler switches maintenance mode on or off (admins only)
+func MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if !isAdmin(userID) {
+		http.Error(w, "Only admins can change maintenance mode", http.StatusForbidden)
+		return
+	}
+
+	var v validation.Validator
+	message := v.MaxLength("message", r.FormValue("message"), maxShortText)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	enabled := r.FormValue("enabled") == "on"
+
+	state := "off"
+	if enabled {
+		state = "on"
+	}
+	if err := database.SetSystemSetting("maintenance_mode", state); err != nil {
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	if err := database.SetSystemSetting("maintenance_message", message); err != nil {
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	maintenance.Lock()
+	maintenance.enabled = enabled
+	maintenance.message = message
+	maintenance.Unlock()
+	log.Printf("Maintenance mode switched %s by user %d", state, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"enabled": enabled, "message": message})
+}
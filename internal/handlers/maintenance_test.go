@@ -0,0 +1,35 @@
+package handlers
+
+import "testing"
+
+func TestMaintenanceExempt(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/login", true},
+		{"/static/css/app.css", true},
+		{"/api/health", true},
+		{"/api/health/ready", true},
+		{"/status", true},
+		{"/", false},
+		{"/api/bookmarks", false},
+		{"/settings", false},
+		{"/staticfoo", false},
+	}
+	for _, tt := range tests {
+		if got := maintenanceExempt(tt.path); got != tt.want {
+			t.Errorf("maintenanceExempt(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseUserList(t *testing.T) {
+	users := parseUserList(" alice, bob,,")
+	if len(users) != 2 || !users["alice"] || !users["bob"] {
+		t.Errorf("parseUserList = %v, want alice and bob", users)
+	}
+	if len(parseUserList("")) != 0 {
+		t.Error("expected no users for empty list")
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"infokeep/internal/database"
+	"infokeep/internal/mediakind"
+)
+
+// mediaKindTagsSetting is the user setting that, when "on", tags uploaded
+// images with their kind (screenshot, photo or scan)
+const mediaKindTagsSetting = "media_kind_tags"
+
+// mediaKindFilter is an entry of the kind filter on the images page
+type mediaKindFilter struct {
+	Kind mediakind.Kind
+	Name string
+	Icon string
+}
+
+var mediaKindFilters = []mediaKindFilter{
+	{mediakind.Photo, "Photos", "fa-camera"},
+	{mediakind.Screenshot, "Screenshots", "fa-desktop"},
+	{mediakind.Scan, "Scans", "fa-file-lines"},
+}
+
+// MediaKindTagsHandler switches tagging uploaded images with their kind on
+// or off
+func MediaKindTagsHandler(w http.ResponseWriter, r *http.Request) {
+	value := "off"
+	if r.FormValue("enabled") == "on" {
+		value = "on"
+	}
+	if err := database.SetUserSetting(getUserID(r), mediaKindTagsSetting, value); err != nil {
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// metricsToken must be sent as a Bearer token to read /metrics. Without it
+// the endpoint is off, as item counts and routes are not meant to be public.
+var metricsToken = os.Getenv("METRICS_TOKEN")
+
+var (
+	httpRequests = metrics.NewCounterVec("infokeep_http_requests_total",
+		"HTTP requests served, by route pattern and status code.", "method", "route", "status")
+	httpDuration = metrics.NewHistogramVec("infokeep_http_request_duration_seconds",
+		"HTTP request latency, by route pattern.", metrics.DefaultBuckets, "method", "route")
+	jobsProcessed = metrics.NewCounterVec("infokeep_jobs_processed_total",
+		"Background jobs run, by type and outcome.", "type", "status")
+	jobDuration = metrics.NewHistogramVec("infokeep_job_duration_seconds",
+		"Background job run time, by type.", []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300}, "type")
+)
+
+var startTime = time.Now()
+
+// MetricsMiddleware counts requests and their latency per route pattern
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// The pattern rather than the path keeps IDs out of the labels
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequests.Inc(r.Method, route, strconv.Itoa(status))
+		httpDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// MetricsHandler serves metrics in the Prometheus text format
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if metricsToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+metricsToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WriteAll(w)
+
+	if counts, err := database.CountItemsByType(); err == nil {
+		var samples []metrics.Sample
+		for itemType, n := range counts {
+			samples = append(samples, metrics.Sample{Labels: []string{itemType}, Value: float64(n)})
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].Labels[0] < samples[j].Labels[0] })
+		metrics.WriteGauge(w, "infokeep_items", "Stored items, by type.", []string{"type"}, samples)
+	}
+
+	if counts, err := database.CountJobsByStatus(); err == nil {
+		var samples []metrics.Sample
+		for _, c := range counts {
+			samples = append(samples, metrics.Sample{Labels: []string{c.Type, c.Status}, Value: float64(c.Count)})
+		}
+		metrics.WriteGauge(w, "infokeep_jobs", "Background jobs in the queue table, by type and status.", []string{"type", "status"}, samples)
+	}
+
+	users, _ := database.CountUsers()
+	metrics.WriteGauge(w, "infokeep_users", "Registered users.", nil, []metrics.Sample{{Value: float64(users)}})
+
+	stats := database.DB.Stats()
+	metrics.WriteGauge(w, "infokeep_db_open_connections", "Open database connections.", nil,
+		[]metrics.Sample{{Value: float64(stats.OpenConnections)}})
+	metrics.WriteGauge(w, "infokeep_db_wait_seconds", "Total time spent waiting for a database connection.", nil,
+		[]metrics.Sample{{Value: stats.WaitDuration.Seconds()}})
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	metrics.WriteGauge(w, "go_goroutines", "Number of goroutines that currently exist.", nil,
+		[]metrics.Sample{{Value: float64(runtime.NumGoroutine())}})
+	metrics.WriteGauge(w, "go_memstats_heap_alloc_bytes", "Heap bytes allocated and still in use.", nil,
+		[]metrics.Sample{{Value: float64(mem.HeapAlloc)}})
+	metrics.WriteGauge(w, "process_start_time_seconds", "Start time of the process since unix epoch in seconds.", nil,
+		[]metrics.Sample{{Value: float64(startTime.Unix())}})
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/validation"
+)
+
+const instanceMigrationJob = "instance_migration"
+
+// migrationClient talks to the remote instance; exports and files can be large
+var migrationClient = &http.Client{Timeout: 5 * time.Minute, Transport: fetchTransport("migration")}
+
+// instanceMigrationPayload is the job payload of an import from another instance.
+// The token is removed from the payload once the job has run.
+type instanceMigrationPayload struct {
+	BaseURL string `json:"base_url"`
+	Token   string `json:"token,omitempty"`
+}
+
+// ApiExportHandler returns all of the token owner's data as a JSON backup,
+// which another instance reads to migrate it, or the items the filter of
+// ExportDataHandler picks
+func ApiExportHandler(w http.ResponseWriter, r *http.Request) {
+	var v validation.Validator
+	filter := parseExportFilter(&v, r.URL.Query())
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	data, err := collectExportData(getUserID(r), func(int) {})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filter.apply(data)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeJSONExport(w, data); err != nil {
+		log.Printf("Failed to write API export: %v", err)
+	}
+}
+
+// StartMigrationHandler checks the remote instance's URL and API token
+// (form values base_url and token) and queues the import
+func StartMigrationHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	baseURL, err := parseInstanceURL(r.FormValue("base_url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := strings.TrimSpace(r.FormValue("token"))
+	if token == "" {
+		http.Error(w, "API token is required", http.StatusBadRequest)
+		return
+	}
+
+	// Fail early on a wrong address or token instead of in the background
+	if err := checkInstance(r.Context(), baseURL, token); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if recent, err := database.GetRecentJobs(userID, instanceMigrationJob, 1); err == nil && len(recent) > 0 {
+		if job := recent[0]; job.Status == database.JobPending || job.Status == database.JobRunning {
+			http.Error(w, "An import from another instance is already running", http.StatusConflict)
+			return
+		}
+	}
+
+	payload, _ := json.Marshal(instanceMigrationPayload{BaseURL: baseURL, Token: token})
+	jobID, err := enqueueJob(userID, instanceMigrationJob, "", string(payload))
+	if err != nil {
+		log.Printf("Failed to enqueue migration for user %d: %v", userID, err)
+		http.Error(w, "Failed to start import", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"job_id": jobID, "status": database.JobPending})
+}
+
+// MigrationJobsHandler lists the user's recent imports from other instances.
+// The settings page polls it.
+func MigrationJobsHandler(w http.ResponseWriter, r *http.Request) {
+	jobs, err := database.GetRecentJobs(getUserID(r), instanceMigrationJob, 5)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type migrationStatus struct {
+		JobID     int64  `json:"job_id"`
+		BaseURL   string `json:"base_url"`
+		Status    string `json:"status"`
+		Progress  int    `json:"progress"`
+		Result    string `json:"result,omitempty"`
+		Error     string `json:"error,omitempty"`
+		CreatedAt string `json:"created_at"`
+	}
+	results := []migrationStatus{}
+	for _, job := range jobs {
+		var p instanceMigrationPayload
+		json.Unmarshal([]byte(job.Payload), &p)
+		s := migrationStatus{JobID: job.ID, BaseURL: p.BaseURL, Status: job.Status, Progress: job.Progress, CreatedAt: job.CreatedAt}
+		switch job.Status {
+		case database.JobDone:
+			s.Result = job.Result
+		case database.JobFailed:
+			s.Error = job.Result
+		}
+		results = append(results, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// runInstanceMigrationJob pulls the user's data from the remote instance's
+// export API and recreates it locally, copying uploaded files along
+func runInstanceMigrationJob(job *database.Job) (string, error) {
+	var p instanceMigrationPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		return "", fmt.Errorf("invalid payload: %w", err)
+	}
+	defer func() {
+		// The token grants full access to the remote account, don't keep it around
+		payload, _ := json.Marshal(instanceMigrationPayload{BaseURL: p.BaseURL})
+		database.SetJobPayload(job.ID, string(payload))
+	}()
+	if p.Token == "" {
+		return "", fmt.Errorf("the API token is no longer available, start the import again")
+	}
+
+	ctx := withUserID(context.Background(), job.UserID)
+	req, err := http.NewRequestWithContext(ctx, "GET", p.BaseURL+"/api/v1/export", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	resp, err := migrationClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach %s: %w", p.BaseURL, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("the remote instance has no export API, update it first")
+	default:
+		return "", fmt.Errorf("remote export failed: %s", resp.Status)
+	}
+
+	data, err := decodeImportData(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("invalid export from remote instance: %w", err)
+	}
+	database.SetJobProgress(job.ID, 10)
+
+	// Files are shared between items (e.g. a recipe thumbnail is also one of
+	// its images), so copy each one only once
+	copied := map[string]string{}
+	failedFiles := 0
+	fetchFile := func(path string) string {
+		if local, ok := copied[path]; ok {
+			return local
+		}
+		local, err := copyRemoteUpload(ctx, p.BaseURL, path)
+		if err != nil {
+			log.Printf("Migration: Failed to copy %s from %s: %v", path, p.BaseURL, err)
+			failedFiles++
+		}
+		copied[path] = local
+		return local
+	}
+
+	report, err := importBackup(job.UserID, data, importOptions{
+		Mode:      importMerge,
+		FetchFile: fetchFile,
+		Progress: func(percent int) {
+			database.SetJobProgress(job.ID, 10+percent*90/100)
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	database.SetJobProgress(job.ID, 100)
+
+	result := fmt.Sprintf("%s. Copied %d files", report, len(copied)-failedFiles)
+	if failedFiles > 0 {
+		result += fmt.Sprintf(", %d could not be copied", failedFiles)
+	}
+	return result, nil
+}
+
+// remoteUploadURL returns the URL of an uploaded file on the remote instance
+// at baseURL. The path comes from the remote export, so it must name a file
+// in its uploads folder and must not lead anywhere else.
+func remoteUploadURL(baseURL, path string) (string, error) {
+	if _, ok := uploadedFileName(path); !ok {
+		return "", fmt.Errorf("invalid path")
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(baseURL + path)
+	if err != nil || u.Scheme != base.Scheme || u.Host != base.Host || u.User != nil {
+		return "", fmt.Errorf("invalid path")
+	}
+	return u.String(), nil
+}
+
+// copyRemoteUpload downloads an uploaded file from the remote instance into
+// the local uploads folder and returns its local path. Files are capped at
+// the size of a local upload.
+func copyRemoteUpload(ctx context.Context, baseURL, path string) (string, error) {
+	fileURL, err := remoteUploadURL(baseURL, path)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := migrationClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	uploadDir := datadir.Uploads()
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return "", err
+	}
+	// Keep the remote file name so a repeated import recognizes the items it
+	// already copied; upload names are timestamps, so clashes are rare
+	filename := filepath.Base(path)
+	savePath := filepath.Join(uploadDir, filename)
+	if _, err := os.Stat(savePath); err == nil {
+		filename = fmt.Sprintf("%d%s", time.Now().UnixNano(), filepath.Ext(path))
+		savePath = filepath.Join(uploadDir, filename)
+	}
+	out, err := os.Create(savePath)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(out, io.LimitReader(resp.Body, maxMediaUpload+1))
+	if err == nil && n > maxMediaUpload {
+		err = fmt.Errorf("file is larger than %d MB", maxMediaUpload>>20)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(savePath)
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(savePath)
+		return "", err
+	}
+	return "/static/uploads/" + filename, nil
+}
+
+// parseInstanceURL validates the base URL of another instance and returns it
+// without a trailing slash
+func parseInstanceURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("enter the instance address as http(s)://host[:port]")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("the instance address must not contain a query or fragment")
+	}
+	return strings.TrimRight(u.Scheme+"://"+u.Host+u.Path, "/"), nil
+}
+
+// checkInstance makes sure the remote instance is reachable and accepts the token
+func checkInstance(ctx context.Context, baseURL, token string) error {
+	// /api/health doesn't need a token, the tag list does and is cheap
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := &http.Client{Timeout: 15 * time.Second, Transport: fetchTransport("migration")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not reach %s", baseURL)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("the remote instance rejected the API token")
+	default:
+		return fmt.Errorf("%s does not look like an InfoKeep instance (%s)", baseURL, resp.Status)
+	}
+}
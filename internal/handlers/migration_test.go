@@ -0,0 +1,45 @@
+package handlers
+
+import "testing"
+
+func TestParseInstanceURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"https://keep.example.com", "https://keep.example.com", true},
+		{" https://keep.example.com/ ", "https://keep.example.com", true},
+		{"http://10.0.0.5:8080/infokeep/", "http://10.0.0.5:8080/infokeep", true},
+		{"keep.example.com", "", false},
+		{"ftp://keep.example.com", "", false},
+		{"https://keep.example.com/?next=/", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseInstanceURL(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseInstanceURL(%q) = %q, %v; want %q, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRemoteUploadURL(t *testing.T) {
+	tests := []struct {
+		base, path, want string
+	}{
+		{"https://keep.example.com", "/static/uploads/1.png", "https://keep.example.com/static/uploads/1.png"},
+		{"http://10.0.0.5:8080/infokeep", "/static/uploads/1.png", "http://10.0.0.5:8080/infokeep/static/uploads/1.png"},
+		{"https://keep.example.com", "@evil.host/x", ""},
+		{"https://keep.example.com", ".evil.host/x", ""},
+		{"https://keep.example.com", "/static/uploads/../../etc/passwd", ""},
+		{"https://keep.example.com", "/static/uploads/a/b.png", ""},
+		{"https://keep.example.com", "/static/rated_items/1.png", ""},
+		{"https://keep.example.com", "/static/uploads/", ""},
+	}
+	for _, tt := range tests {
+		got, err := remoteUploadURL(tt.base, tt.path)
+		if (err == nil) != (tt.want != "") || got != tt.want {
+			t.Errorf("remoteUploadURL(%q, %q) = %q, %v; want %q", tt.base, tt.path, got, err, tt.want)
+		}
+	}
+}
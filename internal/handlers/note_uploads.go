@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/models"
+	"infokeep/internal/validation"
+)
+
+// Images pasted or dropped into the note editor are uploaded at once and
+// the Markdown that shows them is put in the note's content. The image of a
+// saved note is attached to it; the image of a new note waits in
+// note_uploads until the note is saved, when it becomes an attachment of the
+// note that shows it. Either way it is removed with the note when the note
+// is purged from the trash, and the images of notes that were never saved
+// are removed by the note_upload_cleanup task. The Markdown links the image
+// by its path from the root, /static/uploads/..., and BASE_PATH is only put
+// in front of it when the note's card shows the image, so notes keep
+// working when the app moves to another path.
+
+// noteUploadMaxAge is how many hours the image of a note that is not saved
+// is kept
+const noteUploadMaxAge = 24
+
+// noteImageRef finds the uploaded images a note's Markdown shows
+var noteImageRef = regexp.MustCompile(`!\[[^\]]*\]\((/static/uploads/[^)\s]+)\)`)
+
+// noteImageExts are the images a note can show, by their sniffed type
+var noteImageExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// NoteUploadHandler stores an image pasted into a note, sent as multipart
+// "file", with "note_id" when the note is saved already. It returns the
+// image's url, with BASE_PATH, and the Markdown to put in the note.
+func NoteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaUpload+(1<<20))
+	if err := r.ParseMultipartForm(maxMediaUpload); err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var noteID int64
+	if s := r.FormValue("note_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid note ID", http.StatusBadRequest)
+			return
+		}
+		if _, err := database.GetNote(userID, id); err != nil {
+			http.Error(w, "Note not found", http.StatusNotFound)
+			return
+		}
+		noteID = id
+	}
+
+	var v validation.Validator
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		v.Add("file", "is required")
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	mimeType := http.DetectContentType(head[:n])
+	ext, ok := noteImageExts[mimeType]
+	if !ok {
+		v.Add("file", "must be a PNG, JPEG, GIF or WebP image")
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
+	savePath := filepath.Join(datadir.Uploads(), fileName)
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		http.Error(w, "Failed to create uploads directory", http.StatusInternalServerError)
+		return
+	}
+	out, err := os.Create(savePath)
+	if err != nil {
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+	size, err := io.Copy(out, file)
+	out.Close()
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+
+	name := strings.TrimSpace(filepath.Base(header.Filename))
+	if len(name) > maxTitleLength {
+		name = name[:maxTitleLength]
+	}
+	if name == "" || name == "." {
+		name = fileName
+	}
+	relPath := "/static/uploads/" + fileName
+	if noteID != 0 {
+		_, err = database.AddAttachment(models.Attachment{ItemID: noteID, FileName: name, FilePath: relPath, MimeType: mimeType, Size: size})
+	} else {
+		err = database.AddNoteUpload(userID, relPath, name, mimeType, size)
+	}
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"url":      appURL(relPath),
+		"markdown": fmt.Sprintf("![%s](%s)", markdownAlt(name), relPath),
+	})
+}
+
+// markdownAlt returns the alt text of an image named name, which is its
+// name without extension or the brackets that would end the text
+func markdownAlt(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.NewReplacer("[", "", "]", "", "\n", " ").Replace(name)
+}
+
+// noteImages returns the paths of the uploaded images a note's content shows
+func noteImages(content string) []string {
+	var paths []string
+	for _, m := range noteImageRef.FindAllStringSubmatch(content, -1) {
+		paths = append(paths, m[1])
+	}
+	return paths
+}
+
+// claimNoteUploads attaches the images pasted into a new note that its
+// content shows to the note once it is saved
+func claimNoteUploads(userID, noteID int64, content string) {
+	if err := database.ClaimNoteUploads(userID, noteID, content); err != nil {
+		log.Printf("Attaching the images of note %d: %v", noteID, err)
+	}
+}
+
+// removeStaleNoteUploads is the note_upload_cleanup task
+func removeStaleNoteUploads() (string, error) {
+	files, err := database.DeleteStaleNoteUploads(noteUploadMaxAge)
+	if err != nil {
+		return "", err
+	}
+	removeItemFiles(files)
+	return fmt.Sprintf("Deleted %d images of notes that were not saved", len(files)), nil
+}
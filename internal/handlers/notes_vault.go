@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/models"
+)
+
+// The vault export is the notes as a folder that Obsidian opens as a vault
+// and Logseq as a graph: each note is pages/<title>.md with YAML front
+// matter of its title, tags and dates, and the files attached to notes,
+// such as the images pasted into them, are in assets/, which the notes link
+// to. Unlike the notes export (gitnotes), whose files are named by ID to be
+// committed to git, it is meant to be opened in another app and is not read
+// back.
+//
+//	pages/Shopping.md
+//	---
+//	title: "Shopping"
+//	tags:
+//	  - "home"
+//	created: 2024-05-01T10:00:00
+//	updated: 2024-05-02T08:30:00
+//	---
+//
+//	Milk, eggs
+//	![receipt](../assets/1714557600000000000.png)
+
+const (
+	vaultPagesDir  = "pages"
+	vaultAssetsDir = "assets"
+	// vaultMaxName caps the runes of a file name taken from a title
+	vaultMaxName = 100
+)
+
+// vaultUnsafe are the characters file systems or the apps' links don't
+// allow in a file name
+var vaultUnsafe = strings.NewReplacer(
+	"/", " ", "\\", " ", ":", " ", "*", " ", "?", " ", "\"", " ", "<", " ", ">", " ",
+	"|", " ", "#", " ", "^", " ", "[", " ", "]", " ", "\n", " ", "\r", " ", "\t", " ",
+)
+
+// vaultFileName returns the file name of a note titled title, one not in
+// taken, which it is added to. Names are compared ignoring case, as they are
+// on macOS and Windows.
+func vaultFileName(title string, taken map[string]bool) string {
+	name := strings.Join(strings.Fields(vaultUnsafe.Replace(title)), " ")
+	name = strings.Trim(truncateRunes(name, vaultMaxName), ". ")
+	if name == "" {
+		name = "Untitled"
+	}
+	file := name + ".md"
+	for i := 2; taken[strings.ToLower(file)]; i++ {
+		file = fmt.Sprintf("%s (%d).md", name, i)
+	}
+	taken[strings.ToLower(file)] = true
+	return file
+}
+
+// vaultTag returns a tag as Obsidian takes it, without spaces
+func vaultTag(tag string) string {
+	return strings.Join(strings.Fields(strings.TrimPrefix(tag, "#")), "-")
+}
+
+// vaultDate returns a date as stored as the local date and time the apps
+// read, or as it is if it isn't one
+func vaultDate(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.Local().Format("2006-01-02T15:04:05")
+}
+
+// vaultPage returns the Markdown file of a note, with its content as given.
+// Go's quoted strings are YAML's double-quoted ones.
+func vaultPage(n models.Note, content string) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(n.Title))
+	tags := make([]string, 0, len(n.Tags))
+	for _, t := range n.Tags {
+		if t = vaultTag(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	sort.Strings(tags)
+	if len(tags) == 0 {
+		b.WriteString("tags: []\n")
+	} else {
+		b.WriteString("tags:\n")
+		for _, t := range tags {
+			fmt.Fprintf(&b, "  - %s\n", strconv.Quote(t))
+		}
+	}
+	if n.CreatedAt != "" {
+		fmt.Fprintf(&b, "created: %s\n", vaultDate(n.CreatedAt))
+	}
+	if n.UpdatedAt != "" {
+		fmt.Fprintf(&b, "updated: %s\n", vaultDate(n.UpdatedAt))
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(content)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// writeVaultExport writes the notes of an export, and the files attached to
+// them, as a ZIP of a vault
+func writeVaultExport(w io.Writer, userID int64, d *exportData) error {
+	attachments, err := database.GetAttachments(userID, 0)
+	if err != nil {
+		return err
+	}
+	byNote := map[int64][]models.Attachment{}
+	for _, a := range attachments {
+		byNote[a.ItemID] = append(byNote[a.ItemID], a)
+	}
+
+	notes := make([]models.Note, len(d.Notes))
+	copy(notes, d.Notes)
+	sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+
+	zw := zip.NewWriter(w)
+	taken := map[string]bool{}
+	for _, n := range notes {
+		content := n.Content
+		for _, a := range byNote[n.ID] {
+			name, ok := uploadedFileName(a.FilePath)
+			if !ok {
+				continue
+			}
+			asset := vaultAssetsDir + "/" + name
+			if err := addVaultAsset(zw, asset, name); err != nil {
+				return err
+			}
+			content = strings.ReplaceAll(content, a.FilePath, "../"+asset)
+		}
+		f, err := zw.Create(path.Join(vaultPagesDir, vaultFileName(n.Title, taken)))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, vaultPage(n, content)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// addVaultAsset copies the uploaded file name into the archive as asset. A
+// file that is gone is left out.
+func addVaultAsset(zw *zip.Writer, asset, name string) error {
+	src, err := os.Open(filepath.Join(datadir.Uploads(), name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := zw.Create(asset)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// Browsers that find the <link rel="search"> of layout.html offer to add
+// infokeep as a search engine. Searching from the address bar then opens
+// /search?q=..., which SearchHandler answers with a full page rather than
+// the fragment the search bar swaps in.
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Method   string `xml:"method,attr"`
+	Template string `xml:"template,attr"`
+}
+
+type openSearchImage struct {
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+	Type   string `xml:"type,attr"`
+	URL    string `xml:",chardata"`
+}
+
+type openSearchDescription struct {
+	XMLName       xml.Name        `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	ShortName     string          `xml:"ShortName"`
+	Description   string          `xml:"Description"`
+	InputEncoding string          `xml:"InputEncoding"`
+	Image         openSearchImage `xml:"Image"`
+	URLs          []openSearchURL `xml:"Url"`
+}
+
+// OpenSearchHandler serves the OpenSearch description document. It is
+// public, since browsers fetch it without the session cookie.
+func OpenSearchHandler(w http.ResponseWriter, r *http.Request) {
+	base := getBaseURL(r)
+	doc := openSearchDescription{
+		ShortName:     "InfoKeep",
+		Description:   "Search your InfoKeep bookmarks, notes, lists and recipes",
+		InputEncoding: "UTF-8",
+		Image:         openSearchImage{Width: 16, Height: 16, Type: "image/svg+xml", URL: base + "/static/favicon.svg"},
+		URLs: []openSearchURL{
+			{Type: "text/html", Method: "get", Template: base + "/search?q={searchTerms}"},
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/opensearchdescription+xml")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(doc)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenSearchHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://keep.example/opensearch.xml", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	OpenSearchHandler(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/opensearchdescription+xml" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<OpenSearchDescription xmlns="http://a9.com/-/spec/opensearch/1.1/">`,
+		`template="https://keep.example/search?q={searchTerms}"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("description lacks %s:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"infokeep/internal/database"
+)
+
+// Handlers that take an item ID from the URL or form check it with one of the
+// require*Ownership helpers before reading or changing anything. Items of
+// other users get the same 404 as items that don't exist, so IDs can't be
+// probed to learn what other users have.
+
+// requireOwnership writes a 404 and returns false unless the item exists and
+// belongs to userID
+func requireOwnership(w http.ResponseWriter, itemID, userID int64) bool {
+	owner, err := database.GetItemOwner(itemID)
+	return checkOwner(w, owner, userID, err)
+}
+
+// requireListItemOwnership is requireOwnership for a checklist entry. It
+// returns the ID of the checklist the entry is on.
+func requireListItemOwnership(w http.ResponseWriter, id, userID int64) (int64, bool) {
+	listID, owner, err := database.GetListItemOwner(id)
+	return listID, checkOwner(w, owner, userID, err)
+}
+
+// requireRatedListItemOwnership is requireOwnership for a rated list entry.
+// It returns the ID of the rated list the entry is on.
+func requireRatedListItemOwnership(w http.ResponseWriter, id, userID int64) (int64, bool) {
+	listID, owner, err := database.GetRatedListItemOwner(id)
+	return listID, checkOwner(w, owner, userID, err)
+}
+
+func checkOwner(w http.ResponseWriter, owner, userID int64, err error) bool {
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to look up owner: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if err == sql.ErrNoRows || owner != userID {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
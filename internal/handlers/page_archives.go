@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/scraper"
+
+	"golang.org/x/net/html"
+)
+
+// A bookmark's page can be archived, when it is added or later from its
+// card: the page is fetched, its article is cleaned down to text markup
+// (scraper.ArticleHTML) and kept with its text, which full-text search
+// finds. /bookmarks/{id}/archive shows the copy, so the link keeps working
+// after the page changes or goes away. Archiving again replaces the copy.
+
+var pageArchiveClient = &http.Client{Timeout: 30 * time.Second, Transport: fetchTransport("archive")}
+
+// archivePage fetches the page at pageURL and stores its cleaned article as
+// the archived copy of the bookmark itemID
+func archivePage(ctx context.Context, itemID int64, pageURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	resp, err := pageArchiveClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("page returned %s", resp.Status)
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return fmt.Errorf("not an HTML page")
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, bookmarkMaxPage))
+	if err != nil {
+		return err
+	}
+
+	text := scraper.ArticleText(doc)
+	if text == "" {
+		return fmt.Errorf("the page has no text")
+	}
+	title := strings.TrimSpace(scraper.Extract(doc, resp.Request.URL).Title)
+	return database.SavePageArchive(itemID, resp.Request.URL.String(), truncateRunes(title, maxTitleLength),
+		scraper.ArticleHTML(doc, resp.Request.URL), text)
+}
+
+// archiveNewBookmark archives the page of a bookmark that was just added.
+// The bookmark is kept when that fails; it can be archived again from its
+// card.
+func archiveNewBookmark(ctx context.Context, userID, itemID int64, pageURL string) {
+	if err := archivePage(withUserID(ctx, userID), itemID, pageURL); err != nil {
+		log.Printf("Archiving the page of bookmark %d: %v", itemID, err)
+	}
+}
+
+// PageArchiveHandler archives a copy of a bookmark's page (POST), or shows
+// the copy (GET): as a page, or as JSON to the API and to requests that
+// accept JSON. HTMX gets the bookmark's card back from a POST.
+func PageArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	bookmark, err := database.GetBookmark(userID, id)
+	if err != nil {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := archivePage(withUserID(r.Context(), userID), id, bookmark.CanonicalURL); err != nil {
+			http.Error(w, "The page could not be archived: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("HX-Request") != "" {
+			renderBookmarkCard(w, userID, id)
+			return
+		}
+	}
+
+	archive, err := database.GetPageArchive(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "The page was not archived", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPost || strings.HasPrefix(r.URL.Path, "/api/") ||
+		strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(archive)
+		return
+	}
+	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
+	RenderTemplate(w, "page_archive.html", map[string]interface{}{
+		"Bookmark": bookmark,
+		"Archive":  archive,
+		// Only text markup and http(s) links are left of the page
+		"Content":   template.HTML(archive.HTML),
+		"Tags":      tagsWithCounts,
+		"ActiveTag": "",
+	})
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"infokeep/internal/database"
+)
+
+// List pages load their items a page at a time, each page ending in a
+// "Load more" button that fetches the next one. ?page= (counted from 1) and
+// ?per_page= pick the page; the JSON list endpoints take the same parameters
+// and report how many items there are in X-Total-Count.
+const (
+	defaultPerPage = 50
+	maxPerPage     = 200
+)
+
+// pagination is the page of a list a request asks for, and once the list is
+// loaded, how many items it has on all pages
+type pagination struct {
+	Page    int
+	PerPage int
+	Total   int
+}
+
+// parsePagination reads ?page= and ?per_page=, falling back to the first
+// page of the user's items per page (defaultPerPage when signed out) for
+// missing or invalid values
+func parsePagination(r *http.Request) pagination {
+	p := pagination{Page: 1, PerPage: defaultPerPage}
+	if userID := getUserID(r); userID != 0 {
+		p.PerPage = userPerPage(userID)
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && n > 0 {
+		p.Page = n
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("per_page")); err == nil && n > 0 {
+		p.PerPage = min(n, maxPerPage)
+	}
+	return p
+}
+
+// apiPage is the page a JSON list endpoint returns: the one asked for, or
+// every item for clients that don't ask for a page, in the order ?sort=
+// asks for or else the user's default sort
+func apiPage(r *http.Request) database.Page {
+	q := r.URL.Query()
+	var page database.Page
+	if q.Has("page") || q.Has("per_page") {
+		page = parsePagination(r).dbPage()
+	}
+	if sort, ok := database.ParseSort(q.Get("sort")); ok {
+		page.Sort = sort
+	} else if userID := getUserID(r); userID != 0 {
+		page.Sort = userDefaultSort(userID)
+	}
+	return page
+}
+
+// sortSetting is the user setting holding the order a list is shown in
+func sortSetting(name string) string {
+	return "sort:" + name
+}
+
+// sortOption is an entry of the sort menu on the list pages
+type sortOption struct {
+	Sort  database.Sort
+	Label string
+}
+
+var sortOptions = []sortOption{
+	{database.SortCreated, "Newest first"},
+	{database.SortUpdated, "Recently changed"},
+	{database.SortTitle, "Title"},
+	{database.SortManual, "Manual order"},
+}
+
+// listSort is the order the named list is shown in: the one ?sort= asks
+// for, which is remembered for the user's next visit, or the one they
+// picked last time, or the user's default sort
+func listSort(r *http.Request, userID int64, name string) database.Sort {
+	if sort, ok := database.ParseSort(r.URL.Query().Get("sort")); ok {
+		if database.GetUserSetting(userID, sortSetting(name)) != string(sort) {
+			if err := database.SetUserSetting(userID, sortSetting(name), string(sort)); err != nil {
+				log.Printf("Failed to save the sort order of %s for user %d: %v", name, userID, err)
+			}
+		}
+		return sort
+	}
+	settings := database.GetUserSettings(userID, sortSetting(name), defaultSortSetting)
+	if sort, ok := database.ParseSort(settings[sortSetting(name)]); ok {
+		return sort
+	}
+	sort, _ := database.ParseSort(settings[defaultSortSetting])
+	return sort
+}
+
+func (p pagination) dbPage() database.Page {
+	return database.Page{Limit: p.PerPage, Offset: (p.Page - 1) * p.PerPage}
+}
+
+// Shown is how many items this page and the ones before it hold
+func (p pagination) Shown() int {
+	return min(p.Page*p.PerPage, p.Total)
+}
+
+func (p pagination) HasMore() bool {
+	return p.Page*p.PerPage < p.Total
+}
+
+// setTotalHeader reports the number of items on all pages to API clients
+func setTotalHeader(w http.ResponseWriter, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+}
+
+// pagedList is a list page whose items load a page at a time
+type pagedList struct {
+	fragment string
+	nav      bool // entries of a side menu rather than grid columns
+	load     func(userID int64, filter listFilter, page database.Page) (interface{}, int, error)
+}
+
+// listFilter picks the items of a list to show: those with a tag and, on
+// the media list, those of a kind (?kind=). On the bookmarks list,
+// ?kind=later shows the read-later queue and ?kind=broken the bookmarks
+// whose link failed its last check. ?archived=true shows the archived
+// items of any list instead of the others.
+type listFilter struct {
+	Tag      string
+	Kind     string
+	Archived bool
+}
+
+// archived is which items the filter picks by whether they are archived
+func (f listFilter) archived() database.Archived {
+	if f.Archived {
+		return database.OnlyArchived
+	}
+	return database.NotArchived
+}
+
+// showArchived reports whether a list request asks for archived items
+func showArchived(r *http.Request) bool {
+	return r.URL.Query().Get("archived") == "true"
+}
+
+// apiArchived is which items a JSON list endpoint returns: by default those
+// not archived, with ?archived=true the archived ones
+func apiArchived(r *http.Request) database.Archived {
+	return listFilter{Archived: showArchived(r)}.archived()
+}
+
+// bookmarkKinds are the bookmarks a ?kind= of the bookmarks list picks
+var bookmarkKinds = map[string]database.BookmarkKind{
+	readLaterKind:   database.ReadLaterBookmarks,
+	brokenLinksKind: database.BrokenBookmarks,
+}
+
+// pagedLists are keyed by the path the list is served at
+var pagedLists = map[string]pagedList{
+	"bookmarks": {"bookmark_list.html", false, func(userID int64, filter listFilter, page database.Page) (interface{}, int, error) {
+		return database.GetBookmarksPage(userID, filter.Tag, bookmarkKinds[filter.Kind], filter.archived(), page)
+	}},
+	"notes": {"note_list.html", false, func(userID int64, filter listFilter, page database.Page) (interface{}, int, error) {
+		return database.GetNotesPage(userID, filter.Tag, filter.archived(), page)
+	}},
+	"drawings": {"drawing_list.html", false, func(userID int64, filter listFilter, page database.Page) (interface{}, int, error) {
+		return database.GetDrawingsPage(userID, filter.Tag, filter.archived(), page)
+	}},
+	"rated-lists": {"rated_list_nav.html", true, func(userID int64, filter listFilter, page database.Page) (interface{}, int, error) {
+		return database.GetRatedListsPage(userID, filter.Tag, filter.archived(), page)
+	}},
+	"lists": {"list_nav.html", true, func(userID int64, filter listFilter, page database.Page) (interface{}, int, error) {
+		return database.GetListsPage(userID, filter.Tag, filter.archived(), page)
+	}},
+	"media": {"media_grid.html", false, func(userID int64, filter listFilter, page database.Page) (interface{}, int, error) {
+		return database.GetMediaPage(userID, filter.Tag, filter.Kind, filter.archived(), page)
+	}},
+	"recipes": {"recipe_list.html", false, func(userID int64, filter listFilter, page database.Page) (interface{}, int, error) {
+		return database.GetRecipesPage(userID, filter.Tag, filter.archived(), page)
+	}},
+	"cookbooks": {"cookbook_list.html", false, func(userID int64, filter listFilter, page database.Page) (interface{}, int, error) {
+		return database.GetCookbooksPage(userID, filter.Tag, filter.archived(), page)
+	}},
+}
+
+// sortMenu is what sort_select.html needs to show the sort menu of the
+// named list page: the current order, the others, and the URL that loads the
+// list with the page's filters. Side menus get a small one.
+func sortMenu(r *http.Request, userID int64, name string) map[string]interface{} {
+	q := url.Values{}
+	for _, key := range []string{"tag", "kind", "archived"} {
+		if v := r.URL.Query().Get(key); v != "" {
+			q.Set(key, v)
+		}
+	}
+	return map[string]interface{}{
+		"Current": listSort(r, userID, name),
+		"Options": sortOptions,
+		"URL":     "/" + name + "?" + q.Encode(),
+		"Small":   pagedLists[name].nav,
+	}
+}
+
+// renderListPage renders the page of the named list the request asks for,
+// followed by the button that loads the next page
+func renderListPage(w http.ResponseWriter, r *http.Request, name string, userID int64, tagFilter string) {
+	list := pagedLists[name]
+	p := parsePagination(r)
+	filter := listFilter{Tag: tagFilter, Kind: r.URL.Query().Get("kind"), Archived: showArchived(r)}
+	page := p.dbPage()
+	page.Sort = listSort(r, userID, name)
+	items, total, err := list.load(userID, filter, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.Total = total
+
+	RenderFragment(w, list.fragment, items)
+	if !p.HasMore() {
+		return
+	}
+	q := url.Values{}
+	if filter.Tag != "" {
+		q.Set("tag", filter.Tag)
+	}
+	if filter.Kind != "" {
+		q.Set("kind", filter.Kind)
+	}
+	if filter.Archived {
+		q.Set("archived", "true")
+	}
+	if page.Sort != database.SortCreated {
+		q.Set("sort", string(page.Sort))
+	}
+	q.Set("page", strconv.Itoa(p.Page+1))
+	if p.PerPage != defaultPerPage {
+		q.Set("per_page", strconv.Itoa(p.PerPage))
+	}
+	RenderFragment(w, "pager.html", map[string]interface{}{
+		"NextURL": "/" + name + "?" + q.Encode(),
+		"Shown":   p.Shown(),
+		"Total":   p.Total,
+		"Nav":     list.nav,
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"infokeep/internal/database"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query string
+		want  database.Page
+	}{
+		{"", database.Page{Limit: defaultPerPage, Offset: 0}},
+		{"?page=3", database.Page{Limit: defaultPerPage, Offset: 2 * defaultPerPage}},
+		{"?page=2&per_page=10", database.Page{Limit: 10, Offset: 10}},
+		{"?per_page=100000", database.Page{Limit: maxPerPage, Offset: 0}},
+		{"?page=0&per_page=-5", database.Page{Limit: defaultPerPage, Offset: 0}},
+		{"?page=abc", database.Page{Limit: defaultPerPage, Offset: 0}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/bookmarks"+tt.query, nil)
+		if got := parsePagination(r).dbPage(); got != tt.want {
+			t.Errorf("parsePagination(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAPIPage(t *testing.T) {
+	if got := apiPage(httptest.NewRequest("GET", "/api/lists", nil)); got != (database.Page{}) {
+		t.Errorf("apiPage without parameters = %+v, want every item", got)
+	}
+	if got := apiPage(httptest.NewRequest("GET", "/api/lists?per_page=5", nil)); got != (database.Page{Limit: 5}) {
+		t.Errorf("apiPage(per_page=5) = %+v, want the first 5 items", got)
+	}
+	if got := apiPage(httptest.NewRequest("GET", "/api/lists?sort=title&per_page=5", nil)); got != (database.Page{Limit: 5, Sort: database.SortTitle}) {
+		t.Errorf("apiPage(sort=title) = %+v, want the first 5 items by title", got)
+	}
+	if got := apiPage(httptest.NewRequest("GET", "/api/lists?sort=size", nil)); got != (database.Page{}) {
+		t.Errorf("apiPage(sort=size) = %+v, want every item in the default order", got)
+	}
+}
+
+func TestPaginationHasMore(t *testing.T) {
+	tests := []struct {
+		p     pagination
+		more  bool
+		shown int
+	}{
+		{pagination{Page: 1, PerPage: 50, Total: 120}, true, 50},
+		{pagination{Page: 2, PerPage: 50, Total: 120}, true, 100},
+		{pagination{Page: 3, PerPage: 50, Total: 120}, false, 120},
+		{pagination{Page: 1, PerPage: 50, Total: 50}, false, 50},
+		{pagination{Page: 1, PerPage: 50, Total: 0}, false, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.p.HasMore(); got != tt.more {
+			t.Errorf("%+v HasMore() = %v, want %v", tt.p, got, tt.more)
+		}
+		if got := tt.p.Shown(); got != tt.shown {
+			t.Errorf("%+v Shown() = %d, want %d", tt.p, got, tt.shown)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordPolicy is the server-side password strength requirement. It can be
+// tuned with PASSWORD_MIN_LENGTH and PASSWORD_MIN_ENTROPY (bits).
+type PasswordPolicy struct {
+	MinLength  int
+	MinEntropy float64
+}
+
+var passwordPolicy = loadPasswordPolicy()
+
+func loadPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{MinLength: 8, MinEntropy: 40}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && v > 0 {
+		policy.MinLength = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("PASSWORD_MIN_ENTROPY"), 64); err == nil && v >= 0 {
+		policy.MinEntropy = v
+	}
+	return policy
+}
+
+// passwordEntropy estimates the strength of a password in bits from the size
+// of the character classes it uses. Immediately repeated characters do not
+// add to the length, so "aaaaaaaa" scores like "a".
+func passwordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	length := 0
+	var prev rune = -1
+	for _, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c < unicode.MaxASCII && unicode.IsPrint(c):
+			symbol = true
+		default:
+			other = true
+		}
+		if c != prev {
+			length++
+		}
+		prev = c
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(length) * math.Log2(float64(pool))
+}
+
+// Validate records an error for field on v if the password breaks one of
+// the policy's rules, naming the first one it breaks
+func (p PasswordPolicy) Validate(v *validation.Validator, field, password, username string) {
+	switch {
+	case len([]rune(password)) < p.MinLength:
+		v.Add(field, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	case username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)):
+		v.Add(field, "must not contain your username")
+	case passwordEntropy(password) < p.MinEntropy:
+		v.Add(field, "is too easy to guess: use a longer password or mix upper and lower case letters, digits and symbols")
+	}
+}
+
+// ChangePasswordHandler updates the signed-in user's password. Other sessions
+// are signed out. Validation failures are returned as {"errors": [...]}.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	currentPassword := r.FormValue("current_password")
+	newPassword := r.FormValue("new_password")
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	var v validation.Validator
+	if err := bcrypt.CompareHashAndPassword([]byte(user["password_hash"].(string)), []byte(currentPassword)); err != nil {
+		v.Add("current_password", "is incorrect")
+	}
+	passwordPolicy.Validate(&v, "new_password", newPassword, user["username"].(string))
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID := ""
+	if cookie, err := r.Cookie("session_id"); err == nil {
+		sessionID = cookie.Value
+	}
+	if err := database.UpdateUserPassword(userID, string(hashedPassword), sessionID); err != nil {
+		log.Printf("Failed to update password for user %d: %v", userID, err)
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	recordAuthEvent(r, userID, database.AuthPasswordChanged)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"infokeep/internal/validation"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MinEntropy: 40}
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		want     string // part of the error, "" if the password is fine
+	}{
+		{"one character", "a", "alice", "at least 8 characters"},
+		{"lowercase word", "password", "alice", "too easy to guess"},
+		{"repeated character", "aaaaaaaaaaaaaaaa", "alice", "too easy to guess"},
+		{"contains username", "Alice-2024-secret", "alice", "your username"},
+		{"long passphrase", "correct horse battery staple", "alice", ""},
+		{"mixed classes", "Tr0ub4dor&3", "alice", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validation.Validator
+			policy.Validate(&v, "password", tt.password, tt.username)
+			got := v.Errors().Error()
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("Validate(%q) = %q, want %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
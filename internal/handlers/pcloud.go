@@ -20,7 +20,11 @@ var (
 	pcloudClientSecret = os.Getenv("PCLOUD_CLIENT_SECRET")
 )
 
-// DBPath is set from main.go so the backup scheduler knows which file to upload
+// cloudAPIClient makes the small pCloud and Google Drive API calls (tokens,
+// folders). Uploads use their own client with a longer timeout.
+var cloudAPIClient = &http.Client{Timeout: 30 * time.Second}
+
+// DBPath is set from main.go so the backup task knows which file to upload
 var DBPath string
 
 // PCloudLinkHandler redirects the user to pCloud's OAuth2 authorize page
@@ -39,7 +43,7 @@ func PCloudLinkHandler(w http.ResponseWriter, r *http.Request) {
 			scheme = "http"
 		}
 	}
-	redirectURI := fmt.Sprintf("%s://%s/settings/pcloud/callback", scheme, r.Host)
+	redirectURI := fmt.Sprintf("%s://%s%s/settings/pcloud/callback", scheme, r.Host, BasePath)
 
 	authorizeURL := fmt.Sprintf(
 		"https://my.pcloud.com/oauth2/authorize?client_id=%s&response_type=code&redirect_uri=%s",
@@ -79,7 +83,7 @@ func PCloudCallbackHandler(w http.ResponseWriter, r *http.Request) {
 			scheme = "http"
 		}
 	}
-	redirectURI := fmt.Sprintf("%s://%s/settings/pcloud/callback", scheme, r.Host)
+	redirectURI := fmt.Sprintf("%s://%s%s/settings/pcloud/callback", scheme, r.Host, BasePath)
 
 	tokenURL := fmt.Sprintf("https://%s/oauth2_token?client_id=%s&client_secret=%s&code=%s&redirect_uri=%s",
 		hostname,
@@ -89,7 +93,7 @@ func PCloudCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		url.QueryEscape(redirectURI),
 	)
 
-	resp, err := http.Get(tokenURL)
+	resp, err := cloudAPIClient.Get(tokenURL)
 	if err != nil {
 		log.Printf("pCloud token exchange failed: %v", err)
 		http.Error(w, "Failed to exchange authorization code", http.StatusInternalServerError)
@@ -126,7 +130,7 @@ func PCloudCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("pCloud account linked successfully for user %d", userID)
-	http.Redirect(w, r, "/settings?pcloud=linked", http.StatusFound)
+	redirectTo(w, r, "/settings?pcloud=linked", http.StatusFound)
 }
 
 // PCloudUnlinkHandler removes pCloud credentials
@@ -206,7 +210,8 @@ func performBackup(userID int64, accessToken, hostname string) error {
 
 	// Create a copy of the database to avoid locking issues
 	tmpPath := DBPath + ".backup"
-	if err := copyFile(DBPath, tmpPath); err != nil {
+	os.Remove(tmpPath)
+	if err := database.BackupTo(tmpPath); err != nil {
 		return fmt.Errorf("failed to copy database: %w", err)
 	}
 	defer os.Remove(tmpPath)
@@ -233,30 +238,13 @@ func performBackup(userID int64, accessToken, hostname string) error {
 }
 
 // copyFile creates a copy of src at dst
-func copyFile(src, dst string) error {
-	source, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer source.Close()
-
-	dest, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer dest.Close()
-
-	_, err = io.Copy(dest, source)
-	return err
-}
-
 // ensurePCloudFolder creates a folder in pCloud root if it doesn't exist, returns folder ID
 func ensurePCloudFolder(accessToken, hostname, folderName string) (int64, error) {
 	// First, try to list the root folder to find if it exists
 	listURL := fmt.Sprintf("https://%s/listfolder?access_token=%s&path=/",
 		hostname, url.QueryEscape(accessToken))
 
-	resp, err := http.Get(listURL)
+	resp, err := cloudAPIClient.Get(listURL)
 	if err != nil {
 		return 0, err
 	}
@@ -287,7 +275,7 @@ func ensurePCloudFolder(accessToken, hostname, folderName string) (int64, error)
 	createURL := fmt.Sprintf("https://%s/createfolder?access_token=%s&path=/%s",
 		hostname, url.QueryEscape(accessToken), url.PathEscape(folderName))
 
-	resp2, err := http.Get(createURL)
+	resp2, err := cloudAPIClient.Get(createURL)
 	if err != nil {
 		return 0, err
 	}
@@ -376,40 +364,25 @@ func uploadToPCloud(accessToken, hostname, filePath string, folderID int64, file
 	return nil
 }
 
-// StartBackupScheduler runs a background goroutine that checks for due backups every hour
-func StartBackupScheduler(dbPath string) {
-	DBPath = dbPath
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-
-	log.Println("Backup scheduler started (pCloud + Google Drive)")
-
-	// Run an initial check after a short delay
-	time.Sleep(30 * time.Second)
-	checkAndRunBackups()
-	checkAndRunGDriveBackups()
-
-	for range ticker.C {
-		checkAndRunBackups()
-		checkAndRunGDriveBackups()
-	}
-}
-
-func checkAndRunBackups() {
+// checkAndRunBackups uploads the database to pCloud for every user whose
+// backup is due and returns how many backups ran and how many of them failed
+func checkAndRunBackups() (ran, failed int, err error) {
 	users, err := database.GetAllUsersWithPCloud()
 	if err != nil {
-		log.Printf("Backup scheduler: failed to get users: %v", err)
-		return
+		return 0, 0, fmt.Errorf("failed to get pCloud users: %w", err)
 	}
 
 	for _, u := range users {
 		if isBackupDue(u) {
 			log.Printf("Backup due for user %d, starting...", u.UserID)
+			ran++
 			if err := performBackup(u.UserID, u.AccessToken, u.Hostname); err != nil {
 				log.Printf("Scheduled backup failed for user %d: %v", u.UserID, err)
+				failed++
 			}
 		}
 	}
+	return ran, failed, nil
 }
 
 func isBackupDue(u database.UserBackupInfo) bool {
@@ -445,5 +418,5 @@ func getBaseURL(r *http.Request) string {
 			scheme = "http"
 		}
 	}
-	return fmt.Sprintf("%s://%s", scheme, r.Host)
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, BasePath)
 }
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/models"
+)
+
+// personalDataVersion is bumped whenever the layout of the personal data package changes
+const personalDataVersion = "2"
+
+// personalDataReadme documents the layout of the personal data package
+const personalDataReadme = `InfoKeep personal data package
+==============================
+
+This archive contains everything InfoKeep stores about your account. All
+.json files are UTF-8 JSON; timestamps are RFC 3339 in UTC.
+
+manifest.json              Package format and version, when it was generated,
+                           the number of records per file and any uploaded
+                           files that could not be included
+account.json               Your account: username, creation date, preferences
+                           and which services are linked. No password hash.
+
+security/
+  auth_events.json         Logins, failed logins, logouts, password changes and
+                           API token changes, with IP address and user agent
+  sessions.json            Expiry times of your active sessions
+  known_devices.json       Devices (user agent and IP address) you signed in from
+  tokens.json              Whether an API token is set and its IP allowlist,
+                           linked cloud services and push notification
+                           subscriptions. Token and key values are never included.
+
+content/
+  data.json                All bookmarks, notes, drawings, lists, rated lists,
+                           recipes and media. This is the same format as the
+                           regular JSON backup and can be imported on the
+                           Settings page.
+  cookbooks.json           Cookbooks with the IDs of the recipes in them
+  reminders.json           Reminders and their schedules, reminders set on
+                           items, and the due dates of checklist entries
+  shared_links.json        Public share links you created and when they expire
+  comments.json            Comments left on your shared items, with their
+                           moderation status
+  item_log.json            The dated entries you added to your items' logs
+  attachments.json         Files attached to your items, with the names they
+                           were uploaded with
+  exports.json             Data exports that can still be downloaded
+
+activity/
+  jobs.json                Background jobs (imports, exports, migrations)
+  activity_log.json        URLs the server fetched for you and requests made with
+                           your API token, from the last 90 days
+  recent_views.json        The items you opened last and when, latest first
+
+files/                     Uploaded files (images, drawings, media, attachments),
+                           stored under the path they are referenced by in the
+                           JSON files with the leading /static/ removed, e.g.
+                           /static/uploads/123.png is files/uploads/123.png
+`
+
+// personalTokens is the token metadata in security/tokens.json
+type personalTokens struct {
+	APIToken struct {
+		Set       bool   `json:"set"`
+		Allowlist string `json:"allowlist"`
+	} `json:"api_token"`
+	PCloud struct {
+		Linked   bool   `json:"linked"`
+		Hostname string `json:"hostname,omitempty"`
+	} `json:"pcloud"`
+	GoogleDrive struct {
+		Linked bool `json:"linked"`
+	} `json:"google_drive"`
+	PushSubscriptions []personalPushSubscription `json:"push_subscriptions"`
+}
+
+type personalPushSubscription struct {
+	Endpoint  string `json:"endpoint"`
+	CreatedAt string `json:"created_at"`
+}
+
+// personalReminder is a database.Reminder without the sql.Null wrappers
+type personalReminder struct {
+	ID               int64  `json:"id"`
+	ItemID           *int64 `json:"item_id"`
+	Name             string `json:"name"`
+	Frequency        string `json:"frequency"`
+	TimeOfDay        string `json:"time_of_day"`
+	StartDate        string `json:"start_date"`
+	EndDate          string `json:"end_date,omitempty"`
+	NotificationType string `json:"notification_type"`
+	Emails           string `json:"emails,omitempty"`
+	LastTriggeredAt  string `json:"last_triggered_at,omitempty"`
+	IsPinned         bool   `json:"is_pinned"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// personalReminders is content/reminders.json
+type personalReminders struct {
+	Reminders     []personalReminder    `json:"reminders"`
+	ItemReminders []models.ItemReminder `json:"item_reminders"`
+	DueDates      []personalDueDate     `json:"checklist_due_dates"`
+}
+
+// personalDueDate is a checklist entry with a due date
+type personalDueDate struct {
+	ListID    int64  `json:"list_id"`
+	ListTitle string `json:"list_title"`
+	EntryID   int64  `json:"entry_id"`
+	Content   string `json:"content"`
+	DueDate   string `json:"due_date"`
+	Completed bool   `json:"completed"`
+}
+
+// writePersonalDataExport writes a ZIP with everything stored about a user,
+// laid out as described in personalDataReadme
+func writePersonalDataExport(w io.Writer, userID int64, data *exportData) error {
+	account, err := database.GetAccountInfo(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch account: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+	counts := map[string]int{}
+	writeJSON := func(name string, v interface{}, count int) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if count >= 0 {
+			counts[name] = count
+		}
+		return nil
+	}
+
+	f, err := zw.Create("README.txt")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, personalDataReadme); err != nil {
+		return err
+	}
+
+	if err := writeJSON("account.json", account, -1); err != nil {
+		return err
+	}
+
+	// Security
+	events, err := database.GetAuthEvents(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch auth events: %w", err)
+	}
+	if err := writeJSON("security/auth_events.json", nonNil(events), len(events)); err != nil {
+		return err
+	}
+
+	expiries, err := database.GetSessionExpiries(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch sessions: %w", err)
+	}
+	sessions := []map[string]string{}
+	for _, e := range expiries {
+		sessions = append(sessions, map[string]string{"expires_at": e})
+	}
+	if err := writeJSON("security/sessions.json", sessions, len(sessions)); err != nil {
+		return err
+	}
+
+	devices, err := database.GetKnownDevices(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch devices: %w", err)
+	}
+	if err := writeJSON("security/known_devices.json", nonNil(devices), len(devices)); err != nil {
+		return err
+	}
+
+	var tokens personalTokens
+	tokens.APIToken.Set = account.HasAPIToken
+	tokens.APIToken.Allowlist = account.APITokenAllowlist
+	tokens.PCloud.Linked = account.PCloudLinked
+	if account.PCloudLinked {
+		tokens.PCloud.Hostname = account.PCloudHostname
+	}
+	tokens.GoogleDrive.Linked = account.GDriveLinked
+	tokens.PushSubscriptions = []personalPushSubscription{}
+	subs, _ := database.GetUserPushSubscriptions(userID)
+	for _, s := range subs {
+		tokens.PushSubscriptions = append(tokens.PushSubscriptions, personalPushSubscription{Endpoint: s.Endpoint, CreatedAt: s.CreatedAt})
+	}
+	if err := writeJSON("security/tokens.json", tokens, -1); err != nil {
+		return err
+	}
+
+	// Content
+	cf, err := zw.Create("content/data.json")
+	if err != nil {
+		return err
+	}
+	if err := writeJSONExport(cf, data); err != nil {
+		return fmt.Errorf("failed to write content/data.json: %w", err)
+	}
+	counts["content/data.json"] = len(data.Bookmarks) + len(data.Notes) + len(data.Drawings) + len(data.Lists) +
+		len(data.RatedLists) + len(data.Recipes) + len(data.Media)
+
+	cookbooks, err := database.GetCookbooks(userID, "")
+	if err != nil {
+		return fmt.Errorf("failed to fetch cookbooks: %w", err)
+	}
+	for i, c := range cookbooks {
+		recipeIDs := []int64{}
+		recipes, _ := database.GetCookbookRecipes(userID, c.ID)
+		for _, rec := range recipes {
+			recipeIDs = append(recipeIDs, rec.ID)
+		}
+		cookbooks[i].RecipeIDs = recipeIDs
+	}
+	if err := writeJSON("content/cookbooks.json", nonNil(cookbooks), len(cookbooks)); err != nil {
+		return err
+	}
+
+	reminders, err := database.GetRemindersForUser(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch reminders: %w", err)
+	}
+	allReminders := personalReminders{Reminders: []personalReminder{}, DueDates: []personalDueDate{}}
+	for _, r := range reminders {
+		pr := personalReminder{
+			ID:               r.ID,
+			Name:             r.Name,
+			Frequency:        r.Frequency,
+			TimeOfDay:        r.TimeOfDay,
+			StartDate:        r.StartDate,
+			EndDate:          r.EndDate.String,
+			NotificationType: r.NotificationType,
+			Emails:           r.Emails.String,
+			LastTriggeredAt:  r.LastTriggeredAt.String,
+			IsPinned:         r.IsPinned,
+			CreatedAt:        r.CreatedAt,
+		}
+		if r.ItemID.Valid {
+			itemID := r.ItemID.Int64
+			pr.ItemID = &itemID
+		}
+		allReminders.Reminders = append(allReminders.Reminders, pr)
+	}
+	itemReminders, err := database.GetAllItemReminders(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch item reminders: %w", err)
+	}
+	allReminders.ItemReminders = nonNil(itemReminders)
+	dueItems, err := database.GetListItemDueDates(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch checklist due dates: %w", err)
+	}
+	for _, d := range dueItems {
+		allReminders.DueDates = append(allReminders.DueDates, personalDueDate{
+			ListID: d.ListID, ListTitle: d.ListTitle, EntryID: d.ID, Content: d.Content, DueDate: d.DueDate, Completed: d.Completed,
+		})
+	}
+	count := len(allReminders.Reminders) + len(allReminders.ItemReminders) + len(allReminders.DueDates)
+	if err := writeJSON("content/reminders.json", allReminders, count); err != nil {
+		return err
+	}
+
+	links, err := database.GetSharedLinksByUser(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch shared links: %w", err)
+	}
+	if err := writeJSON("content/shared_links.json", nonNil(links), len(links)); err != nil {
+		return err
+	}
+
+	comments := []database.Comment{}
+	for _, status := range []string{database.CommentPending, database.CommentApproved, database.CommentRejected} {
+		c, err := database.GetInboxComments(userID, status)
+		if err != nil {
+			return fmt.Errorf("failed to fetch comments: %w", err)
+		}
+		comments = append(comments, c...)
+	}
+	if err := writeJSON("content/comments.json", comments, len(comments)); err != nil {
+		return err
+	}
+
+	logEntries, err := database.GetLogEntries(userID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to fetch log entries: %w", err)
+	}
+	if err := writeJSON("content/item_log.json", nonNil(logEntries), len(logEntries)); err != nil {
+		return err
+	}
+
+	attachments, err := database.GetAttachments(userID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to fetch attachments: %w", err)
+	}
+	if err := writeJSON("content/attachments.json", nonNil(attachments), len(attachments)); err != nil {
+		return err
+	}
+
+	exports, _ := database.GetExportsByUser(userID)
+	if err := writeJSON("content/exports.json", nonNil(exports), len(exports)); err != nil {
+		return err
+	}
+
+	// Activity; payloads can hold credentials, e.g. of a running migration
+	jobs, err := database.GetJobsByUser(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jobs: %w", err)
+	}
+	for i := range jobs {
+		jobs[i].Payload = ""
+	}
+	if err := writeJSON("activity/jobs.json", nonNil(jobs), len(jobs)); err != nil {
+		return err
+	}
+
+	activity, err := database.GetActivity(userID, "", 0)
+	if err != nil {
+		return fmt.Errorf("failed to fetch activity log: %w", err)
+	}
+	if err := writeJSON("activity/activity_log.json", nonNil(activity), len(activity)); err != nil {
+		return err
+	}
+
+	views, err := database.GetRecentViews(userID, database.ViewsKept)
+	if err != nil {
+		return fmt.Errorf("failed to fetch recent views: %w", err)
+	}
+	if err := writeJSON("activity/recent_views.json", nonNil(views), len(views)); err != nil {
+		return err
+	}
+
+	// Files
+	missing := []string{}
+	files := personalDataFiles(data, cookbooks, attachments)
+	for _, path := range files {
+		if err := addUploadToZip(zw, path); err != nil {
+			missing = append(missing, path)
+		}
+	}
+	counts["files/"] = len(files) - len(missing)
+
+	manifest := map[string]interface{}{
+		"format":        "infokeep-personal-data",
+		"version":       personalDataVersion,
+		"generated_at":  time.Now().UTC(),
+		"user_id":       userID,
+		"counts":        counts,
+		"missing_files": missing,
+	}
+	if err := writeJSON("manifest.json", manifest, -1); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// personalDataFiles returns the local uploaded files referenced by a user's
+// content, sorted and without duplicates
+func personalDataFiles(data *exportData, cookbooks []models.Cookbook, attachments []models.Attachment) []string {
+	seen := map[string]bool{}
+	add := func(path string) {
+		if strings.HasPrefix(path, "/static/") && !strings.Contains(path, "..") {
+			seen[path] = true
+		}
+	}
+	for _, b := range data.Bookmarks {
+		add(b.Favicon)
+		add(b.Thumbnail)
+	}
+	for _, d := range data.Drawings {
+		add(d.FilePath)
+	}
+	for _, m := range data.Media {
+		add(m.FilePath)
+	}
+	for _, r := range data.Recipes {
+		add(r.Thumbnail)
+		for _, img := range r.Images {
+			add(img)
+		}
+	}
+	for _, l := range data.RatedLists {
+		for _, item := range l.Items {
+			add(item.ImagePath)
+		}
+	}
+	for _, c := range cookbooks {
+		add(c.CoverImage)
+	}
+	for _, a := range attachments {
+		add(a.FilePath)
+	}
+
+	files := make([]string, 0, len(seen))
+	for path := range seen {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// addUploadToZip copies the file served at a /static/... path into files/ of the archive
+func addUploadToZip(zw *zip.Writer, path string) error {
+	rel := strings.TrimPrefix(path, "/static/")
+	local, ok := datadir.File(path)
+	if !ok {
+		return fmt.Errorf("%s is not an uploaded file", path)
+	}
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := zw.Create("files/" + rel)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// nonNil makes empty slices encode as [] rather than null
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+// personalExportFile decodes one JSON file of user 1's personal data
+// package into v
+func personalExportFile(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writePersonalDataExport(&buf, 1, &exportData{}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+func initExportDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDB(filepath.Join(t.TempDir(), "export.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	if _, err := database.DB.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPersonalExportRecentViews(t *testing.T) {
+	initExportDB(t)
+	noteID, err := database.CreateNote(1, "Bread", "rye")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RecordView(1, noteID); err != nil {
+		t.Fatal(err)
+	}
+
+	var views []map[string]interface{}
+	personalExportFile(t, "activity/recent_views.json", &views)
+	if len(views) != 1 || views[0]["title"] != "Bread" || views[0]["viewed_at"] == "" {
+		t.Errorf("recent views = %v, want the note", views)
+	}
+}
+
+func TestPersonalExportReminders(t *testing.T) {
+	initExportDB(t)
+	noteID, _ := database.CreateNote(1, "Call the plumber", "")
+	listID, _ := database.CreateList(1, "Chores")
+	entryID, _ := database.AddListItem(listID, "Mow the lawn")
+	database.AddListItem(listID, "Water the plants")
+	if err := database.SetListItemDueDate(entryID, "2026-03-12"); err != nil {
+		t.Fatal(err)
+	}
+	fired, err := database.CreateItemReminder(&models.ItemReminder{ItemID: noteID, UserID: 1,
+		RemindAt: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC), NotificationType: "notification_only"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.MarkItemReminderFired(fired, time.Date(2026, 3, 10, 9, 1, 0, 0, time.UTC))
+	database.CreateItemReminder(&models.ItemReminder{ItemID: noteID, UserID: 1,
+		RemindAt: time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC), NotificationType: "email", Emails: "a@example.com"})
+
+	var got personalReminders
+	personalExportFile(t, "content/reminders.json", &got)
+	if len(got.ItemReminders) != 2 || got.ItemReminders[0].FiredAt == nil || got.ItemReminders[1].FiredAt != nil ||
+		got.ItemReminders[1].Emails != "a@example.com" {
+		t.Errorf("item reminders = %+v, want the fired one and the pending one", got.ItemReminders)
+	}
+	want := []personalDueDate{{ListID: listID, ListTitle: "Chores", EntryID: entryID, Content: "Mow the lawn", DueDate: "2026-03-12"}}
+	if !slices.Equal(got.DueDates, want) {
+		t.Errorf("checklist due dates = %+v, want %+v", got.DueDates, want)
+	}
+}
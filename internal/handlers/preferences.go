@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+)
+
+// A user's preferences are kept on the server, so that every browser and
+// API client starts from them rather than from built-in defaults: the page
+// to land on, how many items a list page loads at a time, the order lists
+// are shown in until one is picked for them, the theme and how dates are
+// shown. The landing page is users.default_page; the others are user
+// settings.
+
+const (
+	perPageSetting     = "per_page"
+	defaultSortSetting = "default_sort"
+	themeSetting       = "theme"
+	dateFormatSetting  = "date_format"
+)
+
+// preferences are a user's preferences, as GET /api/v1/preferences returns
+// them
+type preferences struct {
+	DefaultPage string        `json:"default_page"`
+	PerPage     int           `json:"per_page"`
+	Sort        database.Sort `json:"sort"`
+	Theme       string        `json:"theme"`
+	// DateFormat is how the list pages show dates: iso (2006-01-02 15:04),
+	// locale (as the browser's language writes them) or relative (3 days ago)
+	DateFormat string `json:"date_format"`
+}
+
+// landingPages are the pages a user can land on when opening the app
+var landingPages = []string{
+	"dashboard", "bookmarks", "notes", "recipes", "media", "lists", "rated-lists", "drawings", "reminders", "settings",
+}
+
+// themes are the themes of layout.html
+var themes = []string{
+	"light", "dark", "sepia", "dracula", "catppuccin", "synthwave", "nord", "gruvbox", "rose-pine",
+	"midnight-ocean", "monokai", "tokyo-night", "one-dark", "everforest", "kanagawa", "sunset-glow", "arctic",
+}
+
+var dateFormats = []string{"iso", "locale", "relative"}
+
+// perPageOptions are the items per page the settings page offers; the API
+// takes any number up to maxPerPage
+var perPageOptions = []int{25, 50, 100, 200}
+
+func getPreferences(userID int64) preferences {
+	p := preferences{
+		DefaultPage: database.GetDefaultPage(userID),
+		PerPage:     userPerPage(userID),
+		Sort:        userDefaultSort(userID),
+		Theme:       database.GetUserSetting(userID, themeSetting),
+		DateFormat:  database.GetUserSetting(userID, dateFormatSetting),
+	}
+	if !slices.Contains(themes, p.Theme) {
+		p.Theme = "light"
+	}
+	if !slices.Contains(dateFormats, p.DateFormat) {
+		p.DateFormat = "iso"
+	}
+	return p
+}
+
+// userPerPage is how many items the user's list pages load at a time
+func userPerPage(userID int64) int {
+	if n, err := strconv.Atoi(database.GetUserSetting(userID, perPageSetting)); err == nil && n > 0 {
+		return min(n, maxPerPage)
+	}
+	return defaultPerPage
+}
+
+// userDefaultSort is the order the user's lists are shown in until they
+// pick one for a list
+func userDefaultSort(userID int64) database.Sort {
+	sort, _ := database.ParseSort(database.GetUserSetting(userID, defaultSortSetting))
+	return sort
+}
+
+// PreferencesHandler returns the user's preferences as JSON and changes
+// those given: as a JSON body with any of the fields of GET, or as form
+// values of the same names
+func PreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if r.Method != http.MethodGet {
+		var input struct {
+			DefaultPage *string `json:"default_page"`
+			PerPage     *int    `json:"per_page"`
+			Sort        *string `json:"sort"`
+			Theme       *string `json:"theme"`
+			DateFormat  *string `json:"date_format"`
+		}
+		var v validation.Validator
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			r.ParseForm()
+			formValue := func(name string) *string {
+				if !r.Form.Has(name) {
+					return nil
+				}
+				value := r.Form.Get(name)
+				return &value
+			}
+			input.DefaultPage = formValue("default_page")
+			input.Sort = formValue("sort")
+			input.Theme = formValue("theme")
+			input.DateFormat = formValue("date_format")
+			if s := formValue("per_page"); s != nil {
+				n, err := strconv.Atoi(*s)
+				if err != nil {
+					v.Add("per_page", "must be a number")
+				}
+				input.PerPage = &n
+			}
+		}
+
+		settings := map[string]string{}
+		if input.DefaultPage != nil && !slices.Contains(landingPages, *input.DefaultPage) {
+			v.Add("default_page", "is not a page")
+		}
+		if input.PerPage != nil {
+			if *input.PerPage < 1 || *input.PerPage > maxPerPage {
+				v.Add("per_page", "must be between 1 and "+strconv.Itoa(maxPerPage))
+			}
+			settings[perPageSetting] = strconv.Itoa(*input.PerPage)
+		}
+		if input.Sort != nil {
+			if _, ok := database.ParseSort(*input.Sort); !ok {
+				v.Add("sort", "is not a sort order")
+			}
+			settings[defaultSortSetting] = *input.Sort
+		}
+		if input.Theme != nil {
+			if !slices.Contains(themes, *input.Theme) {
+				v.Add("theme", "is not a theme")
+			}
+			settings[themeSetting] = *input.Theme
+		}
+		if input.DateFormat != nil {
+			if !slices.Contains(dateFormats, *input.DateFormat) {
+				v.Add("date_format", "must be iso, locale or relative")
+			}
+			settings[dateFormatSetting] = *input.DateFormat
+		}
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
+
+		if input.DefaultPage != nil {
+			if err := database.SetDefaultPage(userID, *input.DefaultPage); err != nil {
+				http.Error(w, "failed to save", http.StatusInternalServerError)
+				return
+			}
+		}
+		for key, value := range settings {
+			if err := database.SetUserSetting(userID, key, value); err != nil {
+				http.Error(w, "failed to save", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(getPreferences(userID))
+}
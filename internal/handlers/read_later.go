@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+// readLaterKind is the ?kind= of the bookmarks list that shows the
+// read-later queue
+const readLaterKind = "later"
+
+// ReadLaterHandler puts a bookmark in the read-later queue, or with
+// queued=false takes it out, and returns it
+func ReadLaterHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	err := database.SetReadLater(userID, id, r.FormValue("queued") != "false")
+	if err == sql.ErrNoRows {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBookmark(w, r, userID, id)
+}
+
+// MarkReadHandler marks a bookmark in the read-later queue as read and
+// returns it
+func MarkReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	err := database.MarkBookmarkRead(userID, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Bookmark is not in the read-later queue", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBookmark(w, r, userID, id)
+}
+
+// ToggleReadHandler marks a bookmark in the read-later queue as read, or a
+// read one (or one not in the queue) as unread, and returns it
+func ToggleReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	err := database.ToggleBookmarkRead(userID, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBookmark(w, r, userID, id)
+}
+
+// writeBookmark responds with a bookmark: its card to HTMX, JSON to anyone
+// else
+func writeBookmark(w http.ResponseWriter, r *http.Request, userID, id int64) {
+	if r.Header.Get("HX-Request") != "" {
+		renderBookmarkCard(w, userID, id)
+		return
+	}
+	bookmark, err := database.GetBookmark(userID, id)
+	if err != nil {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookmark)
+}
+
+func renderBookmarkCard(w http.ResponseWriter, userID, id int64) {
+	bookmark, err := database.GetBookmark(userID, id)
+	if err != nil {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	RenderFragment(w, "bookmark_list.html", []models.Bookmark{*bookmark})
+}
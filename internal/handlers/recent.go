@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+const (
+	// dashboardRecentItems is how many recently changed items the dashboard
+	// lists
+	dashboardRecentItems = 8
+	// defaultRecentLimit and maxRecentLimit bound ?limit= of /api/recent
+	defaultRecentLimit = 20
+	maxRecentLimit     = 100
+)
+
+// ApiRecentHandler returns the user's most recently created or changed items
+// of every type, newest first; ?limit= sets how many (at most 100)
+func ApiRecentHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRecentLimit
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, maxRecentLimit)
+	}
+	items, err := database.GetRecentItems(getUserID(r), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []models.RecentItem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
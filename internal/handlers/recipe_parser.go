@@ -1,32 +1,39 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+
+	"infokeep/internal/extensions"
 
 	"golang.org/x/net/html"
 )
 
-type RecipeData struct {
-	Title        string   `json:"title"`
-	Ingredients  []string `json:"ingredients"`
-	Instructions string   `json:"instructions"`
-	Image        string   `json:"image"`
-}
+// RecipeData is a recipe parsed from a page, by the parsers of this file or
+// those of extensions
+type RecipeData = extensions.Recipe
+
+// recipeFetchTimeout bounds fetching a recipe page, on top of any deadline of
+// the caller's context
+const recipeFetchTimeout = 20 * time.Second
 
 // ParseRecipeFromURL attempts to extract recipe data from a URL
-func ParseRecipeFromURL(url string) (*RecipeData, error) {
+func ParseRecipeFromURL(ctx context.Context, pageURL string) (*RecipeData, error) {
 	// Create request with User-Agent to avoid being blocked
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 
 	// Fetch the HTML
-	client := &http.Client{}
+	client := &http.Client{Timeout: recipeFetchTimeout, Transport: fetchTransport("recipe")}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch URL: %w", err)
@@ -43,18 +50,51 @@ func ParseRecipeFromURL(url string) (*RecipeData, error) {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	// Try JSON-LD first (most reliable)
-	if recipe := extractJSONLD(doc); recipe != nil {
-		return recipe, nil
+	// Parsers of extensions for this site first, then JSON-LD (most
+	// reliable), then Microdata (itemprop attributes), then HTML heuristics
+	recipe := extensionRecipe(ctx, req.URL, doc)
+	if recipe == nil {
+		recipe = extractJSONLD(doc)
+	}
+	if recipe == nil {
+		recipe = extractMicrodata(doc)
+	}
+	if recipe == nil {
+		if recipe, err = extractFromHTML(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	if recipe.Language == "" {
+		recipe.Language = documentLanguage(doc)
 	}
+	return recipe, nil
+}
 
-	// Try Microdata (itemprop attributes) second
-	if recipe := extractMicrodata(doc); recipe != nil {
-		return recipe, nil
+// extensionRecipe returns the recipe the first extension parser for pageURL
+// finds, if any
+func extensionRecipe(ctx context.Context, pageURL *url.URL, doc *html.Node) *RecipeData {
+	for _, p := range extensions.RecipeParsers(pageURL) {
+		recipe, err := p.Parse(ctx, pageURL, doc)
+		if err != nil {
+			log.Printf("Recipe parser %s failed on %s: %v", p.Name, pageURL, err)
+			continue
+		}
+		if recipe != nil {
+			return recipe
+		}
 	}
+	return nil
+}
 
-	// Fallback to HTML heuristic parsing
-	return extractFromHTML(doc)
+// documentLanguage returns the lang attribute of the <html> element, if any
+func documentLanguage(doc *html.Node) string {
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode && n.Data == "html" {
+			return getAttr(n, "lang")
+		}
+	}
+	return ""
 }
 
 // extractJSONLD extracts recipe data from JSON-LD structured data in the DOM
@@ -184,6 +224,10 @@ func extractRecipeFromMap(data interface{}) *RecipeData {
 		recipe.Image = extractImage(image)
 	}
 
+	if lang, ok := obj["inLanguage"].(string); ok {
+		recipe.Language = lang
+	}
+
 	return recipe
 }
 
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+// Instruction steps often say how long something takes ("simmer for 20
+// minutes", "bake 1 hour 15 min", "rest 5-10 minutes"). Those durations are
+// picked out of each step so the recipe page, and clients of the API, can
+// offer a timer for them with one tap.
+
+// recipeStep is one line of a recipe's instructions and the timers in it
+type recipeStep struct {
+	Text   string      `json:"text"`
+	Timers []stepTimer `json:"timers,omitempty"`
+}
+
+// stepTimer is a duration mentioned in a step. Text is the words it was
+// read from. For a range ("5-10 minutes") Seconds is the shorter end and
+// MaxSeconds the longer one.
+type stepTimer struct {
+	Text       string `json:"text"`
+	Seconds    int    `json:"seconds"`
+	MaxSeconds int    `json:"max_seconds,omitempty"`
+	Label      string `json:"label"` // e.g. "1 h 15 min"
+}
+
+const durationNumber = `\d+(?:[.,]\d+)?\s*[½¼¾]?|\d+\s*/\s*\d+|half\s+an?|an?|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|fifteen|twenty|thirty|forty[- ]five|forty|sixty|ninety`
+
+var durationPattern = regexp.MustCompile(`(?i)\b(` + durationNumber + `)(?:\s*(?:-|–|to|or)\s*(` + durationNumber + `))?\s*` +
+	`(seconds?|secs?|minutes?|mins?|hours?|hrs?|h)\b(\s+and\s+a\s+half)?`)
+
+// durationJoin is what may stand between the parts of one duration, as in
+// "1 hour and 15 minutes"
+var durationJoin = regexp.MustCompile(`^(?i)\s*(?:,|and)?\s*$`)
+
+var numberWords = map[string]float64{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"fifteen": 15, "twenty": 20, "thirty": 30, "forty": 40, "forty-five": 45,
+	"forty five": 45, "sixty": 60, "ninety": 90,
+}
+
+var fractions = map[string]float64{"½": 0.5, "¼": 0.25, "¾": 0.75}
+
+// parseDurationNumber reads the amount of a duration: "20", "1.5", "1½",
+// "1/2", "half an" or a number word
+func parseDurationNumber(s string) (float64, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "half") {
+		return 0.5, true
+	}
+	if n, ok := numberWords[s]; ok {
+		return n, true
+	}
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		a, err1 := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		b, err2 := strconv.ParseFloat(strings.TrimSpace(den), 64)
+		if err1 != nil || err2 != nil || b == 0 {
+			return 0, false
+		}
+		return a / b, true
+	}
+	var extra float64
+	for f, v := range fractions {
+		if strings.HasSuffix(s, f) {
+			s, extra = strings.TrimSpace(strings.TrimSuffix(s, f)), v
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0, false
+	}
+	return n + extra, true
+}
+
+// unitSeconds is the length of a duration unit as written
+func unitSeconds(unit string) int {
+	switch u := strings.ToLower(unit); {
+	case strings.HasPrefix(u, "s"):
+		return 1
+	case strings.HasPrefix(u, "m"):
+		return 60
+	default:
+		return 3600
+	}
+}
+
+// parseStepTimers finds the durations in an instruction step
+func parseStepTimers(step string) []stepTimer {
+	var timers []stepTimer
+	lastEnd, lastUnit := -1, 0
+	for _, m := range durationPattern.FindAllStringSubmatchIndex(step, -1) {
+		low, ok := parseDurationNumber(step[m[2]:m[3]])
+		if !ok {
+			continue
+		}
+		high := low
+		if m[4] >= 0 {
+			if high, ok = parseDurationNumber(step[m[4]:m[5]]); !ok || high <= low {
+				high = low
+			}
+		}
+		unit := unitSeconds(step[m[6]:m[7]])
+		if m[8] >= 0 {
+			low, high = low+0.5, high+0.5
+		}
+		seconds, maxSeconds := int(low*float64(unit)), int(high*float64(unit))
+		if seconds <= 0 {
+			continue
+		}
+
+		// "1 hour 15 minutes" is one timer
+		if n := len(timers); n > 0 && lastEnd >= 0 && unit < lastUnit && timers[n-1].MaxSeconds == 0 &&
+			durationJoin.MatchString(step[lastEnd:m[0]]) {
+			t := &timers[n-1]
+			t.Text = strings.TrimSpace(t.Text + step[lastEnd:m[1]])
+			t.Seconds += seconds
+			if maxSeconds > seconds {
+				t.MaxSeconds = t.Seconds - seconds + maxSeconds
+			}
+			t.Label = timerLabel(t.Seconds, t.MaxSeconds)
+			lastEnd, lastUnit = m[1], unit
+			continue
+		}
+
+		t := stepTimer{Text: step[m[0]:m[1]], Seconds: seconds}
+		if maxSeconds > seconds {
+			t.MaxSeconds = maxSeconds
+		}
+		t.Label = timerLabel(t.Seconds, t.MaxSeconds)
+		timers = append(timers, t)
+		lastEnd, lastUnit = m[1], unit
+	}
+	return timers
+}
+
+// timerLabel writes a timer's duration shortly, e.g. "45 s", "20 min",
+// "1 h 15 min" or "5–10 min"
+func timerLabel(seconds, maxSeconds int) string {
+	if maxSeconds > seconds {
+		if seconds%60 == 0 && maxSeconds%60 == 0 && maxSeconds < 3600 {
+			return fmt.Sprintf("%d–%d min", seconds/60, maxSeconds/60)
+		}
+		return timerLabel(seconds, 0) + "–" + timerLabel(maxSeconds, 0)
+	}
+	h, m, s := seconds/3600, seconds%3600/60, seconds%60
+	var parts []string
+	if h > 0 {
+		parts = append(parts, fmt.Sprintf("%d h", h))
+	}
+	if m > 0 {
+		parts = append(parts, fmt.Sprintf("%d min", m))
+	}
+	if s > 0 {
+		parts = append(parts, fmt.Sprintf("%d s", s))
+	}
+	return strings.Join(parts, " ")
+}
+
+// recipeSteps splits instructions into steps with their timers
+func recipeSteps(instructions string) []recipeStep {
+	var steps []recipeStep
+	for _, line := range splitLines(instructions) {
+		steps = append(steps, recipeStep{Text: line, Timers: parseStepTimers(line)})
+	}
+	return steps
+}
+
+// recipeJSON is a recipe as the API returns it, with its instructions also
+// split into steps and their timers
+type recipeJSON struct {
+	*models.Recipe
+	Steps []recipeStep `json:"steps"`
+}
+
+func newRecipeJSON(recipe *models.Recipe) recipeJSON {
+	steps := recipeSteps(recipe.Instructions)
+	if steps == nil {
+		steps = []recipeStep{}
+	}
+	return recipeJSON{Recipe: recipe, Steps: steps}
+}
+
+// ApiGetRecipeHandler returns a recipe with its steps and the timers in them
+func ApiGetRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	recipe, err := database.GetRecipe(getUserID(r), id)
+	if err != nil {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRecipeJSON(recipe))
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStepTimers(t *testing.T) {
+	tests := []struct {
+		step string
+		want []stepTimer
+	}{
+		{"Simmer for 20 minutes.", []stepTimer{{Text: "20 minutes", Seconds: 1200, Label: "20 min"}}},
+		{"Bake 1 hour 15 min until golden", []stepTimer{{Text: "1 hour 15 min", Seconds: 4500, Label: "1 h 15 min"}}},
+		{"Roast for 1 hour and 30 minutes", []stepTimer{{Text: "1 hour and 30 minutes", Seconds: 5400, Label: "1 h 30 min"}}},
+		{"Let it rest 5-10 minutes", []stepTimer{{Text: "5-10 minutes", Seconds: 300, MaxSeconds: 600, Label: "5–10 min"}}},
+		{"Cook 3 to 4 mins per side", []stepTimer{{Text: "3 to 4 mins", Seconds: 180, MaxSeconds: 240, Label: "3–4 min"}}},
+		{"Chill for half an hour", []stepTimer{{Text: "half an hour", Seconds: 1800, Label: "30 min"}}},
+		{"Braise for an hour and a half", []stepTimer{{Text: "an hour and a half", Seconds: 5400, Label: "1 h 30 min"}}},
+		{"Proof 1½ hours", []stepTimer{{Text: "1½ hours", Seconds: 5400, Label: "1 h 30 min"}}},
+		{"Blanch 30 seconds, then boil two minutes", []stepTimer{
+			{Text: "30 seconds", Seconds: 30, Label: "30 s"},
+			{Text: "two minutes", Seconds: 120, Label: "2 min"},
+		}},
+		{"Stir for 2 minutes, then 5 minutes more", []stepTimer{
+			{Text: "2 minutes", Seconds: 120, Label: "2 min"},
+			{Text: "5 minutes", Seconds: 300, Label: "5 min"},
+		}},
+		{"Add 2 handfuls of spinach and 200 g of rice", nil},
+		{"Preheat the oven to 180°C.", nil},
+	}
+	for _, tt := range tests {
+		if got := parseStepTimers(tt.step); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStepTimers(%q) = %+v, want %+v", tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestTimerLabel(t *testing.T) {
+	tests := []struct {
+		seconds, maxSeconds int
+		want                string
+	}{
+		{45, 0, "45 s"},
+		{3600, 0, "1 h"},
+		{3690, 0, "1 h 1 min 30 s"},
+		{1800, 3600, "30 min–1 h"},
+	}
+	for _, tt := range tests {
+		if got := timerLabel(tt.seconds, tt.maxSeconds); got != tt.want {
+			t.Errorf("timerLabel(%d, %d) = %q, want %q", tt.seconds, tt.maxSeconds, got, tt.want)
+		}
+	}
+}
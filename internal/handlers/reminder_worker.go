@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/smtp"
-	"os"
 	"strings"
 	"time"
 
@@ -42,6 +40,9 @@ func checkReminders() {
 			fireReminder(reminder, now)
 		}
 	}
+
+	fireItemReminders(now)
+	notifyDueListItems(now)
 }
 
 func isReminderDue(r database.Reminder, now time.Time) bool {
@@ -146,18 +147,23 @@ func fireReminder(r database.Reminder, now time.Time) {
 }
 
 func sendPushNotification(r database.Reminder) {
-	subs, err := database.GetUserPushSubscriptions(r.UserID)
+	sendPushToUser(r.UserID, "InfoKeep Reminder", r.Name)
+}
+
+// sendPushToUser sends a push notification to every browser the user subscribed
+func sendPushToUser(userID int64, title, body string) {
+	subs, err := database.GetUserPushSubscriptions(userID)
 	if err != nil {
-		log.Printf("Worker: Failed to get subscriptions for user %d: %v", r.UserID, err)
+		log.Printf("Worker: Failed to get subscriptions for user %d: %v", userID, err)
 		return
 	}
 
 	if len(subs) == 0 {
-		log.Printf("Worker: No push subscriptions found for user %d", r.UserID)
+		log.Printf("Worker: No push subscriptions found for user %d", userID)
 		return // No active browsers for this user to push to
 	}
 
-	log.Printf("Worker: Found %d push subscriptions for user %d", len(subs), r.UserID)
+	log.Printf("Worker: Found %d push subscriptions for user %d", len(subs), userID)
 
 	// Setup payload matching the sw.js listener format
 	type Payload struct {
@@ -165,8 +171,8 @@ func sendPushNotification(r database.Reminder) {
 		Body  string `json:"body"`
 	}
 	payloadBytes, _ := json.Marshal(Payload{
-		Title: "InfoKeep Reminder",
-		Body:  r.Name,
+		Title: title,
+		Body:  body,
 	})
 
 	for _, sub := range subs {
@@ -199,31 +205,13 @@ func sendPushNotification(r database.Reminder) {
 	}
 }
 
-// sendEmail sends an email if SMTP env vars differ from default
+// sendEmail sends the reminder to the comma-separated addresses on the reminder
 func sendEmail(r database.Reminder) {
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
-	smtpUser := os.Getenv("SMTP_USER")
-	smtpPass := os.Getenv("SMTP_PASS")
-	smtpFrom := os.Getenv("SMTP_FROM")
-
-	if smtpHost == "" || smtpPort == "" {
-		log.Printf("Worker: Cannot send email for reminder %d, missing SMTP config", r.ID)
-		return
-	}
-	if smtpFrom == "" {
-		smtpFrom = "noreply@infokeep.local"
-	}
-
-	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
-	to := strings.Split(r.Emails.String, ",") // Handle comma-separated list of emails
-
-	msg := []byte("To: " + r.Emails.String + "\r\n" +
-		"Subject: InfoKeep Reminder: " + r.Name + "\r\n" +
-		"\r\n" +
-		"This is an automated reminder from your InfoKeep dashboard regarding: " + r.Name + ".\r\n")
-
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpFrom, to, msg)
+	err := sendMail(
+		strings.Split(r.Emails.String, ","),
+		"InfoKeep Reminder: "+r.Name,
+		"This is an automated reminder from your InfoKeep dashboard regarding: "+r.Name+".",
+	)
 	if err != nil {
 		log.Printf("Worker: Failed to send email for reminder %d: %v", r.ID, err)
 	} else {
@@ -5,7 +5,6 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 
 	"infokeep/internal/database"
@@ -67,9 +66,11 @@ func RemindersPageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	tags, _ := database.GetTagsWithCounts(userID)
+	itemReminders, _ := database.GetItemReminders(userID, 0)
 
 	data := map[string]interface{}{
 		"Reminders":      reminders,
+		"ItemReminders":  itemReminders,
 		"VapidPublicKey": VapidPublicKey,
 		"Tags":           tags,
 		"ActiveTag":      "",
@@ -132,7 +133,7 @@ func AddReminderHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Standard full-page redirect to clear POST data and reload the UI
-	http.Redirect(w, r, "/reminders", http.StatusSeeOther)
+	redirectTo(w, r, "/reminders", http.StatusSeeOther)
 }
 
 // DeleteReminderHandler handles deleting a reminder
@@ -142,18 +143,12 @@ func DeleteReminderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	userID := getUserID(r)
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 3 {
-		http.Error(w, "Missing reminder ID", http.StatusBadRequest)
-		return
-	}
-	id, err := strconv.ParseInt(pathParts[2], 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
 
-	err = database.DeleteReminder(id, userID)
+	err := database.DeleteReminder(id, userID)
 	if err != nil {
 		log.Printf("Error deleting reminder: %v", err)
 		http.Error(w, "Failed to delete reminder", http.StatusInternalServerError)
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+)
+
+// ReorderItemsHandler arranges items by hand, for the lists shown in manual
+// order: ids, in a JSON body {"ids": [3, 1, 2]} or as form values like a
+// bulk request's, are the items in their new order. Dragging a card of the
+// bookmarks page or a checklist of the lists page (static/js/reorder.js)
+// posts the ids of the page.
+func ReorderItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	req, err := parseBulkRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var v validation.Validator
+	if len(req.IDs) == 0 {
+		v.Add("ids", "is required")
+	} else if len(req.IDs) > database.MaxBulkItems {
+		v.Add("ids", fmt.Sprintf("at most %d items at a time", database.MaxBulkItems))
+	}
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	err = database.ReorderItems(userID, req.IDs)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"count": len(req.IDs)})
+}
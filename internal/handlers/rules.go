@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"infokeep/internal/database"
+	"infokeep/internal/extensions"
+	"infokeep/internal/validation"
+)
+
+// Users automate their own housekeeping with rules like "if a bookmark's
+// domain is youtube.com, add the tag video" (Settings → Automation rules).
+// Each rule has one condition and one action. Rules run, oldest first, on
+// every item the user creates or updates, through the item hooks of
+// extensions.
+
+// ruleItemTypes are the item types a rule can be limited to, by name
+var ruleItemTypes = map[string]string{
+	"bookmark":   "Bookmark",
+	"note":       "Note",
+	"recipe":     "Recipe",
+	"list":       "Checklist",
+	"rated_list": "Rated list",
+	"drawing":    "Drawing",
+	"media":      "Media",
+}
+
+// ruleFields are the parts of an item a condition can look at
+var ruleFields = map[string]string{
+	"title":   "Title",
+	"domain":  "Domain",
+	"url":     "URL",
+	"content": "Text",
+	"tag":     "A tag",
+}
+
+// ruleOperators compare a field with the rule's value, ignoring case
+var ruleOperators = map[string]string{
+	"equals":      "is",
+	"contains":    "contains",
+	"starts_with": "starts with",
+	"ends_with":   "ends with",
+	"matches":     "matches the regular expression",
+}
+
+// ruleActions are what a rule does to matching items
+var ruleActions = map[string]string{
+	"add_tag":              "Add the tag",
+	"pin":                  "Pin to the dashboard",
+	"convert_to_checklist": "Convert the note to a checklist",
+}
+
+func init() {
+	extensions.RegisterItemHook(extensions.ItemHook{
+		Name:    "rules",
+		Created: runRules,
+		Updated: runRules,
+	})
+}
+
+// ruleSubject is what rules see of an item
+type ruleSubject struct {
+	ID      int64
+	Type    string
+	Title   string
+	URL     string
+	Content string
+	Tags    []string
+}
+
+// ruleMatches reports whether the rule's condition holds for s
+func ruleMatches(rule database.Rule, s ruleSubject) bool {
+	if rule.ItemType != "" && rule.ItemType != s.Type {
+		return false
+	}
+	var values []string
+	switch rule.Field {
+	case "title":
+		values = []string{s.Title}
+	case "url":
+		values = []string{s.URL}
+	case "domain":
+		if u, err := url.Parse(s.URL); err == nil && u.Host != "" {
+			values = []string{strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")}
+		}
+	case "content":
+		values = []string{s.Content}
+	case "tag":
+		values = s.Tags
+	}
+	for _, v := range values {
+		if compareRuleValue(rule.Operator, v, rule.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareRuleValue(operator, value, want string) bool {
+	if operator == "matches" {
+		re, err := regexp.Compile("(?i)" + want)
+		return err == nil && re.MatchString(value)
+	}
+	value, want = strings.ToLower(value), strings.ToLower(want)
+	switch operator {
+	case "equals":
+		return value == want
+	case "contains":
+		return strings.Contains(value, want)
+	case "starts_with":
+		return strings.HasPrefix(value, want)
+	case "ends_with":
+		return strings.HasSuffix(value, want)
+	}
+	return false
+}
+
+// ruleApplies reports whether the rule's action would change s
+func ruleApplies(rule database.Rule, s ruleSubject) bool {
+	switch rule.Action {
+	case "add_tag":
+		for _, t := range s.Tags {
+			if strings.EqualFold(t, rule.ActionValue) {
+				return false
+			}
+		}
+	case "convert_to_checklist":
+		return s.Type == "note"
+	}
+	return true
+}
+
+// describeRule is a rule in words, as listed on the rules page
+func describeRule(rule database.Rule) string {
+	subject := "an item's"
+	if name, ok := ruleItemTypes[rule.ItemType]; ok {
+		subject = "a " + strings.ToLower(name) + "'s"
+	}
+	field := strings.ToLower(ruleFields[rule.Field])
+	if rule.Field == "tag" {
+		subject, field = "one of "+strings.TrimSuffix(subject, "'s")+"'s", "tags"
+	}
+	action := strings.ToLower(ruleActions[rule.Action][:1]) + ruleActions[rule.Action][1:]
+	if rule.Action == "add_tag" {
+		action += " " + rule.ActionValue
+	}
+	return "If " + subject + " " + field + " " + ruleOperators[rule.Operator] + " \"" + rule.Value + "\", " + action
+}
+
+// runRules applies the user's enabled rules to an item that was created or
+// updated
+func runRules(ctx context.Context, item extensions.Item) {
+	rules, err := database.GetRules(item.UserID)
+	if err != nil {
+		log.Printf("Rules: failed to load rules of user %d: %v", item.UserID, err)
+		return
+	}
+	var enabled []database.Rule
+	for _, rule := range rules {
+		if rule.Enabled {
+			enabled = append(enabled, rule)
+		}
+	}
+	if len(enabled) == 0 {
+		return
+	}
+
+	s := loadRuleSubject(item)
+	for _, rule := range enabled {
+		if !ruleMatches(rule, s) || !ruleApplies(rule, s) {
+			continue
+		}
+		if err := applyRule(ctx, item.UserID, rule, &s); err != nil {
+			log.Printf("Rules: rule %d failed on item %d: %v", rule.ID, item.ID, err)
+			continue
+		}
+		if rule.Action == "convert_to_checklist" {
+			return // the item is gone
+		}
+	}
+}
+
+// loadRuleSubject reads the parts of an item rules look at
+func loadRuleSubject(item extensions.Item) ruleSubject {
+	s := ruleSubject{ID: item.ID, Type: item.Type, Title: item.Title}
+	switch item.Type {
+	case "bookmark":
+		if b, err := database.GetBookmark(item.UserID, item.ID); err == nil {
+			s.URL, s.Content = b.URL, b.Description
+		}
+	case "note":
+		if n, err := database.GetNote(item.UserID, item.ID); err == nil {
+			s.Content = n.Content
+		}
+	case "recipe":
+		if rec, err := database.GetRecipe(item.UserID, item.ID); err == nil {
+			s.URL, s.Content = rec.SourceURL, rec.Ingredients+"\n"+rec.Instructions
+		}
+	}
+	s.Tags, _ = database.GetItemTags(item.ID)
+	return s
+}
+
+// applyRule carries out the rule's action on s, keeping s up to date for
+// the rules after it
+func applyRule(ctx context.Context, userID int64, rule database.Rule, s *ruleSubject) error {
+	switch rule.Action {
+	case "add_tag":
+		tags := append(s.Tags, rule.ActionValue)
+		if err := database.SetItemTags(s.ID, tags); err != nil {
+			return err
+		}
+		s.Tags = tags
+	case "pin":
+		return database.PinItem(s.ID, userID)
+	case "convert_to_checklist":
+		return convertNoteToChecklist(ctx, userID, s)
+	}
+	return nil
+}
+
+// convertNoteToChecklist replaces a note with a checklist of the same title
+// and tags, with an item per line of the note
+func convertNoteToChecklist(ctx context.Context, userID int64, s *ruleSubject) error {
+	listID, err := database.CreateList(userID, s.Title)
+	if err != nil {
+		return err
+	}
+	for _, line := range checklistLines(s.Content) {
+		if _, err := database.AddListItem(listID, line); err != nil {
+			return err
+		}
+	}
+	if len(s.Tags) > 0 {
+		database.SetItemTags(listID, s.Tags)
+	}
+	if err := database.DeleteItem(userID, s.ID); err != nil {
+		return err
+	}
+	itemCreated(ctx, userID, listID, "list", s.Title)
+	return nil
+}
+
+// checklistMarkers start the lines of a list written as text
+var checklistMarkers = []string{"- ", "* ", "• ", "[ ] ", "[] "}
+
+// checklistLines are the non-empty lines of a note without list markers
+// ("- ", "* ", "[ ] ")
+func checklistLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		for _, marker := range checklistMarkers {
+			line = strings.TrimSpace(strings.TrimPrefix(line, marker))
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// ruleFromForm reads and validates a rule posted from the rules page
+func ruleFromForm(r *http.Request) (database.Rule, validation.Errors) {
+	var v validation.Validator
+	rule := database.Rule{
+		UserID:      getUserID(r),
+		ItemType:    r.FormValue("item_type"),
+		Field:       r.FormValue("field"),
+		Operator:    r.FormValue("operator"),
+		Value:       v.Required("value", r.FormValue("value"), maxShortText),
+		Action:      r.FormValue("action"),
+		ActionValue: strings.TrimSpace(r.FormValue("action_value")),
+		Enabled:     true,
+	}
+	if _, ok := ruleItemTypes[rule.ItemType]; !ok && rule.ItemType != "" {
+		v.Add("item_type", "is not an item type")
+	}
+	if _, ok := ruleFields[rule.Field]; !ok {
+		v.Add("field", "is not a field rules can look at")
+	}
+	if _, ok := ruleOperators[rule.Operator]; !ok {
+		v.Add("operator", "is not a comparison")
+	}
+	if rule.Operator == "matches" && !v.Has("value") {
+		if _, err := regexp.Compile(rule.Value); err != nil {
+			v.Add("value", "is not a valid regular expression")
+		}
+	}
+	switch rule.Action {
+	case "add_tag":
+		rule.ActionValue = v.Required("action_value", rule.ActionValue, maxShortText)
+	case "pin":
+		rule.ActionValue = ""
+	case "convert_to_checklist":
+		rule.ActionValue = ""
+		if rule.ItemType != "note" {
+			v.Add("item_type", "must be Note to convert to a checklist")
+		}
+	default:
+		v.Add("action", "is not an action")
+	}
+	return rule, v.Errors()
+}
+
+// ruleView is a rule as listed on the rules page
+type ruleView struct {
+	database.Rule
+	Description string
+}
+
+// RulesHandler shows the user's rules and the form to add one
+func RulesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	rules, err := database.GetRules(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	views := make([]ruleView, len(rules))
+	for i, rule := range rules {
+		views[i] = ruleView{Rule: rule, Description: describeRule(rule)}
+	}
+
+	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
+	RenderTemplate(w, "rules.html", map[string]interface{}{
+		"Rules":     views,
+		"ItemTypes": ruleItemTypes,
+		"Fields":    ruleFields,
+		"Operators": ruleOperators,
+		"Actions":   ruleActions,
+		"Tags":      tagsWithCounts,
+		"ActiveTag": "",
+	})
+}
+
+// CreateRuleHandler saves a rule posted from the rules page
+func CreateRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, errs := ruleFromForm(r)
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+	if _, err := database.CreateRule(rule); err != nil {
+		http.Error(w, "Failed to save rule", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Redirect", BasePath+"/rules")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// ToggleRuleHandler turns a rule on or off (form value enabled=true|false)
+func ToggleRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := database.SetRuleEnabled(getUserID(r), id, r.FormValue("enabled") == "true"); err != nil {
+		http.Error(w, "Failed to save rule", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteRuleHandler removes a rule
+func DeleteRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := database.DeleteRule(getUserID(r), id); err != nil {
+		http.Error(w, "Failed to delete rule", http.StatusInternalServerError)
+		return
+	}
+	// An empty 200 lets htmx swap the row out
+	w.WriteHeader(http.StatusOK)
+}
+
+// ruleTestLimit caps the items a dry run lists
+const ruleTestLimit = 50
+
+// TestRuleHandler is the dry run of the rules page: it lists the existing
+// items the posted rule would change, without changing them
+func TestRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, errs := ruleFromForm(r)
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	var matches []ruleSubject
+	total := 0
+	for _, s := range ruleSubjects(rule.UserID, rule.ItemType) {
+		if ruleMatches(rule, s) && ruleApplies(rule, s) {
+			total++
+			if len(matches) < ruleTestLimit {
+				matches = append(matches, s)
+			}
+		}
+	}
+	RenderFragment(w, "rule_test_results.html", map[string]interface{}{
+		"Rule":    describeRule(rule),
+		"Matches": matches,
+		"Total":   total,
+		"Types":   ruleItemTypes,
+	})
+}
+
+// ruleSubjects returns all of the user's items of itemType ("" for all
+// types), for a dry run
+func ruleSubjects(userID int64, itemType string) []ruleSubject {
+	var subjects []ruleSubject
+	want := func(t string) bool { return itemType == "" || itemType == t }
+	if want("bookmark") {
+		bookmarks, _ := database.GetBookmarks(userID, "")
+		for _, b := range bookmarks {
+			subjects = append(subjects, ruleSubject{b.ID, "bookmark", b.Title, b.URL, b.Description, b.Tags})
+		}
+	}
+	if want("note") {
+		notes, _ := database.GetNotes(userID, "")
+		for _, n := range notes {
+			subjects = append(subjects, ruleSubject{n.ID, "note", n.Title, "", n.Content, n.Tags})
+		}
+	}
+	if want("recipe") {
+		recipes, _ := database.GetRecipes(userID, "")
+		for _, rec := range recipes {
+			subjects = append(subjects, ruleSubject{rec.ID, "recipe", rec.Title, rec.SourceURL, rec.Ingredients + "\n" + rec.Instructions, rec.Tags})
+		}
+	}
+	if want("list") {
+		lists, _ := database.GetLists(userID, "")
+		for _, l := range lists {
+			subjects = append(subjects, ruleSubject{ID: l.ID, Type: "list", Title: l.Title, Tags: l.Tags})
+		}
+	}
+	if want("rated_list") {
+		lists, _ := database.GetRatedLists(userID, "")
+		for _, l := range lists {
+			subjects = append(subjects, ruleSubject{ID: l.ID, Type: "rated_list", Title: l.Title, Tags: l.Tags})
+		}
+	}
+	if want("drawing") {
+		drawings, _ := database.GetDrawings(userID, "")
+		for _, d := range drawings {
+			subjects = append(subjects, ruleSubject{ID: d.ID, Type: "drawing", Title: d.Title, Tags: d.Tags})
+		}
+	}
+	if want("media") {
+		media, _ := database.GetMedia(userID, "")
+		for _, m := range media {
+			subjects = append(subjects, ruleSubject{ID: m.ID, Type: "media", Title: m.Title, Tags: m.Tags})
+		}
+	}
+	return subjects
+}
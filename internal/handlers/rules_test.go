@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"infokeep/internal/database"
+)
+
+func TestRuleMatches(t *testing.T) {
+	video := ruleSubject{Type: "bookmark", Title: "A talk", URL: "https://www.YouTube.com/watch?v=1", Tags: []string{"Talks"}}
+	todo := ruleSubject{Type: "note", Title: "TODO groceries", Content: "- milk\n- eggs"}
+
+	tests := []struct {
+		rule database.Rule
+		s    ruleSubject
+		want bool
+	}{
+		{database.Rule{ItemType: "bookmark", Field: "domain", Operator: "equals", Value: "youtube.com"}, video, true},
+		{database.Rule{ItemType: "note", Field: "domain", Operator: "equals", Value: "youtube.com"}, video, false},
+		{database.Rule{Field: "domain", Operator: "equals", Value: "youtube.com"}, todo, false},
+		{database.Rule{Field: "title", Operator: "starts_with", Value: "todo"}, todo, true},
+		{database.Rule{Field: "title", Operator: "ends_with", Value: "todo"}, todo, false},
+		{database.Rule{Field: "content", Operator: "contains", Value: "EGGS"}, todo, true},
+		{database.Rule{Field: "tag", Operator: "equals", Value: "talks"}, video, true},
+		{database.Rule{Field: "url", Operator: "matches", Value: `watch\?v=\d+$`}, video, true},
+		{database.Rule{Field: "url", Operator: "matches", Value: `(`}, video, false},
+	}
+	for _, tt := range tests {
+		if got := ruleMatches(tt.rule, tt.s); got != tt.want {
+			t.Errorf("ruleMatches(%s, %q) = %v, want %v", describeRule(tt.rule), tt.s.Title, got, tt.want)
+		}
+	}
+}
+
+func TestRuleApplies(t *testing.T) {
+	tagged := ruleSubject{Type: "bookmark", Tags: []string{"Video"}}
+	if ruleApplies(database.Rule{Action: "add_tag", ActionValue: "video"}, tagged) {
+		t.Error("adding a tag the item already has counts as a change")
+	}
+	if ruleApplies(database.Rule{Action: "convert_to_checklist"}, tagged) {
+		t.Error("a bookmark can be converted to a checklist")
+	}
+}
+
+func TestDescribeRule(t *testing.T) {
+	rule := database.Rule{ItemType: "bookmark", Field: "domain", Operator: "equals", Value: "youtube.com", Action: "add_tag", ActionValue: "video"}
+	want := `If a bookmark's domain is "youtube.com", add the tag video`
+	if got := describeRule(rule); got != want {
+		t.Errorf("describeRule() = %q, want %q", got, want)
+	}
+}
+
+func TestChecklistLines(t *testing.T) {
+	got := checklistLines("- milk\n\n* eggs\r\n[ ] bread\n  plain line  ")
+	want := []string{"milk", "eggs", "bread", "plain line"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checklistLines() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3-compatible object storage (AWS, MinIO, Cloudflare R2, ...) configured via
+// environment variables. Objects are addressed path-style, {endpoint}/{bucket}/{key}.
+var s3 = s3Config{
+	Endpoint:  strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+	Region:    os.Getenv("S3_REGION"),
+	Bucket:    os.Getenv("S3_BUCKET"),
+	AccessKey: os.Getenv("S3_ACCESS_KEY_ID"),
+	SecretKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+	PublicURL: strings.TrimRight(os.Getenv("S3_PUBLIC_URL"), "/"),
+}
+
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+type s3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PublicURL is where stored objects can be read from, e.g. a CDN or a
+	// public bucket URL. Defaults to the bucket on the endpoint.
+	PublicURL string
+}
+
+// s3Enabled reports whether the S3 backend is configured
+func s3Enabled() bool {
+	return s3.Endpoint != "" && s3.Bucket != "" && s3.AccessKey != "" && s3.SecretKey != ""
+}
+
+func (c s3Config) region() string {
+	if c.Region == "" {
+		return "us-east-1"
+	}
+	return c.Region
+}
+
+// objectURL returns the API URL of an object
+func (c s3Config) objectURL(key string) *url.URL {
+	u, _ := url.Parse(c.Endpoint)
+	u.Path = "/" + c.Bucket + "/" + key
+	return u
+}
+
+// publicURL returns the URL stored objects are served from
+func (c s3Config) publicURL(key string) string {
+	if c.PublicURL != "" {
+		return c.PublicURL + "/" + s3EncodePath(key)
+	}
+	return c.objectURL(key).String()
+}
+
+// presign returns a URL that allows method on u without credentials until it expires
+func (c s3Config) presign(method string, u *url.URL, expires time.Duration, now time.Time) string {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + c.region() + "/s3/aws4_request"
+
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", c.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", fmt.Sprint(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	query := s3CanonicalQuery(q)
+	canonical := strings.Join([]string{
+		method,
+		s3EncodePath(u.Path),
+		query,
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	signed := *u
+	signed.RawQuery = query + "&X-Amz-Signature=" + c.signature(canonical, amzDate, scope, now)
+	return signed.String()
+}
+
+// sign adds SigV4 authorization headers to a request with the given payload hash
+func (c s3Config) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + c.region() + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		s3EncodePath(req.URL.Path),
+		s3CanonicalQuery(req.URL.Query()),
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, c.signature(canonical, amzDate, scope, now)))
+}
+
+func (c s3Config) signature(canonicalRequest, amzDate, scope string, now time.Time) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, c.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// headObject returns the size and content type of a stored object
+func (c s3Config) headObject(key string) (int64, string, error) {
+	req, err := http.NewRequest(http.MethodHead, c.objectURL(key).String(), nil)
+	if err != nil {
+		return 0, "", err
+	}
+	emptyHash := sha256.Sum256(nil)
+	c.sign(req, hex.EncodeToString(emptyHash[:]), time.Now())
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("object storage returned %s", resp.Status)
+	}
+	return resp.ContentLength, resp.Header.Get("Content-Type"), nil
+}
+
+// getObject returns the content of a stored object, which the caller closes
+func (c s3Config) getObject(key string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, c.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	emptyHash := sha256.Sum256(nil)
+	c.sign(req, hex.EncodeToString(emptyHash[:]), time.Now())
+
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("object storage returned %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// deleteObject removes a stored object
+func (c s3Config) deleteObject(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	emptyHash := sha256.Sum256(nil)
+	c.sign(req, hex.EncodeToString(emptyHash[:]), time.Now())
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("object storage returned %s", resp.Status)
+	}
+	return nil
+}
+
+// putObject stores size bytes from body as key. The payload is sent
+// unsigned so large files can be streamed.
+func (c s3Config) putObject(key, contentType string, body io.Reader, size int64) error {
+	req, err := http.NewRequest(http.MethodPut, c.objectURL(key).String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	c.sign(req, s3UnsignedPayload, time.Now())
+
+	client := &http.Client{Timeout: 30 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("object storage returned %s", resp.Status)
+	}
+	return nil
+}
+
+// s3Object is an entry of a bucket listing
+type s3Object struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+}
+
+// listObjects returns the objects whose key starts with prefix, by key
+func (c s3Config) listObjects(prefix string) ([]s3Object, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var objects []s3Object
+	token := ""
+	for {
+		u := c.objectURL("")
+		u.Path = "/" + c.Bucket
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = s3CanonicalQuery(q)
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		emptyHash := sha256.Sum256(nil)
+		c.sign(req, hex.EncodeToString(emptyHash[:]), time.Now())
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents              []s3Object `xml:"Contents"`
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("object storage returned %s", resp.Status)
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// objectKey returns the key of the object a public URL points to, or false
+// if the URL is not one of the bucket's
+func (c s3Config) objectKey(publicURL string) (string, bool) {
+	rest, ok := strings.CutPrefix(publicURL, c.publicURL(""))
+	if !ok || rest == "" {
+		return "", false
+	}
+	key, err := url.PathUnescape(rest)
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Encode percent-encodes s the way SigV4 expects (RFC 3986 unreserved characters are kept)
+func s3Encode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// s3EncodePath encodes each segment of an object path, keeping the slashes
+func s3EncodePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = s3Encode(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func s3CanonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3Encode(k)+"="+s3Encode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
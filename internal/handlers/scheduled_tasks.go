@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/jobs"
+)
+
+// RegisterScheduledTasks adds the recurring maintenance tasks to the
+// scheduler; main starts it with jobs.Start
+func RegisterScheduledTasks(dbPath string) {
+	DBPath = dbPath
+
+	jobs.Register(jobs.Task{
+		Name:        "cloud_backups",
+		Description: "Upload the database to pCloud and Google Drive for users whose backup is due",
+		Interval:    time.Hour,
+		Run:         runCloudBackups,
+	})
+	jobs.Register(jobs.Task{
+		Name:        "session_cleanup",
+		Description: "Delete expired login sessions",
+		Interval:    6 * time.Hour,
+		Run: func() (string, error) {
+			n, err := database.DeleteExpiredSessions()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Deleted %d expired sessions", n), nil
+		},
+	})
+	jobs.Register(jobs.Task{
+		Name:        "activity_cleanup",
+		Description: fmt.Sprintf("Delete activity log entries older than %d days", activityRetentionDays),
+		Interval:    24 * time.Hour,
+		Run: func() (string, error) {
+			n, err := database.DeleteOldActivity(activityRetentionDays)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Deleted %d activity log entries", n), nil
+		},
+	})
+	jobs.Register(jobs.Task{
+		Name:        "media_fingerprints",
+		Description: "Fingerprint images that are not yet, to find duplicates",
+		Interval:    10 * time.Minute,
+		Run:         fingerprintPendingMedia,
+	})
+	jobs.Register(jobs.Task{
+		Name:        "trash_cleanup",
+		Description: fmt.Sprintf("Delete items that have been in the trash for more than %d days, and their files", trashRetentionDays),
+		Interval:    24 * time.Hour,
+		Run:         purgeExpiredTrash,
+	})
+	jobs.Register(jobs.Task{
+		Name:        "note_upload_cleanup",
+		Description: fmt.Sprintf("Delete images pasted into notes that were not saved within %d hours", noteUploadMaxAge),
+		Interval:    time.Hour,
+		Run:         removeStaleNoteUploads,
+	})
+	jobs.Register(jobs.Task{
+		Name:        "snippet_cleanup",
+		Description: "Delete snippets that have expired",
+		Interval:    15 * time.Minute,
+		Run: func() (string, error) {
+			n, err := database.DeleteExpiredSnippets()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Deleted %d expired snippets", n), nil
+		},
+	})
+	jobs.Register(jobs.Task{
+		Name:        "export_cleanup",
+		Description: "Delete data exports whose download link has expired",
+		Interval:    time.Hour,
+		Run: func() (string, error) {
+			expired, err := database.GetExpiredExports()
+			if err != nil {
+				return "", err
+			}
+			for _, e := range expired {
+				removeExport(e)
+			}
+			return fmt.Sprintf("Deleted %d expired exports", len(expired)), nil
+		},
+	})
+	jobs.Register(jobs.Task{
+		Name:        "digest_emails",
+		Description: "Email the daily and weekly digests that are due",
+		Interval:    15 * time.Minute,
+		Run:         sendDueDigests,
+	})
+	if updateCheckEnabled {
+		jobs.Register(jobs.Task{
+			Name:        "update_check",
+			Description: "Check GitHub for a newer InfoKeep release",
+			Interval:    24 * time.Hour,
+			Run:         checkForUpdate,
+		})
+	}
+	registerEmbeddingTask()
+	registerBookmarkRecheckTask()
+	registerLinkCheckTask()
+	registerAutoBackupTask()
+}
+
+// runCloudBackups runs the due pCloud and Google Drive backups
+func runCloudBackups() (string, error) {
+	// A pCloud problem shouldn't hold up the Google Drive backups
+	pcloudRan, pcloudFailed, pcloudErr := checkAndRunBackups()
+	gdriveRan, gdriveFailed, gdriveErr := checkAndRunGDriveBackups()
+	if pcloudErr != nil {
+		return "", pcloudErr
+	}
+	if gdriveErr != nil {
+		return "", gdriveErr
+	}
+
+	result := fmt.Sprintf("%d pCloud and %d Google Drive backups", pcloudRan, gdriveRan)
+	if failed := pcloudFailed + gdriveFailed; failed > 0 {
+		return "", fmt.Errorf("%s, %d failed", result, failed)
+	}
+	return result, nil
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/extensions"
+	"infokeep/internal/jobs"
+)
+
+// Semantic search finds items by meaning rather than by their words, so
+// "pasta dinner" finds a lasagne recipe. It is off unless SEMANTIC_SEARCH is
+// "on" and EMBEDDINGS_URL points at an OpenAI-compatible embeddings endpoint
+// (POST {model, input: [...]} -> {data: [{index, embedding}]}), which Ollama,
+// llama.cpp, LocalAI and OpenAI itself all serve.
+//
+// A scheduled task embeds new and changed items; searches embed the query
+// and blend the most similar items with the full-text results.
+var (
+	semanticSearchFlag = os.Getenv("SEMANTIC_SEARCH") == "on"
+	embeddingsURL      = os.Getenv("EMBEDDINGS_URL")
+	embeddingsModel    = os.Getenv("EMBEDDINGS_MODEL")
+	embeddingsAPIKey   = os.Getenv("EMBEDDINGS_API_KEY")
+)
+
+var embeddingsClient = &http.Client{Timeout: 60 * time.Second, Transport: serviceTransport("embeddings")}
+
+const (
+	// embeddingBatchSize is how many items are embedded per request
+	embeddingBatchSize = 32
+	// embeddingMaxBatches caps the requests of one run of the embedding task
+	embeddingMaxBatches = 50
+	// embeddingMaxText is how much of an item's text is embedded, in
+	// characters
+	embeddingMaxText = 8000
+	// semanticMinSimilarity is the cosine similarity below which an item is
+	// not considered a match
+	semanticMinSimilarity = 0.3
+	// semanticQueryTimeout bounds the wait for a query's embedding, after
+	// which the full-text results are shown alone
+	semanticQueryTimeout = 5 * time.Second
+	// rankFusionK damps the weight of the top ranks when blending result
+	// lists (reciprocal rank fusion)
+	rankFusionK = 60
+)
+
+func init() {
+	if embeddingsModel == "" {
+		embeddingsModel = "nomic-embed-text"
+	}
+	if semanticSearchFlag && embeddingsURL == "" {
+		log.Println("SEMANTIC_SEARCH is on but EMBEDDINGS_URL is not set, semantic search stays off")
+	}
+	if !semanticSearchEnabled() {
+		return
+	}
+	// A changed item is embedded again by the next run of the task
+	extensions.RegisterItemHook(extensions.ItemHook{
+		Name: "semantic_search",
+		Updated: func(_ context.Context, item extensions.Item) {
+			if err := database.DeleteItemEmbedding(item.ID); err != nil {
+				log.Printf("Semantic search: failed to drop the embedding of item %d: %v", item.ID, err)
+			}
+		},
+	})
+}
+
+func semanticSearchEnabled() bool {
+	return semanticSearchFlag && embeddingsURL != ""
+}
+
+// registerEmbeddingTask adds the task embedding new and changed items
+func registerEmbeddingTask() {
+	if !semanticSearchEnabled() {
+		return
+	}
+	jobs.Register(jobs.Task{
+		Name:        "embeddings",
+		Description: "Compute the embeddings of new and changed items for semantic search",
+		Interval:    5 * time.Minute,
+		Run:         embedPendingItems,
+	})
+}
+
+// embedPendingItems embeds the items without an embedding by the configured
+// model, a batch at a time
+func embedPendingItems() (string, error) {
+	embedded := 0
+	for i := 0; i < embeddingMaxBatches; i++ {
+		docs, err := database.GetItemsWithoutEmbeddings(embeddingsModel, embeddingBatchSize)
+		if err != nil {
+			return "", err
+		}
+		if len(docs) == 0 {
+			break
+		}
+		texts := make([]string, len(docs))
+		for j, doc := range docs {
+			texts[j] = truncateRunes(doc.Text, embeddingMaxText)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), embeddingsClient.Timeout)
+		vectors, err := embedTexts(ctx, texts)
+		cancel()
+		if err != nil {
+			return "", fmt.Errorf("embedded %d items, then: %w", embedded, err)
+		}
+		for j, doc := range docs {
+			if err := database.SaveItemEmbedding(doc.ID, embeddingsModel, vectors[j]); err != nil {
+				return "", err
+			}
+		}
+		embedded += len(docs)
+	}
+
+	total, err := database.CountItemEmbeddings(embeddingsModel)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Embedded %d items, %d in total", embedded, total), nil
+}
+
+// embedTexts returns the embeddings of texts, in order
+func embedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"model": embeddingsModel,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, embeddingsURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if embeddingsAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+embeddingsAPIKey)
+	}
+	resp, err := embeddingsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings endpoint returned %s", resp.Status)
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding embeddings: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings endpoint returned index %d for %d texts", d.Index, len(texts))
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embeddings endpoint returned no embedding for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// blendSemanticResults merges the items most similar in meaning to query
+// into the full-text results. When the query can't be embedded the
+// full-text results are returned as they are.
+func blendSemanticResults(userID int64, query string, results []GlobalSearchResult) []GlobalSearchResult {
+	ctx, cancel := context.WithTimeout(context.Background(), semanticQueryTimeout)
+	defer cancel()
+	vectors, err := embedTexts(withUserID(ctx, userID), []string{query})
+	if err != nil {
+		log.Printf("Semantic search: failed to embed the query: %v", err)
+		return results
+	}
+	similar, err := database.NearestItems(userID, embeddingsModel, vectors[0], semanticMinSimilarity, searchResultLimit)
+	if err != nil {
+		log.Printf("Semantic search: %v", err)
+		return results
+	}
+
+	textIDs := make([]int64, len(results))
+	for i, res := range results {
+		textIDs[i] = res.ID
+	}
+	similarIDs := make([]int64, len(similar))
+	for i, s := range similar {
+		similarIDs[i] = s.ID
+	}
+	order := fuseRankings(textIDs, similarIDs)
+	if len(order) > searchResultLimit {
+		order = order[:searchResultLimit]
+	}
+
+	byID := make(map[int64]GlobalSearchResult, len(order))
+	for _, res := range results {
+		byID[res.ID] = res
+	}
+	var missing []int64
+	for _, id := range order {
+		if _, ok := byID[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	found, err := database.GetSearchResults(userID, missing)
+	if err != nil {
+		log.Printf("Semantic search: %v", err)
+		return results
+	}
+	for _, f := range found {
+		byID[f.ID] = globalSearchResult(f)
+	}
+
+	blended := make([]GlobalSearchResult, 0, len(order))
+	for _, id := range order {
+		if res, ok := byID[id]; ok {
+			blended = append(blended, res)
+		}
+	}
+	return blended
+}
+
+// fuseRankings blends ranked lists of ids with reciprocal rank fusion: an
+// id scores 1/(k+rank) in each list it is in, and the ids are returned best
+// score first. Ties keep the order of the first list.
+func fuseRankings(rankings ...[]int64) []int64 {
+	scores := map[int64]float64{}
+	var ids []int64
+	for _, ranking := range rankings {
+		for rank, id := range ranking {
+			if _, ok := scores[id]; !ok {
+				ids = append(ids, id)
+			}
+			scores[id] += 1 / float64(rankFusionK+rank+1)
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return scores[ids[i]] > scores[ids[j]] })
+	return ids
+}
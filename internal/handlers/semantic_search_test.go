@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFuseRankings(t *testing.T) {
+	text := []int64{1, 2, 3}
+	semantic := []int64{3, 4, 1}
+	// 1 and 3 are in both lists and tie; the first list breaks the tie
+	want := []int64{1, 3, 2, 4}
+	if got := fuseRankings(text, semantic); !reflect.DeepEqual(got, want) {
+		t.Errorf("fuseRankings() = %v, want %v", got, want)
+	}
+	if got := fuseRankings(text, nil); !reflect.DeepEqual(got, text) {
+		t.Errorf("fuseRankings() with no semantic matches = %v, want %v", got, text)
+	}
+}
+
+func TestEmbedTexts(t *testing.T) {
+	var gotModel string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotModel = req.Model
+		// Out of order, as the API allows
+		w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	defer srv.Close()
+
+	defer func(u, m string) { embeddingsURL, embeddingsModel = u, m }(embeddingsURL, embeddingsModel)
+	embeddingsURL, embeddingsModel = srv.URL, "test-model"
+
+	vectors, err := embedTexts(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]float32{{1, 0}, {0, 1}}; !reflect.DeepEqual(vectors, want) {
+		t.Errorf("embedTexts() = %v, want %v", vectors, want)
+	}
+	if gotModel != "test-model" {
+		t.Errorf("model sent = %q, want test-model", gotModel)
+	}
+
+	if _, err := embedTexts(context.Background(), []string{"first", "second", "third"}); err == nil {
+		t.Error("embedTexts() succeeded with an embedding missing")
+	}
+}
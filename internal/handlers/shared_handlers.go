@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"net/http"
 	"strconv"
+	"time"
 
 	"infokeep/internal/database"
 
@@ -30,11 +31,57 @@ func generateSecureHash(length int) (string, error) {
 
 // ShareRequest represents the incoming JSON to generate a link
 type ShareRequest struct {
-	ItemType string `json:"item_type"` // note, recipe, bookmark, list
+	ItemType string `json:"item_type"` // note, recipe, bookmark, list (a checklist) or rated_list
 	ItemID   string `json:"item_id"`   // comes as string from JS sometimes, easier to parse here
+	// ExpiresInDays makes the link stop working after that many days, 0
+	// never. Left out, an existing link keeps its expiry.
+	ExpiresInDays *int `json:"expires_in_days"`
 }
 
-// GenerateShareLinkHandler creates or retrieves a public share link for an item
+// maxShareDays is the longest expiry a link can be given
+const maxShareDays = 365
+
+// shareOwned reports whether the user has an item of the type that can be
+// shared, outside the trash
+func shareOwned(userID int64, itemType string, itemID int64) (bool, error) {
+	var err error
+	switch itemType {
+	case "note":
+		_, err = database.GetNote(userID, itemID)
+	case "recipe":
+		_, err = database.GetRecipe(userID, itemID)
+	case "bookmark":
+		_, err = database.GetBookmark(userID, itemID)
+	case "list":
+		_, err = database.GetList(userID, itemID)
+	case "rated_list":
+		_, err = database.GetRatedList(userID, itemID)
+	default:
+		return false, fmt.Errorf("unsupported item type %q", itemType)
+	}
+	return err == nil, nil
+}
+
+// shareLinkJSON is what the share API returns for a link
+func shareLinkJSON(r *http.Request, link database.SharedLink) map[string]interface{} {
+	return map[string]interface{}{
+		"link_hash":  link.LinkHash,
+		"url":        getBaseURL(r) + "/shared/" + link.LinkHash,
+		"expires_at": link.ExpiresAt,
+	}
+}
+
+// shareExpiry returns when a link given days to live expires
+func shareExpiry(days int) *time.Time {
+	if days <= 0 {
+		return nil
+	}
+	t := time.Now().UTC().Add(time.Duration(days) * 24 * time.Hour)
+	return &t
+}
+
+// GenerateShareLinkHandler creates or retrieves a public share link for an
+// item, and sets when it expires
 func GenerateShareLinkHandler(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	if userID == 0 {
@@ -53,40 +100,42 @@ func GenerateShareLinkHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid item ID", http.StatusBadRequest)
 		return
 	}
+	if req.ExpiresInDays != nil && (*req.ExpiresInDays < 0 || *req.ExpiresInDays > maxShareDays) {
+		http.Error(w, fmt.Sprintf("A link can expire in at most %d days", maxShareDays), http.StatusBadRequest)
+		return
+	}
 
 	// Important: Validate that the user actually owns this item!
-	// We'll use the item-specific getter since it already checks userID
-	var exists bool
-	switch req.ItemType {
-	case "note":
-		_, err = database.GetNote(userID, itemID)
-	case "recipe":
-		_, err = database.GetRecipe(userID, itemID)
-	case "bookmark":
-		_, err = database.GetBookmark(userID, itemID)
-	case "list":
-		_, err = database.GetRatedList(userID, itemID)
-	default:
+	owned, err := shareOwned(userID, req.ItemType, itemID)
+	if err != nil {
 		http.Error(w, "Unsupported item type", http.StatusBadRequest)
 		return
 	}
-
-	if err != nil {
-		http.Error(w, "Item not found or unauthorized", http.StatusForbidden)
+	if !owned {
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
-	exists = true
-	_ = exists // used switch for side effect of checking existence
 
-	// Check if it's already shared
+	// Check if it's already shared. An expired link is replaced rather
+	// than brought back, so its old URL stays dead.
 	existingLink, err := database.GetSharedLinkByItem(req.ItemType, itemID, userID)
 	if err == nil && existingLink.LinkHash != "" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"link_hash": existingLink.LinkHash,
-			"url":       fmt.Sprintf("http://%s/shared/%s", r.Host, existingLink.LinkHash),
-		})
-		return
+		if !existingLink.Expired() {
+			if req.ExpiresInDays != nil {
+				existingLink.ExpiresAt = shareExpiry(*req.ExpiresInDays)
+				if err := database.SetSharedLinkExpiry(existingLink.LinkHash, userID, existingLink.ExpiresAt); err != nil {
+					http.Error(w, "Failed to update link", http.StatusInternalServerError)
+					return
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(shareLinkJSON(r, existingLink))
+			return
+		}
+		if err := database.DeleteSharedLink(existingLink.LinkHash, userID); err != nil {
+			http.Error(w, "Failed to replace the expired link", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Generate a new 10-character secure hash
@@ -97,7 +146,11 @@ func GenerateShareLinkHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = database.CreateSharedLink(hash, req.ItemType, itemID, userID)
+	link := database.SharedLink{LinkHash: hash}
+	if req.ExpiresInDays != nil {
+		link.ExpiresAt = shareExpiry(*req.ExpiresInDays)
+	}
+	err = database.CreateSharedLink(hash, req.ItemType, itemID, userID, link.ExpiresAt)
 	if err != nil {
 		log.Printf("Failed to save shared link: %v", err)
 		http.Error(w, "Failed to create link", http.StatusInternalServerError)
@@ -105,10 +158,25 @@ func GenerateShareLinkHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"link_hash": hash,
-		"url":       fmt.Sprintf("http://%s/shared/%s", r.Host, hash),
-	})
+	json.NewEncoder(w).Encode(shareLinkJSON(r, link))
+}
+
+// GetShareLinkHandler returns the link an item is shared under, given by
+// the item_type and item_id query parameters, or 404 if it isn't shared
+func GetShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	itemID, err := parseID(r.URL.Query().Get("item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	link, err := database.GetSharedLinkByItem(r.URL.Query().Get("item_type"), itemID, userID)
+	if err != nil || link.Expired() {
+		http.Error(w, "Not shared", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shareLinkJSON(r, link))
 }
 
 // RevokeShareLinkHandler deletes a shared link
@@ -150,6 +218,10 @@ func PublicViewHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "This link is invalid or has been revoked.", http.StatusNotFound)
 		return
 	}
+	if link.Expired() {
+		http.Error(w, "This link has expired.", http.StatusGone)
+		return
+	}
 
 	// 2. Fetch the corresponding item data and render the public template
 	switch link.ItemType {
@@ -169,8 +241,12 @@ func PublicViewHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Recipe not found", http.StatusNotFound)
 			return
 		}
+		comments, _ := database.GetApprovedComments(link.ItemID)
 		RenderPublicTemplate(w, "public_recipe.html", map[string]interface{}{
-			"Recipe": recipe,
+			"Recipe":        recipe,
+			"Hash":          link.LinkHash,
+			"Comments":      comments,
+			"CommentStatus": r.URL.Query().Get("comment"),
 		})
 
 	case "bookmark":
@@ -184,6 +260,22 @@ func PublicViewHandler(w http.ResponseWriter, r *http.Request) {
 		})
 
 	case "list":
+		list, err := database.GetList(link.UserID, link.ItemID)
+		if err != nil {
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		}
+		items, err := database.GetListItems(link.ItemID)
+		if err != nil {
+			http.Error(w, "List items not found", http.StatusNotFound)
+			return
+		}
+		RenderPublicTemplate(w, "public_checklist.html", map[string]interface{}{
+			"List":  list,
+			"Items": items,
+		})
+
+	case "rated_list":
 		list, err := database.GetRatedList(link.UserID, link.ItemID)
 		if err != nil {
 			http.Error(w, "List not found", http.StatusNotFound)
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Recipe and cookbook pages can be linked as /recipes/42-butter-chicken
+// instead of /recipes/42. Only the number identifies the item; the slug is
+// there for people reading the link and is ignored, so links keep working
+// when the title changes.
+
+// maxSlugLength caps the slug part of a URL, in characters
+const maxSlugLength = 60
+
+var errInvalidID = errors.New("invalid ID")
+
+// slugify turns a title into lowercase words joined by dashes, e.g.
+// "Butter Chicken (Mom's)" -> "butter-chicken-mom-s". Letters of any script
+// are kept; everything else separates words.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = b.Len() > 0
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteRune(r)
+	}
+	slug := b.String()
+	if runes := []rune(slug); len(runes) > maxSlugLength {
+		slug = strings.TrimRight(string(runes[:maxSlugLength]), "-")
+	}
+	return slug
+}
+
+// itemSlug is the URL path segment of an item: "42-butter-chicken", or just
+// "42" when the title has nothing to make a slug from. Used in templates as
+// {{slug .id .title}}.
+func itemSlug(id int64, title string) string {
+	s := strconv.FormatInt(id, 10)
+	if slug := slugify(title); slug != "" {
+		s += "-" + slug
+	}
+	return s
+}
+
+// parseID parses an item ID from a URL segment, with or without a slug
+func parseID(segment string) (int64, error) {
+	if i := strings.IndexByte(segment, '-'); i > 0 {
+		segment = segment[:i]
+	}
+	id, err := strconv.ParseInt(segment, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errInvalidID
+	}
+	return id, nil
+}
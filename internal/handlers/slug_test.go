@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Butter Chicken", "butter-chicken"},
+		{"  Mom's (best) Lasagna!! ", "mom-s-best-lasagna"},
+		{"Crème Brûlée", "crème-brûlée"},
+		{"2-Minute Noodles", "2-minute-noodles"},
+		{"!!!", ""},
+		{strings.Repeat("ab ", 40), strings.TrimRight(strings.Repeat("ab-", 20), "-")},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestItemSlug(t *testing.T) {
+	if got := itemSlug(42, "Butter Chicken"); got != "42-butter-chicken" {
+		t.Errorf("itemSlug = %q", got)
+	}
+	if got := itemSlug(42, "?"); got != "42" {
+		t.Errorf("itemSlug without title = %q", got)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"42", 42, true},
+		{"42-butter-chicken", 42, true},
+		{"42-", 42, true},
+		{"", 0, false},
+		{"0", 0, false},
+		{"-42", 0, false},
+		{"abc", 0, false},
+		{"butter-chicken", 0, false},
+		{"4 2", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseID(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseID(%q) = %d, %v; want %d, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
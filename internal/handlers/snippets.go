@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Snippet endpoints of the versioned API: a paste bin for moving text between
+// devices. A snippet is posted from one device, which gets back a short code,
+// and fetched with the code from another one.
+
+const (
+	// snippetCodeChars leaves out characters that are easily mistaken for
+	// others (0/o, 1/l/i) when a code is read off one screen and typed on
+	// another
+	snippetCodeChars  = "abcdefghjkmnpqrstuvwxyz23456789"
+	snippetCodeLength = 6
+	defaultSnippetTTL = 24 * time.Hour
+	maxSnippetTTL     = 30 * 24 * time.Hour
+)
+
+// snippetResponse is a snippet as the API returns it
+type snippetResponse struct {
+	Code          string    `json:"code"`
+	URL           string    `json:"url"`
+	Text          string    `json:"text,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	BurnAfterRead bool      `json:"burn_after_read"`
+}
+
+// ApiCreateSnippetHandler stores a snippet. The body is either JSON
+// ({"text": "...", "ttl": "10m", "burn_after_read": true}) or the text itself
+// (text/plain) with the options in the query string (?ttl=10m&burn=1).
+func ApiCreateSnippetHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Text          string `json:"text"`
+		TTL           string `json:"ttl"`
+		BurnAfterRead bool   `json:"burn_after_read"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/plain" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Snippet too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		input.Text = string(body)
+		input.TTL = r.URL.Query().Get("ttl")
+		input.BurnAfterRead, _ = strconv.ParseBool(r.URL.Query().Get("burn"))
+	} else if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var v validation.Validator
+	if strings.TrimSpace(input.Text) == "" {
+		v.Add("text", "is required")
+	}
+	input.Text = v.MaxLength("text", input.Text, maxLongText)
+	ttl, err := parseSnippetTTL(input.TTL)
+	if err != nil {
+		v.Add("ttl", err.Error())
+	}
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+
+	userID := getUserID(r)
+	var snippet *database.Snippet
+	// A new code in the unlikely case the first is taken
+	for attempt := 0; attempt < 3; attempt++ {
+		var code string
+		if code, err = newSnippetCode(); err != nil {
+			break
+		}
+		snippet, err = database.CreateSnippet(userID, code, input.Text, ttl, input.BurnAfterRead)
+		if !errors.Is(err, database.ErrSnippetCodeTaken) {
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to create snippet for user %d: %v", userID, err)
+		http.Error(w, "Failed to create snippet", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snippetJSON(snippet, false))
+}
+
+// ApiGetSnippetHandler returns a snippet by its code, as JSON or, with ?raw,
+// as plain text. A snippet to burn after reading is gone afterwards.
+func ApiGetSnippetHandler(w http.ResponseWriter, r *http.Request) {
+	snippet, err := database.ReadSnippet(getUserID(r), snippetCode(r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Snippet not found or expired", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Query().Has("raw") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, snippet.Content)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snippetJSON(snippet, true))
+}
+
+// ApiDeleteSnippetHandler deletes a snippet before it expires
+func ApiDeleteSnippetHandler(w http.ResponseWriter, r *http.Request) {
+	err := database.DeleteSnippet(getUserID(r), snippetCode(r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Snippet not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func snippetJSON(s *database.Snippet, withText bool) snippetResponse {
+	resp := snippetResponse{
+		Code:          s.Code,
+		URL:           appURL("/api/v1/snippets/" + s.Code),
+		CreatedAt:     s.CreatedAt,
+		ExpiresAt:     s.ExpiresAt,
+		BurnAfterRead: s.BurnAfterRead,
+	}
+	if withText {
+		resp.Text = s.Content
+	}
+	return resp
+}
+
+// snippetCode is the {code} URL parameter; codes are typed by hand, so case
+// doesn't matter
+func snippetCode(r *http.Request) string {
+	return strings.ToLower(strings.TrimSpace(chi.URLParam(r, "code")))
+}
+
+func newSnippetCode() (string, error) {
+	b := make([]byte, snippetCodeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(snippetCodeChars))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = snippetCodeChars[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// parseSnippetTTL reads how long a snippet is kept: seconds ("600"), a
+// duration ("10m", "2h") or days ("7d"); defaultSnippetTTL when empty
+func parseSnippetTTL(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultSnippetTTL, nil
+	}
+	var ttl time.Duration
+	if n, err := strconv.Atoi(s); err == nil {
+		ttl = time.Duration(n) * time.Second
+	} else if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("must be a duration such as 600, 10m, 2h or 7d")
+		}
+		ttl = time.Duration(n) * 24 * time.Hour
+	} else if ttl, err = time.ParseDuration(s); err != nil {
+		return 0, fmt.Errorf("must be a duration such as 600, 10m, 2h or 7d")
+	}
+	if ttl < time.Minute || ttl > maxSnippetTTL {
+		return 0, fmt.Errorf("must be between 1 minute and %d days", int(maxSnippetTTL/(24*time.Hour)))
+	}
+	return ttl, nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSnippetTTL(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":     defaultSnippetTTL,
+		"600":  10 * time.Minute,
+		"10m":  10 * time.Minute,
+		"2h":   2 * time.Hour,
+		"7d":   7 * 24 * time.Hour,
+		" 1h ": time.Hour,
+	}
+	for in, want := range tests {
+		if got, err := parseSnippetTTL(in); err != nil || got != want {
+			t.Errorf("parseSnippetTTL(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"30", "30s", "31d", "-5m", "soon", "xd"} {
+		if _, err := parseSnippetTTL(in); err == nil {
+			t.Errorf("parseSnippetTTL(%q) succeeded", in)
+		}
+	}
+}
+
+func TestNewSnippetCode(t *testing.T) {
+	code, err := newSnippetCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != snippetCodeLength {
+		t.Errorf("newSnippetCode() = %q, want %d characters", code, snippetCodeLength)
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(snippetCodeChars, c) {
+			t.Errorf("newSnippetCode() = %q has %q", code, c)
+		}
+	}
+}
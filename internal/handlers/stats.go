@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+)
+
+const (
+	// statsWeeks is how many weeks of additions the statistics cover
+	statsWeeks = 12
+	// statsTopTags is how many of the most used tags they list
+	statsTopTags = 10
+)
+
+// userStats returns the statistics of a user's items, with the size of
+// their files in the uploads folder. Files in object storage are counted
+// but not sized, which would take a request for each.
+func userStats(userID int64) (*database.UserStats, error) {
+	stats, err := database.GetUserStats(userID, statsWeeks, statsTopTags, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	files, err := database.GetUserFiles(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range files {
+		if name, ok := uploadedFileName(p); ok {
+			stats.Files++
+			if info, err := os.Stat(filepath.Join(datadir.Uploads(), name)); err == nil {
+				stats.StorageBytes += info.Size()
+			}
+			continue
+		}
+		if s3Enabled() {
+			if _, ok := s3.objectKey(p); ok {
+				stats.Files++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// weekBar is a week of the dashboard's chart of items added per week
+type weekBar struct {
+	Week    string
+	Count   int64
+	Percent int // height of the bar, relative to the busiest week
+}
+
+// weekBars scales the weeks for the chart
+func weekBars(weeks []database.WeekCount) []weekBar {
+	var most int64
+	for _, w := range weeks {
+		most = max(most, w.Count)
+	}
+	bars := make([]weekBar, len(weeks))
+	for i, w := range weeks {
+		bars[i] = weekBar{Week: w.Week, Count: w.Count}
+		if most > 0 {
+			bars[i].Percent = int(w.Count * 100 / most)
+		}
+	}
+	return bars
+}
+
+// readingBar is a week of the dashboard's chart of bookmarks saved to read
+// later and read
+type readingBar struct {
+	database.ReadingWeek
+	SavedPercent, ReadPercent int // heights of the bars, relative to the busiest week
+}
+
+// readingBars scales the weeks for the reading chart. It returns nil when
+// nothing was saved or read in any of them, and there is no chart.
+func readingBars(weeks []database.ReadingWeek) []readingBar {
+	var most int64
+	for _, w := range weeks {
+		most = max(most, w.Saved, w.Read)
+	}
+	if most == 0 {
+		return nil
+	}
+	bars := make([]readingBar, len(weeks))
+	for i, w := range weeks {
+		bars[i] = readingBar{
+			ReadingWeek:  w,
+			SavedPercent: int(w.Saved * 100 / most),
+			ReadPercent:  int(w.Read * 100 / most),
+		}
+	}
+	return bars
+}
+
+// ApiStatsHandler returns the statistics of the user's items
+func ApiStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := userStats(getUserID(r))
+	if err != nil {
+		log.Printf("Failed to compute stats for user %d: %v", getUserID(r), err)
+		http.Error(w, "Failed to compute stats", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
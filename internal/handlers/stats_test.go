@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"infokeep/internal/database"
+)
+
+func TestWeekBars(t *testing.T) {
+	got := weekBars([]database.WeekCount{{Week: "2024-05-06", Count: 2}, {Week: "2024-05-13"}, {Week: "2024-05-20", Count: 8}})
+	want := []weekBar{{"2024-05-06", 2, 25}, {"2024-05-13", 0, 0}, {"2024-05-20", 8, 100}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("weekBars = %v, want %v", got, want)
+	}
+
+	for _, b := range weekBars([]database.WeekCount{{Week: "2024-05-06"}}) {
+		if b.Percent != 0 {
+			t.Errorf("weekBars of an empty week = %d%%, want 0", b.Percent)
+		}
+	}
+}
+
+func TestReadingBars(t *testing.T) {
+	got := readingBars([]database.ReadingWeek{{Week: "2024-05-06", Saved: 4, Read: 1}, {Week: "2024-05-13", Read: 2}})
+	want := []readingBar{
+		{ReadingWeek: database.ReadingWeek{Week: "2024-05-06", Saved: 4, Read: 1}, SavedPercent: 100, ReadPercent: 25},
+		{ReadingWeek: database.ReadingWeek{Week: "2024-05-13", Read: 2}, SavedPercent: 0, ReadPercent: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readingBars = %v, want %v", got, want)
+	}
+
+	if got := readingBars([]database.ReadingWeek{{Week: "2024-05-06"}}); got != nil {
+		t.Errorf("readingBars of empty weeks = %v, want nil", got)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"infokeep/internal/buildinfo"
+)
+
+// StatusPath is where the public status page is served, for uptime monitors
+// and for users wondering whether the instance is down. It shows uptime,
+// version and whether each component is healthy, and nothing about users
+// or their data. STATUS_PAGE moves it, or turns it off with "off".
+var StatusPath = "/status"
+
+// statusRateLimit is how many status requests one client may make per
+// statusRateWindow; monitors poll far less often
+const (
+	statusRateLimit  = 30
+	statusRateWindow = time.Minute
+)
+
+var statusLimiter = newRateLimiter(statusRateLimit, statusRateWindow)
+
+func init() {
+	switch v := os.Getenv("STATUS_PAGE"); {
+	case v == "off":
+		StatusPath = ""
+	case v != "":
+		StatusPath = "/" + strings.Trim(v, "/")
+	}
+}
+
+// statusComponent is one line of the status page
+type statusComponent struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+}
+
+// StatusHandler serves the status page, or JSON to clients that ask for it
+// with Accept: application/json. It answers 503 when a component is down or
+// maintenance mode is on, so monitors that only look at the status code
+// work too.
+func StatusHandler(w http.ResponseWriter, r *http.Request) {
+	if !statusLimiter.allow(clientIP(r)) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	checks, _, ready := runReadinessChecks(r.Context())
+	var components []statusComponent
+	for name, c := range checks {
+		// Only ok or not: check errors can include paths and other internals
+		components = append(components, statusComponent{Name: name, OK: c.Status == "ok"})
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].Name < components[j].Name })
+
+	maintenanceOn, _ := maintenanceMode()
+	status, code := "operational", http.StatusOK
+	switch {
+	case !ready:
+		status, code = "degraded", http.StatusServiceUnavailable
+	case maintenanceOn:
+		status, code = "maintenance", http.StatusServiceUnavailable
+	}
+
+	uptime := time.Since(startTime).Round(time.Second)
+	w.Header().Set("Cache-Control", "no-store")
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":         status,
+			"version":        buildinfo.Version,
+			"started_at":     startTime.UTC(),
+			"uptime_seconds": int64(uptime.Seconds()),
+			"components":     components,
+		})
+		return
+	}
+
+	w.WriteHeader(code)
+	RenderPublicTemplate(w, "public_status.html", map[string]interface{}{
+		"Status":     status,
+		"Version":    buildinfo.Version,
+		"StartedAt":  startTime.UTC().Format("2006-01-02 15:04 MST"),
+		"Uptime":     uptime.String(),
+		"Components": components,
+	})
+}
+
+// rateLimiter allows each client limit requests per fixed window. All
+// clients share the window, so the counts can simply be dropped when a new
+// one starts.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	start  time.Time
+	counts map[string]int
+	now    func() time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, counts: map[string]int{}, now: time.Now}
+}
+
+// allow counts a request of client and reports whether it is within the limit
+func (l *rateLimiter) allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := l.now(); now.Sub(l.start) >= l.window {
+		l.start = now
+		l.counts = map[string]int{}
+	}
+	l.counts[client]++
+	return l.counts[client] <= l.limit
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i, want := range []bool{true, true, false} {
+		if got := l.allow("1.2.3.4"); got != want {
+			t.Errorf("request %d: allow = %v, want %v", i+1, got, want)
+		}
+	}
+	if !l.allow("5.6.7.8") {
+		t.Error("another client should have its own count")
+	}
+
+	now = now.Add(time.Minute)
+	if !l.allow("1.2.3.4") {
+		t.Error("a new window should reset the count")
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+// The summarize action of bookmark and note cards asks a language model for
+// a short summary of the bookmarked article or the note, and keeps it on the
+// card. It uses an OpenAI-compatible chat completions endpoint
+// (POST {model, messages} -> {choices: [{message: {content}}]}), such as
+// Ollama's, and is hidden unless SUMMARIZE_URL is set: nothing is sent
+// anywhere by default.
+var (
+	summarizeURL    = os.Getenv("SUMMARIZE_URL")
+	summarizeModel  = os.Getenv("SUMMARIZE_MODEL")
+	summarizeAPIKey = os.Getenv("SUMMARIZE_API_KEY")
+)
+
+var summarizeClient = &http.Client{Timeout: 2 * time.Minute, Transport: serviceTransport("summarize")}
+
+const (
+	// summarizeMaxText is how much of an article or note is sent, in
+	// characters
+	summarizeMaxText = 12000
+	// summarizeMaxPage caps the size of a bookmarked page that is read
+	summarizeMaxPage = 2 << 20
+	// summarizePrompt is the instruction the text is sent with
+	summarizePrompt = "Summarize the following text in two to four sentences. " +
+		"Reply with the summary only, in the language of the text."
+)
+
+func init() {
+	if summarizeModel == "" {
+		summarizeModel = "llama3.2"
+	}
+}
+
+func summarizeEnabled() bool {
+	return summarizeURL != ""
+}
+
+// SummarizeItemHandler summarizes a bookmark or note and returns its card
+// with the summary
+func SummarizeItemHandler(w http.ResponseWriter, r *http.Request) {
+	if !summarizeEnabled() {
+		http.Error(w, "Summaries are not set up on this server", http.StatusNotFound)
+		return
+	}
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok || !requireOwnership(w, id, userID) {
+		return
+	}
+
+	ctx := withUserID(r.Context(), userID)
+	var text string
+	note, err := database.GetNote(userID, id)
+	bookmark, bookmarkErr := database.GetBookmark(userID, id)
+	switch {
+	case err == nil:
+		text = note.Title + "\n\n" + note.Content
+	case bookmarkErr == nil:
+		text = bookmarkText(ctx, bookmark)
+	case err == sql.ErrNoRows && bookmarkErr == sql.ErrNoRows:
+		http.Error(w, "Only bookmarks and notes can be summarized", http.StatusBadRequest)
+		return
+	default:
+		http.Error(w, "Failed to load item", http.StatusInternalServerError)
+		return
+	}
+
+	summary, err := summarizeText(ctx, text)
+	if err != nil {
+		log.Printf("Summarize: item %d: %v", id, err)
+		http.Error(w, "The summary could not be made: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := database.SetItemSummary(userID, id, summary); err != nil {
+		http.Error(w, "Failed to save summary", http.StatusInternalServerError)
+		return
+	}
+
+	if note != nil {
+		note.Summary = summary
+		RenderFragment(w, "note_list.html", []models.Note{*note})
+		return
+	}
+	bookmark.Summary = summary
+	RenderFragment(w, "bookmark_list.html", []models.Bookmark{*bookmark})
+}
+
+// bookmarkText returns the article text of a bookmarked page, or the
+// bookmark's title and description when the page can't be read
+func bookmarkText(ctx context.Context, b *models.Bookmark) string {
+	article, err := fetchPageText(ctx, summarizeClient, b.CanonicalURL, summarizeMaxPage)
+	if err != nil || article == "" {
+		return b.Title + "\n\n" + b.Description
+	}
+	return b.Title + "\n\n" + article
+}
+
+// summarizeText asks the configured model for a summary of text
+func summarizeText(ctx context.Context, text string) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"model": summarizeModel,
+		"messages": []map[string]string{
+			{"role": "system", "content": summarizePrompt},
+			{"role": "user", "content": truncateRunes(strings.TrimSpace(text), summarizeMaxText)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, summarizeURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if summarizeAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+summarizeAPIKey)
+	}
+	resp, err := summarizeClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+	if len(result.Choices) == 0 || strings.TrimSpace(result.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("endpoint returned no summary")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
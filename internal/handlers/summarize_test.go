@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSummarizeText(t *testing.T) {
+	var got struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "  A short summary.\n"}}]}`))
+	}))
+	defer srv.Close()
+	defer func(u string) { summarizeURL = u }(summarizeURL)
+	summarizeURL = srv.URL
+
+	summary, err := summarizeText(context.Background(), "  The long text.  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "A short summary." {
+		t.Errorf("summarizeText() = %q, want %q", summary, "A short summary.")
+	}
+	if got.Model != summarizeModel || len(got.Messages) != 2 || got.Messages[1].Content != "The long text." {
+		t.Errorf("request = %+v, want the model, the prompt and the trimmed text", got)
+	}
+}
+
+func TestSummarizeTextWithoutAnswer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices": []}`))
+	}))
+	defer srv.Close()
+	defer func(u string) { summarizeURL = u }(summarizeURL)
+	summarizeURL = srv.URL
+
+	if _, err := summarizeText(context.Background(), "text"); err == nil {
+		t.Error("summarizeText() succeeded without a summary in the response")
+	}
+}
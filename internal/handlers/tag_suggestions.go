@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"infokeep/internal/database"
+	"infokeep/internal/validation"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// A bookmark's tags can be suggested from its page: the keywords the page
+// declares (scraper.Metadata.Keywords) and the user's tags that its title or
+// description mention. Tags the user already has come first, so suggestions
+// don't spread near-duplicate tags. The bookmark form shows them as chips to
+// add with one click, and the clipper returns them with a new bookmark.
+
+// maxSuggestedTags is how many tags are suggested for a page
+const maxSuggestedTags = 8
+
+// suggestTags suggests tags for a bookmark of the page, leaving out the ones
+// in have
+func suggestTags(userID int64, page bookmarkPage, have []string) []string {
+	userTags, _ := database.GetAllUniqueTags(userID)
+	var known, unknown []string
+	for _, k := range page.Keywords {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" || len(k) > maxTitleLength {
+			continue
+		}
+		if slices.Contains(userTags, k) {
+			known = append(known, k)
+		} else {
+			unknown = append(unknown, k)
+		}
+	}
+	// The title and description as their words between spaces, to find
+	// whole tags of one or more words in
+	words := strings.FieldsFunc(strings.ToLower(page.Title+" "+page.Description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	text := " " + strings.Join(words, " ") + " "
+	for _, tag := range userTags {
+		if strings.Contains(text, " "+tag+" ") {
+			known = append(known, tag)
+		}
+	}
+
+	suggestions := []string{}
+	for _, tag := range append(known, unknown...) {
+		if len(suggestions) == maxSuggestedTags {
+			break
+		}
+		had := slices.ContainsFunc(have, func(h string) bool { return strings.EqualFold(h, tag) })
+		if !had && !slices.Contains(suggestions, tag) {
+			suggestions = append(suggestions, tag)
+		}
+	}
+	return suggestions
+}
+
+// SuggestTagsHandler returns the tags suggested for a bookmark's page, as a
+// JSON list: for /bookmarks/{id}/suggest-tags the page of that bookmark,
+// leaving out the tags it has, and for /bookmarks/suggest-tags?url= the page
+// at url, before it is bookmarked
+func SuggestTagsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	var pageURL string
+	var have []string
+	if chi.URLParam(r, "id") != "" {
+		id, ok := pathID(w, r, "id")
+		if !ok {
+			return
+		}
+		bookmark, err := database.GetBookmark(userID, id)
+		if err != nil {
+			http.Error(w, "Bookmark not found", http.StatusNotFound)
+			return
+		}
+		pageURL, have = bookmark.URL, bookmark.Tags
+	} else {
+		var v validation.Validator
+		pageURL = v.URL("url", v.Required("url", r.URL.Query().Get("url"), 0))
+		if !v.Valid() {
+			writeValidationErrors(w, v.Errors())
+			return
+		}
+	}
+
+	page := fetchBookmarkPage(withUserID(r.Context(), userID), pageURL)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestTags(userID, page, have))
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"infokeep/web"
+)
+
+// Templates are parsed once, by LoadTemplates at startup, and kept in
+// templateCache. Pages are parsed together with their layout and all
+// fragments; fragments are also parsed on their own for RenderFragment.
+// They are read from the copies embedded in the binary.
+//
+// With TEMPLATE_RELOAD set, they are read from web/templates instead and a
+// template set whose files changed on disk is parsed again before it is
+// rendered, so template edits show up without a restart during development.
+var templateReload = os.Getenv("TEMPLATE_RELOAD") != ""
+
+var templateFS = func() fs.FS {
+	if templateReload {
+		return os.DirFS(filepath.Join("web", "templates"))
+	}
+	return web.Templates
+}()
+
+// cachedTemplate is a parsed template set and the files it was parsed from
+type cachedTemplate struct {
+	t       *template.Template
+	files   []string
+	modTime time.Time // latest modification time of files
+}
+
+var templateCache = struct {
+	sync.RWMutex
+	sets map[string]*cachedTemplate
+}{sets: map[string]*cachedTemplate{}}
+
+// LoadTemplates parses every page and fragment in web/templates into the
+// template cache, so that broken templates are reported at startup
+func LoadTemplates() error {
+	pages, err := fs.Glob(templateFS, "*.html")
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		name := page
+		if name == "layout.html" || name == "public_layout.html" {
+			continue
+		}
+		layout := "layout.html"
+		if strings.HasPrefix(name, "public_") {
+			layout = "public_layout.html"
+		}
+		if _, err := getTemplate(layout, name); err != nil {
+			return err
+		}
+	}
+
+	fragments, err := fs.Glob(templateFS, "fragments/*.html")
+	if err != nil {
+		return err
+	}
+	for _, fragment := range fragments {
+		if _, err := getTemplate("", path.Base(fragment)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getTemplate returns the parsed template set of a page rendered in layout,
+// or of a fragment on its own when layout is empty. Sets missing from the
+// cache (or changed on disk, in reload mode) are parsed and cached.
+func getTemplate(layout, name string) (*template.Template, error) {
+	key := layout + ":" + name
+
+	templateCache.RLock()
+	cached := templateCache.sets[key]
+	templateCache.RUnlock()
+	if cached != nil && (!templateReload || !templateChanged(cached)) {
+		return cached.t, nil
+	}
+
+	cached, err := parseTemplate(layout, name)
+	if err != nil {
+		return nil, err
+	}
+	templateCache.Lock()
+	templateCache.sets[key] = cached
+	templateCache.Unlock()
+	return cached.t, nil
+}
+
+// parseTemplate parses a page with its layout and all fragments, or a single
+// fragment when layout is empty
+func parseTemplate(layout, name string) (*cachedTemplate, error) {
+	var files []string
+	if layout == "" {
+		files = []string{"fragments/" + name}
+	} else {
+		files = []string{layout, name}
+		fragments, _ := fs.Glob(templateFS, "fragments/*.html")
+		files = append(files, fragments...)
+	}
+
+	modTime, err := latestModTime(files)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(path.Base(files[0])).Funcs(templateFuncs).ParseFS(templateFS, files...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return &cachedTemplate{t: t, files: files, modTime: modTime}, nil
+}
+
+// templateChanged reports whether any file of a cached set was modified, or
+// a fragment added or removed, since it was parsed
+func templateChanged(c *cachedTemplate) bool {
+	if len(c.files) > 1 {
+		fragments, _ := fs.Glob(templateFS, "fragments/*.html")
+		if len(fragments) != len(c.files)-2 {
+			return true
+		}
+	}
+	modTime, err := latestModTime(c.files)
+	if err != nil {
+		log.Printf("Template reload: %v", err)
+		return true
+	}
+	return modTime.After(c.modTime)
+}
+
+func latestModTime(files []string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := fs.Stat(templateFS, f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"infokeep/internal/models"
+)
+
+// useRepoTemplates reads the templates from web/templates rather than the
+// embedded copies, like TEMPLATE_RELOAD does
+func useRepoTemplates(tb testing.TB) {
+	old := templateFS
+	templateFS = os.DirFS(filepath.Join("..", "..", "web", "templates"))
+	tb.Cleanup(func() { templateFS = old })
+}
+
+func TestLoadTemplates(t *testing.T) {
+	useRepoTemplates(t)
+	if err := LoadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var dashboardData = map[string]interface{}{
+	"Bookmarks": []models.Bookmark{{
+		Item:         models.Item{ID: 1, Title: "Example", Tags: []string{"web"}},
+		URL:          "https://example.com/?utm_source=x",
+		CanonicalURL: "https://example.com/",
+	}},
+	"Notes":     []models.Note{{Item: models.Item{ID: 2, Title: "Note"}, Content: "Text"}},
+	"Pinned":    []models.PinnedItem{{ID: 1, Type: "bookmark", Title: "Example", URL: "https://example.com/"}},
+	"Tags":      []map[string]interface{}{},
+	"ActiveTag": "",
+}
+
+// TestRenderDashboard catches templates that use fields the models don't
+// have, which only fails when a template is executed
+func TestRenderDashboard(t *testing.T) {
+	useRepoTemplates(t)
+	tmpl, err := getTemplate("layout.html", "index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.ExecuteTemplate(io.Discard, "layout.html", dashboardData); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkDashboardCached renders the dashboard from the template cache
+func BenchmarkDashboardCached(b *testing.B) {
+	useRepoTemplates(b)
+	for i := 0; i < b.N; i++ {
+		t, err := getTemplate("layout.html", "index.html")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := t.ExecuteTemplate(io.Discard, "layout.html", dashboardData); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDashboardParsed parses the dashboard's templates for every
+// render, as RenderTemplate used to
+func BenchmarkDashboardParsed(b *testing.B) {
+	useRepoTemplates(b)
+	for i := 0; i < b.N; i++ {
+		c, err := parseTemplate("layout.html", "index.html")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := c.t.ExecuteTemplate(io.Discard, "layout.html", dashboardData); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
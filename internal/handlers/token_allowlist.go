@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"infokeep/internal/database"
+)
+
+// parseAllowlist parses CIDR ranges (or single addresses) separated by
+// newlines or commas.
+func parseAllowlist(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ',' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ipAllowed reports whether ip falls within any of the ranges
+func ipAllowed(ip string, nets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenAllowedFrom checks the user's API token allowlist; tokens without an
+// allowlist can be used from anywhere.
+func tokenAllowedFrom(userID int64, ip string) bool {
+	allowlist := database.GetTokenAllowlist(userID)
+	if allowlist == "" {
+		return true
+	}
+	nets, err := parseAllowlist(allowlist)
+	if err != nil {
+		// Stored lists are validated on save; fail closed if one is corrupt
+		log.Printf("Invalid API token allowlist for user %d: %v", userID, err)
+		return false
+	}
+	return ipAllowed(ip, nets)
+}
+
+// TokenAllowlistHandler saves the CIDR allowlist for the user's API token
+func TokenAllowlistHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	nets, err := parseAllowlist(r.FormValue("allowlist"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Store the normalized form, one range per line
+	ranges := make([]string, 0, len(nets))
+	for _, n := range nets {
+		ranges = append(ranges, n.String())
+	}
+	allowlist := strings.Join(ranges, "\n")
+
+	if err := database.SetTokenAllowlist(userID, allowlist); err != nil {
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"allowlist": allowlist})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import "testing"
+
+func TestParseAllowlist(t *testing.T) {
+	nets, err := parseAllowlist("10.0.0.0/8, 192.168.1.20\n2001:db8::/32\n")
+	if err != nil {
+		t.Fatalf("parseAllowlist: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.20", true},
+		{"192.168.1.21", false},
+		{"2001:db8::1", true},
+		{"8.8.8.8", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := ipAllowed(tt.ip, nets); got != tt.want {
+			t.Errorf("ipAllowed(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if _, err := parseAllowlist("10.0.0.0/33"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
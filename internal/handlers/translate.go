@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Recipe translation uses a LibreTranslate-compatible endpoint
+// (POST {q, source, target, format, api_key} -> {translatedText, detectedLanguage}).
+// It is disabled unless TRANSLATE_URL is set.
+var (
+	translateURL    = os.Getenv("TRANSLATE_URL")
+	translateAPIKey = os.Getenv("TRANSLATE_API_KEY")
+	translateTarget = os.Getenv("TRANSLATE_TARGET")
+)
+
+var translateClient = &http.Client{Timeout: 30 * time.Second, Transport: serviceTransport("translate")}
+
+func init() {
+	if translateTarget == "" {
+		translateTarget = "en"
+	}
+}
+
+func translationEnabled() bool {
+	return translateURL != ""
+}
+
+// RecipeOriginal holds the untranslated text of an imported recipe
+type RecipeOriginal struct {
+	Language     string `json:"original_language"`
+	Ingredients  string `json:"original_ingredients"`
+	Instructions string `json:"original_instructions"`
+}
+
+// baseLanguage reduces a language tag like "fr-FR" to "fr"
+func baseLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// translateText translates text from source ("auto" to detect) into the
+// configured target language, returning the translation and source language.
+func translateText(ctx context.Context, text, source string) (string, string, error) {
+	payload, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  source,
+		"target":  translateTarget,
+		"format":  "text",
+		"api_key": translateAPIKey,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, translateURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := translateClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		TranslatedText   string `json:"translatedText"`
+		Error            string `json:"error"`
+		DetectedLanguage struct {
+			Language string `json:"language"`
+		} `json:"detectedLanguage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", "", fmt.Errorf("invalid response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("status %d: %s", resp.StatusCode, result.Error)
+	}
+
+	return result.TranslatedText, result.DetectedLanguage.Language, nil
+}
+
+// translateRecipe translates the ingredients and instructions of an imported
+// recipe in place when it is not already in the target language. It returns
+// the original text, or nil if nothing was translated. Failures are logged and
+// leave the recipe untouched so the import still succeeds.
+func translateRecipe(ctx context.Context, data *RecipeData) *RecipeOriginal {
+	if !translationEnabled() || data == nil {
+		return nil
+	}
+
+	target := baseLanguage(translateTarget)
+	language := baseLanguage(data.Language)
+	if language == target {
+		return nil
+	}
+	source := language
+	if source == "" {
+		source = "auto"
+	}
+
+	ingredients := strings.Join(data.Ingredients, "\n")
+	translatedIngredients, detected, err := translateText(ctx, ingredients, source)
+	if err != nil {
+		log.Printf("Recipe translation failed: %v", err)
+		return nil
+	}
+	if detected != "" {
+		if language = baseLanguage(detected); language == target {
+			return nil
+		}
+		source = language
+	}
+
+	translatedInstructions := data.Instructions
+	if strings.TrimSpace(data.Instructions) != "" {
+		translatedInstructions, _, err = translateText(ctx, data.Instructions, source)
+		if err != nil {
+			log.Printf("Recipe translation failed: %v", err)
+			return nil
+		}
+	}
+
+	original := &RecipeOriginal{
+		Language:     language,
+		Ingredients:  ingredients,
+		Instructions: data.Instructions,
+	}
+	data.Ingredients = splitLines(translatedIngredients)
+	data.Instructions = translatedInstructions
+	return original
+}
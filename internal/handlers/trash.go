@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+)
+
+// trashRetentionDays is how long deleted items stay in the trash before the
+// trash_cleanup task deletes them for good
+const trashRetentionDays = 30
+
+// trashItemTypes names the item types on the trash page
+var trashItemTypes = map[string]string{
+	"bookmark":   "Bookmark",
+	"note":       "Note",
+	"recipe":     "Recipe",
+	"list":       "Checklist",
+	"rated_list": "Rated list",
+	"drawing":    "Drawing",
+	"media":      "Image",
+	"cookbook":   "Cookbook",
+}
+
+type trashView struct {
+	database.TrashedItem
+	TypeName string
+	DaysLeft int
+}
+
+// TrashHandler lists the user's deleted items
+func TrashHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	items, err := database.GetTrash(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	views := make([]trashView, len(items))
+	for i, it := range items {
+		views[i] = trashView{TrashedItem: it, TypeName: trashItemTypes[it.Type], DaysLeft: trashDaysLeft(it.DeletedAt, time.Now())}
+		if views[i].TypeName == "" {
+			views[i].TypeName = it.Type
+		}
+	}
+
+	tagsWithCounts, _ := database.GetTagsWithCounts(userID)
+	RenderTemplate(w, "trash.html", map[string]interface{}{
+		"Items":         views,
+		"RetentionDays": trashRetentionDays,
+		"Tags":          tagsWithCounts,
+		"ActiveTag":     "",
+	})
+}
+
+// RestoreItemHandler takes an item out of the trash
+func RestoreItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	err := database.RestoreItem(userID, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found in the trash", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// PurgeItemHandler deletes an item in the trash for good
+func PurgeItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	files, err := database.PurgeItem(userID, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found in the trash", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	removeItemFiles(files)
+	w.WriteHeader(http.StatusOK)
+}
+
+// EmptyTrashHandler deletes every item in the user's trash for good
+func EmptyTrashHandler(w http.ResponseWriter, r *http.Request) {
+	files, _, err := database.EmptyTrash(getUserID(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	removeItemFiles(files)
+	w.Header().Set("HX-Redirect", appURL("/trash"))
+	w.WriteHeader(http.StatusOK)
+}
+
+// purgeExpiredTrash is the trash_cleanup task
+func purgeExpiredTrash() (string, error) {
+	files, n, err := database.PurgeExpiredTrash(trashRetentionDays)
+	if err != nil {
+		return "", err
+	}
+	removeItemFiles(files)
+	return fmt.Sprintf("Deleted %d items from the trash", n), nil
+}
+
+// trashDaysLeft returns how many days an item deleted at deletedAt has left
+// in the trash
+func trashDaysLeft(deletedAt, now time.Time) int {
+	left := deletedAt.AddDate(0, 0, trashRetentionDays).Sub(now)
+	if left <= 0 {
+		return 0
+	}
+	return int((left + 24*time.Hour - 1) / (24 * time.Hour))
+}
+
+// removeItemFiles removes the uploaded files of purged items: those in the
+// uploads folder and, when it is set up, in object storage. Anything else,
+// such as a bookmark thumbnail on another site, is left alone.
+func removeItemFiles(paths []string) {
+	for _, p := range paths {
+		if name, ok := uploadedFileName(p); ok {
+			if err := os.Remove(filepath.Join(datadir.Uploads(), name)); err != nil && !os.IsNotExist(err) {
+				log.Printf("Trash: removing %s: %v", p, err)
+			}
+			continue
+		}
+		if !s3Enabled() {
+			continue
+		}
+		if key, ok := s3.objectKey(p); ok {
+			if err := s3.deleteObject(key); err != nil {
+				log.Printf("Trash: removing %s from object storage: %v", p, err)
+			}
+		}
+	}
+}
+
+// uploadedFileName returns the name of the file in the uploads folder that
+// path, as stored with an item, points to
+func uploadedFileName(path string) (string, bool) {
+	name, ok := strings.CutPrefix(path, "/static/uploads/")
+	if !ok || name == "" || name != filepath.Base(name) || name == ".." {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUploadedFileName(t *testing.T) {
+	tests := []struct {
+		path, want string
+		ok         bool
+	}{
+		{"/static/uploads/123.png", "123.png", true},
+		{"/static/uploads/", "", false},
+		{"/static/uploads/../../main.go", "", false},
+		{"/static/uploads/..", "", false},
+		{"/static/uploads/a/b.png", "", false},
+		{"https://example.com/static/uploads/123.png", "", false},
+		{"/static/favicon.svg", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := uploadedFileName(tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("uploadedFileName(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTrashDaysLeft(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		deletedAt time.Time
+		want      int
+	}{
+		{now, trashRetentionDays},
+		{now.Add(-time.Hour), trashRetentionDays},
+		{now.AddDate(0, 0, -trashRetentionDays+1), 1},
+		{now.AddDate(0, 0, -trashRetentionDays), 0},
+		{now.AddDate(0, 0, -trashRetentionDays-5), 0},
+	}
+	for _, tt := range tests {
+		if got := trashDaysLeft(tt.deletedAt, now); got != tt.want {
+			t.Errorf("trashDaysLeft(%v) = %d, want %d", tt.deletedAt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Uploaded files are served from the app's own origin, so a page or script
+// among them would run with the signed-in user's session. Only the kinds of
+// file a browser shows without running anything are served inline: images
+// other than SVG, audio, video and PDFs. Anything else, HTML and SVG
+// included, is sent as a download, and no response is sniffed for its type.
+
+// inlineUploads are the extensions of the uploaded files shown in the
+// browser rather than downloaded
+var inlineUploads = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".avif": true, ".bmp": true, ".ico": true,
+	".mp3": true, ".m4a": true, ".ogg": true, ".oga": true, ".opus": true, ".wav": true, ".flac": true, ".aac": true, ".weba": true,
+	".mp4": true, ".m4v": true, ".webm": true, ".mov": true, ".ogv": true,
+	".pdf": true,
+}
+
+// ServeUploads serves the uploaded files in dir
+func ServeUploads(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if !inlineUploads[strings.ToLower(path.Ext(r.URL.Path))] {
+			w.Header().Set("Content-Disposition", "attachment")
+			w.Header().Set("Content-Security-Policy", "sandbox")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Bookmark URLs are cleaned before they are fetched and stored: AMP cache
+// and AMP page addresses are turned into the page's own address, and
+// tracking parameters are removed from the query. The parameters removed
+// can be replaced with URL_CLEAN_PARAMS, a comma separated list in which a
+// trailing * matches any parameter starting with the rest, and name=value
+// only matches that value:
+//
+//	URL_CLEAN_PARAMS=utm_*,fbclid,ref
+//
+// The URL the user gave is kept as the bookmark's url; the cleaned one ends
+// up in canonical_url (see bookmark_url.go).
+var urlCleanParams = parseURLCleanParams(defaultURLCleanParams)
+
+const defaultURLCleanParams = "utm_*,fbclid,gclid,gclsrc,dclid,gbraid,wbraid,msclkid,yclid,twclid,igshid,mc_cid,mc_eid," +
+	"_ga,_gl,_hsenc,_hsmi,mkt_tok,oly_anon_id,oly_enc_id,vero_id,ref_src,ref_url,amp,amp_js_v,usqp,outputType=amp"
+
+func init() {
+	if v := os.Getenv("URL_CLEAN_PARAMS"); v != "" {
+		urlCleanParams = parseURLCleanParams(v)
+	}
+}
+
+// urlCleanParam is one entry of URL_CLEAN_PARAMS
+type urlCleanParam struct {
+	name   string
+	prefix bool   // name ended in *
+	value  string // only matches this value when set
+}
+
+func parseURLCleanParams(s string) []urlCleanParam {
+	var params []urlCleanParam
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		var param urlCleanParam
+		param.name, param.value, _ = strings.Cut(p, "=")
+		if strings.HasSuffix(param.name, "*") {
+			param.name, param.prefix = strings.TrimSuffix(param.name, "*"), true
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+func (p urlCleanParam) matches(name, value string) bool {
+	name = strings.ToLower(name)
+	rule := strings.ToLower(p.name)
+	if p.prefix && !strings.HasPrefix(name, rule) || !p.prefix && name != rule {
+		return false
+	}
+	return p.value == "" || strings.EqualFold(p.value, value)
+}
+
+// cleanBookmarkURL returns rawURL without AMP wrapping and the tracking
+// parameters of urlCleanParams. Anything that isn't an absolute URL is
+// returned as it is.
+func cleanBookmarkURL(rawURL string) string {
+	return cleanURL(rawURL, urlCleanParams)
+}
+
+func cleanURL(rawURL string, params []urlCleanParam) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	if inner := unwrapAMPCache(u); inner != nil {
+		u = inner
+	}
+	if path := stripAMPPath(u.Path); path != u.Path {
+		u.Path, u.RawPath = path, ""
+	}
+
+	if u.RawQuery != "" {
+		// Rebuilt by hand rather than with url.Values so the order of the
+		// remaining parameters stays the same
+		var kept []string
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			name, value, _ := strings.Cut(pair, "=")
+			name, _ = url.QueryUnescape(name)
+			value, _ = url.QueryUnescape(value)
+			if pair != "" && !matchesAnyParam(name, value, params) {
+				kept = append(kept, pair)
+			}
+		}
+		u.RawQuery = strings.Join(kept, "&")
+	}
+	return u.String()
+}
+
+func matchesAnyParam(name, value string, params []urlCleanParam) bool {
+	for _, p := range params {
+		if p.matches(name, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapAMPCache returns the page a Google AMP viewer or AMP cache URL
+// shows, e.g. https://www.google.com/amp/s/example.com/story for
+// https://example.com/story, or nil for other URLs
+func unwrapAMPCache(u *url.URL) *url.URL {
+	host := strings.ToLower(u.Hostname())
+	var rest string
+	switch {
+	case host == "google.com" || strings.HasPrefix(host, "www.google."):
+		var ok bool
+		if rest, ok = strings.CutPrefix(u.Path, "/amp/"); !ok {
+			return nil
+		}
+	case strings.HasSuffix(host, ".cdn.ampproject.org"):
+		// /c/ for documents, /v/ for the viewer, /i/ for images
+		if len(u.Path) < 3 || u.Path[0] != '/' || !strings.Contains("cvi", u.Path[1:2]) || u.Path[2] != '/' {
+			return nil
+		}
+		rest = u.Path[3:]
+	default:
+		return nil
+	}
+
+	scheme := "http"
+	if after, ok := strings.CutPrefix(rest, "s/"); ok {
+		scheme, rest = "https", after
+	}
+	inner, err := url.Parse(scheme + "://" + rest)
+	if err != nil || inner.Host == "" || !strings.Contains(inner.Host, ".") {
+		return nil
+	}
+	inner.RawQuery = u.RawQuery
+	return inner
+}
+
+// stripAMPPath removes the AMP marker of a path: /story/amp, /story/amp/,
+// /story.amp and /story.amp.html become /story, /story/, /story and
+// /story.html
+func stripAMPPath(path string) string {
+	switch {
+	case path == "/amp" || path == "/amp/":
+		return path
+	case strings.HasSuffix(path, "/amp"):
+		return strings.TrimSuffix(path, "/amp")
+	case strings.HasSuffix(path, "/amp/"):
+		return strings.TrimSuffix(path, "amp/")
+	case strings.HasSuffix(path, ".amp"):
+		return strings.TrimSuffix(path, ".amp")
+	case strings.HasSuffix(path, ".amp.html"):
+		return strings.TrimSuffix(path, ".amp.html") + ".html"
+	}
+	return path
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import "testing"
+
+func TestCleanURL(t *testing.T) {
+	params := parseURLCleanParams(defaultURLCleanParams)
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/a?utm_source=x&id=3&utm_medium=y", "https://example.com/a?id=3"},
+		{"https://example.com/a?fbclid=abc", "https://example.com/a"},
+		{"https://example.com/a?b=2&a=1&gclid=z", "https://example.com/a?b=2&a=1"},
+		{"https://example.com/a?outputType=amp&page=2", "https://example.com/a?page=2"},
+		{"https://example.com/a?outputType=html", "https://example.com/a?outputType=html"},
+		{"https://example.com/news/story/amp", "https://example.com/news/story"},
+		{"https://example.com/news/story/amp/", "https://example.com/news/story/"},
+		{"https://example.com/news/story.amp.html", "https://example.com/news/story.html"},
+		{"https://example.com/amp", "https://example.com/amp"},
+		{"https://www.google.com/amp/s/example.com/news/story/amp?utm_campaign=c", "https://example.com/news/story"},
+		{"https://example-com.cdn.ampproject.org/c/s/example.com/story.amp", "https://example.com/story"},
+		{"https://www.google.com/search?q=amp", "https://www.google.com/search?q=amp"},
+		{"not a url?utm_source=x", "not a url?utm_source=x"},
+	}
+	for _, tt := range tests {
+		if got := cleanURL(tt.in, params); got != tt.want {
+			t.Errorf("cleanURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	custom := parseURLCleanParams(" ref , session* ")
+	if got := cleanURL("https://example.com/?ref=hn&sessionid=1&utm_source=x", custom); got != "https://example.com/?utm_source=x" {
+		t.Errorf("custom rules: got %q", got)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"infokeep/internal/validation"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Length limits of user input, in characters
+const (
+	maxTitleLength = 200
+	maxShortText   = 1000   // list entries, rated list notes
+	maxLongText    = 200000 // note content, descriptions, recipe bodies
+)
+
+// Scores of rated list entries
+const (
+	minScore = 1
+	maxScore = 10
+)
+
+// Amounts of checklist entries
+const (
+	maxQuantity   = 1_000_000
+	maxUnitLength = 20
+	maxPrice      = 1_000_000_000
+)
+
+// writeValidationErrors responds 422 with {"errors": [{"field": ..., "message": ...}]}.
+// HTMX forms show each message under the form field of the same name (see
+// showFieldErrors in layout.html).
+func writeValidationErrors(w http.ResponseWriter, errs validation.Errors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+}
+
+// pathID parses the named URL parameter as an ID, writing a 400 if it isn't
+// one. A slug after the ID ("42-butter-chicken") is ignored.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := parseID(chi.URLParam(r, name))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
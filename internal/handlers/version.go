@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"infokeep/internal/buildinfo"
+	"infokeep/internal/database"
+)
+
+// With UPDATE_CHECK=on the server asks GitHub once a day for the latest
+// release, and admins see on the settings page when it is newer than the
+// running version. It is off by default, since it contacts github.com.
+var updateCheckEnabled = os.Getenv("UPDATE_CHECK") == "on"
+
+// latestReleaseURL is the GitHub API endpoint of the project's latest release
+var latestReleaseURL = "https://api.github.com/repos/dvidbruhm/infokeep/releases/latest"
+
+// VersionHandler returns the build's version, commit and build date, and
+// the latest release when the update check has found one
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{
+		"version":    buildinfo.Version,
+		"commit":     buildinfo.Commit,
+		"build_date": buildinfo.Date,
+		"go_version": runtime.Version(),
+	}
+	if latest, _ := latestRelease(); latest != "" {
+		resp["latest_version"] = latest
+		resp["update_available"] = buildinfo.Newer(latest, buildinfo.Version)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// latestRelease returns the latest release the update check found and its
+// page, or "" if it is off or hasn't found one yet
+func latestRelease() (version, url string) {
+	if !updateCheckEnabled {
+		return "", ""
+	}
+	version, _ = database.GetSystemSetting("latest_release")
+	url, _ = database.GetSystemSetting("latest_release_url")
+	return version, url
+}
+
+// checkForUpdate fetches the latest release and stores it for the settings
+// page and /api/version
+func checkForUpdate() (string, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	req, err := http.NewRequest("GET", latestReleaseURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "infokeep/"+buildinfo.Version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("releases feed returned status %d", resp.StatusCode)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&release); err != nil {
+		return "", fmt.Errorf("decoding latest release: %w", err)
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("latest release has no tag")
+	}
+
+	if err := database.SetSystemSetting("latest_release", release.TagName); err != nil {
+		return "", err
+	}
+	if err := database.SetSystemSetting("latest_release_url", release.HTMLURL); err != nil {
+		return "", err
+	}
+	if buildinfo.Newer(release.TagName, buildinfo.Version) {
+		return fmt.Sprintf("Update available: %s (running %s)", release.TagName, buildinfo.Version), nil
+	}
+	return fmt.Sprintf("Latest release is %s, running %s", release.TagName, buildinfo.Version), nil
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"infokeep/internal/database"
+	"infokeep/internal/models"
+)
+
+// The items a user opens (a recipe's or cookbook's page, a checklist, a
+// note, drawing or image in its editor, a bookmark's link) are recorded, so
+// that the dashboard can show what they looked at lately, wherever they
+// filed it.
+
+// dashboardRecentViews is how many recently viewed items the dashboard shows
+const dashboardRecentViews = 10
+
+// recordView notes that the user viewed one of their items. Failing to is
+// logged rather than failing the request that showed the item.
+func recordView(userID, itemID int64) {
+	if err := database.RecordView(userID, itemID); err != nil {
+		log.Printf("Failed to record that user %d viewed item %d: %v", userID, itemID, err)
+	}
+}
+
+// ItemViewHandler records a view of an item that is shown without asking
+// the server, such as a bookmark's page
+func ItemViewHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	if !requireOwnership(w, id, userID) {
+		return
+	}
+	recordView(userID, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApiRecentViewsHandler returns the items the user viewed last, the latest
+// first; ?limit= sets how many (at most 100)
+func ApiRecentViewsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRecentLimit
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, maxRecentLimit)
+	}
+	items, err := database.GetRecentViews(getUserID(r), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []models.ViewedItem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/datadir"
+	"infokeep/internal/validation"
+)
+
+// Voice notes are short recordings sent to POST /api/v1/voice-notes, e.g.
+// from a phone shortcut. The recording is kept as a media item and sent to
+// an OpenAI-compatible transcription endpoint
+// (POST multipart {file, model} -> {text}), such as OpenAI's, LocalAI's or
+// faster-whisper-server's, and the transcript becomes a note. Off unless
+// TRANSCRIBE_URL is set: nothing is sent anywhere by default.
+var (
+	transcribeURL    = os.Getenv("TRANSCRIBE_URL")
+	transcribeModel  = os.Getenv("TRANSCRIBE_MODEL")
+	transcribeAPIKey = os.Getenv("TRANSCRIBE_API_KEY")
+)
+
+var transcribeClient = &http.Client{Timeout: 5 * time.Minute, Transport: serviceTransport("transcribe")}
+
+const (
+	// maxVoiceNote caps the size of a recording
+	maxVoiceNote = 25 << 20
+	// voiceNoteTitleLength is how much of the transcript makes the title of
+	// a note sent without one, in characters
+	voiceNoteTitleLength = 60
+)
+
+func init() {
+	if transcribeModel == "" {
+		transcribeModel = "whisper-1"
+	}
+}
+
+func transcribeEnabled() bool {
+	return transcribeURL != ""
+}
+
+// ApiCreateVoiceNoteHandler stores a recording sent as multipart "audio"
+// (with optional "title", "tags" and "language", e.g. "en") and creates a
+// note with its transcript. If the recording can't be transcribed it is
+// still kept, and its media id is returned with the error.
+func ApiCreateVoiceNoteHandler(w http.ResponseWriter, r *http.Request) {
+	if !transcribeEnabled() {
+		http.Error(w, "Transcription is not set up on this server", http.StatusNotFound)
+		return
+	}
+	userID := getUserID(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxVoiceNote+(1<<20))
+	if err := r.ParseMultipartForm(maxVoiceNote); err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("audio")
+	if err != nil {
+		http.Error(w, "Audio is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	var v validation.Validator
+	title := v.MaxLength("title", strings.TrimSpace(r.FormValue("title")), maxTitleLength)
+	language := v.MaxLength("language", strings.TrimSpace(r.FormValue("language")), 10)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors())
+		return
+	}
+	tags := parseTags(r.FormValue("tags"))
+
+	ext := filepath.Ext(header.Filename)
+	if !safeExt.MatchString(ext) {
+		ext = ""
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = mime.TypeByExtension(strings.ToLower(ext))
+	}
+	// Recordings in video containers (webm, mp4) are fine too
+	if !strings.HasPrefix(mimeType, "audio/") && !strings.HasPrefix(mimeType, "video/") {
+		http.Error(w, "The upload is not an audio file", http.StatusUnsupportedMediaType)
+		return
+	}
+	fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
+	savePath := filepath.Join(datadir.Uploads(), fileName)
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		http.Error(w, "Failed to create uploads directory", http.StatusInternalServerError)
+		return
+	}
+	audio, err := io.ReadAll(file)
+	if err == nil {
+		err = os.WriteFile(savePath, audio, 0644)
+	}
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+
+	recorded := time.Now().Format("2006-01-02 15:04")
+	relPath := "/static/uploads/" + fileName
+	mediaID, err := database.CreateMedia(userID, "Voice note "+recorded, relPath, mimeType)
+	if err != nil {
+		os.Remove(savePath)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(tags) > 0 {
+		database.SetItemTags(mediaID, tags)
+	}
+	media := database.MediaFile{ID: mediaID, UserID: userID, Title: "Voice note " + recorded, FilePath: relPath}
+	if err := fingerprintMediaItem(media); err != nil {
+		log.Printf("Media fingerprints: item %d: %v", mediaID, err)
+	}
+	itemCreated(r.Context(), userID, mediaID, "media", "Voice note "+recorded)
+
+	w.Header().Set("Content-Type", "application/json")
+	transcript, err := transcribeAudio(withUserID(r.Context(), userID), header.Filename, audio, language)
+	if err != nil {
+		log.Printf("Transcribe: media %d: %v", mediaID, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "The recording could not be transcribed: " + err.Error(),
+			"media_id": mediaID,
+		})
+		return
+	}
+
+	if title == "" {
+		title = voiceNoteTitle(transcript, recorded)
+	}
+	content := strings.TrimSpace(transcript + "\n\nRecording: " + appURL(relPath))
+	noteID, err := database.CreateNote(userID, title, content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(tags) > 0 {
+		database.SetItemTags(noteID, tags)
+	}
+	itemCreated(r.Context(), userID, noteID, "note", title)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         noteID,
+		"media_id":   mediaID,
+		"title":      title,
+		"transcript": transcript,
+		"status":     "created",
+	})
+}
+
+// voiceNoteTitle makes the title of a voice note from the start of its
+// transcript, or from when it was recorded if nothing was said
+func voiceNoteTitle(transcript, recorded string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(transcript), "\n")
+	first = strings.TrimSpace(first)
+	if first == "" {
+		return "Voice note " + recorded
+	}
+	if short := truncateRunes(first, voiceNoteTitleLength); short != first {
+		// End at a word
+		if i := strings.LastIndex(short, " "); i > voiceNoteTitleLength/2 {
+			short = short[:i]
+		}
+		return strings.TrimRight(short, " ,.;:") + "…"
+	}
+	return first
+}
+
+// transcribeAudio asks the configured endpoint for the text of a recording
+func transcribeAudio(ctx context.Context, filename string, audio []byte, language string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return "", err
+	}
+	part.Write(audio)
+	mw.WriteField("model", transcribeModel)
+	if language != "" {
+		mw.WriteField("language", language)
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, transcribeURL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if transcribeAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+transcribeAPIKey)
+	}
+	resp, err := transcribeClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTranscribeAudio(t *testing.T) {
+	var model, language, filename string
+	var audio []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		model, language = r.FormValue("model"), r.FormValue("language")
+		if f, h, err := r.FormFile("file"); err == nil {
+			filename = h.Filename
+			audio, _ = io.ReadAll(f)
+		}
+		w.Write([]byte(`{"text": " Buy milk and call Anna. "}`))
+	}))
+	defer srv.Close()
+	defer func(u string) { transcribeURL = u }(transcribeURL)
+	transcribeURL = srv.URL
+
+	text, err := transcribeAudio(context.Background(), "memo.m4a", []byte("sound"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Buy milk and call Anna." {
+		t.Errorf("transcribeAudio() = %q", text)
+	}
+	if model != transcribeModel || language != "en" || filename != "memo.m4a" || string(audio) != "sound" {
+		t.Errorf("request = %q, %q, %q, %q; want the model, the language and the recording", model, language, filename, audio)
+	}
+}
+
+func TestVoiceNoteTitle(t *testing.T) {
+	tests := []struct {
+		transcript, want string
+	}{
+		{"Buy milk.\nAnd bread.", "Buy milk."},
+		{"   ", "Voice note 2024-05-06 08:00"},
+		{"Remember to ask the landlord about the heating before the winter starts, it was cold", "Remember to ask the landlord about the heating before the…"},
+	}
+	for _, tt := range tests {
+		if got := voiceNoteTitle(tt.transcript, "2024-05-06 08:00"); got != tt.want {
+			t.Errorf("voiceNoteTitle(%q) = %q, want %q", tt.transcript, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package imagehash computes perceptual hashes of images: 64-bit
+// fingerprints that stay (nearly) the same when a picture is resized,
+// recompressed or saved in another format, so re-uploads of the same photo
+// can be found by comparing hashes.
+//
+//	a := imagehash.Difference(img1)
+//	b := imagehash.Difference(img2)
+//	if imagehash.Distance(a, b) <= 6 {
+//		// most likely the same picture
+//	}
+package imagehash
+
+import (
+	"errors"
+	"image"
+	"io"
+	"math/bits"
+
+	// Formats Decode understands
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// MaxPixels is the largest image, in pixels, Decode accepts
+const MaxPixels = 64 << 20
+
+// ErrTooLarge is returned by Decode for images larger than MaxPixels
+var ErrTooLarge = errors.New("imagehash: image too large")
+
+// Decode reads a JPEG, PNG or GIF image, refusing ones over MaxPixels
+// before they are decoded. Like image.Decode it returns the name of the
+// format.
+func Decode(r io.ReadSeeker) (image.Image, string, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	return image.Decode(r)
+}
+
+// Difference returns the difference hash (dHash) of img: the image is
+// shrunk to 9x8 grey cells and each bit tells whether a cell is brighter
+// than its right neighbour.
+func Difference(img image.Image) uint64 {
+	const w, h = 9, 8
+	var cells [h][w]float64
+	b := img.Bounds()
+	if b.Empty() {
+		return 0
+	}
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(b.Min.X+(x+1)*b.Dx()/w, x0+1)
+			cells[y][x] = meanLuminance(img, x0, y0, min(x1, b.Max.X), min(y1, b.Max.Y))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the number of bits in which two hashes differ: 0 for
+// the same picture, up to 64
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// meanLuminance averages the luminance of the pixels in [x0,x1)x[y0,y1),
+// sampling at most 16x16 of them
+func meanLuminance(img image.Image, x0, y0, x1, y1 int) float64 {
+	stepX := max((x1-x0)/16, 1)
+	stepY := max((y1-y0)/16, 1)
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
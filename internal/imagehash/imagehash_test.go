@@ -0,0 +1,60 @@
+package imagehash
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// picture draws a w x h test image of 6x6 blocks whose shades depend on
+// seed
+func picture(w, h int, seed uint32) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			n := uint32(y*6/h*6+x*6/w)*2654435761 + seed*97
+			n ^= n >> 13
+			n *= 0x5bd1e995
+			v := uint8(n >> 16)
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestDifference(t *testing.T) {
+	original := picture(640, 480, 3)
+
+	// The same picture, smaller and saved as JPEG
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, picture(320, 240, 3), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	resaved, _, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := Distance(Difference(original), Difference(resaved)); d > 6 {
+		t.Errorf("distance to the re-saved picture = %d, want at most 6", d)
+	}
+	if d := Distance(Difference(original), Difference(picture(640, 480, 11))); d <= 6 {
+		t.Errorf("distance to another picture = %d, want more than 6", d)
+	}
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	// The header of a 65535x65535 GIF
+	header := []byte("GIF89a\xff\xff\xff\xff\x00\x00\x00")
+	if _, _, err := Decode(bytes.NewReader(header)); err != ErrTooLarge {
+		t.Errorf("Decode() error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d := Distance(0b1011, 0b0110); d != 3 {
+		t.Errorf("Distance() = %d, want 3", d)
+	}
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron-like schedule: five fields for the minute, hour, day of
+// the month, month and day of the week, each "*", a number, a range "1-5",
+// a step "*/15" or "0-30/10", or a comma separated list of those. Times are
+// in the server's local time zone.
+type Schedule struct {
+	spec    string
+	minute  []bool
+	hour    []bool
+	day     []bool
+	month   []bool
+	weekday []bool
+	// anyDay and anyWeekday are set for "*" fields: as in cron, a day
+	// matches when both match if either is "*", and when either does
+	// otherwise
+	anyDay     bool
+	anyWeekday bool
+}
+
+// scheduleNames are the shorthands ParseSchedule accepts
+var scheduleNames = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseSchedule parses a cron expression such as "0 3 * * *" (every day at
+// 3:00) or one of @hourly, @daily, @weekly and @monthly
+func ParseSchedule(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	expr := spec
+	if name, ok := scheduleNames[strings.ToLower(spec)]; ok {
+		expr = name
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("jobs: schedule %q should have 5 fields", spec)
+	}
+
+	s := &Schedule{spec: spec}
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("jobs: schedule %q: minute: %w", spec, err)
+	}
+	if s.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("jobs: schedule %q: hour: %w", spec, err)
+	}
+	if s.day, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("jobs: schedule %q: day of month: %w", spec, err)
+	}
+	if s.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("jobs: schedule %q: month: %w", spec, err)
+	}
+	// Both 0 and 7 are Sunday
+	if s.weekday, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("jobs: schedule %q: day of week: %w", spec, err)
+	}
+	s.weekday[0] = s.weekday[0] || s.weekday[7]
+	s.anyDay = fields[2] == "*"
+	s.anyWeekday = fields[4] == "*"
+	return s, nil
+}
+
+// parseField returns which of the values lo..hi a field matches, indexed by
+// value
+func parseField(field string, lo, hi int) ([]bool, error) {
+	match := make([]bool, hi+1)
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("bad step %q", stepText)
+			}
+			step = n
+		}
+
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("bad value %q", a)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("bad value %q", b)
+				}
+			} else if hasStep {
+				// "5/15" means from 5 on, every 15
+				to = hi
+			}
+			if from < lo || to > hi || from > to {
+				return nil, fmt.Errorf("%q is out of range %d-%d", rng, lo, hi)
+			}
+		}
+		for v := from; v <= to; v += step {
+			match[v] = true
+		}
+	}
+	return match, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time after t the schedule matches, or the zero time
+// if it never does (e.g. on February 30th)
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !s.month[m]:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !s.hour[t.Hour()]:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	day, weekday := s.day[t.Day()], s.weekday[t.Weekday()]
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
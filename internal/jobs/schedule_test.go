@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{"0 3 * * *", "*/15 * * * *", "0 9-17/2 * * 1-5", "30 4 1,15 * *", "@daily", "0 0 * * 7"} {
+		if _, err := ParseSchedule(spec); err != nil {
+			t.Errorf("ParseSchedule(%q) failed: %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "0 3 * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@yearly"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// A Sunday
+	from := time.Date(2026, 3, 1, 12, 34, 56, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"0 3 * * *", time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 1, 12, 45, 0, 0, time.UTC)},
+		{"35 12 * * *", time.Date(2026, 3, 1, 12, 35, 0, 0, time.UTC)},
+		{"34 12 * * *", time.Date(2026, 3, 2, 12, 34, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 6", time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Day of month or day of week, as in cron
+		{"0 0 15 * 3", time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestNextScheduledRun(t *testing.T) {
+	daily, _ := ParseSchedule("0 3 * * *")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		last time.Time
+		want time.Time
+	}{
+		{"never ran", time.Time{}, time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)},
+		{"ran today", time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)},
+		{"missed today's run", time.Date(2026, 2, 28, 3, 0, 0, 0, time.UTC), now.Add(firstRunDelay)},
+	}
+	for _, tt := range tests {
+		if got := nextScheduledRun(tt.last, daily, now); !got.Equal(tt.want) {
+			t.Errorf("%s: nextScheduledRun = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+// Package jobs runs recurring maintenance tasks (backups, cleanups, checks)
+// on fixed intervals or cron-like schedules and keeps a history of their runs
+// in the database.
+//
+// Tasks are registered at startup with Register and started with Start. The
+// next run of a task is due one interval after its last recorded run, or at
+// the first time its schedule matches after it, so a restart neither repeats
+// nor skips work.
+package jobs
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"infokeep/internal/database"
+	"infokeep/internal/metrics"
+)
+
+const (
+	// firstRunDelay gives the server time to start before a task that never ran
+	// (or is overdue) runs
+	firstRunDelay = 30 * time.Second
+	// keepRuns is how many past runs of each task are kept
+	keepRuns = 50
+)
+
+var (
+	taskRuns = metrics.NewCounterVec("infokeep_scheduled_task_runs_total",
+		"Scheduled task runs, by task and outcome.", "task", "status")
+	taskDuration = metrics.NewHistogramVec("infokeep_scheduled_task_duration_seconds",
+		"Scheduled task run time, by task.", []float64{0.1, 1, 10, 60, 300, 1800}, "task")
+)
+
+// Task is a recurring piece of work, run every Interval or, if set, on
+// Schedule. Run returns a short summary of what it did, which is shown on the
+// settings page.
+type Task struct {
+	Name        string
+	Description string
+	Interval    time.Duration
+	Schedule    *Schedule
+	Run         func() (result string, err error)
+}
+
+// Status is a task's schedule and the outcome of its latest run
+type Status struct {
+	Name        string
+	Description string
+	Interval    string
+	Running     bool
+	LastRun     *database.TaskRun
+	NextRun     time.Time
+}
+
+type entry struct {
+	Task
+	mu      sync.Mutex
+	running bool
+	nextRun time.Time
+}
+
+var (
+	mu      sync.Mutex
+	tasks   = map[string]*entry{}
+	started bool
+)
+
+// Register adds a task to the scheduler. It must be called before Start.
+func Register(t Task) {
+	if t.Name == "" || (t.Interval <= 0 && t.Schedule == nil) || t.Run == nil {
+		panic(fmt.Sprintf("jobs: invalid task %q", t.Name))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if started {
+		panic("jobs: Register called after Start")
+	}
+	if _, ok := tasks[t.Name]; ok {
+		panic(fmt.Sprintf("jobs: task %q registered twice", t.Name))
+	}
+	tasks[t.Name] = &entry{Task: t}
+}
+
+// Start runs every registered task on its schedule. It only returns if no
+// tasks are registered.
+func Start() {
+	if err := database.FailInterruptedTaskRuns(); err != nil {
+		log.Printf("Scheduler: Failed to close interrupted runs: %v", err)
+	}
+
+	mu.Lock()
+	started = true
+	entries := make([]*entry, 0, len(tasks))
+	for _, e := range tasks {
+		entries = append(entries, e)
+	}
+	mu.Unlock()
+
+	log.Printf("Scheduler started with %d tasks", len(entries))
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func(e *entry) {
+			defer wg.Done()
+			e.loop()
+		}(e)
+	}
+	wg.Wait()
+}
+
+// Statuses returns every registered task with its latest run, by name
+func Statuses() []Status {
+	mu.Lock()
+	entries := make([]*entry, 0, len(tasks))
+	for _, e := range tasks {
+		entries = append(entries, e)
+	}
+	mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	statuses := make([]Status, 0, len(entries))
+	for _, e := range entries {
+		e.mu.Lock()
+		s := Status{
+			Name:        e.Name,
+			Description: e.Description,
+			Interval:    e.describeSchedule(),
+			Running:     e.running,
+			NextRun:     e.nextRun,
+		}
+		e.mu.Unlock()
+		if run, err := database.GetLastTaskRun(e.Name); err == nil {
+			s.LastRun = run
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+func (e *entry) loop() {
+	for {
+		next := e.due(e.lastStart(), time.Now())
+		e.mu.Lock()
+		e.nextRun = next
+		e.mu.Unlock()
+
+		time.Sleep(time.Until(next))
+		e.run()
+	}
+}
+
+// lastStart returns when the task last started, or the zero time if it never ran
+func (e *entry) lastStart() time.Time {
+	run, err := database.GetLastTaskRun(e.Name)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Scheduler: Failed to look up last run of %s: %v", e.Name, err)
+		}
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, run.StartedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// due returns when the task is due again
+func (e *entry) due(last, now time.Time) time.Time {
+	if e.Schedule != nil {
+		return nextScheduledRun(last, e.Schedule, now)
+	}
+	return nextRunAfter(last, e.Interval, now)
+}
+
+// describeSchedule returns the task's interval or schedule for the settings page
+func (e *entry) describeSchedule() string {
+	if e.Schedule != nil {
+		return "at " + e.Schedule.String()
+	}
+	return formatInterval(e.Interval)
+}
+
+// nextRunAfter returns when a task that last started at last is due again,
+// but no sooner than firstRunDelay from now for one that is due already
+func nextRunAfter(last time.Time, interval time.Duration, now time.Time) time.Time {
+	earliest := now.Add(firstRunDelay)
+	if last.IsZero() {
+		return earliest
+	}
+	if next := last.Add(interval); next.After(earliest) {
+		return next
+	}
+	return earliest
+}
+
+// nextScheduledRun returns when a task on a schedule that last started at
+// last is due again: at the schedule's next time, or soon if the server was
+// down when it last matched
+func nextScheduledRun(last time.Time, schedule *Schedule, now time.Time) time.Time {
+	earliest := now.Add(firstRunDelay)
+	if !last.IsZero() {
+		if missed := schedule.Next(last); !missed.IsZero() && missed.Before(earliest) {
+			return earliest
+		}
+	}
+	next := schedule.Next(now)
+	if next.IsZero() {
+		// Never; check again in a year rather than spin
+		return now.AddDate(1, 0, 0)
+	}
+	if next.Before(earliest) {
+		return earliest
+	}
+	return next
+}
+
+func (e *entry) run() {
+	e.mu.Lock()
+	e.running = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+	}()
+
+	runID, err := database.StartTaskRun(e.Name)
+	if err != nil {
+		log.Printf("Scheduler: Failed to record start of %s: %v", e.Name, err)
+	}
+
+	start := time.Now()
+	result, err := e.safeRun()
+	taskDuration.Observe(time.Since(start).Seconds(), e.Name)
+
+	status := database.TaskOK
+	if err != nil {
+		status = database.TaskFailed
+		result = err.Error()
+		log.Printf("Scheduler: Task %s failed: %v", e.Name, err)
+	}
+	taskRuns.Inc(e.Name, status)
+
+	if runID != 0 {
+		if err := database.FinishTaskRun(runID, status, result); err != nil {
+			log.Printf("Scheduler: Failed to record result of %s: %v", e.Name, err)
+		}
+		database.PruneTaskRuns(e.Name, keepRuns)
+	}
+}
+
+// safeRun runs the task, turning a panic into an error so one broken task
+// doesn't take the server down
+func (e *entry) safeRun() (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return e.Run()
+}
+
+// formatInterval writes an interval the way a person would, e.g. "every 6 hours"
+func formatInterval(d time.Duration) string {
+	unit := func(n int64, name string) string {
+		if n == 1 {
+			return "every " + name
+		}
+		return fmt.Sprintf("every %d %ss", n, name)
+	}
+	switch {
+	case d%(24*time.Hour) == 0:
+		return unit(int64(d/(24*time.Hour)), "day")
+	case d%time.Hour == 0:
+		return unit(int64(d/time.Hour), "hour")
+	case d%time.Minute == 0:
+		return unit(int64(d/time.Minute), "minute")
+	}
+	return "every " + d.String()
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRunAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		last time.Time
+		want time.Time
+	}{
+		{"never ran", time.Time{}, now.Add(firstRunDelay)},
+		{"ran recently", now.Add(-10 * time.Minute), now.Add(50 * time.Minute)},
+		{"overdue", now.Add(-3 * time.Hour), now.Add(firstRunDelay)},
+		{"due within the start delay", now.Add(-time.Hour + 5*time.Second), now.Add(firstRunDelay)},
+	}
+	for _, tt := range tests {
+		if got := nextRunAfter(tt.last, time.Hour, now); !got.Equal(tt.want) {
+			t.Errorf("%s: nextRunAfter = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatInterval(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Hour:          "every hour",
+		6 * time.Hour:      "every 6 hours",
+		24 * time.Hour:     "every day",
+		7 * 24 * time.Hour: "every 7 days",
+		15 * time.Minute:   "every 15 minutes",
+		90 * time.Second:   "every 1m30s",
+	}
+	for d, want := range tests {
+		if got := formatInterval(d); got != want {
+			t.Errorf("formatInterval(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
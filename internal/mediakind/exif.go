@@ -0,0 +1,151 @@
+package mediakind
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// EXIF is the part of a JPEG's EXIF data Classify looks at
+type EXIF struct {
+	Make     string
+	Model    string
+	Software string
+	// DateTaken is set when the picture has a DateTimeOriginal
+	DateTaken bool
+}
+
+// Camera returns the make and model of the camera, if known
+func (e EXIF) Camera() string {
+	return strings.TrimSpace(e.Make + " " + e.Model)
+}
+
+// maxEXIF caps the size of the EXIF segment ReadEXIF reads; JPEG segments
+// are at most 64 KB anyway
+const maxEXIF = 64 << 10
+
+// EXIF tags ReadEXIF reads
+const (
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagSoftware         = 0x0131
+	tagEXIFIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+)
+
+var errNoEXIF = errors.New("mediakind: no EXIF data")
+
+// ReadEXIF reads the EXIF data of a JPEG. It returns an error for other
+// files and JPEGs without EXIF data.
+func ReadEXIF(r io.Reader) (EXIF, error) {
+	var marker [2]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil {
+		return EXIF{}, err
+	}
+	if marker != [2]byte{0xff, 0xd8} {
+		return EXIF{}, errNoEXIF
+	}
+	// The EXIF segment (APP1) comes before the image data, normally first
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return EXIF{}, err
+		}
+		if header[0] != 0xff || header[1] == 0xda || header[1] == 0xd9 {
+			return EXIF{}, errNoEXIF
+		}
+		length := int(binary.BigEndian.Uint16(header[2:])) - 2
+		if length < 0 {
+			return EXIF{}, errNoEXIF
+		}
+		if header[1] != 0xe1 {
+			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+				return EXIF{}, err
+			}
+			continue
+		}
+		segment := make([]byte, min(length, maxEXIF))
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return EXIF{}, err
+		}
+		if tiff, ok := bytes.CutPrefix(segment, []byte("Exif\x00\x00")); ok {
+			return parseTIFF(tiff)
+		}
+		// Another APP1 segment, e.g. XMP
+	}
+}
+
+// parseTIFF reads the tags ReadEXIF wants from EXIF's TIFF structure
+func parseTIFF(b []byte) (EXIF, error) {
+	if len(b) < 8 {
+		return EXIF{}, errNoEXIF
+	}
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return EXIF{}, errNoEXIF
+	}
+
+	var exif EXIF
+	var exifIFD uint32
+	readIFD(b, order, order.Uint32(b[4:]), func(tag, typ uint16, count, value uint32, valueAt int) {
+		switch tag {
+		case tagMake:
+			exif.Make = asciiValue(b, typ, count, value, valueAt)
+		case tagModel:
+			exif.Model = asciiValue(b, typ, count, value, valueAt)
+		case tagSoftware:
+			exif.Software = asciiValue(b, typ, count, value, valueAt)
+		case tagEXIFIFD:
+			exifIFD = value
+		}
+	})
+	if exifIFD != 0 {
+		readIFD(b, order, exifIFD, func(tag, typ uint16, count, value uint32, valueAt int) {
+			if tag == tagDateTimeOriginal {
+				exif.DateTaken = asciiValue(b, typ, count, value, valueAt) != ""
+			}
+		})
+	}
+	return exif, nil
+}
+
+// readIFD calls fn for each entry of the image file directory at offset.
+// valueAt is where the entry's value, or the offset of a longer one, is.
+func readIFD(b []byte, order binary.ByteOrder, offset uint32, fn func(tag, typ uint16, count, value uint32, valueAt int)) {
+	if int64(offset)+2 > int64(len(b)) {
+		return
+	}
+	n := int(order.Uint16(b[offset:]))
+	for i := 0; i < n; i++ {
+		entry := int(offset) + 2 + 12*i
+		if entry+12 > len(b) {
+			return
+		}
+		fn(order.Uint16(b[entry:]), order.Uint16(b[entry+2:]), order.Uint32(b[entry+4:]), order.Uint32(b[entry+8:]), entry+8)
+	}
+}
+
+// asciiValue returns the text of an ASCII entry, stored in place when it
+// fits in 4 bytes and at offset otherwise
+func asciiValue(b []byte, typ uint16, count, offset uint32, valueAt int) string {
+	const typeASCII = 2
+	if typ != typeASCII || count == 0 {
+		return ""
+	}
+	start := int64(offset)
+	if count <= 4 {
+		start = int64(valueAt)
+	}
+	end := start + int64(count)
+	if end > int64(len(b)) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(b[start:end]), "\x00"))
+}
@@ -0,0 +1,129 @@
+// Package mediakind tells what kind of picture an uploaded image is: a
+// screenshot, a photo or a scanned document. It goes by the EXIF data of
+// JPEGs, the file name, the dimensions (screens and paper sizes) and, for
+// scans, how much of the picture is white paper.
+//
+//	exif, _ := mediakind.ReadEXIF(file)
+//	kind := mediakind.Classify(img, format, exif, filename)
+package mediakind
+
+import (
+	"image"
+	"strings"
+)
+
+// Kind is the kind of a picture
+type Kind string
+
+const (
+	Unknown    Kind = ""
+	Screenshot Kind = "screenshot"
+	Photo      Kind = "photo"
+	Scan       Kind = "scan"
+)
+
+// Kinds are the kinds Classify can tell, in the order to list them in
+var Kinds = []Kind{Photo, Screenshot, Scan}
+
+// screenshotNames appear in the default file names of screenshots
+var screenshotNames = []string{
+	"screenshot", "screen shot", "screen_shot", "bildschirmfoto", "schermafbeelding",
+	"capture d", "captura de pantalla", "skärmbild",
+}
+
+// scannerHints appear in the camera make, model or software of scans
+var scannerHints = []string{
+	"scan", "canoscan", "perfection", "fujitsu", "vuescan", "naps2", "camscanner",
+	"adobe scan", "microsoft lens", "office lens",
+}
+
+// screenSizes are the resolutions, in pixels and landscape, of common
+// displays and phones
+var screenSizes = map[[2]int]bool{
+	{1280, 720}: true, {1280, 800}: true, {1366, 768}: true, {1440, 900}: true,
+	{1536, 864}: true, {1600, 900}: true, {1680, 1050}: true, {1920, 1080}: true,
+	{1920, 1200}: true, {2048, 1152}: true, {2560, 1080}: true, {2560, 1440}: true,
+	{2560, 1600}: true, {2880, 1800}: true, {3024, 1964}: true, {3440, 1440}: true,
+	{3456, 2234}: true, {3840, 2160}: true, {5120, 2880}: true,
+	// Phones
+	{1334, 750}: true, {1792, 828}: true, {2208, 1242}: true, {2436, 1125}: true,
+	{2532, 1170}: true, {2556, 1179}: true, {2778, 1284}: true, {2796, 1290}: true,
+	{2340, 1080}: true, {2400, 1080}: true, {3088, 1440}: true, {3200, 1440}: true,
+}
+
+// paperRatios are the height/width ratios of A4 (and the other ISO sizes)
+// and US Letter paper
+var paperRatios = []float64{1.4142, 1.2941}
+
+// Classify returns the kind of img, decoded from a file in format ("jpeg",
+// "png", ...) with the given EXIF data and name, or Unknown
+func Classify(img image.Image, format string, exif EXIF, name string) Kind {
+	if exif.Camera() != "" || exif.Software != "" {
+		if containsAny(strings.ToLower(exif.Camera()+" "+exif.Software), scannerHints) {
+			return Scan
+		}
+		if exif.Camera() != "" {
+			return Photo
+		}
+	}
+	if containsAny(strings.ToLower(name), screenshotNames) {
+		return Screenshot
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if screenSizes[[2]int{max(w, h), min(w, h)}] {
+		return Screenshot
+	}
+	if isPaper(w, h) && mostlyPaper(img) {
+		return Scan
+	}
+	// Photos from the web and messengers lose their EXIF data; other large
+	// JPEGs are most likely photos too
+	if exif.DateTaken || (format == "jpeg" && w*h >= 640*480) {
+		return Photo
+	}
+	return Unknown
+}
+
+// isPaper reports whether a w x h picture has the proportions of a sheet of
+// paper, upright or on its side, and is large enough to be a scan
+func isPaper(w, h int) bool {
+	if min(w, h) < 800 {
+		return false
+	}
+	ratio := float64(max(w, h)) / float64(min(w, h))
+	for _, r := range paperRatios {
+		if ratio > r*0.98 && ratio < r*1.02 {
+			return true
+		}
+	}
+	return false
+}
+
+// mostlyPaper reports whether most of img is white or light grey, as the
+// page of a scanned document is
+func mostlyPaper(img image.Image) bool {
+	b := img.Bounds()
+	const samples = 64
+	light := 0
+	for y := 0; y < samples; y++ {
+		for x := 0; x < samples; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x*b.Dx()/samples, b.Min.Y+y*b.Dy()/samples).RGBA()
+			hi, lo := max(r, g, bl), min(r, g, bl)
+			if lo > 0xb000 && hi-lo < 0x2000 {
+				light++
+			}
+		}
+	}
+	return light*10 >= samples*samples*6
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package mediakind
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exifJPEG returns a small JPEG with an EXIF segment holding maker, model and
+// a DateTimeOriginal
+func exifJPEG(t *testing.T, maker, model string) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, le, uint32(8))
+
+	// IFD0 at 8: Make, Model and the EXIF IFD pointer; values after it
+	const ifd0Size = 2 + 3*12 + 4
+	makeAt := uint32(8 + ifd0Size)
+	modelAt := makeAt + uint32(len(maker)+1)
+	exifAt := modelAt + uint32(len(model)+1)
+	entry := func(tag, typ uint16, count, value uint32) {
+		binary.Write(&tiff, le, tag)
+		binary.Write(&tiff, le, typ)
+		binary.Write(&tiff, le, count)
+		binary.Write(&tiff, le, value)
+	}
+	binary.Write(&tiff, le, uint16(3))
+	entry(tagMake, 2, uint32(len(maker)+1), makeAt)
+	entry(tagModel, 2, uint32(len(model)+1), modelAt)
+	entry(tagEXIFIFD, 4, 1, exifAt)
+	binary.Write(&tiff, le, uint32(0))
+	tiff.WriteString(maker + "\x00" + model + "\x00")
+
+	// EXIF IFD: DateTimeOriginal
+	date := "2024:05:01 10:00:00\x00"
+	binary.Write(&tiff, le, uint16(1))
+	entry(tagDateTimeOriginal, 2, uint32(len(date)), exifAt+2+12+4)
+	binary.Write(&tiff, le, uint32(0))
+	tiff.WriteString(date)
+
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	out := []byte{0xff, 0xd8, 0xff, 0xe1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, img.Bytes()[2:]...)
+}
+
+func TestReadEXIF(t *testing.T) {
+	exif, err := ReadEXIF(bytes.NewReader(exifJPEG(t, "Canon", "EOS R5")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exif.Make != "Canon" || exif.Model != "EOS R5" || !exif.DateTaken {
+		t.Errorf("ReadEXIF() = %+v", exif)
+	}
+
+	var plain bytes.Buffer
+	jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	if _, err := ReadEXIF(bytes.NewReader(plain.Bytes())); err == nil {
+		t.Error("ReadEXIF() of a JPEG without EXIF succeeded")
+	}
+	if _, err := ReadEXIF(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n"))); err == nil {
+		t.Error("ReadEXIF() of a PNG succeeded")
+	}
+}
+
+// filled returns a w x h image of one colour
+func filled(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestClassify(t *testing.T) {
+	white := color.RGBA{245, 245, 240, 255}
+	red := color.RGBA{200, 30, 30, 255}
+	tests := []struct {
+		name   string
+		img    image.Image
+		format string
+		exif   EXIF
+		file   string
+		want   Kind
+	}{
+		{"camera", filled(100, 100, red), "jpeg", EXIF{Make: "Apple", Model: "iPhone 15"}, "IMG_1.jpg", Photo},
+		{"scanner", filled(100, 100, white), "jpeg", EXIF{Make: "Canon", Model: "CanoScan LiDE 300"}, "doc.jpg", Scan},
+		{"scanner software", filled(100, 100, white), "jpeg", EXIF{Software: "NAPS2"}, "doc.jpg", Scan},
+		{"screenshot name", filled(100, 100, red), "png", EXIF{}, "Screenshot 2024-05-01.png", Screenshot},
+		{"screen size", filled(1920, 1080, red), "png", EXIF{}, "image.png", Screenshot},
+		{"phone screen", filled(1170, 2532, red), "png", EXIF{}, "image.png", Screenshot},
+		{"A4 page", filled(1240, 1754, white), "png", EXIF{}, "page.png", Scan},
+		{"A4 but dark", filled(1240, 1754, red), "png", EXIF{}, "page.png", Unknown},
+		{"large jpeg", filled(1000, 700, red), "jpeg", EXIF{}, "image.jpg", Photo},
+		{"small png", filled(300, 200, red), "png", EXIF{}, "icon.png", Unknown},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.img, tt.format, tt.exif, tt.file); got != tt.want {
+			t.Errorf("%s: Classify() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	c := &CounterVec{name: "test_total", help: "Test counter", labelNames: []string{"route"},
+		values: map[string]float64{}, labels: map[string][]string{}}
+	c.Inc("/b")
+	c.Inc("/a")
+	c.Inc("/b")
+	c.Inc(`/"q"`)
+
+	var sb strings.Builder
+	c.write(&sb)
+	want := `# HELP test_total Test counter
+# TYPE test_total counter
+test_total{route="/\"q\""} 1
+test_total{route="/a"} 1
+test_total{route="/b"} 2
+`
+	if sb.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := &HistogramVec{name: "test_seconds", help: "Test histogram", labelNames: []string{"op"},
+		buckets: []float64{0.1, 1}, series: map[string]*histogram{}}
+	h.Observe(0.05, "query")
+	h.Observe(0.5, "query")
+	h.Observe(3, "query")
+
+	var sb strings.Builder
+	h.write(&sb)
+	want := `# HELP test_seconds Test histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{op="query",le="0.1"} 1
+test_seconds_bucket{op="query",le="1"} 2
+test_seconds_bucket{op="query",le="+Inf"} 3
+test_seconds_sum{op="query"} 3.55
+test_seconds_count{op="query"} 3
+`
+	if sb.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+func TestWriteGauge(t *testing.T) {
+	var sb strings.Builder
+	WriteGauge(&sb, "test_items", "Items", []string{"type"}, []Sample{{Labels: []string{"note"}, Value: 4}})
+	want := "# HELP test_items Items\n# TYPE test_items gauge\ntest_items{type=\"note\"} 4\n"
+	if sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
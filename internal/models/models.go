@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type TagCount struct {
 	Tag   string
 	Count int
@@ -11,3 +13,227 @@ type User struct {
 	PasswordHash string
 	CreatedAt    string
 }
+
+// Item holds the fields every kind of item has. The JSON names are those of
+// the API and of JSON backups.
+type Item struct {
+	ID        int64    `json:"id"`
+	Title     string   `json:"title"`
+	CreatedAt string   `json:"created_at"`
+	Tags      []string `json:"tags"`
+	IsPinned  bool     `json:"is_pinned"`
+	Archived  bool     `json:"archived,omitempty"` // kept out of the list pages
+}
+
+type Bookmark struct {
+	Item
+	URL          string `json:"url"`           // as the user gave it
+	CanonicalURL string `json:"canonical_url"` // after redirects, rel=canonical and cleaning
+	Description  string `json:"description"`
+	Notes        string `json:"notes,omitempty"` // the user's own, apart from the page's description
+	Favicon      string `json:"favicon"`
+	Thumbnail    string `json:"thumbnail"`
+	Summary      string `json:"summary,omitempty"` // written by the summarize action
+	PageChanged  bool   `json:"page_changed"`      // the page changed since the user last looked
+	ReadLater    bool   `json:"read_later"`        // in the read-later queue, not read yet
+	ReadAt       string `json:"read_at,omitempty"` // when it was marked read from the queue
+	PageSaved    bool   `json:"page_saved"`        // a copy of the page is archived
+	// The last check of the link: its HTTP status (0 without a response),
+	// the error, when it ran, and whether it failed
+	LinkStatus    int    `json:"link_status,omitempty"`
+	LinkError     string `json:"link_error,omitempty"`
+	LinkCheckedAt string `json:"link_checked_at,omitempty"`
+	LinkBroken    bool   `json:"link_broken"`
+	// MetadataPending is set while the page is still to be read in the
+	// background for the title, description and preview image
+	MetadataPending bool `json:"metadata_pending"`
+	// CardPoll counts how often the bookmark's card polled for the page; the
+	// card sends it with its next poll. It is not stored.
+	CardPoll int `json:"-"`
+}
+
+type Note struct {
+	Item
+	Content   string `json:"content"`
+	Summary   string `json:"summary,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+type Drawing struct {
+	Item
+	FilePath   string `json:"file_path"`
+	VectorData string `json:"vector_data,omitempty"` // only filled in for a single drawing
+}
+
+type Media struct {
+	Item
+	FilePath string `json:"file_path"`
+	MimeType string `json:"mime_type"`
+	Kind     string `json:"kind,omitempty"` // screenshot, photo or scan, when known
+}
+
+type List struct {
+	Item
+	Items []ListItem `json:"items,omitempty"` // only filled in for exports
+}
+
+type ListItem struct {
+	ID        int64   `json:"id"`
+	Content   string  `json:"content"`
+	Completed bool    `json:"completed"`
+	Quantity  float64 `json:"quantity,omitempty"`
+	Unit      string  `json:"unit,omitempty"`
+	Price     float64 `json:"price,omitempty"`    // estimated, for the whole quantity
+	DueDate   string  `json:"due_date,omitempty"` // YYYY-MM-DD
+}
+
+type RatedList struct {
+	Item
+	Items []RatedListItem `json:"items,omitempty"` // only filled in for exports
+}
+
+type RatedListItem struct {
+	ID        int64  `json:"id"`
+	Title     string `json:"title"`
+	Score     int    `json:"score"`
+	Note      string `json:"note"`
+	ImagePath string `json:"image_path"`
+}
+
+type Recipe struct {
+	Item
+	Ingredients  string   `json:"ingredients"`
+	Instructions string   `json:"instructions"`
+	Notes        string   `json:"notes"`
+	Thumbnail    string   `json:"thumbnail"`
+	SourceURL    string   `json:"source_url"`
+	Images       []string `json:"images,omitempty"` // only filled in for a single recipe and exports
+
+	// The untranslated text of a recipe translated on import, only filled
+	// in for a single recipe
+	OriginalLanguage     string `json:"original_language,omitempty"`
+	OriginalIngredients  string `json:"original_ingredients,omitempty"`
+	OriginalInstructions string `json:"original_instructions,omitempty"`
+	Translated           bool   `json:"translated,omitempty"`
+}
+
+// Cookbook is a named collection of recipes
+type Cookbook struct {
+	Item
+	Description string  `json:"description"`
+	CoverImage  string  `json:"cover_image"`
+	RecipeCount int     `json:"recipe_count"`
+	RecipeIDs   []int64 `json:"recipe_ids,omitempty"` // only filled in for exports
+}
+
+// RecentItem is an item in the list of recently created and changed items
+type RecentItem struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url,omitempty"` // bookmarks only
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Updated   bool      `json:"updated"` // changed since it was created
+}
+
+// ViewedItem is an item in the list of recently viewed items
+type ViewedItem struct {
+	ID       int64     `json:"id"`
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	URL      string    `json:"url,omitempty"` // bookmarks only
+	Tags     []string  `json:"tags"`
+	ViewedAt time.Time `json:"viewed_at"`
+}
+
+// FeedItem is an item in a user's RSS or Atom feed of new items
+type FeedItem struct {
+	ID        int64
+	Type      string
+	Title     string
+	URL       string // bookmarks only
+	Summary   string // a bookmark's description or a note's text
+	Tags      []string
+	CreatedAt time.Time
+}
+
+// LinkedItem is an item linked to another one, shown in its Related section
+type LinkedItem struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	URL      string `json:"url,omitempty"` // bookmarks only
+	Archived bool   `json:"archived,omitempty"`
+}
+
+// LogEntry is a dated note added to an item's log, e.g. "tried this recipe,
+// too salty". Entries are never changed once written.
+type LogEntry struct {
+	ID        int64     `json:"id"`
+	ItemID    int64     `json:"item_id"`
+	ItemTitle string    `json:"item_title,omitempty"` // only filled in for all of a user's entries
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Attachment is a file uploaded to an item, e.g. the PDF manual of a
+// bookmarked product or a scan of a handwritten recipe
+type Attachment struct {
+	ID        int64     `json:"id"`
+	ItemID    int64     `json:"item_id"`
+	FileName  string    `json:"file_name"` // the name it was uploaded with
+	FilePath  string    `json:"file_path"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ItemReminder is a one-off reminder about an item
+type ItemReminder struct {
+	ID               int64      `json:"id"`
+	UserID           int64      `json:"-"`
+	ItemID           int64      `json:"item_id"`
+	ItemType         string     `json:"item_type"`
+	ItemTitle        string     `json:"item_title"`
+	RemindAt         time.Time  `json:"remind_at"`
+	NotificationType string     `json:"notification_type"`  // notification_only, email, or both
+	Emails           string     `json:"emails,omitempty"`   // comma-separated
+	FiredAt          *time.Time `json:"fired_at,omitempty"` // when it went off, nil until then
+}
+
+// DueListItem is a checklist entry with a due date
+type DueListItem struct {
+	ID        int64
+	UserID    int64
+	ListID    int64
+	ListTitle string
+	Content   string
+	DueDate   string // YYYY-MM-DD
+	Completed bool
+}
+
+// Upcoming is a reminder or due date on the dashboard's Upcoming list
+type Upcoming struct {
+	Kind    string // "reminder", "item_reminder" or "due"
+	ItemID  int64  // the item it opens: the reminder, the item, the checklist
+	Type    string // that item's type
+	Title   string
+	Detail  string // the checklist of a due entry
+	At      time.Time
+	AllDay  bool // a due date, without a time
+	Overdue bool
+}
+
+// PinnedItem is an item of any kind shown among the pinned items of the
+// dashboard
+type PinnedItem struct {
+	ID        int64    `json:"id"`
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	URL       string   `json:"url"` // bookmarks only
+	Thumbnail string   `json:"thumbnail"`
+	Favicon   string   `json:"favicon"`
+	Tags      []string `json:"tags"`
+}
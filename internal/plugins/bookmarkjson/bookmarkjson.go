@@ -0,0 +1,207 @@
+// Package bookmarkjson imports the JSON exports of bookmarking services:
+//
+//   - Pinboard (Settings → Backup → JSON): a list of posts with href,
+//     description (the title), extended (the notes), space separated tags,
+//     toread and time
+//   - Linkding (the REST API's /api/bookmarks/, or a saved page of it): a
+//     list of bookmarks, or an object holding them as "results", with url,
+//     title, description, notes, tag_names, unread and date_added
+//   - Raindrop.io (the REST API's /rest/v1/raindrops/0): a list of
+//     raindrops, or an object holding them as "items", with link, title,
+//     excerpt, note, tags and created
+//
+// Titles, descriptions, tags, the creation date and, where the service has
+// one, the to-read flag (the read-later queue here) carry over.
+package bookmarkjson
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"infokeep/internal/extensions"
+)
+
+func init() {
+	extensions.RegisterImporter(extensions.Importer{
+		Name:        "pinboard",
+		Description: "Pinboard (JSON export)",
+		Accept:      ".json",
+		Import:      ImportPinboard,
+	})
+	extensions.RegisterImporter(extensions.Importer{
+		Name:        "linkding",
+		Description: "Linkding (JSON from its API)",
+		Accept:      ".json",
+		Import:      ImportLinkding,
+	})
+	extensions.RegisterImporter(extensions.Importer{
+		Name:        "raindrop",
+		Description: "Raindrop.io (JSON from its API)",
+		Accept:      ".json",
+		Import:      ImportRaindrop,
+	})
+}
+
+type pinboardPost struct {
+	Href     string `json:"href"`
+	Title    string `json:"description"`
+	Extended string `json:"extended"`
+	Tags     string `json:"tags"`
+	ToRead   string `json:"toread"`
+	Time     string `json:"time"`
+}
+
+// ImportPinboard reads a Pinboard JSON export
+func ImportPinboard(ctx context.Context, r io.Reader) (*extensions.Import, error) {
+	var posts []pinboardPost
+	if err := decodeList(r, "", &posts); err != nil {
+		return nil, err
+	}
+	found := &extensions.Import{}
+	for _, p := range posts {
+		add(found, extensions.ImportedBookmark{
+			Title:       p.Title,
+			URL:         p.Href,
+			Description: p.Extended,
+			Tags:        strings.Fields(p.Tags),
+			ReadLater:   p.ToRead == "yes",
+			Created:     parseTime(p.Time),
+		})
+	}
+	return found, nil
+}
+
+type linkdingBookmark struct {
+	URL                string   `json:"url"`
+	Title              string   `json:"title"`
+	WebsiteTitle       string   `json:"website_title"`
+	Description        string   `json:"description"`
+	WebsiteDescription string   `json:"website_description"`
+	Notes              string   `json:"notes"`
+	TagNames           []string `json:"tag_names"`
+	Unread             bool     `json:"unread"`
+	DateAdded          string   `json:"date_added"`
+}
+
+// ImportLinkding reads Linkding bookmarks as its API returns them
+func ImportLinkding(ctx context.Context, r io.Reader) (*extensions.Import, error) {
+	var bookmarks []linkdingBookmark
+	if err := decodeList(r, "results", &bookmarks); err != nil {
+		return nil, err
+	}
+	found := &extensions.Import{}
+	for _, b := range bookmarks {
+		add(found, extensions.ImportedBookmark{
+			Title:       firstNonEmpty(b.Title, b.WebsiteTitle),
+			URL:         b.URL,
+			Description: joinText(firstNonEmpty(b.Description, b.WebsiteDescription), b.Notes),
+			Tags:        b.TagNames,
+			ReadLater:   b.Unread,
+			Created:     parseTime(b.DateAdded),
+		})
+	}
+	return found, nil
+}
+
+type raindrop struct {
+	Link    string   `json:"link"`
+	Title   string   `json:"title"`
+	Excerpt string   `json:"excerpt"`
+	Note    string   `json:"note"`
+	Tags    []string `json:"tags"`
+	Created string   `json:"created"`
+}
+
+// ImportRaindrop reads raindrops as the Raindrop.io API returns them
+func ImportRaindrop(ctx context.Context, r io.Reader) (*extensions.Import, error) {
+	var raindrops []raindrop
+	if err := decodeList(r, "items", &raindrops); err != nil {
+		return nil, err
+	}
+	found := &extensions.Import{}
+	for _, d := range raindrops {
+		add(found, extensions.ImportedBookmark{
+			Title:       d.Title,
+			URL:         d.Link,
+			Description: joinText(d.Excerpt, d.Note),
+			Tags:        d.Tags,
+			Created:     parseTime(d.Created),
+		})
+	}
+	return found, nil
+}
+
+// decodeList decodes a JSON list into list, or the list that an object
+// holds as key when key isn't empty
+func decodeList(r io.Reader, key string, list interface{}) error {
+	br := bufio.NewReader(r)
+	start, _ := br.Peek(64)
+	if key != "" && bytes.HasPrefix(bytes.TrimSpace(start), []byte("{")) {
+		var wrapper map[string]json.RawMessage
+		if err := json.NewDecoder(br).Decode(&wrapper); err != nil {
+			return fmt.Errorf("not a JSON export: %w", err)
+		}
+		raw, ok := wrapper[key]
+		if !ok {
+			return fmt.Errorf("not a JSON export: no %q list", key)
+		}
+		return json.Unmarshal(raw, list)
+	}
+	if err := json.NewDecoder(br).Decode(list); err != nil {
+		return fmt.Errorf("not a JSON export: %w", err)
+	}
+	return nil
+}
+
+// add adds a bookmark to what was found, unless it has no web address. A
+// bookmark without a title is titled with its address.
+func add(found *extensions.Import, b extensions.ImportedBookmark) {
+	b.URL = strings.TrimSpace(b.URL)
+	if !strings.HasPrefix(b.URL, "http://") && !strings.HasPrefix(b.URL, "https://") {
+		return
+	}
+	b.Title = firstNonEmpty(strings.TrimSpace(b.Title), b.URL)
+	b.Description = strings.TrimSpace(b.Description)
+	var tags []string
+	for _, t := range b.Tags {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	b.Tags = tags
+	found.Bookmarks = append(found.Bookmarks, b)
+}
+
+// parseTime parses the RFC 3339 times the services write, or returns the
+// zero time
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// joinText joins a description and notes with a blank line
+func joinText(description, notes string) string {
+	description, notes = strings.TrimSpace(description), strings.TrimSpace(notes)
+	if description == "" || notes == "" {
+		return description + notes
+	}
+	return description + "\n\n" + notes
+}
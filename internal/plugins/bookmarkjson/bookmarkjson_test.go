@@ -0,0 +1,91 @@
+package bookmarkjson
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"infokeep/internal/extensions"
+)
+
+type bookmark struct {
+	title, url, description string
+	tags                    []string
+	readLater               bool
+	created                 string
+}
+
+func imported(t *testing.T, importer func(context.Context, io.Reader) (*extensions.Import, error), export string) []bookmark {
+	t.Helper()
+	found, err := importer(context.Background(), strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []bookmark
+	for _, b := range found.Bookmarks {
+		created := ""
+		if !b.Created.IsZero() {
+			created = b.Created.UTC().Format(time.DateTime)
+		}
+		got = append(got, bookmark{b.Title, b.URL, b.Description, b.Tags, b.ReadLater, created})
+	}
+	return got
+}
+
+func TestImportPinboard(t *testing.T) {
+	export := `[
+		{"href": "https://go.dev/blog", "description": "The Go Blog", "extended": "Release notes", "tags": "go  reading",
+			"time": "2014-05-06T07:08:09Z", "shared": "no", "toread": "yes"},
+		{"href": "https://example.com/", "description": "", "extended": "", "tags": "", "time": "", "toread": "no"},
+		{"href": "javascript:void(0)", "description": "Bookmarklet"}
+	]`
+	want := []bookmark{
+		{"The Go Blog", "https://go.dev/blog", "Release notes", []string{"go", "reading"}, true, "2014-05-06 07:08:09"},
+		{"https://example.com/", "https://example.com/", "", nil, false, ""},
+	}
+	if got := imported(t, ImportPinboard, export); !reflect.DeepEqual(got, want) {
+		t.Errorf("ImportPinboard() = %v, want %v", got, want)
+	}
+}
+
+func TestImportLinkding(t *testing.T) {
+	export := `{"count": 1, "next": null, "results": [
+		{"id": 1, "url": "https://go.dev/blog", "title": "", "website_title": "The Go Blog", "description": "Posts",
+			"notes": "Read the *generics* one", "tag_names": ["go"], "unread": true, "date_added": "2022-01-02T03:04:05.123456Z"}
+	]}`
+	want := []bookmark{
+		{"The Go Blog", "https://go.dev/blog", "Posts\n\nRead the *generics* one", []string{"go"}, true, "2022-01-02 03:04:05"},
+	}
+	if got := imported(t, ImportLinkding, export); !reflect.DeepEqual(got, want) {
+		t.Errorf("ImportLinkding() = %v, want %v", got, want)
+	}
+	// A bare list, as a saved page of results
+	if got := imported(t, ImportLinkding, `[{"url": "https://go.dev/blog", "title": "Go"}]`); len(got) != 1 || got[0].title != "Go" {
+		t.Errorf("ImportLinkding() of a list = %v", got)
+	}
+}
+
+func TestImportRaindrop(t *testing.T) {
+	export := `{"result": true, "items": [
+		{"_id": 1, "link": "https://example.com/bread", "title": "Bread", "excerpt": "How to bake it", "note": "",
+			"tags": ["baking", " "], "created": "2020-10-11T12:13:14.000Z", "type": "article"}
+	]}`
+	want := []bookmark{
+		{"Bread", "https://example.com/bread", "How to bake it", []string{"baking"}, false, "2020-10-11 12:13:14"},
+	}
+	if got := imported(t, ImportRaindrop, export); !reflect.DeepEqual(got, want) {
+		t.Errorf("ImportRaindrop() = %v, want %v", got, want)
+	}
+}
+
+func TestImportOther(t *testing.T) {
+	if _, err := ImportRaindrop(context.Background(), strings.NewReader(`{"results": []}`)); err == nil {
+		t.Error("ImportRaindrop of a Linkding export did not fail")
+	}
+	if _, err := ImportPinboard(context.Background(), strings.NewReader(`<html></html>`)); err == nil {
+		t.Error("ImportPinboard of HTML did not fail")
+	}
+}
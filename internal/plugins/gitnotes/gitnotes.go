@@ -0,0 +1,290 @@
+// Package gitnotes exports notes as a folder of Markdown files meant to be
+// committed to a git repository, and imports such a folder (zipped) back.
+//
+// Each note is notes/<id>.md: a short front matter block with its title,
+// tags and creation date, then the content. File names only depend on the
+// note's ID and nothing in the export changes unless a note does, so
+// committing a new export over an old one gives a diff of exactly what was
+// edited. manifest.json at the top lists the notes and marks the folder as
+// an InfoKeep export.
+//
+//	notes/42.md
+//	---
+//	id: 42
+//	title: "Shopping"
+//	tags: ["home"]
+//	created: 2024-05-01 10:00:00
+//	---
+//
+//	Milk, eggs
+package gitnotes
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"infokeep/internal/extensions"
+)
+
+const (
+	// ManifestFile is the name of the manifest at the top of an export
+	ManifestFile = "manifest.json"
+	// manifestFormat identifies the manifest of an InfoKeep notes export
+	manifestFormat = "infokeep-notes"
+	// maxImportSize caps the size of a zipped export Import reads
+	maxImportSize = 64 << 20
+	// maxFileSize caps the unpacked size of one file in it
+	maxFileSize = 8 << 20
+)
+
+func init() {
+	extensions.RegisterImporter(extensions.Importer{
+		Name:        "gitnotes",
+		Description: "InfoKeep notes as Markdown files (ZIP)",
+		Accept:      ".zip",
+		Import:      Import,
+	})
+}
+
+// Note is a note in an export
+type Note struct {
+	ID        int64
+	Title     string
+	Content   string
+	Tags      []string
+	CreatedAt string
+}
+
+// manifest is the content of manifest.json
+type manifest struct {
+	Format  string          `json:"format"`
+	Version int             `json:"version"`
+	Notes   []manifestEntry `json:"notes"`
+}
+
+type manifestEntry struct {
+	ID        int64    `json:"id"`
+	File      string   `json:"file"`
+	Title     string   `json:"title"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at,omitempty"`
+}
+
+// FileName returns the path of a note's file in an export
+func FileName(id int64) string {
+	return "notes/" + strconv.FormatInt(id, 10) + ".md"
+}
+
+// Write writes notes to w as a ZIP of Markdown files and a manifest, in ID
+// order
+func Write(w io.Writer, notes []Note) error {
+	sorted := make([]Note, len(notes))
+	copy(sorted, notes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	zw := zip.NewWriter(w)
+	m := manifest{Format: manifestFormat, Version: 1, Notes: []manifestEntry{}}
+	for _, n := range sorted {
+		n.Tags = sortedTags(n.Tags)
+		f, err := zw.Create(FileName(n.ID))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, Format(n)); err != nil {
+			return err
+		}
+		m.Notes = append(m.Notes, manifestEntry{ID: n.ID, File: FileName(n.ID), Title: n.Title, Tags: n.Tags, CreatedAt: n.CreatedAt})
+	}
+
+	f, err := zw.Create(ManifestFile)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Format returns the Markdown file of a note: its front matter, a blank line
+// and the content, ending in a newline
+func Format(n Note) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %d\n", n.ID)
+	fmt.Fprintf(&b, "title: %s\n", jsonValue(n.Title))
+	fmt.Fprintf(&b, "tags: %s\n", jsonValue(sortedTags(n.Tags)))
+	if n.CreatedAt != "" {
+		fmt.Fprintf(&b, "created: %s\n", n.CreatedAt)
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(n.Content)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Parse reads a note file written by Format. A file without front matter
+// (a note added by hand) is all content, titled after the file name.
+func Parse(name string, data []byte) Note {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	n := Note{Title: strings.TrimSuffix(path.Base(name), path.Ext(name))}
+
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if header, body, ok := strings.Cut(rest, "\n---\n"); ok {
+			parseFrontMatter(header, &n)
+			text = strings.TrimPrefix(body, "\n")
+		}
+	}
+	// Format ends the content with a newline of its own
+	n.Content = strings.TrimSuffix(text, "\n")
+	return n
+}
+
+// parseFrontMatter reads the "key: value" lines Format writes into n
+func parseFrontMatter(header string, n *Note) {
+	for _, line := range strings.Split(header, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "id":
+			n.ID, _ = strconv.ParseInt(value, 10, 64)
+		case "title":
+			var title string
+			if json.Unmarshal([]byte(value), &title) != nil {
+				// Hand-written, unquoted
+				title = value
+			}
+			if title != "" {
+				n.Title = title
+			}
+		case "tags":
+			var tags []string
+			if json.Unmarshal([]byte(value), &tags) != nil {
+				for _, t := range strings.Split(strings.Trim(value, "[]"), ",") {
+					if t = strings.Trim(strings.TrimSpace(t), `"'`); t != "" {
+						tags = append(tags, t)
+					}
+				}
+			}
+			n.Tags = tags
+		case "created":
+			n.CreatedAt = value
+		}
+	}
+}
+
+// Read reads the notes of a zipped export, which may be inside a folder (as
+// in the ZIP downloads of git hosts): every .md file in the notes folder next
+// to the manifest, in file name order
+func Read(r io.ReaderAt, size int64) ([]Note, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := "", false
+	for _, f := range zr.File {
+		if path.Base(f.Name) != ManifestFile {
+			continue
+		}
+		var m manifest
+		if err := readJSON(f, &m); err == nil && m.Format == manifestFormat {
+			root, ok = path.Dir(f.Name), true
+			break
+		}
+	}
+	if !ok {
+		return nil, errors.New("not an InfoKeep notes export (no manifest.json)")
+	}
+
+	notesDir := path.Join(root, "notes") + "/"
+	files := make([]*zip.File, 0, len(zr.File))
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, notesDir) && strings.HasSuffix(f.Name, ".md") && !strings.Contains(f.Name[len(notesDir):], "/") {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	notes := make([]Note, 0, len(files))
+	for _, f := range files {
+		data, err := readFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		notes = append(notes, Parse(f.Name, data))
+	}
+	return notes, nil
+}
+
+// Import reads the notes of a zipped export for Settings → Import From Other
+// Apps
+func Import(ctx context.Context, r io.Reader) (*extensions.Import, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportSize {
+		return nil, errors.New("file too large")
+	}
+	notes, err := Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	found := &extensions.Import{}
+	for _, n := range notes {
+		found.Notes = append(found.Notes, extensions.ImportedNote{Title: n.Title, Content: n.Content, Tags: n.Tags})
+	}
+	return found, nil
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+	if err == nil && len(data) > maxFileSize {
+		err = errors.New("file too large")
+	}
+	return data, err
+}
+
+func readJSON(f *zip.File, v interface{}) error {
+	data, err := readFile(f)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// jsonValue writes v as JSON on one line, which YAML reads too
+func jsonValue(v interface{}) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func sortedTags(tags []string) []string {
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,88 @@
+package gitnotes
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatParse(t *testing.T) {
+	n := Note{ID: 42, Title: `Shopping "list" <home>`, Content: "Milk\n\n---\n\neggs\n", Tags: []string{"home", "errands"}, CreatedAt: "2024-05-01 10:00:00"}
+	text := Format(n)
+	want := "---\nid: 42\ntitle: \"Shopping \\\"list\\\" <home>\"\ntags: [\"errands\",\"home\"]\ncreated: 2024-05-01 10:00:00\n---\n\nMilk\n\n---\n\neggs\n\n"
+	if text != want {
+		t.Errorf("Format() = %q, want %q", text, want)
+	}
+
+	got := Parse(FileName(42), []byte(text))
+	n.Tags = []string{"errands", "home"}
+	if !reflect.DeepEqual(got, n) {
+		t.Errorf("Parse(Format()) = %+v, want %+v", got, n)
+	}
+}
+
+func TestParseHandWritten(t *testing.T) {
+	got := Parse("notes/ideas.md", []byte("Just text\r\n"))
+	if got.Title != "ideas" || got.Content != "Just text" || got.ID != 0 {
+		t.Errorf("Parse() without front matter = %+v", got)
+	}
+
+	got = Parse("notes/x.md", []byte("---\ntitle: Plain title\ntags: [a, 'b']\n---\nBody\n"))
+	if got.Title != "Plain title" || !reflect.DeepEqual(got.Tags, []string{"a", "b"}) || got.Content != "Body" {
+		t.Errorf("Parse() of YAML-style front matter = %+v", got)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	notes := []Note{
+		{ID: 7, Title: "Second", Content: "b", Tags: []string{"x"}},
+		{ID: 3, Title: "First", Content: "a"},
+	}
+	var first, second bytes.Buffer
+	if err := Write(&first, notes); err != nil {
+		t.Fatal(err)
+	}
+	// Same notes, same bytes: nothing to commit
+	Write(&second, []Note{notes[1], notes[0]})
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("Write() is not deterministic")
+	}
+
+	got, err := Read(bytes.NewReader(first.Bytes()), int64(first.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != 3 || got[1].Title != "Second" || !reflect.DeepEqual(got[1].Tags, []string{"x"}) {
+		t.Errorf("Read() = %+v", got)
+	}
+}
+
+func TestImportFromFolder(t *testing.T) {
+	// A git host's ZIP download has everything in a top folder
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name, content string) {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	add("notes-main/manifest.json", `{"format": "infokeep-notes", "version": 1, "notes": []}`)
+	add("notes-main/notes/1.md", Format(Note{ID: 1, Title: "Kept", Content: "text", Tags: []string{"t"}}))
+	add("notes-main/notes/new.md", "Written in an editor\n")
+	add("notes-main/README.md", "not a note")
+	zw.Close()
+
+	found, err := Import(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Notes) != 2 || found.Notes[0].Title != "Kept" || found.Notes[1].Title != "new" {
+		t.Errorf("Import() = %+v", found.Notes)
+	}
+
+	if _, err := Import(context.Background(), strings.NewReader("not a zip")); err == nil {
+		t.Error("Import() of a non-ZIP succeeded")
+	}
+}
@@ -0,0 +1,151 @@
+// Package netscape imports bookmark files in the Netscape format, which is
+// what browsers write when exporting bookmarks to HTML and what most
+// bookmarking services export too.
+//
+// A bookmark's TAGS attribute (written by Firefox, Pinboard and others)
+// becomes its tags, as do the names of the folders it is in, apart from the
+// browser's own top folders such as "Bookmarks bar".
+package netscape
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"infokeep/internal/extensions"
+
+	"golang.org/x/net/html"
+)
+
+func init() {
+	extensions.RegisterImporter(extensions.Importer{
+		Name:        "netscape",
+		Description: "Browser bookmarks (HTML export)",
+		Accept:      ".html,.htm",
+		Import:      Import,
+	})
+}
+
+// Import reads the bookmarks of a Netscape bookmark file
+func Import(ctx context.Context, r io.Reader) (*extensions.Import, error) {
+	found := &extensions.Import{}
+	z := html.NewTokenizer(r)
+
+	var (
+		folders []string // one entry per open <DL>, "" when it has no folder name
+		folder  string   // name of the last <H3>, the folder of the next <DL>
+		last    *extensions.ImportedBookmark
+		desc    *strings.Builder // the <DD> text being read, describing last
+		sawDL   bool
+	)
+	for {
+		tt := z.Next()
+		if desc != nil && tt != html.TextToken {
+			last.Description = strings.TrimSpace(desc.String())
+			desc = nil
+		}
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			if !sawDL {
+				return nil, fmt.Errorf("not a bookmarks file")
+			}
+			return found, nil
+
+		case html.StartTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "dl":
+				sawDL = true
+				folders = append(folders, folder)
+				folder = ""
+			case "h3":
+				folder = strings.TrimSpace(text(z, "h3"))
+				last = nil
+			case "a":
+				b := extensions.ImportedBookmark{URL: attr(tok, "href")}
+				if b.URL == "" || strings.HasPrefix(b.URL, "javascript:") || strings.HasPrefix(b.URL, "place:") {
+					last = nil
+					continue
+				}
+				b.Title = strings.TrimSpace(text(z, "a"))
+				if b.Title == "" {
+					b.Title = b.URL
+				}
+				b.Tags = tags(attr(tok, "tags"), folders)
+				found.Bookmarks = append(found.Bookmarks, b)
+				last = &found.Bookmarks[len(found.Bookmarks)-1]
+			case "dd":
+				// The description of the bookmark before it, up to the next tag
+				if last != nil {
+					desc = &strings.Builder{}
+				}
+			}
+
+		case html.TextToken:
+			if desc != nil {
+				desc.Write(z.Text())
+			}
+
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "dl" && len(folders) > 0 {
+				folders = folders[:len(folders)-1]
+				last = nil
+			}
+		}
+	}
+}
+
+// text reads the text up to the end tag of tag. Entities are decoded by
+// the tokenizer.
+func text(z *html.Tokenizer, tag string) string {
+	var sb strings.Builder
+	for {
+		switch z.Next() {
+		case html.TextToken:
+			sb.Write(z.Text())
+		case html.ErrorToken:
+			return sb.String()
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == tag {
+				return sb.String()
+			}
+		}
+	}
+}
+
+func attr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// tags returns a bookmark's tags: those of its TAGS attribute and the folders
+// it is in. folders[0] is the file's outer list and folders[1] the browser's
+// top folder, so neither is a tag.
+func tags(tagsAttr string, folders []string) []string {
+	var list []string
+	seen := map[string]bool{}
+	add := func(t string) {
+		t = strings.TrimSpace(t)
+		if t != "" && !seen[strings.ToLower(t)] {
+			seen[strings.ToLower(t)] = true
+			list = append(list, t)
+		}
+	}
+	for _, t := range strings.Split(tagsAttr, ",") {
+		add(t)
+	}
+	if len(folders) > 2 {
+		for _, f := range folders[2:] {
+			add(f)
+		}
+	}
+	return list
+}
@@ -0,0 +1,60 @@
+package netscape
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const bookmarksFile = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/" ADD_DATE="1700000001">The Go &amp; Gophers</A>
+        <DD>Docs and downloads
+        <DT><H3>Cooking</H3>
+        <DD>Things to make
+        <DL><p>
+            <DT><A HREF="https://example.com/bread" TAGS="baking,Bread">Bread</A>
+            <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+        </DL><p>
+        <DT><A HREF="https://example.com/after">After the folder</A>
+    </DL><p>
+    <DT><A HREF="https://example.com/top"></A>
+</DL>
+`
+
+func TestImport(t *testing.T) {
+	found, err := Import(context.Background(), strings.NewReader(bookmarksFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type bookmark struct {
+		title, url, description string
+		tags                    []string
+	}
+	want := []bookmark{
+		{"The Go & Gophers", "https://go.dev/", "Docs and downloads", nil},
+		{"Bread", "https://example.com/bread", "", []string{"baking", "Bread", "Cooking"}},
+		{"After the folder", "https://example.com/after", "", nil},
+		{"https://example.com/top", "https://example.com/top", "", nil},
+	}
+	var got []bookmark
+	for _, b := range found.Bookmarks {
+		got = append(got, bookmark{b.Title, b.URL, b.Description, b.Tags})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Import() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestImportRejectsOtherFiles(t *testing.T) {
+	if _, err := Import(context.Background(), strings.NewReader("<html><body>Hello</body></html>")); err == nil {
+		t.Error("Import() of a page without bookmarks succeeded")
+	}
+}
@@ -0,0 +1,158 @@
+// Package pocket imports the export of Pocket, the read-it-later service,
+// in either of the formats it has used: an HTML page (ril_export.html) with
+// an "Unread" and a "Read Archive" list of links, or a CSV file with the
+// columns title, url, time_added, tags and status.
+//
+// Unread links go into the read-later queue and read ones are marked read
+// there, so the queue carries over. Pocket's tags become tags.
+package pocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"infokeep/internal/extensions"
+
+	"golang.org/x/net/html"
+)
+
+func init() {
+	extensions.RegisterImporter(extensions.Importer{
+		Name:        "pocket",
+		Description: "Pocket (HTML or CSV export)",
+		Accept:      ".html,.htm,.csv",
+		Import:      Import,
+	})
+}
+
+// Import reads the bookmarks of a Pocket export, telling the formats apart
+// by the first character
+func Import(ctx context.Context, r io.Reader) (*extensions.Import, error) {
+	br := bufio.NewReader(r)
+	start, _ := br.Peek(512)
+	if bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(start, []byte("\ufeff"))), []byte("<")) {
+		return importHTML(br)
+	}
+	return importCSV(br)
+}
+
+// importHTML reads the HTML export: an <h1> names the list (Unread or Read
+// Archive) of the links after it
+func importHTML(r io.Reader) (*extensions.Import, error) {
+	found := &extensions.Import{}
+	z := html.NewTokenizer(r)
+	read, sawList := false, false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			if !sawList {
+				return nil, fmt.Errorf("not a Pocket export")
+			}
+			return found, nil
+
+		case html.StartTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "h1":
+				sawList = true
+				read = strings.Contains(strings.ToLower(text(z, "h1")), "read archive")
+			case "a":
+				u := attr(tok, "href")
+				if !sawList || !strings.HasPrefix(u, "http") {
+					continue
+				}
+				found.Bookmarks = append(found.Bookmarks, newBookmark(strings.TrimSpace(text(z, "a")), u, strings.Split(attr(tok, "tags"), ","), read))
+			}
+		}
+	}
+}
+
+// importCSV reads the CSV export, whose tags are separated by "|" and whose
+// status is "unread" or "archive"
+func importCSV(r io.Reader) (*extensions.Import, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("not a Pocket export: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("not a Pocket export: no url column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	found := &extensions.Import{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return found, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		u := field(record, "url")
+		if !strings.HasPrefix(u, "http") {
+			continue
+		}
+		read := field(record, "status") == "archive"
+		found.Bookmarks = append(found.Bookmarks, newBookmark(field(record, "title"), u, strings.Split(field(record, "tags"), "|"), read))
+	}
+}
+
+// newBookmark is a link of the export: in the read-later queue, and read if
+// it was archived in Pocket
+func newBookmark(title, url string, tags []string, read bool) extensions.ImportedBookmark {
+	if title == "" {
+		title = url
+	}
+	b := extensions.ImportedBookmark{Title: title, URL: url, ReadLater: true, Read: read}
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t != "" {
+			b.Tags = append(b.Tags, t)
+		}
+	}
+	return b
+}
+
+// text reads the text up to the end tag of tag
+func text(z *html.Tokenizer, tag string) string {
+	var sb strings.Builder
+	for {
+		switch z.Next() {
+		case html.TextToken:
+			sb.Write(z.Text())
+		case html.ErrorToken:
+			return sb.String()
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == tag {
+				return sb.String()
+			}
+		}
+	}
+}
+
+func attr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
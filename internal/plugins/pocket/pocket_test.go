@@ -0,0 +1,74 @@
+package pocket
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const htmlExport = `<!DOCTYPE html>
+<html><head><title>Pocket Export</title></head><body>
+<h1>Unread</h1>
+<ul>
+<li><a href="https://go.dev/blog" time_added="1700000000" tags="go,reading">The Go Blog</a></li>
+<li><a href="https://example.com/untitled" time_added="1700000001" tags=""></a></li>
+</ul>
+<h1>Read Archive</h1>
+<ul>
+<li><a href="https://example.com/bread" time_added="1600000000" tags="baking">Bread &amp; butter</a></li>
+</ul>
+</body></html>`
+
+const csvExport = "\ufefftitle,url,time_added,tags,status\n" +
+	"The Go Blog,https://go.dev/blog,1700000000,go|reading,unread\n" +
+	"\"Bread, and butter\",https://example.com/bread,1600000000,baking,archive\n" +
+	"Not a link,pocket://nothing,1600000000,,unread\n"
+
+type bookmark struct {
+	title, url      string
+	tags            []string
+	readLater, read bool
+}
+
+func imported(t *testing.T, export string) []bookmark {
+	t.Helper()
+	found, err := Import(context.Background(), strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []bookmark
+	for _, b := range found.Bookmarks {
+		got = append(got, bookmark{b.Title, b.URL, b.Tags, b.ReadLater, b.Read})
+	}
+	return got
+}
+
+func TestImportHTML(t *testing.T) {
+	want := []bookmark{
+		{"The Go Blog", "https://go.dev/blog", []string{"go", "reading"}, true, false},
+		{"https://example.com/untitled", "https://example.com/untitled", nil, true, false},
+		{"Bread & butter", "https://example.com/bread", []string{"baking"}, true, true},
+	}
+	if got := imported(t, htmlExport); !reflect.DeepEqual(got, want) {
+		t.Errorf("Import() = %v, want %v", got, want)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	want := []bookmark{
+		{"The Go Blog", "https://go.dev/blog", []string{"go", "reading"}, true, false},
+		{"Bread, and butter", "https://example.com/bread", []string{"baking"}, true, true},
+	}
+	if got := imported(t, csvExport); !reflect.DeepEqual(got, want) {
+		t.Errorf("Import() = %v, want %v", got, want)
+	}
+}
+
+func TestImportOther(t *testing.T) {
+	for _, export := range []string{"<html><body><p>Hello</p></body></html>", "name,address\nA,B\n"} {
+		if _, err := Import(context.Background(), strings.NewReader(export)); err == nil {
+			t.Errorf("Import(%q) did not fail", export)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package scraper
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// skippedElements hold no article text: code, navigation and page chrome
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"iframe": true, "nav": true, "header": true, "footer": true, "aside": true,
+	"form": true, "button": true, "select": true,
+}
+
+// blockElements start a new line of text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"li": true, "blockquote": true, "pre": true, "br": true, "tr": true,
+	"figcaption": true, "dd": true, "dt": true,
+}
+
+// ArticleText returns the readable text of a page: that of its <article>,
+// or else its <main> or <body>, without navigation, headers, footers and
+// scripts. Paragraphs are separated by newlines.
+func ArticleText(doc *html.Node) string {
+	var sb strings.Builder
+	writeText(&sb, articleRoot(doc))
+	var lines []string
+	for _, line := range strings.Split(sb.String(), "\n") {
+		if line = collapseSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// articleRoot returns the element holding a page's article: its <article>,
+// or else its <main> or <body>
+func articleRoot(doc *html.Node) *html.Node {
+	for _, tag := range []string{"article", "main", "body"} {
+		if root := findElement(doc, tag); root != nil {
+			return root
+		}
+	}
+	return doc
+}
+
+func writeText(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if skippedElements[n.Data] {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(sb, c)
+	}
+	if n.Type == html.ElementNode && blockElements[n.Data] {
+		sb.WriteByte('\n')
+	}
+}
+
+// findElement returns the first element named tag, depth first
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// keptElements are the elements ArticleHTML keeps, with the attributes kept
+// of each. Others are left out but their content is kept.
+var keptElements = map[string][]string{
+	"p": nil, "h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"ul": nil, "ol": nil, "li": nil, "dl": nil, "dt": nil, "dd": nil,
+	"blockquote": nil, "pre": nil, "code": nil, "br": nil, "hr": nil,
+	"em": nil, "i": nil, "strong": nil, "b": nil, "sub": nil, "sup": nil,
+	"figure": nil, "figcaption": nil,
+	"table": nil, "thead": nil, "tbody": nil, "tr": nil, "th": {"colspan", "rowspan"}, "td": {"colspan", "rowspan"},
+	"a": {"href"},
+}
+
+// ArticleHTML returns the article of a page as ArticleText finds it, as HTML
+// that is safe to show: only text markup is kept, without scripts, styles,
+// images or event handlers, and links are made absolute against base and
+// kept only when they are http(s).
+func ArticleHTML(doc *html.Node, base *url.URL) string {
+	var sb strings.Builder
+	for c := articleRoot(doc).FirstChild; c != nil; c = c.NextSibling {
+		writeHTML(&sb, c, base)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func writeHTML(sb *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if skippedElements[n.Data] {
+		return
+	}
+	attrs, kept := keptElements[n.Data]
+	if kept {
+		sb.WriteString("<" + n.Data)
+		for _, a := range n.Attr {
+			if a.Namespace != "" || !slices.Contains(attrs, a.Key) {
+				continue
+			}
+			val := a.Val
+			if a.Key == "href" {
+				if val = absoluteHTTP(base, val); val == "" {
+					continue
+				}
+			}
+			sb.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+		}
+		sb.WriteString(">")
+		if n.Data == "br" || n.Data == "hr" {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeHTML(sb, c, base)
+	}
+	if kept {
+		sb.WriteString("</" + n.Data + ">")
+	} else if blockElements[n.Data] {
+		sb.WriteByte('\n')
+	}
+}
+
+// absoluteHTTP returns ref resolved against base, or "" unless it is an
+// http(s) address
+func absoluteHTTP(base *url.URL, ref string) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestArticleText(t *testing.T) {
+	tests := []struct {
+		name, page, want string
+	}{
+		{
+			name: "article",
+			page: `<html><body>
+				<header><nav><a href="/">Home</a></nav></header>
+				<article>
+					<h1>Butter   chicken</h1>
+					<p>A creamy curry.<br>Ready in <b>30</b> minutes.</p>
+					<script>track()</script>
+					<aside>Related posts</aside>
+					<ul><li>Chicken</li><li>Butter</li></ul>
+				</article>
+				<footer>© 2024</footer>
+			</body></html>`,
+			want: "Butter chicken\nA creamy curry.\nReady in 30 minutes.\nChicken\nButter",
+		},
+		{
+			name: "main",
+			page: `<body><div>Cookie banner</div><main><p>The text.</p></main></body>`,
+			want: "The text.",
+		},
+		{
+			name: "body",
+			page: `<body><header>Site</header><div>First</div><div>Second</div><footer>Links</footer></body>`,
+			want: "First\nSecond",
+		},
+	}
+	for _, tt := range tests {
+		doc, err := html.Parse(strings.NewReader(tt.page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ArticleText(doc); got != tt.want {
+			t.Errorf("%s: ArticleText() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestArticleHTML(t *testing.T) {
+	page := `<html><body><nav><a href="/">Home</a></nav><article>` +
+		`<h1 class="title" onclick="steal()">Butter &amp; chicken</h1>` +
+		`<div style="color:red"><p>A <a href="/curry" target="_blank">creamy</a> curry.<br><img src="a.png" onerror="x()"></p></div>` +
+		`<script>track()</script>` +
+		`<p><a href="javascript:alert(1)">Run</a> <a href="mailto:a@b.c">Mail</a></p>` +
+		`<table><tr><td colspan="2" bgcolor="red">Cell</td></tr></table>` +
+		`</article></body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/recipes/")
+	want := `<h1>Butter &amp; chicken</h1>` +
+		`<p>A <a href="https://example.com/curry">creamy</a> curry.<br></p>` + "\n" +
+		`<p><a>Run</a> <a>Mail</a></p>` +
+		`<table><tbody><tr><td colspan="2">Cell</td></tr></tbody></table>`
+	if got := ArticleHTML(doc, base); got != want {
+		t.Errorf("ArticleHTML() = %q, want %q", got, want)
+	}
+}
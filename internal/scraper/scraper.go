@@ -0,0 +1,226 @@
+// Package scraper extracts metadata from web pages: the title, description
+// and preview image sites publish for link previews (Open Graph and Twitter
+// card tags), the canonical URL, feed links and icons.
+//
+//	meta, err := scraper.Parse(resp.Body, resp.Request.URL)
+//	if err == nil && meta.Image != "" {
+//		// use meta.Image as the thumbnail
+//	}
+package scraper
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Feed is an RSS or Atom feed a page links to
+type Feed struct {
+	URL   string
+	Type  string // MIME type, e.g. application/rss+xml
+	Title string
+}
+
+// Metadata is what a page says about itself. URLs are absolute; fields the
+// page doesn't provide are empty.
+type Metadata struct {
+	Title       string // og:title, twitter:title or <title>
+	Description string // og:description, twitter:description or meta description
+	Image       string // og:image or twitter:image
+	SiteName    string // og:site_name
+	Canonical   string // link rel="canonical" or og:url
+	Feeds       []Feed
+	// Keywords are the page's article:tag properties and the comma-separated
+	// words of its keywords and news_keywords meta tags, without repeats
+	Keywords []string
+	// Icons are the icons linked with rel="icon" (or "shortcut icon"),
+	// followed by the apple-touch-icons. Mask icons are left out.
+	Icons []string
+	// OpenGraph holds every og:* property by name, e.g. "og:type". Only the
+	// first of repeated properties is kept.
+	OpenGraph map[string]string
+}
+
+// Parse reads an HTML page and extracts its metadata. base is the URL the
+// page was fetched from, used to resolve relative links.
+func Parse(r io.Reader, base *url.URL) (*Metadata, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return Extract(doc, base), nil
+}
+
+// Extract returns the metadata of a parsed HTML page
+func Extract(doc *html.Node, base *url.URL) *Metadata {
+	if base == nil {
+		base = &url.URL{}
+	}
+	p := &pageParser{base: base, meta: map[string]string{}}
+	p.walk(doc)
+
+	m := &Metadata{
+		Title:       firstNonEmpty(p.meta["og:title"], p.meta["twitter:title"], p.title),
+		Description: firstNonEmpty(p.meta["og:description"], p.meta["twitter:description"], p.meta["description"]),
+		Image: p.resolve(firstNonEmpty(p.meta["og:image:secure_url"], p.meta["og:image"], p.meta["og:image:url"],
+			p.meta["twitter:image"], p.meta["twitter:image:src"])),
+		SiteName:  p.meta["og:site_name"],
+		Canonical: firstNonEmpty(p.canonical, p.resolve(p.meta["og:url"])),
+		Keywords:  p.keywords,
+		Feeds:     p.feeds,
+		Icons:     append(p.icons, p.touchIcons...),
+		OpenGraph: map[string]string{},
+	}
+	for k, v := range p.meta {
+		if strings.HasPrefix(k, "og:") {
+			m.OpenGraph[k] = v
+		}
+	}
+	return m
+}
+
+type pageParser struct {
+	base              *url.URL
+	title             string
+	meta              map[string]string // meta tag contents by lowercased property or name
+	canonical         string
+	keywords          []string
+	feeds             []Feed
+	icons, touchIcons []string
+}
+
+func (p *pageParser) walk(n *html.Node) {
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "title":
+			if p.title == "" {
+				p.title = collapseSpace(textContent(n))
+			}
+		case "base":
+			if href := strings.TrimSpace(attr(n, "href")); href != "" {
+				if u, err := p.base.Parse(href); err == nil {
+					p.base = u
+				}
+			}
+		case "meta":
+			p.addMeta(n)
+		case "link":
+			p.addLink(n)
+		case "svg", "script", "style", "template":
+			// An inline SVG has its own <title> elements
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.walk(c)
+	}
+}
+
+func (p *pageParser) addMeta(n *html.Node) {
+	// Open Graph uses property=, Twitter cards and plain meta tags name=,
+	// but sites mix them up
+	key := strings.ToLower(strings.TrimSpace(firstNonEmpty(attr(n, "property"), attr(n, "name"))))
+	content := collapseSpace(attr(n, "content"))
+	if key == "" || content == "" {
+		return
+	}
+	switch key {
+	case "article:tag":
+		p.addKeyword(content)
+	case "keywords", "news_keywords":
+		for _, k := range strings.Split(content, ",") {
+			p.addKeyword(strings.TrimSpace(k))
+		}
+	}
+	if _, seen := p.meta[key]; !seen {
+		p.meta[key] = content
+	}
+}
+
+func (p *pageParser) addKeyword(k string) {
+	if k == "" {
+		return
+	}
+	for _, seen := range p.keywords {
+		if strings.EqualFold(seen, k) {
+			return
+		}
+	}
+	p.keywords = append(p.keywords, k)
+}
+
+func (p *pageParser) addLink(n *html.Node) {
+	href := p.resolve(attr(n, "href"))
+	if href == "" {
+		return
+	}
+	for _, rel := range strings.Fields(strings.ToLower(attr(n, "rel"))) {
+		switch rel {
+		case "canonical":
+			if p.canonical == "" {
+				p.canonical = href
+			}
+		case "alternate":
+			t := strings.ToLower(strings.TrimSpace(attr(n, "type")))
+			if t != "application/rss+xml" && t != "application/atom+xml" && t != "application/feed+json" {
+				// e.g. rel="alternate icon" or a translation of the page
+				continue
+			}
+			p.feeds = append(p.feeds, Feed{URL: href, Type: t, Title: collapseSpace(attr(n, "title"))})
+		case "icon":
+			p.icons = append(p.icons, href)
+		case "apple-touch-icon", "apple-touch-icon-precomposed":
+			p.touchIcons = append(p.touchIcons, href)
+		default:
+			continue
+		}
+		return
+	}
+}
+
+// resolve makes a link absolute, returning "" for links that aren't http(s)
+func (p *pageParser) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := p.base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		}
+	}
+	return sb.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package scraper
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The pages in testdata are trimmed copies of the <head> of real pages
+// (GitHub, a WordPress blog, a video site) plus a few hand-written ones for
+// the awkward cases.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file string
+		url  string
+		want Metadata
+	}{
+		{
+			file: "github_repo.html",
+			url:  "https://github.com/go-chi/chi",
+			want: Metadata{
+				Title:       "GitHub - go-chi/chi: lightweight, idiomatic and composable router for building Go HTTP services",
+				Description: "lightweight, idiomatic and composable router for building Go HTTP services - go-chi/chi",
+				Image:       "https://opengraph.githubassets.com/1a2b3c/go-chi/chi",
+				SiteName:    "GitHub",
+				Canonical:   "https://github.com/go-chi/chi",
+				Icons: []string{
+					"https://github.githubassets.com/favicons/favicon.png",
+					"https://github.githubassets.com/favicons/favicon.svg",
+				},
+			},
+		},
+		{
+			file: "wordpress_post.html",
+			url:  "https://thecozykitchen.example/butter-chicken/",
+			want: Metadata{
+				Title:       "Easy Weeknight Butter Chicken",
+				Description: "A creamy, mildly spiced butter chicken you can have on the table in 30 minutes.",
+				Image:       "https://thecozykitchen.example/wp-content/uploads/2024/03/butter-chicken-1200x630.jpg",
+				SiteName:    "The Cozy Kitchen",
+				Canonical:   "https://thecozykitchen.example/butter-chicken/",
+				Keywords:    []string{"Curry", "Weeknight dinners"},
+				Feeds: []Feed{
+					{URL: "https://thecozykitchen.example/feed/", Type: "application/rss+xml", Title: "The Cozy Kitchen » Feed"},
+					{URL: "https://thecozykitchen.example/comments/feed/", Type: "application/rss+xml", Title: "The Cozy Kitchen » Comments Feed"},
+				},
+				Icons: []string{
+					"https://thecozykitchen.example/wp-content/uploads/2023/01/cropped-logo-32x32.png",
+					"https://thecozykitchen.example/wp-content/uploads/2023/01/cropped-logo-192x192.png",
+					"https://thecozykitchen.example/wp-content/uploads/2023/01/cropped-logo-180x180.png",
+				},
+			},
+		},
+		{
+			file: "reversed_attributes.html",
+			url:  "https://www.video.example/watch?v=abc123",
+			want: Metadata{
+				Title:       "Trailer: The Long Night",
+				Description: "Official trailer.",
+				Image:       "https://i.ytimg.example/vi/abc123/maxresdefault.jpg?secure",
+				Canonical:   "https://www.video.example/watch?v=abc123",
+				Icons:       []string{"https://www.video.example/s/desktop/favicon_32x32.png"},
+			},
+		},
+		{
+			file: "relative_links.html",
+			url:  "http://bread.example/blog/sourdough?utm_source=feed",
+			want: Metadata{
+				Title:       "Notes on sourdough",
+				Description: "What I learned baking a loaf a week for a year.",
+				Image:       "https://bread.example/blog/images/loaf.jpg",
+				Canonical:   "https://bread.example/blog/sourdough",
+				Keywords:    []string{"sourdough", "bread", "Baking"},
+				Feeds:       []Feed{{URL: "https://bread.example/blog/atom.xml", Type: "application/atom+xml"}},
+				Icons:       []string{"https://bread.example/blog/favicon.ico", "https://bread.example/late-icon.png"},
+			},
+		},
+		{
+			file: "twitter_card.html",
+			url:  "https://docs.acme.example/releases/2.0",
+			want: Metadata{
+				Title:       "Acme 2.0 is out",
+				Description: "Faster sync and a new plugin API.",
+				Image:       "https://docs.acme.example/static/card.png",
+				Feeds:       []Feed{{URL: "https://docs.acme.example/feed.json", Type: "application/feed+json", Title: "JSON Feed"}},
+				Icons:       []string{"https://docs.acme.example/apple-touch-icon.png"},
+			},
+		},
+		{
+			file: "bare.html",
+			url:  "https://example.com/",
+			want: Metadata{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			base, _ := url.Parse(tt.url)
+
+			got, err := Parse(f, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// OpenGraph is checked separately
+			got.OpenGraph = nil
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse(%s) =\n%+v\nwant\n%+v", tt.file, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOpenGraph(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "wordpress_post.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := Parse(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for prop, want := range map[string]string{
+		"og:type":   "article",
+		"og:locale": "en_US",
+		"og:image":  "https://thecozykitchen.example/wp-content/uploads/2024/03/butter-chicken-1200x630.jpg",
+	} {
+		if got.OpenGraph[prop] != want {
+			t.Errorf("OpenGraph[%q] = %q, want %q", prop, got.OpenGraph[prop], want)
+		}
+	}
+	if _, ok := got.OpenGraph["twitter:card"]; ok {
+		t.Error("OpenGraph contains a Twitter card tag")
+	}
+}
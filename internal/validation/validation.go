@@ -0,0 +1,167 @@
+// Package validation checks form and JSON input field by field. A Validator
+// collects one error per field so a form can show every problem at once.
+//
+//	var v validation.Validator
+//	title := v.Required("title", r.FormValue("title"), 200)
+//	score := v.Int("score", r.FormValue("score"), 0, 10)
+//	if !v.Valid() {
+//		// respond with v.Errors()
+//	}
+package validation
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError is a problem with one input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of field errors of a request
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Validator collects field errors. The zero value is ready to use.
+type Validator struct {
+	errs Errors
+}
+
+// Add records an error for a field unless it already has one
+func (v *Validator) Add(field, message string) {
+	if v.Has(field) {
+		return
+	}
+	v.errs = append(v.errs, FieldError{Field: field, Message: message})
+}
+
+// Has reports whether the field has an error
+func (v *Validator) Has(field string) bool {
+	for _, fe := range v.errs {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Validator) Valid() bool {
+	return len(v.errs) == 0
+}
+
+func (v *Validator) Errors() Errors {
+	return v.errs
+}
+
+// Required returns the trimmed value, recording an error if it is empty or
+// longer than max characters (max <= 0 means no limit)
+func (v *Validator) Required(field, value string, max int) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		v.Add(field, "is required")
+		return value
+	}
+	return v.MaxLength(field, value, max)
+}
+
+// MaxLength returns the value, recording an error if it is longer than max
+// characters (max <= 0 means no limit)
+func (v *Validator) MaxLength(field, value string, max int) string {
+	if max > 0 && utf8.RuneCountInString(value) > max {
+		v.Add(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+	return value
+}
+
+// URL returns the trimmed value, recording an error unless it is empty or an
+// absolute http(s) URL
+func (v *Validator) URL(field, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.Add(field, "must be a http:// or https:// URL")
+	}
+	return v.MaxLength(field, value, 2048)
+}
+
+// Int parses a required whole number between min and max
+func (v *Validator) Int(field, value string, min, max int) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		v.Add(field, "is required")
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		v.Add(field, "must be a whole number")
+		return 0
+	}
+	return v.Range(field, n, min, max)
+}
+
+// Range returns n, recording an error unless it is between min and max
+func (v *Validator) Range(field string, n, min, max int) int {
+	if n < min || n > max {
+		v.Add(field, fmt.Sprintf("must be between %d and %d", min, max))
+	}
+	return n
+}
+
+// Number parses an optional number between 0 and max, with a decimal point
+// or comma; an empty value is 0
+func (v *Validator) Number(field, value string, max float64) float64 {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", ".")
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(n) {
+		v.Add(field, "must be a number")
+		return 0
+	}
+	if n < 0 || n > max {
+		v.Add(field, fmt.Sprintf("must be between 0 and %g", max))
+	}
+	return n
+}
+
+// Date parses an optional date as YYYY-MM-DD, the value of a date input; an
+// empty value is ""
+func (v *Validator) Date(field, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		v.Add(field, "must be a date (YYYY-MM-DD)")
+		return ""
+	}
+	return value
+}
+
+// ID parses a required positive integer ID
+func (v *Validator) ID(field, value string) int64 {
+	id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || id <= 0 {
+		v.Add(field, "must be a valid ID")
+		return 0
+	}
+	return id
+}
@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	var v Validator
+	if got := v.Required("title", "  Dune  ", 10); got != "Dune" {
+		t.Errorf("Required trimmed to %q, want %q", got, "Dune")
+	}
+	v.Required("empty", "   ", 10)
+	v.Required("long", strings.Repeat("é", 11), 10)
+	v.URL("url", "example.com")
+	v.URL("no_url", "")
+	v.Int("score", "11", 0, 10)
+	v.Int("score_text", "ten", 0, 10)
+	v.ID("id", "-3")
+	v.Number("price", "-1", 100)
+	v.Number("quantity", "two", 100)
+	v.Date("due", "2026-02-30")
+	// Only the first error of a field is kept
+	v.Add("empty", "second error")
+
+	want := map[string]string{
+		"empty":      "is required",
+		"long":       "must be at most 10 characters",
+		"url":        "must be a http:// or https:// URL",
+		"score":      "must be between 0 and 10",
+		"score_text": "must be a whole number",
+		"id":         "must be a valid ID",
+		"price":      "must be between 0 and 100",
+		"quantity":   "must be a number",
+		"due":        "must be a date (YYYY-MM-DD)",
+	}
+	if v.Valid() {
+		t.Fatal("Valid() = true, want false")
+	}
+	if len(v.Errors()) != len(want) {
+		t.Errorf("got %d errors, want %d: %v", len(v.Errors()), len(want), v.Errors())
+	}
+	for _, fe := range v.Errors() {
+		if want[fe.Field] != fe.Message {
+			t.Errorf("%s: got %q, want %q", fe.Field, fe.Message, want[fe.Field])
+		}
+	}
+}
+
+func TestValidatorValid(t *testing.T) {
+	var v Validator
+	v.Required("title", "Dune", 200)
+	v.URL("url", "https://example.com/books?id=1")
+	if n := v.Int("score", " 7 ", 0, 10); n != 7 {
+		t.Errorf("Int = %d, want 7", n)
+	}
+	if id := v.ID("id", "42"); id != 42 {
+		t.Errorf("ID = %d, want 42", id)
+	}
+	if n := v.Number("price", "2,50", 100); n != 2.5 {
+		t.Errorf("Number = %g, want 2.5", n)
+	}
+	if d := v.Date("due", " 2026-03-01 "); d != "2026-03-01" {
+		t.Errorf("Date = %q, want 2026-03-01", d)
+	}
+	if d := v.Date("no_due", ""); d != "" {
+		t.Errorf("Date of an empty value = %q", d)
+	}
+	if n := v.Number("quantity", " ", 100); n != 0 {
+		t.Errorf("Number of nothing = %g, want 0", n)
+	}
+	if !v.Valid() {
+		t.Errorf("Valid() = false: %v", v.Errors())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// listen opens the listener the server is reached on: the unix domain
+// socket SOCKET_PATH if it is set, otherwise TCP port PORT (8080 by
+// default). It also returns a description of the address for the log.
+//
+// The socket is created with the permissions SOCKET_MODE (octal, 0660 by
+// default) and, if SOCKET_GROUP is set, owned by that group, so that e.g.
+// nginx running as www-data can connect to it.
+func listen() (net.Listener, string, error) {
+	path := os.Getenv("SOCKET_PATH")
+	if path == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		ln, err := net.Listen("tcp", ":"+port)
+		return ln, ":" + port, err
+	}
+
+	mode := os.FileMode(0660)
+	if s := os.Getenv("SOCKET_MODE"); s != "" {
+		m, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || m > 0777 {
+			return nil, "", fmt.Errorf("invalid SOCKET_MODE %q: want octal permissions like 0660", s)
+		}
+		mode = os.FileMode(m)
+	}
+
+	// A socket left behind by a previous run that didn't shut down cleanly
+	// would make the listen fail. Anything else at the path is left alone.
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, "", fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := setSocketPermissions(path, mode, os.Getenv("SOCKET_GROUP")); err != nil {
+		ln.Close()
+		return nil, "", err
+	}
+	return ln, "unix:" + path, nil
+}
+
+func setSocketPermissions(path string, mode os.FileMode, group string) error {
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return fmt.Errorf("invalid SOCKET_GROUP: %w", err)
+		}
+		gid, err := strconv.Atoi(g.Gid)
+		if err != nil {
+			return fmt.Errorf("invalid SOCKET_GROUP: %w", err)
+		}
+		if err := os.Chown(path, -1, gid); err != nil {
+			return fmt.Errorf("setting socket group: %w", err)
+		}
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("setting socket permissions: %w", err)
+	}
+	return nil
+}
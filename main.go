@@ -1,156 +1,106 @@
 package main
 
 import (
+	"context"
 	"infokeep/internal/database"
+	"infokeep/internal/datadir"
 	"infokeep/internal/handlers"
+	"infokeep/internal/jobs"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	// Extensions, which register themselves (see internal/extensions)
+	_ "infokeep/internal/plugins/bookmarkjson"
+	_ "infokeep/internal/plugins/gitnotes"
+	_ "infokeep/internal/plugins/netscape"
+	_ "infokeep/internal/plugins/pocket"
+)
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+// Request timeouts. Handlers get a context that is cancelled after the
+// timeout and the client gets a 504 if the handler ran out of time.
+const (
+	requestTimeout     = 30 * time.Second
+	longRequestTimeout = 5 * time.Minute // uploads, imports and exports
 )
 
 func main() {
+	// Create the data directory, moving in the data of older versions
+	if err := datadir.Init(); err != nil {
+		log.Fatalf("Failed to set up the data directory: %v", err)
+	}
+
 	// Initialize database
-	dbPath := "infokeep.db"
+	dbPath := datadir.DB()
 	if err := database.InitDB(dbPath); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer database.DB.Close()
 
-	// Start backup scheduler in background
-	go handlers.StartBackupScheduler(dbPath)
+	// Parse all templates up front so a broken one stops the server here
+	if err := handlers.LoadTemplates(); err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	handlers.LoadMaintenanceMode()
+	handlers.LoadAnnouncement()
+
+	// Start the scheduler for recurring tasks (cloud backups, cleanups)
+	handlers.RegisterScheduledTasks(dbPath)
+	go jobs.Start()
 
 	// Initialize Web Push VAPID keys
 	handlers.InitVAPIDKeys()
 	// Start the Reminders scheduler
 	go handlers.StartReminderWorker()
+	// Start the background job queue worker
+	go handlers.StartJobWorker()
+
+	r := newRouter()
+
+	// Serve under BASE_PATH when reverse proxied at a subpath. The proxy
+	// forwards the full path; the prefix is stripped so routes stay the same.
+	var handler http.Handler = r
+	if base := handlers.BasePath; base != "" {
+		mux := http.NewServeMux()
+		mux.Handle(base+"/", http.StripPrefix(base, r))
+		mux.Handle(base, http.RedirectHandler(base+"/", http.StatusMovedPermanently))
+		handler = mux
+	}
 
-	r := chi.NewRouter()
-
-	// Middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-
-	// Static files
-	workDir, _ := os.Getwd()
-	filesDir := http.Dir(workDir + "/web/static")
-	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(filesDir)))
-
-	// Service worker must be served from root for full scope
-	r.Get("/sw.js", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/javascript")
-		http.ServeFile(w, r, workDir+"/web/static/sw.js")
-	})
-
-	// Routes
-	r.Get("/login", handlers.LoginHandler)
-	r.Post("/login", handlers.LoginHandler)
-	r.Get("/register", handlers.RegisterHandler)
-	r.Post("/register", handlers.RegisterHandler)
-	r.Post("/logout", handlers.LogoutHandler)
-
-	// Public Share Route (No Auth Required)
-	r.Get("/shared/{hash}", handlers.PublicViewHandler)
-
-	// Protected Routes
-	r.Group(func(r chi.Router) {
-		r.Use(handlers.AuthMiddleware)
-
-		r.Get("/", handlers.IndexHandler)
-		r.Get("/dashboard", handlers.DashboardHandler)
-		r.Get("/share", handlers.ShareHandler)
-		r.Post("/items/{id}/pin", handlers.TogglePinHandler)
-		r.Get("/bookmarks", handlers.BookmarkHandler)
-		r.Post("/bookmarks", handlers.BookmarkHandler)
-		r.Get("/bookmarks/{id}", handlers.GetBookmarkHandler)
-		r.Post("/bookmarks/{id}", handlers.UpdateBookmarkHandler)
-		r.Get("/notes", handlers.NoteHandler)
-		r.Post("/notes", handlers.NoteHandler)
-		r.Get("/notes/{id}", handlers.GetNoteHandler)
-		r.Post("/notes/{id}", handlers.UpdateNoteHandler)
-		r.Get("/rated-lists", handlers.RatedListHandler)
-		r.Post("/rated-lists", handlers.RatedListHandler)
-		r.Get("/rated-lists/{id}/items", handlers.RatedListItemHandler)
-		r.Post("/rated-lists/{id}/items", handlers.RatedListItemHandler)
-		r.Get("/rated-list-items/{id}", handlers.GetRatedListItemHandler)
-		r.Post("/rated-list-items/{id}", handlers.UpdateRatedListItemHandler)
-		r.Get("/drawings", handlers.DrawingsHandler)
-		r.Post("/drawings", handlers.CreateDrawingHandler)
-		r.Get("/drawings/{id}", handlers.GetDrawingHandler)
-		r.Post("/drawings/{id}", handlers.UpdateDrawingHandler)
-		r.Get("/lists", handlers.ListHandler)
-		r.Post("/lists", handlers.ListHandler)
-		r.Get("/lists/{id}/items", handlers.ListItemHandler)
-		r.Post("/lists/{id}/items", handlers.ListItemHandler)
-		r.Get("/list-items/{id}", handlers.GetListItemByIdHandler)
-		r.Post("/list-items/{id}", handlers.UpdateListItemHandler)
-		r.Post("/list-items/{itemID}/toggle", handlers.ToggleListItemHandler)
-		r.Get("/media", handlers.MediaHandler)
-		r.Post("/media", handlers.MediaHandler)
-		r.Get("/media/{id}", handlers.GetMediaItemHandler)
-		r.Post("/media/{id}", handlers.UpdateMediaItemHandler)
-		r.Get("/recipes", handlers.RecipeHandler)
-		r.Post("/recipes", handlers.CreateRecipeHandler)
-		r.Get("/recipes/import", handlers.ImportRecipeHandler)
-		r.Post("/recipes/share-import", handlers.ShareImportRecipeHandler)
-		r.Get("/recipes/{id}", handlers.GetRecipeHandler)
-		r.Post("/recipes/{id}", handlers.UpdateRecipeHandler)
-		r.Get("/search", handlers.SearchHandler)
-		r.Get("/search/suggestions", handlers.SearchSuggestionsHandler)
-		r.Get("/tags/suggestions", handlers.TagSuggestionsHandler)
-
-		// Settings Routes
-		r.Get("/settings", handlers.SettingsHandler)
-		r.Post("/settings/import", handlers.ImportDataHandler)
-		r.Get("/settings/export", handlers.ExportDataHandler)
-		r.Post("/settings/landing-page", handlers.SetLandingPageHandler)
-
-		// pCloud Routes
-		r.Get("/settings/pcloud/link", handlers.PCloudLinkHandler)
-		r.Get("/settings/pcloud/callback", handlers.PCloudCallbackHandler)
-		r.Post("/settings/pcloud/unlink", handlers.PCloudUnlinkHandler)
-		r.Post("/settings/pcloud/backup-now", handlers.PCloudBackupNowHandler)
-		r.Post("/settings/pcloud/interval", handlers.PCloudUpdateIntervalHandler)
-		r.Get("/settings/pcloud/status", handlers.PCloudStatusHandler)
-
-		// Google Drive Routes
-		r.Get("/settings/gdrive/link", handlers.GDriveLinkHandler)
-		r.Get("/settings/gdrive/callback", handlers.GDriveCallbackHandler)
-		r.Post("/settings/gdrive/unlink", handlers.GDriveUnlinkHandler)
-		r.Post("/settings/gdrive/backup-now", handlers.GDriveBackupNowHandler)
-
-		// Reminders & Web Push
-		r.Get("/reminders", handlers.RemindersPageHandler)
-		r.Post("/reminders", handlers.AddReminderHandler)
-		r.Delete("/reminders/{id}", handlers.DeleteReminderHandler)
-		r.Post("/api/push/subscribe", handlers.SavePushSubscriptionHandler)
-	})
-
-	// API Routes (CORS enabled in handlers)
-	r.Route("/api", func(r chi.Router) {
-		r.Use(handlers.CorsMiddleware)
-		r.Use(handlers.AuthMiddleware)
-
-		// CorsMiddleware already returns 200 for OPTIONS, so this just ensures chi doesn't 404 preflight requests
-		r.Options("/*", func(w http.ResponseWriter, r *http.Request) {})
-		r.Get("/health", handlers.HealthHandler)
-		r.Post("/bookmarks", handlers.ApiCreateBookmarkClipperHandler)
-		r.Post("/notes", handlers.ApiCreateNoteClipperHandler)
-		r.Get("/rated-lists", handlers.ApiGetRatedListsHandler)
-		r.Post("/rated-lists/{id}/items", handlers.ApiAddRatedListItemHandler)
-		r.Post("/recipes/clipper", handlers.ApiCreateRecipeClipperHandler)
-		r.Get("/tags", handlers.ApiGetTagsHandler)
-
-		// Share Links
-		r.Post("/share", handlers.GenerateShareLinkHandler)
-		r.Delete("/share/{hash}", handlers.RevokeShareLinkHandler)
-	})
-
-	log.Println("Server starting on :8080")
+	ln, addr, err := listen()
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	log.Printf("Server starting on %s%s/", addr, handlers.BasePath)
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       longRequestTimeout,
+		// No WriteTimeout: the routes have their own timeouts, and a server
+		// wide one would cut off large downloads (exports, backups, videos)
+		// however long they rightly take to stream
+		IdleTimeout: 2 * time.Minute,
+	}
 
-	if err := http.ListenAndServe(":8080", r); err != nil {
+	// Shut down cleanly on SIGINT/SIGTERM (docker stop), so main returns and
+	// closing the database checkpoints its WAL back into infokeep.db
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		log.Println("Shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown: %v", err)
+		}
+	}()
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
 }
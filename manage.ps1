@@ -11,11 +11,11 @@ switch ($Target) {
         if (!(Test-Path -Path $BIN_DIR)) {
             New-Item -ItemType Directory -Force -Path $BIN_DIR | Out-Null
         }
-        go build -o "$BIN_DIR\$APP_NAME.exe" .
+        go build -tags sqlite_fts5 -o "$BIN_DIR\$APP_NAME.exe" .
     }
     "run" {
         Write-Host "Running $APP_NAME..."
-        go run .
+        go run -tags sqlite_fts5 .
     }
     "clean" {
         Write-Host "Cleaning up..."
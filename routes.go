@@ -0,0 +1,328 @@
+package main
+
+import (
+	"infokeep/internal/datadir"
+	"infokeep/internal/handlers"
+	"infokeep/web"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// newRouter returns the routes of the server. Static files are served from
+// the binary, uploads from the data directory. The database and templates
+// must be loaded first.
+func newRouter() *chi.Mux {
+	r := chi.NewRouter()
+
+	// Middleware
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(handlers.MetricsMiddleware)
+	r.Use(handlers.MaintenanceMiddleware)
+
+	// Static files
+	r.Handle("/static/uploads/*", http.StripPrefix("/static/uploads/", handlers.ServeUploads(datadir.Uploads())))
+	r.Handle("/static/rated_items/*", http.StripPrefix("/static/rated_items/", handlers.ServeUploads(datadir.RatedItems())))
+	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.FS(web.Static))))
+
+	// Service worker must be served from root for full scope
+	r.Get("/sw.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		http.ServeFileFS(w, r, web.Static, "sw.js")
+	})
+
+	// Prometheus metrics (requires METRICS_TOKEN)
+	r.Get("/metrics", handlers.MetricsHandler)
+
+	// Routes
+	r.Get("/login", handlers.LoginHandler)
+	r.Post("/login", handlers.LoginHandler)
+	r.Get("/register", handlers.RegisterHandler)
+	r.Post("/register", handlers.RegisterHandler)
+	r.Post("/logout", handlers.LogoutHandler)
+
+	// OpenSearch description, so browsers can search infokeep from the address bar
+	r.Get("/opensearch.xml", handlers.OpenSearchHandler)
+
+	// Public status page for uptime monitors (STATUS_PAGE)
+	if handlers.StatusPath != "" {
+		r.Get(handlers.StatusPath, handlers.StatusHandler)
+	}
+
+	// Public Share Route (No Auth Required)
+	r.Get("/shared/{hash}", handlers.PublicViewHandler)
+	r.Post("/shared/{hash}/comments", handlers.PublicCommentHandler)
+
+	// RSS and Atom feeds of new items, authenticated by the feed token
+	r.Get("/feeds/{token}/{format}", handlers.FeedHandler)
+
+	// Protected uploads, imports and exports. These move large bodies or
+	// fetch other sites, so they get longer than the default request timeout.
+	r.Group(func(r chi.Router) {
+		r.Use(handlers.AuthMiddleware)
+		r.Use(middleware.Timeout(longRequestTimeout))
+
+		r.Post("/drawings", handlers.CreateDrawingHandler)
+		r.Post("/drawings/{id}", handlers.UpdateDrawingHandler)
+		r.Post("/media", handlers.MediaHandler)
+		r.Post("/recipes", handlers.CreateRecipeHandler)
+		r.Get("/recipes/import", handlers.ImportRecipeHandler)
+		r.Get("/recipes/export", handlers.ExportRecipesHandler)
+		r.Post("/recipes/share-import", handlers.ShareImportRecipeHandler)
+		r.Post("/recipes/{id}", handlers.UpdateRecipeHandler)
+		r.Post("/cookbooks", handlers.CookbooksHandler)
+		r.Post("/cookbooks/{id}", handlers.UpdateCookbookHandler)
+		r.Get("/cookbooks/{id}/export", handlers.ExportCookbookHandler)
+		r.Post("/settings/import", handlers.ImportDataHandler)
+		r.Post("/settings/import-app", handlers.ImportFromAppHandler)
+		r.Get("/settings/export", handlers.ExportDataHandler)
+		r.Post("/bookmarks/{id}/archive", handlers.PageArchiveHandler)
+		r.Post("/bookmarks/{id}/check-link", handlers.CheckLinkHandler)
+		r.Get("/settings/export/download/{token}", handlers.DownloadExportHandler)
+		r.Get("/settings/database-backup", handlers.DatabaseBackupHandler)
+	})
+
+	// Protected Routes
+	r.Group(func(r chi.Router) {
+		r.Use(handlers.AuthMiddleware)
+		r.Use(middleware.Timeout(requestTimeout))
+
+		r.Get("/", handlers.IndexHandler)
+		r.Get("/dashboard", handlers.DashboardHandler)
+		r.Get("/share", handlers.ShareHandler)
+		r.Post("/capture", handlers.CaptureHandler)
+		r.Post("/items/bulk", handlers.BulkItemsHandler)
+		r.Post("/items/reorder", handlers.ReorderItemsHandler)
+		r.Post("/items/{id}/pin", handlers.TogglePinHandler)
+		r.Post("/items/{id}/archive", handlers.ArchiveItemHandler)
+		r.Post("/items/{id}/duplicate", handlers.DuplicateItemHandler)
+		r.Post("/items/{id}/view", handlers.ItemViewHandler)
+		r.Get("/items/{id}/links", handlers.ItemLinksHandler)
+		r.Post("/items/{id}/links", handlers.ItemLinksHandler)
+		r.Get("/items/{id}/links/search", handlers.LinkCandidatesHandler)
+		r.Delete("/items/{id}/links/{linkedID}", handlers.UnlinkItemHandler)
+		r.Get("/items/{id}/log", handlers.ItemLogHandler)
+		r.Post("/items/{id}/log", handlers.ItemLogHandler)
+		r.Get("/items/{id}/attachments", handlers.ItemAttachmentsHandler)
+		r.Post("/items/{id}/attachments", handlers.ItemAttachmentsHandler)
+		r.Delete("/items/{id}/attachments/{attachmentID}", handlers.DeleteAttachmentHandler)
+		r.Post("/items/{id}/summarize", handlers.SummarizeItemHandler)
+		r.Delete("/items/{id}", handlers.DeleteItemHandler)
+		r.Get("/items/{id}/reminders", handlers.ItemRemindersHandler)
+		r.Post("/items/{id}/reminders", handlers.ItemRemindersHandler)
+		r.Delete("/item-reminders/{id}", handlers.DeleteItemReminderHandler)
+		r.Get("/bookmarks", handlers.BookmarkHandler)
+		r.Post("/bookmarks", handlers.BookmarkHandler)
+		r.Get("/bookmarks/{id}", handlers.GetBookmarkHandler)
+		r.Get("/bookmarks/{id}/card", handlers.BookmarkCardHandler)
+		r.Post("/bookmarks/{id}", handlers.UpdateBookmarkHandler)
+		r.Get("/bookmarks/{id}/changes", handlers.BookmarkChangesHandler)
+		r.Get("/bookmarks/{id}/archive", handlers.PageArchiveHandler)
+		r.Post("/bookmarks/{id}/read-later", handlers.ReadLaterHandler)
+		r.Post("/bookmarks/{id}/read", handlers.MarkReadHandler)
+		r.Post("/bookmarks/{id}/read-toggle", handlers.ToggleReadHandler)
+		r.Get("/bookmarks/suggest-tags", handlers.SuggestTagsHandler)
+		r.Get("/bookmarks/{id}/suggest-tags", handlers.SuggestTagsHandler)
+		r.Get("/favicons/{host}", handlers.FaviconHandler)
+		r.Get("/notes", handlers.NoteHandler)
+		r.Post("/notes", handlers.NoteHandler)
+		r.Post("/notes/attachments", handlers.NoteUploadHandler)
+		r.Get("/notes/{id}", handlers.GetNoteHandler)
+		r.Post("/notes/{id}", handlers.UpdateNoteHandler)
+		r.Get("/rated-lists", handlers.RatedListHandler)
+		r.Post("/rated-lists", handlers.RatedListHandler)
+		r.Get("/rated-lists/{id}/items", handlers.RatedListItemHandler)
+		r.Post("/rated-lists/{id}/items", handlers.RatedListItemHandler)
+		r.Get("/rated-list-items/{id}", handlers.GetRatedListItemHandler)
+		r.Post("/rated-list-items/{id}", handlers.UpdateRatedListItemHandler)
+		r.Delete("/rated-list-items/{id}", handlers.DeleteRatedListItemHandler)
+		r.Get("/drawings", handlers.DrawingsHandler)
+		r.Get("/drawings/{id}", handlers.GetDrawingHandler)
+		r.Get("/lists", handlers.ListHandler)
+		r.Post("/lists", handlers.ListHandler)
+		r.Get("/lists/{id}/items", handlers.ListItemHandler)
+		r.Post("/lists/{id}/items", handlers.ListItemHandler)
+		r.Get("/list-items/{id}", handlers.GetListItemByIdHandler)
+		r.Post("/list-items/{id}", handlers.UpdateListItemHandler)
+		r.Post("/list-items/{itemID}/toggle", handlers.ToggleListItemHandler)
+		r.Delete("/list-items/{id}", handlers.DeleteListItemHandler)
+		r.Get("/media", handlers.MediaHandler)
+		r.Get("/media/duplicates", handlers.DuplicateMediaHandler)
+		r.Post("/media/duplicates/merge", handlers.MergeDuplicateMediaHandler)
+		r.Get("/media/{id}", handlers.GetMediaItemHandler)
+		r.Post("/media/{id}", handlers.UpdateMediaItemHandler)
+		r.Get("/recipes", handlers.RecipeHandler)
+		r.Get("/recipes/{id}", handlers.GetRecipeHandler)
+		r.Get("/cookbooks", handlers.CookbooksHandler)
+		r.Get("/cookbooks/{id}", handlers.GetCookbookHandler)
+		r.Delete("/cookbooks/{id}", handlers.DeleteCookbookHandler)
+		r.Post("/cookbooks/{id}/recipes", handlers.AddCookbookRecipeHandler)
+		r.Delete("/cookbooks/{id}/recipes/{recipeID}", handlers.RemoveCookbookRecipeHandler)
+		r.Get("/search", handlers.SearchHandler)
+		r.Get("/search/suggestions", handlers.SearchSuggestionsHandler)
+		r.Get("/tags/suggestions", handlers.TagSuggestionsHandler)
+
+		// Settings Routes
+		r.Get("/settings", handlers.SettingsHandler)
+		r.Post("/settings/export", handlers.StartExportHandler)
+		r.Get("/settings/export/jobs", handlers.ExportJobsHandler)
+		r.Post("/settings/migrate", handlers.StartMigrationHandler)
+		r.Get("/settings/migrate/jobs", handlers.MigrationJobsHandler)
+		r.Post("/settings/landing-page", handlers.SetLandingPageHandler)
+		r.Get("/settings/preferences", handlers.PreferencesHandler)
+		r.Post("/settings/preferences", handlers.PreferencesHandler)
+		r.Post("/settings/media-kind-tags", handlers.MediaKindTagsHandler)
+		r.Post("/settings/features", handlers.FeatureHandler)
+		r.Post("/settings/password", handlers.ChangePasswordHandler)
+		r.Post("/settings/token/allowlist", handlers.TokenAllowlistHandler)
+		r.Post("/settings/feed-token", handlers.RegenerateFeedTokenHandler)
+		r.Post("/settings/recipe-import", handlers.BulkImportRecipesHandler)
+		r.Get("/settings/recipe-import", handlers.RecipeImportStatusHandler)
+		r.Post("/settings/login-alerts", handlers.LoginAlertEmailHandler)
+		r.Post("/settings/digest", handlers.DigestSettingsHandler)
+		r.Get("/settings/digest/preview", handlers.DigestPreviewHandler)
+		r.Delete("/settings/devices/{id}", handlers.ForgetDeviceHandler)
+		r.Get("/settings/activity", handlers.ActivityLogHandler)
+		r.Post("/settings/maintenance", handlers.MaintenanceHandler)
+		r.Get("/settings/integrity", handlers.IntegrityHandler)
+		r.Post("/settings/integrity", handlers.IntegrityHandler)
+		r.Post("/settings/announcement", handlers.AnnouncementHandler)
+		r.Get("/announcement", handlers.AnnouncementBannerHandler)
+		r.Post("/announcement/dismiss", handlers.DismissAnnouncementHandler)
+
+		// pCloud Routes
+		r.Get("/settings/pcloud/link", handlers.PCloudLinkHandler)
+		r.Get("/settings/pcloud/callback", handlers.PCloudCallbackHandler)
+		r.Post("/settings/pcloud/unlink", handlers.PCloudUnlinkHandler)
+		r.Post("/settings/pcloud/backup-now", handlers.PCloudBackupNowHandler)
+		r.Post("/settings/pcloud/interval", handlers.PCloudUpdateIntervalHandler)
+		r.Get("/settings/pcloud/status", handlers.PCloudStatusHandler)
+
+		// Google Drive Routes
+		r.Get("/settings/gdrive/link", handlers.GDriveLinkHandler)
+		r.Get("/settings/gdrive/callback", handlers.GDriveCallbackHandler)
+		r.Post("/settings/gdrive/unlink", handlers.GDriveUnlinkHandler)
+		r.Post("/settings/gdrive/backup-now", handlers.GDriveBackupNowHandler)
+
+		// Comment inbox
+		r.Get("/inbox", handlers.InboxHandler)
+		r.Post("/inbox/{id}", handlers.ModerateCommentHandler)
+		r.Delete("/inbox/{id}", handlers.DeleteCommentHandler)
+
+		// Reminders & Web Push
+		r.Get("/reminders", handlers.RemindersPageHandler)
+		r.Post("/reminders", handlers.AddReminderHandler)
+		r.Delete("/reminders/{id}", handlers.DeleteReminderHandler)
+		r.Post("/api/push/subscribe", handlers.SavePushSubscriptionHandler)
+
+		// Automation rules
+		r.Get("/rules", handlers.RulesHandler)
+		r.Post("/rules", handlers.CreateRuleHandler)
+		r.Post("/rules/test", handlers.TestRuleHandler)
+		r.Post("/rules/{id}/toggle", handlers.ToggleRuleHandler)
+		r.Delete("/rules/{id}", handlers.DeleteRuleHandler)
+
+		// Trash
+		r.Get("/trash", handlers.TrashHandler)
+		r.Delete("/trash", handlers.EmptyTrashHandler)
+		r.Post("/trash/{id}/restore", handlers.RestoreItemHandler)
+		r.Delete("/trash/{id}", handlers.PurgeItemHandler)
+	})
+
+	// API Routes (CORS enabled in handlers)
+	r.Route("/api", func(r chi.Router) {
+		r.Use(handlers.CorsMiddleware)
+		r.Use(handlers.AuthMiddleware)
+
+		// CorsMiddleware already returns 200 for OPTIONS, so this just ensures chi doesn't 404 preflight requests
+		r.Options("/*", func(w http.ResponseWriter, r *http.Request) {})
+
+		r.With(middleware.Timeout(longRequestTimeout)).Post("/recipes/clipper", handlers.ApiCreateRecipeClipperHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(requestTimeout))
+
+			r.Get("/health", handlers.HealthHandler)
+			r.Get("/health/live", handlers.HealthLiveHandler)
+			r.Get("/health/ready", handlers.HealthReadyHandler)
+			r.Get("/version", handlers.VersionHandler)
+			r.Post("/bookmarks", handlers.ApiCreateBookmarkClipperHandler)
+			r.Get("/bookmarks/lookup", handlers.ApiLookupBookmarkHandler)
+			r.Post("/notes", handlers.ApiCreateNoteClipperHandler)
+			r.Post("/capture", handlers.CaptureHandler)
+			r.Get("/rated-lists", handlers.ApiGetRatedListsHandler)
+			r.Post("/rated-lists/{id}/items", handlers.ApiAddRatedListItemHandler)
+			r.Get("/tags", handlers.ApiGetTagsHandler)
+			r.Get("/recent", handlers.ApiRecentHandler)
+			r.Get("/recent-views", handlers.ApiRecentViewsHandler)
+			r.Get("/stats", handlers.ApiStatsHandler)
+			r.Get("/features", handlers.ApiFeaturesHandler)
+
+			// Share Links
+			r.Get("/share", handlers.GetShareLinkHandler)
+			r.Post("/share", handlers.GenerateShareLinkHandler)
+			r.Delete("/share/{hash}", handlers.RevokeShareLinkHandler)
+		})
+
+		// Versioned API
+		r.Route("/v1", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.Timeout(longRequestTimeout))
+
+				r.Get("/export", handlers.ApiExportHandler)
+				r.Post("/drawings", handlers.ApiCreateDrawingHandler)
+				r.Post("/media", handlers.ApiUploadMediaHandler)
+				r.Post("/voice-notes", handlers.ApiCreateVoiceNoteHandler)
+				r.Post("/bookmarks/{id}/archive", handlers.PageArchiveHandler)
+				r.Post("/bookmarks/{id}/check-link", handlers.CheckLinkHandler)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.Timeout(requestTimeout))
+
+				r.Get("/lists", handlers.ApiGetListsHandler)
+				r.Post("/lists", handlers.ApiCreateListHandler)
+				r.Get("/lists/{id}/items", handlers.ApiGetListItemsHandler)
+				r.Post("/lists/{id}/items", handlers.ApiAddListItemHandler)
+				r.Post("/lists/{id}/items/{itemID}/toggle", handlers.ApiToggleListItemHandler)
+				r.Post("/rated-lists/{id}/rate", handlers.ApiRateListItemHandler)
+				r.Get("/recipes/{id}", handlers.ApiGetRecipeHandler)
+				r.Get("/bookmarks/{id}/archive", handlers.PageArchiveHandler)
+				r.Post("/bookmarks/{id}/read-later", handlers.ReadLaterHandler)
+				r.Post("/bookmarks/{id}/read", handlers.MarkReadHandler)
+				r.Post("/bookmarks/{id}/read-toggle", handlers.ToggleReadHandler)
+				r.Get("/bookmarks/suggest-tags", handlers.SuggestTagsHandler)
+				r.Get("/bookmarks/{id}/suggest-tags", handlers.SuggestTagsHandler)
+				r.Post("/items/bulk", handlers.BulkItemsHandler)
+				r.Post("/items/reorder", handlers.ReorderItemsHandler)
+				r.Post("/items/{id}/pin", handlers.ApiPinItemHandler)
+				r.Post("/items/{id}/archive", handlers.ApiArchiveItemHandler)
+				r.Post("/items/{id}/duplicate", handlers.DuplicateItemHandler)
+				r.Post("/items/{id}/view", handlers.ItemViewHandler)
+				r.Get("/items/{id}/links", handlers.ItemLinksHandler)
+				r.Post("/items/{id}/links", handlers.ItemLinksHandler)
+				r.Delete("/items/{id}/links/{linkedID}", handlers.UnlinkItemHandler)
+				r.Get("/items/{id}/log", handlers.ItemLogHandler)
+				r.Post("/items/{id}/log", handlers.ItemLogHandler)
+				r.Get("/items/{id}/attachments", handlers.ItemAttachmentsHandler)
+				r.Post("/items/{id}/attachments", handlers.ItemAttachmentsHandler)
+				r.Delete("/items/{id}/attachments/{attachmentID}", handlers.DeleteAttachmentHandler)
+				r.Get("/preferences", handlers.PreferencesHandler)
+				r.Put("/preferences", handlers.PreferencesHandler)
+				r.Get("/drawings", handlers.ApiGetDrawingsHandler)
+				r.Get("/drawings/{id}", handlers.ApiGetDrawingHandler)
+				r.Get("/media", handlers.ApiGetMediaHandler)
+				r.Get("/media/{id}", handlers.ApiGetMediaItemHandler)
+				r.Post("/media/uploads", handlers.ApiCreateMediaUploadHandler)
+				r.Post("/media/uploads/{uploadID}/complete", handlers.ApiCompleteMediaUploadHandler)
+				r.Post("/snippets", handlers.ApiCreateSnippetHandler)
+				r.Get("/snippets/{code}", handlers.ApiGetSnippetHandler)
+				r.Delete("/snippets/{code}", handlers.ApiDeleteSnippetHandler)
+			})
+		})
+	})
+
+	return r
+}
@@ -0,0 +1,70 @@
+// The user's preferences are kept on the server (/settings/preferences), so
+// that every browser starts from them. The theme and date format are also
+// kept in localStorage to show pages in them before the preferences load.
+// Dates the pages show as <time class="item-date" datetime="..."> are
+// written in the date format: iso (2006-01-02 15:04), locale or relative.
+
+// savePreferences saves the given preferences, e.g. {theme: 'dark'}
+function savePreferences(changes) {
+    return fetch(BASE_PATH + '/settings/preferences', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(changes)
+    });
+}
+
+(function () {
+    const pad = n => String(n).padStart(2, '0');
+
+    function relative(date) {
+        const rtf = new Intl.RelativeTimeFormat(undefined, { numeric: 'auto' });
+        const seconds = (date - Date.now()) / 1000;
+        const units = [['year', 31536000], ['month', 2592000], ['week', 604800], ['day', 86400], ['hour', 3600], ['minute', 60]];
+        for (const [unit, size] of units) {
+            if (Math.abs(seconds) >= size) return rtf.format(Math.round(seconds / size), unit);
+        }
+        return rtf.format(0, 'second');
+    }
+
+    function formatDate(date, format) {
+        switch (format) {
+            case 'locale':
+                return date.toLocaleString(undefined, { dateStyle: 'medium', timeStyle: 'short' });
+            case 'relative':
+                return relative(date);
+        }
+        return `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())} ${pad(date.getHours())}:${pad(date.getMinutes())}`;
+    }
+
+    function formatDates() {
+        const format = localStorage.getItem('date-format') || 'iso';
+        document.querySelectorAll('time.item-date[datetime]').forEach(el => {
+            // SQLite writes "2006-01-02 15:04:05" in UTC
+            let value = el.getAttribute('datetime');
+            if (/^\d{4}-\d{2}-\d{2} \d{2}:\d{2}/.test(value)) value = value.replace(' ', 'T') + 'Z';
+            const date = new Date(value);
+            if (isNaN(date)) return;
+            el.textContent = formatDate(date, format);
+            el.title = date.toLocaleString();
+        });
+    }
+
+    async function load() {
+        formatDates();
+        try {
+            const response = await fetch(BASE_PATH + '/settings/preferences');
+            if (!response.ok) return;
+            const prefs = await response.json();
+            if (prefs.theme !== (localStorage.getItem('theme') || 'light')) {
+                showTheme(prefs.theme);
+            }
+            if (prefs.date_format !== (localStorage.getItem('date-format') || 'iso')) {
+                localStorage.setItem('date-format', prefs.date_format);
+                formatDates();
+            }
+        } catch (e) { }
+    }
+
+    document.addEventListener('DOMContentLoaded', load);
+    document.addEventListener('htmx:afterSettle', formatDates);
+})();
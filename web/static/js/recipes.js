@@ -27,7 +27,7 @@ function initRecipeTagInput() {
     });
 
     // Load suggestions
-    fetch('/tags/suggestions')
+    fetch(BASE_PATH + '/tags/suggestions')
         .then(r => r.text())
         .then(html => {
             const suggestions = document.getElementById('recipe-tag-suggestions');
@@ -66,6 +66,9 @@ function openRecipeModal() {
     document.getElementById('recipe-form').reset();
     document.getElementById('recipe-edit-id').value = '';
     document.getElementById('recipe-thumbnail').value = '';
+    document.getElementById('recipe-original-language').value = '';
+    document.getElementById('recipe-original-ingredients').value = '';
+    document.getElementById('recipe-original-instructions').value = '';
     document.getElementById('thumbnail-preview-container').style.display = 'none';
     document.getElementById('image-preview-grid').innerHTML = '';
     document.getElementById('recipe-images-name').textContent = 'No images selected';
@@ -102,7 +105,10 @@ function importRecipeFromURL() {
     status.className = 'notification is-info is-light';
     status.innerHTML = '<i class="fas fa-spinner fa-pulse mr-2"></i> Importing recipe...';
 
-    fetch('/recipes/import?url=' + encodeURIComponent(url))
+    const translate = document.getElementById('import-translate');
+    const translateParam = translate && !translate.checked ? '&translate=0' : '';
+
+    fetch(BASE_PATH + '/recipes/import?url=' + encodeURIComponent(url) + translateParam)
         .then(r => {
             if (!r.ok) throw new Error('Failed to import recipe');
             return r.json();
@@ -116,9 +122,16 @@ function importRecipeFromURL() {
             document.getElementById('recipe-instructions').value = data.instructions || '';
             document.getElementById('recipe-source-url').value = data.source_url || '';
 
+            // Keep the untranslated text so it can be shown alongside the translation
+            if (data.original) {
+                document.getElementById('recipe-original-language').value = data.original.original_language || '';
+                document.getElementById('recipe-original-ingredients').value = data.original.original_ingredients || '';
+                document.getElementById('recipe-original-instructions').value = data.original.original_instructions || '';
+            }
+
             if (data.thumbnail) {
                 document.getElementById('recipe-thumbnail').value = data.thumbnail;
-                document.getElementById('thumbnail-preview').src = data.thumbnail;
+                document.getElementById('thumbnail-preview').src = appURL(data.thumbnail);
                 document.getElementById('thumbnail-preview-container').style.display = 'block';
             }
         })
@@ -159,15 +172,21 @@ function submitRecipe() {
     const formData = new FormData(form);
     const editId = document.getElementById('recipe-edit-id').value;
 
-    const url = editId ? '/recipes/' + editId : '/recipes';
+    const url = BASE_PATH + (editId ? '/recipes/' + editId : '/recipes');
+    clearFieldErrors(form);
 
     fetch(url, {
         method: 'POST',
         body: formData,
         headers: { 'HX-Request': 'true' }
     })
-        .then(r => r.text())
+        .then(async r => {
+            if (await handleFieldErrors(form, r)) return null;
+            if (!r.ok) throw new Error(await r.text());
+            return r.text();
+        })
         .then(html => {
+            if (html === null) return;
             // If we are on the recipes list page, update the grid.
             const target = document.getElementById('main-search-target');
             if (target) {
@@ -186,7 +205,7 @@ function submitRecipe() {
 // Edit recipe
 function editRecipe(id) {
     // UPDATED: Explicitly request JSON
-    fetch('/recipes/' + id, {
+    fetch(BASE_PATH + '/recipes/' + id, {
         headers: {
             'Accept': 'application/json'
         }
@@ -207,7 +226,7 @@ function editRecipe(id) {
             document.getElementById('recipe-thumbnail').value = recipe.thumbnail || '';
 
             if (recipe.thumbnail) {
-                document.getElementById('thumbnail-preview').src = recipe.thumbnail;
+                document.getElementById('thumbnail-preview').src = appURL(recipe.thumbnail);
                 document.getElementById('thumbnail-preview-container').style.display = 'block';
             } else {
                 document.getElementById('thumbnail-preview-container').style.display = 'none';
@@ -0,0 +1,73 @@
+// Manual ordering of the bookmarks and lists pages. While a page's sort menu
+// is on "Manual order", the entries of #main-search-target that have a
+// data-item-id can be dragged into place; dropping one posts the ids of the
+// shown entries, in their new order, to /items/reorder.
+(function () {
+    let dragged = null;
+    let before = '';
+
+    function entries(list) {
+        return Array.from(list.children).filter(el => el.dataset.itemId);
+    }
+
+    function ids(list) {
+        return entries(list).map(el => el.dataset.itemId);
+    }
+
+    function refresh() {
+        const list = document.getElementById('main-search-target');
+        if (!list) return;
+        const select = document.querySelector('select[name="sort"]');
+        const manual = select !== null && select.value === 'manual';
+        list.classList.toggle('is-reorderable', manual);
+        entries(list).forEach(el => { el.draggable = manual; });
+    }
+
+    document.addEventListener('DOMContentLoaded', refresh);
+    document.addEventListener('htmx:afterSettle', refresh);
+
+    document.addEventListener('dragstart', e => {
+        const el = e.target.closest && e.target.closest('#main-search-target > [data-item-id]');
+        if (!el || !el.draggable) return;
+        dragged = el;
+        before = ids(el.parentNode).join(',');
+        el.classList.add('is-dragging');
+        e.dataTransfer.effectAllowed = 'move';
+        e.dataTransfer.setData('text/plain', el.dataset.itemId);
+    });
+
+    document.addEventListener('dragover', e => {
+        if (!dragged) return;
+        const over = e.target.closest('#main-search-target > [data-item-id]');
+        if (!over || over.parentNode !== dragged.parentNode) return;
+        e.preventDefault();
+        if (over === dragged) return;
+        const shown = entries(over.parentNode);
+        if (shown.indexOf(dragged) < shown.indexOf(over)) {
+            over.after(dragged);
+        } else {
+            over.before(dragged);
+        }
+    });
+
+    document.addEventListener('drop', e => {
+        if (dragged) e.preventDefault();
+    });
+
+    document.addEventListener('dragend', async () => {
+        if (!dragged) return;
+        const list = dragged.parentNode;
+        dragged.classList.remove('is-dragging');
+        dragged = null;
+        const order = ids(list);
+        if (order.join(',') === before) return;
+
+        const formData = new FormData();
+        formData.append('ids', order.join(','));
+        const response = await fetch(`${BASE_PATH}/items/reorder`, { method: 'POST', body: formData });
+        if (!response.ok) {
+            alert('Failed to save the order: ' + await response.text());
+            location.reload();
+        }
+    });
+})();
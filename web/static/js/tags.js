@@ -50,7 +50,7 @@ class TagInput {
     }
 
     fetchSuggestions() {
-        fetch('/api/tags')
+        fetch(BASE_PATH + '/api/tags')
             .then(res => res.json())
             .then(data => {
                 this.suggestions = data || [];
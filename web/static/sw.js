@@ -3,9 +3,10 @@
 
 const CACHE_NAME = 'infokeep-v2';
 
-// Cache only static assets on install
+// Cache only static assets on install. Paths are relative to this script so
+// they work when the app is served under a base path.
 const STATIC_ASSETS = [
-    '/static/css/bulma.min.css',
+    'static/css/bulma.min.css',
 ];
 
 self.addEventListener('install', (event) => {
@@ -75,7 +76,7 @@ self.addEventListener('notificationclick', function (event) {
                 }
             }
             if (clients.openWindow) {
-                return clients.openWindow('/reminders');
+                return clients.openWindow('reminders');
             }
         })
     );
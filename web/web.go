@@ -0,0 +1,31 @@
+// Package web holds the templates and static files, embedded in the binary
+// so that it runs from any directory without the web folder next to it.
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// The static files are listed one by one so that files uploaded into
+// web/static by older versions are never built into the binary
+//
+//go:embed static/css static/js static/icons static/favicon.svg static/manifest.json static/sw.js
+var static embed.FS
+
+// Templates are the page templates, with the fragments in fragments/
+var Templates = sub(templates, "templates")
+
+// Static are the files served under /static/
+var Static = sub(static, "static")
+
+func sub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}